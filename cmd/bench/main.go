@@ -0,0 +1,219 @@
+// Command bench measures the throughput and latency of the storage backends.
+// Every backend is driven through the storage.Storage interface, so any type
+// accepted by storage.NewStorage can be benchmarked the same way.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+// Workload names accepted by the -workload flag
+const (
+	ReadHeavy  = "read-heavy"  // 95% reads, 5% writes
+	WriteHeavy = "write-heavy" // 5% reads, 95% writes
+	Mixed      = "mixed"       // 50% reads, 50% writes
+	Scan       = "scan"        // every operation reads the ScanSize largest keys
+)
+
+// readRatio is the fraction of point operations that are reads for each workload
+var readRatio = map[string]float64{
+	ReadHeavy:  0.95,
+	WriteHeavy: 0.05,
+	Mixed:      0.5,
+	Scan:       0,
+}
+
+// Config describes one benchmark run
+type Config struct {
+	Workload    string
+	Concurrency int   // number of goroutines issuing operations
+	Ops         int   // total operations across all goroutines
+	Keys        int   // size of the keyspace, preloaded before timing starts
+	ValueSize   int   // bytes per value
+	ScanSize    int   // entries read per scan operation
+	Seed        int64 // makes the key and operation sequence reproducible
+}
+
+// DefaultConfig returns the default benchmark configuration
+func DefaultConfig() Config {
+	return Config{
+		Workload:    Mixed,
+		Concurrency: 8,
+		Ops:         100000,
+		Keys:        10000,
+		ValueSize:   100,
+		ScanSize:    100,
+		Seed:        1,
+	}
+}
+
+// Result summarizes a benchmark run
+type Result struct {
+	Ops        int
+	Errors     int
+	Elapsed    time.Duration
+	Throughput float64 // operations per second
+	P50        time.Duration
+	P95        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+}
+
+// Print writes the result in a human readable form
+func (r Result) Print(w io.Writer) {
+	fmt.Fprintf(w, "ops:        %d (%d errors) in %v\n", r.Ops, r.Errors, r.Elapsed)
+	fmt.Fprintf(w, "throughput: %.0f ops/s\n", r.Throughput)
+	fmt.Fprintf(w, "latency:    p50=%v p95=%v p99=%v max=%v\n", r.P50, r.P95, r.P99, r.Max)
+}
+
+// Run preloads store with cfg.Keys keys and then runs the workload against it
+func Run(store storage.Storage, cfg Config) (Result, error) {
+	ratio, ok := readRatio[cfg.Workload]
+	if !ok {
+		return Result{}, fmt.Errorf("unknown workload: %s", cfg.Workload)
+	}
+	if cfg.Concurrency <= 0 || cfg.Ops <= 0 || cfg.Keys <= 0 {
+		return Result{}, fmt.Errorf("concurrency, ops and keys must be positive")
+	}
+
+	value := make([]byte, cfg.ValueSize)
+	rand.New(rand.NewSource(cfg.Seed)).Read(value)
+
+	for i := 0; i < cfg.Keys; i++ {
+		if err := store.Put(benchKey(i), value); err != nil {
+			return Result{}, fmt.Errorf("failed to preload: %v", err)
+		}
+	}
+
+	latencies := make([][]time.Duration, cfg.Concurrency)
+	errCounts := make([]int, cfg.Concurrency)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < cfg.Concurrency; w++ {
+		// Spread the remainder so exactly cfg.Ops operations run
+		ops := cfg.Ops / cfg.Concurrency
+		if w < cfg.Ops%cfg.Concurrency {
+			ops++
+		}
+
+		wg.Add(1)
+		go func(w, ops int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(cfg.Seed + int64(w) + 1))
+			latencies[w] = make([]time.Duration, 0, ops)
+
+			for i := 0; i < ops; i++ {
+				key := benchKey(rng.Intn(cfg.Keys))
+
+				var err error
+				opStart := time.Now()
+				switch {
+				case cfg.Workload == Scan:
+					_, err = store.Tail(cfg.ScanSize)
+				case rng.Float64() < ratio:
+					_, err = store.Get(key)
+				default:
+					err = store.Put(key, value)
+				}
+				latencies[w] = append(latencies[w], time.Since(opStart))
+
+				if err != nil {
+					errCounts[w]++
+				}
+			}
+		}(w, ops)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	var all []time.Duration
+	result := Result{Elapsed: elapsed}
+	for w := range latencies {
+		all = append(all, latencies[w]...)
+		result.Errors += errCounts[w]
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+
+	result.Ops = len(all)
+	result.Throughput = float64(len(all)) / elapsed.Seconds()
+	result.P50 = percentile(all, 0.50)
+	result.P95 = percentile(all, 0.95)
+	result.P99 = percentile(all, 0.99)
+	result.Max = percentile(all, 1)
+	return result, nil
+}
+
+// benchKey returns the key for index i. Keys are zero-padded so their
+// byte order matches their numeric order.
+func benchKey(i int) []byte {
+	return []byte(fmt.Sprintf("key%010d", i))
+}
+
+// percentile returns the p-th percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p * float64(len(sorted)-1))
+	return sorted[i]
+}
+
+// openStorage opens a backend of the given type under dir. File-based
+// backends get a file inside dir; directory-based ones use dir itself.
+func openStorage(backend, dir string) (storage.Storage, error) {
+	storageType := storage.StorageType(backend)
+	if storageType == storage.CustomStorage {
+		return storage.NewStorage(storageType, filepath.Join(dir, "bench.db"))
+	}
+	return storage.NewStorage(storageType, dir)
+}
+
+func main() {
+	cfg := DefaultConfig()
+	backend := flag.String("backend", "badger", "Storage backend (badger or custom)")
+	dir := flag.String("dir", "", "Directory for backend data (default: a temporary directory)")
+	flag.StringVar(&cfg.Workload, "workload", cfg.Workload, "Workload (read-heavy, write-heavy, mixed or scan)")
+	flag.IntVar(&cfg.Concurrency, "concurrency", cfg.Concurrency, "Number of concurrent workers")
+	flag.IntVar(&cfg.Ops, "ops", cfg.Ops, "Total number of operations")
+	flag.IntVar(&cfg.Keys, "keys", cfg.Keys, "Number of keys to preload and operate on")
+	flag.IntVar(&cfg.ValueSize, "value-size", cfg.ValueSize, "Value size in bytes")
+	flag.IntVar(&cfg.ScanSize, "scan-size", cfg.ScanSize, "Entries read per scan")
+	flag.Int64Var(&cfg.Seed, "seed", cfg.Seed, "Random seed")
+	flag.Parse()
+
+	if *dir == "" {
+		tmp, err := os.MkdirTemp("", "bench")
+		if err != nil {
+			log.Fatalf("Failed to create data directory: %v", err)
+		}
+		defer os.RemoveAll(tmp)
+		*dir = tmp
+	}
+
+	store, err := openStorage(*backend, *dir)
+	if err != nil {
+		log.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	fmt.Printf("backend=%s workload=%s concurrency=%d keys=%d value-size=%d\n",
+		*backend, cfg.Workload, cfg.Concurrency, cfg.Keys, cfg.ValueSize)
+
+	result, err := Run(store, cfg)
+	if err != nil {
+		log.Fatalf("Benchmark failed: %v", err)
+	}
+	result.Print(os.Stdout)
+}
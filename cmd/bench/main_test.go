@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRun_Smoke(t *testing.T) {
+	for _, workload := range []string{ReadHeavy, WriteHeavy, Mixed, Scan} {
+		store, err := openStorage("badger", t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to open storage: %v", err)
+		}
+
+		cfg := DefaultConfig()
+		cfg.Workload = workload
+		cfg.Concurrency = 4
+		cfg.Ops = 200
+		cfg.Keys = 50
+		cfg.ScanSize = 10
+
+		result, err := Run(store, cfg)
+		store.Close()
+		if err != nil {
+			t.Fatalf("%s: Run failed: %v", workload, err)
+		}
+		if result.Ops != cfg.Ops {
+			t.Errorf("%s: Expected %d ops, got %d", workload, cfg.Ops, result.Ops)
+		}
+		if result.Errors != 0 {
+			t.Errorf("%s: Expected no errors, got %d", workload, result.Errors)
+		}
+		if result.Throughput <= 0 {
+			t.Errorf("%s: Expected non-zero throughput, got %f", workload, result.Throughput)
+		}
+	}
+}
+
+func TestRun_UnknownWorkload(t *testing.T) {
+	store, err := openStorage("badger", t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := DefaultConfig()
+	cfg.Workload = "bogus"
+	if _, err := Run(store, cfg); err == nil {
+		t.Error("Expected error for unknown workload")
+	}
+}
@@ -19,23 +19,12 @@ func main() {
 		"localhost:8083",
 	}
 
-	var c *client.Client
-	var err error
-
-	// Find a working node
-	for _, addr := range nodes {
-		fmt.Printf("Trying to connect to %s...\n", addr)
-		c, err = client.New(addr)
-		if err == nil {
-			fmt.Printf("✓ Connected to %s\n", addr)
-			break
-		}
-		fmt.Printf("✗ Failed to connect to %s: %v\n", addr, err)
-	}
-
-	if c == nil {
-		log.Fatalf("Failed to connect to any node")
+	// Connect to the first working node; writes follow the leader
+	c, err := client.New(nodes)
+	if err != nil {
+		log.Fatalf("Failed to connect to any node: %v", err)
 	}
+	fmt.Println("✓ Connected to the cluster")
 	defer c.Close()
 
 	fmt.Println("\n1. Writing data to Raft cluster...")
@@ -49,13 +38,17 @@ func main() {
 		"cache:key": "cached_value",
 	}
 
+	// Write it all in one batch, committed as a single log entry
+	batch := c.NewBatch()
 	for key, value := range data {
-		if err := c.Put([]byte(key), []byte(value)); err != nil {
-			log.Printf("Failed to put %s: %v", key, err)
-		} else {
+		batch.Put([]byte(key), []byte(value))
+	}
+	if err := batch.Commit(); err != nil {
+		log.Printf("Failed to write batch: %v", err)
+	} else {
+		for key, value := range data {
 			fmt.Printf("  ✓ Stored: %s = %s\n", key, value)
 		}
-		time.Sleep(100 * time.Millisecond)
 	}
 
 	fmt.Println("\n2. Reading data from Raft cluster...")
@@ -76,7 +69,7 @@ func main() {
 	for i, addr := range nodes {
 		fmt.Printf("\nTesting node %d (%s):\n", i+1, addr)
 
-		nodeClient, err := client.New(addr)
+		nodeClient, err := client.NewClient(addr)
 		if err != nil {
 			fmt.Printf("  ✗ Failed to connect: %v\n", err)
 			continue
@@ -117,7 +110,7 @@ func main() {
 	for i, addr := range nodes {
 		fmt.Printf("\nNode %d (%s):\n", i+1, addr)
 
-		nodeClient, err := client.New(addr)
+		nodeClient, err := client.NewClient(addr)
 		if err != nil {
 			fmt.Printf("  ✗ Failed to connect: %v\n", err)
 			continue
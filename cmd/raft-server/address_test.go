@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"godatabase/internal/network"
+)
+
+func TestResolveAddress(t *testing.T) {
+	grpcAddr, err := resolveAddress(":50051", "")
+	if err != nil {
+		t.Fatalf("resolveAddress failed: %v", err)
+	}
+	if grpcAddr != "localhost:50051" {
+		t.Errorf("Expected localhost:50051, got %s", grpcAddr)
+	}
+
+	grpcAddr, err = resolveAddress("0.0.0.0:50052", "node2:50052")
+	if err != nil {
+		t.Fatalf("resolveAddress failed: %v", err)
+	}
+	if grpcAddr != "node2:50052" {
+		t.Errorf("Expected node2:50052, got %s", grpcAddr)
+	}
+
+	if _, err := resolveAddress(":50051", "node2:50052"); !errors.Is(err, network.ErrAddressMismatch) {
+		t.Errorf("Expected ErrAddressMismatch, got %v", err)
+	}
+	if _, err := resolveAddress("50051", ""); !errors.Is(err, network.ErrInvalidAddress) {
+		t.Errorf("Expected ErrInvalidAddress, got %v", err)
+	}
+}
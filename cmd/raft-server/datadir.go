@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"godatabase/internal/storage"
+)
+
+// ErrDataDirConflict is returned when a node's data directory is, contains,
+// or is inside the data directory of another node in this process
+var ErrDataDirConflict = errors.New("data directory conflict")
+
+// dataDirs tracks which node owns each data directory in this process
+var dataDirs = struct {
+	mu    sync.Mutex
+	owner map[string]string // absolute dir -> node ID
+}{owner: make(map[string]string)}
+
+// nodeDataDir returns the directory a node keeps its data in. Each node
+// gets its own subdirectory of root, so several nodes can share one root.
+func nodeDataDir(root, nodeID string) string {
+	return filepath.Join(root, nodeID)
+}
+
+// claimDataDir records that nodeID owns dir, failing if dir overlaps a
+// directory already claimed by a node in this process
+func claimDataDir(nodeID, dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve data directory: %v", err)
+	}
+
+	dataDirs.mu.Lock()
+	defer dataDirs.mu.Unlock()
+
+	for other, owner := range dataDirs.owner {
+		if overlaps(abs, other) {
+			return fmt.Errorf("%w: node %s would use %s, which overlaps %s used by node %s",
+				ErrDataDirConflict, nodeID, abs, other, owner)
+		}
+	}
+	dataDirs.owner[abs] = nodeID
+	return nil
+}
+
+// releaseDataDir gives up a claim made by claimDataDir
+func releaseDataDir(dir string) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+
+	dataDirs.mu.Lock()
+	defer dataDirs.mu.Unlock()
+	delete(dataDirs.owner, abs)
+}
+
+// overlaps reports whether two cleaned absolute paths are the same or one
+// is inside the other
+func overlaps(a, b string) bool {
+	if a == b {
+		return true
+	}
+	sep := string(filepath.Separator)
+	return strings.HasPrefix(a, strings.TrimSuffix(b, sep)+sep) ||
+		strings.HasPrefix(b, strings.TrimSuffix(a, sep)+sep)
+}
+
+// nodeStorage releases its data directory claim when closed
+type nodeStorage struct {
+	storage.Storage
+	dir string
+}
+
+// Close closes the storage and releases its data directory
+func (s *nodeStorage) Close() error {
+	err := s.Storage.Close()
+	releaseDataDir(s.dir)
+	return err
+}
+
+// openNodeStorage claims dir for nodeID and opens storage of the given type
+// in it. Closing the storage releases the claim.
+func openNodeStorage(storageType, nodeID, dir string) (storage.Storage, error) {
+	if err := claimDataDir(nodeID, dir); err != nil {
+		return nil, err
+	}
+
+	var store storage.Storage
+	var err error
+
+	switch storageType {
+	case "badger":
+		store, err = storage.NewBadgerStorage(dir)
+	case "btree":
+		if err = os.MkdirAll(dir, 0755); err == nil {
+			store, err = storage.NewStorage(storage.CustomStorage, filepath.Join(dir, "btree.db"))
+		}
+	default:
+		err = fmt.Errorf("unknown storage type: %s", storageType)
+	}
+
+	if err != nil {
+		releaseDataDir(dir)
+		return nil, err
+	}
+	return &nodeStorage{Storage: store, dir: dir}, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenNodeStorage_DefaultDirsAreIsolated(t *testing.T) {
+	root := t.TempDir()
+
+	store1, err := openNodeStorage("badger", "node1", nodeDataDir(root, "node1"))
+	if err != nil {
+		t.Fatalf("Failed to open node1 storage: %v", err)
+	}
+	defer store1.Close()
+
+	store2, err := openNodeStorage("badger", "node2", nodeDataDir(root, "node2"))
+	if err != nil {
+		t.Fatalf("Failed to open node2 storage: %v", err)
+	}
+	defer store2.Close()
+
+	store1.Put([]byte("key"), []byte("node1"))
+	if _, err := store2.Get([]byte("key")); err == nil {
+		t.Error("Expected node2 not to see node1's data")
+	}
+}
+
+func TestOpenNodeStorage_OverlappingDirsConflict(t *testing.T) {
+	root := t.TempDir()
+
+	store, err := openNodeStorage("badger", "node1", root)
+	if err != nil {
+		t.Fatalf("Failed to open node1 storage: %v", err)
+	}
+
+	for _, dir := range []string{root, filepath.Join(root, "nested"), filepath.Dir(root)} {
+		if _, err := openNodeStorage("badger", "node2", dir); !errors.Is(err, ErrDataDirConflict) {
+			t.Errorf("Expected conflict for %s, got %v", dir, err)
+		}
+	}
+
+	// Closing the first node frees its directory
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	store, err = openNodeStorage("badger", "node2", root)
+	if err != nil {
+		t.Fatalf("Expected directory to be free after close, got %v", err)
+	}
+	store.Close()
+}
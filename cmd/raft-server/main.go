@@ -5,11 +5,11 @@ import (
 	"log"
 	"os"
 	"os/signal"
-	"strconv"
-	"strings"
+	"path/filepath"
 	"syscall"
 	"time"
 
+	"godatabase/internal/network"
 	"godatabase/internal/raft"
 	"godatabase/internal/rpc"
 	"godatabase/internal/storage"
@@ -17,53 +17,71 @@ import (
 
 func main() {
 	// Parse command line flags
-	addr := flag.String("addr", ":50051", "The server address")
+	addr := flag.String("addr", ":50051", "The address the gRPC server binds to")
+	advertise := flag.String("advertise", "", "The gRPC address peers and clients use to reach this node (default: -addr)")
 	nodeID := flag.String("id", "node1", "The node ID")
-	peers := flag.String("peers", "", "Comma-separated list of peer addresses (id:addr)")
+	peers := flag.String("peers", "", "Comma-separated list of peer gRPC addresses (id:addr)")
 	storageType := flag.String("storage", "badger", "Storage type (badger or btree)")
-	dataDir := flag.String("data", "data", "Data directory")
+	dataDir := flag.String("data", "data", "Data root directory; each node stores its data in a subdirectory named after its ID")
+	snapshotThreshold := flag.Int("snapshot-threshold", 10000, "Applied log entries to keep before compacting the log into a snapshot (0 disables)")
+	defaults := raft.DefaultRaftConfig()
+	electionMin := flag.Duration("election-timeout-min", defaults.ElectionTimeoutMin, "Shortest time a follower waits to hear from a leader before starting an election")
+	electionMax := flag.Duration("election-timeout-max", defaults.ElectionTimeoutMax, "Longest time a follower waits to hear from a leader before starting an election")
+	heartbeat := flag.Duration("heartbeat-interval", defaults.HeartbeatInterval, "How often a leader sends heartbeats; must be below -election-timeout-min")
+	monitorInterval := flag.Duration("leader-monitor-interval", raft.DefaultMonitorInterval, "How often to check for, and step down, extra leaders")
 	flag.Parse()
 
-	// Parse peers
+	grpcAddr, err := resolveAddress(*addr, *advertise)
+	if err != nil {
+		log.Fatalf("Invalid address: %v", err)
+	}
+
+	// Parse peers. Peers are named by their gRPC addresses, which serve
+	// Raft too.
 	peerMap := make(map[string]string)
 	if *peers != "" {
 		peerList := splitPeers(*peers)
 		for _, peer := range peerList {
 			parts := splitPeer(peer)
 			if len(parts) == 2 {
-				peerMap[parts[0]] = parts[1]
+				peerAddr, err := network.NormalizeAddress(parts[1])
+				if err != nil {
+					log.Fatalf("Invalid address for peer %s: %v", parts[0], err)
+				}
+				peerMap[parts[0]] = peerAddr
 			}
 		}
 	}
 
-	// Create storage
-	var store storage.Storage
-	var err error
-
-	switch *storageType {
-	case "badger":
-		store, err = storage.NewBadgerStorage(*dataDir)
-	case "btree":
-		store, err = storage.NewStorage(storage.CustomStorage, *dataDir)
-	default:
-		log.Fatalf("Unknown storage type: %s", *storageType)
-	}
-
+	// Create storage in this node's own data directory
+	nodeDir := nodeDataDir(*dataDir, *nodeID)
+	store, err := openNodeStorage(*storageType, *nodeID, nodeDir)
 	if err != nil {
 		log.Fatalf("Failed to create storage: %v", err)
 	}
-	defer store.Close()
 
 	// Get global cluster
 	globalCluster := raft.GetGlobalCluster()
 
-	// Calculate Raft RPC port (gRPC port + 1000 to avoid conflicts)
-	port := parsePort(*addr) + 1000
-	raftRPCAddr := ":" + strconv.Itoa(port)
-	log.Printf("gRPC address: %s, Raft RPC address: %s", *addr, raftRPCAddr)
-
 	// Create Raft node
-	node := raft.NewRaftNode(*nodeID, raftRPCAddr, peerMap, store)
+	node, err := raft.NewRaftNodeWithConfig(*nodeID, grpcAddr, peerMap, store, raft.RaftConfig{
+		ElectionTimeoutMin: *electionMin,
+		ElectionTimeoutMax: *electionMax,
+		HeartbeatInterval:  *heartbeat,
+	})
+	if err != nil {
+		log.Fatalf("Invalid Raft settings: %v", err)
+	}
+	node.SetSnapshotThreshold(*snapshotThreshold)
+
+	// Restore the term, vote and log this node had when it last stopped
+	stable, err := raft.NewFileStableStore(filepath.Join(nodeDir, "raft"))
+	if err != nil {
+		log.Fatalf("Failed to open raft state: %v", err)
+	}
+	if err := node.SetStableStore(stable); err != nil {
+		log.Fatalf("Failed to restore raft state: %v", err)
+	}
 
 	// Register with global cluster
 	err = globalCluster.RegisterNode(node)
@@ -71,23 +89,21 @@ func main() {
 		log.Fatalf("Failed to register node with global cluster: %v", err)
 	}
 
+	// Create Raft storage wrapper
+	raftStorage := raft.NewRaftStorage(globalCluster, *nodeID)
+
+	// Create the gRPC server, which serves Raft to peers alongside
+	// Storage to clients
+	server := rpc.NewServer(raftStorage)
+	node.RegisterService(server.GRPCServer())
+
 	// Start the node
 	if err := node.Start(); err != nil {
 		globalCluster.UnregisterNode(*nodeID)
 		log.Fatalf("Failed to start node: %v", err)
 	}
 
-	// Start RPC server
-	if err := node.StartRPCServer(); err != nil {
-		log.Printf("Failed to start RPC server: %v", err)
-		// Don't stop the node, let it continue without RPC for now
-	}
-
-	// Create Raft storage wrapper
-	raftStorage := raft.NewRaftStorage(globalCluster, *nodeID)
-
-	// Create and start gRPC server
-	server := rpc.NewServer(raftStorage)
+	// Start gRPC server
 	go func() {
 		if err := server.Start(*addr); err != nil {
 			log.Fatalf("Failed to start server: %v", err)
@@ -96,12 +112,13 @@ func main() {
 
 	log.Printf("Raft server started:")
 	log.Printf("  Node ID: %s", *nodeID)
-	log.Printf("  Address: %s", *addr)
+	log.Printf("  Address: %s (bound to %s)", grpcAddr, *addr)
 	log.Printf("  Peers: %v", peerMap)
 	log.Printf("  Storage: %s", *storageType)
+	log.Printf("  Data: %s", nodeDir)
 
 	// Start heartbeat monitor
-	globalCluster.StartHeartbeatMonitor()
+	globalCluster.StartHeartbeatMonitorWithInterval(*monitorInterval)
 
 	// Print cluster info periodically
 	go func() {
@@ -111,6 +128,10 @@ func main() {
 		for range ticker.C {
 			info := globalCluster.GetClusterInfo()
 			log.Printf("Cluster info: %+v", info)
+
+			m := globalCluster.Metrics()
+			log.Printf("Cluster metrics: leader=%q term=%d elections=%d votes=%d heartbeats=%d",
+				m.Leader, m.MaxTerm, m.ElectionsStarted, m.VotesGranted, m.HeartbeatsSent)
 		}
 	}()
 
@@ -121,8 +142,38 @@ func main() {
 
 	// Graceful shutdown
 	log.Println("Shutting down server...")
+	shutdown(globalCluster, node, server, store)
+	if err := stable.Close(); err != nil {
+		log.Printf("Failed to close raft state: %v", err)
+	}
+}
+
+// transferTimeout bounds how long a leader waits for a successor on shutdown
+const transferTimeout = 2 * time.Second
+
+// shutdown stops a node in an order that avoids losing work: leadership
+// is handed off first so the cluster stays available, then client requests
+// are drained, Raft stops, and storage is flushed before the node leaves
+// the cluster.
+func shutdown(cluster *raft.GlobalCluster, node *raft.RaftNode, server *rpc.Server, store storage.Storage) {
+	if node.IsLeader() {
+		if err := node.TransferLeadership(transferTimeout); err != nil {
+			log.Printf("Leadership transfer failed: %v", err)
+		}
+	}
+
+	// Stop accepting clients, end open streams and wait briefly for
+	// in-flight requests
 	server.Stop()
-	globalCluster.UnregisterNode(*nodeID)
+
+	// Stop Raft work and close connections to peers
+	node.Stop()
+
+	if err := store.Close(); err != nil {
+		log.Printf("Failed to close storage: %v", err)
+	}
+
+	cluster.UnregisterNode(node.GetID())
 }
 
 // splitPeers splits a comma-separated list of peers
@@ -157,15 +208,15 @@ func splitPeer(peer string) []string {
 	return []string{peer}
 }
 
-// parsePort extracts the port number from an address string
-func parsePort(addr string) int {
-	parts := strings.Split(addr, ":")
-	if len(parts) < 2 {
-		return 50051 // default port
+// resolveAddress validates the gRPC bind address and the address
+// advertised for it, which defaults to the bind address, and returns the
+// advertised address normalized. Peers reach both Raft and Storage there.
+func resolveAddress(bind, advertise string) (string, error) {
+	if advertise == "" {
+		advertise = bind
 	}
-	port, err := strconv.Atoi(parts[len(parts)-1])
-	if err != nil {
-		return 50051 // default port
+	if err := network.CheckAdvertised(bind, advertise); err != nil {
+		return "", err
 	}
-	return port
+	return network.NormalizeAddress(advertise)
 }
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"godatabase/internal/raft"
+	"godatabase/internal/rpc"
+	"godatabase/internal/storage"
+)
+
+// closeRecorder records whether storage was closed and whether its node
+// was still leader at that moment
+type closeRecorder struct {
+	storage.Storage
+	node          *raft.RaftNode
+	closed        bool
+	leaderAtClose bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	c.leaderAtClose = c.node.IsLeader()
+	return c.Storage.Close()
+}
+
+func freeAddr(t *testing.T) string {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+func TestShutdown_TransfersLeadershipAndFlushes(t *testing.T) {
+	cluster := raft.GetGlobalCluster()
+
+	raftAddrs := make(map[string]string)
+	for i := 1; i <= 3; i++ {
+		_, port, _ := net.SplitHostPort(freeAddr(t))
+		raftAddrs[fmt.Sprintf("shutdown%d", i)] = ":" + port
+	}
+
+	stores := make(map[string]*closeRecorder)
+	servers := make(map[string]*rpc.Server)
+	for id, addr := range raftAddrs {
+		peers := make(map[string]string)
+		for peerID, peerAddr := range raftAddrs {
+			if peerID != id {
+				peers[peerID] = peerAddr
+			}
+		}
+
+		badger, err := storage.NewBadgerStorage(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		store := &closeRecorder{Storage: badger}
+		node := raft.NewRaftNode(id, addr, peers, store)
+		store.node = node
+		stores[id] = store
+
+		if err := cluster.RegisterNode(node); err != nil {
+			t.Fatal(err)
+		}
+		if err := node.StartRPCServer(); err != nil {
+			t.Fatal(err)
+		}
+		if err := node.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		server := rpc.NewServer(raft.NewRaftStorage(cluster, id))
+		go server.Start(freeAddr(t))
+		servers[id] = server
+	}
+	t.Cleanup(func() {
+		for id := range raftAddrs {
+			servers[id].Stop()
+			if node, err := cluster.GetNode(id); err == nil {
+				cluster.UnregisterNode(id)
+				node.Stop()
+				stores[id].Close()
+			}
+		}
+	})
+
+	var leader *raft.RaftNode
+	deadline := time.Now().Add(5 * time.Second)
+	for leader == nil && time.Now().Before(deadline) {
+		leader, _ = cluster.GetLeader()
+		time.Sleep(50 * time.Millisecond)
+	}
+	if leader == nil {
+		t.Fatal("no leader elected")
+	}
+	if err := leader.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	id := leader.GetID()
+	shutdown(cluster, leader, servers[id], stores[id])
+
+	if !stores[id].closed {
+		t.Error("Expected storage to be closed on shutdown")
+	}
+	if stores[id].leaderAtClose {
+		t.Error("Expected leadership to be transferred before storage was closed")
+	}
+	if _, err := cluster.GetNode(id); err == nil {
+		t.Error("Expected node to be unregistered")
+	}
+
+	newLeader, err := cluster.GetLeader()
+	if err != nil {
+		t.Fatalf("Expected a new leader after shutdown: %v", err)
+	}
+	if newLeader.GetID() == id {
+		t.Error("Expected a different node to lead")
+	}
+}
@@ -14,7 +14,10 @@ import (
 func main() {
 	// Parse command line flags
 	addr := flag.String("addr", ":50051", "The server address")
-	storageType := flag.String("storage", "badger", "Storage type (badger or btree)")
+	storageType := flag.String("storage", "badger", "Storage type (badger, btree or memory)")
+	slowThreshold := flag.Duration("slow-threshold", rpc.DefaultSlowThreshold, "Latency from which operations are logged as slow")
+	logSlow := flag.Bool("log-slow", true, "Log operations slower than -slow-threshold")
+	token := flag.String("token", "", "Bearer token clients must present (empty disables authentication)")
 	flag.Parse()
 	
 	// Create storage
@@ -26,6 +29,8 @@ func main() {
 		store, err = storage.NewBadgerStorage("data")
 	case "btree":
 		store, err = storage.NewStorage(storage.CustomStorage, "data")
+	case "memory":
+		store, err = storage.NewStorage(storage.MemStorageType, "")
 	default:
 		log.Fatalf("Unknown storage type: %s", *storageType)
 	}
@@ -36,7 +41,16 @@ func main() {
 	defer store.Close()
 	
 	// Create and start gRPC server
-	server := rpc.NewServer(store)
+	serverOpts := []rpc.ServerOption{rpc.WithSlowThreshold(*slowThreshold), rpc.WithSlowLogging(*logSlow)}
+	var server *rpc.Server
+	if *token != "" {
+		server, err = rpc.NewServerWithAuth(store, *token, serverOpts...)
+		if err != nil {
+			log.Fatalf("Failed to create server: %v", err)
+		}
+	} else {
+		server = rpc.NewServer(store, serverOpts...)
+	}
 	go func() {
 		if err := server.Start(*addr); err != nil {
 			log.Fatalf("Failed to start server: %v", err)
@@ -14,7 +14,7 @@ func main() {
 	fmt.Println("==============================")
 	
 	// Connect to primary node
-	primary, err := client.New("localhost:8080")
+	primary, err := client.NewClient("localhost:8080")
 	if err != nil {
 		log.Fatalf("Failed to connect to primary: %v", err)
 	}
@@ -63,7 +63,7 @@ func main() {
 	fmt.Println("\n3. Testing direct replica access...")
 	
 	// Connect directly to a replica
-	replica1, err := client.New("localhost:8081")
+	replica1, err := client.NewClient("localhost:8081")
 	if err != nil {
 		log.Printf("Failed to connect to replica: %v", err)
 	} else {
@@ -100,7 +100,7 @@ func main() {
 	}
 	
 	for name, addr := range nodes {
-		node, err := client.New(addr)
+		node, err := client.NewClient(addr)
 		if err != nil {
 			log.Printf("  ✗ Failed to connect to %s: %v", name, err)
 			continue
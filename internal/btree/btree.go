@@ -6,27 +6,220 @@ package btree
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"sort"
 )
 
 // BTree represents the overall B+Tree data structure.
 // A B+Tree is a self-balancing tree data structure that maintains sorted data
 // and allows searches, sequential access, insertions, and deletions in logarithmic time.
 type BTree struct {
-	root *Node // The root node of the tree
-	size int   // The number of keys in the tree
+	root    *Node      // The root node of the tree
+	size    int        // The number of keys in the tree
+	minFill float64    // Fraction of a page below which a non-root node is rebalanced
+	cmp     Comparator // Orders keys; fixed for the lifetime of the tree
+	version uint64     // Bumped on every split, merge and redistribution; see Cursor
+	lastID  uint64     // The last node ID handed out by nodeID
+
+	// Size limits, fixed for the lifetime of the tree; see Options
+	pageSize   int
+	maxKeySize int
+	maxValSize int
+
+	observer RebalanceObserver // Told about merges and redistributions, if set
+}
+
+var (
+	// ErrKeyNotFound is returned when a key is not in the tree
+	ErrKeyNotFound = errors.New("key not found")
+
+	// ErrKeyExists is returned when inserting a key the tree already holds
+	ErrKeyExists = errors.New("key already exists")
+)
+
+// Options sets a tree's page size and the largest keys and values it
+// accepts. A zero field takes the default: BTREE_PAGE_SIZE,
+// BTREE_MAX_KEY_SIZE or BTREE_MAX_VAL_SIZE.
+//
+// The largest key and value needn't fit in a page together; a pair that
+// doesn't is refused when it is written. Internal nodes must hold at
+// least three of the largest keys, so nodes can always be split.
+type Options struct {
+	// PageSize is the size of a node when it is serialized, in bytes.
+	// Nodes are split once they reach it. At most MaxPageSize.
+	PageSize int
+
+	// MaxKeySize and MaxValueSize bound a single key and value, in bytes
+	MaxKeySize   int
+	MaxValueSize int
+}
+
+const (
+	// MinPageSize and MaxPageSize bound Options.PageSize. Entry offsets
+	// are 16 bits, and a node may grow past its page by one entry before
+	// it is split.
+	MinPageSize = 256
+	MaxPageSize = 32768
+)
+
+// withDefaults returns o with its zero fields set to the defaults
+func (o Options) withDefaults() Options {
+	if o.PageSize == 0 {
+		o.PageSize = BTREE_PAGE_SIZE
+	}
+	if o.MaxKeySize == 0 {
+		o.MaxKeySize = BTREE_MAX_KEY_SIZE
+	}
+	if o.MaxValueSize == 0 {
+		o.MaxValueSize = BTREE_MAX_VAL_SIZE
+	}
+	return o
+}
+
+// validate reports why o can't be used for a tree, if it can't
+func (o Options) validate() error {
+	if o.PageSize < MinPageSize || o.PageSize > MaxPageSize {
+		return fmt.Errorf("page size %d is outside [%d, %d]", o.PageSize, MinPageSize, MaxPageSize)
+	}
+	if o.MaxKeySize < 1 || o.MaxValueSize < 1 || o.MaxKeySize > 0xFFFF || o.MaxValueSize > 0xFFFF {
+		return errors.New("max key and value sizes must be between 1 and 65535")
+	}
+	if internalEntrySize(o.MaxKeySize)*3+4+8 > o.PageSize {
+		return fmt.Errorf("a %d-byte page can't hold three %d-byte keys in an internal node", o.PageSize, o.MaxKeySize)
+	}
+	return nil
+}
+
+// leafEntrySize returns how many bytes a key/value pair takes up in a
+// leaf: its offset, its lengths, and the key and value themselves
+func leafEntrySize(keyLen, valLen int) int {
+	return 2 + 4 + keyLen + valLen
+}
+
+// internalEntrySize returns how many bytes a key takes up in an internal
+// node, including one child pointer
+func internalEntrySize(keyLen int) int {
+	return 2 + 4 + keyLen + 8
 }
 
+// Comparator orders keys. It returns a negative number if a sorts before b,
+// zero if they are equal, and a positive number if a sorts after b.
+type Comparator func(a, b []byte) int
+
 // NewBTree creates a new B+ tree with an empty leaf node as the root.
+// Keys are ordered byte-wise with bytes.Compare, and the tree has the
+// default page size and size limits.
 //
 // Returns:
 //   - A pointer to a new BTree instance
 func NewBTree() *BTree {
+	return NewBTreeWithComparator(bytes.Compare)
+}
+
+// NewBTreeWithComparator creates a new B+ tree whose keys are ordered by cmp.
+// Keys that cmp considers equal are the same key, so a case-insensitive
+// comparator makes "key" and "KEY" interchangeable.
+//
+// The comparator determines where every key lives in the tree. A tree built
+// with one comparator must always be reopened with the same one; switching
+// comparators on existing data corrupts the ordering and makes keys
+// unreachable.
+//
+// Parameters:
+//   - cmp: The key comparator
+//
+// Returns:
+//   - A pointer to a new BTree instance
+func NewBTreeWithComparator(cmp Comparator) *BTree {
+	t, _ := NewBTreeWithOptions(cmp, Options{})
+	return t
+}
+
+// NewBTreeWithOptions creates a new B+ tree whose keys are ordered by cmp,
+// with the page size and size limits in opts. Like the comparator, the
+// page size must stay the same when the tree is written and loaded again.
+//
+// Parameters:
+//   - cmp: The key comparator
+//   - opts: The page size and size limits; zero fields take the defaults
+//
+// Returns:
+//   - A pointer to a new BTree instance
+//   - An error if opts are out of range
+func NewBTreeWithOptions(cmp Comparator, opts Options) (*BTree, error) {
+	opts = opts.withDefaults()
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
 	// Create a new leaf node as the root
 	root := NewNode(BNODE_LEAF)
 	return &BTree{
-		root: root,
-		size: 0,
+		root:       root,
+		size:       0,
+		minFill:    BTREE_MIN_FILL,
+		cmp:        cmp,
+		pageSize:   opts.PageSize,
+		maxKeySize: opts.MaxKeySize,
+		maxValSize: opts.MaxValueSize,
+	}, nil
+}
+
+// Options returns the tree's page size and size limits.
+func (t *BTree) Options() Options {
+	return Options{PageSize: t.pageSize, MaxKeySize: t.maxKeySize, MaxValueSize: t.maxValSize}
+}
+
+// Comparator returns the comparator that orders this tree's keys.
+func (t *BTree) Comparator() Comparator {
+	return t.cmp
+}
+
+// SetMinFill sets the minimum fill threshold as a fraction of the page size.
+// After a deletion, any non-root node smaller than this is merged with a
+// sibling or refilled from it. A value of 0 only rebalances empty nodes.
+//
+// Parameters:
+//   - fraction: The minimum fill, between 0 and 0.5
+//
+// Returns:
+//   - An error if fraction is out of range
+func (t *BTree) SetMinFill(fraction float64) error {
+	// Above half a page two underfull siblings might not fit in one page
+	if fraction < 0 || fraction > 0.5 {
+		return errors.New("min fill must be between 0 and 0.5")
+	}
+	t.minFill = fraction
+	return nil
+}
+
+// isUnderflow reports whether a node has dropped below the minimum fill.
+func (t *BTree) isUnderflow(n *Node) bool {
+	return n.IsEmpty() || n.Size() < int(float64(t.pageSize)*t.minFill)
+}
+
+// CheckSize reports whether a key/value pair is small enough to store,
+// so callers can validate several pairs before inserting any of them.
+// The key and value must be within the tree's limits, and together they
+// must fit in a leaf page on their own.
+//
+// Parameters:
+//   - key: The key as a byte slice
+//   - value: The value as a byte slice
+//
+// Returns:
+//   - An error if the key or value is too large
+func (t *BTree) CheckSize(key, value []byte) error {
+	if len(key) > t.maxKeySize {
+		return errors.New("key too large")
 	}
+	if len(value) > t.maxValSize {
+		return errors.New("value too large")
+	}
+	if size := 4 + leafEntrySize(len(key), len(value)); size > t.pageSize {
+		return fmt.Errorf("key and value take %d bytes, more than fit in a %d-byte page", size, t.pageSize)
+	}
+	return nil
 }
 
 // Insert adds a key/value pair into the B+ tree.
@@ -41,11 +234,8 @@ func NewBTree() *BTree {
 //   - An error if the key is too large, value is too large, or key already exists
 func (t *BTree) Insert(key, value []byte) error {
 	// Validate input
-	if len(key) > BTREE_MAX_KEY_SIZE {
-		return errors.New("key too large")
-	}
-	if len(value) > BTREE_MAX_VAL_SIZE {
-		return errors.New("value too large")
+	if err := t.CheckSize(key, value); err != nil {
+		return err
 	}
 
 	// Find the leaf node where the key should be inserted
@@ -57,18 +247,54 @@ func (t *BTree) Insert(key, value []byte) error {
 	}
 	
 	// If the leaf is now overfull, split it
-	if leaf.IsFull() {
-		newLeaf, promotedKey := leaf.Split()
-		// Propagate the split upward
-		t.insertInParent(leaf, promotedKey, newLeaf)
+	if leaf.IsFull(t.pageSize) {
+		t.split(leaf)
 	}
 
 	t.size++
 	return nil
 }
 
+// Upsert adds a key/value pair, or replaces the value if the key is
+// already in the tree. A replacement keeps the stored key, which matters
+// when the comparator treats different byte strings as the same key. The
+// leaf is split if the new value makes it overfull, and rebalanced if a
+// shorter value leaves it underfull.
+//
+// Parameters:
+//   - key: The key as a byte slice
+//   - value: The value as a byte slice
+//
+// Returns:
+//   - An error if the key or value is too large
+func (t *BTree) Upsert(key, value []byte) error {
+	// Validate input
+	if err := t.CheckSize(key, value); err != nil {
+		return err
+	}
+
+	leaf := t.findLeaf(t.root, key)
+	pos, found := t.search(leaf, key)
+	if !found {
+		return t.Insert(key, value)
+	}
+
+	// Re-encode the entry; removeKV and insertKV shift the offsets of the
+	// entries after it by the change in size
+	stored := append([]byte(nil), leaf.getKey(pos)...)
+	leaf.removeKV(pos)
+	leaf.insertKV(pos, stored, value)
+
+	if leaf.IsFull(t.pageSize) {
+		t.split(leaf)
+	} else if leaf != t.root && t.isUnderflow(leaf) {
+		t.rebalance(leaf)
+	}
+	return nil
+}
+
 // findLeaf traverses the tree to find the leaf node where a key belongs.
-// It performs a recursive search starting from the provided node.
+// It descends from the provided node, binary searching each internal node.
 //
 // Parameters:
 //   - n: The node to start the search from
@@ -77,24 +303,31 @@ func (t *BTree) Insert(key, value []byte) error {
 // Returns:
 //   - A pointer to the leaf Node where key belongs
 func (t *BTree) findLeaf(n *Node, key []byte) *Node {
-	// If node is leaf, return it
-	if n.typ == BNODE_LEAF {
-		return n
-	}
-	
-	// For internal node, choose the proper child pointer
-	// by comparing the key with each key in the node
-	for i, k := range n.keys() {
-		if bytes.Compare(key, k) < 0 {
-			// Key is smaller than the current node key,
-			// so go down the left child pointer
-			return t.findLeaf(n.getChild(i), key)
+	for n.typ != BNODE_LEAF {
+		// Go down the pointer left of the first key greater than key.
+		// This follows the B+Tree property where keys in a node divide
+		// the key space for its children: a key equal to a separator
+		// belongs to the child on its right.
+		i, found := t.search(n, key)
+		if found {
+			i++
 		}
+		n = n.getChild(i)
 	}
-	// Otherwise, key is greater than all keys in n; use last child
-	// This follows the B+Tree property where keys in a node divide
-	// the key space for its children
-	return t.findLeaf(n.getChild(len(n.keys())), key)
+	return n
+}
+
+// search binary searches n for key without copying its keys.
+//
+// Returns:
+//   - The index of the first key in n that is not less than key
+//   - Whether the key at that index equals key
+func (t *BTree) search(n *Node, key []byte) (int, bool) {
+	nkeys := int(n.nkeys)
+	i := sort.Search(nkeys, func(i int) bool {
+		return t.cmp(key, n.getKey(i)) <= 0
+	})
+	return i, i < nkeys && t.cmp(key, n.getKey(i)) == 0
 }
 
 // insertInLeaf inserts a key/value pair into a leaf node in sorted order.
@@ -110,15 +343,9 @@ func (t *BTree) findLeaf(n *Node, key []byte) *Node {
 //   - An error if the key already exists
 func (t *BTree) insertInLeaf(leaf *Node, key, value []byte) error {
 	// Find insertion position
-	pos := 0
-	for i, k := range leaf.keys() {
-		if bytes.Compare(key, k) == 0 {
-			return errors.New("key already exists")
-		}
-		if bytes.Compare(key, k) < 0 {
-			break
-		}
-		pos = i + 1
+	pos, found := t.search(leaf, key)
+	if found {
+		return ErrKeyExists
 	}
 
 	// Insert key and value
@@ -144,58 +371,46 @@ func (t *BTree) insertInParent(oldNode *Node, key []byte, newNode *Node) {
 		return
 	}
 
-	// Find the parent node
-	parent := t.findParent(t.root, oldNode)
+	parent := oldNode.parent
 	if parent == nil {
 		panic("parent not found")
 	}
 
 	// Insert key and newNode pointer into the parent
-	pos := 0
-	for i, k := range parent.keys() {
-		if bytes.Compare(key, k) < 0 {
-			break
-		}
-		pos = i + 1
+	pos, found := t.search(parent, key)
+	if found {
+		pos++
 	}
 	parent.insertKV(pos, key, nil)
-	parent.setChild(pos+1, newNode)
+	parent.insertChild(pos+1, newNode)
 
 	// If parent overflows, split it recursively
-	if parent.IsFull() {
-		newParent, promotedKey := parent.Split()
-		t.insertInParent(parent, promotedKey, newParent)
+	if parent.IsFull(t.pageSize) {
+		t.split(parent)
 	}
 }
 
-// findParent finds the parent node of a given node by traversing the tree.
-// This is used during insertInParent to locate where changes need to be made.
+// split splits a node that has filled its page and propagates the split
+// upward. Nodes split in half by key count, so with large entries one half
+// can still be full; it is split again until every node fits in a page.
 //
 // Parameters:
-//   - root: The node to start the search from
-//   - target: The node whose parent we're looking for
-//
-// Returns:
-//   - A pointer to the parent Node, or nil if not found
-func (t *BTree) findParent(root, target *Node) *Node {
-	if root == target {
-		return nil
+//   - n: The full node
+func (t *BTree) split(n *Node) {
+	right, promotedKey := n.Split()
+	if right == nil {
+		// A single entry that CheckSize let through fits on its own
+		return
 	}
+	t.version++
+	t.insertInParent(n, promotedKey, right)
 
-	if root.typ == BNODE_LEAF {
-		return nil
+	if n.IsFull(t.pageSize) {
+		t.split(n)
 	}
-
-	for i := 0; i < len(root.pointers); i++ {
-		child := root.getChild(i)
-		if child == target {
-			return root
-		}
-		if found := t.findParent(child, target); found != nil {
-			return found
-		}
+	if right.IsFull(t.pageSize) {
+		t.split(right)
 	}
-	return nil
 }
 
 // Get retrieves a value for a given key from the B+Tree.
@@ -212,12 +427,10 @@ func (t *BTree) Get(key []byte) ([]byte, error) {
 	leaf := t.findLeaf(t.root, key)
 	
 	// Search for the key in the leaf node
-	for i, k := range leaf.keys() {
-		if bytes.Compare(key, k) == 0 {
-			return leaf.getValue(i), nil
-		}
+	if i, found := t.search(leaf, key); found {
+		return leaf.getValue(i), nil
 	}
-	return nil, errors.New("key not found")
+	return nil, ErrKeyNotFound
 }
 
 // Delete removes a key/value pair from the B+ tree.
@@ -233,22 +446,16 @@ func (t *BTree) Delete(key []byte) error {
 	leaf := t.findLeaf(t.root, key)
 	
 	// Search for the key's position in the leaf
-	pos := -1
-	for i, k := range leaf.keys() {
-		if bytes.Compare(key, k) == 0 {
-			pos = i
-			break
-		}
-	}
-	if pos == -1 {
-		return errors.New("key not found")
+	pos, found := t.search(leaf, key)
+	if !found {
+		return ErrKeyNotFound
 	}
 
 	// Remove the key/value pair
 	leaf.removeKV(pos)
 
 	// If the leaf is now underfull, try to redistribute or merge
-	if leaf.IsEmpty() && leaf != t.root {
+	if leaf != t.root && t.isUnderflow(leaf) {
 		t.rebalance(leaf)
 	}
 
@@ -256,20 +463,57 @@ func (t *BTree) Delete(key []byte) error {
 	return nil
 }
 
+// DeleteRange removes every key from start, inclusive, up to end,
+// exclusive. A nil start begins at the smallest key and a nil end runs
+// past the largest. The keys are found by walking the leaf list from
+// start, then deleted one at a time, rebalancing like Delete.
+//
+// Parameters:
+//   - start: The first key to delete, or nil
+//   - end: The key to stop before, or nil
+//
+// Returns:
+//   - The number of keys removed
+func (t *BTree) DeleteRange(start, end []byte) int {
+	leaf, i := t.FirstLeaf(), 0
+	if start != nil {
+		leaf = t.findLeaf(t.root, start)
+		i, _ = t.search(leaf, start)
+	}
+
+	// Copy the keys out first; deleting shifts entries between leaves
+	var keys [][]byte
+scan:
+	for ; leaf != nil; leaf, i = leaf.next, 0 {
+		for ; i < int(leaf.nkeys); i++ {
+			key := leaf.getKey(i)
+			if end != nil && t.cmp(key, end) >= 0 {
+				break scan
+			}
+			keys = append(keys, append([]byte(nil), key...))
+		}
+	}
+
+	for _, key := range keys {
+		t.Delete(key)
+	}
+	return len(keys)
+}
+
 // rebalance handles underflow in a node by redistributing keys or merging nodes.
 // This ensures the B+Tree remains balanced after deletions.
 //
 // Parameters:
 //   - n: The node to rebalance
 func (t *BTree) rebalance(n *Node) {
-	parent := t.findParent(t.root, n)
+	parent := n.parent
 	if parent == nil {
 		return
 	}
 
 	// Find the position of n in parent's children
 	pos := -1
-	for i, child := range parent.children() {
+	for i, child := range parent.childNodes {
 		if child == n {
 			pos = i
 			break
@@ -279,36 +523,32 @@ func (t *BTree) rebalance(n *Node) {
 		panic("node not found in parent")
 	}
 
-	// Try to redistribute with left sibling
+	// Pair the node with its left sibling, or its right sibling if it's the first child
+	var left, right *Node
+	sep := pos - 1
 	if pos > 0 {
-		leftSibling := parent.getChild(pos - 1)
-		if !leftSibling.IsFull() {
-			t.redistribute(leftSibling, n, parent, pos-1)
-			return
-		}
-	}
-
-	// Try to redistribute with right sibling
-	if pos < len(parent.children())-1 {
-		rightSibling := parent.getChild(pos + 1)
-		if !rightSibling.IsFull() {
-			t.redistribute(n, rightSibling, parent, pos)
-			return
+		left, right = parent.getChild(pos-1), n
+	} else {
+		if len(parent.childNodes) < 2 {
+			return // No sibling to rebalance with
 		}
+		left, right = n, parent.getChild(pos+1)
+		sep = pos
 	}
 
-	// If redistribution failed, merge
-	if pos > 0 {
-		leftSibling := parent.getChild(pos - 1)
-		t.merge(leftSibling, n, parent, pos-1)
-	} else {
-		rightSibling := parent.getChild(pos + 1)
-		t.merge(n, rightSibling, parent, pos)
+	// Merge if both fit in one page, otherwise borrow from the sibling
+	if err := t.merge(left, right, parent, sep); err != nil {
+		t.redistribute(left, right, parent, sep)
 	}
 }
 
-// redistribute moves keys between two nodes to balance them.
-// This is a simplified implementation that needs to be expanded for a full B+Tree.
+// redistribute moves entries from the fuller of two siblings into the
+// underfull one until it is back above the minimum fill, without taking
+// the donor below it, and updates the parent's separator to match. Leaf
+// entries move directly and the separator becomes the right node's first
+// key. Internal entries rotate through the parent: the separator moves
+// down into the receiving node along with the donor's nearest child, and
+// the donor's nearest key moves up to replace it.
 //
 // Parameters:
 //   - left: The left node
@@ -316,23 +556,199 @@ func (t *BTree) rebalance(n *Node) {
 //   - parent: The parent node
 //   - pos: The position of the separator key in the parent
 func (t *BTree) redistribute(left, right *Node, parent *Node, pos int) {
-	// Implementation of redistribution logic
-	// This is a simplified version - you'll need to implement the full logic
-	// based on your specific requirements
+	fromLeft := left.Size() > right.Size()
+	donor, receiver := right, left
+	if fromLeft {
+		donor, receiver = left, right
+	}
+
+	var moved [][]byte
+	var event RebalanceEvent
+	for t.isUnderflow(receiver) && donor.nkeys > 1 {
+		i := 0
+		if fromLeft {
+			i = int(donor.nkeys) - 1
+		}
+		if donor.Size()-entrySize(donor, i) < int(float64(t.pageSize)*t.minFill) {
+			break
+		}
+		// A large entry could overfill the receiver; it stays underfull
+		if receiver.Size()+entrySize(donor, i) >= t.pageSize {
+			break
+		}
+		if t.observer != nil && moved == nil {
+			event = t.rebalanceEvent(RebalanceRedistribute, parent, pos, nil)
+		}
+
+		key := append([]byte(nil), donor.getKey(i)...)
+		moved = append(moved, key)
+		separator := append([]byte(nil), parent.getKey(pos)...)
+
+		switch {
+		case donor.typ == BNODE_LEAF && fromLeft:
+			right.insertKV(0, key, donor.getValue(i))
+			left.removeKV(i)
+			separator = key
+		case donor.typ == BNODE_LEAF:
+			left.insertKV(int(left.nkeys), key, donor.getValue(i))
+			right.removeKV(i)
+			separator = append([]byte(nil), right.getKey(0)...)
+		case fromLeft:
+			right.insertKV(0, separator, nil)
+			right.insertChild(0, left.getChild(i+1))
+			left.removeKV(i)
+			left.removeChild(i + 1)
+			separator = key
+		default:
+			left.insertKV(int(left.nkeys), separator, nil)
+			left.insertChild(len(left.childNodes), right.getChild(0))
+			right.removeKV(0)
+			right.removeChild(0)
+			separator = key
+		}
+
+		parent.removeKV(pos)
+		parent.insertKV(pos, separator, nil)
+	}
+	if moved == nil {
+		return
+	}
+	t.version++
+
+	if t.observer != nil {
+		event.Keys = moved
+		t.observer(event)
+	}
+
+	// A new separator of a different length can overfill or underfill
+	// the parent
+	if parent.IsFull(t.pageSize) {
+		t.split(parent)
+	} else if parent != t.root && t.isUnderflow(parent) {
+		t.rebalance(parent)
+	}
 }
 
-// merge combines two nodes into one.
-// This is a simplified implementation that needs to be expanded for a full B+Tree.
+// entrySize returns how many bytes the entry at index i takes up in n,
+// including its offset and, for internal nodes, one child pointer
+func entrySize(n *Node, i int) int {
+	size := 2 + 4 + len(n.getKey(i))
+	if n.typ == BNODE_LEAF {
+		size += len(n.getValue(i))
+	} else {
+		size += 8
+	}
+	return size
+}
+
+// merge moves every entry of right into left and removes right from the
+// parent. Leaves are concatenated; for internal nodes the parent's
+// separator key moves down between left's last pointer and right's first,
+// since it still divides their subtrees. A parent left underfull is
+// rebalanced in turn, and a root left with a single child is replaced by
+// that child, so the tree shrinks by a level.
 //
 // Parameters:
 //   - left: The left node
 //   - right: The right node
 //   - parent: The parent node
 //   - pos: The position of the separator key in the parent
-func (t *BTree) merge(left, right *Node, parent *Node, pos int) {
-	// Implementation of merge logic
-	// This is a simplified version - you'll need to implement the full logic
-	// based on your specific requirements
+//
+// Returns:
+//   - An error, with the tree unchanged, if the nodes are of different
+//     types or together don't fit in a page
+func (t *BTree) merge(left, right *Node, parent *Node, pos int) error {
+	if left.typ != right.typ {
+		return errors.New("cannot merge nodes of different types")
+	}
+	if size := mergedSize(left, right, parent.getKey(pos)); size >= t.pageSize {
+		return fmt.Errorf("merged node would take %d bytes, more than fit in a %d-byte page", size, t.pageSize)
+	}
+
+	var event RebalanceEvent
+	if t.observer != nil {
+		event = t.rebalanceEvent(RebalanceMerge, parent, pos, right.keys())
+	}
+
+	if left.typ == BNODE_NODE {
+		separator := append([]byte(nil), parent.getKey(pos)...)
+		left.insertKV(int(left.nkeys), separator, nil)
+	}
+
+	// Move all of right's entries into left, taking the separator back
+	// out if that fails
+	if err := left.Merge(right); err != nil {
+		if left.typ == BNODE_NODE {
+			left.removeKV(int(left.nkeys) - 1)
+		}
+		return err
+	}
+	t.version++
+
+	// Drop the separator key and the pointer to right from the parent
+	parent.removeKV(pos)
+	parent.removeChild(pos + 1)
+
+	if t.observer != nil {
+		t.observer(event)
+	}
+
+	if parent == t.root {
+		if parent.nkeys == 0 {
+			t.root = left
+			left.parent = nil
+		}
+		return nil
+	}
+	if t.isUnderflow(parent) {
+		t.rebalance(parent)
+	}
+	return nil
+}
+
+// mergedSize returns the size of the node merging right into left would
+// produce, including the separator an internal merge pulls down
+func mergedSize(left, right *Node, separator []byte) int {
+	size := left.Size() + right.Size() - 4
+	if left.typ == BNODE_NODE {
+		size += 2 + 4 + len(separator)
+	}
+	return size
+}
+
+// ReverseIterate visits the key/value pairs in descending key order.
+// Iteration stops as soon as f returns false, so callers that only need
+// the largest keys don't walk the whole tree.
+//
+// Parameters:
+//   - f: The function called for each key/value pair
+func (t *BTree) ReverseIterate(f func(key, value []byte) bool) {
+	t.reverseIterate(t.root, f)
+}
+
+// reverseIterate walks the subtree rooted at n from right to left.
+// It returns false once f has asked to stop.
+func (t *BTree) reverseIterate(n *Node, f func(key, value []byte) bool) bool {
+	if n == nil {
+		return true
+	}
+
+	if n.typ == BNODE_LEAF {
+		keys := n.keys()
+		for i := len(keys) - 1; i >= 0; i-- {
+			if !f(keys[i], n.getValue(i)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for i := len(n.childNodes) - 1; i >= 0; i-- {
+		if !t.reverseIterate(n.getChild(i), f) {
+			return false
+		}
+	}
+	return true
 }
 
 // Size returns the number of keys in the tree.
@@ -1,8 +1,15 @@
 package btree
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"runtime"
 	"testing"
+	"time"
 )
 
 func TestBTree_Insert(t *testing.T) {
@@ -158,4 +165,990 @@ func TestBTree_Height(t *testing.T) {
 	if height <= 0 {
 		t.Errorf("Expected height > 0, got %d", height)
 	}
-} 
\ No newline at end of file
+} 
+// countLeaves returns the number of leaf nodes under n.
+func countLeaves(n *Node) int {
+	if n.typ == BNODE_LEAF {
+		return 1
+	}
+	count := 0
+	for _, child := range n.children() {
+		count += countLeaves(child)
+	}
+	return count
+}
+
+func TestBTree_MergeOnUnderflow(t *testing.T) {
+	tree := NewBTree()
+
+	// Fill several leaves
+	for i := 0; i < 2000; i++ {
+		key := []byte(fmt.Sprintf("key_%05d", i))
+		val := []byte(fmt.Sprintf("val_%05d", i))
+		if err := tree.Insert(key, val); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	before := countLeaves(tree.root)
+	if before < 5 {
+		t.Fatalf("Expected several leaves, got %d", before)
+	}
+
+	// Delete 90% of the keys across every leaf, leaving them all sparse
+	for i := 0; i < 2000; i++ {
+		if i%10 == 0 {
+			continue
+		}
+		if err := tree.Delete([]byte(fmt.Sprintf("key_%05d", i))); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+
+	// The survivors fit in a couple of pages, so sparse leaves must have merged
+	after := countLeaves(tree.root)
+	if after > 2 {
+		t.Errorf("Expected at most 2 leaves after deletes, got %d (was %d)", after, before)
+	}
+
+	for i := 0; i < 2000; i += 10 {
+		key := []byte(fmt.Sprintf("key_%05d", i))
+		value, err := tree.Get(key)
+		if err != nil {
+			t.Errorf("Get %s failed: %v", key, err)
+			continue
+		}
+		if string(value) != fmt.Sprintf("val_%05d", i) {
+			t.Errorf("Wrong value for %s: %s", key, value)
+		}
+	}
+	if tree.Size() != 200 {
+		t.Errorf("Expected size 200, got %d", tree.Size())
+	}
+}
+
+func TestBTree_RefusedMergeLeavesTreeUnchanged(t *testing.T) {
+	// A bulk load packs every node but the last on each level, and small
+	// pages make for several levels
+	tree, err := NewBTreeWithOptions(bytes.Compare, Options{PageSize: 512, MaxKeySize: 16, MaxValueSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.BulkLoad(sortedPairs(20000)); err != nil {
+		t.Fatal(err)
+	}
+	if tree.Height() < 3 {
+		t.Fatalf("Expected at least 3 levels, got %d", tree.Height())
+	}
+
+	// Two full siblings don't fit in one page, at either level
+	parent := tree.root
+	for parent.getChild(0).typ == BNODE_NODE && len(parent.getChild(0).childNodes) > 1 {
+		parent = parent.getChild(0)
+	}
+	for _, p := range []*Node{parent, tree.root} {
+		left, right := p.getChild(0), p.getChild(1)
+		nkeys, children := p.nkeys, len(p.childNodes)
+		leftKeys := left.nkeys
+		if err := tree.merge(left, right, p, 0); err == nil {
+			t.Fatalf("Expected merging two full nodes to be refused")
+		}
+		if p.nkeys != nkeys || len(p.childNodes) != children || left.nkeys != leftKeys {
+			t.Errorf("Refused merge changed the nodes")
+		}
+		checkTree(t, tree)
+	}
+
+	// Nodes of different types are never merged
+	leaf := parent.getChild(0)
+	for leaf.typ != BNODE_LEAF {
+		leaf = leaf.getChild(0)
+	}
+	if err := tree.merge(tree.root, leaf, tree.root, 0); err == nil {
+		t.Errorf("Expected merging a leaf into an internal node to be refused")
+	}
+	checkTree(t, tree)
+}
+
+// checkTree verifies that every leaf is at the same depth, that keys are
+// in order and within the bounds set by their ancestors' separators, that
+// the leaf list links the leaves in order, that every node links back to
+// its parent, and that the tree holds Size keys
+func checkTree(t *testing.T, tree *BTree) {
+	t.Helper()
+	if tree.root.parent != nil {
+		t.Fatalf("Root has a parent")
+	}
+	leafDepth := -1
+	count := 0
+	var leaves []*Node
+	var walk func(n *Node, depth int, lo, hi []byte)
+	walk = func(n *Node, depth int, lo, hi []byte) {
+		keys := n.keys()
+		for i, k := range keys {
+			if i > 0 && bytes.Compare(keys[i-1], k) >= 0 {
+				t.Fatalf("Keys out of order: %q before %q", keys[i-1], k)
+			}
+			if (lo != nil && bytes.Compare(k, lo) < 0) || (hi != nil && bytes.Compare(k, hi) >= 0) {
+				t.Fatalf("Key %q outside its parent's bounds [%q, %q)", k, lo, hi)
+			}
+		}
+		if n.typ == BNODE_LEAF {
+			if leafDepth == -1 {
+				leafDepth = depth
+			} else if depth != leafDepth {
+				t.Fatalf("Leaves at depths %d and %d", leafDepth, depth)
+			}
+			count += len(keys)
+			leaves = append(leaves, n)
+			return
+		}
+		if len(n.childNodes) != len(keys)+1 {
+			t.Fatalf("Internal node with %d keys has %d children", len(keys), len(n.childNodes))
+		}
+		for i, child := range n.children() {
+			if child.parent != n {
+				t.Fatalf("Child %d of a node at depth %d doesn't link back to it", i, depth)
+			}
+			childLo, childHi := lo, hi
+			if i > 0 {
+				childLo = keys[i-1]
+			}
+			if i < len(keys) {
+				childHi = keys[i]
+			}
+			walk(child, depth+1, childLo, childHi)
+		}
+	}
+	walk(tree.root, 0, nil, nil)
+	leaf := tree.FirstLeaf()
+	for i, want := range leaves {
+		if leaf != want {
+			t.Fatalf("Leaf list diverges from the tree at leaf %d", i)
+		}
+		leaf = leaf.NextLeaf()
+	}
+	if leaf != nil {
+		t.Fatalf("Leaf list runs past the last leaf")
+	}
+	if count != tree.Size() {
+		t.Fatalf("Expected %d keys in the leaves, found %d", tree.Size(), count)
+	}
+}
+
+func TestBTree_RandomDeletesShrinkTree(t *testing.T) {
+	tree := NewBTree()
+	// Long keys keep internal nodes small, so the tree has several levels
+	key := func(i int) []byte {
+		return []byte(fmt.Sprintf("%050d", i))
+	}
+	value := func(i int) string {
+		return fmt.Sprintf("%0100d", i)
+	}
+	const n = 2000
+	for i := 0; i < n; i++ {
+		if err := tree.Insert(key(i), []byte(value(i))); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	before := tree.Height()
+	if before < 2 {
+		t.Fatalf("Expected internal nodes below the root, got height %d", before)
+	}
+
+	perm := rand.New(rand.NewSource(1)).Perm(n)
+	deleted, kept := perm[:n*9/10], perm[n*9/10:]
+	for i, k := range deleted {
+		if err := tree.Delete(key(k)); err != nil {
+			t.Fatalf("Delete %d failed: %v", k, err)
+		}
+		if i%100 == 0 {
+			checkTree(t, tree)
+		}
+	}
+	checkTree(t, tree)
+
+	for _, k := range kept {
+		v, err := tree.Get(key(k))
+		if err != nil {
+			t.Fatalf("Get %d failed: %v", k, err)
+		}
+		if string(v) != value(k) {
+			t.Errorf("Wrong value for %d: %s", k, v)
+		}
+	}
+	for _, k := range deleted[:100] {
+		if _, err := tree.Get(key(k)); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Expected %d to be deleted, got %v", k, err)
+		}
+	}
+	if after := tree.Height(); after >= before {
+		t.Errorf("Expected the tree to shrink from height %d, got %d", before, after)
+	}
+
+	// Emptying the tree collapses it to a single leaf
+	for _, k := range kept {
+		if err := tree.Delete(key(k)); err != nil {
+			t.Fatalf("Delete %d failed: %v", k, err)
+		}
+	}
+	checkTree(t, tree)
+	if tree.Height() != 0 || tree.Size() != 0 {
+		t.Errorf("Expected an empty leaf root, got height %d size %d", tree.Height(), tree.Size())
+	}
+}
+
+func TestBTree_SetMinFill(t *testing.T) {
+	tree := NewBTree()
+	if err := tree.SetMinFill(0.25); err != nil {
+		t.Errorf("SetMinFill failed: %v", err)
+	}
+	if err := tree.SetMinFill(0.9); err == nil {
+		t.Error("Expected error for min fill above 0.5")
+	}
+}
+
+func TestBTree_CaseInsensitiveComparator(t *testing.T) {
+	tree := NewBTreeWithComparator(func(a, b []byte) int {
+		return bytes.Compare(bytes.ToLower(a), bytes.ToLower(b))
+	})
+
+	if err := tree.Insert([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	value, err := tree.Get([]byte("KEY"))
+	if err != nil {
+		t.Fatalf("Get with different case failed: %v", err)
+	}
+	if string(value) != "value" {
+		t.Errorf("Expected value, got %s", value)
+	}
+
+	// Keys equal under the comparator are the same key
+	if err := tree.Insert([]byte("Key"), []byte("other")); err == nil {
+		t.Error("Expected error inserting a key equal under the comparator")
+	}
+
+	// Ordering follows the comparator across splits
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("K%03d", i)
+		if i%2 == 0 {
+			key = fmt.Sprintf("k%03d", i)
+		}
+		if err := tree.Insert([]byte(key), []byte("v")); err != nil {
+			t.Fatalf("Insert %s failed: %v", key, err)
+		}
+	}
+	for i := 0; i < 200; i++ {
+		if _, err := tree.Get([]byte(fmt.Sprintf("k%03d", i))); err != nil {
+			t.Errorf("Get k%03d failed: %v", i, err)
+		}
+	}
+
+	if err := tree.Delete([]byte("KEY")); err != nil {
+		t.Errorf("Delete with different case failed: %v", err)
+	}
+	if _, err := tree.Get([]byte("key")); err == nil {
+		t.Error("Expected key to be deleted")
+	}
+}
+
+func TestBTree_RepeatedRootSplits(t *testing.T) {
+	// Long keys fill internal nodes quickly, so the root splits again
+	// once the first internal root overflows
+	key := func(i int) []byte {
+		return []byte(fmt.Sprintf("%0200d", i))
+	}
+	value := make([]byte, 500)
+
+	orders := map[string]func(i, n int) int{
+		"ascending":   func(i, n int) int { return i },
+		"descending":  func(i, n int) int { return n - 1 - i },
+		"interleaved": func(i, n int) int { return (i * 7919) % n },
+	}
+
+	for name, order := range orders {
+		tree := NewBTree()
+		const n = 3000
+
+		for i := 0; i < n; i++ {
+			if err := tree.Insert(key(order(i, n)), value); err != nil {
+				t.Fatalf("%s: Insert %d failed: %v", name, i, err)
+			}
+		}
+
+		if height := tree.Height(); height < 2 {
+			t.Errorf("%s: Expected the root to split at least twice, got height %d", name, height)
+		}
+		if size := tree.Size(); size != n {
+			t.Errorf("%s: Expected size %d, got %d", name, n, size)
+		}
+
+		missing := 0
+		for i := 0; i < n; i++ {
+			if _, err := tree.Get(key(i)); err != nil {
+				missing++
+			}
+		}
+		if missing > 0 {
+			t.Errorf("%s: Expected every key to be findable, %d missing", name, missing)
+		}
+	}
+}
+
+func TestBTree_RebalanceObserverReportsMerge(t *testing.T) {
+	tree := NewBTree()
+	for i := 0; i < 2000; i++ {
+		key := []byte(fmt.Sprintf("key_%05d", i))
+		if err := tree.Insert(key, []byte(fmt.Sprintf("val_%05d", i))); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if tree.root.typ != BNODE_NODE || len(tree.root.childNodes) < 3 {
+		t.Fatalf("Expected a root with several leaves")
+	}
+
+	var events []RebalanceEvent
+	tree.SetRebalanceObserver(func(e RebalanceEvent) {
+		events = append(events, e)
+	})
+
+	// Empty the second leaf; it merges into the first
+	parentID := tree.nodeID(tree.root)
+	leftID, rightID := tree.nodeID(tree.root.getChild(0)), tree.nodeID(tree.root.getChild(1))
+	separator := string(tree.root.keys()[0])
+	second := tree.root.getChild(1)
+	var remaining []string
+	for _, k := range second.keys() {
+		remaining = append(remaining, string(k))
+	}
+	for len(events) == 0 && len(remaining) > 0 {
+		if err := tree.Delete([]byte(remaining[0])); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		remaining = remaining[1:]
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("Expected one merge, got %d events", len(events))
+	}
+	e := events[0]
+	if e.Op != RebalanceMerge {
+		t.Errorf("Expected a merge, got %v", e.Op)
+	}
+	if e.ParentID != parentID || e.LeftID != leftID || e.RightID != rightID {
+		t.Errorf("Expected parent %d merging %d into %d, got parent %d merging %d into %d",
+			parentID, rightID, leftID, e.ParentID, e.RightID, e.LeftID)
+	}
+	if string(e.Separator) != separator {
+		t.Errorf("Expected separator %s, got %s", separator, e.Separator)
+	}
+	if len(e.Keys) != len(remaining) {
+		t.Fatalf("Expected %d moved keys, got %d", len(remaining), len(e.Keys))
+	}
+	for i, k := range e.Keys {
+		if string(k) != remaining[i] {
+			t.Errorf("Expected moved key %s, got %s", remaining[i], k)
+		}
+	}
+
+	// The merged leaf replaced both siblings under the separator's position
+	if tree.root.getChild(0).id != leftID || string(tree.root.keys()[0]) == separator {
+		t.Error("Expected the right sibling and separator to be gone from the parent")
+	}
+}
+
+// findNode returns the node under n with the given ID, or nil
+func findNode(n *Node, id uint64) *Node {
+	if n.id == id {
+		return n
+	}
+	for _, child := range n.childNodes {
+		if found := findNode(child, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// newBorrowTree returns a tree whose root has several leaves, with leaf
+// full filled close to a page so a sparse neighbour can't merge into it
+func newBorrowTree(t *testing.T, full int) *BTree {
+	tree := NewBTree()
+	key := func(i int) []byte { return []byte(fmt.Sprintf("key_%04d", i)) }
+	value := make([]byte, 200)
+	for i := 0; i < 600; i += 10 {
+		if err := tree.Insert(key(i), value); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if tree.root.typ != BNODE_NODE || len(tree.root.childNodes) < 3 {
+		t.Fatalf("Expected a root with several leaves")
+	}
+
+	leaf := tree.root.getChild(full)
+	var first int
+	fmt.Sscanf(string(leaf.keys()[0]), "key_%04d", &first)
+	entry := 2 + 4 + len(key(0)) + len(value)
+	for i := first + 1; leaf.Size()+entry < BTREE_PAGE_SIZE; i++ {
+		if err := tree.Insert(key(i), value); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	return tree
+}
+
+// deleteUntilRebalance deletes keys from the leaf at pos, last first,
+// until the tree reports a rebalance
+func deleteUntilRebalance(t *testing.T, tree *BTree, pos int) RebalanceEvent {
+	var events []RebalanceEvent
+	tree.SetRebalanceObserver(func(e RebalanceEvent) {
+		events = append(events, e)
+	})
+	defer tree.SetRebalanceObserver(nil)
+
+	leaf := tree.root.getChild(pos)
+	for len(events) == 0 && leaf.nkeys > 0 {
+		last := append([]byte(nil), leaf.keys()[leaf.nkeys-1]...)
+		if err := tree.Delete(last); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	if len(events) != 1 {
+		t.Fatalf("Expected one rebalance, got %d", len(events))
+	}
+	return events[0]
+}
+
+func TestBTree_RedistributeBorrowsFromLeftSibling(t *testing.T) {
+	tree := newBorrowTree(t, 1)
+	leftID, rightID := tree.nodeID(tree.root.getChild(1)), tree.nodeID(tree.root.getChild(2))
+	lastOfLeft := string(tree.root.getChild(1).keys()[tree.root.getChild(1).nkeys-1])
+
+	e := deleteUntilRebalance(t, tree, 2)
+	if e.Op != RebalanceRedistribute {
+		t.Fatalf("Expected a redistribution, got %v", e.Op)
+	}
+	if e.LeftID != leftID || e.RightID != rightID {
+		t.Errorf("Expected siblings %d and %d, got %d and %d", leftID, rightID, e.LeftID, e.RightID)
+	}
+	if len(e.Keys) == 0 || string(e.Keys[0]) != lastOfLeft {
+		t.Errorf("Expected %s to move first, got %q", lastOfLeft, e.Keys)
+	}
+
+	// The separator is now the right leaf's first key, a key borrowed from the left
+	left, right := tree.root.getChild(1), tree.root.getChild(2)
+	separator := tree.root.keys()[1]
+	if !bytes.Equal(separator, right.keys()[0]) || !bytes.Equal(separator, e.Keys[len(e.Keys)-1]) {
+		t.Errorf("Expected separator %s to be the right leaf's first key %s", separator, right.keys()[0])
+	}
+	for _, n := range []*Node{left, right} {
+		if tree.isUnderflow(n) {
+			t.Errorf("Expected both siblings above the minimum fill, got %d bytes", n.Size())
+		}
+	}
+	checkTree(t, tree)
+}
+
+func TestBTree_RedistributeBorrowsFromRightSibling(t *testing.T) {
+	tree := newBorrowTree(t, 1)
+	firstOfRight := string(tree.root.getChild(1).keys()[0])
+
+	// The first leaf has no left sibling, so it borrows from the right
+	e := deleteUntilRebalance(t, tree, 0)
+	if e.Op != RebalanceRedistribute {
+		t.Fatalf("Expected a redistribution, got %v", e.Op)
+	}
+	if len(e.Keys) == 0 || string(e.Keys[0]) != firstOfRight {
+		t.Errorf("Expected %s to move first, got %q", firstOfRight, e.Keys)
+	}
+	if string(e.Separator) != firstOfRight {
+		t.Errorf("Expected the old separator %s, got %s", firstOfRight, e.Separator)
+	}
+
+	left, right := tree.root.getChild(0), tree.root.getChild(1)
+	separator := tree.root.keys()[0]
+	if !bytes.Equal(separator, right.keys()[0]) || bytes.Equal(separator, e.Separator) {
+		t.Errorf("Expected the separator to move to the right leaf's new first key %s, got %s", right.keys()[0], separator)
+	}
+	if !bytes.Equal(left.keys()[left.nkeys-1], e.Keys[len(e.Keys)-1]) {
+		t.Errorf("Expected the borrowed keys at the end of the left leaf")
+	}
+	for _, n := range []*Node{left, right} {
+		if tree.isUnderflow(n) {
+			t.Errorf("Expected both siblings above the minimum fill, got %d bytes", n.Size())
+		}
+	}
+	checkTree(t, tree)
+}
+
+func TestBTree_MixedWorkloadKeepsTreeValid(t *testing.T) {
+	tree := NewBTree()
+	ops := make(map[string]int)
+	tree.SetRebalanceObserver(func(e RebalanceEvent) {
+		if left := findNode(tree.root, e.LeftID); left != nil && left.typ == BNODE_NODE {
+			ops["internal "+e.Op.String()]++
+		}
+	})
+
+	// Keys of varied length, some long, so internal nodes split, merge and
+	// redistribute too
+	key := func(i int) []byte { return []byte(fmt.Sprintf("%0*d", 40+i%200, i)) }
+	rng := rand.New(rand.NewSource(1))
+	live := make(map[int]bool)
+	for round := 0; round < 20000; round++ {
+		i := rng.Intn(3000)
+		if live[i] {
+			if err := tree.Delete(key(i)); err != nil {
+				t.Fatalf("Delete %d failed: %v", i, err)
+			}
+			delete(live, i)
+		} else {
+			if err := tree.Insert(key(i), make([]byte, rng.Intn(300))); err != nil {
+				t.Fatalf("Insert %d failed: %v", i, err)
+			}
+			live[i] = true
+		}
+		if round%500 == 0 {
+			checkTree(t, tree)
+		}
+	}
+	checkTree(t, tree)
+
+	for i := range live {
+		if _, err := tree.Get(key(i)); err != nil {
+			t.Errorf("Get %d failed: %v", i, err)
+		}
+	}
+	if ops["internal merge"] == 0 || ops["internal redistribute"] == 0 {
+		t.Errorf("Expected internal merges and redistributions, got %v", ops)
+	}
+}
+
+func TestBTree_DeleteRangeRebalances(t *testing.T) {
+	tree := NewBTree()
+	key := func(i int) []byte { return []byte(fmt.Sprintf("key_%05d", i)) }
+	for i := 0; i < 5000; i++ {
+		if err := tree.Insert(key(i), []byte("value")); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	height := tree.Height()
+
+	// A range spanning many leaves, one inside a single leaf and one past
+	// the last key
+	if n := tree.DeleteRange(key(1000), key(4000)); n != 3000 {
+		t.Errorf("Expected 3000 keys deleted, got %d", n)
+	}
+	checkTree(t, tree)
+	if n := tree.DeleteRange(key(10), key(13)); n != 3 {
+		t.Errorf("Expected 3 keys deleted, got %d", n)
+	}
+	if n := tree.DeleteRange(key(4990), nil); n != 10 {
+		t.Errorf("Expected 10 keys deleted, got %d", n)
+	}
+	checkTree(t, tree)
+
+	if tree.Size() != 1987 {
+		t.Fatalf("Expected 1987 keys, got %d", tree.Size())
+	}
+	for _, i := range []int{0, 9, 13, 999, 4000, 4989} {
+		if _, err := tree.Get(key(i)); err != nil {
+			t.Errorf("Expected key %d outside the ranges to survive, got %v", i, err)
+		}
+	}
+
+	if n := tree.DeleteRange(nil, nil); n != 1987 {
+		t.Errorf("Expected the remaining 1987 keys deleted, got %d", n)
+	}
+	checkTree(t, tree)
+	if tree.Size() != 0 || tree.Height() >= height {
+		t.Errorf("Expected an empty, shorter tree, got %d keys at height %d", tree.Size(), tree.Height())
+	}
+}
+
+func TestBTree_UpsertReplacesValues(t *testing.T) {
+	tree := NewBTree()
+	if err := tree.Upsert([]byte("k"), []byte("v1")); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if err := tree.Upsert([]byte("k"), []byte("v2")); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if value, _ := tree.Get([]byte("k")); string(value) != "v2" {
+		t.Errorf("Expected v2, got %s", value)
+	}
+	if tree.Size() != 1 {
+		t.Errorf("Expected size 1, got %d", tree.Size())
+	}
+
+	// Growing values split leaves; shrinking them rebalances the tree
+	const n = 300
+	for i := 0; i < n; i++ {
+		tree.Upsert([]byte(fmt.Sprintf("key%04d", i)), []byte("small"))
+	}
+	for _, size := range []int{200, 5, 150, 0} {
+		for i := 0; i < n; i++ {
+			value := bytes.Repeat([]byte{byte('a' + i%26)}, size)
+			if err := tree.Upsert([]byte(fmt.Sprintf("key%04d", i)), value); err != nil {
+				t.Fatalf("Upsert %d failed: %v", i, err)
+			}
+		}
+		checkTree(t, tree)
+		if tree.Size() != n+1 {
+			t.Fatalf("Expected size %d, got %d", n+1, tree.Size())
+		}
+		for i := 0; i < n; i++ {
+			value, err := tree.Get([]byte(fmt.Sprintf("key%04d", i)))
+			if err != nil || !bytes.Equal(value, bytes.Repeat([]byte{byte('a' + i%26)}, size)) {
+				t.Fatalf("Expected key%04d to hold %d bytes, got %q (%v)", i, size, value, err)
+			}
+		}
+	}
+}
+
+func TestBTree_IndependentTreesConcurrently(t *testing.T) {
+	trees := []*BTree{NewBTree(), NewBTree()}
+	ids := make([][]uint64, len(trees))
+
+	var wg sync.WaitGroup
+	for n, tree := range trees {
+		n, tree := n, tree
+		tree.SetRebalanceObserver(func(e RebalanceEvent) {
+			ids[n] = append(ids[n], e.ParentID, e.LeftID, e.RightID)
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Both trees use the same keys, each with its own values, so
+			// a node shared between them would show up as a wrong value
+			for i := 0; i < 3000; i++ {
+				key := []byte(fmt.Sprintf("key_%05d", i))
+				if err := tree.Insert(key, []byte(fmt.Sprintf("tree%d_%05d", n, i))); err != nil {
+					t.Errorf("Tree %d: Insert failed: %v", n, err)
+					return
+				}
+			}
+			for i := 0; i < 3000; i++ {
+				if i%(n+2) == 0 {
+					continue
+				}
+				if err := tree.Delete([]byte(fmt.Sprintf("key_%05d", i))); err != nil {
+					t.Errorf("Tree %d: Delete failed: %v", n, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for n, tree := range trees {
+		checkTree(t, tree)
+		for i := 0; i < 3000; i++ {
+			value, err := tree.Get([]byte(fmt.Sprintf("key_%05d", i)))
+			if i%(n+2) != 0 {
+				if !errors.Is(err, ErrKeyNotFound) {
+					t.Fatalf("Tree %d: expected key_%05d to be deleted, got %s (%v)", n, i, value, err)
+				}
+				continue
+			}
+			if err != nil || string(value) != fmt.Sprintf("tree%d_%05d", n, i) {
+				t.Fatalf("Tree %d: expected tree%d_%05d, got %s (%v)", n, n, i, value, err)
+			}
+		}
+
+		// Each tree numbers its own nodes from 1
+		if len(ids[n]) == 0 {
+			t.Fatalf("Tree %d: expected rebalances", n)
+		}
+		if ids[n][0] != 1 {
+			t.Errorf("Tree %d: expected its first node ID to be 1, got %d", n, ids[n][0])
+		}
+	}
+}
+
+func TestBTree_LeafListIsSorted(t *testing.T) {
+	tree := NewBTree()
+	rng := rand.New(rand.NewSource(1))
+	for _, i := range rng.Perm(1000) {
+		if err := tree.Insert([]byte(fmt.Sprintf("key_%04d", i)), []byte("value")); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	checkTree(t, tree)
+
+	var keys []string
+	leaves := 0
+	for leaf := tree.FirstLeaf(); leaf != nil; leaf = leaf.NextLeaf() {
+		leaves++
+		for _, k := range leaf.keys() {
+			keys = append(keys, string(k))
+		}
+	}
+	if leaves < 2 {
+		t.Fatalf("Expected several leaves, got %d", leaves)
+	}
+	if len(keys) != 1000 {
+		t.Fatalf("Expected 1000 keys, got %d", len(keys))
+	}
+	for i, k := range keys {
+		if want := fmt.Sprintf("key_%04d", i); k != want {
+			t.Fatalf("Expected %s at position %d, got %s", want, i, k)
+		}
+	}
+}
+
+// maxNodeSize returns the size of the largest node under n
+func maxNodeSize(n *Node) int {
+	size := n.Size()
+	for _, child := range n.childNodes {
+		if s := maxNodeSize(child); s > size {
+			size = s
+		}
+	}
+	return size
+}
+
+func TestBTree_LargePagesHoldLargeValues(t *testing.T) {
+	value := bytes.Repeat([]byte("v"), 6*1024)
+	if err := NewBTree().Insert([]byte("key"), value); err == nil {
+		t.Fatal("Expected a 6K value to be too large for the default tree")
+	}
+
+	tree, err := NewBTreeWithOptions(bytes.Compare, Options{PageSize: 8192, MaxValueSize: 6 * 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts := tree.Options(); opts.PageSize != 8192 || opts.MaxKeySize != BTREE_MAX_KEY_SIZE {
+		t.Errorf("Expected an 8K page and the default key limit, got %+v", opts)
+	}
+
+	// Mix large and small values so nodes split unevenly
+	rng := rand.New(rand.NewSource(1))
+	sizes := []int{6 * 1024, 4000, 3000, 100, 10}
+	want := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("key%04d", rng.Intn(200))
+		size := sizes[rng.Intn(len(sizes))]
+		if err := tree.Upsert([]byte(key), value[:size]); err != nil {
+			t.Fatalf("Upsert of a %d-byte value failed: %v", size, err)
+		}
+		want[key] = size
+	}
+	checkTree(t, tree)
+	if size := maxNodeSize(tree.root); size > 8192 {
+		t.Fatalf("Expected every node to fit in an 8K page, found one of %d bytes", size)
+	}
+	for key, size := range want {
+		got, err := tree.Get([]byte(key))
+		if err != nil || len(got) != size {
+			t.Fatalf("Expected a %d-byte value for %s, got %d bytes (%v)", size, key, len(got), err)
+		}
+	}
+
+	// Deleting keeps every node within the page too
+	for key := range want {
+		if rng.Intn(2) == 0 {
+			if err := tree.Delete([]byte(key)); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+		}
+	}
+	checkTree(t, tree)
+	if size := maxNodeSize(tree.root); size > 8192 {
+		t.Fatalf("Expected every node to fit in an 8K page, found one of %d bytes", size)
+	}
+}
+
+func TestBTree_OptionsGuardPageSize(t *testing.T) {
+	for _, opts := range []Options{
+		{PageSize: 100},
+		{PageSize: 1 << 16},
+		{PageSize: 8192, MaxKeySize: 4000}, // three keys don't fit an internal node
+		{MaxValueSize: -1},
+	} {
+		if _, err := NewBTreeWithOptions(bytes.Compare, opts); err == nil {
+			t.Errorf("Expected %+v to be refused", opts)
+		}
+	}
+
+	// Limits that together exceed the page are allowed, but a pair that
+	// doesn't fit in a page on its own is refused
+	tree, err := NewBTreeWithOptions(bytes.Compare, Options{PageSize: 8192, MaxValueSize: 8000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.Insert([]byte("small"), make([]byte, 8000)); err != nil {
+		t.Errorf("Expected a pair that fits in a page to be stored, got %v", err)
+	}
+	err = tree.Insert(make([]byte, 1000), make([]byte, 8000))
+	if err == nil || !strings.Contains(err.Error(), "8192-byte page") {
+		t.Errorf("Expected a pair larger than a page to be refused, got %v", err)
+	}
+}
+
+// insertKeys inserts keys[from:to] into tree and returns how long it
+// took per key
+func insertKeys(tb testing.TB, tree *BTree, keys [][]byte, from, to int) time.Duration {
+	runtime.GC()
+	start := time.Now()
+	for _, key := range keys[from:to] {
+		if err := tree.Insert(key, []byte("value")); err != nil {
+			tb.Fatalf("Insert failed: %v", err)
+		}
+	}
+	return time.Since(start) / time.Duration(to-from)
+}
+
+// shuffledKeys returns n distinct keys in a fixed random order
+func shuffledKeys(n int) [][]byte {
+	keys := make([][]byte, n)
+	for i, j := range rand.New(rand.NewSource(1)).Perm(n) {
+		keys[i] = []byte(fmt.Sprintf("key%08d", j))
+	}
+	return keys
+}
+
+func TestBTree_InsertThroughputHoldsAsTreeGrows(t *testing.T) {
+	const n, batch = 100000, 2000
+	keys := shuffledKeys(n)
+	// Small pages make for many nodes and frequent splits
+	tree, err := NewBTreeWithOptions(bytes.Compare, Options{PageSize: 512, MaxKeySize: 16, MaxValueSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An insert costs O(log n), so one into a tree of 98k keys costs
+	// little more than one into an almost empty tree. Work that grows with
+	// the tree, like walking it to find each split node's parent, makes
+	// the last batch tens of times slower than the first.
+	first := insertKeys(t, tree, keys, 0, batch)
+	insertKeys(t, tree, keys, batch, n-batch)
+	last := insertKeys(t, tree, keys, n-batch, n)
+	if last > 10*first {
+		t.Errorf("Inserts slowed from %v to %v per key as the tree grew", first, last)
+	}
+	t.Logf("%v per insert at first, %v per insert at %d keys", first, last, n)
+
+	checkTree(t, tree)
+	if tree.Size() != n {
+		t.Errorf("Expected %d keys, got %d", n, tree.Size())
+	}
+}
+
+// sortedPairs returns n pairs with distinct keys in key order
+func sortedPairs(n int) []Pair {
+	pairs := make([]Pair, n)
+	for i := range pairs {
+		pairs[i] = Pair{Key: []byte(fmt.Sprintf("key%08d", i)), Value: []byte(fmt.Sprintf("val%08d", i))}
+	}
+	return pairs
+}
+
+func TestBTree_BulkLoadAnswersGets(t *testing.T) {
+	for _, n := range []int{1, 2, 100, 20000} {
+		// Small pages make for several levels of internal nodes
+		tree, err := NewBTreeWithOptions(bytes.Compare, Options{PageSize: 512, MaxKeySize: 16, MaxValueSize: 16})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pairs := sortedPairs(n)
+		if err := tree.BulkLoad(pairs); err != nil {
+			t.Fatalf("BulkLoad of %d pairs failed: %v", n, err)
+		}
+		checkTree(t, tree)
+		if tree.Size() != n {
+			t.Fatalf("Expected %d keys, got %d", n, tree.Size())
+		}
+		for _, p := range pairs {
+			value, err := tree.Get(p.Key)
+			if err != nil {
+				t.Fatalf("Get %s failed: %v", p.Key, err)
+			}
+			if !bytes.Equal(value, p.Value) {
+				t.Fatalf("Wrong value for %s: %s", p.Key, value)
+			}
+		}
+		if _, err := tree.Get([]byte("key")); err != ErrKeyNotFound {
+			t.Errorf("Expected ErrKeyNotFound for a missing key, got %v", err)
+		}
+
+		// The loaded tree takes inserts and deletes like any other
+		for i := 0; i < n; i += 2 {
+			if err := tree.Delete(pairs[i].Key); err != nil {
+				t.Fatalf("Delete %s failed: %v", pairs[i].Key, err)
+			}
+			if err := tree.Insert([]byte(fmt.Sprintf("key%08dx", i)), []byte("v")); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+		checkTree(t, tree)
+		if tree.Size() != n {
+			t.Errorf("Expected %d keys after deletes and inserts, got %d", n, tree.Size())
+		}
+	}
+}
+
+func TestBTree_BulkLoadRejectsBadInput(t *testing.T) {
+	tree := NewBTree()
+	pairs := sortedPairs(10)
+	pairs[4], pairs[5] = pairs[5], pairs[4]
+	if err := tree.BulkLoad(pairs); !errors.Is(err, ErrUnsorted) {
+		t.Errorf("Expected ErrUnsorted, got %v", err)
+	}
+
+	pairs = sortedPairs(10)
+	pairs[5].Key = pairs[4].Key
+	if err := tree.BulkLoad(pairs); !errors.Is(err, ErrKeyExists) {
+		t.Errorf("Expected ErrKeyExists for a duplicate key, got %v", err)
+	}
+
+	pairs = sortedPairs(10)
+	pairs[7].Value = make([]byte, BTREE_MAX_VAL_SIZE+1)
+	if err := tree.BulkLoad(pairs); err == nil {
+		t.Errorf("Expected an oversized value to be refused")
+	}
+
+	// A refused load leaves the tree empty
+	if tree.Size() != 0 || tree.root.nkeys != 0 {
+		t.Fatalf("Expected an empty tree after refused loads, got %d keys", tree.Size())
+	}
+
+	if err := tree.Insert([]byte("a"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.BulkLoad(sortedPairs(10)); err == nil {
+		t.Errorf("Expected BulkLoad into a non-empty tree to be refused")
+	}
+}
+
+func BenchmarkBTree_BulkLoad100k(b *testing.B) {
+	pairs := sortedPairs(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := NewBTree().BulkLoad(pairs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBTree_InsertSorted100k(b *testing.B) {
+	pairs := sortedPairs(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree := NewBTree()
+		for _, p := range pairs {
+			if err := tree.Insert(p.Key, p.Value); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkBTree_Insert100k(b *testing.B) {
+	keys := shuffledKeys(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		insertKeys(b, NewBTree(), keys, 0, len(keys))
+	}
+}
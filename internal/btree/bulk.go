@@ -0,0 +1,144 @@
+package btree
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsorted is returned by BulkLoad when its pairs aren't in key order
+var ErrUnsorted = errors.New("pairs are not sorted")
+
+// Pair is a key/value pair for BulkLoad
+type Pair struct {
+	Key   []byte
+	Value []byte
+}
+
+// BulkLoad fills an empty tree with pairs, which must be sorted by the
+// tree's comparator and hold no duplicate keys. Rather than inserting the
+// pairs one at a time, it packs them into leaves from left to right and
+// then builds each level of internal nodes above the one below, so the
+// tree is built in one pass with every leaf at the same depth. Nodes are
+// filled as far as a page allows, except that the last node of each level
+// takes entries from its neighbour if it would otherwise be underfull.
+//
+// The pairs are checked before the tree is touched, so on error it is
+// left empty. The keys and values are copied.
+//
+// Parameters:
+//   - pairs: The key/value pairs, in key order
+//
+// Returns:
+//   - An error if the tree isn't empty, a pair is too large, or the pairs
+//     are out of order (ErrUnsorted) or repeat a key (ErrKeyExists)
+func (t *BTree) BulkLoad(pairs []Pair) error {
+	if t.size != 0 {
+		return errors.New("bulk load into a non-empty tree")
+	}
+	for i, p := range pairs {
+		if err := t.CheckSize(p.Key, p.Value); err != nil {
+			return fmt.Errorf("pair %d: %w", i, err)
+		}
+		if i == 0 {
+			continue
+		}
+		if c := t.cmp(pairs[i-1].Key, p.Key); c == 0 {
+			return fmt.Errorf("pair %d: %w", i, ErrKeyExists)
+		} else if c > 0 {
+			return fmt.Errorf("pair %d: %w", i, ErrUnsorted)
+		}
+	}
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	level := t.bulkLeaves(pairs)
+	for len(level) > 1 {
+		level = t.bulkInternal(level)
+	}
+	t.root = level[0]
+	t.root.parent = nil
+	t.size = len(pairs)
+	t.version++
+	return nil
+}
+
+// bulkLeaves packs sorted pairs into a list of linked leaves
+func (t *BTree) bulkLeaves(pairs []Pair) []*Node {
+	leaf := NewNode(BNODE_LEAF)
+	leaves := []*Node{leaf}
+	for _, p := range pairs {
+		if leaf.nkeys > 0 && leaf.Size()+leafEntrySize(len(p.Key), len(p.Value)) >= t.pageSize {
+			leaf = NewNode(BNODE_LEAF)
+			leaves = append(leaves, leaf)
+		}
+		leaf.insertKV(int(leaf.nkeys), p.Key, p.Value)
+	}
+
+	// Top up the last leaf from the one before it
+	if n := len(leaves); n > 1 {
+		prev, last := leaves[n-2], leaves[n-1]
+		for prev.nkeys > 1 && t.isUnderflow(last) {
+			i := int(prev.nkeys) - 1
+			e := entrySize(prev, i)
+			if prev.Size()-e <= last.Size()+e {
+				break
+			}
+			last.insertKV(0, prev.getKey(i), prev.getValue(i))
+			prev.removeKV(i)
+		}
+	}
+
+	for i := 0; i < len(leaves)-1; i++ {
+		leaves[i].next = leaves[i+1]
+	}
+	return leaves
+}
+
+// bulkInternal builds the level of internal nodes above children, which
+// are in key order, and returns it. Each child after the first in a node
+// is preceded by its lowest key as the separator.
+func (t *BTree) bulkInternal(children []*Node) []*Node {
+	node := NewNode(BNODE_NODE)
+	node.setChild(0, children[0])
+	nodes := []*Node{node}
+	for _, child := range children[1:] {
+		low := lowestKey(child)
+		if len(node.childNodes) > 1 && node.Size()+internalEntrySize(len(low)) >= t.pageSize {
+			node = NewNode(BNODE_NODE)
+			node.setChild(0, child)
+			nodes = append(nodes, node)
+			continue
+		}
+		node.insertKV(int(node.nkeys), low, nil)
+		node.setChild(len(node.childNodes), child)
+	}
+
+	// Top up the last node from the one before it. A node needs at least
+	// one key, so a last node with a single child always takes another.
+	if n := len(nodes); n > 1 {
+		prev, last := nodes[n-2], nodes[n-1]
+		for prev.nkeys > 1 && (last.nkeys == 0 || t.isUnderflow(last)) {
+			i := int(prev.nkeys) - 1
+			e := entrySize(prev, i)
+			if last.nkeys > 0 && prev.Size()-e <= last.Size()+e {
+				break
+			}
+			// The moved child's separator goes with it, and the last
+			// node's old lowest key becomes the separator after it
+			last.insertKV(0, lowestKey(last), nil)
+			last.insertChild(0, prev.childNodes[i+1])
+			prev.removeKV(i)
+			prev.removeChild(i + 1)
+		}
+	}
+	return nodes
+}
+
+// lowestKey returns the first key in n's subtree
+func lowestKey(n *Node) []byte {
+	for n.typ != BNODE_LEAF {
+		n = n.childNodes[0]
+	}
+	return n.getKey(0)
+}
@@ -0,0 +1,309 @@
+package btree
+
+import (
+	"errors"
+)
+
+// ErrIterInvalidated is returned by a strict Cursor when the tree was split
+// or merged while it was positioned on a leaf.
+var ErrIterInvalidated = errors.New("iterator invalidated by a structural change")
+
+// Cursor walks the keys of a tree in ascending order, one entry at a time.
+//
+// A cursor remembers the leaf it is positioned on between calls to Next.
+// Splits, merges and redistributions can move entries between leaves or
+// retire the leaf altogether, so the cursor records the tree's version and
+// checks it on every step. If the tree changed structure, a default cursor re-seeks
+// from the last key it returned and carries on; a strict cursor stops and
+// reports ErrIterInvalidated instead. Either way it never reads from a
+// leaf that no longer belongs to the tree.
+//
+// A reverse cursor walks the keys in descending order instead. Leaves are
+// only linked forwards, so whenever it runs off the front of a leaf it
+// seeks from the root to the leaf holding the previous key.
+//
+// A cursor is not safe for concurrent use, and the tree must not be
+// modified during a call to Next.
+type Cursor struct {
+	tree    *BTree
+	strict  bool
+	reverse bool
+	version uint64 // tree version when leaf was found
+	leaf    *Node  // leaf holding the next entry, nil if unpositioned
+	from    []byte // seek key before the first entry, last returned key after
+	started bool   // whether an entry has been returned
+	done    bool
+	err     error
+	key     []byte
+	value   []byte
+}
+
+// Cursor returns a cursor positioned before the first key that is greater
+// than or equal to start. A nil start begins at the smallest key. The
+// cursor re-seeks if the tree's structure changes during iteration.
+//
+// Parameters:
+//   - start: The key to start from, or nil
+//
+// Returns:
+//   - A pointer to a new Cursor
+func (t *BTree) Cursor(start []byte) *Cursor {
+	return &Cursor{tree: t, from: start}
+}
+
+// StrictCursor is like Cursor, but the cursor fails with ErrIterInvalidated
+// instead of re-seeking if the tree's structure changes during iteration.
+//
+// Parameters:
+//   - start: The key to start from, or nil
+//
+// Returns:
+//   - A pointer to a new Cursor
+func (t *BTree) StrictCursor(start []byte) *Cursor {
+	return &Cursor{tree: t, from: start, strict: true}
+}
+
+// ReverseCursor returns a cursor positioned after the last key that is
+// less than end, which walks the tree in descending order. A nil end
+// begins at the largest key. Like Cursor, it re-seeks if the tree's
+// structure changes during iteration.
+//
+// Parameters:
+//   - end: The key to stop before, or nil
+//
+// Returns:
+//   - A pointer to a new Cursor
+func (t *BTree) ReverseCursor(end []byte) *Cursor {
+	return &Cursor{tree: t, from: end, reverse: true}
+}
+
+// Next advances the cursor to the next entry. It returns false when there
+// are no more entries or the cursor failed; check Err to tell them apart.
+func (c *Cursor) Next() bool {
+	if c.done {
+		return false
+	}
+	if c.reverse {
+		return c.prev()
+	}
+
+	t := c.tree
+	if c.leaf != nil && t.version != c.version {
+		if c.strict {
+			c.err = ErrIterInvalidated
+			c.done = true
+			return false
+		}
+		c.leaf = nil
+	}
+
+	// Stay on the current leaf if it still holds a later key, or else
+	// follow the leaf list to one that does. Entries may have been added
+	// or removed within a leaf, so search rather than trusting an index.
+	idx := -1
+	for c.leaf != nil {
+		if idx = t.leafPosition(c.leaf, c.from, false); idx >= 0 {
+			break
+		}
+		c.leaf = c.leaf.next
+	}
+	if c.leaf == nil && !c.started {
+		c.leaf, idx = t.scanFrom(c.from)
+		c.version = t.version
+	} else if c.leaf == nil && c.version != t.version {
+		c.leaf, idx = t.seek(t.root, c.from, false)
+		c.version = t.version
+	}
+	if c.leaf == nil {
+		c.done = true
+		return false
+	}
+
+	// Copy the entry; the leaf's data shifts in place on later writes
+	c.key = append([]byte(nil), c.leaf.getKey(idx)...)
+	c.value = append([]byte(nil), c.leaf.getValue(idx)...)
+	c.from = c.key
+	c.started = true
+	return true
+}
+
+// prev moves a reverse cursor to the entry before the last one returned
+func (c *Cursor) prev() bool {
+	t := c.tree
+	if c.leaf != nil && t.version != c.version {
+		if c.strict {
+			c.err = ErrIterInvalidated
+			c.done = true
+			return false
+		}
+		c.leaf = nil
+	}
+
+	// Stay on the current leaf if it still holds an earlier key, or else
+	// seek from the root, since there is no link to the previous leaf
+	idx := -1
+	if c.leaf != nil {
+		if idx = t.leafPositionBefore(c.leaf, c.from); idx < 0 {
+			c.leaf = nil
+		}
+	}
+	if c.leaf == nil {
+		if !c.started && c.from == nil {
+			c.leaf, idx = t.seekLast(t.root)
+		} else {
+			c.leaf, idx = t.seekBefore(t.root, c.from)
+		}
+		c.version = t.version
+	}
+	if c.leaf == nil {
+		c.done = true
+		return false
+	}
+
+	c.key = append([]byte(nil), c.leaf.getKey(idx)...)
+	c.value = append([]byte(nil), c.leaf.getValue(idx)...)
+	c.from = c.key
+	c.started = true
+	return true
+}
+
+// Key returns the key of the current entry
+func (c *Cursor) Key() []byte {
+	return c.key
+}
+
+// Value returns the value of the current entry
+func (c *Cursor) Value() []byte {
+	return c.value
+}
+
+// Err returns the error that stopped the cursor, if any
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// FirstLeaf returns the leaf holding the smallest keys. Following
+// NextLeaf from it visits every leaf in key order.
+//
+// Returns:
+//   - The first leaf, which is the root if the tree has a single node
+func (t *BTree) FirstLeaf() *Node {
+	node := t.root
+	for node.typ != BNODE_LEAF {
+		node = node.getChild(0)
+	}
+	return node
+}
+
+// scanFrom returns the leaf and index of the first entry whose key is
+// greater than or equal to key, or a nil leaf if there is none. A nil key
+// starts at the first entry.
+func (t *BTree) scanFrom(key []byte) (*Node, int) {
+	if key == nil {
+		for leaf := t.FirstLeaf(); leaf != nil; leaf = leaf.next {
+			if leaf.nkeys > 0 {
+				return leaf, 0
+			}
+		}
+		return nil, 0
+	}
+	return t.seek(t.root, key, true)
+}
+
+// seek finds the first entry in the subtree rooted at n whose key is after
+// key, or equal to it if inclusive. It returns a nil leaf if there is none.
+func (t *BTree) seek(n *Node, key []byte, inclusive bool) (*Node, int) {
+	if n == nil {
+		return nil, 0
+	}
+
+	if n.typ == BNODE_LEAF {
+		if idx := t.leafPosition(n, key, inclusive); idx >= 0 {
+			return n, idx
+		}
+		return nil, 0
+	}
+
+	// Start at the child findLeaf would pick and move right until a later
+	// subtree holds a matching key
+	start, found := t.search(n, key)
+	if found {
+		start++
+	}
+	for i := start; i < len(n.childNodes); i++ {
+		if leaf, idx := t.seek(n.getChild(i), key, inclusive); leaf != nil {
+			return leaf, idx
+		}
+	}
+	return nil, 0
+}
+
+// leafPosition returns the index of the first key in leaf after key, or
+// equal to it if inclusive, or -1 if there is none
+func (t *BTree) leafPosition(leaf *Node, key []byte, inclusive bool) int {
+	i, found := t.search(leaf, key)
+	if found && !inclusive {
+		i++
+	}
+	if i >= int(leaf.nkeys) {
+		return -1
+	}
+	return i
+}
+
+// seekLast finds the last entry in the subtree rooted at n, skipping empty
+// leaves. It returns a nil leaf if the subtree is empty.
+func (t *BTree) seekLast(n *Node) (*Node, int) {
+	if n == nil {
+		return nil, 0
+	}
+
+	if n.typ == BNODE_LEAF {
+		if n.nkeys > 0 {
+			return n, int(n.nkeys) - 1
+		}
+		return nil, 0
+	}
+
+	for i := len(n.childNodes) - 1; i >= 0; i-- {
+		if leaf, idx := t.seekLast(n.getChild(i)); leaf != nil {
+			return leaf, idx
+		}
+	}
+	return nil, 0
+}
+
+// seekBefore finds the last entry in the subtree rooted at n whose key is
+// before key. It returns a nil leaf if there is none.
+func (t *BTree) seekBefore(n *Node, key []byte) (*Node, int) {
+	if n == nil {
+		return nil, 0
+	}
+
+	if n.typ == BNODE_LEAF {
+		if idx := t.leafPositionBefore(n, key); idx >= 0 {
+			return n, idx
+		}
+		return nil, 0
+	}
+
+	// Start at the child findLeaf would pick and move left until an
+	// earlier subtree holds a matching key
+	start, found := t.search(n, key)
+	if found {
+		start++
+	}
+	for i := start; i >= 0; i-- {
+		if leaf, idx := t.seekBefore(n.getChild(i), key); leaf != nil {
+			return leaf, idx
+		}
+	}
+	return nil, 0
+}
+
+// leafPositionBefore returns the index of the last key in leaf before key,
+// or -1 if there is none
+func (t *BTree) leafPositionBefore(leaf *Node, key []byte) int {
+	i, _ := t.search(leaf, key)
+	return i - 1
+}
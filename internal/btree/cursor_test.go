@@ -0,0 +1,152 @@
+package btree
+
+import (
+	"fmt"
+	"testing"
+)
+
+func newCursorTree(t *testing.T, n int) *BTree {
+	tree := NewBTree()
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("key_%05d", i))
+		val := []byte(fmt.Sprintf("val_%05d", i))
+		if err := tree.Insert(key, val); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	return tree
+}
+
+func TestCursor_IteratesInOrder(t *testing.T) {
+	tree := newCursorTree(t, 2000)
+
+	c := tree.Cursor([]byte("key_00500"))
+	i := 500
+	for c.Next() {
+		if string(c.Key()) != fmt.Sprintf("key_%05d", i) || string(c.Value()) != fmt.Sprintf("val_%05d", i) {
+			t.Fatalf("Expected entry %d, got %s=%s", i, c.Key(), c.Value())
+		}
+		i++
+	}
+	if c.Err() != nil {
+		t.Errorf("Unexpected error: %v", c.Err())
+	}
+	if i != 2000 {
+		t.Errorf("Expected to stop after key_01999, stopped at %d", i)
+	}
+}
+
+func TestCursor_MergeDuringIteration(t *testing.T) {
+	// Delete most of the keys after the cursor so its leaf is merged away
+	deleteAhead := func(tree *BTree) {
+		for i := 101; i < 2000; i++ {
+			if i%10 != 0 {
+				tree.Delete([]byte(fmt.Sprintf("key_%05d", i)))
+			}
+		}
+	}
+
+	tree := newCursorTree(t, 2000)
+	strict := tree.StrictCursor(nil)
+	for i := 0; i <= 100; i++ {
+		strict.Next()
+	}
+	version := tree.version
+	deleteAhead(tree)
+	if tree.version == version {
+		t.Fatal("Expected the deletes to merge leaves")
+	}
+	if strict.Next() {
+		t.Errorf("Expected strict cursor to stop, got %s", strict.Key())
+	}
+	if strict.Err() != ErrIterInvalidated {
+		t.Errorf("Expected ErrIterInvalidated, got %v", strict.Err())
+	}
+
+	tree = newCursorTree(t, 2000)
+	c := tree.Cursor(nil)
+	for i := 0; i <= 100; i++ {
+		c.Next()
+	}
+	deleteAhead(tree)
+
+	// The cursor re-seeks and continues with exactly the surviving keys
+	var got []string
+	for c.Next() {
+		got = append(got, string(c.Key()))
+	}
+	if c.Err() != nil {
+		t.Errorf("Unexpected error: %v", c.Err())
+	}
+	if len(got) != 189 {
+		t.Fatalf("Expected 189 remaining keys, got %d", len(got))
+	}
+	for j, key := range got {
+		if want := fmt.Sprintf("key_%05d", 110+j*10); key != want {
+			t.Fatalf("Expected %s, got %s", want, key)
+		}
+	}
+}
+
+func TestReverseCursor_IteratesInOrder(t *testing.T) {
+	tree := newCursorTree(t, 2000)
+
+	c := tree.ReverseCursor([]byte("key_01500"))
+	i := 1499
+	for c.Next() {
+		if string(c.Key()) != fmt.Sprintf("key_%05d", i) || string(c.Value()) != fmt.Sprintf("val_%05d", i) {
+			t.Fatalf("Expected entry %d, got %s=%s", i, c.Key(), c.Value())
+		}
+		i--
+	}
+	if c.Err() != nil {
+		t.Errorf("Unexpected error: %v", c.Err())
+	}
+	if i != -1 {
+		t.Errorf("Expected to stop after key_00000, stopped at %d", i)
+	}
+
+	c = tree.ReverseCursor(nil)
+	if !c.Next() || string(c.Key()) != "key_01999" {
+		t.Errorf("Expected an unbounded cursor to start at the largest key, got %s", c.Key())
+	}
+}
+
+func TestReverseCursor_MergeDuringIteration(t *testing.T) {
+	tree := newCursorTree(t, 2000)
+	c := tree.ReverseCursor(nil)
+	for i := 1999; i >= 1900; i-- {
+		c.Next()
+	}
+
+	// Delete most of the keys below the cursor so their leaves merge
+	version := tree.version
+	for i := 0; i < 1899; i++ {
+		if i%10 != 0 {
+			tree.Delete([]byte(fmt.Sprintf("key_%05d", i)))
+		}
+	}
+	if tree.version == version {
+		t.Fatal("Expected the deletes to merge leaves")
+	}
+
+	// The cursor re-seeks and continues with exactly the surviving keys
+	var got []string
+	for c.Next() {
+		got = append(got, string(c.Key()))
+	}
+	if c.Err() != nil {
+		t.Errorf("Unexpected error: %v", c.Err())
+	}
+	if len(got) != 191 {
+		t.Fatalf("Expected 191 remaining keys, got %d", len(got))
+	}
+	if got[0] != "key_01899" {
+		t.Errorf("Expected key_01899 first, got %s", got[0])
+	}
+	for j, key := range got[1:] {
+		if want := fmt.Sprintf("key_%05d", 1890-j*10); key != want {
+			t.Fatalf("Expected %s, got %s", want, key)
+		}
+	}
+}
@@ -11,29 +11,52 @@ const (
 	BNODE_LEAF = 2 // leaf node: use value storage
 )
 
+// Default page size and size limits; see Options
 const (
 	BTREE_PAGE_SIZE    = 4096
 	BTREE_MAX_KEY_SIZE = 1000
 	BTREE_MAX_VAL_SIZE = 3000
+
+	// BTREE_MIN_FILL is the default fraction of a page a non-root node
+	// must fill before it is merged with or refilled from a sibling.
+	BTREE_MIN_FILL = 0.4
 )
 
-// Node represents a B+tree node that can be serialized to a fixed 4K page.
-// The on-disk layout is:
+// Node represents a B+tree node that can be serialized to a fixed-size
+// page, 4K unless its tree was created with another page size.
+// The on-disk layout depends on the node type:
 //
-//   | type (2B) | nkeys (2B) | pointers (nkeys×8B) | offsets (nkeys×2B) | key-values (variable) | unused |
+//   leaf:     | type (2B) | nkeys (2B) | offsets (nkeys×2B) | key-values (variable) | unused |
+//   internal: | type (2B) | nkeys (2B) | pointers ((nkeys+1)×8B) | offsets (nkeys×2B) | key-values (variable) | unused |
 // 
 // In this structure:
-//   - For a leaf node (typ == BNODE_LEAF), the pointers are unused and values are stored
+//   - For a leaf node (typ == BNODE_LEAF), there is no pointer section and values are stored
 //     as key-value pairs inside the data section.
-//   - For an internal node (typ == BNODE_NODE), each key has an associated child pointer (as a page number),
+//   - For an internal node (typ == BNODE_NODE), the keys separate nkeys+1 child pointers (as page numbers),
 //     and the value size in the key-value pair is 0.
 type Node struct {
+	// id identifies the node in rebalance events. Its tree assigns it the
+	// first time the node is reported and it never changes. Zero means no
+	// ID has been assigned yet.
+	id uint64
+
 	// Header
 	typ   uint16 // Node type: BNODE_NODE or BNODE_LEAF
 	nkeys uint16 // Number of keys stored
 
-	// For internal nodes only. For leaf nodes, this remains unused.
-	pointers []uint64 // Each 8 bytes representing a child pointer (page number)
+	// For internal nodes only. For leaf nodes, these remain unused.
+	childNodes []*Node   // The in-memory children, one per key plus one
+	pointers   []uint64 // Child page numbers, as read by Deserialize or written by Serialize
+
+	// For leaf nodes only. The leaves form a list in key order, so a scan
+	// can move to the next leaf without descending from the root. The link
+	// isn't serialized; LoadBTree rebuilds it.
+	next *Node
+
+	// The in-memory parent, or nil for the root. The child helpers and
+	// Split and Merge keep it up to date, so a split or rebalance can
+	// reach the parent without searching the tree.
+	parent *Node
 
 	// Offsets into 'data' for each key-value pair (except the first which always starts at 0).
 	offsets []uint16 // Each offset is 2 bytes
@@ -45,10 +68,6 @@ type Node struct {
 	data []byte // Concatenated key-value pairs
 }
 
-// Track parent-child relationships with a map
-var nodeRelationships = make(map[uint64]*Node)
-var nextNodeID uint64 = 1
-
 // NewNode creates a new node of the specified type.
 func NewNode(typ uint16) *Node {
 	return &Node{
@@ -63,15 +82,27 @@ func NewNode(typ uint16) *Node {
 // Reset clears the node's data.
 func (n *Node) Reset() {
 	n.nkeys = 0
+	n.childNodes = n.childNodes[:0]
 	n.pointers = n.pointers[:0]
 	n.offsets = n.offsets[:0]
 	n.data = n.data[:0]
 }
 
+// numPointers returns the number of child pointers stored in the node's
+// pointer section. Leaves have none; internal nodes have one more than nkeys.
+func (n *Node) numPointers() int {
+	if n.typ == BNODE_LEAF {
+		return 0
+	}
+	return int(n.nkeys) + 1
+}
+
 // Serialize converts the node to a byte slice.
+// The pointer section is only written for internal nodes; missing child
+// pointers are written as zero so the layout always matches Deserialize.
 func (n *Node) Serialize() []byte {
 	// Calculate the total size needed for the serialized node.
-	size := 4 + len(n.pointers)*8 + len(n.offsets)*2 + len(n.data)
+	size := n.Size()
 	buf := make([]byte, size)
 
 	// Write the header (type and nkeys).
@@ -80,9 +111,13 @@ func (n *Node) Serialize() []byte {
 	buf[2] = byte(n.nkeys >> 8)
 	buf[3] = byte(n.nkeys)
 
-	// Write the pointers.
+	// Write the pointers (internal nodes only).
 	offset := 4
-	for _, ptr := range n.pointers {
+	for i := 0; i < n.numPointers(); i++ {
+		var ptr uint64
+		if i < len(n.pointers) {
+			ptr = n.pointers[i]
+		}
 		buf[offset] = byte(ptr >> 56)
 		buf[offset+1] = byte(ptr >> 48)
 		buf[offset+2] = byte(ptr >> 40)
@@ -108,19 +143,31 @@ func (n *Node) Serialize() []byte {
 }
 
 // Deserialize converts a byte slice back into a node.
+// It returns an error instead of panicking when the buffer is too short
+// for the header, pointer, or offset sections it describes.
 func (n *Node) Deserialize(data []byte) error {
 	if len(data) < 4 {
 		return errors.New("data too short")
 	}
 
 	// Read the header (type and nkeys).
-	n.typ = uint16(data[0])<<8 | uint16(data[1])
+	typ := uint16(data[0])<<8 | uint16(data[1])
+	if typ != BNODE_NODE && typ != BNODE_LEAF {
+		return fmt.Errorf("invalid node type %d", typ)
+	}
+	n.typ = typ
 	n.nkeys = uint16(data[2])<<8 | uint16(data[3])
 
-	// Read the pointers.
+	// Make sure the pointer and offset sections fit in the buffer.
+	nptrs := n.numPointers()
+	if len(data) < 4+nptrs*8+int(n.nkeys)*2 {
+		return errors.New("data too short for node header")
+	}
+
+	// Read the pointers (internal nodes only).
 	offset := 4
-	n.pointers = make([]uint64, n.nkeys)
-	for i := uint16(0); i < n.nkeys; i++ {
+	n.pointers = make([]uint64, nptrs)
+	for i := 0; i < nptrs; i++ {
 		n.pointers[i] = uint64(data[offset])<<56 | uint64(data[offset+1])<<48 | uint64(data[offset+2])<<40 | uint64(data[offset+3])<<32 | uint64(data[offset+4])<<24 | uint64(data[offset+5])<<16 | uint64(data[offset+6])<<8 | uint64(data[offset+7])
 		offset += 8
 	}
@@ -136,11 +183,20 @@ func (n *Node) Deserialize(data []byte) error {
 	n.data = make([]byte, len(data)-offset)
 	copy(n.data, data[offset:])
 
+	for i := uint16(0); i < n.nkeys; i++ {
+		if int(n.offsets[i])+4 > len(n.data) {
+			return errors.New("key offset out of range")
+		}
+	}
+
 	return nil
 }
 
 // Split splits the node into two nodes and returns (rightNode, promotedKey).
-// The promotedKey is the smallest key in the right node which will be pushed up to the parent.
+// For a leaf, the promotedKey is the smallest key in the right node, which
+// stays in the leaf and is copied up to the parent. For an internal node,
+// the promotedKey moves up to the parent and is removed from the right node,
+// which keeps the child pointers to its right.
 func (n *Node) Split() (*Node, []byte) {
 	if n.nkeys < 2 {
 		return nil, nil // nothing to split
@@ -151,10 +207,21 @@ func (n *Node) Split() (*Node, []byte) {
 	// Create right node of same type
 	right := NewNode(n.typ)
 
-	// Copy pointers (for internal nodes only)
+	// Copy pointers (for internal nodes only). The left node keeps one
+	// pointer per key plus one; the pointer right of the promoted key
+	// becomes the right node's first child.
 	if n.typ == BNODE_NODE {
-		right.pointers = append(right.pointers, n.pointers[splitIdx:]...)
-		n.pointers = n.pointers[:splitIdx]
+		right.childNodes = append(right.childNodes, n.childNodes[splitIdx+1:]...)
+		n.childNodes = n.childNodes[:splitIdx+1]
+		for _, child := range right.childNodes {
+			child.parent = right
+		}
+	}
+
+	// A new leaf goes into the leaf list right after this one
+	if n.typ == BNODE_LEAF {
+		right.next = n.next
+		n.next = right
 	}
 
 	// Data slice start where right node entries begin
@@ -186,11 +253,17 @@ func (n *Node) Split() (*Node, []byte) {
 			kStart := o + 4
 			kEnd := kStart + kLen
 			if int(kEnd) <= len(right.data) {
-				promotedKey = right.data[kStart:kEnd]
+				promotedKey = append([]byte(nil), right.data[kStart:kEnd]...)
 			}
 		}
 	}
 
+	// An internal node's promoted key separates the two halves in the
+	// parent and must not also stay in the right node
+	if n.typ == BNODE_NODE {
+		right.removeKV(0)
+	}
+
 	return right, promotedKey
 }
 
@@ -202,18 +275,36 @@ func (n *Node) Merge(other *Node) error {
 	}
 
 	// Append the keys, pointers, offsets, and data from the other node.
-	n.pointers = append(n.pointers, other.pointers...)
-	n.offsets = append(n.offsets, other.offsets...)
+	// The other node's offsets are relative to its own data, so rebase them.
+	base := uint16(len(n.data))
+	for _, off := range other.offsets {
+		n.offsets = append(n.offsets, base+off)
+	}
+	n.childNodes = append(n.childNodes, other.childNodes...)
+	for _, child := range other.childNodes {
+		child.parent = n
+	}
 	n.data = append(n.data, other.data...)
 	n.nkeys += other.nkeys
 
+	// The other leaf, which follows this one, leaves the leaf list
+	if n.typ == BNODE_LEAF {
+		n.next = other.next
+	}
+
 	return nil
 }
 
 // Validate checks the node's integrity.
 func (n *Node) Validate() error {
-	// Check if the number of keys matches the number of pointers and offsets.
-	if n.nkeys != uint16(len(n.pointers)) || n.nkeys != uint16(len(n.offsets)) {
+	// Check if the number of keys matches the number of children and
+	// offsets. A node read by Deserialize has page numbers instead of
+	// children until its tree is loaded.
+	nchildren := len(n.childNodes)
+	if nchildren == 0 {
+		nchildren = len(n.pointers)
+	}
+	if nchildren != n.numPointers() || n.nkeys != uint16(len(n.offsets)) {
 		return errors.New("inconsistent number of keys, pointers, or offsets")
 	}
 
@@ -270,12 +361,12 @@ func (n *Node) Iterate(f func(key, value []byte) error) error {
 
 // Size returns the current size of the node in bytes.
 func (n *Node) Size() int {
-	return 4 + len(n.pointers)*8 + len(n.offsets)*2 + len(n.data)
+	return 4 + n.numPointers()*8 + len(n.offsets)*2 + len(n.data)
 }
 
-// IsFull checks if the node is full.
-func (n *Node) IsFull() bool {
-	return n.Size() >= BTREE_PAGE_SIZE
+// IsFull checks if the node has filled a page of pageSize bytes.
+func (n *Node) IsFull(pageSize int) bool {
+	return n.Size() >= pageSize
 }
 
 // IsEmpty checks if the node is empty.
@@ -289,80 +380,58 @@ func (n *Node) keys() [][]byte {
 		return [][]byte{}
 	}
 	keys := make([][]byte, n.nkeys)
-	for i := uint16(0); i < n.nkeys; i++ {
-		if int(i) >= len(n.offsets) {
-			continue
-		}
-		start := n.offsets[i]
-		if int(start)+4 > len(n.data) {
-			continue
-		}
-		keyLen := uint16(n.data[start])<<8 | uint16(n.data[start+1])
-		// valLen := uint16(n.data[start+2])<<8 | uint16(n.data[start+3]) // not needed here
-		keyStart := start + 4
-		keyEnd := keyStart + keyLen
-		if int(keyEnd) > len(n.data) {
-			continue
-		}
-		keys[i] = n.data[keyStart:keyEnd]
+	for i := 0; i < int(n.nkeys); i++ {
+		keys[i] = n.getKey(i)
 	}
 	return keys
 }
 
-// getChild returns the child pointer at the given index.
+// getKey returns the key at index i, or nil if there is none. It points
+// into the node's data, so it is only valid until the node changes.
+func (n *Node) getKey(i int) []byte {
+	if i < 0 || i >= int(n.nkeys) || i >= len(n.offsets) {
+		return nil
+	}
+	start := n.offsets[i]
+	if int(start)+4 > len(n.data) {
+		return nil
+	}
+	keyLen := uint16(n.data[start])<<8 | uint16(n.data[start+1])
+	keyStart := start + 4
+	keyEnd := keyStart + keyLen
+	if int(keyEnd) > len(n.data) {
+		return nil
+	}
+	return n.data[keyStart:keyEnd]
+}
+
+// getChild returns the child at the given index, or nil if there is none.
 func (n *Node) getChild(i int) *Node {
-	if i >= len(n.pointers) {
-		return nil // We shouldn't create new nodes here - should return nil
-	}
-	
-	// Get the node ID stored in the pointer
-	nodeID := n.pointers[i]
-	
-	// Check if we have this node in our relationships map
-	if child, exists := nodeRelationships[nodeID]; exists {
-		return child
-	}
-	
-	// If we reach here, either the node doesn't exist or it's not loaded
-	// In a real implementation, we would load the node from disk
-	// For now, create a new node and track the relationship
-	child := NewNode(BNODE_LEAF)
-	
-	// Only store if we have a valid nodeID (not 0)
-	if nodeID > 0 {
-		nodeRelationships[nodeID] = child
-	}
-	
-	return child
+	if i < 0 || i >= len(n.childNodes) {
+		return nil
+	}
+	return n.childNodes[i]
 }
 
-// setChild sets the child pointer at the given index.
+// setChild sets the child at the given index.
 func (n *Node) setChild(i int, child *Node) {
-	// Ensure we have enough pointers
-	if i >= len(n.pointers) {
-		n.pointers = append(n.pointers, make([]uint64, i-len(n.pointers)+1)...)
-	}
-	
-	// If the child node doesn't have an ID yet, assign one
-	var nodeID uint64
-	
-	// Find the ID for this child node
-	for id, node := range nodeRelationships {
-		if node == child {
-			nodeID = id
-			break
-		}
+	// Ensure we have enough children
+	if i >= len(n.childNodes) {
+		n.childNodes = append(n.childNodes, make([]*Node, i-len(n.childNodes)+1)...)
 	}
-	
-	// If no existing ID found, create a new one
-	if nodeID == 0 && child != nil {
-		nodeID = nextNodeID
-		nextNodeID++
-		nodeRelationships[nodeID] = child
+	n.childNodes[i] = child
+	if child != nil {
+		child.parent = n
 	}
-	
-	// Store the nodeID in the pointer
-	n.pointers[i] = nodeID
+}
+
+// insertChild inserts a child at index i, shifting later children one
+// place to the right.
+func (n *Node) insertChild(i int, child *Node) {
+	n.childNodes = append(n.childNodes, nil)
+	copy(n.childNodes[i+1:], n.childNodes[i:])
+	n.childNodes[i] = child
+	child.parent = n
 }
 
 // insertKV inserts a key-value pair at the given position.
@@ -452,11 +521,23 @@ func (n *Node) removeKV(pos int) {
 	n.nkeys--
 }
 
+// removeChild removes the child at index i.
+func (n *Node) removeChild(i int) {
+	if i < 0 || i >= len(n.childNodes) {
+		return
+	}
+	n.childNodes = append(n.childNodes[:i], n.childNodes[i+1:]...)
+}
+
+// NextLeaf returns the leaf after this one in key order, or nil if this
+// is the last leaf or not a leaf.
+func (n *Node) NextLeaf() *Node {
+	return n.next
+}
+
 // children returns the child nodes.
 func (n *Node) children() []*Node {
-	children := make([]*Node, len(n.pointers))
-	for i := range n.pointers {
-		children[i] = n.getChild(i)
-	}
+	children := make([]*Node, len(n.childNodes))
+	copy(children, n.childNodes)
 	return children
 }
\ No newline at end of file
@@ -0,0 +1,96 @@
+package btree
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestNode_SerializeRoundTrip(t *testing.T) {
+	// Build a leaf node with a few entries
+	leaf := NewNode(BNODE_LEAF)
+	for i := 0; i < 5; i++ {
+		leaf.insertKV(i, []byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i)))
+	}
+
+	// Build an internal node with separator keys and child pointers
+	internal := NewNode(BNODE_NODE)
+	for i := 0; i < 3; i++ {
+		internal.insertKV(i, []byte(fmt.Sprintf("sep%d", i)), nil)
+	}
+	internal.pointers = []uint64{11, 12, 13, 14}
+
+	for _, n := range []*Node{leaf, internal} {
+		data := n.Serialize()
+
+		// Leaves must not carry a pointer section
+		if n.typ == BNODE_LEAF && len(data) != 4+int(n.nkeys)*2+len(n.data) {
+			t.Errorf("Leaf serialized with unexpected size %d", len(data))
+		}
+
+		decoded := &Node{}
+		if err := decoded.Deserialize(data); err != nil {
+			t.Fatalf("Deserialize failed for type %d: %v", n.typ, err)
+		}
+		if err := decoded.Validate(); err != nil {
+			t.Errorf("Decoded node failed validation: %v", err)
+		}
+
+		if again := decoded.Serialize(); !bytes.Equal(data, again) {
+			t.Errorf("Round trip for type %d not byte-identical", n.typ)
+		}
+	}
+}
+
+func TestNode_DeserializeShortData(t *testing.T) {
+	leaf := NewNode(BNODE_LEAF)
+	leaf.insertKV(0, []byte("key"), []byte("value"))
+	data := leaf.Serialize()
+
+	// Truncated buffers must return an error rather than panic
+	for _, n := range []int{0, 3, 5, 7} {
+		if err := (&Node{}).Deserialize(data[:n]); err == nil {
+			t.Errorf("Expected error for %d-byte buffer", n)
+		}
+	}
+}
+
+func TestNode_SplitInternal(t *testing.T) {
+	n := NewNode(BNODE_NODE)
+	children := make([]*Node, 5)
+	for i := range children {
+		children[i] = NewNode(BNODE_LEAF)
+		n.setChild(i, children[i])
+	}
+	for i := 0; i < 4; i++ {
+		n.insertKV(i, []byte{byte('a' + i)}, nil)
+	}
+
+	right, promoted := n.Split()
+
+	if string(promoted) != "c" {
+		t.Errorf("Expected promoted key c, got %s", promoted)
+	}
+	if err := n.Validate(); err != nil {
+		t.Errorf("Left node invalid: %v", err)
+	}
+	if err := right.Validate(); err != nil {
+		t.Errorf("Right node invalid: %v", err)
+	}
+	if right.nkeys != 1 || !bytes.Equal(right.keys()[0], []byte("d")) {
+		t.Errorf("Expected right node to hold only d, got %q", right.keys())
+	}
+
+	// Every child is still reachable from exactly one side
+	for i, child := range children {
+		var got *Node
+		if i < 3 {
+			got = n.getChild(i)
+		} else {
+			got = right.getChild(i - 3)
+		}
+		if got != child {
+			t.Errorf("Expected child %d to keep its identity across the split", i)
+		}
+	}
+}
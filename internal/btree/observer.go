@@ -0,0 +1,79 @@
+package btree
+
+// RebalanceOp identifies the structural change a RebalanceEvent describes
+type RebalanceOp int
+
+const (
+	// RebalanceMerge moves every entry of a node into its left sibling and
+	// removes the node from its parent
+	RebalanceMerge RebalanceOp = iota
+
+	// RebalanceRedistribute moves entries between two siblings so both
+	// stay above the minimum fill
+	RebalanceRedistribute
+)
+
+func (op RebalanceOp) String() string {
+	switch op {
+	case RebalanceMerge:
+		return "merge"
+	case RebalanceRedistribute:
+		return "redistribute"
+	default:
+		return "unknown"
+	}
+}
+
+// RebalanceEvent describes one merge or redistribution after a delete.
+// Nodes are identified by their IDs, which stay stable while a node is in
+// the tree.
+type RebalanceEvent struct {
+	Op        RebalanceOp
+	ParentID  uint64   // the parent of both siblings
+	LeftID    uint64   // the left sibling
+	RightID   uint64   // the right sibling; after a merge it is gone
+	Separator []byte   // the parent's key between the siblings, before the change
+	Keys      [][]byte // the keys that moved between the siblings
+}
+
+// RebalanceObserver is called after each merge or redistribution, while
+// the tree is still locked by its caller. It must not modify the tree.
+type RebalanceObserver func(RebalanceEvent)
+
+// SetRebalanceObserver registers obs to be told about every merge and
+// redistribution, for tracing and for tests that assert on the tree's
+// structure. Passing nil removes the observer. No events are built while
+// no observer is set.
+//
+// Parameters:
+//   - obs: The observer, or nil
+func (t *BTree) SetRebalanceObserver(obs RebalanceObserver) {
+	t.observer = obs
+}
+
+// rebalanceEvent captures the siblings at pos in parent before op changes
+// them, along with the keys about to move
+func (t *BTree) rebalanceEvent(op RebalanceOp, parent *Node, pos int, moved [][]byte) RebalanceEvent {
+	keys := make([][]byte, len(moved))
+	for i, k := range moved {
+		keys[i] = append([]byte(nil), k...)
+	}
+	return RebalanceEvent{
+		Op:        op,
+		ParentID:  t.nodeID(parent),
+		LeftID:    t.nodeID(parent.getChild(pos)),
+		RightID:   t.nodeID(parent.getChild(pos + 1)),
+		Separator: append([]byte(nil), parent.getKey(pos)...),
+		Keys:      keys,
+	}
+}
+
+// nodeID returns n's ID, assigning it the next unused one in this tree if
+// it doesn't have one yet
+func (t *BTree) nodeID(n *Node) uint64 {
+	if n.id == 0 {
+		t.lastID++
+		n.id = t.lastID
+	}
+	return n.id
+}
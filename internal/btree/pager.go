@@ -0,0 +1,162 @@
+package btree
+
+import (
+	"errors"
+	"fmt"
+)
+
+// WritePages serializes every node of the tree into its own page of the
+// tree's page size. Pages are numbered from 1 in the order they are
+// written, children before their parents, and each internal page holds
+// its children's page numbers, so the pages can be read back with
+// LoadBTree.
+//
+// Parameters:
+//   - write: Called once per page, with consecutive page numbers
+//
+// Returns:
+//   - The page number of the root node
+//   - An error if a node doesn't fit in a page or write fails
+func (t *BTree) WritePages(write func(page uint64, data []byte) error) (uint64, error) {
+	var next uint64
+	var writeNode func(n *Node) (uint64, error)
+	writeNode = func(n *Node) (uint64, error) {
+		// Copy the node so its children can be written as page numbers
+		page := &Node{typ: n.typ, nkeys: n.nkeys, offsets: n.offsets, data: n.data}
+		if n.typ != BNODE_LEAF {
+			page.pointers = make([]uint64, len(n.childNodes))
+			for i, child := range n.childNodes {
+				if child == nil {
+					return 0, errors.New("missing child node")
+				}
+				num, err := writeNode(child)
+				if err != nil {
+					return 0, err
+				}
+				page.pointers[i] = num
+			}
+		}
+
+		data := page.Serialize()
+		if len(data) > t.pageSize {
+			return 0, fmt.Errorf("node of %d bytes doesn't fit in a %d-byte page", len(data), t.pageSize)
+		}
+		buf := make([]byte, t.pageSize)
+		copy(buf, data)
+
+		next++
+		if err := write(next, buf); err != nil {
+			return 0, err
+		}
+		return next, nil
+	}
+
+	return writeNode(t.root)
+}
+
+// LoadBTree rebuilds a tree with the default options from pages written
+// by WritePages, linking its leaves in key order. The tree must be loaded
+// with the comparator it was written with.
+//
+// Parameters:
+//   - cmp: The key comparator
+//   - root: The page number of the root node
+//   - read: Returns the page with the given number
+//
+// Returns:
+//   - The rebuilt tree
+//   - An error if a page can't be read or doesn't hold a valid node
+func LoadBTree(cmp Comparator, root uint64, read func(page uint64) ([]byte, error)) (*BTree, error) {
+	return LoadBTreeWithOptions(cmp, Options{}, root, read)
+}
+
+// LoadBTreeWithOptions is LoadBTree for a tree written with opts. Pages
+// passed to it by read hold opts.PageSize bytes.
+//
+// Parameters:
+//   - cmp: The key comparator
+//   - opts: The options the tree was created with
+//   - root: The page number of the root node
+//   - read: Returns the page with the given number
+//
+// Returns:
+//   - The rebuilt tree
+//   - An error if opts are out of range, or a page can't be read or
+//     doesn't hold a valid node
+func LoadBTreeWithOptions(cmp Comparator, opts Options, root uint64, read func(page uint64) ([]byte, error)) (*BTree, error) {
+	t, err := NewBTreeWithOptions(cmp, opts)
+	if err != nil {
+		return nil, err
+	}
+	loaded := make(map[uint64]bool)
+	var lastLeaf *Node // Leaves load left to right
+
+	var loadNode func(num uint64) (*Node, error)
+	loadNode = func(num uint64) (*Node, error) {
+		// A page reachable twice would make the tree a graph
+		if loaded[num] {
+			return nil, fmt.Errorf("page %d is referenced more than once", num)
+		}
+		loaded[num] = true
+
+		data, err := read(num)
+		if err != nil {
+			return nil, err
+		}
+		n := NewNode(BNODE_LEAF)
+		if err := n.Deserialize(data); err != nil {
+			return nil, fmt.Errorf("page %d: %v", num, err)
+		}
+		if err := n.trimData(); err != nil {
+			return nil, fmt.Errorf("page %d: %v", num, err)
+		}
+
+		if n.typ == BNODE_LEAF {
+			t.size += int(n.nkeys)
+			if lastLeaf != nil {
+				lastLeaf.next = n
+			}
+			lastLeaf = n
+			return n, nil
+		}
+
+		// Replace the page numbers with the loaded children
+		pages := n.pointers
+		n.pointers = nil
+		for i, page := range pages {
+			child, err := loadNode(page)
+			if err != nil {
+				return nil, err
+			}
+			n.setChild(i, child)
+		}
+		return n, nil
+	}
+
+	node, err := loadNode(root)
+	if err != nil {
+		return nil, err
+	}
+	t.root = node
+	return t, nil
+}
+
+// trimData drops the page padding that Deserialize reads past the node's
+// last entry, so the node's size is what it was when it was written.
+func (n *Node) trimData() error {
+	end := 0
+	for i := 0; i < int(n.nkeys); i++ {
+		start := int(n.offsets[i])
+		if start != end || start+4 > len(n.data) {
+			return errors.New("entries are not contiguous")
+		}
+		keyLen := int(n.data[start])<<8 | int(n.data[start+1])
+		valLen := int(n.data[start+2])<<8 | int(n.data[start+3])
+		end = start + 4 + keyLen + valLen
+		if end > len(n.data) {
+			return errors.New("entry runs past the end of the page")
+		}
+	}
+	n.data = n.data[:end]
+	return nil
+}
@@ -0,0 +1,128 @@
+package btree
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestBTree_WritePagesLoadBTreeRoundTrip(t *testing.T) {
+	tree := NewBTree()
+	key := func(i int) []byte { return []byte(fmt.Sprintf("key_%05d", i)) }
+	for i := 0; i < 1000; i++ {
+		if err := tree.Insert(key(i), []byte(fmt.Sprintf("val_%05d", i))); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	// A root over a level of leaves
+	if tree.Height() != 1 {
+		t.Fatalf("Expected a two-level tree, got height %d", tree.Height())
+	}
+
+	pages := make(map[uint64][]byte)
+	root, err := tree.WritePages(func(page uint64, data []byte) error {
+		pages[page] = data
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WritePages failed: %v", err)
+	}
+
+	// The root page alone names its children by page number
+	var decoded Node
+	if err := decoded.Deserialize(pages[root]); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if len(decoded.pointers) != len(tree.root.childNodes) {
+		t.Fatalf("Expected %d child pages, got %d", len(tree.root.childNodes), len(decoded.pointers))
+	}
+	for _, page := range decoded.pointers {
+		if _, ok := pages[page]; !ok || page == root {
+			t.Errorf("Root points at page %d, which isn't a child page", page)
+		}
+	}
+
+	loaded, err := LoadBTree(bytes.Compare, root, func(page uint64) ([]byte, error) {
+		data, ok := pages[page]
+		if !ok {
+			return nil, fmt.Errorf("no page %d", page)
+		}
+		return data, nil
+	})
+	if err != nil {
+		t.Fatalf("LoadBTree failed: %v", err)
+	}
+	checkTree(t, loaded)
+	if loaded.Size() != 1000 {
+		t.Fatalf("Expected 1000 keys, got %d", loaded.Size())
+	}
+	for i := 0; i < 1000; i++ {
+		value, err := loaded.Get(key(i))
+		if err != nil || string(value) != fmt.Sprintf("val_%05d", i) {
+			t.Fatalf("Expected val_%05d for %s, got %s (%v)", i, key(i), value, err)
+		}
+	}
+
+	// The loaded tree is a working tree, independent of the original
+	for i := 1000; i < 2000; i++ {
+		if err := loaded.Insert(key(i), []byte("new")); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	for i := 0; i < 1500; i++ {
+		if err := loaded.Delete(key(i)); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	checkTree(t, loaded)
+	if loaded.Size() != 500 {
+		t.Errorf("Expected 500 keys, got %d", loaded.Size())
+	}
+	if tree.Size() != 1000 {
+		t.Errorf("Expected the original tree to keep 1000 keys, got %d", tree.Size())
+	}
+	checkTree(t, tree)
+}
+
+func TestBTree_WritePagesUsesConfiguredPageSize(t *testing.T) {
+	opts := Options{PageSize: 8192, MaxValueSize: 6 * 1024}
+	tree, err := NewBTreeWithOptions(bytes.Compare, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := bytes.Repeat([]byte("v"), 6*1024)
+	for i := 0; i < 50; i++ {
+		if err := tree.Insert([]byte(fmt.Sprintf("key%03d", i)), value[:(i%3+1)*2048]); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	pages := make(map[uint64][]byte)
+	root, err := tree.WritePages(func(page uint64, data []byte) error {
+		if len(data) != 8192 {
+			t.Fatalf("Expected an 8192-byte page, got %d bytes", len(data))
+		}
+		pages[page] = data
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WritePages failed: %v", err)
+	}
+
+	loaded, err := LoadBTreeWithOptions(bytes.Compare, opts, root, func(page uint64) ([]byte, error) {
+		return pages[page], nil
+	})
+	if err != nil {
+		t.Fatalf("LoadBTreeWithOptions failed: %v", err)
+	}
+	checkTree(t, loaded)
+	if loaded.Options().PageSize != 8192 {
+		t.Errorf("Expected the loaded tree to keep its 8K pages, got %d", loaded.Options().PageSize)
+	}
+	for i := 0; i < 50; i++ {
+		got, err := loaded.Get([]byte(fmt.Sprintf("key%03d", i)))
+		if err != nil || len(got) != (i%3+1)*2048 {
+			t.Fatalf("Expected a %d-byte value for key%03d, got %d bytes (%v)", (i%3+1)*2048, i, len(got), err)
+		}
+	}
+}
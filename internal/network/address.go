@@ -0,0 +1,92 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidAddress is returned when an address is not a valid host:port
+	ErrInvalidAddress = errors.New("invalid address")
+
+	// ErrAddressMismatch is returned when an advertised address can't reach
+	// the address a server is bound to
+	ErrAddressMismatch = errors.New("advertised address does not match bind address")
+)
+
+// NormalizeAddress returns the canonical host:port form of addr. An empty
+// host, as in ":50051", becomes "localhost"; hosts are lower-cased, and
+// IPv6 hosts are bracketed.
+//
+// Parameters:
+//   - addr: The address to normalize
+//
+// Returns:
+//   - The canonical address
+//   - An error wrapping ErrInvalidAddress if addr has no valid port
+func NormalizeAddress(addr string) (string, error) {
+	host, port, err := splitAddress(addr)
+	if err != nil {
+		return "", err
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(strings.ToLower(host), strconv.Itoa(port)), nil
+}
+
+// CheckAdvertised verifies that peers dialing advertised reach a server
+// bound to bind. The ports must match, and unless bind listens on every
+// interface, so must the hosts.
+//
+// Parameters:
+//   - bind: The address the server listens on
+//   - advertised: The address given to peers
+//
+// Returns:
+//   - An error wrapping ErrInvalidAddress or ErrAddressMismatch, or nil
+func CheckAdvertised(bind, advertised string) error {
+	bindHost, bindPort, err := splitAddress(bind)
+	if err != nil {
+		return err
+	}
+	_, advPort, err := splitAddress(advertised)
+	if err != nil {
+		return err
+	}
+
+	if bindPort != advPort {
+		return fmt.Errorf("%w: %s is not on port %d", ErrAddressMismatch, advertised, bindPort)
+	}
+	if isWildcardHost(bindHost) {
+		return nil
+	}
+
+	canonicalBind, _ := NormalizeAddress(bind)
+	canonicalAdv, _ := NormalizeAddress(advertised)
+	if canonicalBind != canonicalAdv {
+		return fmt.Errorf("%w: %s is not %s", ErrAddressMismatch, canonicalAdv, canonicalBind)
+	}
+	return nil
+}
+
+// splitAddress splits addr into its host and a port in 1-65535
+func splitAddress(addr string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(strings.TrimSpace(addr))
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: %q: %v", ErrInvalidAddress, addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("%w: %q: bad port %q", ErrInvalidAddress, addr, portStr)
+	}
+	return host, port, nil
+}
+
+// isWildcardHost reports whether host listens on every interface
+func isWildcardHost(host string) bool {
+	return host == "" || host == "0.0.0.0" || host == "::"
+}
@@ -0,0 +1,62 @@
+package network
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{":50051", "localhost:50051"},
+		{"localhost:50051", "localhost:50051"},
+		{"LocalHost:50051", "localhost:50051"},
+		{"10.0.0.5:7000", "10.0.0.5:7000"},
+		{" node1:7000 ", "node1:7000"},
+		{"[::1]:7000", "[::1]:7000"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeAddress(tt.addr)
+		if err != nil {
+			t.Errorf("NormalizeAddress(%q) failed: %v", tt.addr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeAddress(%q): Expected %s, got %s", tt.addr, tt.want, got)
+		}
+	}
+
+	for _, addr := range []string{"", "50051", "localhost", "host:", "host:http", "host:0", "host:70000"} {
+		if _, err := NormalizeAddress(addr); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("NormalizeAddress(%q): Expected ErrInvalidAddress, got %v", addr, err)
+		}
+	}
+}
+
+func TestCheckAdvertised(t *testing.T) {
+	ok := [][2]string{
+		{":50051", "node1:50051"},
+		{"0.0.0.0:50051", "10.0.0.5:50051"},
+		{":50051", ":50051"},
+		{":50051", "localhost:50051"},
+		{"node1:50051", "NODE1:50051"},
+	}
+	for _, pair := range ok {
+		if err := CheckAdvertised(pair[0], pair[1]); err != nil {
+			t.Errorf("CheckAdvertised(%q, %q) failed: %v", pair[0], pair[1], err)
+		}
+	}
+
+	mismatched := [][2]string{
+		{":50051", "node1:50052"},
+		{"node1:50051", "node2:50051"},
+		{"127.0.0.1:50051", "10.0.0.5:50051"},
+	}
+	for _, pair := range mismatched {
+		if err := CheckAdvertised(pair[0], pair[1]); !errors.Is(err, ErrAddressMismatch) {
+			t.Errorf("CheckAdvertised(%q, %q): Expected ErrAddressMismatch, got %v", pair[0], pair[1], err)
+		}
+	}
+}
@@ -1,69 +1,203 @@
 package network
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
+	"time"
+
+	"godatabase/internal/storage"
 )
 
-// Client represents a TCP client for the key-value store
+// defaultMaxConns is how many connections a client opens by default
+const defaultMaxConns = 4
+
+// ErrClientClosed is returned by operations on a closed client
+var ErrClientClosed = errors.New("client closed")
+
+// Client represents a TCP client for the key-value store. It keeps a small
+// pool of connections, so concurrent operations don't wait on each other,
+// and re-dials connections that fail.
 type Client struct {
-	addr string
-	conn net.Conn
-	mu   sync.Mutex
+	addr     string
+	maxConns int
+	
+	// slots holds a token for each connection in use, capping them at
+	// maxConns
+	slots chan struct{}
+	
+	// Idle connections, most recently used last
+	idle   []net.Conn
+	closed bool
+	mu     sync.Mutex
+}
+
+// ClientOption configures a Client
+type ClientOption func(*Client)
+
+// WithMaxConns sets the most connections the client opens to the server
+// at once. Values below 1 are treated as 1.
+func WithMaxConns(n int) ClientOption {
+	return func(c *Client) {
+		if n < 1 {
+			n = 1
+		}
+		c.maxConns = n
+	}
 }
 
-// NewClient creates a new TCP client
-func NewClient(addr string) *Client {
-	return &Client{
-		addr: addr,
+// NewClient creates a new TCP client. Connections are opened as
+// operations need them.
+func NewClient(addr string, opts ...ClientOption) *Client {
+	c := &Client{
+		addr:     addr,
+		maxConns: defaultMaxConns,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.slots = make(chan struct{}, c.maxConns)
+	return c
 }
 
-// Connect connects to the server
+// Connect opens a connection to the server, to check that it is reachable
 func (c *Client) Connect() error {
-	conn, err := net.Dial("tcp", c.addr)
+	conn, err := c.acquire()
 	if err != nil {
-		return fmt.Errorf("failed to connect: %w", err)
+		return err
 	}
-	c.conn = conn
+	c.release(conn, true)
 	return nil
 }
 
-// Close closes the connection
+// Close closes the client's idle connections. Connections in use are
+// closed when their operations finish.
 func (c *Client) Close() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	
-	if c.conn != nil {
-		return c.conn.Close()
+	c.closed = true
+	var firstErr error
+	for _, conn := range c.idle {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
 	}
-	return nil
+	c.idle = nil
+	return firstErr
 }
 
-// Put stores a key-value pair
-func (c *Client) Put(key, value []byte) error {
+// acquire returns a connection for one operation, reusing a healthy idle
+// connection if there is one and dialing otherwise. It waits while
+// maxConns connections are in use. The connection must be given back
+// with release.
+func (c *Client) acquire() (net.Conn, error) {
+	c.slots <- struct{}{}
+	
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			<-c.slots
+			return nil, ErrClientClosed
+		}
+		if len(c.idle) == 0 {
+			c.mu.Unlock()
+			break
+		}
+		conn := c.idle[len(c.idle)-1]
+		c.idle = c.idle[:len(c.idle)-1]
+		c.mu.Unlock()
+		
+		if healthy(conn) {
+			return conn, nil
+		}
+		conn.Close()
+	}
+	
+	conn, err := net.Dial("tcp", c.addr)
+	if err != nil {
+		<-c.slots
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	return conn, nil
+}
+
+// release gives back a connection from acquire, keeping it for reuse if
+// it is still usable and closing it otherwise
+func (c *Client) release(conn net.Conn, reuse bool) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	if reuse && !c.closed {
+		c.idle = append(c.idle, conn)
+	} else {
+		conn.Close()
+	}
+	c.mu.Unlock()
+	<-c.slots
+}
+
+// healthy reports whether an idle connection is still open. The server
+// only writes in reply to a request, so an idle connection with something
+// to read has been closed by the server, or is out of step with it.
+func healthy(conn net.Conn) bool {
+	if err := conn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		return false
+	}
+	var b [1]byte
+	_, err := conn.Read(b[:])
+	conn.SetReadDeadline(time.Time{})
 	
-	if c.conn == nil {
-		return fmt.Errorf("not connected")
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// do sends a request and reads its response. A request that fails
+// because of its connection is retried once on a new connection, since
+// the server may have dropped the connection while it sat idle.
+func (c *Client) do(msg *Message) (*Response, error) {
+	resp, err := c.roundTrip(msg)
+	if err != nil && !errors.Is(err, ErrClientClosed) {
+		resp, err = c.roundTrip(msg)
+	}
+	return resp, err
+}
+
+// roundTrip sends a request on one connection and reads its response.
+// Any error leaves the connection in an unknown state, so it is closed.
+func (c *Client) roundTrip(msg *Message) (*Response, error) {
+	conn, err := c.acquire()
+	if err != nil {
+		return nil, err
 	}
 	
 	// Send request
-	msg := &Message{
-		Op:    OpPut,
-		Key:   key,
-		Value: value,
-	}
-	if err := WriteMessage(c.conn, msg); err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+	if err := WriteMessage(conn, msg); err != nil {
+		c.release(conn, false)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	
 	// Read response
-	resp, err := ReadResponse(c.conn)
+	resp, err := ReadResponse(conn)
 	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+		c.release(conn, false)
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	
+	c.release(conn, true)
+	return resp, nil
+}
+
+// Put stores a key-value pair
+func (c *Client) Put(key, value []byte) error {
+	resp, err := c.do(&Message{
+		Op:    OpPut,
+		Key:   key,
+		Value: value,
+	})
+	if err != nil {
+		return err
 	}
 	
 	if resp.Status != StatusOK {
@@ -75,30 +209,16 @@ func (c *Client) Put(key, value []byte) error {
 
 // Get retrieves a value for a key
 func (c *Client) Get(key []byte) ([]byte, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	
-	if c.conn == nil {
-		return nil, fmt.Errorf("not connected")
-	}
-	
-	// Send request
-	msg := &Message{
+	resp, err := c.do(&Message{
 		Op:  OpGet,
 		Key: key,
-	}
-	if err := WriteMessage(c.conn, msg); err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	
-	// Read response
-	resp, err := ReadResponse(c.conn)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 	
 	if resp.Status == StatusNotFound {
-		return nil, fmt.Errorf("key not found")
+		return nil, storage.ErrKeyNotFound
 	}
 	if resp.Status != StatusOK {
 		return nil, fmt.Errorf("server error: %s", resp.Error)
@@ -109,31 +229,73 @@ func (c *Client) Get(key []byte) ([]byte, error) {
 
 // Delete removes a key-value pair
 func (c *Client) Delete(key []byte) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	resp, err := c.do(&Message{
+		Op:  OpDelete,
+		Key: key,
+	})
+	if err != nil {
+		return err
+	}
 	
-	if c.conn == nil {
-		return fmt.Errorf("not connected")
+	if resp.Status == StatusNotFound {
+		return storage.ErrKeyNotFound
+	}
+	if resp.Status != StatusOK {
+		return fmt.Errorf("server error: %s", resp.Error)
 	}
 	
-	// Send request
-	msg := &Message{
-		Op:  OpDelete,
-		Key: key,
+	return nil
+}
+
+// Scan returns the key-value pairs with keys from start, inclusive, up to
+// end, exclusive, in ascending key order. An empty end means the range
+// has no end.
+func (c *Client) Scan(start, end []byte) ([]storage.KV, error) {
+	pairs, done, err := c.scanOnce(start, end)
+	if err != nil && !errors.Is(err, ErrClientClosed) {
+		// Like other operations, retry once on a new connection
+		pairs, done, err = c.scanOnce(start, end)
+	}
+	if err != nil {
+		return nil, err
 	}
-	if err := WriteMessage(c.conn, msg); err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+	
+	if done.Error != "" {
+		return nil, fmt.Errorf("server error: %s", done.Error)
 	}
 	
-	// Read response
-	resp, err := ReadResponse(c.conn)
+	return pairs, nil
+}
+
+// scanOnce sends a scan on one connection and reads its pairs up to the
+// terminator, which it returns. An error leaves the connection in an
+// unknown state, so it is closed.
+func (c *Client) scanOnce(start, end []byte) ([]storage.KV, *ScanResponse, error) {
+	conn, err := c.acquire()
 	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+		return nil, nil, err
 	}
 	
-	if resp.Status != StatusOK {
-		return fmt.Errorf("server error: %s", resp.Error)
+	// Send request
+	if err := WriteScanRequest(conn, start, end); err != nil {
+		c.release(conn, false)
+		return nil, nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	
-	return nil
-} 
\ No newline at end of file
+	// Read pairs up to the terminator. The server sends nothing after it
+	// until the next request, so buffering can't swallow a later reply.
+	r := bufio.NewReader(conn)
+	var pairs []storage.KV
+	for {
+		resp, err := ReadScanResponse(r)
+		if err != nil {
+			c.release(conn, false)
+			return nil, nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		if resp.Done {
+			c.release(conn, true)
+			return pairs, resp, nil
+		}
+		pairs = append(pairs, storage.KV{Key: resp.Key, Value: resp.Value})
+	}
+}
@@ -0,0 +1,215 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+// startServer serves store on addr until the returned stop function is
+// called
+func startServer(t *testing.T, addr string, store storage.Storage) (stop func()) {
+	srv := NewServer(addr, store)
+	done := make(chan error, 1)
+	go func() { done <- srv.Start() }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Server on %s never started: %v", addr, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			srv.Stop()
+			if err := <-done; err != nil {
+				t.Errorf("Start returned %v", err)
+			}
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func newTestStore(t *testing.T) storage.Storage {
+	store, err := storage.NewStorageEngine(filepath.Join(t.TempDir(), "data.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// freeAddr returns a localhost address that is currently unused
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func TestClient_RecoversFromServerRestart(t *testing.T) {
+	addr := freeAddr(t)
+	store := newTestStore(t)
+	stop := startServer(t, addr, store)
+
+	c := NewClient(addr)
+	defer c.Close()
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := c.Put([]byte("key1"), []byte("value1")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// The client's pooled connection dies with the server
+	stop()
+	if _, err := c.Get([]byte("key1")); err == nil {
+		t.Error("Expected Get to fail while the server is down")
+	}
+
+	stop = startServer(t, addr, store)
+	value, err := c.Get([]byte("key1"))
+	if err != nil {
+		t.Fatalf("Get after restart failed: %v", err)
+	}
+	if string(value) != "value1" {
+		t.Errorf("Expected value1, got %s", value)
+	}
+	if err := c.Put([]byte("key2"), []byte("value2")); err != nil {
+		t.Errorf("Put after restart failed: %v", err)
+	}
+
+	// A restart between operations leaves a dead idle connection, which
+	// must be discarded rather than surfaced as an error
+	stop()
+	startServer(t, addr, store)
+	if value, err := c.Get([]byte("key2")); err != nil || string(value) != "value2" {
+		t.Errorf("Expected value2 after a quiet restart, got %s (%v)", value, err)
+	}
+}
+
+func TestClient_ConcurrentOperationsShareThePool(t *testing.T) {
+	addr := freeAddr(t)
+	startServer(t, addr, newTestStore(t))
+
+	c := NewClient(addr, WithMaxConns(2))
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := []byte(fmt.Sprintf("key%d", i))
+			if err := c.Put(key, key); err != nil {
+				t.Errorf("Put failed: %v", err)
+				return
+			}
+			if value, err := c.Get(key); err != nil || string(value) != string(key) {
+				t.Errorf("Expected %s, got %s (%v)", key, value, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	idle := len(c.idle)
+	c.mu.Unlock()
+	if idle > 2 {
+		t.Errorf("Expected at most 2 pooled connections, got %d", idle)
+	}
+}
+
+func TestClient_Scan(t *testing.T) {
+	addr := freeAddr(t)
+	store := newTestStore(t)
+	for i := 0; i < 10; i++ {
+		key := []byte(fmt.Sprintf("key%d", i))
+		if err := store.Put(key, []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	startServer(t, addr, store)
+
+	c := NewClient(addr)
+	defer c.Close()
+
+	pairs, err := c.Scan([]byte("key3"), []byte("key7"))
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(pairs) != 4 {
+		t.Fatalf("Expected 4 pairs, got %d", len(pairs))
+	}
+	for i, pair := range pairs {
+		if want := fmt.Sprintf("key%d", i+3); string(pair.Key) != want {
+			t.Errorf("Expected key %s, got %s", want, pair.Key)
+		}
+		if want := fmt.Sprintf("value%d", i+3); string(pair.Value) != want {
+			t.Errorf("Expected value %s, got %s", want, pair.Value)
+		}
+	}
+
+	// An empty end scans to the last key
+	pairs, err = c.Scan([]byte("key8"), nil)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(pairs) != 2 || string(pairs[1].Key) != "key9" {
+		t.Errorf("Expected key8 and key9, got %v", pairs)
+	}
+
+	pairs, err = c.Scan([]byte("zzz"), nil)
+	if err != nil || len(pairs) != 0 {
+		t.Errorf("Expected an empty scan, got %v (%v)", pairs, err)
+	}
+
+	// The connection is still in step for ordinary requests
+	if value, err := c.Get([]byte("key5")); err != nil || string(value) != "value5" {
+		t.Errorf("Expected value5, got %s (%v)", value, err)
+	}
+}
+
+func TestServer_RefusesOversizedRequests(t *testing.T) {
+	addr := freeAddr(t)
+	store := newTestStore(t)
+	startServer(t, addr, store)
+
+	c := NewClient(addr)
+	defer c.Close()
+
+	limits := store.Limits()
+	err := c.Put([]byte("key"), make([]byte, limits.MaxValueSize+1))
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("value is %d bytes, more than the %d-byte limit", limits.MaxValueSize+1, limits.MaxValueSize)) {
+		t.Errorf("Expected a clear error for an oversized value, got %v", err)
+	}
+	_, err = c.Get(make([]byte, limits.MaxKeySize+1))
+	if err == nil || !strings.Contains(err.Error(), "key is") {
+		t.Errorf("Expected a clear error for an oversized key, got %v", err)
+	}
+
+	// The connection is still usable, and nothing was stored
+	if err := c.Put([]byte("key"), []byte("value")); err != nil {
+		t.Errorf("Put after a refused request failed: %v", err)
+	}
+	if store.Size() != 1 {
+		t.Errorf("Expected 1 key, got %d", store.Size())
+	}
+}
@@ -1,16 +1,31 @@
 package network
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
 )
 
+// ProtocolVersion is the first byte of every frame this package writes.
+// Versioned frames end with a CRC32 of the bytes before it. The version
+// can't be mistaken for the operation or status byte that starts an
+// unversioned frame, so older peers' frames are still read.
+const ProtocolVersion = byte(0x81)
+
+// ErrChecksumMismatch is returned when a frame's contents don't match its
+// checksum, because it was truncated or corrupted in transit
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
 // Operation types
 const (
 	OpPut    = byte(1)
 	OpGet    = byte(2)
 	OpDelete = byte(3)
+	OpScan   = byte(4) // Key is the start of the range, Value its end
 )
 
 // Response codes
@@ -18,6 +33,7 @@ const (
 	StatusOK       = byte(0)
 	StatusError    = byte(1)
 	StatusNotFound = byte(2)
+	StatusDone     = byte(3) // Ends a scan's stream of pairs
 )
 
 // Message represents a request/response message
@@ -25,6 +41,10 @@ type Message struct {
 	Op    byte   // Operation type
 	Key   []byte // Key
 	Value []byte // Value (for Put operations and Get responses)
+	
+	// Legacy marks an unversioned frame without a checksum, as sent by
+	// older clients. WriteMessage writes such messages unversioned too.
+	Legacy bool
 }
 
 // Response represents a server response
@@ -32,138 +52,314 @@ type Response struct {
 	Status byte   // Status code
 	Value  []byte // Value (for Get responses)
 	Error  string // Error message (if any)
+	
+	// Legacy marks an unversioned frame without a checksum, for replying
+	// to older clients
+	Legacy bool
+}
+
+// ScanResponse is one frame of the reply to a scan: a key-value pair, or
+// the terminator that ends the stream
+type ScanResponse struct {
+	Key   []byte
+	Value []byte
+	Done  bool   // Terminator: no more pairs follow
+	Error string // Why the scan stopped early, set only on the terminator
 }
 
 // WriteMessage writes a message to the writer
 func WriteMessage(w io.Writer, msg *Message) error {
-	// Format: [Op(1)] [KeyLen(4)] [Key] [ValueLen(4)] [Value]
+	// Format: [Version(1)] [Op(1)] [KeyLen(4)] [Key] [ValueLen(4)] [Value] [CRC32(4)]
+	// Legacy format: [Op(1)] [KeyLen(4)] [Key] [ValueLen(4)] [Value]
+	var buf bytes.Buffer
+	if !msg.Legacy {
+		buf.WriteByte(ProtocolVersion)
+	}
 	
 	// Write operation
-	if err := binary.Write(w, binary.BigEndian, msg.Op); err != nil {
-		return err
-	}
+	buf.WriteByte(msg.Op)
 	
 	// Write key length and key
-	if err := binary.Write(w, binary.BigEndian, uint32(len(msg.Key))); err != nil {
-		return err
-	}
-	if _, err := w.Write(msg.Key); err != nil {
-		return err
-	}
+	binary.Write(&buf, binary.BigEndian, uint32(len(msg.Key)))
+	buf.Write(msg.Key)
 	
 	// Write value length and value
-	if err := binary.Write(w, binary.BigEndian, uint32(len(msg.Value))); err != nil {
-		return err
-	}
-	if _, err := w.Write(msg.Value); err != nil {
-		return err
-	}
+	binary.Write(&buf, binary.BigEndian, uint32(len(msg.Value)))
+	buf.Write(msg.Value)
 	
-	return nil
+	return writeFrame(w, &buf, msg.Legacy)
 }
 
-// ReadMessage reads a message from the reader
+// ReadMessage reads a message from the reader. It returns
+// ErrChecksumMismatch if a versioned frame fails its checksum.
 func ReadMessage(r io.Reader) (*Message, error) {
 	msg := &Message{}
 	
-	// Read operation
-	if err := binary.Read(r, binary.BigEndian, &msg.Op); err != nil {
+	// Read the version, or the operation of a legacy frame
+	fr, first, err := openFrame(r)
+	if err != nil {
 		return nil, err
 	}
+	msg.Legacy = fr.legacy
+	
+	// Read operation
+	msg.Op = first
+	if !fr.legacy {
+		if err := binary.Read(fr, binary.BigEndian, &msg.Op); err != nil {
+			return nil, err
+		}
+	}
 	
 	// Read key length and key
 	var keyLen uint32
-	if err := binary.Read(r, binary.BigEndian, &keyLen); err != nil {
+	if err := binary.Read(fr, binary.BigEndian, &keyLen); err != nil {
 		return nil, err
 	}
 	if keyLen > 1024*1024 { // 1MB max key size
 		return nil, errors.New("key too large")
 	}
 	msg.Key = make([]byte, keyLen)
-	if _, err := io.ReadFull(r, msg.Key); err != nil {
+	if _, err := io.ReadFull(fr, msg.Key); err != nil {
 		return nil, err
 	}
 	
 	// Read value length and value
 	var valueLen uint32
-	if err := binary.Read(r, binary.BigEndian, &valueLen); err != nil {
+	if err := binary.Read(fr, binary.BigEndian, &valueLen); err != nil {
 		return nil, err
 	}
 	if valueLen > 10*1024*1024 { // 10MB max value size
 		return nil, errors.New("value too large")
 	}
 	msg.Value = make([]byte, valueLen)
-	if _, err := io.ReadFull(r, msg.Value); err != nil {
+	if _, err := io.ReadFull(fr, msg.Value); err != nil {
 		return nil, err
 	}
 	
+	if err := fr.verify(); err != nil {
+		return nil, err
+	}
 	return msg, nil
 }
 
 // WriteResponse writes a response to the writer
 func WriteResponse(w io.Writer, resp *Response) error {
-	// Format: [Status(1)] [ValueLen(4)] [Value] [ErrorLen(4)] [Error]
+	// Format: [Version(1)] [Status(1)] [ValueLen(4)] [Value] [ErrorLen(4)] [Error] [CRC32(4)]
+	// Legacy format: [Status(1)] [ValueLen(4)] [Value] [ErrorLen(4)] [Error]
+	var buf bytes.Buffer
+	if !resp.Legacy {
+		buf.WriteByte(ProtocolVersion)
+	}
 	
 	// Write status
-	if err := binary.Write(w, binary.BigEndian, resp.Status); err != nil {
-		return err
-	}
+	buf.WriteByte(resp.Status)
 	
 	// Write value length and value
-	if err := binary.Write(w, binary.BigEndian, uint32(len(resp.Value))); err != nil {
-		return err
-	}
-	if _, err := w.Write(resp.Value); err != nil {
-		return err
-	}
+	binary.Write(&buf, binary.BigEndian, uint32(len(resp.Value)))
+	buf.Write(resp.Value)
 	
 	// Write error length and error
-	errorBytes := []byte(resp.Error)
-	if err := binary.Write(w, binary.BigEndian, uint32(len(errorBytes))); err != nil {
-		return err
-	}
-	if _, err := w.Write(errorBytes); err != nil {
-		return err
-	}
+	binary.Write(&buf, binary.BigEndian, uint32(len(resp.Error)))
+	buf.WriteString(resp.Error)
 	
-	return nil
+	return writeFrame(w, &buf, resp.Legacy)
 }
 
-// ReadResponse reads a response from the reader
+// ReadResponse reads a response from the reader. It returns
+// ErrChecksumMismatch if a versioned frame fails its checksum.
 func ReadResponse(r io.Reader) (*Response, error) {
 	resp := &Response{}
 	
-	// Read status
-	if err := binary.Read(r, binary.BigEndian, &resp.Status); err != nil {
+	// Read the version, or the status of a legacy frame
+	fr, first, err := openFrame(r)
+	if err != nil {
 		return nil, err
 	}
+	resp.Legacy = fr.legacy
+	
+	// Read status
+	resp.Status = first
+	if !fr.legacy {
+		if err := binary.Read(fr, binary.BigEndian, &resp.Status); err != nil {
+			return nil, err
+		}
+	}
 	
 	// Read value length and value
 	var valueLen uint32
-	if err := binary.Read(r, binary.BigEndian, &valueLen); err != nil {
+	if err := binary.Read(fr, binary.BigEndian, &valueLen); err != nil {
 		return nil, err
 	}
 	if valueLen > 10*1024*1024 { // 10MB max value size
 		return nil, errors.New("value too large")
 	}
 	resp.Value = make([]byte, valueLen)
-	if _, err := io.ReadFull(r, resp.Value); err != nil {
+	if _, err := io.ReadFull(fr, resp.Value); err != nil {
 		return nil, err
 	}
 	
 	// Read error length and error
 	var errorLen uint32
-	if err := binary.Read(r, binary.BigEndian, &errorLen); err != nil {
+	if err := binary.Read(fr, binary.BigEndian, &errorLen); err != nil {
 		return nil, err
 	}
 	if errorLen > 1024 { // 1KB max error message
 		return nil, errors.New("error message too large")
 	}
 	errorBytes := make([]byte, errorLen)
-	if _, err := io.ReadFull(r, errorBytes); err != nil {
+	if _, err := io.ReadFull(fr, errorBytes); err != nil {
+		return nil, err
+	}
+	resp.Error = string(errorBytes)
+	
+	if err := fr.verify(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// WriteScanRequest writes a request for the pairs with keys from start,
+// inclusive, up to end, exclusive. An empty end means the range has no
+// end.
+func WriteScanRequest(w io.Writer, start, end []byte) error {
+	return WriteMessage(w, &Message{Op: OpScan, Key: start, Value: end})
+}
+
+// WriteScanResponse writes one frame of a scan's reply to the writer
+func WriteScanResponse(w io.Writer, resp *ScanResponse) error {
+	// Format: [Version(1)] [Status(1)] [KeyLen(4)] [Key] [ValueLen(4)] [Value] [ErrorLen(4)] [Error] [CRC32(4)]
+	status := StatusOK
+	if resp.Done {
+		status = StatusDone
+		if resp.Error != "" {
+			status = StatusError
+		}
+	}
+	
+	var buf bytes.Buffer
+	buf.WriteByte(ProtocolVersion)
+	buf.WriteByte(status)
+	binary.Write(&buf, binary.BigEndian, uint32(len(resp.Key)))
+	buf.Write(resp.Key)
+	binary.Write(&buf, binary.BigEndian, uint32(len(resp.Value)))
+	buf.Write(resp.Value)
+	binary.Write(&buf, binary.BigEndian, uint32(len(resp.Error)))
+	buf.WriteString(resp.Error)
+	
+	return writeFrame(w, &buf, false)
+}
+
+// ReadScanResponse reads one frame of a scan's reply from the reader. It
+// returns ErrChecksumMismatch if the frame fails its checksum.
+func ReadScanResponse(r io.Reader) (*ScanResponse, error) {
+	fr, _, err := openFrame(r)
+	if err != nil {
+		return nil, err
+	}
+	if fr.legacy {
+		// Scans were added after frames were versioned
+		return nil, errors.New("unversioned scan response")
+	}
+	
+	var status byte
+	if err := binary.Read(fr, binary.BigEndian, &status); err != nil {
+		return nil, err
+	}
+	
+	resp := &ScanResponse{Done: status != StatusOK}
+	if resp.Key, err = readField(fr, 1024*1024, "key"); err != nil { // 1MB max key size
+		return nil, err
+	}
+	if resp.Value, err = readField(fr, 10*1024*1024, "value"); err != nil { // 10MB max value size
+		return nil, err
+	}
+	errorBytes, err := readField(fr, 1024, "error message") // 1KB max error message
+	if err != nil {
 		return nil, err
 	}
 	resp.Error = string(errorBytes)
+	if status == StatusError && resp.Error == "" {
+		resp.Error = "scan failed"
+	}
 	
+	if err := fr.verify(); err != nil {
+		return nil, err
+	}
 	return resp, nil
-} 
\ No newline at end of file
+}
+
+// readField reads a length-prefixed field of at most limit bytes, naming
+// it what in the error if it is longer
+func readField(r io.Reader, limit uint32, what string) ([]byte, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	if n > limit {
+		return nil, fmt.Errorf("%s too large", what)
+	}
+	field := make([]byte, n)
+	if _, err := io.ReadFull(r, field); err != nil {
+		return nil, err
+	}
+	return field, nil
+}
+
+// writeFrame writes a serialized frame in a single write, appending its
+// checksum unless it is a legacy frame
+func writeFrame(w io.Writer, buf *bytes.Buffer, legacy bool) error {
+	if !legacy {
+		binary.Write(buf, binary.BigEndian, crc32.ChecksumIEEE(buf.Bytes()))
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// frameReader reads the body of a frame, hashing versioned frames as they
+// are read so verify can check their checksum
+type frameReader struct {
+	src    io.Reader // The underlying reader
+	body   io.Reader // Reads from src, feeding hash for versioned frames
+	hash   hash.Hash32
+	legacy bool
+}
+
+// openFrame reads the first byte of a frame. For a versioned frame it
+// returns a reader over the rest of the frame; for a legacy frame, which
+// has no version byte, the byte it read is the frame's operation or
+// status, and is returned for the caller to use.
+func openFrame(r io.Reader) (*frameReader, byte, error) {
+	var first byte
+	if err := binary.Read(r, binary.BigEndian, &first); err != nil {
+		return nil, 0, err
+	}
+	if first != ProtocolVersion {
+		return &frameReader{src: r, body: r, legacy: true}, first, nil
+	}
+	
+	h := crc32.NewIEEE()
+	h.Write([]byte{first})
+	return &frameReader{src: r, body: io.TeeReader(r, h), hash: h}, first, nil
+}
+
+// Read reads from the frame's body
+func (f *frameReader) Read(p []byte) (int, error) {
+	return f.body.Read(p)
+}
+
+// verify reads a versioned frame's checksum, which follows its body, and
+// checks it against the body read so far. Legacy frames always pass.
+func (f *frameReader) verify() error {
+	if f.legacy {
+		return nil
+	}
+	
+	var sum uint32
+	if err := binary.Read(f.src, binary.BigEndian, &sum); err != nil {
+		return err
+	}
+	if sum != f.hash.Sum32() {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestProtocol_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, &Message{Op: OpPut, Key: []byte("key"), Value: []byte("value")}); err != nil {
+		t.Fatalf("WriteMessage failed: %v", err)
+	}
+	msg, err := ReadMessage(&buf)
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+	if msg.Op != OpPut || string(msg.Key) != "key" || string(msg.Value) != "value" || msg.Legacy {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+
+	if err := WriteResponse(&buf, &Response{Status: StatusError, Value: []byte("v"), Error: "failed"}); err != nil {
+		t.Fatalf("WriteResponse failed: %v", err)
+	}
+	resp, err := ReadResponse(&buf)
+	if err != nil {
+		t.Fatalf("ReadResponse failed: %v", err)
+	}
+	if resp.Status != StatusError || string(resp.Value) != "v" || resp.Error != "failed" || resp.Legacy {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+}
+
+func TestProtocol_CorruptFrameFailsChecksum(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, &Message{Op: OpPut, Key: []byte("key"), Value: []byte("value")}); err != nil {
+		t.Fatalf("WriteMessage failed: %v", err)
+	}
+	frame := buf.Bytes()
+	frame[len(frame)-6] ^= 0x01 // A byte of the value
+
+	if _, err := ReadMessage(bytes.NewReader(frame)); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+	}
+
+	buf.Reset()
+	if err := WriteResponse(&buf, &Response{Status: StatusOK, Value: []byte("value")}); err != nil {
+		t.Fatalf("WriteResponse failed: %v", err)
+	}
+	frame = buf.Bytes()
+	frame[1] = StatusNotFound
+
+	if _, err := ReadResponse(bytes.NewReader(frame)); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+	}
+}
+
+func TestProtocol_ReadsLegacyFrames(t *testing.T) {
+	// An unversioned Get for "key", as older clients send it
+	legacy := []byte{OpGet, 0, 0, 0, 3, 'k', 'e', 'y', 0, 0, 0, 0}
+	msg, err := ReadMessage(bytes.NewReader(legacy))
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+	if msg.Op != OpGet || string(msg.Key) != "key" || !msg.Legacy {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+
+	// Replies to legacy requests are written the same way
+	var buf bytes.Buffer
+	if err := WriteResponse(&buf, &Response{Status: StatusNotFound, Legacy: true}); err != nil {
+		t.Fatalf("WriteResponse failed: %v", err)
+	}
+	want := []byte{StatusNotFound, 0, 0, 0, 0, 0, 0, 0, 0}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Expected legacy frame %v, got %v", want, buf.Bytes())
+	}
+}
@@ -1,9 +1,12 @@
 package network
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"sync"
 	
 	"godatabase/internal/storage"
 )
@@ -13,6 +16,11 @@ type Server struct {
 	addr    string
 	storage storage.Storage
 	ln      net.Listener
+	
+	// Open client connections, closed by Stop
+	conns   map[net.Conn]struct{}
+	stopped bool
+	mu      sync.Mutex
 }
 
 // NewServer creates a new TCP server
@@ -20,32 +28,61 @@ func NewServer(addr string, storage storage.Storage) *Server {
 	return &Server{
 		addr:    addr,
 		storage: storage,
+		conns:   make(map[net.Conn]struct{}),
 	}
 }
 
-// Start starts the TCP server
+// Start starts the TCP server. It serves until Stop is called, then
+// returns nil.
 func (s *Server) Start() error {
 	ln, err := net.Listen("tcp", s.addr)
 	if err != nil {
 		return fmt.Errorf("failed to listen: %w", err)
 	}
+	
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return ln.Close()
+	}
 	s.ln = ln
+	s.mu.Unlock()
 	
 	log.Printf("Server listening on %s", s.addr)
 	
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
 			log.Printf("Failed to accept connection: %v", err)
 			continue
 		}
 		
+		s.mu.Lock()
+		if s.stopped {
+			s.mu.Unlock()
+			conn.Close()
+			continue
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		
 		go s.handleConnection(conn)
 	}
 }
 
-// Stop stops the server
+// Stop stops the server, closing its listener and every client
+// connection
 func (s *Server) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	s.stopped = true
+	for conn := range s.conns {
+		conn.Close()
+	}
 	if s.ln != nil {
 		return s.ln.Close()
 	}
@@ -54,7 +91,12 @@ func (s *Server) Stop() error {
 
 // handleConnection handles a client connection
 func (s *Server) handleConnection(conn net.Conn) {
-	defer conn.Close()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
 	
 	log.Printf("New connection from %s", conn.RemoteAddr())
 	
@@ -68,10 +110,20 @@ func (s *Server) handleConnection(conn net.Conn) {
 			break
 		}
 		
+		// Scans stream their reply rather than sending one response
+		if msg.Op == OpScan {
+			if err := s.handleScan(conn, msg.Key, msg.Value); err != nil {
+				log.Printf("Failed to write scan: %v", err)
+				break
+			}
+			continue
+		}
+		
 		// Process request
 		resp := s.processRequest(msg)
 		
-		// Send response
+		// Send response, unversioned if the client sent its request that way
+		resp.Legacy = msg.Legacy
 		if err := WriteResponse(conn, resp); err != nil {
 			log.Printf("Failed to write response: %v", err)
 			break
@@ -83,6 +135,10 @@ func (s *Server) handleConnection(conn net.Conn) {
 
 // processRequest processes a client request
 func (s *Server) processRequest(msg *Message) *Response {
+	if resp := s.checkLimits(msg); resp != nil {
+		return resp
+	}
+	
 	switch msg.Op {
 	case OpPut:
 		return s.handlePut(msg.Key, msg.Value)
@@ -98,6 +154,23 @@ func (s *Server) processRequest(msg *Message) *Response {
 	}
 }
 
+// checkLimits refuses a request whose key or value is larger than the
+// storage accepts, before it reaches the storage. ReadMessage's limits
+// are far looser than those of some backends, such as the B+Tree's.
+func (s *Server) checkLimits(msg *Message) *Response {
+	var value []byte
+	if msg.Op == OpPut {
+		value = msg.Value
+	}
+	if err := s.storage.Limits().Check(msg.Key, value); err != nil {
+		return &Response{
+			Status: StatusError,
+			Error:  err.Error(),
+		}
+	}
+	return nil
+}
+
 // handlePut handles a PUT request
 func (s *Server) handlePut(key, value []byte) *Response {
 	if err := s.storage.Put(key, value); err != nil {
@@ -116,7 +189,7 @@ func (s *Server) handlePut(key, value []byte) *Response {
 func (s *Server) handleGet(key []byte) *Response {
 	value, err := s.storage.Get(key)
 	if err != nil {
-		if err.Error() == "key not found" {
+		if errors.Is(err, storage.ErrKeyNotFound) {
 			return &Response{
 				Status: StatusNotFound,
 				Error:  err.Error(),
@@ -137,6 +210,12 @@ func (s *Server) handleGet(key []byte) *Response {
 // handleDelete handles a DELETE request
 func (s *Server) handleDelete(key []byte) *Response {
 	if err := s.storage.Delete(key); err != nil {
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			return &Response{
+				Status: StatusNotFound,
+				Error:  err.Error(),
+			}
+		}
 		return &Response{
 			Status: StatusError,
 			Error:  err.Error(),
@@ -146,4 +225,39 @@ func (s *Server) handleDelete(key []byte) *Response {
 	return &Response{
 		Status: StatusOK,
 	}
-} 
\ No newline at end of file
+}
+
+// handleScan handles a SCAN request, streaming every pair in the range
+// and then a terminator. An empty end means the range has no end. Errors
+// reading the storage are reported in the terminator; the returned error
+// is from writing to the connection.
+func (s *Server) handleScan(conn net.Conn, start, end []byte) error {
+	if len(end) == 0 {
+		end = nil
+	}
+	
+	w := bufio.NewWriter(conn)
+	it, err := s.storage.Scan(start, end)
+	if err != nil {
+		if err := WriteScanResponse(w, &ScanResponse{Done: true, Error: err.Error()}); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+	
+	for it.Next() {
+		if err := WriteScanResponse(w, &ScanResponse{Key: it.Key(), Value: it.Value()}); err != nil {
+			it.Close()
+			return err
+		}
+	}
+	
+	done := &ScanResponse{Done: true}
+	if err := it.Close(); err != nil {
+		done.Error = err.Error()
+	}
+	if err := WriteScanResponse(w, done); err != nil {
+		return err
+	}
+	return w.Flush()
+}
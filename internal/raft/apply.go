@@ -0,0 +1,327 @@
+package raft
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+// applyRetryInterval is how often a node whose storage failed to apply an
+// entry tries again
+const applyRetryInterval = 100 * time.Millisecond
+
+// ErrUnhealthy is returned by reads on a node whose state machine failed
+// to apply a committed entry. Clients should retry the read on another
+// node.
+var ErrUnhealthy = errors.New("node unhealthy")
+
+// Op bytes of single-key log commands. They sit below every printable
+// character, so they can't be mistaken for the text commands ("BAT ",
+// "SES ", "NOP", and the "PUT "/"DEL " of older logs).
+const (
+	cmdPut    byte = 0x01
+	cmdDelete byte = 0x02
+)
+
+// encodePutCommand encodes a put as a log command: the op byte followed by
+// the length-prefixed key and value, so any bytes round-trip
+func encodePutCommand(key, value []byte) []byte {
+	command := []byte{cmdPut}
+	command = binary.AppendUvarint(command, uint64(len(key)))
+	command = append(command, key...)
+	command = binary.AppendUvarint(command, uint64(len(value)))
+	return append(command, value...)
+}
+
+// encodeDeleteCommand encodes a delete as a log command: the op byte
+// followed by the length-prefixed key
+func encodeDeleteCommand(key []byte) []byte {
+	command := []byte{cmdDelete}
+	command = binary.AppendUvarint(command, uint64(len(key)))
+	return append(command, key...)
+}
+
+// readUvarintField splits a uvarint length-prefixed field from the front
+// of data. ok is false if data is too short to hold it.
+func readUvarintField(data []byte) (field, rest []byte, ok bool) {
+	n, size := binary.Uvarint(data)
+	if size <= 0 || n > uint64(len(data)-size) {
+		return nil, nil, false
+	}
+	return data[size : size+int(n)], data[size+int(n):], true
+}
+
+// decodeCommand parses a PUT or DEL log command, unwrapping it from its
+// client session if it has one. ok is false for commands that don't target
+// a single key.
+func decodeCommand(command []byte) (op string, key, value []byte, ok bool) {
+	if _, _, _, inner, ok := decodeSession(command); ok {
+		command = inner
+	}
+	if len(command) == 0 {
+		return "", nil, nil, false
+	}
+
+	switch command[0] {
+	case cmdPut:
+		key, rest, ok := readUvarintField(command[1:])
+		if !ok {
+			return "", nil, nil, false
+		}
+		value, rest, ok := readUvarintField(rest)
+		if !ok || len(rest) > 0 {
+			return "", nil, nil, false
+		}
+		return "PUT", key, value, true
+	case cmdDelete:
+		key, rest, ok := readUvarintField(command[1:])
+		if !ok || len(rest) > 0 {
+			return "", nil, nil, false
+		}
+		return "DEL", key, nil, true
+	}
+	return decodeTextCommand(command)
+}
+
+// decodeTextCommand parses the "PUT key value" and "DEL key" commands of
+// logs written before commands were length-prefixed. Their keys can't
+// contain spaces.
+func decodeTextCommand(command []byte) (op string, key, value []byte, ok bool) {
+	if len(command) < 4 {
+		return "", nil, nil, false
+	}
+
+	switch string(command[:4]) {
+	case "PUT ":
+		// The key ends at the first space; the value may contain spaces
+		keyValue := command[4:]
+		i := bytes.IndexByte(keyValue, ' ')
+		if i <= 0 {
+			return "", nil, nil, false
+		}
+		return "PUT", keyValue[:i], keyValue[i+1:], true
+	case "DEL ":
+		return "DEL", command[4:], nil, true
+	}
+	return "", nil, nil, false
+}
+
+// applyCommand applies a single log entry to the state machine
+func (n *RaftNode) applyCommand(entry LogEntry) error {
+	if n.applySkip[entry.Index] {
+		// A retry of a write its session already applied
+		return nil
+	}
+
+	op, key, value, ok := decodeCommand(entry.Command)
+	if !ok {
+		if ops, ok := decodeBatchCommand(entry.Command); ok {
+			return n.applyBatch(ops)
+		}
+		return nil
+	}
+
+	switch op {
+	case "PUT":
+		return n.storage.Put(key, value)
+	case "DEL":
+		err := n.storage.Delete(key)
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			// Deleting a missing key leaves the state machine as the
+			// command intended
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// applyObserver is registered through ObserveApplied
+type applyObserver struct {
+	applied func(index int, ops []storage.BatchOp)
+	reset   func(index int)
+}
+
+// ObserveApplied registers applied to be called, in log order, with the
+// index and the writes of every entry the node applies from now on. The
+// writes of a batch entry are passed together; entries that write nothing,
+// such as noops and retries their session already applied, are skipped.
+// reset is called instead when a snapshot from the leader replaces the
+// state machine, since the writes it covers can't be passed on.
+//
+// Both run with the node's lock held, so they must be quick and must not
+// call back into the node. ObserveApplied returns the index of the last
+// entry applied before registering.
+func (n *RaftNode) ObserveApplied(applied func(index int, ops []storage.BatchOp), reset func(index int)) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.observers = append(n.observers, applyObserver{applied: applied, reset: reset})
+	return n.lastApplied
+}
+
+// notifyApplied passes the writes of newly applied entries to the
+// observers. The caller must hold n.mu and must not yet have cleared
+// applySkip.
+func (n *RaftNode) notifyApplied(entries []LogEntry) {
+	if len(n.observers) == 0 {
+		return
+	}
+	for _, entry := range entries {
+		if n.applySkip[entry.Index] {
+			continue
+		}
+		ops := commandWrites(entry.Command)
+		if len(ops) == 0 {
+			continue
+		}
+		for _, o := range n.observers {
+			o.applied(entry.Index, ops)
+		}
+	}
+}
+
+// commandWrites returns the writes a log command makes, or nil if it makes
+// none
+func commandWrites(command []byte) []storage.BatchOp {
+	op, key, value, ok := decodeCommand(command)
+	if !ok {
+		ops, _ := decodeBatchCommand(command)
+		return ops
+	}
+	if op == "DEL" {
+		return []storage.BatchOp{{Key: key, Delete: true}}
+	}
+	return []storage.BatchOp{{Key: key, Value: value}}
+}
+
+// getApplied reads key from the state machine along with the index of the
+// last entry applied to it. The node's lock keeps entries from being
+// applied during the read.
+func (n *RaftNode) getApplied(key []byte) ([]byte, int, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if n.applyErr != nil {
+		return nil, 0, fmt.Errorf("%w: node %s: %v", ErrUnhealthy, n.id, n.applyErr)
+	}
+	value, err := n.storage.Get(key)
+	return value, n.lastApplied, err
+}
+
+// scanApplied opens a point-in-time scan of the state machine along with
+// the index of the last entry applied to it. The node's lock is only held
+// while the scan is opened, not while it is read.
+func (n *RaftNode) scanApplied(start, end []byte) (storage.Iterator, int, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if n.applyErr != nil {
+		return nil, 0, fmt.Errorf("%w: node %s: %v", ErrUnhealthy, n.id, n.applyErr)
+	}
+	it, err := n.storage.ScanWithConsistency(start, end, storage.ScanConsistentPrefix)
+	return it, n.lastApplied, err
+}
+
+// SetApplyWorkers sets how many goroutines apply committed entries.
+// Entries for the same key always go to the same worker, so per-key order
+// is preserved; commands that don't target a single key are applied
+// alone, after everything before them. One worker applies serially.
+func (n *RaftNode) SetApplyWorkers(workers int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if workers < 1 {
+		workers = 1
+	}
+	n.applyWorkers = workers
+}
+
+// applyEntries applies entries in log order, spreading them over the apply
+// workers when more than one is configured. It stops at the first entry
+// that fails and returns how many entries before it were applied, along
+// with the error. The caller must hold n.mu.
+//
+// With several workers, entries after the failed one may already have been
+// applied on other workers. Applying them again on a retry is harmless:
+// each worker keeps its keys' entries in order, and re-running a key's
+// PUTs and DELs in order leaves it with the same final value.
+func (n *RaftNode) applyEntries(entries []LogEntry) (int, error) {
+	if n.applyWorkers <= 1 {
+		for i, entry := range entries {
+			if err := n.applyCommand(entry); err != nil {
+				return i, err
+			}
+		}
+		return len(entries), nil
+	}
+
+	start := 0
+	for i, entry := range entries {
+		if _, _, _, ok := decodeCommand(entry.Command); ok {
+			continue
+		}
+		// Globally ordered: finish everything before it, then apply it alone
+		if applied, err := n.applyParallel(entries[start:i]); err != nil {
+			return start + applied, err
+		}
+		if err := n.applyCommand(entry); err != nil {
+			return i, err
+		}
+		start = i + 1
+	}
+	applied, err := n.applyParallel(entries[start:])
+	return start + applied, err
+}
+
+// applyParallel applies keyed entries on the apply workers, hashing each
+// key to a worker so entries for one key are applied in order. Like
+// applyEntries, it returns the number of entries before the earliest one
+// that failed.
+func (n *RaftNode) applyParallel(entries []LogEntry) (int, error) {
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	// Buckets hold positions in entries, so failures can be ordered
+	buckets := make([][]int, n.applyWorkers)
+	for i, entry := range entries {
+		_, key, _, _ := decodeCommand(entry.Command)
+		h := fnv.New32a()
+		h.Write(key)
+		w := int(h.Sum32() % uint32(n.applyWorkers))
+		buckets[w] = append(buckets[w], i)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := len(entries)
+	var firstErr error
+	for _, bucket := range buckets {
+		if len(bucket) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(bucket []int) {
+			defer wg.Done()
+			for _, i := range bucket {
+				if err := n.applyCommand(entries[i]); err != nil {
+					mu.Lock()
+					if i < failed {
+						failed, firstErr = i, err
+					}
+					mu.Unlock()
+					return
+				}
+			}
+		}(bucket)
+	}
+	wg.Wait()
+	return failed, firstErr
+}
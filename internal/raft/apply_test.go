@@ -0,0 +1,401 @@
+package raft
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+// slowStorage records the order of writes per key and delays each Put
+type slowStorage struct {
+	storage.Storage
+	delay  time.Duration
+	mu     sync.Mutex
+	values map[string][]string
+}
+
+func newSlowStorage(delay time.Duration) *slowStorage {
+	return &slowStorage{delay: delay, values: make(map[string][]string)}
+}
+
+func (s *slowStorage) Put(key, value []byte) error {
+	time.Sleep(s.delay)
+	s.mu.Lock()
+	s.values[string(key)] = append(s.values[string(key)], string(value))
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *slowStorage) Delete(key []byte) error {
+	return nil
+}
+
+func (s *slowStorage) Close() error {
+	return nil
+}
+
+// applied returns how many times each value was written to key
+func (s *slowStorage) applied(key string) map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, value := range s.values[key] {
+		counts[value]++
+	}
+	return counts
+}
+
+// newApplyNode returns an unstarted node whose log holds entries and
+// whose commit index covers all of them
+func newApplyNode(store storage.Storage, workers int, entries []LogEntry) *RaftNode {
+	n := NewRaftNode("apply", ":0", nil, store)
+	n.SetApplyWorkers(workers)
+	n.log = entries
+	n.commitIndex = len(entries)
+	return n
+}
+
+func putEntries(keys, perKey int) []LogEntry {
+	var entries []LogEntry
+	for i := 0; i < perKey; i++ {
+		for k := 0; k < keys; k++ {
+			cmd := encodePutCommand([]byte(fmt.Sprintf("key%d", k)), []byte(strconv.Itoa(i)))
+			entries = append(entries, LogEntry{Term: 1, Index: len(entries) + 1, Command: cmd})
+		}
+	}
+	return entries
+}
+
+func TestRaftNode_ParallelApplyPreservesKeyOrder(t *testing.T) {
+	store := newSlowStorage(0)
+	n := newApplyNode(store, 4, putEntries(16, 50))
+
+	n.mu.Lock()
+	n.applyCommittedEntries()
+	n.mu.Unlock()
+
+	if n.LastApplied() != 16*50 {
+		t.Errorf("Expected %d entries applied, got %d", 16*50, n.LastApplied())
+	}
+
+	for k := 0; k < 16; k++ {
+		values := store.values[fmt.Sprintf("key%d", k)]
+		if len(values) != 50 {
+			t.Fatalf("Expected 50 writes to key%d, got %d", k, len(values))
+		}
+		for i, v := range values {
+			if v != strconv.Itoa(i) {
+				t.Errorf("key%d: expected write %d to be %d, got %s", k, i, i, v)
+				break
+			}
+		}
+	}
+}
+
+func TestRaftNode_ObserveAppliedReportsWritesInLogOrder(t *testing.T) {
+	entries := putEntries(8, 10)
+	entries = append(entries,
+		LogEntry{Term: 1, Command: []byte("NOP")},
+		LogEntry{Term: 1, Command: encodeBatchCommand([]storage.BatchOp{
+			{Key: []byte("a"), Value: []byte("1")},
+			{Key: []byte("key0"), Delete: true},
+		})},
+		LogEntry{Term: 1, Command: encodeDeleteCommand([]byte("key1"))},
+	)
+	for i := range entries {
+		entries[i].Index = i + 1
+	}
+	n := newApplyNode(storage.NewMemStorage(), 4, entries)
+
+	type write struct {
+		index int
+		ops   []storage.BatchOp
+	}
+	var writes []write
+	if start := n.ObserveApplied(func(index int, ops []storage.BatchOp) {
+		writes = append(writes, write{index, ops})
+	}, nil); start != 0 {
+		t.Errorf("Expected observing to start at 0, got %d", start)
+	}
+
+	n.mu.Lock()
+	n.applyCommittedEntries()
+	n.mu.Unlock()
+
+	// Every entry but the noop, in log order, however the workers ran
+	if len(writes) != len(entries)-1 {
+		t.Fatalf("Expected %d writes, got %d", len(entries)-1, len(writes))
+	}
+	for i, w := range writes[:80] {
+		if w.index != i+1 || len(w.ops) != 1 || !bytes.Equal(w.ops[0].Value, []byte(strconv.Itoa(i/8))) {
+			t.Fatalf("Write %d: unexpected %+v", i, w)
+		}
+	}
+	batch := writes[80]
+	if batch.index != 82 || len(batch.ops) != 2 || !batch.ops[1].Delete {
+		t.Errorf("Expected the batch's two writes at index 82, got %+v", batch)
+	}
+	del := writes[81]
+	if del.index != 83 || !del.ops[0].Delete || string(del.ops[0].Key) != "key1" {
+		t.Errorf("Expected the delete of key1 at index 83, got %+v", del)
+	}
+}
+
+func TestDecodeCommand_ValueWithSpaces(t *testing.T) {
+	op, key, value, ok := decodeCommand([]byte("PUT key hello world"))
+	if !ok || op != "PUT" || string(key) != "key" || string(value) != "hello world" {
+		t.Errorf("Unexpected decode: %q %q %q %v", op, key, value, ok)
+	}
+}
+
+func TestDecodeCommand_BinaryKeysAndValues(t *testing.T) {
+	key := []byte("my key\x00 PUT ")
+	value := []byte("production db\x00\x00 DEL x")
+
+	command := encodeSessionCommand("client", 1, time.Now(), encodePutCommand(key, value))
+	op, k, v, ok := decodeCommand(command)
+	if !ok || op != "PUT" || !bytes.Equal(k, key) || !bytes.Equal(v, value) {
+		t.Errorf("Unexpected put decode: %q %q %q %v", op, k, v, ok)
+	}
+
+	op, k, v, ok = decodeCommand(encodeDeleteCommand(key))
+	if !ok || op != "DEL" || !bytes.Equal(k, key) || v != nil {
+		t.Errorf("Unexpected delete decode: %q %q %q %v", op, k, v, ok)
+	}
+
+	// Truncated commands must not decode to a shorter key or value
+	put := encodePutCommand(key, value)
+	for i := 1; i < len(put); i++ {
+		if _, _, _, ok := decodeCommand(put[:i]); ok {
+			t.Errorf("Expected a put truncated to %d bytes not to decode", i)
+		}
+	}
+}
+
+func benchmarkApply(b *testing.B, workers int) {
+	for i := 0; i < b.N; i++ {
+		n := newApplyNode(newSlowStorage(time.Millisecond), workers, putEntries(32, 2))
+		n.mu.Lock()
+		n.applyCommittedEntries()
+		n.mu.Unlock()
+	}
+}
+
+func BenchmarkApply_Serial(b *testing.B)   { benchmarkApply(b, 1) }
+func BenchmarkApply_Workers8(b *testing.B) { benchmarkApply(b, 8) }
+
+func TestRaftNode_CommitPathsApplyEachEntryOnce(t *testing.T) {
+	store := newSlowStorage(0)
+	n := newApplyNode(store, 1, putEntries(1, 6))
+	n.currentTerm = 1
+	rpc := &RaftRPC{node: n}
+
+	// Leader path commits the first two entries
+	n.commitIndex = 2
+	n.applyCommittedEntries()
+
+	// AppendEntries advances the commit index over them and two more
+	var resp AppendEntriesResponse
+	if err := rpc.AppendEntries(AppendEntriesRequest{Term: 1, LeaderID: "leader", LeaderCommit: 4}, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// The leader path runs again with a commit index that is now behind
+	n.commitIndex = 3
+	n.applyCommittedEntries()
+
+	// Both paths race to commit the rest
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		n.mu.Lock()
+		n.commitIndex = 6
+		n.applyCommittedEntries()
+		n.mu.Unlock()
+	}()
+	go func() {
+		defer wg.Done()
+		var resp AppendEntriesResponse
+		rpc.AppendEntries(AppendEntriesRequest{Term: 1, LeaderID: "leader", LeaderCommit: 6}, &resp)
+	}()
+	wg.Wait()
+
+	counts := store.applied("key0")
+	for i := 0; i < 6; i++ {
+		if counts[strconv.Itoa(i)] != 1 {
+			t.Errorf("Expected entry %d applied once, got %d", i+1, counts[strconv.Itoa(i)])
+		}
+	}
+}
+
+func TestRaftNode_ClusterAppliesEachCommandOnce(t *testing.T) {
+	cluster := startTestClusterWith(t, 3, func() storage.Storage {
+		return newSlowStorage(0)
+	})
+	leader := waitForLeader(t, cluster)
+
+	const writes = 5
+	for i := 0; i < writes; i++ {
+		if err := leader.Put([]byte("counter"), []byte(strconv.Itoa(i))); err != nil {
+			t.Fatalf("Put %d failed: %v", i, err)
+		}
+	}
+
+	// Followers learn the final commit index from a heartbeat
+	time.Sleep(300 * time.Millisecond)
+
+	for _, node := range cluster.GetAllNodes() {
+		counts := node.storage.(*slowStorage).applied("counter")
+		for i := 0; i < writes; i++ {
+			if counts[strconv.Itoa(i)] != 1 {
+				t.Errorf("%s: Expected write %d applied once, got %d", node.id, i, counts[strconv.Itoa(i)])
+			}
+		}
+	}
+}
+
+func TestRaftNode_FollowersApplyWithoutWaitingForHeartbeat(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	leader := waitForLeader(t, cluster)
+
+	// Make regular heartbeats rare, so a follower that applies promptly can
+	// only have learned the commit index from the commit notification
+	const heartbeat = 2 * time.Second
+	var followers []*RaftNode
+	for _, node := range cluster.GetAllNodes() {
+		if node == leader {
+			continue
+		}
+		node.mu.Lock()
+		node.electionTimeout = time.Hour
+		node.mu.Unlock()
+		followers = append(followers, node)
+	}
+	leader.mu.Lock()
+	leader.heartbeatInterval = heartbeat
+	leader.mu.Unlock()
+	// Let the heartbeat already scheduled at the old interval go out
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		index := leaderLogLen(leader) + 1
+		done := make(chan error, 1)
+		go func() {
+			done <- leader.Put([]byte(fmt.Sprintf("key%d", i)), []byte("value"))
+		}()
+
+		// Time from the leader committing the entry until every follower
+		// has applied it
+		var committed time.Time
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if committed.IsZero() && leader.CommitIndex() >= index {
+				committed = time.Now()
+			}
+			if !committed.IsZero() && followersApplied(followers, index) {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if committed.IsZero() || !followersApplied(followers, index) {
+			t.Fatalf("Write %d was not applied on every follower", i)
+		}
+		if lag := time.Since(committed); lag > heartbeat/10 {
+			t.Errorf("Write %d: Expected followers to apply well within the heartbeat interval, took %v", i, lag)
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("Put %d failed: %v", i, err)
+		}
+	}
+}
+
+func leaderLogLen(n *RaftNode) int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return len(n.log)
+}
+
+func followersApplied(followers []*RaftNode, index int) bool {
+	for _, f := range followers {
+		if f.LastApplied() < index {
+			return false
+		}
+	}
+	return true
+}
+
+// failingStorage fails every Put of failValue to failKey while broken
+type failingStorage struct {
+	*slowStorage
+	failKey, failValue string
+	broken             atomic.Bool
+}
+
+func (f *failingStorage) Put(key, value []byte) error {
+	if f.broken.Load() && string(key) == f.failKey && string(value) == f.failValue {
+		return errors.New("disk full")
+	}
+	return f.slowStorage.Put(key, value)
+}
+
+func TestRaftNode_ApplyFailureHaltsApply(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		store := &failingStorage{slowStorage: newSlowStorage(0), failKey: "key3", failValue: "5"}
+		store.broken.Store(true)
+		n := newApplyNode(store, workers, putEntries(8, 10))
+		defer n.Stop()
+
+		n.mu.Lock()
+		n.state = Leader
+		n.applyCommittedEntries()
+		n.mu.Unlock()
+
+		// PUT key3 5 is entry 44; nothing from it on counts as applied
+		if n.LastApplied() != 43 {
+			t.Errorf("workers=%d: Expected apply to stop at entry 43, got %d", workers, n.LastApplied())
+		}
+		if err := n.Health(); !errors.Is(err, ErrUnhealthy) {
+			t.Errorf("workers=%d: Expected ErrUnhealthy, got %v", workers, err)
+		}
+
+		// Retries keep failing while the storage is broken, and reads are refused
+		time.Sleep(3 * applyRetryInterval)
+		if n.LastApplied() != 43 {
+			t.Errorf("workers=%d: Expected apply to stay at entry 43, got %d", workers, n.LastApplied())
+		}
+		cluster := newGlobalCluster()
+		if err := cluster.RegisterNode(n); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewRaftStorage(cluster, n.GetID()).Get([]byte("key0")); !errors.Is(err, ErrUnhealthy) {
+			t.Errorf("workers=%d: Expected reads to fail with ErrUnhealthy, got %v", workers, err)
+		}
+
+		// Once the storage recovers, a retry applies the rest
+		store.broken.Store(false)
+		if err := n.WaitForApplied(80, 2*time.Second); err != nil {
+			t.Fatalf("workers=%d: %v", workers, err)
+		}
+		if err := n.Health(); err != nil {
+			t.Errorf("workers=%d: Expected node to be healthy again, got %v", workers, err)
+		}
+		for k := 0; k < 8; k++ {
+			store.mu.Lock()
+			values := store.values[fmt.Sprintf("key%d", k)]
+			last := values[len(values)-1]
+			store.mu.Unlock()
+			if last != "9" {
+				t.Errorf("workers=%d: Expected key%d to end at 9, got %s", workers, k, last)
+			}
+		}
+	}
+}
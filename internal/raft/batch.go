@@ -0,0 +1,87 @@
+package raft
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"godatabase/internal/storage"
+)
+
+// encodeBatchCommand encodes a write batch as a single log command, so the
+// whole batch commits and applies as one entry. Each operation is a type
+// byte, 'P' or 'D', followed by the length-prefixed key and, for puts, the
+// length-prefixed value.
+func encodeBatchCommand(ops []storage.BatchOp) []byte {
+	command := []byte("BAT ")
+	for _, op := range ops {
+		if op.Delete {
+			command = append(command, 'D')
+			command = binary.AppendUvarint(command, uint64(len(op.Key)))
+			command = append(command, op.Key...)
+			continue
+		}
+		command = append(command, 'P')
+		command = binary.AppendUvarint(command, uint64(len(op.Key)))
+		command = append(command, op.Key...)
+		command = binary.AppendUvarint(command, uint64(len(op.Value)))
+		command = append(command, op.Value...)
+	}
+	return command
+}
+
+// decodeBatchCommand parses a batch command, unwrapping it from its client
+// session if it has one. ok is false for other commands and for batches
+// that don't parse.
+func decodeBatchCommand(command []byte) (ops []storage.BatchOp, ok bool) {
+	if _, _, _, inner, ok := decodeSession(command); ok {
+		command = inner
+	}
+	if !bytes.HasPrefix(command, []byte("BAT ")) {
+		return nil, false
+	}
+
+	data := command[4:]
+	for len(data) > 0 {
+		typ := data[0]
+		key, rest, ok := readUvarintField(data[1:])
+		if !ok {
+			return nil, false
+		}
+		switch typ {
+		case 'D':
+			ops = append(ops, storage.BatchOp{Key: key, Delete: true})
+		case 'P':
+			var value []byte
+			value, rest, ok = readUvarintField(rest)
+			if !ok {
+				return nil, false
+			}
+			ops = append(ops, storage.BatchOp{Key: key, Value: value})
+		default:
+			return nil, false
+		}
+		data = rest
+	}
+	return ops, true
+}
+
+// applyBatch applies a batch entry through a batch of the node's storage,
+// with whatever atomicity that storage's batches provide
+func (n *RaftNode) applyBatch(ops []storage.BatchOp) error {
+	batch := n.storage.NewBatch()
+	for _, op := range ops {
+		if op.Delete {
+			batch.Delete(op.Key)
+		} else {
+			batch.Put(op.Key, op.Value)
+		}
+	}
+	return batch.Commit()
+}
+
+// WriteBatch submits ops as one log entry and returns once it commits.
+// Followers apply the whole batch together.
+func (n *RaftNode) WriteBatch(ops []storage.BatchOp) error {
+	_, err := n.SubmitRequest("batch", nil, encodeBatchCommand(ops))
+	return err
+}
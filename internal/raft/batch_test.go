@@ -0,0 +1,70 @@
+package raft
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+func TestBatchCommand_RoundTrip(t *testing.T) {
+	ops := []storage.BatchOp{
+		{Key: []byte("key with spaces"), Value: []byte("value\nwith\nlines")},
+		{Key: []byte("gone"), Delete: true},
+		{Key: []byte("empty"), Value: []byte{}},
+	}
+	command := encodeBatchCommand(ops)
+
+	got, ok := decodeBatchCommand(command)
+	if !ok {
+		t.Fatal("Expected the batch to decode")
+	}
+	if !reflect.DeepEqual(got, ops) {
+		t.Errorf("Expected %v, got %v", ops, got)
+	}
+
+	// Inside a session, and truncated
+	if got, ok := decodeBatchCommand(encodeSessionCommand("client", 1, time.Now(), command)); !ok || len(got) != 3 {
+		t.Errorf("Expected the session-wrapped batch to decode, got %v", got)
+	}
+	if _, ok := decodeBatchCommand(command[:len(command)-3]); ok {
+		t.Error("Expected a truncated batch to be rejected")
+	}
+	if _, _, _, ok := decodeCommand(command); ok {
+		t.Error("Expected a batch not to decode as a single-key command")
+	}
+}
+
+func TestRaftStorage_BatchAppliesOnEveryNode(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	leader := waitForLeader(t, cluster)
+	rs := NewRaftStorage(cluster, leader.GetID())
+
+	if err := rs.Put([]byte("old"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	batch := rs.NewBatch()
+	for i := 0; i < 20; i++ {
+		batch.Put([]byte(fmt.Sprintf("key%02d", i)), []byte("value"))
+	}
+	batch.Delete([]byte("old"))
+	before := leaderLogLen(leader)
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if entries := leaderLogLen(leader) - before; entries != 1 {
+		t.Errorf("Expected the batch in one log entry, got %d", entries)
+	}
+
+	index := leaderLogLen(leader)
+	for id, node := range cluster.GetAllNodes() {
+		if err := node.WaitForApplied(index, 2*time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if size := node.storage.Size(); size != 20 {
+			t.Errorf("%s: Expected 20 keys, got %d", id, size)
+		}
+	}
+}
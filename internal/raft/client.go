@@ -1,95 +1,191 @@
 package raft
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 )
 
-// handleClientRequest handles client requests
-func (n *RaftNode) handleClientRequest(req ClientRequest) {
+// ErrNoQuorum is returned for writes submitted to a leader that hasn't
+// heard from a majority of the cluster recently. Such writes could never
+// commit, so they are rejected instead of growing the log.
+var ErrNoQuorum = errors.New("leader has no quorum")
+
+// defaultMaxInFlight is how many client writes a leader appends and
+// replicates together by default
+const defaultMaxInFlight = 32
+
+// replicateTimeout is how long a leader waits for a majority to store a
+// round of client writes before failing them
+const replicateTimeout = time.Second
+
+// SetMaxInFlight sets how many client writes a leader may have appended
+// but not yet committed. Writes waiting when a replication round starts
+// are appended together and replicated in one round, up to this many.
+// A limit of 1 replicates each write on its own.
+func (n *RaftNode) SetMaxInFlight(max int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if max < 1 {
+		max = 1
+	}
+	n.maxInFlight = max
+}
+
+// collectClientRequests returns first along with any requests already
+// waiting behind it, up to the in-flight limit
+func (n *RaftNode) collectClientRequests(first ClientRequest) []ClientRequest {
+	n.mu.RLock()
+	max := n.maxInFlight
+	n.mu.RUnlock()
+
+	reqs := []ClientRequest{first}
+	for len(reqs) < max {
+		select {
+		case req := <-n.clientRequestChan:
+			reqs = append(reqs, req)
+		default:
+			return reqs
+		}
+	}
+	return reqs
+}
+
+// handleClientRequests appends a batch of client requests to the log in
+// the order they arrived, replicates them in one round, and answers each
+// once the batch commits. Entries commit and apply in log order, so the
+// batch is ordered exactly as if its requests had been handled one by one.
+func (n *RaftNode) handleClientRequests(reqs []ClientRequest) {
 	n.mu.RLock()
 	state := n.state
+	quorum := n.hasQuorum()
 	n.mu.RUnlock()
 
 	// Only the leader can handle client requests
 	if state != Leader {
-		req.Response <- ClientResponse{
-			Success: false,
-			Error:   fmt.Errorf("not the leader"),
+		for _, req := range reqs {
+			req.Response <- ClientResponse{
+				Success: false,
+				Error:   ErrNotLeader,
+			}
+		}
+		return
+	}
+
+	// A partitioned leader fails fast rather than appending entries that
+	// can't commit
+	if !quorum {
+		for _, req := range reqs {
+			req.Response <- ClientResponse{
+				Success: false,
+				Error:   ErrNoQuorum,
+			}
 		}
 		return
 	}
 
-	// Create log entry for the command
-	var command []byte
-	switch req.Operation {
-	case "put":
-		command = append([]byte("PUT "), req.Key...)
-		command = append(command, ' ')
-		command = append(command, req.Value...)
-	case "delete":
-		command = append([]byte("DEL "), req.Key...)
-	default:
-		req.Response <- ClientResponse{
-			Success: false,
-			Error:   fmt.Errorf("unknown operation: %s", req.Operation),
+	// Create a log command for each request
+	var accepted []ClientRequest
+	var commands [][]byte
+	for _, req := range reqs {
+		var command []byte
+		switch req.Operation {
+		case "put":
+			command = encodePutCommand(req.Key, req.Value)
+		case "delete":
+			command = encodeDeleteCommand(req.Key)
+		case "batch":
+			// The value already holds the encoded batch command
+			command = req.Value
+		case "noop":
+			// Changes nothing; committing it settles the commit index
+			// of a new leader
+			command = []byte("NOP")
+		default:
+			req.Response <- ClientResponse{
+				Success: false,
+				Error:   fmt.Errorf("unknown operation: %s", req.Operation),
+			}
+			continue
+		}
+		if req.ClientID != "" {
+			command = encodeSessionCommand(req.ClientID, req.Seq, time.Now(), command)
 		}
+		accepted = append(accepted, req)
+		commands = append(commands, command)
+	}
+	if len(accepted) == 0 {
 		return
 	}
 
-	// Add entry to log
+	// Add the entries to the log
 	n.mu.Lock()
-	entry := LogEntry{
-		Term:    n.currentTerm,
-		Index:   len(n.log) + 1,
-		Command: command,
+	entries := make([]LogEntry, len(commands))
+	for i, command := range commands {
+		entries[i] = LogEntry{
+			Term:    n.currentTerm,
+			Index:   n.lastLogIndex() + 1,
+			Command: command,
+		}
+		n.log = append(n.log, entries[i])
+	}
+	lastIndex := n.lastLogIndex()
+
+	// The leader's own copy counts toward the majority, so it must be
+	// durable before the entries are replicated
+	if err := n.persistState(); err != nil {
+		n.truncateLog(lastIndex - len(entries))
+		n.mu.Unlock()
+		for _, req := range accepted {
+			req.Response <- ClientResponse{
+				Success: false,
+				Error:   err,
+			}
+		}
+		return
 	}
-	n.log = append(n.log, entry)
-	logIndex := len(n.log)
 	n.mu.Unlock()
 
 	// Replicate to followers
-	success := n.replicateLogEntry(entry, logIndex)
-
-	if success {
-		// Apply the entry locally
-		n.applyEntry(entry)
+	success := n.replicateLogEntries(entries, lastIndex)
 
-		// Send response
-		if req.Operation == "get" {
-			value, err := n.storage.Get(req.Key)
+	for _, req := range accepted {
+		if success {
+			// The entry was applied when it committed; applying it here
+			// again would run it twice
 			req.Response <- ClientResponse{
 				Success: true,
-				Value:   value,
-				Error:   err,
 			}
 		} else {
 			req.Response <- ClientResponse{
-				Success: true,
+				Success: false,
+				Error:   fmt.Errorf("failed to replicate to majority"),
 			}
 		}
-	} else {
-		req.Response <- ClientResponse{
-			Success: false,
-			Error:   fmt.Errorf("failed to replicate to majority"),
-		}
 	}
 }
 
-// replicateLogEntry replicates a log entry to all followers
-func (n *RaftNode) replicateLogEntry(entry LogEntry, logIndex int) bool {
+// replicateLogEntries replicates consecutive log entries ending at
+// lastIndex to all followers in one AppendEntries each. It returns as soon
+// as a majority, counting the leader, has stored them and they have
+// committed, or false if that doesn't happen within replicateTimeout.
+func (n *RaftNode) replicateLogEntries(entries []LogEntry, lastIndex int) bool {
+	prevIndex := lastIndex - len(entries)
+
 	n.mu.RLock()
 	term := n.currentTerm
+	prevTerm := n.getPrevLogTerm(prevIndex)
+	commitIndex := n.commitIndex
 	peers := make(map[string]string)
 	for k, v := range n.peers {
 		peers[k] = v
 	}
 	n.mu.RUnlock()
 
-	successCount := 1 // Count self
-	totalPeers := len(peers) + 1
+	// Each peer reports whether it stored the entries
+	acks := make(chan bool, len(peers))
 
 	// Send append entries to all peers
 	for peerID, peerAddr := range peers {
@@ -97,38 +193,47 @@ func (n *RaftNode) replicateLogEntry(entry LogEntry, logIndex int) bool {
 			req := AppendEntriesRequest{
 				Term:         term,
 				LeaderID:     n.id,
-				PrevLogIndex: logIndex - 1,
-				PrevLogTerm:  n.getPrevLogTerm(logIndex - 1),
-				Entries:      []LogEntry{entry},
-				LeaderCommit: n.commitIndex,
+				PrevLogIndex: prevIndex,
+				PrevLogTerm:  prevTerm,
+				Entries:      entries,
+				LeaderCommit: commitIndex,
 			}
 
 			resp, err := n.sendAppendEntries(addr, req)
 			if err != nil {
 				log.Printf("Failed to replicate to %s: %v", id, err)
+				acks <- false
 				return
 			}
 
 			n.mu.Lock()
 			defer n.mu.Unlock()
 
+			n.peerContact[id] = time.Now()
+
 			if resp.Term > n.currentTerm {
-				n.currentTerm = resp.Term
-				n.state = Follower
-				n.votedFor = ""
+				n.becomeFollower(resp.Term)
+				n.persistOrLog()
+				acks <- false
 				return
 			}
 
 			if resp.Success {
-				n.matchIndex[id] = logIndex
-				n.nextIndex[id] = logIndex + 1
-				successCount++
+				// Responses for earlier entries may arrive after later
+				// ones, so never move a peer's match index back
+				if lastIndex > n.matchIndex[id] {
+					n.matchIndex[id] = lastIndex
+					n.nextIndex[id] = lastIndex + 1
+				}
+				if n.state == Leader && n.currentTerm == term {
+					n.advanceCommitIndex()
+				}
 
-				// Check if we have majority
-				if successCount > totalPeers/2 {
-					// Update commit index
-					n.commitIndex = logIndex
-					n.applyCommittedEntries()
+				// Tell followers about the commit right away. A follower
+				// that acknowledges after the entry committed still needs
+				// a later commit index than the one it was sent.
+				if n.commitIndex >= lastIndex {
+					n.notifyCommit()
 				}
 			} else {
 				// Decrement nextIndex and retry
@@ -136,59 +241,103 @@ func (n *RaftNode) replicateLogEntry(entry LogEntry, logIndex int) bool {
 					n.nextIndex[id]--
 				}
 			}
+			acks <- resp.Success
 		}(peerID, peerAddr)
 	}
 
-	// Wait for majority (simplified - in practice, this should be more sophisticated)
-	time.Sleep(100 * time.Millisecond)
-
-	n.mu.RLock()
-	defer n.mu.RUnlock()
-	return n.commitIndex >= logIndex
-}
-
-// getPrevLogTerm returns the term of the log entry at the given index
-func (n *RaftNode) getPrevLogTerm(index int) int {
-	if index == 0 {
-		return 0
-	}
-	if index > len(n.log) {
-		return 0
+	// Wait for a majority
+	successCount := 1 // Count self
+	needed := (len(peers)+1)/2 + 1
+	timeout := time.NewTimer(replicateTimeout)
+	defer timeout.Stop()
+	for pending := len(peers); successCount < needed; pending-- {
+		if pending == 0 {
+			return false
+		}
+		select {
+		case ok := <-acks:
+			if ok {
+				successCount++
+			}
+		case <-timeout.C:
+			return false
+		case <-n.ctx.Done():
+			return false
+		}
 	}
-	return n.log[index-1].Term
-}
 
-// applyEntry applies a single log entry to the state machine
-func (n *RaftNode) applyEntry(entry LogEntry) {
-	command := string(entry.Command)
+	n.mu.Lock()
+	defer n.mu.Unlock()
 
-	if len(command) < 4 {
-		return
+	// A leader that has moved on to a later term can no longer commit
+	// these entries itself
+	if n.state != Leader || n.currentTerm != term {
+		return false
 	}
 
-	switch command[:4] {
-	case "PUT ":
-		// Parse key-value from command
-		parts := bytes.Split(entry.Command[4:], []byte{' '})
-		if len(parts) >= 2 {
-			key := parts[0]
-			value := parts[1]
-			n.storage.Put(key, value)
+	n.advanceCommitIndex()
+	return n.commitIndex >= lastIndex
+}
+
+// advanceCommitIndex commits the latest entry of the leader's term that a
+// majority, counting the leader, has stored, and applies everything up to
+// it. The caller must hold n.mu.
+func (n *RaftNode) advanceCommitIndex() {
+	for index := n.lastLogIndex(); index > n.commitIndex; index-- {
+		// Entries from earlier terms only commit along with a later one
+		if n.termAt(index) != n.currentTerm {
+			return
+		}
+		stored := 1 // Count self
+		for peerID := range n.peers {
+			if n.matchIndex[peerID] >= index {
+				stored++
+			}
+		}
+		if stored > (len(n.peers)+1)/2 {
+			n.commitIndex = index
+			n.applyCommittedEntries()
+			n.notifyCommit()
+			return
 		}
-	case "DEL ":
-		key := entry.Command[4:]
-		n.storage.Delete(key)
 	}
 }
 
+// getPrevLogTerm returns the term of the log entry at the given index
+func (n *RaftNode) getPrevLogTerm(index int) int {
+	return n.termAt(index)
+}
+
 // SubmitRequest submits a client request to the Raft cluster
 func (n *RaftNode) SubmitRequest(operation string, key, value []byte) ([]byte, error) {
-	req := ClientRequest{
+	return n.submit(ClientRequest{
 		Operation: operation,
 		Key:       key,
 		Value:     value,
-		Response:  make(chan ClientResponse, 1),
+	})
+}
+
+// SubmitSessionRequest submits a write on behalf of a client session.
+// Each write from a client carries a higher seq than the last, and a retry
+// reuses the seq of the write it retries, so a retry whose first attempt
+// already committed is acknowledged without being applied again. See
+// SetSessionLimits for how long sessions are remembered.
+func (n *RaftNode) SubmitSessionRequest(clientID string, seq uint64, operation string, key, value []byte) ([]byte, error) {
+	if err := validateClientID(clientID); err != nil {
+		return nil, err
 	}
+	return n.submit(ClientRequest{
+		Operation: operation,
+		Key:       key,
+		Value:     value,
+		ClientID:  clientID,
+		Seq:       seq,
+	})
+}
+
+// submit sends req to the node's request loop and waits for its response
+func (n *RaftNode) submit(req ClientRequest) ([]byte, error) {
+	req.Response = make(chan ClientResponse, 1)
 
 	select {
 	case n.clientRequestChan <- req:
@@ -208,9 +357,9 @@ func (n *RaftNode) SubmitRequest(operation string, key, value []byte) ([]byte, e
 	}
 }
 
-// Get retrieves a value from the cluster
+// Get retrieves a value from the cluster. See LinearizableGet.
 func (n *RaftNode) Get(key []byte) ([]byte, error) {
-	return n.SubmitRequest("get", key, nil)
+	return n.LinearizableGet(key)
 }
 
 // Put stores a key-value pair in the cluster
@@ -0,0 +1,125 @@
+package raft
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+func TestRaftNode_PipelinedWritesKeepOrder(t *testing.T) {
+	cluster := startTestClusterWith(t, 3, func() storage.Storage {
+		return newSlowStorage(0)
+	})
+	leader := waitForLeader(t, cluster)
+
+	// Each writer issues its writes one after another; writers race
+	const writers = 8
+	const perWriter = 5
+	start := time.Now()
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			key := []byte(fmt.Sprintf("key%d", w))
+			for i := 0; i < perWriter; i++ {
+				if err := leader.Put(key, []byte(strconv.Itoa(i))); err != nil {
+					t.Errorf("Put key%d=%d failed: %v", w, i, err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// One replication round per write would take at least 4s
+	if elapsed > 2*time.Second {
+		t.Errorf("Expected concurrent writes to share replication rounds, took %v", elapsed)
+	}
+
+	// A write acknowledged before another was submitted comes first in
+	// the log and in every state machine
+	leader.mu.RLock()
+	next := make(map[string]int)
+	for _, entry := range leader.log {
+		_, key, value, _ := decodeCommand(entry.Command)
+		if string(value) != strconv.Itoa(next[string(key)]) {
+			t.Errorf("Expected %s=%d next in the log, got %s", key, next[string(key)], value)
+		}
+		next[string(key)]++
+	}
+	leader.mu.RUnlock()
+
+	// Followers learn the final commit index from a heartbeat
+	time.Sleep(300 * time.Millisecond)
+	for _, node := range cluster.GetAllNodes() {
+		store := node.storage.(*slowStorage)
+		for w := 0; w < writers; w++ {
+			store.mu.Lock()
+			values := store.values[fmt.Sprintf("key%d", w)]
+			store.mu.Unlock()
+			if len(values) != perWriter {
+				t.Errorf("%s: Expected %d writes to key%d, got %v", node.id, perWriter, w, values)
+				continue
+			}
+			for i, v := range values {
+				if v != strconv.Itoa(i) {
+					t.Errorf("%s: Expected write %d to key%d to be %d, got %s", node.id, i, w, i, v)
+					break
+				}
+			}
+		}
+	}
+}
+
+func benchmarkClientWrites(b *testing.B, maxInFlight int) {
+	cluster := startTestCluster(b, 3)
+	leader := waitForLeader(b, cluster)
+	leader.SetMaxInFlight(maxInFlight)
+
+	var next int64
+	b.SetParallelism(16)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			key := []byte(fmt.Sprintf("key%d", atomic.AddInt64(&next, 1)))
+			if err := leader.Put(key, []byte("value")); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func BenchmarkClientWrites_OneAtATime(b *testing.B) { benchmarkClientWrites(b, 1) }
+func BenchmarkClientWrites_Pipelined(b *testing.B)  { benchmarkClientWrites(b, defaultMaxInFlight) }
+
+func TestRaftNode_ReplicateReturnsOnMajority(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	leader := waitForLeader(t, cluster)
+	if err := leader.Put([]byte("warmup"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// Peers answer within a millisecond or so, so a write commits long
+	// before a fixed wait of 100ms would end
+	const writes = 20
+	start := time.Now()
+	for i := 0; i < writes; i++ {
+		if err := leader.Put([]byte(fmt.Sprintf("key%d", i)), []byte("value")); err != nil {
+			t.Fatalf("Put %d failed: %v", i, err)
+		}
+	}
+	if perWrite := time.Since(start) / writes; perWrite > 30*time.Millisecond {
+		t.Errorf("Expected writes to return well under 100ms, took %v each", perWrite)
+	}
+
+	if pending := leader.UncommittedEntries(); pending != 0 {
+		t.Errorf("Expected every acknowledged write to be committed, got %d uncommitted", pending)
+	}
+}
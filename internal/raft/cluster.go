@@ -8,20 +8,26 @@ import (
 	"godatabase/internal/storage"
 )
 
-// Cluster represents a Raft cluster
+// Cluster represents a Raft cluster.
+// Every node it manages is also registered with a GlobalCluster, which is
+// the single source of truth for node IDs in the process.
 type Cluster struct {
-	nodes map[string]*RaftNode
-	mu    sync.RWMutex
+	nodes    map[string]*RaftNode
+	registry *GlobalCluster
+	mu       sync.RWMutex
 }
 
-// NewCluster creates a new Raft cluster
+// NewCluster creates a new Raft cluster backed by the global registry
 func NewCluster() *Cluster {
 	return &Cluster{
-		nodes: make(map[string]*RaftNode),
+		nodes:    make(map[string]*RaftNode),
+		registry: GetGlobalCluster(),
 	}
 }
 
-// AddNode adds a node to the cluster
+// AddNode adds a node to the cluster.
+// It fails if a node with the same ID is already registered, whether
+// through this cluster, another Cluster, or the GlobalCluster directly.
 func (c *Cluster) AddNode(id, address string, peers map[string]string, storage storage.Storage) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -31,18 +37,22 @@ func (c *Cluster) AddNode(id, address string, peers map[string]string, storage s
 	}
 
 	node := NewRaftNode(id, address, peers, storage)
+	if err := c.registry.RegisterNode(node); err != nil {
+		return err
+	}
 	c.nodes[id] = node
 
 	// Start the node
 	if err := node.Start(); err != nil {
 		delete(c.nodes, id)
+		c.registry.UnregisterNode(id)
 		return fmt.Errorf("failed to start node %s: %v", id, err)
 	}
 
 	// Start RPC server
 	if err := node.StartRPCServer(); err != nil {
-		node.Stop()
 		delete(c.nodes, id)
+		c.registry.UnregisterNode(id)
 		return fmt.Errorf("failed to start RPC server for node %s: %v", id, err)
 	}
 
@@ -50,18 +60,17 @@ func (c *Cluster) AddNode(id, address string, peers map[string]string, storage s
 	return nil
 }
 
-// RemoveNode removes a node from the cluster
+// RemoveNode stops a node and removes it from the cluster and the registry
 func (c *Cluster) RemoveNode(id string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	node, exists := c.nodes[id]
-	if !exists {
+	if _, exists := c.nodes[id]; !exists {
 		return fmt.Errorf("node %s not found", id)
 	}
 
-	node.Stop()
 	delete(c.nodes, id)
+	c.registry.UnregisterNode(id)
 	log.Printf("Removed node %s from cluster", id)
 	return nil
 }
@@ -105,15 +114,17 @@ func (c *Cluster) GetNodes() map[string]*RaftNode {
 	return nodes
 }
 
-// Stop stops all nodes in the cluster
+// Stop stops all nodes in the cluster and removes them from the registry.
+// Calling Stop again is a no-op.
 func (c *Cluster) Stop() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	for id, node := range c.nodes {
+	for id := range c.nodes {
 		log.Printf("Stopping node %s", id)
-		node.Stop()
+		c.registry.UnregisterNode(id)
 	}
+	c.nodes = make(map[string]*RaftNode)
 }
 
 // GetClusterInfo returns information about the cluster
@@ -132,6 +143,7 @@ func (c *Cluster) GetClusterInfo() map[string]interface{} {
 			"state":   state.String(),
 			"term":    term,
 			"leader":  node.IsLeader(),
+			"healthy": node.Health() == nil,
 		}
 	}
 
@@ -0,0 +1,69 @@
+package raft
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestCluster_RejectsDuplicateRegistration(t *testing.T) {
+	registry := newGlobalCluster()
+	cluster := &Cluster{nodes: make(map[string]*RaftNode), registry: registry}
+	defer cluster.Stop()
+
+	addr := ":" + strconv.Itoa(freePort(t))
+	if err := cluster.AddNode("node1", addr, map[string]string{}, nil); err != nil {
+		t.Fatalf("AddNode failed: %v", err)
+	}
+
+	// Same ID through the same cluster
+	if err := cluster.AddNode("node1", addr, map[string]string{}, nil); err == nil {
+		t.Error("Expected error adding node1 twice")
+	}
+
+	// Same ID registered directly with the global registry
+	if err := registry.RegisterNode(NewRaftNode("node1", addr, nil, nil)); err == nil {
+		t.Error("Expected error registering node1 with the registry")
+	}
+
+	// Same ID through a second cluster sharing the registry
+	other := &Cluster{nodes: make(map[string]*RaftNode), registry: registry}
+	if err := other.AddNode("node1", addr, map[string]string{}, nil); err == nil {
+		t.Error("Expected error adding node1 through another cluster")
+	}
+	if len(other.GetNodes()) != 0 {
+		t.Error("Rejected node must not be tracked by the second cluster")
+	}
+}
+
+func TestCluster_RemoveNodeStopsOnce(t *testing.T) {
+	registry := newGlobalCluster()
+	cluster := &Cluster{nodes: make(map[string]*RaftNode), registry: registry}
+
+	addr := ":" + strconv.Itoa(freePort(t))
+	if err := cluster.AddNode("node1", addr, map[string]string{}, nil); err != nil {
+		t.Fatalf("AddNode failed: %v", err)
+	}
+	node, err := registry.GetNode("node1")
+	if err != nil {
+		t.Fatalf("Node not registered: %v", err)
+	}
+
+	if err := cluster.RemoveNode("node1"); err != nil {
+		t.Fatalf("RemoveNode failed: %v", err)
+	}
+	if node.GetContext().Err() == nil {
+		t.Error("Expected node to be stopped")
+	}
+
+	// The registry no longer owns the node, so these must not stop it again
+	if _, err := registry.GetNode("node1"); err == nil {
+		t.Error("Expected node to be removed from the registry")
+	}
+	registry.UnregisterNode("node1")
+	cluster.Stop()
+	cluster.Stop()
+
+	if err := cluster.RemoveNode("node1"); err == nil {
+		t.Error("Expected error removing node1 twice")
+	}
+}
@@ -0,0 +1,48 @@
+package raft
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestBinaryLogCodec_RoundTrip(t *testing.T) {
+	codec := BinaryLogCodec{}
+	entries := []LogEntry{
+		{Term: 1, Index: 1, Command: []byte("PUT key value")},
+		{Term: 7, Index: 42, Command: []byte{0x00, 0xff, ' ', '\n', 0x00}},
+		{Term: 3, Index: 9, Command: nil},
+	}
+
+	for _, entry := range entries {
+		decoded, err := codec.Decode(codec.Encode(entry))
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if decoded.Term != entry.Term || decoded.Index != entry.Index {
+			t.Errorf("Expected term %d index %d, got term %d index %d",
+				entry.Term, entry.Index, decoded.Term, decoded.Index)
+		}
+		if !bytes.Equal(decoded.Command, entry.Command) {
+			t.Errorf("Expected command %v, got %v", entry.Command, decoded.Command)
+		}
+	}
+}
+
+func TestBinaryLogCodec_RejectsFutureVersion(t *testing.T) {
+	codec := BinaryLogCodec{}
+	record := codec.Encode(LogEntry{Term: 1, Index: 1, Command: []byte("DEL key")})
+	record[0] = logCodecVersion + 1
+
+	if _, err := codec.Decode(record); !errors.Is(err, ErrUnsupportedLogVersion) {
+		t.Errorf("Expected ErrUnsupportedLogVersion, got %v", err)
+	}
+
+	// Truncated records are errors, not panics
+	valid := codec.Encode(LogEntry{Term: 1, Index: 1, Command: []byte("DEL key")})
+	for i := 0; i < len(valid); i++ {
+		if _, err := codec.Decode(valid[:i]); err == nil {
+			t.Errorf("Expected error decoding %d-byte prefix", i)
+		}
+	}
+}
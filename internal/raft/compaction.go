@@ -0,0 +1,323 @@
+package raft
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+// SetSnapshotThreshold makes the node compact its log once entries applied
+// entries have built up since the last snapshot: it captures the state
+// machine in a snapshot and drops the entries the snapshot covers. Leaders
+// send the snapshot to followers that fall behind it. 0 disables
+// compaction.
+func (n *RaftNode) SetSnapshotThreshold(entries int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if entries < 0 {
+		entries = 0
+	}
+	n.snapshotThreshold = entries
+}
+
+// compactLog snapshots the state machine through lastApplied and drops
+// the log entries the snapshot covers. Nothing is applied while the
+// storage is read, so the snapshot matches lastApplied exactly. The caller
+// must hold n.mu.
+func (n *RaftNode) compactLog() {
+	data, err := n.captureState()
+	if err != nil {
+		log.Printf("ERROR: node %s failed to snapshot state: %v", n.id, err)
+		return
+	}
+
+	snap := &snapshot{
+		lastIncludedIndex: n.lastApplied,
+		lastIncludedTerm:  n.termAt(n.lastApplied),
+		data:              data,
+	}
+	if err := n.setSnapshot(snap); err != nil {
+		log.Printf("ERROR: node %s failed to compact log: %v", n.id, err)
+		return
+	}
+	log.Printf("Node %s compacted log through entry %d into a %d-byte snapshot", n.id, snap.lastIncludedIndex, len(data))
+}
+
+// setSnapshot makes snap the node's snapshot and drops the log entries it
+// covers. The log must either hold every entry up to the snapshot or none
+// past the current one. With a stable store, the snapshot is saved before
+// the entries are dropped from it, so a crash in between leaves entries
+// that SetStableStore skips. The caller must hold n.mu.
+func (n *RaftNode) setSnapshot(snap *snapshot) error {
+	drop := snap.lastIncludedIndex - n.snapshotIndex
+	if drop > len(n.log) {
+		drop = len(n.log)
+	}
+
+	if n.stable != nil {
+		// The stored log must match the log in memory before its first
+		// entries can be dropped
+		if err := n.persistState(); err != nil {
+			return err
+		}
+		if err := n.stable.SaveSnapshot(snap.lastIncludedIndex, snap.lastIncludedTerm, snap.data); err != nil {
+			return fmt.Errorf("failed to persist snapshot: %v", err)
+		}
+		if err := n.stable.CompactLog(drop); err != nil {
+			return fmt.Errorf("failed to compact stored log: %v", err)
+		}
+		n.persistedLog -= drop
+		n.storedLog -= drop
+	}
+
+	n.log = append([]LogEntry(nil), n.log[drop:]...)
+	n.snapshotIndex = snap.lastIncludedIndex
+	n.snapshotTerm = snap.lastIncludedTerm
+	n.lastSnapshot = snap
+	return nil
+}
+
+// installSnapshot replaces the state machine with a snapshot received from
+// the leader. Log entries after the snapshot are kept if the log agrees
+// with it; otherwise the snapshot replaces the whole log. A snapshot the
+// node has already applied past is ignored. The caller must hold n.mu.
+func (n *RaftNode) installSnapshot(snap *snapshot) error {
+	if snap.lastIncludedIndex <= n.lastApplied {
+		return nil
+	}
+	if err := n.restoreState(snap.data); err != nil {
+		return err
+	}
+
+	if snap.lastIncludedIndex > n.lastLogIndex() || n.termAt(snap.lastIncludedIndex) != snap.lastIncludedTerm {
+		n.truncateLog(n.snapshotIndex)
+	}
+	if err := n.setSnapshot(snap); err != nil {
+		return err
+	}
+
+	if n.commitIndex < snap.lastIncludedIndex {
+		n.commitIndex = snap.lastIncludedIndex
+	}
+	n.lastApplied = snap.lastIncludedIndex
+	for _, o := range n.observers {
+		o.reset(n.lastApplied)
+	}
+	log.Printf("Node %s installed snapshot through entry %d", n.id, snap.lastIncludedIndex)
+	return nil
+}
+
+// captureState encodes the state machine: the client sessions, then every
+// key-value pair in the node's storage. The caller must hold n.mu.
+func (n *RaftNode) captureState() ([]byte, error) {
+	ids := make([]string, 0, len(n.sessions.sessions))
+	for id := range n.sessions.sessions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	data := binary.BigEndian.AppendUint32(nil, uint32(len(ids)))
+	for _, id := range ids {
+		session := n.sessions.sessions[id]
+		data = appendField(data, []byte(id))
+		data = binary.BigEndian.AppendUint64(data, session.seq)
+		data = binary.BigEndian.AppendUint64(data, uint64(session.lastActive))
+	}
+
+	it, err := n.storage.Scan(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	for it.Next() {
+		data = appendField(data, it.Key())
+		data = appendField(data, it.Value())
+	}
+	if err := it.Close(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// restoreState replaces the state machine with one encoded by
+// captureState. The snapshot is decoded in full before the storage is
+// touched, so a corrupt one changes nothing. The caller must hold n.mu.
+func (n *RaftNode) restoreState(data []byte) error {
+	sessions, pairs, err := decodeState(data)
+	if err != nil {
+		return err
+	}
+
+	it, err := n.storage.Scan(nil, nil)
+	if err != nil {
+		return err
+	}
+	var stale [][]byte
+	for it.Next() {
+		stale = append(stale, append([]byte(nil), it.Key()...))
+	}
+	if err := it.Close(); err != nil {
+		return err
+	}
+	for _, key := range stale {
+		if err := n.storage.Delete(key); err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+			return err
+		}
+	}
+	for _, pair := range pairs {
+		if err := n.storage.Put(pair.Key, pair.Value); err != nil {
+			return err
+		}
+	}
+
+	n.sessions.sessions = sessions
+	return nil
+}
+
+// decodeState splits a snapshot encoded by captureState into its client
+// sessions and key-value pairs
+func decodeState(data []byte) (map[string]*clientSession, []storage.KV, error) {
+	if len(data) < 4 {
+		return nil, nil, errors.New("snapshot is truncated")
+	}
+	count := int(binary.BigEndian.Uint32(data))
+	data = data[4:]
+
+	sessions := make(map[string]*clientSession)
+	for i := 0; i < count; i++ {
+		id, rest, ok := readField(data)
+		if !ok || len(rest) < 16 {
+			return nil, nil, errors.New("snapshot session is truncated")
+		}
+		sessions[string(id)] = &clientSession{
+			seq:        binary.BigEndian.Uint64(rest),
+			lastActive: int64(binary.BigEndian.Uint64(rest[8:])),
+		}
+		data = rest[16:]
+	}
+
+	var pairs []storage.KV
+	for len(data) > 0 {
+		key, rest, ok := readField(data)
+		if !ok {
+			return nil, nil, errors.New("snapshot key is truncated")
+		}
+		value, rest, ok := readField(rest)
+		if !ok {
+			return nil, nil, errors.New("snapshot value is truncated")
+		}
+		pairs = append(pairs, storage.KV{Key: key, Value: value})
+		data = rest
+	}
+	return sessions, pairs, nil
+}
+
+// appendField appends b to data, prefixed with its length
+func appendField(data, b []byte) []byte {
+	data = binary.BigEndian.AppendUint32(data, uint32(len(b)))
+	return append(data, b...)
+}
+
+// readField reads a field written by appendField, returning it and the
+// data after it
+func readField(data []byte) (field, rest []byte, ok bool) {
+	if len(data) < 4 {
+		return nil, nil, false
+	}
+	size := int(binary.BigEndian.Uint32(data))
+	if len(data)-4 < size {
+		return nil, nil, false
+	}
+	return data[4 : 4+size], data[4+size:], true
+}
+
+// catchUp brings a follower whose log ends at last up to date with the
+// leader. Entries the leader still holds are sent in one AppendEntries; if
+// the follower needs entries compacted away, it is sent the snapshot
+// first. If the follower's log turns out to diverge, the leader backs up
+// an entry at a time, falling back to the snapshot once it reaches it.
+func (n *RaftNode) catchUp(peerID, addr string, last int) {
+	defer func() {
+		n.mu.Lock()
+		delete(n.catchingUp, peerID)
+		n.mu.Unlock()
+	}()
+
+	for {
+		n.mu.RLock()
+		if n.state != Leader || n.ctx.Err() != nil {
+			n.mu.RUnlock()
+			return
+		}
+		prev := last
+		if prev > n.lastLogIndex() {
+			prev = n.lastLogIndex()
+		}
+		snap := n.lastSnapshot
+		needSnapshot := prev < n.snapshotIndex
+		var req AppendEntriesRequest
+		if !needSnapshot {
+			req = AppendEntriesRequest{
+				Term:         n.currentTerm,
+				LeaderID:     n.id,
+				PrevLogIndex: prev,
+				PrevLogTerm:  n.termAt(prev),
+				Entries:      append([]LogEntry(nil), n.log[prev-n.snapshotIndex:]...),
+				LeaderCommit: n.commitIndex,
+			}
+		}
+		n.mu.RUnlock()
+
+		if needSnapshot {
+			log.Printf("Node %s sending snapshot through entry %d to %s", n.id, snap.lastIncludedIndex, peerID)
+			if _, err := n.sendSnapshot(peerID, snap); err != nil {
+				log.Printf("Failed to send snapshot to %s: %v", peerID, err)
+				return
+			}
+			last = snap.lastIncludedIndex
+			continue
+		}
+
+		resp, err := n.sendAppendEntries(addr, req)
+		if err != nil {
+			log.Printf("Failed to catch up %s: %v", peerID, err)
+			return
+		}
+
+		n.mu.Lock()
+		n.peerContact[peerID] = time.Now()
+		if resp.Term > n.currentTerm {
+			n.becomeFollower(resp.Term)
+			n.persistOrLog()
+			n.mu.Unlock()
+			return
+		}
+		if resp.Success {
+			matched := req.PrevLogIndex + len(req.Entries)
+			if matched > n.matchIndex[peerID] {
+				n.matchIndex[peerID] = matched
+				n.nextIndex[peerID] = matched + 1
+			}
+			if n.state == Leader {
+				n.advanceCommitIndex()
+			}
+			n.mu.Unlock()
+			return
+		}
+		n.mu.Unlock()
+
+		// The follower's log diverges at or before prev
+		last = prev - 1
+		if resp.LastLogIndex < last {
+			last = resp.LastLogIndex
+		}
+		if last < 0 {
+			last = 0
+		}
+	}
+}
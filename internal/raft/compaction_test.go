@@ -0,0 +1,136 @@
+package raft
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+func newBadger(t *testing.T) storage.Storage {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestRaftNode_CompactsLogAndCatchesUpFreshFollower(t *testing.T) {
+	const writes = 60
+	cluster := startTestCluster(t, 3)
+	for _, node := range cluster.GetAllNodes() {
+		node.SetSnapshotThreshold(20)
+	}
+	leader := waitForLeader(t, cluster)
+
+	// Take a follower down for the writes
+	var stale *RaftNode
+	for _, node := range cluster.GetAllNodes() {
+		if node != leader {
+			stale = node
+			break
+		}
+	}
+	cluster.UnregisterNode(stale.GetID())
+	stale.storage.Close()
+
+	rs := NewRaftStorage(cluster, leader.GetID())
+	for i := 0; i < writes; i++ {
+		if err := rs.Put([]byte(fmt.Sprintf("key%02d", i)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Fatalf("Put %d failed: %v", i, err)
+		}
+	}
+
+	leader.mu.RLock()
+	snapshotIndex, logLen := leader.snapshotIndex, len(leader.log)
+	leader.mu.RUnlock()
+	if snapshotIndex < 20 || logLen >= writes {
+		t.Fatalf("Expected the leader to compact its log, got snapshot through %d and %d entries", snapshotIndex, logLen)
+	}
+
+	// A follower with an empty log needs entries the leader dropped, so
+	// it is brought up to date from the snapshot
+	fresh := NewRaftNode(stale.GetID(), stale.GetAddress(), stale.peers, newBadger(t))
+	fresh.SetSnapshotThreshold(20)
+	if err := cluster.RegisterNode(fresh); err != nil {
+		t.Fatal(err)
+	}
+	if err := fresh.StartRPCServer(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fresh.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fresh.WaitForApplied(leader.CommitIndex(), 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	fresh.mu.RLock()
+	installed := fresh.snapshotIndex
+	fresh.mu.RUnlock()
+	if installed < snapshotIndex {
+		t.Errorf("Expected the follower to install a snapshot through at least %d, got %d", snapshotIndex, installed)
+	}
+	for i := 0; i < writes; i++ {
+		value, err := fresh.storage.Get([]byte(fmt.Sprintf("key%02d", i)))
+		if err != nil || string(value) != fmt.Sprintf("value%d", i) {
+			t.Errorf("Expected key%02d to be value%d, got %q (%v)", i, i, value, err)
+		}
+	}
+}
+
+func TestRaftNode_RestoresSnapshotAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStableStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := NewRaftNode("a", ":0", nil, newBadger(t))
+	n.SetSnapshotThreshold(5)
+	if err := n.SetStableStore(store); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp AppendEntriesResponse
+	req := AppendEntriesRequest{Term: 1, LeaderID: "b", Entries: putEntries(1, 8), LeaderCommit: 8}
+	if err := (&RaftRPC{node: n}).AppendEntries(req, &resp); err != nil || !resp.Success {
+		t.Fatalf("Expected entries to be appended, got %+v (%v)", resp, err)
+	}
+	n.mu.RLock()
+	snapshotIndex, logLen := n.snapshotIndex, len(n.log)
+	n.mu.RUnlock()
+	if snapshotIndex != 8 || logLen != 0 {
+		t.Fatalf("Expected a snapshot through 8 and an empty log, got %d and %d entries", snapshotIndex, logLen)
+	}
+
+	// Append past the snapshot, then restart with empty storage
+	req = AppendEntriesRequest{Term: 1, LeaderID: "b", PrevLogIndex: 8, PrevLogTerm: 1, LeaderCommit: 8,
+		Entries: []LogEntry{{Term: 1, Command: []byte("PUT key1 uncommitted")}}}
+	if err := (&RaftRPC{node: n}).AppendEntries(req, &resp); err != nil || !resp.Success {
+		t.Fatalf("Expected entry to be appended, got %+v (%v)", resp, err)
+	}
+	store.Close()
+
+	store, err = NewFileStableStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	restarted := NewRaftNode("a", ":0", nil, newBadger(t))
+	if err := restarted.SetStableStore(store); err != nil {
+		t.Fatal(err)
+	}
+
+	if restarted.snapshotIndex != 8 || restarted.lastApplied != 8 || restarted.lastLogIndex() != 9 {
+		t.Errorf("Expected a snapshot through 8 and log through 9, got snapshot %d, applied %d, log %d",
+			restarted.snapshotIndex, restarted.lastApplied, restarted.lastLogIndex())
+	}
+	if value, err := restarted.storage.Get([]byte("key0")); err != nil || string(value) != "7" {
+		t.Errorf("Expected key0 restored from the snapshot as 7, got %q (%v)", value, err)
+	}
+	if string(restarted.log[0].Command) != "PUT key1 uncommitted" {
+		t.Errorf("Expected the entry after the snapshot, got %q", restarted.log[0].Command)
+	}
+}
@@ -0,0 +1,176 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"godatabase/internal/rpc/proto"
+)
+
+const (
+	// forwardTimeout bounds how long a write made through a follower keeps
+	// looking for a leader to forward it to
+	forwardTimeout = 3 * time.Second
+
+	// forwardRetryInterval is how long to wait before retrying a write
+	// that found no leader, or one that had just lost leadership
+	forwardRetryInterval = 50 * time.Millisecond
+
+	// leaderConnectTimeout bounds how long a forwarded write waits to
+	// connect to a remote leader before it counts as unreachable
+	leaderConnectTimeout = time.Second
+)
+
+// leaderNode returns this storage's node if it is the leader. Otherwise
+// it returns an error wrapping ErrNotLeader, naming the leader if one is
+// known, or ErrNoLeader.
+func (rs *RaftStorage) leaderNode() (*RaftNode, error) {
+	node, err := rs.cluster.GetNode(rs.nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node: %v", err)
+	}
+	if node.IsLeader() {
+		return node, nil
+	}
+
+	if leader, err := rs.cluster.GetLeader(); err == nil {
+		return nil, fmt.Errorf("%w, leader is at %s", ErrNotLeader, leader.GetAddress())
+	}
+	if _, addr, ok := node.Leader(); ok {
+		return nil, fmt.Errorf("%w, leader is at %s", ErrNotLeader, addr)
+	}
+	return nil, fmt.Errorf("%w: no leader found", ErrNoLeader)
+}
+
+// forward runs a write on the leader. local runs it through a RaftStorage
+// bound to a node: this one, or the leader if it is registered in the same
+// cluster. If the leader is in another process, remote runs it against
+// the leader's gRPC server instead, which serves Raft and Storage on the
+// same address. Attempts that show the write was never submitted are
+// retried until forwardTimeout elapses: those that find no leader, reach a
+// node that has just lost leadership, or can't connect to the leader
+// before sending. Any other error is returned as it is, including a
+// timeout or lost connection after the request was sent to a remote
+// leader, which may have applied the write. If ctx is done
+// while waiting to retry, forward gives up at once with ctx.Err(); ctx
+// also bounds calls to a remote leader.
+func (rs *RaftStorage) forward(ctx context.Context, local func(leader *RaftStorage) error, remote func(ctx context.Context, c proto.StorageClient) error) error {
+	deadline := time.Now().Add(forwardTimeout)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := rs.forwardOnce(ctx, local, remote)
+		if err == nil || !isLeaderChange(err) || time.Now().After(deadline) {
+			return err
+		}
+
+		retry := time.NewTimer(forwardRetryInterval)
+		select {
+		case <-ctx.Done():
+			retry.Stop()
+			return ctx.Err()
+		case <-retry.C:
+		}
+	}
+}
+
+// forwardOnce makes one attempt at running a write on the leader
+func (rs *RaftStorage) forwardOnce(ctx context.Context, local func(leader *RaftStorage) error, remote func(ctx context.Context, c proto.StorageClient) error) error {
+	err := local(rs)
+	if !isLeaderChange(err) {
+		return err
+	}
+
+	// A leader in this process is called directly
+	if leader, lerr := rs.cluster.GetLeader(); lerr == nil {
+		if leader.GetID() == rs.nodeID {
+			return err
+		}
+		return local(&RaftStorage{cluster: rs.cluster, nodeID: leader.GetID()})
+	}
+
+	// Otherwise ask the leader this node last heard from
+	node, nerr := rs.cluster.GetNode(rs.nodeID)
+	if nerr != nil {
+		return err
+	}
+	id, addr, ok := node.Leader()
+	if !ok || id == rs.nodeID {
+		return err
+	}
+	conn, cerr := rs.leaderConn(addr)
+	if cerr == nil {
+		cerr = waitConnected(ctx, conn)
+	}
+	if cerr != nil {
+		// Nothing was sent, so the write can be retried
+		return fmt.Errorf("%w: can't reach leader %s: %v", ErrNotLeader, id, cerr)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, forwardTimeout)
+	defer cancel()
+	return remote(ctx, proto.NewStorageClient(conn))
+}
+
+// leaderConn returns a connection to the gRPC server at addr, reusing the
+// last one if it went to the same address
+func (rs *RaftStorage) leaderConn(addr string) (*grpc.ClientConn, error) {
+	rs.forwardMu.Lock()
+	defer rs.forwardMu.Unlock()
+
+	if rs.forwardConn != nil && rs.forwardAddr == addr {
+		return rs.forwardConn, nil
+	}
+	if rs.forwardConn != nil {
+		rs.forwardConn.Close()
+		rs.forwardConn, rs.forwardAddr = nil, ""
+	}
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	rs.forwardConn, rs.forwardAddr = conn, addr
+	return conn, nil
+}
+
+// waitConnected waits until conn is connected, or leaderConnectTimeout
+// elapses, so a request isn't sent over a connection that never came up
+func waitConnected(ctx context.Context, conn *grpc.ClientConn) error {
+	ctx, cancel := context.WithTimeout(ctx, leaderConnectTimeout)
+	defer cancel()
+
+	conn.Connect()
+	for {
+		state := conn.GetState()
+		if state == connectivity.Ready {
+			return nil
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("connection %s: %w", state, ctx.Err())
+		}
+	}
+}
+
+// isLeaderChange reports whether err means the write reached no leader,
+// so it wasn't submitted and can be retried. Errors from a remote leader
+// arrive as text. A gRPC error such as Unavailable doesn't count: the
+// request may have reached the leader before the connection failed.
+func isLeaderChange(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrNotLeader) || errors.Is(err, ErrNoLeader) {
+		return true
+	}
+	msg := err.Error()
+	return strings.HasPrefix(msg, ErrNotLeader.Error()) || strings.HasPrefix(msg, ErrNoLeader.Error())
+}
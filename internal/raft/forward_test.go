@@ -0,0 +1,266 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"godatabase/internal/rpc"
+	"godatabase/internal/rpc/proto"
+	"godatabase/internal/storage"
+)
+
+func TestRaftStorage_FollowerForwardsWrites(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	leader := waitForLeader(t, cluster)
+
+	var follower *RaftStorage
+	for id, node := range cluster.GetAllNodes() {
+		if node != leader {
+			follower = NewRaftStorage(cluster, id)
+			break
+		}
+	}
+
+	if err := follower.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put through a follower failed: %v", err)
+	}
+	if swapped, err := follower.CompareAndSwap([]byte("key"), []byte("value"), []byte("swapped")); err != nil || !swapped {
+		t.Fatalf("CompareAndSwap through a follower failed: %v (%v)", swapped, err)
+	}
+
+	for id := range cluster.GetAllNodes() {
+		value, err := NewRaftStorage(cluster, id).Get([]byte("key"))
+		if err != nil || string(value) != "swapped" {
+			t.Errorf("Node %s: Expected swapped, got %q (%v)", id, value, err)
+		}
+	}
+
+	if err := follower.Delete([]byte("key")); err != nil {
+		t.Fatalf("Delete through a follower failed: %v", err)
+	}
+	if _, err := NewRaftStorage(cluster, leader.GetID()).Get([]byte("key")); err == nil {
+		t.Error("Expected key to be deleted on the leader")
+	}
+
+	for i := 1; i <= 3; i++ {
+		if n, err := follower.Increment([]byte("counter"), 1); err != nil || n != int64(i) {
+			t.Fatalf("Increment through a follower: Expected %d, got %d (%v)", i, n, err)
+		}
+	}
+}
+
+func TestRaftStorage_ForwardsWritesToRemoteLeader(t *testing.T) {
+	// Each node gets a cluster of its own, as if it ran in its own
+	// process, so writes can only reach the leader over gRPC. Each node
+	// serves Raft and Storage on one address.
+	addrs := make(map[string]string)
+	for i := 1; i <= 3; i++ {
+		addrs[fmt.Sprintf("remote%d", i)] = "localhost:" + strconv.Itoa(freePort(t))
+	}
+
+	nodes := make(map[string]*RaftNode)
+	storages := make(map[string]*RaftStorage)
+	for id, addr := range addrs {
+		peers := make(map[string]string)
+		for peerID, peerAddr := range addrs {
+			if peerID != id {
+				peers[peerID] = peerAddr
+			}
+		}
+		store, err := storage.NewBadgerStorage(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cluster := newGlobalCluster()
+		node := NewRaftNode(id, addr, peers, store)
+		if err := cluster.RegisterNode(node); err != nil {
+			t.Fatal(err)
+		}
+		rs := NewRaftStorage(cluster, id)
+		server := rpc.NewServer(rs)
+		node.RegisterService(server.GRPCServer())
+		if err := node.Start(); err != nil {
+			t.Fatal(err)
+		}
+		nodes[id] = node
+		storages[id] = rs
+		go server.Start(addr)
+		t.Cleanup(func() {
+			server.Stop()
+			rs.Close()
+			node.Stop()
+			store.Close()
+		})
+	}
+
+	// Wait until a follower knows who the leader is
+	var follower string
+	deadline := time.Now().Add(5 * time.Second)
+	for follower == "" && time.Now().Before(deadline) {
+		for id, node := range nodes {
+			if leaderID, _, ok := node.Leader(); ok && leaderID != id {
+				follower = id
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if follower == "" {
+		t.Fatal("no follower learned of a leader")
+	}
+
+	if err := storages[follower].Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put through a follower failed: %v", err)
+	}
+
+	// A read through the follower gets its read index from the leader
+	// over gRPC, so it sees the write before the follower is told of it
+	if value, err := storages[follower].Get([]byte("key")); err != nil || string(value) != "value" {
+		t.Errorf("Get through a follower: Expected value, got %q (%v)", value, err)
+	}
+
+	// Followers apply the write once they hear it committed
+	for id, node := range nodes {
+		var value []byte
+		var err error
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+			if value, err = node.storage.Get([]byte("key")); err == nil {
+				break
+			}
+		}
+		if err != nil || string(value) != "value" {
+			t.Errorf("Node %s: Expected value, got %q (%v)", id, value, err)
+		}
+	}
+}
+
+func TestRaftStorage_LaggingFollowerForwardsGet(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	leader := waitForLeader(t, cluster)
+
+	var follower *RaftNode
+	for _, node := range cluster.GetAllNodes() {
+		if node != leader {
+			follower = node
+			break
+		}
+	}
+	followerStorage := NewRaftStorage(cluster, follower.GetID())
+
+	// Stop replicating to the follower, and keep it from starting an
+	// election, so it can never catch up to the read index
+	follower.mu.Lock()
+	follower.electionTimeout = time.Hour
+	follower.mu.Unlock()
+	leader.mu.Lock()
+	delete(leader.peers, follower.GetID())
+	leader.mu.Unlock()
+
+	if err := NewRaftStorage(cluster, leader.GetID()).Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	value, err := followerStorage.Get([]byte("key"))
+	if err != nil || string(value) != "value" {
+		t.Fatalf("Expected value from the leader, got %q (%v)", value, err)
+	}
+	if _, err := follower.storage.Get([]byte("key")); err == nil {
+		t.Error("Expected the follower not to have applied the write")
+	}
+
+	// Scans can't be forwarded, so the lagging follower refuses them
+	if _, err := followerStorage.Scan(nil, nil); !errors.Is(err, ErrTooStale) {
+		t.Errorf("Expected ErrTooStale from Scan on the lagging follower, got %v", err)
+	}
+}
+
+func TestRaftStorage_PutCtxStopsWaitingForLeader(t *testing.T) {
+	// A node that was never started can't elect a leader, so writes
+	// through it wait for one until forwardTimeout
+	cluster := newGlobalCluster()
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	peers := map[string]string{"node2": ":" + strconv.Itoa(freePort(t))}
+	if err := cluster.RegisterNode(NewRaftNode("node1", ":"+strconv.Itoa(freePort(t)), peers, store)); err != nil {
+		t.Fatal(err)
+	}
+	rs := NewRaftStorage(cluster, "node1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	err = rs.PutCtx(ctx, []byte("key"), []byte("value"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > forwardTimeout/2 {
+		t.Errorf("Expected PutCtx to return soon after cancel, took %v", elapsed)
+	}
+
+	// An expired context fails before looking for a leader at all
+	ctx, cancel = context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	time.Sleep(time.Millisecond)
+	if err := rs.DeleteCtx(ctx, []byte("key")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// lostReplyLeader is a leader's Storage service whose connection drops
+// after each Put reaches it
+type lostReplyLeader struct {
+	proto.UnimplementedStorageServer
+	puts atomic.Int32
+}
+
+func (l *lostReplyLeader) Put(ctx context.Context, req *proto.PutRequest) (*proto.PutResponse, error) {
+	l.puts.Add(1)
+	return nil, status.Error(codes.Unavailable, "connection lost")
+}
+
+func TestRaftStorage_ForwardDoesNotResendAfterReachingLeader(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := &lostReplyLeader{}
+	server := grpc.NewServer()
+	proto.RegisterStorageServer(server, leader)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	// A follower that knows of the leader only by address
+	store := storage.NewMemStorage()
+	defer store.Close()
+	cluster := newGlobalCluster()
+	node := NewRaftNode("follower", ":0", map[string]string{"leader": lis.Addr().String()}, store)
+	node.leaderID = "leader"
+	if err := cluster.RegisterNode(node); err != nil {
+		t.Fatal(err)
+	}
+	rs := NewRaftStorage(cluster, "follower")
+	defer rs.Close()
+
+	// The leader may have applied the write, so it isn't sent again
+	if err := rs.Put([]byte("key"), []byte("value")); status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected the lost reply to be reported, got %v", err)
+	}
+	if puts := leader.puts.Load(); puts != 1 {
+		t.Errorf("Expected the write sent once, got %d", puts)
+	}
+}
@@ -19,13 +19,18 @@ var once sync.Once
 // GetGlobalCluster returns the singleton global cluster instance
 func GetGlobalCluster() *GlobalCluster {
 	once.Do(func() {
-		globalCluster = &GlobalCluster{
-			nodes: make(map[string]*RaftNode),
-		}
+		globalCluster = newGlobalCluster()
 	})
 	return globalCluster
 }
 
+// newGlobalCluster creates an empty node registry
+func newGlobalCluster() *GlobalCluster {
+	return &GlobalCluster{
+		nodes: make(map[string]*RaftNode),
+	}
+}
+
 // RegisterNode registers a node with the global cluster
 func (gc *GlobalCluster) RegisterNode(node *RaftNode) error {
 	gc.mu.Lock()
@@ -40,16 +45,23 @@ func (gc *GlobalCluster) RegisterNode(node *RaftNode) error {
 	return nil
 }
 
-// UnregisterNode removes a node from the global cluster
-func (gc *GlobalCluster) UnregisterNode(nodeID string) {
+// UnregisterNode removes a node from the global cluster and stops it.
+// It is safe to call from several shutdown paths, concurrently or
+// repeatedly: only the call that actually removes the node stops it and
+// returns true, and every other call is a no-op returning false.
+func (gc *GlobalCluster) UnregisterNode(nodeID string) bool {
 	gc.mu.Lock()
-	defer gc.mu.Unlock()
+	node, exists := gc.nodes[nodeID]
+	delete(gc.nodes, nodeID)
+	gc.mu.Unlock()
 
-	if node, exists := gc.nodes[nodeID]; exists {
-		node.Stop()
-		delete(gc.nodes, nodeID)
-		log.Printf("Unregistered node %s from global cluster", nodeID)
+	if !exists {
+		return false
 	}
+
+	node.Stop()
+	log.Printf("Unregistered node %s from global cluster", nodeID)
+	return true
 }
 
 // GetNode returns a node by ID
@@ -105,6 +117,7 @@ func (gc *GlobalCluster) GetClusterInfo() map[string]interface{} {
 			"state":   state.String(),
 			"term":    term,
 			"leader":  node.IsLeader(),
+			"healthy": node.Health() == nil,
 		}
 	}
 
@@ -134,45 +147,75 @@ func (gc *GlobalCluster) StopAll() {
 	gc.nodes = make(map[string]*RaftNode)
 }
 
-// StartHeartbeatMonitor monitors the cluster and ensures only one leader
+// DefaultMonitorInterval is how often StartHeartbeatMonitor checks for
+// multiple leaders
+const DefaultMonitorInterval = 1 * time.Second
+
+// StartHeartbeatMonitor monitors the cluster and ensures only one leader,
+// checking every DefaultMonitorInterval
 func (gc *GlobalCluster) StartHeartbeatMonitor() {
+	gc.StartHeartbeatMonitorWithInterval(DefaultMonitorInterval)
+}
+
+// StartHeartbeatMonitorWithInterval is StartHeartbeatMonitor, checking
+// every interval. A non-positive interval uses DefaultMonitorInterval.
+func (gc *GlobalCluster) StartHeartbeatMonitorWithInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultMonitorInterval
+	}
 	go func() {
-		ticker := time.NewTicker(1 * time.Second)
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
 		for range ticker.C {
-			gc.mu.RLock()
-			leaders := make([]*RaftNode, 0)
-			for _, node := range gc.nodes {
-				if node.IsLeader() {
-					leaders = append(leaders, node)
-				}
-			}
-			gc.mu.RUnlock()
-
-			// If multiple leaders, step down all but the one with highest term
-			if len(leaders) > 1 {
-				log.Printf("WARNING: Multiple leaders detected (%d), resolving conflict", len(leaders))
-
-				// Find leader with highest term
-				var highestTermLeader *RaftNode
-				highestTerm := -1
-				for _, leader := range leaders {
-					_, term := leader.GetState()
-					if term > highestTerm {
-						highestTerm = term
-						highestTermLeader = leader
-					}
-				}
-
-				// Step down all other leaders
-				for _, leader := range leaders {
-					if leader != highestTermLeader {
-						leader.StepDown()
-						log.Printf("Stepped down leader %s", leader.GetID())
-					}
-				}
-			}
+			gc.resolveLeaders()
 		}
 	}()
 }
+
+// resolveLeaders steps down all but one leader if several claim
+// leadership. A leader of an older term steps down. Leaders sharing the
+// highest term, as can happen briefly during a split, keep the one with
+// the lowest node ID, so repeated checks always agree on the same node
+// instead of passing leadership back and forth.
+func (gc *GlobalCluster) resolveLeaders() {
+	gc.mu.RLock()
+	leaders := make([]*RaftNode, 0)
+	for _, node := range gc.nodes {
+		if node.IsLeader() {
+			leaders = append(leaders, node)
+		}
+	}
+	gc.mu.RUnlock()
+
+	if len(leaders) <= 1 {
+		return
+	}
+	log.Printf("WARNING: Multiple leaders detected (%d), resolving conflict", len(leaders))
+
+	// Find the leader to keep: highest term, then lowest ID
+	var keep *RaftNode
+	keepTerm := -1
+	terms := make(map[*RaftNode]int, len(leaders))
+	for _, leader := range leaders {
+		_, term := leader.GetState()
+		terms[leader] = term
+		if term > keepTerm || (term == keepTerm && leader.GetID() < keep.GetID()) {
+			keep, keepTerm = leader, term
+		}
+	}
+
+	for _, leader := range leaders {
+		if leader == keep {
+			continue
+		}
+		if terms[leader] < keepTerm {
+			log.Printf("Stepping down leader %s of term %d in favour of %s of term %d",
+				leader.GetID(), terms[leader], keep.GetID(), keepTerm)
+		} else {
+			log.Printf("Stepping down leader %s: %s shares term %d and has the lower ID",
+				leader.GetID(), keep.GetID(), keepTerm)
+		}
+		leader.StepDown()
+	}
+}
@@ -0,0 +1,96 @@
+package raft
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestGlobalCluster_UnregisterNodeIsIdempotent(t *testing.T) {
+	registry := newGlobalCluster()
+	node := NewRaftNode("node1", ":0", nil, nil)
+	if err := registry.RegisterNode(node); err != nil {
+		t.Fatal(err)
+	}
+
+	// Race several shutdown paths against each other
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	removed := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if registry.UnregisterNode("node1") {
+				mu.Lock()
+				removed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if removed != 1 {
+		t.Errorf("Expected exactly 1 effective removal, got %d", removed)
+	}
+	if node.GetContext().Err() == nil {
+		t.Error("Expected node to be stopped")
+	}
+	if _, err := registry.GetNode("node1"); err == nil {
+		t.Error("Expected node to be removed from the registry")
+	}
+
+	// Later calls, and stopping the node directly, are harmless no-ops
+	if registry.UnregisterNode("node1") {
+		t.Error("Expected second UnregisterNode to report no removal")
+	}
+	node.Stop()
+}
+
+// claimLeadership makes a stopped node believe it leads in term
+func claimLeadership(node *RaftNode, term int) {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	node.state = Leader
+	node.currentTerm = term
+	node.leaderID = node.id
+}
+
+func TestGlobalCluster_ResolveLeadersKeepsOneOnTie(t *testing.T) {
+	registry := newGlobalCluster()
+	for _, id := range []string{"node3", "node1", "node2"} {
+		if err := registry.RegisterNode(NewRaftNode(id, ":0", nil, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	node1, _ := registry.GetNode("node1")
+	node2, _ := registry.GetNode("node2")
+	node3, _ := registry.GetNode("node3")
+
+	// Two leaders share term 5 during a split
+	claimLeadership(node2, 5)
+	claimLeadership(node1, 5)
+	registry.resolveLeaders()
+
+	if !node1.IsLeader() || node2.IsLeader() {
+		t.Errorf("Expected node1, the lowest ID, to keep leadership; node1=%v node2=%v", node1.IsLeader(), node2.IsLeader())
+	}
+
+	// Another cycle leaves the decision alone
+	registry.resolveLeaders()
+	if !node1.IsLeader() {
+		t.Error("Expected node1 to still lead after another cycle")
+	}
+
+	// A higher term wins regardless of ID
+	claimLeadership(node3, 6)
+	registry.resolveLeaders()
+	leaders := 0
+	for _, node := range registry.GetAllNodes() {
+		if node.IsLeader() {
+			leaders++
+		}
+	}
+	if leaders != 1 || !node3.IsLeader() {
+		t.Errorf("Expected node3 of term 6 to be the only leader, got %d leaders", leaders)
+	}
+}
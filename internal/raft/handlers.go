@@ -2,8 +2,6 @@ package raft
 
 import (
 	"log"
-	"net"
-	"net/rpc"
 	"time"
 )
 
@@ -28,21 +26,28 @@ func (r *RaftRPC) RequestVote(req RequestVoteRequest, resp *RequestVoteResponse)
 
 	// If RPC request or response contains term T > currentTerm: set currentTerm = T, convert to follower
 	if req.Term > r.node.currentTerm {
-		r.node.currentTerm = req.Term
-		r.node.state = Follower
-		r.node.votedFor = ""
+		r.node.becomeFollower(req.Term)
 	}
 
 	// If votedFor is null or candidateId, and candidate's log is at least as up-to-date as receiver's log, grant vote
-	if (r.node.votedFor == "" || r.node.votedFor == req.CandidateID) && r.isLogUpToDate(req.LastLogIndex, req.LastLogTerm) {
+	grant := (r.node.votedFor == "" || r.node.votedFor == req.CandidateID) && r.isLogUpToDate(req.LastLogIndex, req.LastLogTerm)
+	if grant {
 		r.node.votedFor = req.CandidateID
+	}
+
+	// The vote must be durable before the candidate hears of it, or a
+	// restart could let this node vote twice in one term
+	if err := r.node.persistState(); err != nil {
+		return err
+	}
+
+	resp.Term = r.node.currentTerm
+	resp.VoteGranted = grant
+	if grant {
+		r.node.votesGranted++
 		r.node.lastHeartbeat = time.Now()
-		resp.Term = r.node.currentTerm
-		resp.VoteGranted = true
 		log.Printf("Node %s granted vote to %s", r.node.id, req.CandidateID)
 	} else {
-		resp.Term = r.node.currentTerm
-		resp.VoteGranted = false
 		log.Printf("Node %s denied vote to %s", r.node.id, req.CandidateID)
 	}
 
@@ -65,18 +70,33 @@ func (r *RaftRPC) AppendEntries(req AppendEntriesRequest, resp *AppendEntriesRes
 
 	// If RPC request or response contains term T > currentTerm: set currentTerm = T, convert to follower
 	if req.Term > r.node.currentTerm {
-		r.node.currentTerm = req.Term
-		r.node.state = Follower
-		r.node.votedFor = ""
+		r.node.becomeFollower(req.Term)
+	}
+	if err := r.node.persistState(); err != nil {
+		return err
 	}
 
 	// Update last heartbeat
+	r.node.leaderID = req.LeaderID
 	r.node.lastHeartbeat = time.Now()
+	r.node.lastLeaderContact = r.node.lastHeartbeat
 
-	// If this is a heartbeat (no entries), just return success
+	// If this is a heartbeat (no entries), only advance the commit index.
+	// Heartbeats carry no consistency check, but an entry from the leader's
+	// current term was written by that leader, so everything up to it is
+	// known to match the leader's log and is safe to commit.
 	if len(req.Entries) == 0 {
+		if req.LeaderCommit > r.node.commitIndex && r.node.getLastLogTerm() == req.Term {
+			if req.LeaderCommit < r.node.lastLogIndex() {
+				r.node.commitIndex = req.LeaderCommit
+			} else {
+				r.node.commitIndex = r.node.lastLogIndex()
+			}
+			r.node.applyCommittedEntries()
+		}
 		resp.Term = r.node.currentTerm
 		resp.Success = true
+		resp.LastLogIndex = r.node.lastLogIndex()
 		return nil
 	}
 
@@ -84,51 +104,59 @@ func (r *RaftRPC) AppendEntries(req AppendEntriesRequest, resp *AppendEntriesRes
 	if !r.logContainsEntry(req.PrevLogIndex, req.PrevLogTerm) {
 		resp.Term = r.node.currentTerm
 		resp.Success = false
+		resp.LastLogIndex = r.node.lastLogIndex()
 		return nil
 	}
 
-	// If an existing entry conflicts with a new one (same index but different terms), delete the existing entry and all that follow it
-	conflictIndex := -1
+	// Skip entries the log already holds, including those compacted into
+	// the snapshot. If an existing entry conflicts with a new one (same
+	// index but different terms), delete the existing entry and all that
+	// follow it, then append the new entries not already in the log.
 	for i, entry := range req.Entries {
-		logIndex := req.PrevLogIndex + 1 + i
-		if logIndex < len(r.node.log) && r.node.log[logIndex].Term != entry.Term {
-			conflictIndex = logIndex
-			break
+		index := req.PrevLogIndex + 1 + i
+		if index <= r.node.snapshotIndex {
+			continue
 		}
+		if index <= r.node.lastLogIndex() {
+			if r.node.termAt(index) == entry.Term {
+				continue
+			}
+			r.node.truncateLog(index - 1)
+		}
+		entry.Index = index
+		r.node.log = append(r.node.log, entry)
 	}
 
-	if conflictIndex != -1 {
-		// Truncate log from conflict index
-		r.node.log = r.node.log[:conflictIndex]
-	}
-
-	// Append any new entries not already in the log
-	for _, entry := range req.Entries {
-		entry.Index = len(r.node.log) + 1
-		r.node.log = append(r.node.log, entry)
+	// The leader counts this node as holding the entries once it replies
+	if err := r.node.persistState(); err != nil {
+		return err
 	}
 
-	// If leaderCommit > commitIndex, set commitIndex = min(leaderCommit, index of last new entry)
+	// If leaderCommit > commitIndex, set commitIndex = min(leaderCommit, index of last new entry).
+	// A delayed request may carry entries older than the commit index,
+	// which must not move it back.
 	if req.LeaderCommit > r.node.commitIndex {
-		lastNewEntryIndex := len(r.node.log)
-		if req.LeaderCommit < lastNewEntryIndex {
-			r.node.commitIndex = req.LeaderCommit
-		} else {
-			r.node.commitIndex = lastNewEntryIndex
+		commitIndex := req.PrevLogIndex + len(req.Entries)
+		if req.LeaderCommit < commitIndex {
+			commitIndex = req.LeaderCommit
+		}
+		if commitIndex > r.node.commitIndex {
+			r.node.commitIndex = commitIndex
 		}
 	}
 
 	// Apply committed entries
-	r.applyCommittedEntries()
+	r.node.applyCommittedEntries()
 
 	resp.Term = r.node.currentTerm
 	resp.Success = true
+	resp.LastLogIndex = r.node.lastLogIndex()
 	return nil
 }
 
 // isLogUpToDate checks if the candidate's log is at least as up-to-date as this node's log
 func (r *RaftRPC) isLogUpToDate(candidateLastIndex, candidateLastTerm int) bool {
-	lastIndex := len(r.node.log)
+	lastIndex := r.node.lastLogIndex()
 	lastTerm := r.node.getLastLogTerm()
 
 	// Raft determines which of two logs is more up-to-date by comparing the index and term of the last entries in the logs.
@@ -142,111 +170,28 @@ func (r *RaftRPC) logContainsEntry(index, term int) bool {
 	if index == 0 {
 		return true // Special case for empty log
 	}
-	if index > len(r.node.log) {
-		return false
+	if index < r.node.snapshotIndex {
+		return true // Compacted entries are committed, so every leader holds them
 	}
-	return r.node.log[index-1].Term == term
-}
-
-// applyCommittedEntries applies all committed entries to the state machine
-func (r *RaftRPC) applyCommittedEntries() {
-	for r.node.lastApplied < r.node.commitIndex {
-		r.node.lastApplied++
-		entry := r.node.log[r.node.lastApplied-1]
-
-		// Apply the command to the storage
-		switch string(entry.Command[:4]) { // First 4 bytes indicate operation
-		case "PUT ":
-			// Parse key-value from command
-			keyValue := entry.Command[4:]
-			// Find the separator (assuming it's a space)
-			spaceIndex := -1
-			for i, b := range keyValue {
-				if b == ' ' {
-					spaceIndex = i
-					break
-				}
-			}
-			if spaceIndex > 0 {
-				key := keyValue[:spaceIndex]
-				value := keyValue[spaceIndex+1:]
-				r.node.storage.Put(key, value)
-			}
-		case "DEL ":
-			key := entry.Command[4:]
-			r.node.storage.Delete(key)
-		}
+	if index > r.node.lastLogIndex() {
+		return false
 	}
+	return r.node.termAt(index) == term
 }
 
-// StartRPCServer starts the RPC server for this node
-func (n *RaftNode) StartRPCServer() error {
-	rpcServer := rpc.NewServer()
-	raftRPC := &RaftRPC{node: n}
+// TimeoutNow handles leadership transfer requests from the leader
+func (r *RaftRPC) TimeoutNow(req TimeoutNowRequest, resp *TimeoutNowResponse) error {
+	r.node.mu.RLock()
+	term := r.node.currentTerm
+	r.node.mu.RUnlock()
 
-	err := rpcServer.Register(raftRPC)
-	if err != nil {
-		return err
-	}
-
-	// Use a simpler address format
-	address := "localhost" + n.address
-	listener, err := net.Listen("tcp", address)
-	if err != nil {
-		return err
+	resp.Term = term
+	if req.Term < term {
+		return nil
 	}
 
-	log.Printf("Raft RPC server listening on %s", address)
-
-	go func() {
-		for {
-			conn, err := listener.Accept()
-			if err != nil {
-				select {
-				case <-n.ctx.Done():
-					return
-				default:
-					log.Printf("Failed to accept connection: %v", err)
-					continue
-				}
-			}
-			go rpcServer.ServeConn(conn)
-		}
-	}()
-
+	log.Printf("Node %s taking over leadership from %s", r.node.id, req.LeaderID)
+	go r.node.startElection()
+	resp.Success = true
 	return nil
 }
-
-// sendRequestVote sends a vote request to a peer
-func (n *RaftNode) sendRequestVote(peerAddr string, req RequestVoteRequest) (*RequestVoteResponse, error) {
-	client, err := rpc.Dial("tcp", peerAddr)
-	if err != nil {
-		return nil, err
-	}
-	defer client.Close()
-
-	var resp RequestVoteResponse
-	err = client.Call("RaftRPC.RequestVote", req, &resp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &resp, nil
-}
-
-// sendAppendEntries sends an append entries request to a peer
-func (n *RaftNode) sendAppendEntries(peerAddr string, req AppendEntriesRequest) (*AppendEntriesResponse, error) {
-	client, err := rpc.Dial("tcp", peerAddr)
-	if err != nil {
-		return nil, err
-	}
-	defer client.Close()
-
-	var resp AppendEntriesResponse
-	err = client.Call("RaftRPC.AppendEntries", req, &resp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &resp, nil
-}
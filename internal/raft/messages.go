@@ -26,16 +26,52 @@ type AppendEntriesRequest struct {
 
 // AppendEntriesResponse represents an append entries RPC response
 type AppendEntriesResponse struct {
+	Term         int  // currentTerm, for leader to update itself
+	Success      bool // true if follower contained entry matching prevLogIndex and prevLogTerm
+	LastLogIndex int  // index of follower's last log entry, so the leader can bring it up to date
+}
+
+// TimeoutNowRequest asks a follower to start an election immediately,
+// as part of a leadership transfer
+type TimeoutNowRequest struct {
+	Term     int    // leader's term
+	LeaderID string // leader handing over leadership
+}
+
+// TimeoutNowResponse represents a timeout now RPC response
+type TimeoutNowResponse struct {
 	Term    int  // currentTerm, for leader to update itself
-	Success bool // true if follower contained entry matching prevLogIndex and prevLogTerm
+	Success bool // true if the follower started an election
+}
+
+// InstallSnapshotRequest carries one chunk of a state-machine snapshot
+type InstallSnapshotRequest struct {
+	Term              int    // leader's term
+	LeaderID          string // so follower can redirect clients
+	LastIncludedIndex int    // the snapshot replaces all entries up to this index
+	LastIncludedTerm  int    // term of lastIncludedIndex
+	Offset            int64  // byte offset of Data within the snapshot
+	Data              []byte // the chunk
+	Done              bool   // true if this is the last chunk
+}
+
+// InstallSnapshotResponse represents an install snapshot RPC response
+type InstallSnapshotResponse struct {
+	Term   int   // currentTerm, for leader to update itself
+	Stored int64 // bytes of the snapshot the follower holds; the leader resumes from here
 }
 
 // ClientRequest represents a client request to the Raft cluster
 type ClientRequest struct {
-	Operation string // "put", "get", "delete"
+	Operation string // "put", "delete", "batch" or "noop"
 	Key       []byte
 	Value     []byte
 	Response  chan ClientResponse
+
+	// Optional client session: a write with the same ClientID and a Seq
+	// no higher than one already applied is not applied again
+	ClientID string
+	Seq      uint64
 }
 
 // ClientResponse represents a response to a client request
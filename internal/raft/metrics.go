@@ -0,0 +1,89 @@
+package raft
+
+// Metrics is a snapshot of a node's Raft state and activity counters, for
+// monitoring election churn and replication health
+type Metrics struct {
+	ID          string
+	State       NodeState
+	CurrentTerm int
+
+	CommitIndex int
+	LastApplied int
+
+	// Index of the last log entry, counting the entries compacted into
+	// the snapshot
+	LogLength int
+
+	// Highest entry known to be replicated on each peer. Only a leader
+	// tracks it; on other nodes it is nil.
+	MatchIndex map[string]int
+
+	// Counters since the node was created: elections this node started,
+	// votes it granted to candidates, and heartbeats it sent as leader,
+	// one per peer per round
+	ElectionsStarted uint64
+	VotesGranted     uint64
+	HeartbeatsSent   uint64
+}
+
+// Metrics returns a snapshot of the node's state and counters
+func (n *RaftNode) Metrics() Metrics {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	m := Metrics{
+		ID:               n.id,
+		State:            n.state,
+		CurrentTerm:      n.currentTerm,
+		CommitIndex:      n.commitIndex,
+		LastApplied:      n.lastApplied,
+		LogLength:        n.lastLogIndex(),
+		ElectionsStarted: n.electionsStarted,
+		VotesGranted:     n.votesGranted,
+		HeartbeatsSent:   n.heartbeatsSent,
+	}
+	if n.state == Leader {
+		m.MatchIndex = make(map[string]int, len(n.peers))
+		for peerID := range n.peers {
+			m.MatchIndex[peerID] = n.matchIndex[peerID]
+		}
+	}
+	return m
+}
+
+// ClusterMetrics aggregates the metrics of every node in a GlobalCluster
+type ClusterMetrics struct {
+	Nodes map[string]Metrics
+
+	// The leader with the highest term, or "" if there is none
+	Leader string
+
+	// The highest term of any node
+	MaxTerm int
+
+	// Sums of the nodes' counters
+	ElectionsStarted uint64
+	VotesGranted     uint64
+	HeartbeatsSent   uint64
+}
+
+// Metrics returns the metrics of every registered node, with their totals
+func (gc *GlobalCluster) Metrics() ClusterMetrics {
+	cm := ClusterMetrics{Nodes: make(map[string]Metrics)}
+	leaderTerm := -1
+	for id, node := range gc.GetAllNodes() {
+		m := node.Metrics()
+		cm.Nodes[id] = m
+
+		if m.CurrentTerm > cm.MaxTerm {
+			cm.MaxTerm = m.CurrentTerm
+		}
+		if m.State == Leader && m.CurrentTerm > leaderTerm {
+			cm.Leader, leaderTerm = id, m.CurrentTerm
+		}
+		cm.ElectionsStarted += m.ElectionsStarted
+		cm.VotesGranted += m.VotesGranted
+		cm.HeartbeatsSent += m.HeartbeatsSent
+	}
+	return cm
+}
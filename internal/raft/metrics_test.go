@@ -0,0 +1,58 @@
+package raft
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetrics_CountElectionsAndVotes(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	leader := waitForLeader(t, cluster)
+
+	if err := leader.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	m := leader.Metrics()
+	if m.State != Leader || m.ElectionsStarted == 0 {
+		t.Errorf("Expected a leader that started an election, got %+v", m)
+	}
+	if len(m.MatchIndex) != 2 {
+		t.Errorf("Expected the leader to report 2 peers' match index, got %v", m.MatchIndex)
+	}
+	if m.LogLength == 0 || m.CommitIndex == 0 {
+		t.Errorf("Expected the write in the leader's log, got %+v", m)
+	}
+
+	// Handing over leadership makes another node run an election
+	before := cluster.Metrics()
+	if before.Leader != leader.GetID() || before.VotesGranted == 0 {
+		t.Errorf("Expected leader %s elected by granted votes, got %+v", leader.GetID(), before)
+	}
+	if err := leader.TransferLeadership(2 * time.Second); err != nil {
+		t.Fatalf("TransferLeadership failed: %v", err)
+	}
+	newLeader := waitForLeader(t, cluster)
+
+	after := cluster.Metrics()
+	if after.ElectionsStarted <= before.ElectionsStarted {
+		t.Errorf("Expected elections started to grow past %d, got %d", before.ElectionsStarted, after.ElectionsStarted)
+	}
+	if after.VotesGranted <= before.VotesGranted {
+		t.Errorf("Expected votes granted to grow past %d, got %d", before.VotesGranted, after.VotesGranted)
+	}
+	if after.MaxTerm <= before.MaxTerm {
+		t.Errorf("Expected the term to advance past %d, got %d", before.MaxTerm, after.MaxTerm)
+	}
+	if nm := newLeader.Metrics(); nm.ElectionsStarted == 0 {
+		t.Errorf("Expected new leader %s to have started an election", newLeader.GetID())
+	}
+	if leader.Metrics().MatchIndex != nil {
+		t.Error("Expected a follower to report no match index")
+	}
+
+	// The new leader heartbeats its peers
+	time.Sleep(200 * time.Millisecond)
+	if newLeader.Metrics().HeartbeatsSent == 0 {
+		t.Error("Expected the new leader to have sent heartbeats")
+	}
+}
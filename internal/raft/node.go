@@ -2,11 +2,15 @@ package raft
 
 import (
 	"context"
+	"fmt"
+	"hash/fnv"
 	"log"
 	"math/rand"
 	"sync"
 	"time"
 
+	"google.golang.org/grpc"
+
 	"godatabase/internal/storage"
 )
 
@@ -61,6 +65,9 @@ type RaftNode struct {
 	// Node state
 	state NodeState
 
+	// The leader of the current term, if this node has heard from it
+	leaderID string
+
 	// Cluster configuration
 	peers map[string]string // peer_id -> address
 
@@ -76,23 +83,177 @@ type RaftNode struct {
 	// Mutex for thread safety
 	mu sync.RWMutex
 
-	// Election timeout
-	electionTimeout time.Duration
-	lastHeartbeat   time.Time
-
-	// Heartbeat interval for leaders
+	// Election timeout, redrawn from [electionTimeoutMin, electionTimeoutMax)
+	// whenever the timer resets
+	electionTimeout    time.Duration
+	electionTimeoutMin time.Duration
+	electionTimeoutMax time.Duration
+	lastHeartbeat      time.Time
+
+	// Last time an AppendEntries from a current leader was accepted
+	lastLeaderContact time.Time
+
+	// Per-node random source for election timeouts, guarded by mu
+	rand *rand.Rand
+
+	// Encoding used when persisting log entries
+	codec LogCodec
+
+	// Where the term, vote and log are persisted, nil to keep them only in
+	// memory. persistedTerm and persistedVote were last saved, the first
+	// persistedLog log entries are saved unchanged, and the store holds
+	// storedLog entries. Guarded by mu.
+	stable        StableStore
+	persistedTerm int
+	persistedVote string
+	persistedLog  int
+	storedLog     int
+
+	// Number of goroutines applying committed entries, guarded by mu
+	applyWorkers int
+
+	// Most client writes appended and replicated together, guarded by mu
+	maxInFlight int
+
+	// Why the last apply failed, nil while applies succeed. A node whose
+	// state machine fails to apply an entry stops applying and serving
+	// reads, and retries until the storage recovers. Guarded by mu.
+	applyErr      error
+	applyRetrying bool
+
+	// Client sessions, for applying retried writes once, and the entries
+	// of the current apply that repeat an applied write. Guarded by mu.
+	sessions  *sessionTable
+	applySkip map[int]bool
+
+	// Told about the writes of every applied entry, see ObserveApplied.
+	// Guarded by mu.
+	observers []applyObserver
+
+	// Heartbeat interval for leaders, guarded by mu
 	heartbeatInterval time.Duration
 
+	// Snapshot transfer settings for leaders: chunk size in bytes, and the
+	// most bytes per second to send to one follower (0 means unlimited)
+	snapshotChunkSize int
+	snapshotRate      int
+
+	// Follower-side snapshot state: the snapshot being received, and the
+	// last one received in full. Guarded by mu.
+	incomingSnapshot *snapshot
+	receivedSnapshot *snapshot
+
+	// Log compaction: the log holds only the entries after snapshotIndex,
+	// whose term was snapshotTerm, and lastSnapshot is the state-machine
+	// image that replaced them. A node compacts once snapshotThreshold
+	// applied entries build up after the snapshot; 0 never compacts.
+	// catchingUp marks the followers a leader is bringing up to date.
+	// Guarded by mu.
+	snapshotIndex     int
+	snapshotTerm      int
+	lastSnapshot      *snapshot
+	snapshotThreshold int
+	catchingUp        map[string]bool
+
+	// Signals the heartbeat loop that the commit index advanced, so
+	// followers hear about it without waiting for the next heartbeat
+	commitNotify chan struct{}
+
+	// Leader-side quorum tracking: when each peer last answered an
+	// AppendEntries, and when this node became leader. A leader that hasn't
+	// heard from a majority within quorumTimeout rejects new client writes.
+	peerContact   map[string]time.Time
+	leaderSince   time.Time
+	quorumTimeout time.Duration
+
+	// Activity counters reported by Metrics, guarded by mu
+	electionsStarted uint64
+	votesGranted     uint64
+	heartbeatsSent   uint64
+
+	// Serializes writes made through RaftStorage on this node, so a
+	// conditional write compares and submits without another write in
+	// between, whichever RaftStorage it came through
+	writeMu sync.Mutex
+
+	// Server started by StartRPCServer, stopped when the node stops
+	rpcServer *grpc.Server
+
+	// Connections to peers, reused across RPCs and closed when the node
+	// stops, keyed by address
+	connMu sync.Mutex
+	conns  map[string]*grpc.ClientConn
+
 	// Context for cancellation
 	ctx    context.Context
 	cancel context.CancelFunc
 }
 
-// NewRaftNode creates a new Raft node
+// RaftConfig holds a node's timing settings. Clusters spread over
+// high-latency links need longer timeouts than the defaults, so that
+// heartbeats arrive before followers give up on the leader.
+type RaftConfig struct {
+	// A follower that hears nothing from a leader for a random duration
+	// in [ElectionTimeoutMin, ElectionTimeoutMax) starts an election
+	ElectionTimeoutMin time.Duration
+	ElectionTimeoutMax time.Duration
+
+	// How often a leader sends heartbeats to its followers
+	HeartbeatInterval time.Duration
+}
+
+// DefaultRaftConfig returns timing settings suitable for a cluster on a
+// local network
+func DefaultRaftConfig() RaftConfig {
+	return RaftConfig{
+		ElectionTimeoutMin: 150 * time.Millisecond,
+		ElectionTimeoutMax: 300 * time.Millisecond,
+		HeartbeatInterval:  50 * time.Millisecond,
+	}
+}
+
+// Validate reports whether the settings can keep a leader in place: the
+// election timeout range must be non-empty, and heartbeats must be sent
+// more often than the shortest election timeout.
+func (c RaftConfig) Validate() error {
+	if c.ElectionTimeoutMin <= 0 || c.ElectionTimeoutMin >= c.ElectionTimeoutMax {
+		return fmt.Errorf("invalid election timeout range: [%v, %v)", c.ElectionTimeoutMin, c.ElectionTimeoutMax)
+	}
+	if c.HeartbeatInterval <= 0 || c.HeartbeatInterval >= c.ElectionTimeoutMin {
+		return fmt.Errorf("heartbeat interval %v must be positive and below the minimum election timeout %v", c.HeartbeatInterval, c.ElectionTimeoutMin)
+	}
+	return nil
+}
+
+// NewRaftNode creates a new Raft node with the default timing settings.
+// Its election timeouts are randomized from a source seeded by the node ID.
 func NewRaftNode(id, address string, peers map[string]string, storage storage.Storage) *RaftNode {
+	return NewRaftNodeWithSeed(id, address, peers, storage, seedFromID(id))
+}
+
+// NewRaftNodeWithConfig creates a new Raft node with the given timing
+// settings, or returns an error if they are invalid.
+// Its election timeouts are randomized from a source seeded by the node ID.
+func NewRaftNodeWithConfig(id, address string, peers map[string]string, storage storage.Storage, cfg RaftConfig) (*RaftNode, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return newRaftNode(id, address, peers, storage, seedFromID(id), cfg), nil
+}
+
+// NewRaftNodeWithSeed creates a new Raft node whose election timeouts are
+// drawn from a random source with the given seed, so tests can reproduce
+// election sequences.
+func NewRaftNodeWithSeed(id, address string, peers map[string]string, storage storage.Storage, seed int64) *RaftNode {
+	return newRaftNode(id, address, peers, storage, seed, DefaultRaftConfig())
+}
+
+// newRaftNode creates a new Raft node from settings that have already
+// been validated
+func newRaftNode(id, address string, peers map[string]string, storage storage.Storage, seed int64, cfg RaftConfig) *RaftNode {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &RaftNode{
+	n := &RaftNode{
 		id:                id,
 		address:           address,
 		peers:             peers,
@@ -109,17 +270,70 @@ func NewRaftNode(id, address string, peers map[string]string, storage storage.St
 		appendEntriesChan: make(chan AppendEntriesRequest, 100),
 		clientRequestChan: make(chan ClientRequest, 100),
 		stopChan:          make(chan struct{}),
-		electionTimeout:   time.Duration(150+rand.Intn(150)) * time.Millisecond, // 150-300ms
-		heartbeatInterval: 50 * time.Millisecond,
+		rand:              rand.New(rand.NewSource(seed)),
+		codec:             BinaryLogCodec{},
+		applyWorkers:      1,
+		maxInFlight:       defaultMaxInFlight,
+		sessions:          newSessionTable(),
+		heartbeatInterval: cfg.HeartbeatInterval,
+		commitNotify:      make(chan struct{}, 1),
+		snapshotChunkSize: defaultSnapshotChunkSize,
+		snapshotRate:      defaultSnapshotRate,
+		peerContact:       make(map[string]time.Time),
+		catchingUp:        make(map[string]bool),
+		conns:             make(map[string]*grpc.ClientConn),
+		quorumTimeout:     500 * time.Millisecond,
 		ctx:               ctx,
 		cancel:            cancel,
+
+		electionTimeoutMin: cfg.ElectionTimeoutMin,
+		electionTimeoutMax: cfg.ElectionTimeoutMax,
 	}
+	n.electionTimeout = n.randomElectionTimeout()
+	return n
+}
+
+// seedFromID derives a random seed from a node ID so nodes in the same
+// process get distinct, reproducible election timeouts.
+func seedFromID(id string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	return int64(h.Sum64())
+}
+
+// randomElectionTimeout returns a new election timeout in the node's
+// configured range.
+// The caller must hold n.mu unless the node hasn't started yet.
+func (n *RaftNode) randomElectionTimeout() time.Duration {
+	spread := n.electionTimeoutMax - n.electionTimeoutMin
+	return n.electionTimeoutMin + time.Duration(n.rand.Int63n(int64(spread)))
+}
+
+// SetLogCodec replaces the codec used to persist log entries.
+// It must be called before the node starts.
+func (n *RaftNode) SetLogCodec(codec LogCodec) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.codec = codec
+}
+
+// LogCodec returns the codec used to persist log entries
+func (n *RaftNode) LogCodec() LogCodec {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.codec
 }
 
 // Start starts the Raft node
 func (n *RaftNode) Start() error {
 	log.Printf("Starting Raft node %s on %s", n.id, n.address)
 
+	// A node waits a full election timeout before its first election, so
+	// it can hear from a leader that is already running
+	n.mu.Lock()
+	n.lastHeartbeat = time.Now()
+	n.mu.Unlock()
+
 	// Start the main event loop
 	go n.run()
 
@@ -135,9 +349,8 @@ func (n *RaftNode) Start() error {
 // Stop stops the Raft node
 func (n *RaftNode) Stop() {
 	n.mu.Lock()
-	defer n.mu.Unlock()
-
 	if n.ctx.Err() != nil {
+		n.mu.Unlock()
 		return // Already stopped
 	}
 
@@ -150,6 +363,11 @@ func (n *RaftNode) Stop() {
 	default:
 		close(n.stopChan)
 	}
+	n.mu.Unlock()
+
+	// The RPC server's handlers take n.mu, so it is stopped without it
+	n.stopRPCServer()
+	n.closeConns()
 }
 
 // run is the main event loop
@@ -163,7 +381,7 @@ func (n *RaftNode) run() {
 		case req := <-n.appendEntriesChan:
 			n.handleAppendEntries(req)
 		case req := <-n.clientRequestChan:
-			n.handleClientRequest(req)
+			n.handleClientRequests(n.collectClientRequests(req))
 		}
 	}
 }
@@ -178,10 +396,10 @@ func (n *RaftNode) electionTimer() {
 			n.mu.Lock()
 			state := n.state
 			lastHeartbeat := n.lastHeartbeat
+			timeout := n.electionTimeout
 			n.mu.Unlock()
 
 			if state != Leader {
-				timeout := n.electionTimeout
 				if time.Since(lastHeartbeat) > timeout {
 					n.startElection()
 				}
@@ -192,27 +410,45 @@ func (n *RaftNode) electionTimer() {
 	}
 }
 
-// heartbeatTimer sends heartbeats if this node is the leader
+// heartbeatTimer sends heartbeats if this node is the leader. Besides the
+// regular interval, it sends one as soon as the commit index advances:
+// heartbeats carry the leader's commit index, and followers only apply
+// entries once they learn they are committed.
 func (n *RaftNode) heartbeatTimer() {
-	ticker := time.NewTicker(n.heartbeatInterval)
-	defer ticker.Stop()
-
 	for {
+		n.mu.RLock()
+		interval := n.heartbeatInterval
+		n.mu.RUnlock()
+		timer := time.NewTimer(interval)
+
 		select {
 		case <-n.ctx.Done():
+			timer.Stop()
 			return
-		case <-ticker.C:
-			n.mu.RLock()
-			state := n.state
-			n.mu.RUnlock()
+		case <-timer.C:
+		case <-n.commitNotify:
+			timer.Stop()
+		}
 
-			if state == Leader {
-				n.sendHeartbeats()
-			}
+		n.mu.RLock()
+		state := n.state
+		n.mu.RUnlock()
+
+		if state == Leader {
+			n.sendHeartbeats()
 		}
 	}
 }
 
+// notifyCommit asks the heartbeat loop to send the current commit index to
+// followers now. Notifications made while one is pending are merged into it.
+func (n *RaftNode) notifyCommit() {
+	select {
+	case n.commitNotify <- struct{}{}:
+	default:
+	}
+}
+
 // startElection starts a new election
 func (n *RaftNode) startElection() {
 	n.mu.Lock()
@@ -224,47 +460,91 @@ func (n *RaftNode) startElection() {
 	n.state = Candidate
 	n.currentTerm++
 	n.votedFor = n.id
+	n.leaderID = ""
 	n.lastHeartbeat = time.Now()
+	n.electionsStarted++
 
 	// Reset election timeout
-	n.electionTimeout = time.Duration(150+rand.Intn(150)) * time.Millisecond
+	n.electionTimeout = n.randomElectionTimeout()
 
-	// Request votes from all peers
-	votes := 1 // Vote for self
-	totalVotes := len(n.peers) + 1
+	// Persist the new term and the vote for self before asking for votes;
+	// if that fails, the next timeout starts another election
+	if err := n.persistState(); err != nil {
+		log.Printf("ERROR: node %s: %v", n.id, err)
+		return
+	}
 
+	// Build the request under the lock, so every vote is asked for and
+	// counted against this term alone
+	term := n.currentTerm
+	req := RequestVoteRequest{
+		Term:         term,
+		CandidateID:  n.id,
+		LastLogIndex: n.lastLogIndex(),
+		LastLogTerm:  n.getLastLogTerm(),
+	}
+	needed := (len(n.peers)+1)/2 + 1
+	if needed == 1 {
+		// A node without peers is a majority on its own
+		n.becomeLeader()
+		return
+	}
+
+	// Request votes from all peers
+	results := make(chan *RequestVoteResponse, len(n.peers))
 	for peerID, peerAddr := range n.peers {
 		go func(id, addr string) {
-			req := RequestVoteRequest{
-				Term:         n.currentTerm,
-				CandidateID:  n.id,
-				LastLogIndex: len(n.log),
-				LastLogTerm:  n.getLastLogTerm(),
-			}
-
 			resp, err := n.sendRequestVote(addr, req)
 			if err != nil {
 				log.Printf("Failed to send vote request to %s: %v", id, err)
-				return
+				resp = nil
 			}
+			results <- resp
+		}(peerID, peerAddr)
+	}
+	go n.countVotes(term, needed, len(n.peers), results)
+}
 
-			n.mu.Lock()
-			defer n.mu.Unlock()
+// countVotes tallies the responses to an election for term as they arrive
+// and makes this node leader once needed votes, counting its own, are
+// granted. It is the only reader of results, so the node becomes leader at
+// most once per election, and it stops counting as soon as the node has
+// moved on from that election.
+func (n *RaftNode) countVotes(term, needed, pending int, results <-chan *RequestVoteResponse) {
+	votes := 1 // Vote for self
+	for ; pending > 0; pending-- {
+		var resp *RequestVoteResponse
+		select {
+		case resp = <-results:
+		case <-n.ctx.Done():
+			return
+		}
+		if resp == nil {
+			continue
+		}
 
-			if resp.Term > n.currentTerm {
-				n.currentTerm = resp.Term
-				n.state = Follower
-				n.votedFor = ""
+		n.mu.Lock()
+		if resp.Term > n.currentTerm {
+			n.becomeFollower(resp.Term)
+			n.persistOrLog()
+			n.mu.Unlock()
+			return
+		}
+		if n.currentTerm != term || n.state != Candidate {
+			// A later election, a new leader or a step-down superseded
+			// this one; its votes no longer count
+			n.mu.Unlock()
+			return
+		}
+		if resp.VoteGranted && resp.Term == term {
+			votes++
+			if votes >= needed {
+				n.becomeLeader()
+				n.mu.Unlock()
 				return
 			}
-
-			if resp.VoteGranted {
-				votes++
-				if votes > totalVotes/2 {
-					n.becomeLeader()
-				}
-			}
-		}(peerID, peerAddr)
+		}
+		n.mu.Unlock()
 	}
 }
 
@@ -273,16 +553,20 @@ func (n *RaftNode) becomeLeader() {
 	log.Printf("Node %s became leader for term %d", n.id, n.currentTerm)
 
 	n.state = Leader
+	n.leaderID = n.id
 	n.lastHeartbeat = time.Now()
+	n.leaderSince = n.lastHeartbeat
+	n.peerContact = make(map[string]time.Time)
 
 	// Initialize nextIndex and matchIndex for all peers
 	for peerID := range n.peers {
-		n.nextIndex[peerID] = len(n.log) + 1
+		n.nextIndex[peerID] = n.lastLogIndex() + 1
 		n.matchIndex[peerID] = 0
 	}
 
-	// Send initial heartbeat
-	n.sendHeartbeats()
+	// Send initial heartbeat. The caller holds n.mu, and sendHeartbeats
+	// takes it again, so it must run on its own goroutine.
+	go n.sendHeartbeats()
 }
 
 // StepDown forces this node to step down from leader role
@@ -294,19 +578,73 @@ func (n *RaftNode) StepDown() {
 		log.Printf("Node %s stepping down from leader role", n.id)
 		n.state = Follower
 		n.votedFor = ""
+		n.leaderID = ""
 		n.lastHeartbeat = time.Now()
+		n.persistOrLog()
 	}
 }
 
+// TransferLeadership hands leadership to the most up-to-date peer. The
+// leader steps down, tells that peer to start an election right away, and
+// waits until a new leader has contacted it or timeout elapses.
+func (n *RaftNode) TransferLeadership(timeout time.Duration) error {
+	n.mu.Lock()
+	if n.state != Leader {
+		n.mu.Unlock()
+		return fmt.Errorf("not the leader")
+	}
+
+	target, best := "", -1
+	for peerID := range n.peers {
+		if n.matchIndex[peerID] > best {
+			target, best = peerID, n.matchIndex[peerID]
+		}
+	}
+	if target == "" {
+		n.mu.Unlock()
+		return fmt.Errorf("no peer to transfer leadership to")
+	}
+	addr := n.peers[target]
+	term := n.currentTerm
+	n.mu.Unlock()
+
+	// Stop accepting writes before handing over
+	start := time.Now()
+	n.StepDown()
+
+	resp, err := n.sendTimeoutNow(addr, TimeoutNowRequest{Term: term, LeaderID: n.id})
+	if err != nil {
+		return fmt.Errorf("failed to contact %s: %v", target, err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s refused leadership in term %d", target, resp.Term)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		n.mu.RLock()
+		handedOver := n.currentTerm > term && n.lastLeaderContact.After(start)
+		n.mu.RUnlock()
+		if handedOver {
+			log.Printf("Node %s transferred leadership to %s", n.id, target)
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Errorf("leadership transfer to %s timed out", target)
+}
+
 // sendHeartbeats sends heartbeat messages to all peers
 func (n *RaftNode) sendHeartbeats() {
-	n.mu.RLock()
+	n.mu.Lock()
 	term := n.currentTerm
+	commitIndex := n.commitIndex
 	peers := make(map[string]string)
 	for k, v := range n.peers {
 		peers[k] = v
 	}
-	n.mu.RUnlock()
+	n.heartbeatsSent += uint64(len(peers))
+	n.mu.Unlock()
 
 	for peerID, peerAddr := range peers {
 		go func(id, addr string) {
@@ -316,7 +654,7 @@ func (n *RaftNode) sendHeartbeats() {
 				PrevLogIndex: 0,
 				PrevLogTerm:  0,
 				Entries:      []LogEntry{},
-				LeaderCommit: n.commitIndex,
+				LeaderCommit: commitIndex,
 			}
 
 			resp, err := n.sendAppendEntries(addr, req)
@@ -328,21 +666,55 @@ func (n *RaftNode) sendHeartbeats() {
 			n.mu.Lock()
 			defer n.mu.Unlock()
 
+			n.peerContact[id] = time.Now()
+
 			if resp.Term > n.currentTerm {
-				n.currentTerm = resp.Term
-				n.state = Follower
-				n.votedFor = ""
+				n.becomeFollower(resp.Term)
+				n.persistOrLog()
+				return
+			}
+
+			// A follower missing committed entries won't be sent them
+			// again by replication, so bring it up to date
+			if resp.LastLogIndex < req.LeaderCommit && n.state == Leader && !n.catchingUp[id] {
+				n.catchingUp[id] = true
+				go n.catchUp(id, addr, resp.LastLogIndex)
 			}
 		}(peerID, peerAddr)
 	}
 }
 
+// becomeFollower moves this node to a newer term as a follower, with no
+// vote cast and no leader known yet. The caller must hold n.mu.
+func (n *RaftNode) becomeFollower(term int) {
+	n.currentTerm = term
+	n.state = Follower
+	n.votedFor = ""
+	n.leaderID = ""
+}
+
 // getLastLogTerm returns the term of the last log entry
 func (n *RaftNode) getLastLogTerm() int {
-	if len(n.log) == 0 {
+	return n.termAt(n.lastLogIndex())
+}
+
+// lastLogIndex returns the index of the last log entry, counting the
+// entries compacted into the snapshot. The caller must hold n.mu.
+func (n *RaftNode) lastLogIndex() int {
+	return n.snapshotIndex + len(n.log)
+}
+
+// termAt returns the term of the entry at index, or 0 if the log has no
+// such entry. Of the compacted entries only the last one's term is kept.
+// The caller must hold n.mu.
+func (n *RaftNode) termAt(index int) int {
+	if index == n.snapshotIndex {
+		return n.snapshotTerm
+	}
+	if index < n.snapshotIndex || index > n.lastLogIndex() {
 		return 0
 	}
-	return n.log[len(n.log)-1].Term
+	return n.log[index-n.snapshotIndex-1].Term
 }
 
 // GetState returns the current state of the node
@@ -359,6 +731,22 @@ func (n *RaftNode) IsLeader() bool {
 	return n.state == Leader
 }
 
+// Leader returns the ID and RPC address of the leader this node last
+// heard from in its current term. ok is false if it hasn't heard from one.
+func (n *RaftNode) Leader() (id, address string, ok bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	switch n.leaderID {
+	case "":
+		return "", "", false
+	case n.id:
+		return n.id, n.address, true
+	}
+	address, ok = n.peers[n.leaderID]
+	return n.leaderID, address, ok
+}
+
 // GetAddress returns the address of this node
 func (n *RaftNode) GetAddress() string {
 	return n.address
@@ -369,6 +757,102 @@ func (n *RaftNode) GetID() string {
 	return n.id
 }
 
+// CommitIndex returns the highest log index known to be committed
+func (n *RaftNode) CommitIndex() int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.commitIndex
+}
+
+// SetQuorumTimeout sets how long a leader keeps accepting client writes
+// without hearing from a majority of the cluster
+func (n *RaftNode) SetQuorumTimeout(timeout time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.quorumTimeout = timeout
+}
+
+// hasQuorum reports whether a majority of the cluster, counting this node,
+// answered within quorumTimeout. A new leader is given one quorumTimeout
+// of grace, since winning the election already proved majority contact.
+// The caller must hold n.mu.
+func (n *RaftNode) hasQuorum() bool {
+	now := time.Now()
+	if now.Sub(n.leaderSince) < n.quorumTimeout {
+		return true
+	}
+
+	reachable := 1 // Count self
+	for peerID := range n.peers {
+		if now.Sub(n.peerContact[peerID]) < n.quorumTimeout {
+			reachable++
+		}
+	}
+	return reachable > (len(n.peers)+1)/2
+}
+
+// UncommittedEntries returns the number of log entries not yet committed
+func (n *RaftNode) UncommittedEntries() int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.lastLogIndex() - n.commitIndex
+}
+
+// LastApplied returns the highest log index applied to the state machine
+func (n *RaftNode) LastApplied() int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.lastApplied
+}
+
+// LastLeaderContact returns when this node last accepted an AppendEntries
+// from a leader. It is the zero time if no leader has contacted it yet.
+func (n *RaftNode) LastLeaderContact() time.Time {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.lastLeaderContact
+}
+
+// lastKnownLeader returns when this node last knew the cluster had a
+// leader: when it last accepted an AppendEntries, or, for a node that has
+// led, when a peer last answered it
+func (n *RaftNode) lastKnownLeader() time.Time {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	latest := n.lastLeaderContact
+	for _, contact := range n.peerContact {
+		if contact.After(latest) {
+			latest = contact
+		}
+	}
+	return latest
+}
+
+// WaitForApplied blocks until the state machine has applied every entry up
+// to and including index, or returns an error once timeout elapses.
+func (n *RaftNode) WaitForApplied(index int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		n.mu.RLock()
+		applied := n.lastApplied
+		n.mu.RUnlock()
+
+		if applied >= index {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("node %s applied index %d, waiting for %d", n.id, applied, index)
+		}
+
+		select {
+		case <-n.ctx.Done():
+			return fmt.Errorf("node %s stopped", n.id)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
 // GetContext returns the context for this node
 func (n *RaftNode) GetContext() context.Context {
 	return n.ctx
@@ -384,33 +868,79 @@ func (n *RaftNode) handleAppendEntries(req AppendEntriesRequest) {
 	// This will be handled by the RPC server
 }
 
-// applyCommittedEntries applies all committed entries to the state machine
+// applyCommittedEntries applies all committed entries to the state machine.
+// It is the only path that applies entries: lastApplied records how far it
+// got, so each entry is applied exactly once no matter whether the commit
+// index was advanced by the leader or by AppendEntries.
+//
+// If the storage fails to apply an entry, lastApplied stops before it and
+// the node is marked unhealthy until a retry gets past it.
+// The caller must hold n.mu.
 func (n *RaftNode) applyCommittedEntries() {
-	for n.lastApplied < n.commitIndex {
-		n.lastApplied++
-		entry := n.log[n.lastApplied-1]
-
-		// Apply the command to the storage
-		switch string(entry.Command[:4]) { // First 4 bytes indicate operation
-		case "PUT ":
-			// Parse key-value from command
-			keyValue := entry.Command[4:]
-			// Find the separator (assuming it's a space)
-			spaceIndex := -1
-			for i, b := range keyValue {
-				if b == ' ' {
-					spaceIndex = i
-					break
-				}
-			}
-			if spaceIndex > 0 {
-				key := keyValue[:spaceIndex]
-				value := keyValue[spaceIndex+1:]
-				n.storage.Put(key, value)
-			}
-		case "DEL ":
-			key := entry.Command[4:]
-			n.storage.Delete(key)
+	if n.lastApplied >= n.commitIndex {
+		return
+	}
+
+	entries := n.log[n.lastApplied-n.snapshotIndex : n.commitIndex-n.snapshotIndex]
+	n.applySkip = n.sessions.duplicates(entries)
+	applied, err := n.applyEntries(entries)
+	n.notifyApplied(entries[:applied])
+	n.applySkip = nil
+	n.sessions.record(entries[:applied])
+	n.lastApplied += applied
+	if err != nil {
+		if n.applyErr == nil {
+			log.Printf("ERROR: node %s failed to apply entry %d, halting apply and reads until storage recovers: %v",
+				n.id, n.lastApplied+1, err)
 		}
+		n.applyErr = fmt.Errorf("failed to apply entry %d: %v", n.lastApplied+1, err)
+		if !n.applyRetrying {
+			n.applyRetrying = true
+			go n.retryApply()
+		}
+		return
+	}
+
+	if n.applyErr != nil {
+		log.Printf("Node %s storage recovered, applied through entry %d", n.id, n.lastApplied)
+		n.applyErr = nil
+	}
+
+	if n.snapshotThreshold > 0 && n.lastApplied-n.snapshotIndex >= n.snapshotThreshold {
+		n.compactLog()
+	}
+}
+
+// retryApply retries applying committed entries every applyRetryInterval
+// until an apply succeeds or the node stops
+func (n *RaftNode) retryApply() {
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-time.After(applyRetryInterval):
+		}
+
+		n.mu.Lock()
+		n.applyCommittedEntries()
+		if n.applyErr == nil {
+			n.applyRetrying = false
+			n.mu.Unlock()
+			return
+		}
+		n.mu.Unlock()
+	}
+}
+
+// Health returns nil if the node's state machine is keeping up with its
+// log, or an error wrapping ErrUnhealthy if applying an entry failed.
+// An unhealthy node does not serve reads.
+func (n *RaftNode) Health() error {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if n.applyErr != nil {
+		return fmt.Errorf("%w: node %s: %v", ErrUnhealthy, n.id, n.applyErr)
 	}
+	return nil
 }
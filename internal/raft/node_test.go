@@ -0,0 +1,197 @@
+package raft
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRaftNode_SeededElectionTimeouts(t *testing.T) {
+	a := NewRaftNodeWithSeed("a", ":0", nil, nil, 42)
+	b := NewRaftNodeWithSeed("b", ":0", nil, nil, 42)
+
+	if a.electionTimeout != b.electionTimeout {
+		t.Errorf("Expected equal initial timeouts, got %v and %v", a.electionTimeout, b.electionTimeout)
+	}
+
+	// The same seed must produce the same sequence
+	for i := 0; i < 20; i++ {
+		ta := a.randomElectionTimeout()
+		tb := b.randomElectionTimeout()
+		if ta != tb {
+			t.Fatalf("Sequences diverged at step %d: %v vs %v", i, ta, tb)
+		}
+		if ta < 150*time.Millisecond || ta >= 300*time.Millisecond {
+			t.Errorf("Timeout %v outside 150-300ms", ta)
+		}
+	}
+
+	// Default seeds come from the node ID
+	c := NewRaftNode("node1", ":0", nil, nil)
+	d := NewRaftNode("node1", ":0", nil, nil)
+	if c.randomElectionTimeout() != d.randomElectionTimeout() {
+		t.Error("Expected nodes with the same ID to share a default seed")
+	}
+}
+
+func TestRaftNode_PartitionedLeaderFailsFast(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	leader := waitForLeader(t, cluster)
+	leader.SetQuorumTimeout(200 * time.Millisecond)
+
+	if err := leader.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put before partition failed: %v", err)
+	}
+
+	// Partition the leader: neither side can reach the other
+	for _, node := range cluster.GetAllNodes() {
+		node.mu.Lock()
+		if node == leader {
+			for peerID := range node.peers {
+				node.peers[peerID] = "127.0.0.1:1"
+			}
+		} else {
+			node.peers[leader.GetID()] = "127.0.0.1:1"
+		}
+		node.mu.Unlock()
+	}
+	time.Sleep(400 * time.Millisecond)
+
+	before := leader.UncommittedEntries()
+	for i := 0; i < 20; i++ {
+		start := time.Now()
+		err := leader.Put([]byte(fmt.Sprintf("key%d", i)), []byte("value"))
+		if !errors.Is(err, ErrNoQuorum) {
+			t.Fatalf("Expected ErrNoQuorum from partitioned leader, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+			t.Errorf("Expected write to fail fast, took %v", elapsed)
+		}
+	}
+
+	if after := leader.UncommittedEntries(); after != before {
+		t.Errorf("Expected %d uncommitted entries, got %d", before, after)
+	}
+}
+
+// lockedBuffer is a bytes.Buffer that the log package and a test can use
+// at the same time
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRaftNode_RepeatedElectionsElectOneLeaderPerTerm(t *testing.T) {
+	// Run with -race: votes arrive concurrently, and with five nodes a
+	// candidate can gather more votes than it needs
+	var logs lockedBuffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	cluster := startTestCluster(t, 5)
+	var nodes []*RaftNode
+	for i := 1; i <= 5; i++ {
+		node, err := cluster.GetNode(fmt.Sprintf("node%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, node)
+	}
+	waitForLeader(t, cluster)
+
+	for round := 0; round < 10; round++ {
+		// Two nodes start competing elections at once
+		var wg sync.WaitGroup
+		for _, node := range []*RaftNode{nodes[round%len(nodes)], nodes[(round+2)%len(nodes)]} {
+			wg.Add(1)
+			go func(node *RaftNode) {
+				defer wg.Done()
+				node.startElection()
+			}(node)
+		}
+		wg.Wait()
+		waitForLeader(t, cluster)
+	}
+	// Let late votes for the last election arrive
+	time.Sleep(100 * time.Millisecond)
+
+	// Every term has at most one leader, which became leader once
+	leaders := make(map[string]string)
+	elected := regexp.MustCompile(`Node (\S+) became leader for term (\d+)`)
+	for _, match := range elected.FindAllStringSubmatch(logs.String(), -1) {
+		id, term := match[1], match[2]
+		if other, ok := leaders[term]; ok {
+			t.Errorf("Expected one leadership change in term %s, got %s after %s", term, id, other)
+		}
+		leaders[term] = id
+	}
+	if len(leaders) == 0 {
+		t.Error("Expected elections to elect leaders")
+	}
+}
+
+func TestRaftConfig_Validate(t *testing.T) {
+	if err := DefaultRaftConfig().Validate(); err != nil {
+		t.Errorf("Expected the default config to be valid, got %v", err)
+	}
+
+	invalid := []RaftConfig{
+		{ElectionTimeoutMin: 300 * time.Millisecond, ElectionTimeoutMax: 300 * time.Millisecond, HeartbeatInterval: 50 * time.Millisecond},
+		{ElectionTimeoutMin: 300 * time.Millisecond, ElectionTimeoutMax: 150 * time.Millisecond, HeartbeatInterval: 50 * time.Millisecond},
+		{ElectionTimeoutMin: 150 * time.Millisecond, ElectionTimeoutMax: 300 * time.Millisecond, HeartbeatInterval: 150 * time.Millisecond},
+		{ElectionTimeoutMin: 150 * time.Millisecond, ElectionTimeoutMax: 300 * time.Millisecond},
+		{ElectionTimeoutMax: 300 * time.Millisecond, HeartbeatInterval: 50 * time.Millisecond},
+	}
+	for _, cfg := range invalid {
+		if _, err := NewRaftNodeWithConfig("a", ":0", nil, nil, cfg); err == nil {
+			t.Errorf("Expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestRaftNode_ConfiguredElectionTimeout(t *testing.T) {
+	cfg := RaftConfig{
+		ElectionTimeoutMin: 2 * time.Second,
+		ElectionTimeoutMax: 3 * time.Second,
+		HeartbeatInterval:  200 * time.Millisecond,
+	}
+
+	// The peer never answers, so the node only hears silence
+	node, err := NewRaftNodeWithConfig("a", ":0", map[string]string{"b": fmt.Sprintf("localhost:%d", freePort(t))}, nil, cfg)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		if timeout := node.randomElectionTimeout(); timeout < cfg.ElectionTimeoutMin || timeout >= cfg.ElectionTimeoutMax {
+			t.Errorf("Timeout %v outside %v-%v", timeout, cfg.ElectionTimeoutMin, cfg.ElectionTimeoutMax)
+		}
+	}
+
+	if err := node.Start(); err != nil {
+		t.Fatalf("Failed to start node: %v", err)
+	}
+	defer node.Stop()
+
+	time.Sleep(time.Second)
+	if state, term := node.GetState(); state != Follower || term != 0 {
+		t.Errorf("Expected a follower in term 0 after 1s of silence, got %v in term %d", state, term)
+	}
+}
@@ -0,0 +1,419 @@
+package raft
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// StableStore keeps the state Raft requires to survive a restart: the
+// current term, the vote cast in it, and the log. Log entries are stored
+// as opaque records encoded by the node's LogCodec.
+type StableStore interface {
+	// Load returns the saved term, vote and log records, in log order.
+	// A store that has never been written returns term 0, no vote and an
+	// empty log.
+	Load() (term int, votedFor string, records [][]byte, err error)
+
+	// SaveState durably replaces the saved term and vote
+	SaveState(term int, votedFor string) error
+
+	// AppendLog durably appends records to the end of the log
+	AppendLog(records [][]byte) error
+
+	// TruncateLog durably drops every record after the first n
+	TruncateLog(n int) error
+
+	// SaveSnapshot durably replaces the saved snapshot, which covers the
+	// log up to and including index, whose entry was in term
+	SaveSnapshot(index, term int, data []byte) error
+
+	// LoadSnapshot returns the saved snapshot. A store without one
+	// returns index 0.
+	LoadSnapshot() (index, term int, data []byte, err error)
+
+	// CompactLog durably drops the first n records, which a saved
+	// snapshot covers
+	CompactLog(n int) error
+}
+
+// SetStableStore makes the node persist its term, vote, log and snapshot
+// to store, and restores them from it. It must be called before the node
+// starts, and after SetLogCodec if the codec is replaced.
+//
+// The state machine is restored from the snapshot, if there is one, and
+// is otherwise not part of the stable state: a restarted node applies its
+// log again from the snapshot on as entries are committed, which leaves
+// storage as it was since every command sets or deletes keys outright.
+func (n *RaftNode) SetStableStore(store StableStore) error {
+	term, votedFor, records, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load raft state: %v", err)
+	}
+	snapIndex, snapTerm, snapData, err := store.LoadSnapshot()
+	if err != nil {
+		return fmt.Errorf("failed to load snapshot: %v", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	// Records the snapshot covers are left over from a compaction cut
+	// short by a crash
+	covered := 0
+	entries := make([]LogEntry, 0, len(records))
+	for i, record := range records {
+		entry, err := n.codec.Decode(record)
+		if err != nil {
+			return fmt.Errorf("failed to decode log entry %d: %v", i+1, err)
+		}
+		if entry.Index <= snapIndex && len(entries) == 0 {
+			covered++
+			continue
+		}
+		if want := snapIndex + len(entries) + 1; entry.Index != want {
+			return fmt.Errorf("log entry %d has index %d", want, entry.Index)
+		}
+		entries = append(entries, entry)
+	}
+	if covered > 0 {
+		if err := store.CompactLog(covered); err != nil {
+			return fmt.Errorf("failed to compact log: %v", err)
+		}
+	}
+
+	if snapIndex > 0 {
+		if err := n.restoreState(snapData); err != nil {
+			return fmt.Errorf("failed to restore snapshot: %v", err)
+		}
+		n.snapshotIndex, n.snapshotTerm = snapIndex, snapTerm
+		n.lastSnapshot = &snapshot{lastIncludedIndex: snapIndex, lastIncludedTerm: snapTerm, data: snapData}
+		n.commitIndex, n.lastApplied = snapIndex, snapIndex
+	}
+
+	n.stable = store
+	n.currentTerm, n.votedFor, n.log = term, votedFor, entries
+	n.persistedTerm, n.persistedVote = term, votedFor
+	n.persistedLog, n.storedLog = len(entries), len(entries)
+	if len(entries) > 0 || term > 0 || snapIndex > 0 {
+		log.Printf("Node %s restored term %d, a snapshot through entry %d and %d log entries", n.id, term, snapIndex, len(entries))
+	}
+	return nil
+}
+
+// persistState writes whatever part of the term, vote and log changed
+// since it last ran. Raft handlers call it before replying, so nothing a
+// node has promised is forgotten if it restarts. It does nothing if the
+// node has no stable store. The caller must hold n.mu.
+func (n *RaftNode) persistState() error {
+	if n.stable == nil {
+		return nil
+	}
+
+	if n.currentTerm != n.persistedTerm || n.votedFor != n.persistedVote {
+		if err := n.stable.SaveState(n.currentTerm, n.votedFor); err != nil {
+			return fmt.Errorf("failed to persist term and vote: %v", err)
+		}
+		n.persistedTerm, n.persistedVote = n.currentTerm, n.votedFor
+	}
+
+	if n.storedLog > n.persistedLog {
+		if err := n.stable.TruncateLog(n.persistedLog); err != nil {
+			return fmt.Errorf("failed to truncate log: %v", err)
+		}
+		n.storedLog = n.persistedLog
+	}
+	if n.persistedLog < len(n.log) {
+		records := make([][]byte, 0, len(n.log)-n.persistedLog)
+		for _, entry := range n.log[n.persistedLog:] {
+			records = append(records, n.codec.Encode(entry))
+		}
+		if err := n.stable.AppendLog(records); err != nil {
+			return fmt.Errorf("failed to persist log: %v", err)
+		}
+		n.persistedLog = len(n.log)
+		n.storedLog = n.persistedLog
+	}
+	return nil
+}
+
+// persistOrLog persists state changed while handling a response, where
+// there is no caller to report a failure to. The change is retried with
+// the next persistState.
+func (n *RaftNode) persistOrLog() {
+	if err := n.persistState(); err != nil {
+		log.Printf("ERROR: node %s: %v", n.id, err)
+	}
+}
+
+// truncateLog drops every entry after index, so the next persistState
+// drops them from the stable store too. The caller must hold n.mu.
+func (n *RaftNode) truncateLog(index int) {
+	length := index - n.snapshotIndex
+	n.log = n.log[:length]
+	if n.persistedLog > length {
+		n.persistedLog = length
+	}
+}
+
+// FileStableStore is a StableStore kept in a directory. The term and vote
+// live in a small file that is replaced atomically, as does the snapshot,
+// and the log in a file of length-prefixed records that is appended to or
+// truncated, and rewritten when it is compacted.
+type FileStableStore struct {
+	mu      sync.Mutex
+	dir     string
+	logFile *os.File
+
+	// Offset of each record in the log file, and of the end of the last
+	offsets []int64
+	end     int64
+}
+
+const (
+	stateFileName    = "raft-state"
+	logFileName      = "raft-log"
+	snapshotFileName = "raft-snapshot"
+)
+
+// NewFileStableStore opens the stable store in dir, creating it if needed
+func NewFileStableStore(dir string) (*FileStableStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStableStore{dir: dir, logFile: f}, nil
+}
+
+// Load implements StableStore.Load. A record cut short by a crash during
+// an append is dropped: the append never returned, so nothing depended
+// on it.
+func (s *FileStableStore) Load() (int, string, [][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	term, votedFor, err := s.loadState()
+	if err != nil {
+		return 0, "", nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, logFileName))
+	if err != nil {
+		return 0, "", nil, err
+	}
+
+	var records [][]byte
+	s.offsets = s.offsets[:0]
+	var pos int64
+	for int64(len(data))-pos >= 4 {
+		size := int64(binary.BigEndian.Uint32(data[pos:]))
+		if int64(len(data))-pos-4 < size {
+			break
+		}
+		s.offsets = append(s.offsets, pos)
+		records = append(records, data[pos+4:pos+4+size])
+		pos += 4 + size
+	}
+	s.end = pos
+
+	if pos < int64(len(data)) {
+		log.Printf("Dropping %d bytes of incomplete log record from %s", int64(len(data))-pos, s.dir)
+		if err := s.logFile.Truncate(pos); err != nil {
+			return 0, "", nil, err
+		}
+		if err := s.logFile.Sync(); err != nil {
+			return 0, "", nil, err
+		}
+	}
+	return term, votedFor, records, nil
+}
+
+// loadState reads the term and vote, which are absent in a new store
+func (s *FileStableStore) loadState() (int, string, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, stateFileName))
+	if os.IsNotExist(err) {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if len(data) < 8 {
+		return 0, "", errors.New("raft state file is truncated")
+	}
+	return int(binary.BigEndian.Uint64(data)), string(data[8:]), nil
+}
+
+// SaveState implements StableStore.SaveState. The state is written to a
+// temporary file that is then renamed over the old one, so a crash leaves
+// either the old state or the new.
+func (s *FileStableStore) SaveState(term int, votedFor string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := make([]byte, 8+len(votedFor))
+	binary.BigEndian.PutUint64(data, uint64(term))
+	copy(data[8:], votedFor)
+	return replaceFile(filepath.Join(s.dir, stateFileName), data)
+}
+
+// SaveSnapshot implements StableStore.SaveSnapshot. Like the state, the
+// snapshot is replaced atomically.
+func (s *FileStableStore) SaveSnapshot(index, term int, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buf := make([]byte, 16, 16+len(data))
+	binary.BigEndian.PutUint64(buf, uint64(index))
+	binary.BigEndian.PutUint64(buf[8:], uint64(term))
+	return replaceFile(filepath.Join(s.dir, snapshotFileName), append(buf, data...))
+}
+
+// LoadSnapshot implements StableStore.LoadSnapshot
+func (s *FileStableStore) LoadSnapshot() (int, int, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFileName))
+	if os.IsNotExist(err) {
+		return 0, 0, nil, nil
+	}
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if len(data) < 16 {
+		return 0, 0, nil, errors.New("raft snapshot file is truncated")
+	}
+	return int(binary.BigEndian.Uint64(data)), int(binary.BigEndian.Uint64(data[8:])), data[16:], nil
+}
+
+// replaceFile writes data to a temporary file that is then renamed over
+// path, so a crash leaves either the old contents or the new
+func replaceFile(path string, data []byte) error {
+	tmp, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// AppendLog implements StableStore.AppendLog
+func (s *FileStableStore) AppendLog(records [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var buf []byte
+	offsets := make([]int64, 0, len(records))
+	pos := s.end
+	for _, record := range records {
+		offsets = append(offsets, pos)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(record)))
+		buf = append(buf, record...)
+		pos += 4 + int64(len(record))
+	}
+
+	if _, err := s.logFile.WriteAt(buf, s.end); err != nil {
+		return err
+	}
+	if err := s.logFile.Sync(); err != nil {
+		return err
+	}
+	s.offsets = append(s.offsets, offsets...)
+	s.end = pos
+	return nil
+}
+
+// TruncateLog implements StableStore.TruncateLog
+func (s *FileStableStore) TruncateLog(n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n >= len(s.offsets) {
+		return nil
+	}
+	if err := s.logFile.Truncate(s.offsets[n]); err != nil {
+		return err
+	}
+	if err := s.logFile.Sync(); err != nil {
+		return err
+	}
+	s.end = s.offsets[n]
+	s.offsets = s.offsets[:n]
+	return nil
+}
+
+// CompactLog implements StableStore.CompactLog. The records that remain
+// are copied to a new file that is renamed over the log, so a crash
+// leaves either the whole log or the compacted one.
+func (s *FileStableStore) CompactLog(n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n <= 0 {
+		return nil
+	}
+	if n > len(s.offsets) {
+		n = len(s.offsets)
+	}
+	base := s.end
+	if n < len(s.offsets) {
+		base = s.offsets[n]
+	}
+	rest := make([]byte, s.end-base)
+	if _, err := s.logFile.ReadAt(rest, base); err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, logFileName)
+	f, err := os.OpenFile(path+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(rest); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		f.Close()
+		return err
+	}
+
+	s.logFile.Close()
+	s.logFile = f
+	offsets := make([]int64, 0, len(s.offsets)-n)
+	for _, offset := range s.offsets[n:] {
+		offsets = append(offsets, offset-base)
+	}
+	s.offsets = offsets
+	s.end -= base
+	return nil
+}
+
+// Close closes the log file. The node using the store must be stopped
+// first.
+func (s *FileStableStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logFile.Close()
+}
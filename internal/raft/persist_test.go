@@ -0,0 +1,119 @@
+package raft
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRaftNode_RestoresStateAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStableStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := NewRaftNode("a", ":0", nil, nil)
+	if err := n.SetStableStore(store); err != nil {
+		t.Fatal(err)
+	}
+	rpc := &RaftRPC{node: n}
+
+	var voteResp RequestVoteResponse
+	if err := rpc.RequestVote(RequestVoteRequest{Term: 3, CandidateID: "b"}, &voteResp); err != nil || !voteResp.VoteGranted {
+		t.Fatalf("Expected vote for b, got %+v (%v)", voteResp, err)
+	}
+
+	var entries []LogEntry
+	for i := 1; i <= 5; i++ {
+		entries = append(entries, LogEntry{Term: 3, Command: []byte(fmt.Sprintf("PUT key%d value%d", i, i))})
+	}
+	var appendResp AppendEntriesResponse
+	if err := rpc.AppendEntries(AppendEntriesRequest{Term: 3, LeaderID: "b", Entries: entries}, &appendResp); err != nil || !appendResp.Success {
+		t.Fatalf("Expected entries to be appended, got %+v (%v)", appendResp, err)
+	}
+
+	// Replace the last two entries, as a conflicting leader would
+	n.mu.Lock()
+	n.truncateLog(3)
+	n.log = append(n.log, LogEntry{Term: 3, Index: 4, Command: []byte("PUT key4 replaced")})
+	if err := n.persistState(); err != nil {
+		t.Fatal(err)
+	}
+	n.mu.Unlock()
+	store.Close()
+
+	// Restart over the same directory
+	store, err = NewFileStableStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	restarted := NewRaftNode("a", ":0", nil, nil)
+	if err := restarted.SetStableStore(store); err != nil {
+		t.Fatal(err)
+	}
+
+	if restarted.currentTerm != 3 || restarted.votedFor != "b" {
+		t.Errorf("Expected term 3 with a vote for b, got term %d and vote %q", restarted.currentTerm, restarted.votedFor)
+	}
+	if len(restarted.log) != 4 {
+		t.Fatalf("Expected 4 log entries, got %d", len(restarted.log))
+	}
+	for i, entry := range restarted.log {
+		if entry.Index != i+1 || entry.Term != 3 {
+			t.Errorf("Expected entry %d in term 3, got index %d term %d", i+1, entry.Index, entry.Term)
+		}
+	}
+	if string(restarted.log[3].Command) != "PUT key4 replaced" {
+		t.Errorf("Expected the replaced entry, got %q", restarted.log[3].Command)
+	}
+
+	// The restored vote stops a second vote in the same term
+	voteResp = RequestVoteResponse{}
+	(&RaftRPC{node: restarted}).RequestVote(RequestVoteRequest{Term: 3, CandidateID: "c", LastLogIndex: 4, LastLogTerm: 3}, &voteResp)
+	if voteResp.VoteGranted {
+		t.Error("Expected a restarted node not to vote twice in one term")
+	}
+}
+
+func TestFileStableStore_DropsIncompleteRecord(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStableStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AppendLog([][]byte{[]byte("one"), []byte("two")}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// A crash part way through appending a third record
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 10, 't', 'h'})
+	f.Close()
+
+	store, err = NewFileStableStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	_, _, records, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || string(records[1]) != "two" {
+		t.Fatalf("Expected the two complete records, got %q", records)
+	}
+
+	// Appends continue after the last complete record
+	if err := store.AppendLog([][]byte{[]byte("three")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, records, _ = store.Load(); len(records) != 3 || string(records[2]) != "three" {
+		t.Errorf("Expected three records, got %q", records)
+	}
+}
@@ -0,0 +1,152 @@
+package raft
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// readIndexTimeout bounds how long a linearizable read waits for a
+// majority to confirm the leader, and then for the read index to apply
+const readIndexTimeout = time.Second
+
+// LinearizableGet reads key on the leader using the ReadIndex protocol:
+// it records the commit index, confirms with a round of heartbeats that a
+// majority still follows this node, waits until the state machine has
+// applied through that index, and only then reads local storage. The read
+// reflects every write committed before it began, so a leader that has
+// been deposed without noticing fails the read instead of returning stale
+// data.
+//
+// It returns an error wrapping ErrNotLeader on a node that isn't the
+// leader or learns it was deposed, and ErrNoQuorum if a majority doesn't
+// answer in time.
+func (n *RaftNode) LinearizableGet(key []byte) ([]byte, error) {
+	// A node that can't apply entries can't catch up to any read index
+	if err := n.Health(); err != nil {
+		return nil, err
+	}
+	index, err := n.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	if err := n.WaitForApplied(index, readIndexTimeout); err != nil {
+		return nil, err
+	}
+	return n.storage.Get(key)
+}
+
+// readIndex returns a commit index that covers every write committed
+// before it was called, once a majority has confirmed this node is still
+// the leader
+func (n *RaftNode) readIndex() (int, error) {
+	n.mu.RLock()
+	if n.state != Leader {
+		n.mu.RUnlock()
+		return 0, ErrNotLeader
+	}
+	settled := n.termAt(n.commitIndex) == n.currentTerm
+	n.mu.RUnlock()
+
+	// A new leader's commit index may trail what its predecessors
+	// committed until an entry from its own term commits
+	if !settled {
+		if _, err := n.submit(ClientRequest{Operation: "noop"}); err != nil {
+			return 0, err
+		}
+	}
+
+	n.mu.RLock()
+	if n.state != Leader {
+		n.mu.RUnlock()
+		return 0, ErrNotLeader
+	}
+	term := n.currentTerm
+	index := n.commitIndex
+	peers := make(map[string]string)
+	for k, v := range n.peers {
+		peers[k] = v
+	}
+	n.mu.RUnlock()
+
+	if err := n.confirmLeadership(term, index, peers); err != nil {
+		return 0, err
+	}
+	return index, nil
+}
+
+// leaderReadIndex returns a read index for a read served by this node. The
+// leader runs readIndex itself; a follower asks the leader it last heard
+// from over the Raft transport, which confirms its leadership with a
+// majority before answering.
+func (n *RaftNode) leaderReadIndex() (int, error) {
+	index, err := n.readIndex()
+	if !errors.Is(err, ErrNotLeader) {
+		return index, err
+	}
+
+	id, addr, ok := n.Leader()
+	if !ok || id == n.id {
+		return 0, fmt.Errorf("%w: no leader found", ErrNoLeader)
+	}
+	index, err = n.sendReadIndex(addr)
+	if err != nil {
+		return 0, fmt.Errorf("read index from leader %s: %w", id, err)
+	}
+	return index, nil
+}
+
+// confirmLeadership sends a heartbeat for term to every peer and waits
+// until a majority of the cluster, counting this node, accepts it
+func (n *RaftNode) confirmLeadership(term, commitIndex int, peers map[string]string) error {
+	results := make(chan *AppendEntriesResponse, len(peers))
+	for peerID, peerAddr := range peers {
+		go func(id, addr string) {
+			resp, err := n.sendAppendEntries(addr, AppendEntriesRequest{
+				Term:         term,
+				LeaderID:     n.id,
+				Entries:      []LogEntry{},
+				LeaderCommit: commitIndex,
+			})
+			if err != nil {
+				log.Printf("Failed to confirm leadership with %s: %v", id, err)
+				results <- nil
+				return
+			}
+
+			n.mu.Lock()
+			n.peerContact[id] = time.Now()
+			if resp.Term > n.currentTerm {
+				n.becomeFollower(resp.Term)
+				n.persistOrLog()
+			}
+			n.mu.Unlock()
+			results <- resp
+		}(peerID, peerAddr)
+	}
+
+	acks := 1 // Count self
+	needed := (len(peers)+1)/2 + 1
+	timeout := time.After(readIndexTimeout)
+	for pending := len(peers); acks < needed; pending-- {
+		if pending == 0 {
+			return fmt.Errorf("%w: a majority didn't confirm the leader for term %d", ErrNoQuorum, term)
+		}
+		select {
+		case resp := <-results:
+			if resp == nil {
+				continue
+			}
+			if resp.Term > term {
+				return fmt.Errorf("%w: deposed in term %d", ErrNotLeader, resp.Term)
+			}
+			if resp.Success {
+				acks++
+			}
+		case <-timeout:
+			return fmt.Errorf("%w: leadership not confirmed within %v", ErrNoQuorum, readIndexTimeout)
+		}
+	}
+	return nil
+}
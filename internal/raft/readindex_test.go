@@ -0,0 +1,172 @@
+package raft
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRaftNode_LinearizableGet(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	leader := waitForLeader(t, cluster)
+
+	if err := leader.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	value, err := leader.LinearizableGet([]byte("key"))
+	if err != nil {
+		t.Fatalf("LinearizableGet failed: %v", err)
+	}
+	if string(value) != "value" {
+		t.Errorf("Expected value, got %q", value)
+	}
+
+	for _, node := range cluster.GetAllNodes() {
+		if node == leader {
+			continue
+		}
+		if _, err := node.LinearizableGet([]byte("key")); !errors.Is(err, ErrNotLeader) {
+			t.Errorf("Expected ErrNotLeader from follower %s, got %v", node.GetID(), err)
+		}
+	}
+}
+
+func TestRaftNode_StaleLeaderRejectsLinearizableGet(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	old := waitForLeader(t, cluster)
+	// Keep the old leader from stepping down on its own, so it still
+	// believes it leads when the read arrives
+	old.SetQuorumTimeout(time.Hour)
+
+	if err := old.Put([]byte("key"), []byte("v1")); err != nil {
+		t.Fatalf("Put before partition failed: %v", err)
+	}
+
+	// The majority side elects a new leader and overwrites the key
+	leader := partitionLeader(t, cluster, old)
+	if err := leader.Put([]byte("key"), []byte("v2")); err != nil {
+		t.Fatalf("Put on new leader failed: %v", err)
+	}
+
+	if !old.IsLeader() {
+		t.Fatal("Expected the partitioned node to still believe it is leader")
+	}
+	value, err := old.LinearizableGet([]byte("key"))
+	if !errors.Is(err, ErrNoQuorum) && !errors.Is(err, ErrNotLeader) {
+		t.Errorf("Expected the stale leader to reject the read, got %q (%v)", value, err)
+	}
+
+	value, err = leader.LinearizableGet([]byte("key"))
+	if err != nil || string(value) != "v2" {
+		t.Errorf("Expected v2 from the new leader, got %q (%v)", value, err)
+	}
+}
+
+// partitionLeader cuts old off from the rest of the cluster, so neither
+// side can reach the other, and returns the leader the majority elects
+func partitionLeader(t *testing.T, cluster *GlobalCluster, old *RaftNode) *RaftNode {
+	for _, node := range cluster.GetAllNodes() {
+		node.mu.Lock()
+		if node == old {
+			for peerID := range node.peers {
+				node.peers[peerID] = "127.0.0.1:1"
+			}
+		} else {
+			node.peers[old.GetID()] = "127.0.0.1:1"
+		}
+		node.mu.Unlock()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		for _, node := range cluster.GetAllNodes() {
+			if node != old && node.IsLeader() {
+				return node
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the majority to elect a new leader")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRaftStorage_FollowerScanGetsReadIndexFromLeader(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	leader := waitForLeader(t, cluster)
+
+	var follower *RaftNode
+	for _, node := range cluster.GetAllNodes() {
+		if node != leader {
+			follower = node
+			break
+		}
+	}
+
+	// Register the follower alone, as it would be in a process of its
+	// own, so the read index can only come over the transport
+	alone := newGlobalCluster()
+	if err := alone.RegisterNode(follower); err != nil {
+		t.Fatal(err)
+	}
+	followerStorage := NewRaftStorage(alone, follower.GetID())
+
+	leaderStorage := NewRaftStorage(cluster, leader.GetID())
+	for i := 0; i < 20; i++ {
+		key := []byte(fmt.Sprintf("key%02d", i))
+		if err := leaderStorage.Put(key, []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	// Scan straight away; the barrier must hide the follower's lag
+	it, err := followerStorage.Scan(nil, nil)
+	if err != nil {
+		t.Fatalf("Scan on follower failed: %v", err)
+	}
+	count := 0
+	for it.Next() {
+		if want := fmt.Sprintf("key%02d", count); string(it.Key()) != want {
+			t.Errorf("Expected %s, got %s", want, it.Key())
+		}
+		count++
+	}
+	if err := it.Close(); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if count != 20 {
+		t.Errorf("Expected 20 keys from the follower, got %d", count)
+	}
+
+	tail, err := followerStorage.Tail(1)
+	if err != nil || len(tail) != 1 || string(tail[0].Key) != "key19" {
+		t.Errorf("Expected key19 from Tail, got %v (%v)", tail, err)
+	}
+}
+
+func TestRaftStorage_StaleLeaderRefusesScan(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	old := waitForLeader(t, cluster)
+	old.SetQuorumTimeout(time.Hour)
+
+	oldStorage := NewRaftStorage(cluster, old.GetID())
+	if err := oldStorage.Put([]byte("key"), []byte("v1")); err != nil {
+		t.Fatalf("Put before partition failed: %v", err)
+	}
+
+	leader := partitionLeader(t, cluster, old)
+	if err := leader.Put([]byte("key2"), []byte("v2")); err != nil {
+		t.Fatalf("Put on new leader failed: %v", err)
+	}
+
+	if !old.IsLeader() {
+		t.Fatal("Expected the partitioned node to still believe it is leader")
+	}
+	if _, err := oldStorage.Scan(nil, nil); !errors.Is(err, ErrNoQuorum) && !errors.Is(err, ErrNotLeader) {
+		t.Errorf("Expected the stale leader to refuse the scan, got %v", err)
+	}
+	if _, err := oldStorage.Keys(); !errors.Is(err, ErrNoQuorum) && !errors.Is(err, ErrNotLeader) {
+		t.Errorf("Expected the stale leader to refuse Keys, got %v", err)
+	}
+}
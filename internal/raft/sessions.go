@@ -0,0 +1,186 @@
+package raft
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultSessionTTL is how long a client session is kept without
+	// writes from it
+	defaultSessionTTL = 10 * time.Minute
+
+	// defaultMaxSessions bounds how many client sessions are kept
+	defaultMaxSessions = 10000
+)
+
+// clientSession is what a node remembers about one client to recognize its
+// retries. A write's response carries no value, so the highest applied
+// sequence number is the whole cached response.
+type clientSession struct {
+	seq        uint64 // highest sequence number applied
+	lastActive int64  // leader timestamp of the client's latest write, in unix nanoseconds
+}
+
+// sessionTable tracks client sessions so a write retried after its first
+// attempt committed isn't applied twice. It is part of the state machine:
+// it changes only as entries are applied, and expiry is measured with the
+// timestamps the leader put in the log rather than each node's clock, so
+// every node keeps and evicts the same sessions.
+type sessionTable struct {
+	sessions map[string]*clientSession
+	ttl      time.Duration
+	max      int
+}
+
+func newSessionTable() *sessionTable {
+	return &sessionTable{
+		sessions: make(map[string]*clientSession),
+		ttl:      defaultSessionTTL,
+		max:      defaultMaxSessions,
+	}
+}
+
+// SetSessionLimits sets how long a client session is kept after its last
+// write and how many sessions are kept at most; when there are more, the
+// least recently active are evicted. A retry from an evicted session is
+// applied again, so ttl should comfortably exceed how long clients retry.
+func (n *RaftNode) SetSessionLimits(ttl time.Duration, maxSessions int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if maxSessions < 1 {
+		maxSessions = 1
+	}
+	n.sessions.ttl = ttl
+	n.sessions.max = maxSessions
+}
+
+// encodeSessionCommand wraps command so it is applied at most once for
+// the given client and sequence number
+func encodeSessionCommand(clientID string, seq uint64, at time.Time, command []byte) []byte {
+	prefix := fmt.Sprintf("SES %s %d %d ", clientID, seq, at.UnixNano())
+	return append([]byte(prefix), command...)
+}
+
+// decodeSession splits a session command into its session fields and the
+// command it wraps. ok is false for commands without a session.
+func decodeSession(command []byte) (clientID string, seq uint64, at int64, inner []byte, ok bool) {
+	if !bytes.HasPrefix(command, []byte("SES ")) {
+		return "", 0, 0, nil, false
+	}
+
+	fields := bytes.SplitN(command[4:], []byte(" "), 4)
+	if len(fields) != 4 || len(fields[0]) == 0 {
+		return "", 0, 0, nil, false
+	}
+	seq, err := strconv.ParseUint(string(fields[1]), 10, 64)
+	if err != nil {
+		return "", 0, 0, nil, false
+	}
+	at, err = strconv.ParseInt(string(fields[2]), 10, 64)
+	if err != nil {
+		return "", 0, 0, nil, false
+	}
+	return string(fields[0]), seq, at, fields[3], true
+}
+
+// validateClientID rejects client IDs that can't be encoded in a command
+func validateClientID(clientID string) error {
+	if clientID == "" || strings.ContainsAny(clientID, " \n") {
+		return fmt.Errorf("invalid client ID %q", clientID)
+	}
+	return nil
+}
+
+// duplicates returns the indexes of the entries that repeat a write
+// already applied for their session, or repeat an earlier entry in the
+// same batch. The table itself is not changed; see record.
+func (t *sessionTable) duplicates(entries []LogEntry) map[int]bool {
+	var dups map[int]bool
+	latest := make(map[string]uint64)
+	for _, entry := range entries {
+		clientID, seq, _, _, ok := decodeSession(entry.Command)
+		if !ok {
+			continue
+		}
+
+		last, seen := latest[clientID]
+		if !seen {
+			if s, ok := t.sessions[clientID]; ok {
+				last, seen = s.seq, true
+			}
+		}
+		if seen && seq <= last {
+			if dups == nil {
+				dups = make(map[int]bool)
+			}
+			dups[entry.Index] = true
+			continue
+		}
+		latest[clientID] = seq
+	}
+	return dups
+}
+
+// record updates the sessions of applied entries, then evicts sessions
+// idle for longer than the TTL as of the newest entry, and the least
+// recently active sessions beyond the limit
+func (t *sessionTable) record(entries []LogEntry) {
+	var now int64
+	for _, entry := range entries {
+		clientID, seq, at, _, ok := decodeSession(entry.Command)
+		if !ok {
+			continue
+		}
+
+		s, ok := t.sessions[clientID]
+		if !ok {
+			s = &clientSession{}
+			t.sessions[clientID] = s
+		}
+		if seq > s.seq {
+			s.seq = seq
+		}
+		if at > s.lastActive {
+			s.lastActive = at
+		}
+		if at > now {
+			now = at
+		}
+	}
+	if now == 0 {
+		return
+	}
+
+	if t.ttl > 0 {
+		cutoff := now - int64(t.ttl)
+		for clientID, s := range t.sessions {
+			if s.lastActive < cutoff {
+				delete(t.sessions, clientID)
+			}
+		}
+	}
+
+	if len(t.sessions) > t.max {
+		ids := make([]string, 0, len(t.sessions))
+		for clientID := range t.sessions {
+			ids = append(ids, clientID)
+		}
+		// Break ties by ID so every node evicts the same sessions
+		sort.Slice(ids, func(i, j int) bool {
+			a, b := t.sessions[ids[i]], t.sessions[ids[j]]
+			if a.lastActive != b.lastActive {
+				return a.lastActive < b.lastActive
+			}
+			return ids[i] < ids[j]
+		})
+		for _, clientID := range ids[:len(ids)-t.max] {
+			delete(t.sessions, clientID)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package raft
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+// applySessionWrites appends a PUT of value to key for each client at the
+// given log time and applies it
+func applySessionWrites(n *RaftNode, at time.Time, key, value string, seq uint64, clients ...string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, clientID := range clients {
+		command := encodeSessionCommand(clientID, seq, at, []byte("PUT "+key+" "+value))
+		n.log = append(n.log, LogEntry{Term: 1, Index: len(n.log) + 1, Command: command})
+	}
+	n.commitIndex = len(n.log)
+	n.applyCommittedEntries()
+}
+
+func TestRaftNode_SessionRetriesApplyOnce(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		store := newSlowStorage(0)
+		n := newApplyNode(store, workers, nil)
+		now := time.Now()
+
+		// A retry in the same batch as the original, then one that arrives
+		// after a newer write from the same client
+		applySessionWrites(n, now, "key", "a", 1, "client")
+		applySessionWrites(n, now, "key", "a", 1, "client")
+		applySessionWrites(n, now, "key", "b", 2, "client")
+		applySessionWrites(n, now, "key", "a", 1, "client")
+
+		if values := store.values["key"]; fmt.Sprint(values) != "[a b]" {
+			t.Errorf("workers=%d: Expected writes [a b], got %v", workers, values)
+		}
+		if n.LastApplied() != 4 {
+			t.Errorf("workers=%d: Expected retries to count as applied, got %d", workers, n.LastApplied())
+		}
+	}
+}
+
+func TestRaftNode_IdleSessionsAreEvicted(t *testing.T) {
+	const ttl = time.Minute
+	store := newSlowStorage(0)
+	n := newApplyNode(store, 1, nil)
+	n.SetSessionLimits(ttl, 1000)
+
+	var all, active []string
+	for i := 0; i < 100; i++ {
+		all = append(all, fmt.Sprintf("client%d", i))
+	}
+	active = all[:10]
+
+	start := time.Now()
+	applySessionWrites(n, start, "key", "first", 1, all...)
+
+	// Only the active clients write again, more than a TTL later
+	applySessionWrites(n, start.Add(2*ttl), "key", "second", 2, active...)
+
+	if len(n.sessions.sessions) != len(active) {
+		t.Fatalf("Expected %d sessions after idle ones expired, got %d", len(active), len(n.sessions.sessions))
+	}
+	for _, clientID := range active {
+		if s, ok := n.sessions.sessions[clientID]; !ok || s.seq != 2 {
+			t.Errorf("Expected %s to keep its session at seq 2, got %+v", clientID, s)
+		}
+	}
+
+	// An active session still recognizes its retry; an evicted one can't
+	later := start.Add(2*ttl + time.Second)
+	applySessionWrites(n, later, "retry", "active", 2, "client0")
+	applySessionWrites(n, later, "retry", "evicted", 1, "client50")
+	if values := store.values["retry"]; fmt.Sprint(values) != "[evicted]" {
+		t.Errorf("Expected only the evicted session's retry to apply, got %v", values)
+	}
+}
+
+func TestRaftNode_SessionCountIsBounded(t *testing.T) {
+	n := newApplyNode(newSlowStorage(0), 1, nil)
+	n.SetSessionLimits(time.Hour, 5)
+
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		applySessionWrites(n, start.Add(time.Duration(i)*time.Second), "key", "value", 1, fmt.Sprintf("client%02d", i))
+	}
+
+	if len(n.sessions.sessions) != 5 {
+		t.Fatalf("Expected 5 sessions, got %d", len(n.sessions.sessions))
+	}
+	for i := 15; i < 20; i++ {
+		if _, ok := n.sessions.sessions[fmt.Sprintf("client%02d", i)]; !ok {
+			t.Errorf("Expected the most recent session client%02d to be kept", i)
+		}
+	}
+}
+
+func TestRaftNode_SubmitSessionRequestRejectsBadClientID(t *testing.T) {
+	n := NewRaftNode("node", ":0", nil, newSlowStorage(0))
+	if _, err := n.SubmitSessionRequest("two words", 1, "put", []byte("key"), []byte("value")); err == nil {
+		t.Error("Expected an error for a client ID with a space")
+	}
+}
+
+func TestRaftNode_ClusterAppliesSessionRetryOnce(t *testing.T) {
+	cluster := startTestClusterWith(t, 3, func() storage.Storage {
+		return newSlowStorage(0)
+	})
+	leader := waitForLeader(t, cluster)
+
+	// The client retries a write whose first attempt committed
+	for i := 0; i < 2; i++ {
+		if _, err := leader.SubmitSessionRequest("client", 1, "put", []byte("key"), []byte("value")); err != nil {
+			t.Fatalf("Attempt %d failed: %v", i+1, err)
+		}
+	}
+	index := leaderLogLen(leader)
+	for _, node := range cluster.GetAllNodes() {
+		if err := node.WaitForApplied(index, 2*time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, node := range cluster.GetAllNodes() {
+		if counts := node.storage.(*slowStorage).applied("key"); counts["value"] != 1 {
+			t.Errorf("%s: Expected the write applied once, got %d", node.id, counts["value"])
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package raft
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// defaultSnapshotChunkSize is how many snapshot bytes go in one
+	// InstallSnapshot RPC
+	defaultSnapshotChunkSize = 64 << 10
+
+	// defaultSnapshotRate caps snapshot transfer to one follower, in bytes
+	// per second, so heartbeats and replication keep getting through
+	defaultSnapshotRate = 8 << 20
+
+	// snapshotRetryInterval is how long a leader waits before resending a
+	// chunk that failed to send
+	snapshotRetryInterval = 100 * time.Millisecond
+
+	// snapshotMaxRetries bounds consecutive failed sends before a transfer
+	// is abandoned
+	snapshotMaxRetries = 20
+)
+
+// snapshot is a state-machine image covering the log up to and including
+// lastIncludedIndex
+type snapshot struct {
+	lastIncludedIndex int
+	lastIncludedTerm  int
+	data              []byte
+}
+
+// SetSnapshotTransfer sets how a leader ships snapshots to followers:
+// chunkSize bytes per InstallSnapshot RPC, and at most bytesPerSecond
+// bytes per second to each follower. A bytesPerSecond of 0 disables the
+// rate limit.
+func (n *RaftNode) SetSnapshotTransfer(chunkSize, bytesPerSecond int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if chunkSize < 1 {
+		chunkSize = defaultSnapshotChunkSize
+	}
+	if bytesPerSecond < 0 {
+		bytesPerSecond = 0
+	}
+	n.snapshotChunkSize = chunkSize
+	n.snapshotRate = bytesPerSecond
+}
+
+// sendSnapshot ships snap to a follower in chunks, pacing them to the
+// configured rate. Heartbeats go out on their own connections, so they
+// keep flowing between chunks and the follower doesn't start an election.
+// If a chunk fails to send, the leader retries from however much of the
+// snapshot the follower reports holding, so a dropped connection resumes
+// the transfer rather than restarting it.
+//
+// It returns the number of snapshot bytes it tried to send, counting every
+// attempt at a chunk.
+func (n *RaftNode) sendSnapshot(peerID string, snap *snapshot) (int64, error) {
+	n.mu.RLock()
+	term := n.currentTerm
+	addr, ok := n.peers[peerID]
+	chunkSize := n.snapshotChunkSize
+	rate := n.snapshotRate
+	n.mu.RUnlock()
+
+	if !ok {
+		return 0, fmt.Errorf("unknown peer %s", peerID)
+	}
+
+	size := int64(len(snap.data))
+	var offset, sent int64
+	failures := 0
+	start := time.Now()
+
+	for {
+		end := offset + int64(chunkSize)
+		if end > size {
+			end = size
+		}
+		req := InstallSnapshotRequest{
+			Term:              term,
+			LeaderID:          n.id,
+			LastIncludedIndex: snap.lastIncludedIndex,
+			LastIncludedTerm:  snap.lastIncludedTerm,
+			Offset:            offset,
+			Data:              snap.data[offset:end],
+			Done:              end == size,
+		}
+
+		resp, err := n.sendInstallSnapshot(addr, req)
+		sent += end - offset
+		if err != nil {
+			failures++
+			if failures > snapshotMaxRetries {
+				return sent, fmt.Errorf("snapshot transfer to %s failed at byte %d: %v", peerID, offset, err)
+			}
+			log.Printf("Snapshot chunk to %s failed, retrying: %v", peerID, err)
+			if !n.sleep(snapshotRetryInterval) {
+				return sent, errors.New("node stopped")
+			}
+			continue
+		}
+		failures = 0
+
+		n.mu.Lock()
+		n.peerContact[peerID] = time.Now()
+		if resp.Term > n.currentTerm {
+			n.becomeFollower(resp.Term)
+			n.persistOrLog()
+			n.mu.Unlock()
+			return sent, fmt.Errorf("snapshot transfer to %s aborted: term %d is newer", peerID, resp.Term)
+		}
+		n.mu.Unlock()
+
+		offset = resp.Stored
+		if req.Done && offset == size {
+			return sent, nil
+		}
+
+		// Pace the transfer: wait until the bytes sent so far fit the rate
+		if rate > 0 {
+			due := time.Duration(float64(sent) / float64(rate) * float64(time.Second))
+			if wait := due - time.Since(start); wait > 0 && !n.sleep(wait) {
+				return sent, errors.New("node stopped")
+			}
+		}
+	}
+}
+
+// sleep waits for d, returning false if the node stops first
+func (n *RaftNode) sleep(d time.Duration) bool {
+	select {
+	case <-n.ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// InstallSnapshot receives a chunk of a snapshot from the leader. Chunks
+// must arrive in order; a chunk at an offset the follower already holds
+// replaces what follows it, and one past the end is refused. Either way
+// the follower reports how much it holds, so the leader knows where to
+// resume.
+func (r *RaftRPC) InstallSnapshot(req InstallSnapshotRequest, resp *InstallSnapshotResponse) error {
+	r.node.mu.Lock()
+	defer r.node.mu.Unlock()
+
+	if req.Term < r.node.currentTerm {
+		resp.Term = r.node.currentTerm
+		return nil
+	}
+
+	if req.Term > r.node.currentTerm {
+		r.node.becomeFollower(req.Term)
+	}
+	if err := r.node.persistState(); err != nil {
+		return err
+	}
+
+	// A chunk from the leader is as good as a heartbeat
+	r.node.leaderID = req.LeaderID
+	r.node.lastHeartbeat = time.Now()
+	r.node.lastLeaderContact = r.node.lastHeartbeat
+	resp.Term = r.node.currentTerm
+
+	in := r.node.incomingSnapshot
+	if in == nil || in.lastIncludedIndex != req.LastIncludedIndex || in.lastIncludedTerm != req.LastIncludedTerm {
+		// A different snapshot has to be received from the start
+		if req.Offset != 0 {
+			r.node.incomingSnapshot = nil
+			resp.Stored = 0
+			return nil
+		}
+		in = &snapshot{lastIncludedIndex: req.LastIncludedIndex, lastIncludedTerm: req.LastIncludedTerm}
+		r.node.incomingSnapshot = in
+	}
+
+	if req.Offset > int64(len(in.data)) {
+		resp.Stored = int64(len(in.data))
+		return nil
+	}
+	in.data = append(in.data[:req.Offset], req.Data...)
+	resp.Stored = int64(len(in.data))
+
+	if req.Done {
+		log.Printf("Node %s received snapshot through index %d (%d bytes) from %s",
+			r.node.id, in.lastIncludedIndex, len(in.data), req.LeaderID)
+		r.node.receivedSnapshot = in
+		r.node.incomingSnapshot = nil
+		if err := r.node.installSnapshot(in); err != nil {
+			log.Printf("ERROR: node %s failed to install snapshot through entry %d: %v", r.node.id, in.lastIncludedIndex, err)
+		}
+	}
+	return nil
+}
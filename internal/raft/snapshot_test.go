@@ -0,0 +1,130 @@
+package raft
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// transferFixture returns a started cluster's leader, the follower to ship
+// a snapshot to, the remaining follower, and a snapshot of size bytes
+func transferFixture(t *testing.T, size int) (*RaftNode, *RaftNode, *RaftNode, *snapshot) {
+	cluster := startTestCluster(t, 3)
+	leader := waitForLeader(t, cluster)
+
+	var followers []*RaftNode
+	for _, node := range cluster.GetAllNodes() {
+		if node != leader {
+			followers = append(followers, node)
+		}
+	}
+
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	return leader, followers[0], followers[1], &snapshot{lastIncludedIndex: 100, lastIncludedTerm: 1, data: data}
+}
+
+func receivedData(n *RaftNode) []byte {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.receivedSnapshot == nil {
+		return nil
+	}
+	return n.receivedSnapshot.data
+}
+
+func TestRaftNode_ThrottledSnapshotKeepsLeadership(t *testing.T) {
+	const size = 2 << 20
+	const rate = 4 << 20
+	leader, target, other, snap := transferFixture(t, size)
+	leader.SetSnapshotTransfer(32<<10, rate)
+	_, term := leader.GetState()
+
+	type result struct {
+		sent int64
+		err  error
+	}
+	done := make(chan result, 1)
+	start := time.Now()
+	go func() {
+		sent, err := leader.sendSnapshot(target.GetID(), snap)
+		done <- result{sent, err}
+	}()
+
+	// Heartbeats keep reaching the follower that isn't receiving the
+	// snapshot, well inside its election timeout
+	var res result
+	for waiting := true; waiting; {
+		select {
+		case res = <-done:
+			waiting = false
+		case <-time.After(10 * time.Millisecond):
+			if age := time.Since(other.LastLeaderContact()); age > 150*time.Millisecond {
+				t.Errorf("Expected heartbeats during transfer, none for %v", age)
+			}
+		}
+	}
+	elapsed := time.Since(start)
+
+	if res.err != nil {
+		t.Fatalf("sendSnapshot failed: %v", res.err)
+	}
+	if minimum := time.Duration(float64(size) / rate * float64(time.Second) * 0.9); elapsed < minimum {
+		t.Errorf("Expected transfer paced to at least %v, took %v", minimum, elapsed)
+	}
+	if !bytes.Equal(receivedData(target), snap.data) {
+		t.Error("Expected the follower to hold the full snapshot")
+	}
+
+	for _, node := range []*RaftNode{leader, target, other} {
+		if _, nodeTerm := node.GetState(); nodeTerm != term {
+			t.Errorf("%s: Expected term to stay %d, got %d", node.GetID(), term, nodeTerm)
+		}
+	}
+	if !leader.IsLeader() {
+		t.Error("Expected leader to keep leadership through the transfer")
+	}
+}
+
+func TestRaftNode_SnapshotTransferResumes(t *testing.T) {
+	const size = 1 << 20
+	const chunk = 16 << 10
+	leader, target, _, snap := transferFixture(t, size)
+	leader.SetSnapshotTransfer(chunk, 2<<20)
+
+	// The follower loses contact while its RPC server is down
+	target.mu.Lock()
+	target.electionTimeout = time.Hour
+	target.mu.Unlock()
+
+	type result struct {
+		sent int64
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		sent, err := leader.sendSnapshot(target.GetID(), snap)
+		done <- result{sent, err}
+	}()
+
+	// Drop the connection partway through, then bring it back
+	time.Sleep(150 * time.Millisecond)
+	target.stopRPCServer()
+	time.Sleep(300 * time.Millisecond)
+	if err := target.StartRPCServer(); err != nil {
+		t.Fatal(err)
+	}
+
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("sendSnapshot failed: %v", res.err)
+	}
+	if !bytes.Equal(receivedData(target), snap.data) {
+		t.Error("Expected the follower to hold the full snapshot")
+	}
+	// Restarting would have resent everything before the drop
+	if limit := int64(size + 8*chunk); res.sent > limit {
+		t.Errorf("Expected transfer to resume, sent %d bytes for a %d-byte snapshot", res.sent, size)
+	}
+}
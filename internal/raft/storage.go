@@ -1,16 +1,62 @@
 package raft
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"sync"
 	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"godatabase/internal/rpc/proto"
+	"godatabase/internal/storage"
 )
 
+// readBarrierTimeout bounds how long a read waits for the leader's read
+// index, and then for the local state machine to catch up with it.
+const readBarrierTimeout = 2 * time.Second
+
+// ErrNotLeader is returned for writes made through a node that isn't the
+// leader, when they can't be forwarded to it
+var ErrNotLeader = errors.New("not the leader")
+
+// ErrNoLeader is returned for writes made while no leader is known
+var ErrNoLeader = errors.New("no leader available")
+
+// ErrTooStale is returned by reads on a follower that has fallen further
+// behind the leader than its configured staleness bounds allow. Clients
+// should retry the read on another node.
+var ErrTooStale = errors.New("node too stale to serve reads")
+
+// errBehind marks a read barrier that timed out waiting for this node to
+// apply the read index, which the leader can serve instead
+var errBehind = errors.New("not caught up to the read index")
+
 // RaftStorage implements the storage.Storage interface using Raft consensus
 type RaftStorage struct {
 	cluster *GlobalCluster
 	nodeID  string
 	mu      sync.RWMutex
+
+	// Read staleness bounds for followers; zero disables a bound
+	maxIndexLag   int
+	maxContactAge time.Duration
+
+	// How recently a leader must have been known for Get to serve stale
+	// reads while no leader is elected; zero disables stale reads
+	staleReadAge time.Duration
+
+	// Connection to the leader's gRPC server, for forwarding writes to a
+	// leader in another process. Guarded by forwardMu.
+	forwardMu   sync.Mutex
+	forwardAddr string
+	forwardConn *grpc.ClientConn
 }
 
 // NewRaftStorage creates a new Raft-based storage
@@ -21,99 +67,788 @@ func NewRaftStorage(cluster *GlobalCluster, nodeID string) *RaftStorage {
 	}
 }
 
-// Put stores a key-value pair using Raft consensus
-func (rs *RaftStorage) Put(key, value []byte) error {
+// SetMaxStaleness bounds how far behind the leader this node may be and
+// still serve reads as a follower. maxIndexLag is the number of committed
+// entries the node may not have applied yet, and maxContactAge is how long
+// since it last heard from a leader. Beyond either bound reads return
+// ErrTooStale. A zero value disables that bound.
+func (rs *RaftStorage) SetMaxStaleness(maxIndexLag int, maxContactAge time.Duration) {
 	rs.mu.Lock()
 	defer rs.mu.Unlock()
 
-	node, err := rs.cluster.GetNode(rs.nodeID)
-	if err != nil {
-		return fmt.Errorf("failed to get node: %v", err)
-	}
+	rs.maxIndexLag = maxIndexLag
+	rs.maxContactAge = maxContactAge
+}
 
-	// Only the leader can handle writes
-	if !node.IsLeader() {
-		// Try to find the leader
-		leader, err := rs.cluster.GetLeader()
+// SetStaleReadsDuringElection lets Get keep serving reads while the cluster
+// has no leader, from this node's last known committed state, provided the
+// node has applied every entry it knows to be committed and knew of a
+// leader within maxAge: as a follower it last heard from one, or as a
+// former leader a peer last answered it. Such reads may miss writes the
+// new leader commits, and GetWithStaleness reports them as stale. Once a
+// leader is elected, reads go through the read barrier again. A maxAge of
+// zero, the default, makes reads fail while there is no leader.
+func (rs *RaftStorage) SetStaleReadsDuringElection(maxAge time.Duration) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.staleReadAge = maxAge
+}
+
+// Put stores a key-value pair using Raft consensus. On a follower the
+// write is forwarded to the leader; see forward.
+func (rs *RaftStorage) Put(key, value []byte) error {
+	return rs.PutCtx(context.Background(), key, value)
+}
+
+// PutCtx is Put bounded by ctx. While no leader is known, the wait for one
+// ends as soon as ctx is done.
+func (rs *RaftStorage) PutCtx(ctx context.Context, key, value []byte) error {
+	return rs.forward(ctx, func(leader *RaftStorage) error {
+		return leader.put(key, value)
+	}, func(ctx context.Context, c proto.StorageClient) error {
+		resp, err := c.Put(ctx, &proto.PutRequest{Key: key, Value: value})
 		if err != nil {
-			// No leader found, wait a bit and retry
-			rs.mu.Unlock()
-			time.Sleep(100 * time.Millisecond)
-			rs.mu.Lock()
-
-			// Try again
-			leader, err = rs.cluster.GetLeader()
-			if err != nil {
-				return fmt.Errorf("no leader available: %v", err)
-			}
+			return err
 		}
+		if !resp.Success {
+			return errors.New(resp.Error)
+		}
+		return nil
+	})
+}
 
-		// Redirect to leader (in a real implementation, you'd forward the request)
-		return fmt.Errorf("not the leader, leader is at %s", leader.GetAddress())
+// put submits a write through this node, which must be the leader
+func (rs *RaftStorage) put(key, value []byte) error {
+	node, err := rs.leaderNode()
+	if err != nil {
+		return err
 	}
+	node.writeMu.Lock()
+	defer node.writeMu.Unlock()
 
 	return node.Put(key, value)
 }
 
-// Get retrieves a value for a key
+// PutWithTTL stores a key-value pair like Put. Log entries carry no
+// expiry, and replicas applying them at different times couldn't agree on
+// one, so the key never expires.
+func (rs *RaftStorage) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	return rs.Put(key, value)
+}
+
+// Get retrieves a value for a key from the committed state machine. The
+// read is linearizable: the leader serves it with LinearizableGet, and a
+// follower waits on a read barrier and reads its own state machine, or
+// forwards the read to the leader if it can't catch up in time. See
+// SetStaleReadsDuringElection for reads while there is no leader.
 func (rs *RaftStorage) Get(key []byte) ([]byte, error) {
+	return rs.GetCtx(context.Background(), key)
+}
+
+// GetCtx is Get, returning ctx.Err() instead if ctx is done before the
+// read begins. ctx also bounds a read forwarded to the leader; the read
+// barrier keeps its own timeout.
+func (rs *RaftStorage) GetCtx(ctx context.Context, key []byte) ([]byte, error) {
+	value, _, err := rs.getWithStaleness(ctx, key)
+	return value, err
+}
+
+// GetWithStaleness is like Get, and also reports whether the value was
+// served from this node's last known committed state during an election
+// rather than through the read barrier
+func (rs *RaftStorage) GetWithStaleness(key []byte) (value []byte, stale bool, err error) {
+	return rs.getWithStaleness(context.Background(), key)
+}
+
+// getWithStaleness is GetWithStaleness bounded by ctx
+func (rs *RaftStorage) getWithStaleness(ctx context.Context, key []byte) (value []byte, stale bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
 	rs.mu.RLock()
 	defer rs.mu.RUnlock()
 
-	node, err := rs.cluster.GetNode(rs.nodeID)
+	value, err = rs.get(ctx, key)
+	if err == nil || errors.Is(err, storage.ErrKeyNotFound) || errors.Is(err, ctx.Err()) {
+		return value, false, err
+	}
+
+	staleNode, ok := rs.staleReadNode(err)
+	if !ok {
+		return nil, false, err
+	}
+	value, err = staleNode.storage.Get(key)
+	return value, true, err
+}
+
+// get reads key linearizably. A follower that lags past the read barrier
+// forwards the read to the leader, over the transport if the leader is in
+// another process; one outside its staleness bounds refuses it.
+func (rs *RaftStorage) get(ctx context.Context, key []byte) ([]byte, error) {
+	value, err := rs.linearizableGet(key)
+	if !isLeaderChange(err) {
+		return value, err
+	}
+
+	node, err := rs.readBarrier()
+	if err == nil {
+		return node.storage.Get(key)
+	}
+	if !errors.Is(err, errBehind) {
+		return nil, err
+	}
+
+	err = rs.forward(ctx, func(leader *RaftStorage) error {
+		var err error
+		value, err = leader.linearizableGet(key)
+		return err
+	}, func(ctx context.Context, c proto.StorageClient) error {
+		resp, err := c.Get(ctx, &proto.GetRequest{Key: key})
+		if err != nil {
+			return err
+		}
+		if !resp.Found {
+			return storage.ErrKeyNotFound
+		}
+		value = resp.Value
+		return nil
+	})
+	return value, err
+}
+
+// linearizableGet is LinearizableGet on this node, which must be the
+// leader
+func (rs *RaftStorage) linearizableGet(key []byte) ([]byte, error) {
+	node, err := rs.leaderNode()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get node: %v", err)
+		return nil, err
+	}
+	return node.LinearizableGet(key)
+}
+
+// staleReadNode returns the local node if stale reads are enabled, the
+// read failed with readErr because it found no leader, and the node's
+// committed state is recent enough to serve
+func (rs *RaftStorage) staleReadNode(readErr error) (*RaftNode, bool) {
+	if rs.staleReadAge <= 0 || !isLeaderChange(readErr) {
+		return nil, false
 	}
 
-	return node.Get(key)
+	node, err := rs.cluster.GetNode(rs.nodeID)
+	if err != nil || node.Health() != nil {
+		return nil, false
+	}
+	if node.LastApplied() < node.CommitIndex() {
+		return nil, false
+	}
+	if time.Since(node.lastKnownLeader()) > rs.staleReadAge {
+		return nil, false
+	}
+	return node, true
 }
 
-// Delete removes a key-value pair using Raft consensus
+// Delete removes a key-value pair using Raft consensus. On a follower
+// the delete is forwarded to the leader.
 func (rs *RaftStorage) Delete(key []byte) error {
-	rs.mu.Lock()
-	defer rs.mu.Unlock()
+	return rs.DeleteCtx(context.Background(), key)
+}
 
-	node, err := rs.cluster.GetNode(rs.nodeID)
+// DeleteCtx is Delete bounded by ctx, like PutCtx
+func (rs *RaftStorage) DeleteCtx(ctx context.Context, key []byte) error {
+	return rs.forward(ctx, func(leader *RaftStorage) error {
+		return leader.delete(key)
+	}, func(ctx context.Context, c proto.StorageClient) error {
+		resp, err := c.Delete(ctx, &proto.DeleteRequest{Key: key})
+		if status.Code(err) == codes.NotFound {
+			return storage.ErrKeyNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !resp.Success {
+			return errors.New(resp.Error)
+		}
+		return nil
+	})
+}
+
+// delete submits a delete through this node, which must be the leader
+func (rs *RaftStorage) delete(key []byte) error {
+	node, err := rs.leaderNode()
 	if err != nil {
-		return fmt.Errorf("failed to get node: %v", err)
+		return err
 	}
+	node.writeMu.Lock()
+	defer node.writeMu.Unlock()
 
-	// Only the leader can handle writes
-	if !node.IsLeader() {
-		// Try to find the leader
-		leader, err := rs.cluster.GetLeader()
+	return node.Delete(key)
+}
+
+// DeleteRange removes a key range using Raft consensus. The log has no
+// range delete, so the keys are read from the committed state machine
+// like Scan and deleted in batches, each submitted as one log entry like
+// a NewBatch batch.
+func (rs *RaftStorage) DeleteRange(start, end []byte) (int, error) {
+	return storage.DeleteRangeInBatches(rs, start, end, storage.DeleteRangeBatchSize)
+}
+
+// NewBatch returns a batch that is submitted to the leader as a single log
+// entry, so it commits as a whole and every node applies it together
+func (rs *RaftStorage) NewBatch() storage.WriteBatch {
+	return &raftBatch{rs: rs}
+}
+
+// raftBatch is a WriteBatch over RaftStorage
+type raftBatch struct {
+	storage.BatchOps
+	rs *RaftStorage
+}
+
+// Commit submits the batch through the leader, forwarding it from a
+// follower. Once it commits, each node applies it through a batch of its
+// own storage.
+func (b *raftBatch) Commit() error {
+	if len(b.Ops) == 0 {
+		return nil
+	}
+
+	return b.rs.forward(context.Background(), func(leader *RaftStorage) error {
+		node, err := leader.leaderNode()
 		if err != nil {
-			// No leader found, wait a bit and retry
-			rs.mu.Unlock()
-			time.Sleep(100 * time.Millisecond)
-			rs.mu.Lock()
-
-			// Try again
-			leader, err = rs.cluster.GetLeader()
-			if err != nil {
-				return fmt.Errorf("no leader available: %v", err)
+			return err
+		}
+		node.writeMu.Lock()
+		defer node.writeMu.Unlock()
+
+		return node.WriteBatch(b.Ops)
+	}, func(ctx context.Context, c proto.StorageClient) error {
+		req := &proto.WriteBatchRequest{Ops: make([]*proto.Operation, 0, len(b.Ops))}
+		for _, op := range b.Ops {
+			if op.Delete {
+				req.Ops = append(req.Ops, &proto.Operation{Type: proto.Operation_DELETE, Key: op.Key})
+			} else {
+				req.Ops = append(req.Ops, &proto.Operation{Type: proto.Operation_PUT, Key: op.Key, Value: op.Value})
 			}
 		}
+		resp, err := c.WriteBatch(ctx, req)
+		if err != nil {
+			return err
+		}
+		if !resp.Success {
+			return errors.New(resp.Error)
+		}
+		return nil
+	})
+}
+
+// DeleteIf removes key only if its committed value equals expected.
+// The leader compares against its own state machine and submits the
+// delete while holding its write lock, so no other write through it can
+// slip in between. On a follower the whole operation is forwarded.
+func (rs *RaftStorage) DeleteIf(key, expected []byte) (bool, error) {
+	var deleted bool
+	err := rs.forward(context.Background(), func(leader *RaftStorage) error {
+		var err error
+		deleted, err = leader.deleteIf(key, expected)
+		return err
+	}, func(ctx context.Context, c proto.StorageClient) error {
+		resp, err := c.DeleteIf(ctx, &proto.DeleteIfRequest{Key: key, Expected: expected})
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		deleted = resp.Deleted
+		return nil
+	})
+	return deleted, err
+}
 
-		// Redirect to leader (in a real implementation, you'd forward the request)
-		return fmt.Errorf("not the leader, leader is at %s", leader.GetAddress())
+// deleteIf is DeleteIf on this node, which must be the leader
+func (rs *RaftStorage) deleteIf(key, expected []byte) (bool, error) {
+	node, err := rs.leaderNode()
+	if err != nil {
+		return false, err
 	}
+	node.writeMu.Lock()
+	defer node.writeMu.Unlock()
 
-	return node.Delete(key)
+	// Make sure every committed write is visible before comparing. A new
+	// leader's commit index can trail writes its predecessor committed,
+	// so wait for the read index rather than the commit index.
+	index, err := node.readIndex()
+	if err != nil {
+		return false, err
+	}
+	if err := node.WaitForApplied(index, readBarrierTimeout); err != nil {
+		return false, err
+	}
+
+	value, err := node.storage.Get(key)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(value, expected) {
+		return false, nil
+	}
+
+	if err := node.Delete(key); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CompareAndSwap sets key to new only if its committed value equals old,
+// or if it is absent when old is nil. Like DeleteIf, the leader compares
+// against its own state machine and submits the write while holding its
+// write lock, and a follower forwards the whole operation.
+func (rs *RaftStorage) CompareAndSwap(key, old, new []byte) (bool, error) {
+	var swapped bool
+	err := rs.forward(context.Background(), func(leader *RaftStorage) error {
+		var err error
+		swapped, err = leader.compareAndSwap(key, old, new)
+		return err
+	}, func(ctx context.Context, c proto.StorageClient) error {
+		resp, err := c.CompareAndSwap(ctx, &proto.CompareAndSwapRequest{Key: key, Old: old, New: new, Absent: old == nil})
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		swapped = resp.Swapped
+		return nil
+	})
+	return swapped, err
+}
+
+// compareAndSwap is CompareAndSwap on this node, which must be the leader
+func (rs *RaftStorage) compareAndSwap(key, old, new []byte) (bool, error) {
+	node, err := rs.leaderNode()
+	if err != nil {
+		return false, err
+	}
+	node.writeMu.Lock()
+	defer node.writeMu.Unlock()
+
+	// Make sure every committed write is visible before comparing, as
+	// deleteIf does
+	index, err := node.readIndex()
+	if err != nil {
+		return false, err
+	}
+	if err := node.WaitForApplied(index, readBarrierTimeout); err != nil {
+		return false, err
+	}
+
+	value, err := node.storage.Get(key)
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return false, err
+	}
+	if old == nil {
+		if err == nil {
+			return false, nil
+		}
+	} else if err != nil || !bytes.Equal(value, old) {
+		return false, nil
+	}
+
+	if err := node.Put(key, new); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Increment adds delta to key's committed counter. The leader reads and
+// submits the new value under its write lock, like CompareAndSwap, and a
+// follower forwards the whole operation.
+func (rs *RaftStorage) Increment(key []byte, delta int64) (int64, error) {
+	var n int64
+	err := rs.forward(context.Background(), func(leader *RaftStorage) error {
+		var err error
+		n, err = leader.increment(key, delta)
+		return err
+	}, func(ctx context.Context, c proto.StorageClient) error {
+		resp, err := c.Increment(ctx, &proto.IncrementRequest{Key: key, Delta: delta})
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		n = resp.Value
+		return nil
+	})
+	return n, err
+}
+
+// increment is Increment on this node, which must be the leader
+func (rs *RaftStorage) increment(key []byte, delta int64) (int64, error) {
+	node, err := rs.leaderNode()
+	if err != nil {
+		return 0, err
+	}
+	node.writeMu.Lock()
+	defer node.writeMu.Unlock()
+
+	// Make sure every committed write is visible before reading the
+	// counter, as deleteIf does
+	index, err := node.readIndex()
+	if err != nil {
+		return 0, err
+	}
+	if err := node.WaitForApplied(index, readBarrierTimeout); err != nil {
+		return 0, err
+	}
+
+	current, err := node.storage.Get(key)
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return 0, err
+	}
+	n, value, err := storage.AddToCounter(current, err == nil, delta)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := node.Put(key, value); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Append adds value to key's committed list. It reads the list and
+// compare-and-swaps the longer one in through the leader, retrying if
+// another write to the key gets in between.
+func (rs *RaftStorage) Append(key, value []byte) error {
+	return storage.AppendWithCompareAndSwap(rs, key, value)
+}
+
+// GetList returns the elements of key's list, read like Get
+func (rs *RaftStorage) GetList(key []byte) ([][]byte, error) {
+	value, err := rs.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return storage.DecodeList(value)
+}
+
+// Sync returns once every write acknowledged through the leader is durable.
+// Acknowledged writes have already committed on a quorum; Sync waits for
+// the leader to apply all of them and then syncs its state machine's
+// storage.
+func (rs *RaftStorage) Sync() error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	node, err := rs.leaderNode()
+	if err != nil {
+		return err
+	}
+
+	if err := node.WaitForApplied(node.CommitIndex(), readBarrierTimeout); err != nil {
+		return err
+	}
+
+	return node.storage.Sync()
 }
 
-// Close closes the Raft storage
+// Close closes the Raft storage's connection to a remote leader, if it
+// forwarded writes to one
 func (rs *RaftStorage) Close() error {
 	// The cluster manages the lifecycle of nodes
 	// Individual storage instances don't need to close the cluster
+	rs.forwardMu.Lock()
+	defer rs.forwardMu.Unlock()
+
+	if rs.forwardConn != nil {
+		err := rs.forwardConn.Close()
+		rs.forwardConn, rs.forwardAddr = nil, ""
+		return err
+	}
 	return nil
 }
 
-// Size returns the number of keys (not implemented for Raft storage)
+// Size returns the number of keys in the committed state machine.
+// It waits on a read barrier first, so a follower reports the same
+// count the leader has committed. Returns -1 if no barrier can be reached.
 func (rs *RaftStorage) Size() int {
-	// This would require a special Raft command to count keys
-	// For now, return -1 to indicate not supported
-	return -1
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	node, err := rs.readBarrier()
+	if err != nil {
+		log.Printf("Size read barrier failed on node %s: %v", rs.nodeID, err)
+		return -1
+	}
+
+	return node.storage.Size()
+}
+
+// Stats returns the stats of the committed state machine's storage, after
+// waiting on a read barrier like Size
+func (rs *RaftStorage) Stats() (storage.StorageStats, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	node, err := rs.readBarrier()
+	if err != nil {
+		return storage.StorageStats{}, err
+	}
+
+	return node.storage.Stats()
+}
+
+// Limits returns the limits of the local node's state machine storage,
+// or none if the node isn't registered. Every node is expected to run the
+// same kind of storage, so these are the limits a write meets everywhere.
+func (rs *RaftStorage) Limits() storage.Limits {
+	node, err := rs.cluster.GetNode(rs.nodeID)
+	if err != nil {
+		return storage.Limits{}
+	}
+	return node.storage.Limits()
+}
+
+// Tail returns the n largest keys from the committed state machine.
+// Like Size, it waits on a read barrier before reading.
+func (rs *RaftStorage) Tail(n int) ([]storage.KV, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	node, err := rs.readBarrier()
+	if err != nil {
+		return nil, err
+	}
+
+	return node.storage.Tail(n)
+}
+
+// Fingerprint returns the fingerprint of the committed state machine.
+// Like Size, it waits on a read barrier before reading, so nodes that
+// have applied the same entries report the same fingerprint.
+func (rs *RaftStorage) Fingerprint() ([]byte, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	node, err := rs.readBarrier()
+	if err != nil {
+		return nil, err
+	}
+
+	return node.storage.Fingerprint()
+}
+
+// SplitRanges splits the committed state machine's keyspace, after
+// waiting on a read barrier like Size
+func (rs *RaftStorage) SplitRanges(n int) ([]storage.KeyRange, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	node, err := rs.readBarrier()
+	if err != nil {
+		return nil, err
+	}
+
+	return node.storage.SplitRanges(n)
+}
+
+// Scan returns an iterator over the committed state machine, after
+// waiting on a read barrier like Size. The iterator reads this node's
+// storage directly, so entries applied while it is open may or may not be
+// seen.
+func (rs *RaftStorage) Scan(start, end []byte) (storage.Iterator, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	node, err := rs.readBarrier()
+	if err != nil {
+		return nil, err
+	}
+
+	return node.storage.Scan(start, end)
+}
+
+// ScanWithConsistency returns an iterator over the committed state machine
+// with the chosen consistency, after a read barrier like Scan. With
+// ScanConsistentPrefix the range is read from this node's storage as of a
+// single point in time, so entries applied while the iterator is open are
+// never seen, and it holds every entry committed before the call.
+func (rs *RaftStorage) ScanWithConsistency(start, end []byte, consistency storage.ScanConsistency) (storage.Iterator, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	node, err := rs.readBarrier()
+	if err != nil {
+		return nil, err
+	}
+
+	return node.storage.ScanWithConsistency(start, end, consistency)
+}
+
+// ObserveApplied registers applied and reset with the local node; see
+// RaftNode.ObserveApplied. Writes are versioned by the index of the log
+// entry that made them, so every node numbers the same writes the same.
+func (rs *RaftStorage) ObserveApplied(applied func(version int64, ops []storage.BatchOp), reset func(version int64)) (int64, error) {
+	node, err := rs.cluster.GetNode(rs.nodeID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get node: %v", err)
+	}
+
+	index := node.ObserveApplied(func(index int, ops []storage.BatchOp) {
+		applied(int64(index), ops)
+	}, func(index int) {
+		reset(int64(index))
+	})
+	return int64(index), nil
+}
+
+// VersionedGet reads key from the committed state machine after a read
+// barrier like Scan, and returns it with the index of the last log entry
+// applied when it was read
+func (rs *RaftStorage) VersionedGet(key []byte) ([]byte, int64, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	node, err := rs.readBarrier()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	value, index, err := node.getApplied(key)
+	return value, int64(index), err
+}
+
+// VersionedScan opens a point-in-time scan of the committed state machine
+// after a read barrier like Scan, and returns it with the index of the
+// last log entry the scan holds
+func (rs *RaftStorage) VersionedScan(start, end []byte) (storage.Iterator, int64, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	node, err := rs.readBarrier()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	it, index, err := node.scanApplied(start, end)
+	return it, int64(index), err
+}
+
+// ScanReverse returns a descending iterator over the committed state
+// machine, after a read barrier like Scan
+func (rs *RaftStorage) ScanReverse(start, end []byte) (storage.Iterator, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	node, err := rs.readBarrier()
+	if err != nil {
+		return nil, err
+	}
+
+	return node.storage.ScanReverse(start, end)
+}
+
+// ScanPrefix returns a prefix iterator over the committed state machine,
+// after a read barrier like Scan
+func (rs *RaftStorage) ScanPrefix(prefix []byte) (storage.Iterator, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	node, err := rs.readBarrier()
+	if err != nil {
+		return nil, err
+	}
+
+	return node.storage.ScanPrefix(prefix)
+}
+
+// Keys lists the committed state machine's keys, after a read barrier
+// like Scan
+func (rs *RaftStorage) Keys() ([][]byte, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	node, err := rs.readBarrier()
+	if err != nil {
+		return nil, err
+	}
+
+	return node.storage.Keys()
+}
+
+// Backup backs up the committed state machine, after a read barrier like
+// Scan. The backup is of a single node's storage, so it restores without
+// the Raft log.
+func (rs *RaftStorage) Backup(w io.Writer) error {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	node, err := rs.readBarrier()
+	if err != nil {
+		return err
+	}
+
+	return node.storage.Backup(w)
+}
+
+// readBarrier waits until the local state machine has applied every entry
+// committed before the read began, so reads from it are linearizable. The
+// read index comes from the leader through the ReadIndex protocol: the
+// leader confirms with a majority that it still leads, so a deposed leader
+// fails the read instead of serving stale data. A follower gets the index
+// from the leader over the Raft transport and then reads its own state
+// machine. It returns the local node once it has caught up, or an error
+// wrapping ErrTooStale if it is outside its staleness bounds or hasn't
+// caught up within readBarrierTimeout.
+func (rs *RaftStorage) readBarrier() (*RaftNode, error) {
+	node, err := rs.cluster.GetNode(rs.nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node: %v", err)
+	}
+
+	// A node that can't apply entries can't catch up to any read index
+	if err := node.Health(); err != nil {
+		return nil, err
+	}
+
+	readIndex, err := node.leaderReadIndex()
+	if err != nil {
+		return nil, err
+	}
+	if !node.IsLeader() {
+		if err := rs.checkStaleness(node, readIndex); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := node.WaitForApplied(readIndex, readBarrierTimeout); err != nil {
+		return nil, fmt.Errorf("%w: %w: %v", ErrTooStale, errBehind, err)
+	}
+	return node, nil
+}
+
+// checkStaleness returns ErrTooStale if the follower node is outside the
+// configured staleness bounds relative to the leader's commit index
+func (rs *RaftStorage) checkStaleness(node *RaftNode, commitIndex int) error {
+	if rs.maxIndexLag > 0 {
+		if lag := commitIndex - node.LastApplied(); lag > rs.maxIndexLag {
+			return fmt.Errorf("%w: %d entries behind the leader", ErrTooStale, lag)
+		}
+	}
+
+	if rs.maxContactAge > 0 {
+		if age := time.Since(node.LastLeaderContact()); age > rs.maxContactAge {
+			return fmt.Errorf("%w: no leader contact for %v", ErrTooStale, age.Round(time.Millisecond))
+		}
+	}
+
+	return nil
 }
 
 // GetClusterInfo returns information about the Raft cluster
@@ -130,6 +865,38 @@ func (rs *RaftStorage) IsLeader() bool {
 	return node.IsLeader()
 }
 
+// Health returns nil if this node is applying its log and its state
+// machine's storage answers a read. Unlike Get it doesn't need a leader,
+// so a follower can report healthy storage during an election.
+func (rs *RaftStorage) Health() error {
+	node, err := rs.cluster.GetNode(rs.nodeID)
+	if err != nil {
+		return err
+	}
+	if err := node.Health(); err != nil {
+		return err
+	}
+
+	if _, err := node.storage.Get([]byte("\x00health")); err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return err
+	}
+	return nil
+}
+
+// NodeID returns the ID of the node this storage is bound to
+func (rs *RaftStorage) NodeID() string {
+	return rs.nodeID
+}
+
+// LeaderID returns the ID of the current leader
+func (rs *RaftStorage) LeaderID() (string, error) {
+	leader, err := rs.cluster.GetLeader()
+	if err != nil {
+		return "", err
+	}
+	return leader.GetID(), nil
+}
+
 // GetLeaderAddress returns the address of the current leader
 func (rs *RaftStorage) GetLeaderAddress() (string, error) {
 	leader, err := rs.cluster.GetLeader()
@@ -0,0 +1,581 @@
+package raft
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+// freePort returns a TCP port that is currently unused on localhost.
+func freePort(t testing.TB) int {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+// startTestCluster starts size Raft nodes backed by Badger in temporary
+// directories and registers them in a private GlobalCluster.
+func startTestCluster(t testing.TB, size int) *GlobalCluster {
+	return startTestClusterWith(t, size, func() storage.Storage {
+		store, err := storage.NewBadgerStorage(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+// startTestClusterWith is startTestCluster with each node's storage
+// created by newStore
+func startTestClusterWith(t testing.TB, size int, newStore func() storage.Storage) *GlobalCluster {
+	cluster := newGlobalCluster()
+
+	addrs := make(map[string]string)
+	for i := 1; i <= size; i++ {
+		addrs[fmt.Sprintf("node%d", i)] = ":" + strconv.Itoa(freePort(t))
+	}
+
+	for id, addr := range addrs {
+		peers := make(map[string]string)
+		for peerID, peerAddr := range addrs {
+			if peerID != id {
+				peers[peerID] = peerAddr
+			}
+		}
+
+		node := NewRaftNode(id, addr, peers, newStore())
+		if err := cluster.RegisterNode(node); err != nil {
+			t.Fatal(err)
+		}
+		if err := node.StartRPCServer(); err != nil {
+			t.Fatal(err)
+		}
+		if err := node.Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Cleanup(func() {
+		for _, node := range cluster.GetAllNodes() {
+			node.Stop()
+			node.storage.Close()
+		}
+	})
+
+	return cluster
+}
+
+// waitForLeader waits until the cluster has elected a leader.
+func waitForLeader(t testing.TB, cluster *GlobalCluster) *RaftNode {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if leader, err := cluster.GetLeader(); err == nil {
+			return leader
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("no leader elected")
+	return nil
+}
+
+func TestRaftStorage_SizeOnFollowerSeesCommittedWrites(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	leader := waitForLeader(t, cluster)
+
+	var followerID string
+	for id, node := range cluster.GetAllNodes() {
+		if node != leader {
+			followerID = id
+			break
+		}
+	}
+
+	leaderStorage := NewRaftStorage(cluster, leader.GetID())
+	for i := 0; i < 3; i++ {
+		key := []byte(fmt.Sprintf("key%d", i))
+		if err := leaderStorage.Put(key, []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	// Read immediately from the follower; the barrier must hide its lag
+	followerStorage := NewRaftStorage(cluster, followerID)
+	if size := followerStorage.Size(); size != 3 {
+		t.Errorf("Expected size 3 on follower, got %d", size)
+	}
+}
+
+func TestRaftStorage_StaleFollowerRefusesReads(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	leader := waitForLeader(t, cluster)
+
+	var follower *RaftNode
+	for _, node := range cluster.GetAllNodes() {
+		if node != leader {
+			follower = node
+			break
+		}
+	}
+
+	leaderStorage := NewRaftStorage(cluster, leader.GetID())
+	if err := leaderStorage.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	followerStorage := NewRaftStorage(cluster, follower.GetID())
+	followerStorage.SetMaxStaleness(2, 0)
+	if _, err := followerStorage.Get([]byte("key")); err != nil {
+		t.Fatalf("Get on caught-up follower failed: %v", err)
+	}
+
+	// Stop replicating to the follower, and keep it from starting an
+	// election that would disturb the leader
+	follower.mu.Lock()
+	follower.electionTimeout = time.Hour
+	follower.mu.Unlock()
+	leader.mu.Lock()
+	delete(leader.peers, follower.GetID())
+	leader.mu.Unlock()
+
+	for i := 0; i < 5; i++ {
+		key := []byte(fmt.Sprintf("key%d", i))
+		if err := leaderStorage.Put(key, []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	if _, err := followerStorage.Get([]byte("key")); !errors.Is(err, ErrTooStale) {
+		t.Errorf("Expected ErrTooStale from lagging follower, got %v", err)
+	}
+
+	// A time bound alone also trips once heartbeats have stopped
+	timeBound := NewRaftStorage(cluster, follower.GetID())
+	timeBound.SetMaxStaleness(0, 200*time.Millisecond)
+	time.Sleep(300 * time.Millisecond)
+	if _, err := timeBound.Get([]byte("key")); !errors.Is(err, ErrTooStale) {
+		t.Errorf("Expected ErrTooStale without leader contact, got %v", err)
+	}
+
+	// The leader itself is never stale
+	leaderStorage.SetMaxStaleness(2, 200*time.Millisecond)
+	if _, err := leaderStorage.Get([]byte("key")); err != nil {
+		t.Errorf("Get on leader failed: %v", err)
+	}
+}
+
+// syncCounter counts Sync calls on the wrapped storage
+type syncCounter struct {
+	storage.Storage
+	mu    sync.Mutex
+	syncs int
+}
+
+func (s *syncCounter) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.syncs++
+	return nil
+}
+
+func TestRaftStorage_SyncOnLeader(t *testing.T) {
+	cluster := startTestClusterWith(t, 3, func() storage.Storage {
+		store, err := storage.NewBadgerStorage(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &syncCounter{Storage: store}
+	})
+	leader := waitForLeader(t, cluster)
+
+	leaderStorage := NewRaftStorage(cluster, leader.GetID())
+	if err := leaderStorage.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := leaderStorage.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	store := leader.storage.(*syncCounter)
+	store.mu.Lock()
+	syncs := store.syncs
+	store.mu.Unlock()
+	if syncs != 1 {
+		t.Errorf("Expected leader storage synced once, got %d", syncs)
+	}
+	if _, err := store.Get([]byte("key")); err != nil {
+		t.Errorf("Expected write applied before sync: %v", err)
+	}
+
+	for id, node := range cluster.GetAllNodes() {
+		if node != leader {
+			if err := NewRaftStorage(cluster, id).Sync(); err == nil {
+				t.Error("Expected Sync on a follower to fail")
+			}
+			break
+		}
+	}
+}
+
+func TestRaftStorage_StaleReadsDuringElection(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	leader := waitForLeader(t, cluster)
+
+	if err := NewRaftStorage(cluster, leader.GetID()).Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	index := leaderLogLen(leader)
+	var stores []*RaftStorage
+	var strict *RaftStorage
+	for id, node := range cluster.GetAllNodes() {
+		if err := node.WaitForApplied(index, 2*time.Second); err != nil {
+			t.Fatal(err)
+		}
+		rs := NewRaftStorage(cluster, id)
+		rs.SetStaleReadsDuringElection(5 * time.Second)
+		stores = append(stores, rs)
+		strict = NewRaftStorage(cluster, id)
+	}
+
+	// Force an election; reads keep working on every node, the former
+	// leader included, until a new leader is established
+	leader.StepDown()
+	staleReads, strictFailures := 0, 0
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := cluster.GetLeader(); err == nil {
+			break
+		}
+		for _, rs := range stores {
+			value, stale, err := rs.GetWithStaleness([]byte("key"))
+			if err != nil {
+				t.Fatalf("%s: Expected a stale read during the election, got %v", rs.NodeID(), err)
+			}
+			if string(value) != "value" {
+				t.Errorf("%s: Expected value, got %q", rs.NodeID(), value)
+			}
+			if stale {
+				staleReads++
+			}
+		}
+		if _, err := strict.Get([]byte("key")); err != nil {
+			strictFailures++
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if staleReads == 0 || strictFailures == 0 {
+		t.Fatalf("Expected an election window, saw %d stale reads and %d strict failures", staleReads, strictFailures)
+	}
+
+	// Reads get their read index from the leader each node knows of, so
+	// wait until every node has heard from the new one
+	newLeader := waitForLeader(t, cluster)
+	for id, node := range cluster.GetAllNodes() {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			if leaderID, _, ok := node.Leader(); ok && leaderID == newLeader.GetID() {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s never heard from the new leader", id)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	for _, rs := range stores {
+		value, stale, err := rs.GetWithStaleness([]byte("key"))
+		if err != nil || string(value) != "value" {
+			t.Errorf("%s: Expected value after the election, got %q, %v", rs.NodeID(), value, err)
+		}
+		if stale {
+			t.Errorf("%s: Expected a linearizable read once a leader is elected", rs.NodeID())
+		}
+	}
+}
+
+func TestRaftStorage_BinaryValuesRoundTrip(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	leader := waitForLeader(t, cluster)
+
+	key := []byte("config key\x00")
+	value := []byte("production db\x00with\x00NULs ")
+
+	leaderStorage := NewRaftStorage(cluster, leader.GetID())
+	if err := leaderStorage.Put(key, value); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// Every replica must have applied the same bytes
+	for id := range cluster.GetAllNodes() {
+		got, err := NewRaftStorage(cluster, id).Get(key)
+		if err != nil {
+			t.Errorf("Get on %s failed: %v", id, err)
+		} else if !bytes.Equal(got, value) {
+			t.Errorf("Expected %q on %s, got %q", value, id, got)
+		}
+	}
+}
+
+// brokenReadStorage fails every Get with an error other than
+// ErrKeyNotFound
+type brokenReadStorage struct {
+	storage.Storage
+}
+
+var errBrokenRead = errors.New("read failed")
+
+func (s brokenReadStorage) Get(key []byte) ([]byte, error) {
+	return nil, errBrokenRead
+}
+
+func TestRaftStorage_ConditionalWritesReportReadErrors(t *testing.T) {
+	cluster := startTestClusterWith(t, 3, func() storage.Storage {
+		return brokenReadStorage{storage.NewMemStorage()}
+	})
+	leader := waitForLeader(t, cluster)
+	rs := NewRaftStorage(cluster, leader.GetID())
+
+	// A read that fails isn't the same as a mismatch or a missing key
+	if deleted, err := rs.DeleteIf([]byte("key"), []byte("value")); !errors.Is(err, errBrokenRead) || deleted {
+		t.Errorf("Expected DeleteIf to report the read error, got %v (%v)", deleted, err)
+	}
+	if swapped, err := rs.CompareAndSwap([]byte("key"), nil, []byte("value")); !errors.Is(err, errBrokenRead) || swapped {
+		t.Errorf("Expected CompareAndSwap to report the read error, got %v (%v)", swapped, err)
+	}
+}
+
+func TestRaftStorage_FollowerScanConsistentPrefix(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	leader := waitForLeader(t, cluster)
+
+	var followerID string
+	for id, node := range cluster.GetAllNodes() {
+		if node != leader {
+			followerID = id
+			break
+		}
+	}
+	leaderStorage := NewRaftStorage(cluster, leader.GetID())
+	followerStorage := NewRaftStorage(cluster, followerID)
+
+	const keys = 10
+	const rounds = 20
+	for k := 0; k < keys; k++ {
+		if err := leaderStorage.Put([]byte(fmt.Sprintf("key%02d", k)), []byte("0")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each round rewrites every key in order with the round number
+	var completed atomic.Int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for round := 1; round <= rounds; round++ {
+			for k := 0; k < keys; k++ {
+				if err := leaderStorage.Put([]byte(fmt.Sprintf("key%02d", k)), []byte(strconv.Itoa(round))); err != nil {
+					t.Errorf("Put failed: %v", err)
+					return
+				}
+			}
+			completed.Store(int64(round))
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		before := int(completed.Load())
+		it, err := followerStorage.ScanWithConsistency(nil, nil, storage.ScanConsistentPrefix)
+		if err != nil {
+			t.Fatalf("Scan on follower failed: %v", err)
+		}
+		var seen []int
+		for it.Next() {
+			r, _ := strconv.Atoi(string(it.Value()))
+			seen = append(seen, r)
+		}
+		if err := it.Close(); err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		if len(seen) != keys {
+			t.Fatalf("Expected %d keys, got %d", keys, len(seen))
+		}
+
+		// No later write shows without an earlier one, and every round
+		// acknowledged before the scan began is visible
+		for i := 1; i < len(seen); i++ {
+			if seen[i] > seen[i-1] || seen[0]-seen[i] > 1 {
+				t.Fatalf("Torn view: %v", seen)
+			}
+		}
+		if seen[keys-1] < before {
+			t.Fatalf("Expected at least round %d, got %v", before, seen)
+		}
+	}
+}
+
+// startUnsettledLeader starts a single-node cluster whose leader holds a
+// put of key to value from its previous term that it doesn't know has
+// committed yet, as a newly elected leader does until an entry from its
+// own term commits
+func startUnsettledLeader(t *testing.T, key, value []byte) (*RaftNode, *RaftStorage) {
+	cluster := startTestCluster(t, 1)
+	leader := waitForLeader(t, cluster)
+
+	leader.mu.Lock()
+	leader.log = append(leader.log, LogEntry{
+		Term:    leader.currentTerm,
+		Index:   leader.lastLogIndex() + 1,
+		Command: encodePutCommand(key, value),
+	})
+	leader.currentTerm++
+	leader.mu.Unlock()
+
+	return leader, NewRaftStorage(cluster, leader.GetID())
+}
+
+func TestRaftStorage_DeleteIfOnNewLeaderSeesEarlierWrites(t *testing.T) {
+	leader, rs := startUnsettledLeader(t, []byte("key"), []byte("value"))
+
+	deleted, err := rs.DeleteIf([]byte("key"), []byte("value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !deleted {
+		t.Error("Expected DeleteIf to see the write from the previous term")
+	}
+	if _, err := leader.storage.Get([]byte("key")); !errors.Is(err, storage.ErrKeyNotFound) {
+		t.Errorf("Expected key to be deleted, got %v", err)
+	}
+}
+
+func TestRaftStorage_CompareAndSwapOnNewLeaderSeesEarlierWrites(t *testing.T) {
+	leader, rs := startUnsettledLeader(t, []byte("key"), []byte("old"))
+
+	swapped, err := rs.CompareAndSwap([]byte("key"), []byte("old"), []byte("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !swapped {
+		t.Error("Expected CompareAndSwap to see the write from the previous term")
+	}
+	if value, err := leader.storage.Get([]byte("key")); err != nil || string(value) != "new" {
+		t.Errorf("Expected new, got %q (%v)", value, err)
+	}
+}
+
+func TestRaftStorage_IncrementOnNewLeaderSeesEarlierWrites(t *testing.T) {
+	_, rs := startUnsettledLeader(t, []byte("counter"), storage.EncodeCounter(5))
+
+	n, err := rs.Increment([]byte("counter"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 6 {
+		t.Errorf("Expected 6, got %d", n)
+	}
+}
+
+func TestRaftStorage_VersionedScanOnFollowerPairsWithObservedWrites(t *testing.T) {
+	cluster := startTestCluster(t, 3)
+	leader := waitForLeader(t, cluster)
+
+	var follower *RaftNode
+	for _, node := range cluster.GetAllNodes() {
+		if node != leader {
+			follower = node
+			break
+		}
+	}
+	leaderStorage := NewRaftStorage(cluster, leader.GetID())
+	followerStorage := NewRaftStorage(cluster, follower.GetID())
+
+	type write struct {
+		version int64
+		ops     []storage.BatchOp
+	}
+	var mu sync.Mutex
+	var writes []write
+	if _, err := followerStorage.ObserveApplied(func(version int64, ops []storage.BatchOp) {
+		mu.Lock()
+		writes = append(writes, write{version, ops})
+		mu.Unlock()
+	}, func(version int64) {
+		t.Errorf("Unexpected reset at %d", version)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			if err := leaderStorage.Put([]byte(fmt.Sprintf("key%d", i%10)), []byte(strconv.Itoa(i))); err != nil {
+				t.Errorf("Put failed: %v", err)
+				return
+			}
+		}
+	}()
+
+	// Take the snapshot while the writes are being applied
+	time.Sleep(20 * time.Millisecond)
+	it, version, err := followerStorage.VersionedScan(nil, nil)
+	if err != nil {
+		t.Fatalf("VersionedScan failed: %v", err)
+	}
+	state := make(map[string]string)
+	for it.Next() {
+		state[string(it.Key())] = string(it.Value())
+	}
+	if err := it.Close(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	if err := follower.WaitForApplied(leader.CommitIndex(), 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// The writes observed after the snapshot's version are exactly the
+	// ones it misses: each is newer than the snapshot's value for its key,
+	// and together they bring it to the final state
+	mu.Lock()
+	for _, w := range writes {
+		if w.version <= version {
+			continue
+		}
+		for _, op := range w.ops {
+			if have, ok := state[string(op.Key)]; ok {
+				n, _ := strconv.Atoi(have)
+				if m, _ := strconv.Atoi(string(op.Value)); m <= n {
+					t.Errorf("Write %s=%s at %d is already in the snapshot at %d", op.Key, op.Value, w.version, version)
+				}
+			}
+			state[string(op.Key)] = string(op.Value)
+		}
+	}
+	mu.Unlock()
+
+	for k := 0; k < 10; k++ {
+		key := fmt.Sprintf("key%d", k)
+		want := strconv.Itoa(190 + k)
+		if state[key] != want {
+			t.Errorf("%s: expected %s, got %q", key, want, state[key])
+		}
+	}
+}
@@ -0,0 +1,376 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"godatabase/internal/network"
+	"godatabase/internal/rpc/proto"
+)
+
+const (
+	// rpcTimeout bounds a single Raft RPC to a peer
+	rpcTimeout = 5 * time.Second
+
+	// maxRPCMessageSize is the largest Raft RPC a node's own server
+	// accepts. Catching up a follower can send many entries at once.
+	maxRPCMessageSize = 64 << 20
+)
+
+// raftService serves the Raft gRPC service with a node's RaftRPC handlers
+type raftService struct {
+	proto.UnimplementedRaftServer
+	rpc *RaftRPC
+}
+
+// RegisterService registers the node's Raft RPC handlers on server, so
+// they share its listener with other services such as Storage. The node's
+// address must then be the address server listens on. It must be called
+// before server starts serving.
+func (n *RaftNode) RegisterService(server grpc.ServiceRegistrar) {
+	proto.RegisterRaftServer(server, &raftService{rpc: &RaftRPC{node: n}})
+}
+
+// StartRPCServer starts a gRPC server for this node's Raft RPCs alone,
+// listening on the node's address
+func (n *RaftNode) StartRPCServer() error {
+	// Bind to the canonical form of the address peers are given, so a
+	// bare ":port" and "localhost:port" name the same listener
+	address, err := network.NormalizeAddress(n.address)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer(grpc.MaxRecvMsgSize(maxRPCMessageSize))
+	n.RegisterService(server)
+	log.Printf("Raft RPC server listening on %s", address)
+
+	n.mu.Lock()
+	n.rpcServer = server
+	n.mu.Unlock()
+
+	go server.Serve(listener)
+	return nil
+}
+
+// stopRPCServer stops the server started by StartRPCServer, if any,
+// dropping its connections
+func (n *RaftNode) stopRPCServer() {
+	n.mu.Lock()
+	server := n.rpcServer
+	n.rpcServer = nil
+	n.mu.Unlock()
+
+	if server != nil {
+		server.Stop()
+	}
+}
+
+// peerClient returns a Raft client for the peer at addr, reusing the
+// node's connection to it. A connection that failed would otherwise wait
+// out gRPC's reconnect backoff, failing every RPC in the meantime, so it
+// reconnects right away: Raft's own timers already pace the attempts.
+func (n *RaftNode) peerClient(addr string) (proto.RaftClient, error) {
+	n.connMu.Lock()
+	defer n.connMu.Unlock()
+
+	if conn, ok := n.conns[addr]; ok {
+		if conn.GetState() == connectivity.TransientFailure {
+			conn.ResetConnectBackoff()
+		}
+		return proto.NewRaftClient(conn), nil
+	}
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	n.conns[addr] = conn
+	return proto.NewRaftClient(conn), nil
+}
+
+// closeConns closes the node's connections to its peers
+func (n *RaftNode) closeConns() {
+	n.connMu.Lock()
+	defer n.connMu.Unlock()
+
+	for addr, conn := range n.conns {
+		conn.Close()
+		delete(n.conns, addr)
+	}
+}
+
+// sendRequestVote sends a request vote RPC to a peer
+func (n *RaftNode) sendRequestVote(peerAddr string, req RequestVoteRequest) (*RequestVoteResponse, error) {
+	client, err := n.peerClient(peerAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(n.ctx, rpcTimeout)
+	defer cancel()
+
+	resp, err := client.RequestVote(ctx, &proto.RequestVoteRequest{
+		Term:         int64(req.Term),
+		CandidateId:  req.CandidateID,
+		LastLogIndex: int64(req.LastLogIndex),
+		LastLogTerm:  int64(req.LastLogTerm),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RequestVoteResponse{
+		Term:        int(resp.Term),
+		VoteGranted: resp.VoteGranted,
+	}, nil
+}
+
+// sendAppendEntries sends an append entries request to a peer
+func (n *RaftNode) sendAppendEntries(peerAddr string, req AppendEntriesRequest) (*AppendEntriesResponse, error) {
+	client, err := n.peerClient(peerAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(n.ctx, rpcTimeout)
+	defer cancel()
+
+	entries := make([]*proto.LogEntry, len(req.Entries))
+	for i, entry := range req.Entries {
+		entries[i] = &proto.LogEntry{Term: int64(entry.Term), Index: int64(entry.Index), Command: entry.Command}
+	}
+	resp, err := client.AppendEntries(ctx, &proto.AppendEntriesRequest{
+		Term:         int64(req.Term),
+		LeaderId:     req.LeaderID,
+		PrevLogIndex: int64(req.PrevLogIndex),
+		PrevLogTerm:  int64(req.PrevLogTerm),
+		Entries:      entries,
+		LeaderCommit: int64(req.LeaderCommit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &AppendEntriesResponse{
+		Term:         int(resp.Term),
+		Success:      resp.Success,
+		LastLogIndex: int(resp.LastLogIndex),
+	}, nil
+}
+
+// sendInstallSnapshot sends a snapshot chunk to a peer
+func (n *RaftNode) sendInstallSnapshot(peerAddr string, req InstallSnapshotRequest) (*InstallSnapshotResponse, error) {
+	client, err := n.peerClient(peerAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(n.ctx, rpcTimeout)
+	defer cancel()
+
+	resp, err := client.InstallSnapshot(ctx, &proto.InstallSnapshotRequest{
+		Term:              int64(req.Term),
+		LeaderId:          req.LeaderID,
+		LastIncludedIndex: int64(req.LastIncludedIndex),
+		LastIncludedTerm:  int64(req.LastIncludedTerm),
+		Offset:            req.Offset,
+		Data:              req.Data,
+		Done:              req.Done,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &InstallSnapshotResponse{
+		Term:   int(resp.Term),
+		Stored: resp.Stored,
+	}, nil
+}
+
+// sendTimeoutNow asks a peer to start an election immediately
+func (n *RaftNode) sendTimeoutNow(peerAddr string, req TimeoutNowRequest) (*TimeoutNowResponse, error) {
+	client, err := n.peerClient(peerAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(n.ctx, rpcTimeout)
+	defer cancel()
+
+	resp, err := client.TimeoutNow(ctx, &proto.TimeoutNowRequest{
+		Term:     int64(req.Term),
+		LeaderId: req.LeaderID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TimeoutNowResponse{
+		Term:    int(resp.Term),
+		Success: resp.Success,
+	}, nil
+}
+
+// sendReadIndex asks the leader at peerAddr for a read index. A peer that
+// isn't the leader answers with an error wrapping ErrNotLeader.
+func (n *RaftNode) sendReadIndex(peerAddr string) (int, error) {
+	client, err := n.peerClient(peerAddr)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(n.ctx, readBarrierTimeout)
+	defer cancel()
+
+	resp, err := client.ReadIndex(ctx, &proto.ReadIndexRequest{})
+	switch status.Code(err) {
+	case codes.FailedPrecondition:
+		return 0, fmt.Errorf("%w: %s", ErrNotLeader, status.Convert(err).Message())
+	case codes.Unavailable:
+		// Asking for a read index changes nothing, so an unreachable
+		// leader is as good as none
+		return 0, fmt.Errorf("%w: %s", ErrNoLeader, status.Convert(err).Message())
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int(resp.Index), nil
+}
+
+// stopped refuses RPCs for a node that has stopped but whose handlers are
+// still registered on a shared server
+func (s *raftService) stopped() error {
+	if s.rpc.node.ctx.Err() != nil {
+		return status.Errorf(codes.Unavailable, "node %s stopped", s.rpc.node.id)
+	}
+	return nil
+}
+
+// RequestVote implements the RequestVote RPC method
+func (s *raftService) RequestVote(ctx context.Context, req *proto.RequestVoteRequest) (*proto.RequestVoteResponse, error) {
+	if err := s.stopped(); err != nil {
+		return nil, err
+	}
+
+	var resp RequestVoteResponse
+	err := s.rpc.RequestVote(RequestVoteRequest{
+		Term:         int(req.Term),
+		CandidateID:  req.CandidateId,
+		LastLogIndex: int(req.LastLogIndex),
+		LastLogTerm:  int(req.LastLogTerm),
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.RequestVoteResponse{
+		Term:        int64(resp.Term),
+		VoteGranted: resp.VoteGranted,
+	}, nil
+}
+
+// AppendEntries implements the AppendEntries RPC method
+func (s *raftService) AppendEntries(ctx context.Context, req *proto.AppendEntriesRequest) (*proto.AppendEntriesResponse, error) {
+	if err := s.stopped(); err != nil {
+		return nil, err
+	}
+
+	entries := make([]LogEntry, len(req.Entries))
+	for i, entry := range req.Entries {
+		entries[i] = LogEntry{Term: int(entry.Term), Index: int(entry.Index), Command: entry.Command}
+	}
+	var resp AppendEntriesResponse
+	err := s.rpc.AppendEntries(AppendEntriesRequest{
+		Term:         int(req.Term),
+		LeaderID:     req.LeaderId,
+		PrevLogIndex: int(req.PrevLogIndex),
+		PrevLogTerm:  int(req.PrevLogTerm),
+		Entries:      entries,
+		LeaderCommit: int(req.LeaderCommit),
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.AppendEntriesResponse{
+		Term:         int64(resp.Term),
+		Success:      resp.Success,
+		LastLogIndex: int64(resp.LastLogIndex),
+	}, nil
+}
+
+// InstallSnapshot implements the InstallSnapshot RPC method
+func (s *raftService) InstallSnapshot(ctx context.Context, req *proto.InstallSnapshotRequest) (*proto.InstallSnapshotResponse, error) {
+	if err := s.stopped(); err != nil {
+		return nil, err
+	}
+
+	var resp InstallSnapshotResponse
+	err := s.rpc.InstallSnapshot(InstallSnapshotRequest{
+		Term:              int(req.Term),
+		LeaderID:          req.LeaderId,
+		LastIncludedIndex: int(req.LastIncludedIndex),
+		LastIncludedTerm:  int(req.LastIncludedTerm),
+		Offset:            req.Offset,
+		Data:              req.Data,
+		Done:              req.Done,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.InstallSnapshotResponse{
+		Term:   int64(resp.Term),
+		Stored: resp.Stored,
+	}, nil
+}
+
+// TimeoutNow implements the TimeoutNow RPC method
+func (s *raftService) TimeoutNow(ctx context.Context, req *proto.TimeoutNowRequest) (*proto.TimeoutNowResponse, error) {
+	if err := s.stopped(); err != nil {
+		return nil, err
+	}
+
+	var resp TimeoutNowResponse
+	err := s.rpc.TimeoutNow(TimeoutNowRequest{
+		Term:     int(req.Term),
+		LeaderID: req.LeaderId,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.TimeoutNowResponse{
+		Term:    int64(resp.Term),
+		Success: resp.Success,
+	}, nil
+}
+
+// ReadIndex implements the ReadIndex RPC method. A node that isn't the
+// leader refuses with codes.FailedPrecondition, and one that can't confirm
+// its leadership with codes.Unavailable.
+func (s *raftService) ReadIndex(ctx context.Context, req *proto.ReadIndexRequest) (*proto.ReadIndexResponse, error) {
+	if err := s.stopped(); err != nil {
+		return nil, err
+	}
+
+	index, err := s.rpc.node.readIndex()
+	if errors.Is(err, ErrNotLeader) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	return &proto.ReadIndexResponse{Index: int64(index)}, nil
+}
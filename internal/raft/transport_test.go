@@ -0,0 +1,80 @@
+package raft
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestRaftNode_ElectsLeaderOverSharedGRPCServer(t *testing.T) {
+	// As in raft-server, each node's Raft handlers share a gRPC server
+	// with other services rather than listening on a port of their own
+	addrs := make(map[string]string)
+	for i := 1; i <= 3; i++ {
+		addrs[fmt.Sprintf("grpc%d", i)] = "localhost:" + strconv.Itoa(freePort(t))
+	}
+
+	cluster := newGlobalCluster()
+	for id, addr := range addrs {
+		peers := make(map[string]string)
+		for peerID, peerAddr := range addrs {
+			if peerID != id {
+				peers[peerID] = peerAddr
+			}
+		}
+
+		node := NewRaftNode(id, addr, peers, newBadger(t))
+		if err := cluster.RegisterNode(node); err != nil {
+			t.Fatal(err)
+		}
+		server := grpc.NewServer()
+		node.RegisterService(server)
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go server.Serve(lis)
+		if err := node.Start(); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			server.Stop()
+			node.Stop()
+		})
+	}
+
+	leader := waitForLeader(t, cluster)
+	_, term := leader.GetState()
+
+	// Every follower hears from the leader of the same term
+	deadline := time.Now().Add(2 * time.Second)
+	for _, node := range cluster.GetAllNodes() {
+		for {
+			id, _, ok := node.Leader()
+			_, nodeTerm := node.GetState()
+			if ok && id == leader.GetID() && nodeTerm == term {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %s to follow %s in term %d, got leader %q in term %d", node.GetID(), leader.GetID(), term, id, nodeTerm)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	if err := leader.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// Heartbeats and replication reuse one connection per peer
+	leader.connMu.Lock()
+	conns := len(leader.conns)
+	leader.connMu.Unlock()
+	if conns != len(addrs)-1 {
+		t.Errorf("Expected %d peer connections, got %d", len(addrs)-1, conns)
+	}
+}
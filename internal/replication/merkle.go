@@ -0,0 +1,188 @@
+package replication
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"godatabase/internal/storage"
+)
+
+// DefaultMerkleDepth is the depth of the Merkle trees built by SyncWith.
+// A depth of 8 splits the keyspace into 256 ranges.
+const DefaultMerkleDepth = 8
+
+// MerkleTree hashes a storage's contents over a fixed set of key ranges.
+// Keys are assigned to ranges by hash rather than by value, see
+// storage.RangeOf, so two trees of the same depth always cover identical
+// ranges and can be compared node by node.
+type MerkleTree struct {
+	nodes [][]byte // heap layout: node i has children 2i+1 and 2i+2
+}
+
+// SyncStats reports the work done by a sync
+type SyncStats struct {
+	RangesCompared    int // tree nodes whose hashes were compared
+	RangesTransferred int // leaf ranges that differed and were repaired
+	KeysPushed        int // keys written to the replica
+	KeysDeleted       int // keys deleted from the replica
+}
+
+// rangeHasher is implemented by replicas that hash and read their own key
+// ranges, such as a client.Client for a remote server, so a sync only
+// transfers the range hashes and the ranges that differ rather than the
+// whole replica
+type rangeHasher interface {
+	RangeHashes(depth int) ([][]byte, error)
+	ScanRanges(depth int, ranges []int) (storage.Iterator, error)
+}
+
+// BuildMerkleTree hashes every key-value pair of s into a tree with
+// 2^depth leaf ranges
+func BuildMerkleTree(s storage.Storage, depth int) (*MerkleTree, error) {
+	leaves, err := rangeHashes(s, depth)
+	if err != nil {
+		return nil, err
+	}
+	if len(leaves) != 1<<depth {
+		return nil, fmt.Errorf("expected %d range hashes, got %d", 1<<depth, len(leaves))
+	}
+	return newMerkleTree(leaves), nil
+}
+
+// newMerkleTree builds a tree over the hashes of its leaf ranges, whose
+// number must be a power of two
+func newMerkleTree(leaves [][]byte) *MerkleTree {
+	t := &MerkleTree{
+		nodes: make([][]byte, 2*len(leaves)-1),
+	}
+	copy(t.nodes[len(leaves)-1:], leaves)
+
+	// Hash every internal node from the bottom up
+	for i := len(leaves) - 2; i >= 0; i-- {
+		h := sha256.New()
+		h.Write(t.nodes[2*i+1])
+		h.Write(t.nodes[2*i+2])
+		t.nodes[i] = h.Sum(nil)
+	}
+	return t
+}
+
+// Root returns the hash of the whole tree
+func (t *MerkleTree) Root() []byte {
+	return t.nodes[0]
+}
+
+// rangeHashes returns the hashes of s's 2^depth key ranges, computed by s
+// itself if it can
+func rangeHashes(s storage.Storage, depth int) ([][]byte, error) {
+	if rh, ok := s.(rangeHasher); ok {
+		return rh.RangeHashes(depth)
+	}
+	return storage.RangeHashes(s, depth)
+}
+
+// scanRanges returns the pairs in the given key ranges of s, read by s
+// itself if it can
+func scanRanges(s storage.Storage, depth int, ranges []int) ([]storage.KV, error) {
+	var it storage.Iterator
+	var err error
+	if rh, ok := s.(rangeHasher); ok {
+		it, err = rh.ScanRanges(depth, ranges)
+	} else {
+		it, err = storage.ScanRanges(s, depth, ranges)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return storage.Collect(it)
+}
+
+// diff walks both trees top-down and returns the leaf ranges that differ.
+// Subtrees with matching hashes are skipped entirely.
+func (t *MerkleTree) diff(other *MerkleTree, stats *SyncStats) []int {
+	leaves := (len(t.nodes) + 1) / 2
+	var differing []int
+
+	stack := []int{0}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		stats.RangesCompared++
+		if bytes.Equal(t.nodes[i], other.nodes[i]) {
+			continue
+		}
+		if i >= leaves-1 {
+			differing = append(differing, i-(leaves-1))
+			continue
+		}
+		stack = append(stack, 2*i+2, 2*i+1)
+	}
+
+	return differing
+}
+
+// SyncWith repairs replica so it matches the primary. Both sides are hashed
+// into Merkle trees and compared top-down; only the key ranges whose hashes
+// differ are read from both sides and repaired, so a mostly-consistent
+// replica receives just the keys that diverged. A replica that can hash
+// its own ranges, such as a client for a remote server, does so where its
+// data lives, and only sends the ranges that differ.
+func (rs *ReplicatedStorage) SyncWith(replica storage.Storage) (SyncStats, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	var stats SyncStats
+
+	primaryTree, err := BuildMerkleTree(rs.primary, DefaultMerkleDepth)
+	if err != nil {
+		return stats, fmt.Errorf("failed to hash primary: %v", err)
+	}
+	replicaTree, err := BuildMerkleTree(replica, DefaultMerkleDepth)
+	if err != nil {
+		return stats, fmt.Errorf("failed to hash replica: %v", err)
+	}
+
+	differing := primaryTree.diff(replicaTree, &stats)
+	if len(differing) == 0 {
+		return stats, nil
+	}
+	stats.RangesTransferred = len(differing)
+
+	want, err := scanRanges(rs.primary, DefaultMerkleDepth, differing)
+	if err != nil {
+		return stats, fmt.Errorf("failed to read primary: %v", err)
+	}
+	pairs, err := scanRanges(replica, DefaultMerkleDepth, differing)
+	if err != nil {
+		return stats, fmt.Errorf("failed to read replica: %v", err)
+	}
+	have := make(map[string][]byte, len(pairs))
+	for _, kv := range pairs {
+		have[string(kv.Key)] = kv.Value
+	}
+
+	// Push keys the replica is missing or holds a different value for
+	for _, kv := range want {
+		value, ok := have[string(kv.Key)]
+		delete(have, string(kv.Key))
+		if ok && bytes.Equal(value, kv.Value) {
+			continue
+		}
+		if err := replica.Put(kv.Key, kv.Value); err != nil {
+			return stats, fmt.Errorf("failed to push key: %v", err)
+		}
+		stats.KeysPushed++
+	}
+
+	// Whatever is left exists only on the replica
+	for key := range have {
+		if err := replica.Delete([]byte(key)); err != nil {
+			return stats, fmt.Errorf("failed to delete key: %v", err)
+		}
+		stats.KeysDeleted++
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,195 @@
+package replication
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"godatabase/internal/rpc"
+	"godatabase/internal/storage"
+	"godatabase/pkg/client"
+)
+
+// countingStorage counts the writes made to the wrapped storage
+type countingStorage struct {
+	storage.Storage
+	puts    int
+	deletes int
+}
+
+func (c *countingStorage) Put(key, value []byte) error {
+	c.puts++
+	return c.Storage.Put(key, value)
+}
+
+func (c *countingStorage) Delete(key []byte) error {
+	c.deletes++
+	return c.Storage.Delete(key)
+}
+
+func newBadger(t *testing.T) storage.Storage {
+	s, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestReplicatedStorage_SyncWithTransfersOnlyDivergentRange(t *testing.T) {
+	primary := newBadger(t)
+	replica := &countingStorage{Storage: newBadger(t)}
+
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprintf("key%04d", i))
+		value := []byte(fmt.Sprintf("value%d", i))
+		if err := primary.Put(key, value); err != nil {
+			t.Fatal(err)
+		}
+		if err := replica.Storage.Put(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rs, err := NewReplicatedStorage(primary, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// In sync: only the roots are compared
+	stats, err := rs.SyncWith(replica)
+	if err != nil {
+		t.Fatalf("SyncWith failed: %v", err)
+	}
+	if stats.RangesCompared != 1 || stats.RangesTransferred != 0 {
+		t.Errorf("Expected only the root compared, got %+v", stats)
+	}
+
+	// Diverge a single key on the replica
+	if err := replica.Storage.Put([]byte("key0500"), []byte("stale")); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err = rs.SyncWith(replica)
+	if err != nil {
+		t.Fatalf("SyncWith failed: %v", err)
+	}
+	if stats.RangesTransferred != 1 {
+		t.Errorf("Expected 1 range transferred, got %d", stats.RangesTransferred)
+	}
+	if stats.KeysPushed != 1 || replica.puts != 1 {
+		t.Errorf("Expected exactly 1 key pushed, got %d (%d puts)", stats.KeysPushed, replica.puts)
+	}
+	if stats.RangesCompared > 2*DefaultMerkleDepth+1 {
+		t.Errorf("Expected one root-to-leaf path compared, got %d nodes", stats.RangesCompared)
+	}
+
+	value, err := replica.Get([]byte("key0500"))
+	if err != nil || string(value) != "value500" {
+		t.Errorf("Expected value500 after sync, got %s (%v)", value, err)
+	}
+
+	// A key only the replica has is deleted
+	if err := replica.Storage.Put([]byte("extra"), []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = rs.SyncWith(replica)
+	if err != nil {
+		t.Fatalf("SyncWith failed: %v", err)
+	}
+	if stats.KeysDeleted != 1 || stats.KeysPushed != 0 {
+		t.Errorf("Expected 1 key deleted and none pushed, got %+v", stats)
+	}
+	if _, err := replica.Get([]byte("extra")); err == nil {
+		t.Error("Expected extra key to be deleted from the replica")
+	}
+}
+
+// remoteReplica is a client for a replica server that counts what it reads
+// back from the server
+type remoteReplica struct {
+	*client.Client
+	fullReads int
+	fetched   int
+}
+
+func (r *remoteReplica) Scan(start, end []byte) (storage.Iterator, error) {
+	r.fullReads++
+	return r.Client.Scan(start, end)
+}
+
+func (r *remoteReplica) Tail(n int) ([]storage.KV, error) {
+	r.fullReads++
+	return r.Client.Tail(n)
+}
+
+func (r *remoteReplica) ScanRanges(depth int, ranges []int) (storage.Iterator, error) {
+	it, err := r.Client.ScanRanges(depth, ranges)
+	if err != nil {
+		return nil, err
+	}
+	pairs, err := storage.Collect(it)
+	r.fetched += len(pairs)
+	return storage.NewSliceIterator(pairs), err
+}
+
+func TestReplicatedStorage_SyncWithRemoteReplicaFetchesOnlyDivergentRange(t *testing.T) {
+	primary := newBadger(t)
+	replicaStore := newBadger(t)
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+	server := rpc.NewServer(replicaStore)
+	go server.Start(addr)
+	t.Cleanup(server.Stop)
+
+	c, err := client.NewClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	replica := &remoteReplica{Client: c}
+
+	const keys = 1000
+	for i := 0; i < keys; i++ {
+		key := []byte(fmt.Sprintf("key%04d", i))
+		value := []byte(fmt.Sprintf("value%d", i))
+		if err := primary.Put(key, value); err != nil {
+			t.Fatal(err)
+		}
+		if err := replicaStore.Put(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := replicaStore.Put([]byte("key0500"), []byte("stale")); err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := NewReplicatedStorage(primary, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := rs.SyncWith(replica)
+	if err != nil {
+		t.Fatalf("SyncWith failed: %v", err)
+	}
+	if stats.RangesTransferred != 1 || stats.KeysPushed != 1 {
+		t.Errorf("Expected 1 range repaired with 1 key pushed, got %+v", stats)
+	}
+
+	// The replica hashed its own ranges and sent back only the one that
+	// differed
+	if replica.fullReads != 0 {
+		t.Errorf("Expected the replica not to be read in full, got %d full reads", replica.fullReads)
+	}
+	if replica.fetched == 0 || replica.fetched > keys/(1<<DefaultMerkleDepth)*4 {
+		t.Errorf("Expected only the divergent range fetched, got %d pairs", replica.fetched)
+	}
+	if value, err := replicaStore.Get([]byte("key0500")); err != nil || string(value) != "value500" {
+		t.Errorf("Expected value500 after sync, got %s (%v)", value, err)
+	}
+}
@@ -0,0 +1,122 @@
+package replication
+
+import (
+	"fmt"
+	"strings"
+
+	"godatabase/internal/storage"
+)
+
+// AllReplicas is the ReplicationPolicy.Replicas value that writes to every replica
+const AllReplicas = -1
+
+// ReplicationPolicy decides how writes to keys under a prefix reach the
+// replicas
+type ReplicationPolicy struct {
+	// Replicas is how many replicas receive each write, taken in the order
+	// they were configured. AllReplicas, or any count beyond the number
+	// of replicas, writes to all of them; zero keeps keys on the primary.
+	Replicas int
+
+	// Async returns once the primary has the write, replicating in the
+	// background. Otherwise the write waits for every chosen replica, or
+	// only for enough of them to meet the storage's write quorum; a
+	// policy choosing fewer replicas than the quorum needs makes every
+	// synchronous write fail with ErrNoWriteQuorum.
+	Async bool
+}
+
+// SetPolicy sets the replication policy for keys starting with prefix.
+// When several prefixes match a key, the longest one wins. Keys matching
+// no prefix are written to every replica, synchronously unless the
+// storage was created in async mode.
+func (rs *ReplicatedStorage) SetPolicy(prefix string, policy ReplicationPolicy) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.policies[prefix] = policy
+}
+
+// RemovePolicy removes the policy for prefix, if any
+func (rs *ReplicatedStorage) RemovePolicy(prefix string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	delete(rs.policies, prefix)
+}
+
+// PolicyFor returns the policy that applies to key
+func (rs *ReplicatedStorage) PolicyFor(key []byte) ReplicationPolicy {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.policyFor(key)
+}
+
+// policyFor returns the policy of the longest prefix matching key.
+// The caller must hold rs.mu.
+func (rs *ReplicatedStorage) policyFor(key []byte) ReplicationPolicy {
+	policy := ReplicationPolicy{Replicas: AllReplicas, Async: rs.asyncMode}
+	longest := -1
+	for prefix, p := range rs.policies {
+		if len(prefix) > longest && strings.HasPrefix(string(key), prefix) {
+			policy, longest = p, len(prefix)
+		}
+	}
+	return policy
+}
+
+// replicate applies op to the replicas chosen by policy, waiting for them
+// unless the policy is async. Failed writes are retried as configured by
+// SetRetryConfig, then queued for background retry.
+//
+// Without a write quorum, replicate waits for every replica and never
+// fails. With one, it returns once enough replicas have the write, leaving
+// the rest to finish in the background, and returns an error wrapping
+// ErrNoWriteQuorum once too many have failed for the quorum to be met.
+// The caller must hold rs.mu.
+func (rs *ReplicatedStorage) replicate(policy ReplicationPolicy, what string, key []byte, op func(storage.Storage) error) error {
+	targets := rs.replicas
+	if policy.Replicas >= 0 && policy.Replicas < len(targets) {
+		targets = targets[:policy.Replicas]
+	}
+
+	if policy.Async {
+		for _, replica := range targets {
+			go rs.writeReplica(replica, what, key, op)
+		}
+		return nil
+	}
+
+	results := make(chan error, len(targets))
+	for _, replica := range targets {
+		go func(r storage.Storage) {
+			results <- rs.writeReplica(r, what, key, op)
+		}(replica)
+	}
+
+	if rs.writeQuorum <= 0 {
+		for range targets {
+			<-results
+		}
+		return nil
+	}
+
+	// The primary already has the write. A policy that writes to fewer
+	// replicas than the quorum needs can't meet it.
+	needed := rs.writeQuorum - 1
+	if needed > len(targets) {
+		return fmt.Errorf("%w: %s goes to %d replicas, %d needed", ErrNoWriteQuorum, what, len(targets), needed)
+	}
+	acks, failed := 0, 0
+	var lastErr error
+	for acks < needed {
+		if err := <-results; err != nil {
+			failed++
+			lastErr = err
+			if len(targets)-failed < needed {
+				return fmt.Errorf("%w: %s acknowledged by %d of %d replicas needed: %v", ErrNoWriteQuorum, what, acks, needed, lastErr)
+			}
+			continue
+		}
+		acks++
+	}
+	return nil
+}
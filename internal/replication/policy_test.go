@@ -0,0 +1,103 @@
+package replication
+
+import (
+	"testing"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+// delayedStorage delays every Put to the wrapped storage
+type delayedStorage struct {
+	storage.Storage
+	delay time.Duration
+}
+
+func (d *delayedStorage) Put(key, value []byte) error {
+	time.Sleep(d.delay)
+	return d.Storage.Put(key, value)
+}
+
+func TestReplicatedStorage_PrefixPolicies(t *testing.T) {
+	const delay = 200 * time.Millisecond
+
+	rs, err := NewReplicatedStorage(newBadger(t), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replicas := []storage.Storage{
+		&delayedStorage{Storage: newBadger(t), delay: delay},
+		&delayedStorage{Storage: newBadger(t), delay: delay},
+		&delayedStorage{Storage: newBadger(t), delay: delay},
+	}
+	rs.replicas = replicas
+
+	rs.SetPolicy("critical:", ReplicationPolicy{Replicas: AllReplicas})
+	rs.SetPolicy("cache:", ReplicationPolicy{Replicas: 1, Async: true})
+
+	if p := rs.PolicyFor([]byte("cache:user")); p.Replicas != 1 || !p.Async {
+		t.Errorf("Expected cache policy, got %+v", p)
+	}
+
+	// A critical write waits for every replica
+	start := time.Now()
+	if err := rs.Put([]byte("critical:balance"), []byte("100")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("Expected critical write to wait for replicas, returned after %v", elapsed)
+	}
+	for i, r := range replicas {
+		if _, err := r.Get([]byte("critical:balance")); err != nil {
+			t.Errorf("Expected replica %d to have the critical key on return", i)
+		}
+	}
+
+	// A cache write returns once the primary has it
+	start = time.Now()
+	if err := rs.Put([]byte("cache:session"), []byte("abc")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= delay {
+		t.Errorf("Expected cache write to return after the primary, took %v", elapsed)
+	}
+	if _, err := rs.primary.Get([]byte("cache:session")); err != nil {
+		t.Error("Expected primary to have the cache key on return")
+	}
+
+	// Only the first replica ever receives it
+	time.Sleep(2 * delay)
+	if _, err := replicas[0].Get([]byte("cache:session")); err != nil {
+		t.Error("Expected the cache key on one replica")
+	}
+	for i, r := range replicas[1:] {
+		if _, err := r.Get([]byte("cache:session")); err == nil {
+			t.Errorf("Expected replica %d not to receive the cache key", i+1)
+		}
+	}
+}
+
+func TestReplicatedStorage_LongestPrefixWins(t *testing.T) {
+	rs, err := NewReplicatedStorage(newBadger(t), nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs.SetPolicy("user:", ReplicationPolicy{Replicas: 2})
+	rs.SetPolicy("user:admin:", ReplicationPolicy{Replicas: AllReplicas})
+
+	if p := rs.PolicyFor([]byte("user:admin:root")); p.Replicas != AllReplicas || p.Async {
+		t.Errorf("Expected the longer prefix to win, got %+v", p)
+	}
+	if p := rs.PolicyFor([]byte("user:bob")); p.Replicas != 2 {
+		t.Errorf("Expected user policy, got %+v", p)
+	}
+	if p := rs.PolicyFor([]byte("other")); p.Replicas != AllReplicas || !p.Async {
+		t.Errorf("Expected default async policy, got %+v", p)
+	}
+
+	rs.RemovePolicy("user:admin:")
+	if p := rs.PolicyFor([]byte("user:admin:root")); p.Replicas != 2 {
+		t.Errorf("Expected user policy after removal, got %+v", p)
+	}
+}
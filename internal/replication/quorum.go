@@ -0,0 +1,196 @@
+package replication
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+// defaultReadTimeout is how long a quorum read waits for a majority
+const defaultReadTimeout = time.Second
+
+var (
+	// ErrNoReadQuorum is returned by a quorum read when no majority of the
+	// nodes agreed on the key's value before the read timed out
+	ErrNoReadQuorum = errors.New("no read quorum")
+
+	// ErrNoWriteQuorum is returned by a write that too few replicas
+	// acknowledged to meet the write quorum. The primary has the write,
+	// and the replicas that failed keep retrying it in the background.
+	ErrNoWriteQuorum = errors.New("no write quorum")
+)
+
+// ReadConsistency decides which nodes a Get reads from
+type ReadConsistency int
+
+const (
+	// ReadPrimary reads the primary, falling back to the replicas in turn
+	// if it fails. Right after an async write, a fallback read may return
+	// the previous value.
+	ReadPrimary ReadConsistency = iota
+
+	// ReadQuorum reads the primary and every replica concurrently and
+	// returns the value a majority of them hold, then repairs the replicas
+	// that disagreed. Values carry no versions, so while an async write is
+	// still reaching the replicas a majority may hold the previous value;
+	// quorum reads are consistent once writes are synchronous. Since every
+	// write reaches the primary first, the primary is never repaired, and
+	// a replica is only repaired while the primary still holds the value
+	// the majority agreed on.
+	ReadQuorum
+)
+
+// Option configures a ReplicatedStorage
+type Option func(*ReplicatedStorage)
+
+// WithReadConsistency sets which nodes a Get reads from. The default is
+// ReadPrimary.
+func WithReadConsistency(consistency ReadConsistency) Option {
+	return func(rs *ReplicatedStorage) {
+		rs.readConsistency = consistency
+	}
+}
+
+// WithWriteQuorum makes synchronous writes return once quorum nodes, the
+// primary and quorum-1 replicas, have them, instead of waiting for every
+// replica. Writes to slower replicas finish in the background, so a
+// later write to the same key may reach such a replica first, as with
+// async writes. A write that can't reach the quorum returns an error
+// wrapping ErrNoWriteQuorum. NewReplicatedStorage fails if there are
+// fewer than quorum-1 replicas. Zero, the default, waits for every
+// replica and reports only primary failures.
+func WithWriteQuorum(quorum int) Option {
+	return func(rs *ReplicatedStorage) {
+		rs.writeQuorum = quorum
+	}
+}
+
+// WithReadTimeout sets how long a quorum read waits for a majority
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(rs *ReplicatedStorage) {
+		rs.readTimeout = timeout
+	}
+}
+
+// readResult is one node's answer to a quorum read
+type readResult struct {
+	node  storage.Storage
+	value []byte
+	found bool
+	err   error // the node couldn't answer, so it has no vote
+}
+
+// vote identifies the answer, so equal answers count together
+func (r readResult) vote() string {
+	if !r.found {
+		return "-"
+	}
+	return "+" + string(r.value)
+}
+
+// quorumGet reads key from the primary and every replica concurrently and
+// returns the answer a majority agree on. Replicas whose answer differs,
+// including those that answer after the majority is reached, are repaired
+// in the background; see readRepair. The caller must hold rs.mu.
+func (rs *ReplicatedStorage) quorumGet(key []byte) ([]byte, error) {
+	nodes := append([]storage.Storage{rs.primary}, rs.replicas...)
+	needed := len(nodes)/2 + 1
+
+	results := make(chan readResult, len(nodes))
+	for _, node := range nodes {
+		go func(node storage.Storage) {
+			value, err := node.Get(key)
+			switch {
+			case err == nil:
+				results <- readResult{node: node, value: value, found: true}
+			case errors.Is(err, storage.ErrKeyNotFound):
+				results <- readResult{node: node}
+			default:
+				results <- readResult{node: node, err: err}
+			}
+		}(node)
+	}
+
+	deadline := time.Now().Add(rs.readTimeout)
+	timer := time.NewTimer(rs.readTimeout)
+	defer timer.Stop()
+
+	var answers []readResult
+	votes := make(map[string]int)
+	for received := 0; received < len(nodes); received++ {
+		select {
+		case r := <-results:
+			if r.err != nil {
+				continue
+			}
+			answers = append(answers, r)
+			votes[r.vote()]++
+			if votes[r.vote()] < needed {
+				continue
+			}
+
+			go rs.readRepair(key, r, answers, results, len(nodes)-received-1, deadline)
+			if !r.found {
+				return nil, storage.ErrKeyNotFound
+			}
+			return r.value, nil
+		case <-timer.C:
+			return nil, fmt.Errorf("%w: %d of %d nodes needed to agree", ErrNoReadQuorum, needed, len(nodes))
+		}
+	}
+	return nil, fmt.Errorf("%w: %d of %d nodes needed to agree", ErrNoReadQuorum, needed, len(nodes))
+}
+
+// readRepair brings the replicas whose answers differ from the quorum's in
+// line with it: first those already heard from, then the remaining nodes
+// as they answer, until the read's deadline. Each repair takes the write
+// lock and first checks that the primary still holds the quorum's answer,
+// so a write made since the read isn't overwritten with the older value.
+func (rs *ReplicatedStorage) readRepair(key []byte, quorum readResult, answers []readResult, pending <-chan readResult, remaining int, deadline time.Time) {
+	repair := func(r readResult) {
+		if r.err != nil || r.node == rs.primary || r.vote() == quorum.vote() {
+			return
+		}
+
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+
+		value, err := rs.primary.Get(key)
+		if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+			return
+		}
+		if current := (readResult{value: value, found: err == nil}); current.vote() != quorum.vote() {
+			return
+		}
+
+		if quorum.found {
+			rs.writeReplica(r.node, "REPAIR", key, func(s storage.Storage) error {
+				return s.Put(key, quorum.value)
+			})
+			return
+		}
+		rs.writeReplica(r.node, "REPAIR", key, func(s storage.Storage) error {
+			if err := s.Delete(key); err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+				return err
+			}
+			return nil
+		})
+	}
+
+	for _, r := range answers {
+		repair(r)
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	for ; remaining > 0; remaining-- {
+		select {
+		case r := <-pending:
+			repair(r)
+		case <-timer.C:
+			return
+		}
+	}
+}
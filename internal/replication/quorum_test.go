@@ -0,0 +1,190 @@
+package replication
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+func newQuorumTestStorage(t *testing.T, primary storage.Storage, replicas ...storage.Storage) *ReplicatedStorage {
+	rs, err := NewReplicatedStorage(primary, nil, false, WithReadConsistency(ReadQuorum), WithReadTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rs.stopRetrying)
+	rs.replicas = replicas
+	return rs
+}
+
+func TestReplicatedStorage_QuorumReadRepairsStaleReplica(t *testing.T) {
+	primary, fresh, stale := newBadger(t), newBadger(t), newBadger(t)
+	for _, s := range []storage.Storage{primary, fresh} {
+		if err := s.Put([]byte("key"), []byte("new")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stale.Put([]byte("key"), []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+
+	rs := newQuorumTestStorage(t, primary, fresh, stale)
+	value, err := rs.Get([]byte("key"))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(value) != "new" {
+		t.Errorf("Expected new, got %s", value)
+	}
+	waitForValue(t, stale, "key", "new")
+}
+
+func TestReplicatedStorage_QuorumReadNeverRepairsPrimary(t *testing.T) {
+	primary, a, b := newBadger(t), newBadger(t), newBadger(t)
+
+	// An async write the primary has acknowledged but the replicas
+	// haven't received yet
+	if err := primary.Put([]byte("key"), []byte("acknowledged")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The lagging replicas outvote the primary, but its newer copy stays
+	rs := newQuorumTestStorage(t, primary, a, b)
+	if _, err := rs.Get([]byte("key")); !errors.Is(err, storage.ErrKeyNotFound) {
+		t.Fatalf("Expected ErrKeyNotFound, got %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if value, err := primary.Get([]byte("key")); err != nil || string(value) != "acknowledged" {
+		t.Errorf("Expected the primary to keep its write, got %q (%v)", value, err)
+	}
+}
+
+// slowReadStorage answers each Get with the value the key held when it
+// was called, after a delay
+type slowReadStorage struct {
+	storage.Storage
+	delay time.Duration
+}
+
+func (s *slowReadStorage) Get(key []byte) ([]byte, error) {
+	value, err := s.Storage.Get(key)
+	time.Sleep(s.delay)
+	return value, err
+}
+
+func TestReplicatedStorage_ReadRepairSkipsKeyWrittenSinceRead(t *testing.T) {
+	primary, fresh := newBadger(t), newBadger(t)
+	stale := &slowReadStorage{Storage: newBadger(t), delay: 300 * time.Millisecond}
+	for _, s := range []storage.Storage{primary, fresh} {
+		if err := s.Put([]byte("key"), []byte("v1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stale.Put([]byte("key"), []byte("v0")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The majority answers v1 before the stale replica does
+	rs := newQuorumTestStorage(t, primary, fresh, stale)
+	if value, err := rs.Get([]byte("key")); err != nil || string(value) != "v1" {
+		t.Fatalf("Expected v1, got %q (%v)", value, err)
+	}
+
+	// A write lands before the stale replica's answer triggers its repair
+	if err := rs.Put([]byte("key"), []byte("v2")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	time.Sleep(2 * stale.delay)
+	for name, s := range map[string]storage.Storage{"primary": primary, "fresh": fresh, "stale": stale.Storage} {
+		if value, err := s.Get([]byte("key")); err != nil || string(value) != "v2" {
+			t.Errorf("Expected v2 on the %s node, got %q (%v)", name, value, err)
+		}
+	}
+}
+
+func TestReplicatedStorage_QuorumReadWithoutMajority(t *testing.T) {
+	nodes := []storage.Storage{newBadger(t), newBadger(t), newBadger(t)}
+	for i, s := range nodes {
+		if err := s.Put([]byte("key"), []byte{byte('a' + i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rs := newQuorumTestStorage(t, nodes[0], nodes[1:]...)
+	if _, err := rs.Get([]byte("key")); !errors.Is(err, ErrNoReadQuorum) {
+		t.Errorf("Expected ErrNoReadQuorum, got %v", err)
+	}
+}
+
+// slowStorage delays every Put to the wrapped storage
+type slowStorage struct {
+	storage.Storage
+	delay time.Duration
+}
+
+func (s *slowStorage) Put(key, value []byte) error {
+	time.Sleep(s.delay)
+	return s.Storage.Put(key, value)
+}
+
+func newWriteQuorumTestStorage(t *testing.T, quorum int, replicas ...storage.Storage) *ReplicatedStorage {
+	rs, err := NewReplicatedStorage(newBadger(t), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rs.stopRetrying)
+	rs.replicas = replicas
+	rs.writeQuorum = quorum
+	rs.SetRetryConfig(RetryConfig{Attempts: 1, RetryInterval: time.Hour})
+	return rs
+}
+
+func TestReplicatedStorage_WriteQuorumSkipsSlowReplica(t *testing.T) {
+	slow := &slowStorage{Storage: newBadger(t), delay: time.Second}
+	rs := newWriteQuorumTestStorage(t, 2, slow, newBadger(t), newBadger(t))
+
+	start := time.Now()
+	if err := rs.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected Put to return without the slow replica, took %v", elapsed)
+	}
+
+	// The slow replica still gets the write in the background
+	waitForValue(t, slow, "key", "value")
+}
+
+func TestReplicatedStorage_WriteQuorumNotMet(t *testing.T) {
+	down := func() storage.Storage { return &flakyStorage{Storage: newBadger(t), failures: 100} }
+	rs := newWriteQuorumTestStorage(t, 3, down(), down(), newBadger(t))
+
+	if err := rs.Put([]byte("key"), []byte("value")); !errors.Is(err, ErrNoWriteQuorum) {
+		t.Errorf("Expected ErrNoWriteQuorum, got %v", err)
+	}
+
+	// One ack is enough for a quorum of two
+	rs.writeQuorum = 2
+	if err := rs.Put([]byte("key"), []byte("value")); err != nil {
+		t.Errorf("Expected Put to meet a quorum of 2, got %v", err)
+	}
+}
+
+func TestReplicatedStorage_WriteQuorumLargerThanReplicas(t *testing.T) {
+	if _, err := NewReplicatedStorage(newBadger(t), nil, false, WithWriteQuorum(2)); err == nil {
+		t.Errorf("Expected a write quorum of 2 without replicas to be refused")
+	}
+
+	// A policy writing to fewer replicas than the quorum needs fails
+	// rather than lowering the quorum
+	rs := newWriteQuorumTestStorage(t, 3, newBadger(t), newBadger(t))
+	rs.SetPolicy("one/", ReplicationPolicy{Replicas: 1})
+	if err := rs.Put([]byte("one/key"), []byte("value")); !errors.Is(err, ErrNoWriteQuorum) {
+		t.Errorf("Expected ErrNoWriteQuorum with one target replica, got %v", err)
+	}
+	if err := rs.Put([]byte("key"), []byte("value")); err != nil {
+		t.Errorf("Expected Put to all replicas to meet the quorum, got %v", err)
+	}
+}
@@ -1,9 +1,13 @@
 package replication
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"sync"
+	"time"
 	
 	"godatabase/internal/storage"
 	"godatabase/pkg/client"
@@ -15,19 +19,44 @@ type ReplicatedStorage struct {
 	replicas  []storage.Storage
 	mu        sync.RWMutex
 	asyncMode bool // If true, replicate asynchronously
+	policies  map[string]ReplicationPolicy // key prefix -> policy
+
+	readConsistency ReadConsistency
+	readTimeout     time.Duration // how long a quorum read waits for a majority
+	writeQuorum     int           // nodes, primary included, a write waits for; 0 for all
+
+	retryMu       sync.Mutex
+	retry         RetryConfig
+	deadLetters   map[replicaKey]*failedOp // latest failed write per replica and key
+	stats         ReplicationStats
+	retryStop     chan struct{}
+	retryDone     chan struct{}
+	retryStopOnce sync.Once
 }
 
 // NewReplicatedStorage creates a new replicated storage
-func NewReplicatedStorage(primary storage.Storage, replicaAddrs []string, asyncMode bool) (*ReplicatedStorage, error) {
+func NewReplicatedStorage(primary storage.Storage, replicaAddrs []string, asyncMode bool, opts ...Option) (*ReplicatedStorage, error) {
 	rs := &ReplicatedStorage{
 		primary:   primary,
 		replicas:  make([]storage.Storage, 0, len(replicaAddrs)),
 		asyncMode: asyncMode,
+		policies:  make(map[string]ReplicationPolicy),
+
+		readConsistency: ReadPrimary,
+		readTimeout:     defaultReadTimeout,
+
+		retry:       DefaultRetryConfig(),
+		deadLetters: make(map[replicaKey]*failedOp),
+		retryStop:   make(chan struct{}),
+		retryDone:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(rs)
 	}
 	
 	// Connect to replicas
 	for _, addr := range replicaAddrs {
-		replica, err := client.New(addr)
+		replica, err := client.NewClient(addr)
 		if err != nil {
 			log.Printf("Failed to connect to replica %s: %v", addr, err)
 			// Continue with other replicas
@@ -40,123 +69,305 @@ func NewReplicatedStorage(primary storage.Storage, replicaAddrs []string, asyncM
 		return nil, errors.New("failed to connect to any replica")
 	}
 	
+	if rs.writeQuorum > len(rs.replicas)+1 {
+		return nil, fmt.Errorf("write quorum %d needs %d replicas, only %d connected", rs.writeQuorum, rs.writeQuorum-1, len(rs.replicas))
+	}
+	
+	go rs.retryLoop()
+	
 	return rs, nil
 }
 
 // Put stores a key-value pair in primary and replicates to backups
 func (rs *ReplicatedStorage) Put(key, value []byte) error {
+	return rs.PutCtx(context.Background(), key, value)
+}
+
+// PutCtx is Put with ctx bounding the write to the primary. Replication
+// isn't bounded by it, since asynchronous and retried writes to replicas
+// outlive the call.
+func (rs *ReplicatedStorage) PutCtx(ctx context.Context, key, value []byte) error {
 	rs.mu.Lock()
 	defer rs.mu.Unlock()
 	
 	// Write to primary first
-	if err := rs.primary.Put(key, value); err != nil {
+	if err := rs.primary.PutCtx(ctx, key, value); err != nil {
 		return err
 	}
 	
 	// Replicate to backups
-	if rs.asyncMode {
-		// Asynchronous replication
-		for _, replica := range rs.replicas {
-			go func(r storage.Storage) {
-				if err := r.Put(key, value); err != nil {
-					log.Printf("Failed to replicate PUT to backup: %v", err)
-				}
-			}(replica)
-		}
-	} else {
-		// Synchronous replication
-		var wg sync.WaitGroup
-		errChan := make(chan error, len(rs.replicas))
-		
-		for _, replica := range rs.replicas {
-			wg.Add(1)
-			go func(r storage.Storage) {
-				defer wg.Done()
-				if err := r.Put(key, value); err != nil {
-					errChan <- err
-				}
-			}(replica)
-		}
-		
-		wg.Wait()
-		close(errChan)
-		
-		// Check for errors
-		for err := range errChan {
-			if err != nil {
-				// At least one replica failed
-				// In production, you might want to handle this differently
-				log.Printf("Replication error: %v", err)
-			}
-		}
+	return rs.replicate(rs.policyFor(key), "PUT", key, func(r storage.Storage) error {
+		return r.Put(key, value)
+	})
+}
+
+// PutWithTTL stores an expiring key-value pair in primary and replicates
+// it to backups with the same ttl, so each copy expires on its own clock
+func (rs *ReplicatedStorage) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	
+	if err := rs.primary.PutWithTTL(key, value, ttl); err != nil {
+		return err
 	}
 	
-	return nil
+	return rs.replicate(rs.policyFor(key), "PUT", key, func(r storage.Storage) error {
+		return r.PutWithTTL(key, value, ttl)
+	})
 }
 
-// Get retrieves a value from the primary
+// Get retrieves a value from the primary, or from a majority of the nodes
+// if the storage reads with ReadQuorum
 func (rs *ReplicatedStorage) Get(key []byte) ([]byte, error) {
+	return rs.GetCtx(context.Background(), key)
+}
+
+// GetCtx is Get with ctx bounding the read from the primary. A quorum
+// read keeps its own timeout.
+func (rs *ReplicatedStorage) GetCtx(ctx context.Context, key []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	
 	rs.mu.RLock()
 	defer rs.mu.RUnlock()
 	
+	if rs.readConsistency == ReadQuorum {
+		return rs.quorumGet(key)
+	}
+	
 	// Read from primary
-	value, err := rs.primary.Get(key)
+	value, err := rs.primary.GetCtx(ctx, key)
 	if err == nil {
 		return value, nil
 	}
 	
-	// If primary fails, try replicas (read repair)
+	// If primary fails, try replicas. The primary isn't repaired from
+	// them: it gets every write first, so a replica's copy may be older.
 	for _, replica := range rs.replicas {
 		if value, err := replica.Get(key); err == nil {
-			// Found in replica, repair primary
-			go rs.primary.Put(key, value)
 			return value, nil
 		}
 	}
 	
-	return nil, errors.New("key not found")
+	return nil, storage.ErrKeyNotFound
 }
 
 // Delete removes a key from primary and replicas
 func (rs *ReplicatedStorage) Delete(key []byte) error {
+	return rs.DeleteCtx(context.Background(), key)
+}
+
+// DeleteCtx is Delete with ctx bounding the delete from the primary, like
+// PutCtx
+func (rs *ReplicatedStorage) DeleteCtx(ctx context.Context, key []byte) error {
 	rs.mu.Lock()
 	defer rs.mu.Unlock()
 	
 	// Delete from primary first
-	if err := rs.primary.Delete(key); err != nil {
+	if err := rs.primary.DeleteCtx(ctx, key); err != nil {
 		return err
 	}
 	
 	// Delete from replicas
-	if rs.asyncMode {
-		// Asynchronous deletion
-		for _, replica := range rs.replicas {
-			go func(r storage.Storage) {
-				if err := r.Delete(key); err != nil {
-					log.Printf("Failed to replicate DELETE to backup: %v", err)
-				}
-			}(replica)
+	return rs.replicate(rs.policyFor(key), "DELETE", key, func(r storage.Storage) error {
+		return r.Delete(key)
+	})
+}
+
+// DeleteRange removes a key range from the primary, then deletes each
+// removed key from the replicas under its own replication policy, like a
+// batch of deletes. The count is the primary's.
+func (rs *ReplicatedStorage) DeleteRange(start, end []byte) (int, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	
+	// Note the keys first; replicas are sent a delete for each one
+	it, err := rs.primary.Scan(start, end)
+	if err != nil {
+		return 0, err
+	}
+	var keys [][]byte
+	for it.Next() {
+		keys = append(keys, append([]byte(nil), it.Key()...))
+	}
+	if err := it.Close(); err != nil {
+		return 0, err
+	}
+	
+	deleted, err := rs.primary.DeleteRange(start, end)
+	if err != nil {
+		return deleted, err
+	}
+	
+	var firstErr error
+	for _, key := range keys {
+		key := key
+		err := rs.replicate(rs.policyFor(key), "DELETE", key, func(r storage.Storage) error {
+			err := r.Delete(key)
+			if errors.Is(err, storage.ErrKeyNotFound) {
+				// The replica never had it, or missed an earlier write
+				return nil
+			}
+			return err
+		})
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return deleted, firstErr
+}
+
+// CompareAndSwap sets key on the primary if it holds old, then
+// replicates the new value
+func (rs *ReplicatedStorage) CompareAndSwap(key, old, new []byte) (bool, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	
+	// The primary decides whether the value matches
+	swapped, err := rs.primary.CompareAndSwap(key, old, new)
+	if err != nil || !swapped {
+		return swapped, err
+	}
+	
+	// Replicas follow the primary's decision
+	return true, rs.replicate(rs.policyFor(key), "PUT", key, func(r storage.Storage) error {
+		return r.Put(key, new)
+	})
+}
+
+// Increment adds to key's counter on the primary, then replicates the
+// counter's new value
+func (rs *ReplicatedStorage) Increment(key []byte, delta int64) (int64, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	
+	n, err := rs.primary.Increment(key, delta)
+	if err != nil {
+		return 0, err
+	}
+	
+	// Replicas receive the value, not the delta, so a replica that missed
+	// an earlier increment catches up
+	value := storage.EncodeCounter(n)
+	return n, rs.replicate(rs.policyFor(key), "PUT", key, func(r storage.Storage) error {
+		return r.Put(key, value)
+	})
+}
+
+// Append adds value to key's list on the primary, then replicates the
+// whole list
+func (rs *ReplicatedStorage) Append(key, value []byte) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	
+	if err := rs.primary.Append(key, value); err != nil {
+		return err
+	}
+	
+	// Like Increment, replicas receive the result rather than the
+	// operation, so one that missed an earlier append catches up
+	list, err := rs.primary.Get(key)
+	if err != nil {
+		return err
+	}
+	return rs.replicate(rs.policyFor(key), "PUT", key, func(r storage.Storage) error {
+		return r.Put(key, list)
+	})
+}
+
+// GetList returns the elements of key's list, read like Get
+func (rs *ReplicatedStorage) GetList(key []byte) ([][]byte, error) {
+	value, err := rs.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return storage.DecodeList(value)
+}
+
+// NewBatch returns a batch that commits to the primary and then
+// replicates each of its operations like Put and Delete
+func (rs *ReplicatedStorage) NewBatch() storage.WriteBatch {
+	return &replicatedBatch{rs: rs}
+}
+
+// replicatedBatch is a WriteBatch over ReplicatedStorage
+type replicatedBatch struct {
+	storage.BatchOps
+	rs *ReplicatedStorage
+}
+
+// Commit commits the batch to the primary, with the primary's atomicity.
+// Replicas then receive the operations one by one, under each key's
+// replication policy, so a replica may briefly hold part of the batch.
+func (b *replicatedBatch) Commit() error {
+	rs := b.rs
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	
+	batch := rs.primary.NewBatch()
+	for _, op := range b.Ops {
+		if op.Delete {
+			batch.Delete(op.Key)
+		} else {
+			batch.Put(op.Key, op.Value)
 		}
-	} else {
-		// Synchronous deletion
-		var wg sync.WaitGroup
-		for _, replica := range rs.replicas {
-			wg.Add(1)
-			go func(r storage.Storage) {
-				defer wg.Done()
-				if err := r.Delete(key); err != nil {
-					log.Printf("Failed to delete from replica: %v", err)
+	}
+	if err := batch.Commit(); err != nil {
+		return err
+	}
+	
+	// A replica that misses the quorum for one operation still gets the
+	// rest, so the first error is reported once they are all replicated
+	var firstErr error
+	for _, op := range b.Ops {
+		op := op
+		var err error
+		if op.Delete {
+			err = rs.replicate(rs.policyFor(op.Key), "DELETE", op.Key, func(r storage.Storage) error {
+				err := r.Delete(op.Key)
+				if errors.Is(err, storage.ErrKeyNotFound) {
+					// The batch deleted a key that wasn't there
+					return nil
 				}
-			}(replica)
+				return err
+			})
+		} else {
+			err = rs.replicate(rs.policyFor(op.Key), "PUT", op.Key, func(r storage.Storage) error {
+				return r.Put(op.Key, op.Value)
+			})
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
 		}
-		wg.Wait()
 	}
 	
-	return nil
+	return firstErr
+}
+
+// DeleteIf removes key from the primary if it holds expected, then
+// deletes it from the replicas
+func (rs *ReplicatedStorage) DeleteIf(key, expected []byte) (bool, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	
+	// The primary decides whether the value matches
+	deleted, err := rs.primary.DeleteIf(key, expected)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+	
+	// Replicas follow the primary's decision
+	return true, rs.replicate(rs.policyFor(key), "DELETE", key, func(r storage.Storage) error {
+		return r.Delete(key)
+	})
 }
 
 // Close closes all connections
 func (rs *ReplicatedStorage) Close() error {
+	// Stop retrying before the replicas are closed under it
+	rs.stopRetrying()
+	
 	rs.mu.Lock()
 	defer rs.mu.Unlock()
 	
@@ -175,10 +386,116 @@ func (rs *ReplicatedStorage) Close() error {
 	return nil
 }
 
+// Tail returns the largest keys from the primary
+func (rs *ReplicatedStorage) Tail(n int) ([]storage.KV, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	
+	return rs.primary.Tail(n)
+}
+
+// Fingerprint returns the primary's fingerprint
+func (rs *ReplicatedStorage) Fingerprint() ([]byte, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	
+	return rs.primary.Fingerprint()
+}
+
+// SplitRanges splits the primary's keyspace
+func (rs *ReplicatedStorage) SplitRanges(n int) ([]storage.KeyRange, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	
+	return rs.primary.SplitRanges(n)
+}
+
+// Scan returns an iterator over the primary
+func (rs *ReplicatedStorage) Scan(start, end []byte) (storage.Iterator, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	
+	return rs.primary.Scan(start, end)
+}
+
+// ScanWithConsistency returns an iterator over the primary with the chosen
+// consistency
+func (rs *ReplicatedStorage) ScanWithConsistency(start, end []byte, consistency storage.ScanConsistency) (storage.Iterator, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	
+	return rs.primary.ScanWithConsistency(start, end, consistency)
+}
+
+// ScanReverse returns a descending iterator over the primary
+func (rs *ReplicatedStorage) ScanReverse(start, end []byte) (storage.Iterator, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	
+	return rs.primary.ScanReverse(start, end)
+}
+
+// ScanPrefix returns a prefix iterator over the primary
+func (rs *ReplicatedStorage) ScanPrefix(prefix []byte) (storage.Iterator, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	
+	return rs.primary.ScanPrefix(prefix)
+}
+
+// Keys lists the primary's keys
+func (rs *ReplicatedStorage) Keys() ([][]byte, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	
+	return rs.primary.Keys()
+}
+
+// Stats returns the primary's stats
+func (rs *ReplicatedStorage) Stats() (storage.StorageStats, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	
+	return rs.primary.Stats()
+}
+
+// Limits returns the primary's limits
+func (rs *ReplicatedStorage) Limits() storage.Limits {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	
+	return rs.primary.Limits()
+}
+
 // Size returns the size from the primary
 func (rs *ReplicatedStorage) Size() int {
 	rs.mu.RLock()
 	defer rs.mu.RUnlock()
 	
 	return rs.primary.Size()
-} 
\ No newline at end of file
+}
+
+// Backup backs up the primary
+func (rs *ReplicatedStorage) Backup(w io.Writer) error {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	
+	return rs.primary.Backup(w)
+}
+
+// Sync syncs the primary, then asks each replica to sync. Like failed
+// replica writes, replicas that fail to sync are only logged.
+func (rs *ReplicatedStorage) Sync() error {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	
+	if err := rs.primary.Sync(); err != nil {
+		return err
+	}
+	for _, replica := range rs.replicas {
+		if err := replica.Sync(); err != nil {
+			log.Printf("Error syncing replica: %v", err)
+		}
+	}
+	return nil
+}
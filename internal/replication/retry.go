@@ -0,0 +1,198 @@
+package replication
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+// RetryConfig tunes how failed replica writes are retried
+type RetryConfig struct {
+	// Attempts is how many times a write is tried on a replica before it
+	// is queued for background retry, including the first try
+	Attempts int
+
+	// Backoff is the wait before the second attempt. It doubles after each
+	// further failure, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// RetryInterval is how often queued writes are retried in the background
+	RetryInterval time.Duration
+
+	// MaxAge is how long a queued write is retried before it is dropped.
+	// Zero retries it until it succeeds.
+	MaxAge time.Duration
+}
+
+// DefaultRetryConfig returns the default retry configuration
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		Attempts:      3,
+		Backoff:       10 * time.Millisecond,
+		MaxBackoff:    time.Second,
+		RetryInterval: time.Second,
+		MaxAge:        10 * time.Minute,
+	}
+}
+
+// ReplicationStats reports the state of writes that failed on a replica
+type ReplicationStats struct {
+	Pending   int // writes queued for background retry
+	Failed    int // writes that exhausted their attempts and were queued
+	Retries   int // attempts after the first, immediate and background
+	Recovered int // queued writes that later succeeded
+	Dropped   int // queued writes given up on after MaxAge
+}
+
+// replicaKey identifies the latest queued write for a key on a replica
+type replicaKey struct {
+	replica storage.Storage
+	key     string
+}
+
+// failedOp is a replica write waiting for background retry
+type failedOp struct {
+	what     string
+	op       func(storage.Storage) error
+	failedAt time.Time // when the write first failed
+	lastErr  error
+}
+
+// SetRetryConfig sets how failed replica writes are retried. Attempts
+// below 1 are treated as 1.
+func (rs *ReplicatedStorage) SetRetryConfig(cfg RetryConfig) {
+	if cfg.Attempts < 1 {
+		cfg.Attempts = 1
+	}
+
+	rs.retryMu.Lock()
+	defer rs.retryMu.Unlock()
+	rs.retry = cfg
+}
+
+// RetryStats returns a snapshot of the replication metrics
+func (rs *ReplicatedStorage) RetryStats() ReplicationStats {
+	rs.retryMu.Lock()
+	defer rs.retryMu.Unlock()
+
+	stats := rs.stats
+	stats.Pending = len(rs.deadLetters)
+	return stats
+}
+
+// writeReplica applies op to replica, retrying with backoff, and queues it
+// for background retry if every attempt fails. A write to a key that
+// already has one queued on this replica replaces it rather than racing
+// it, so the replica never ends up with the older value. It returns nil
+// if the write landed, and the last error if it was queued.
+func (rs *ReplicatedStorage) writeReplica(replica storage.Storage, what string, key []byte, op func(storage.Storage) error) error {
+	rk := replicaKey{replica: replica, key: string(key)}
+
+	rs.retryMu.Lock()
+	cfg := rs.retry
+	if queued, ok := rs.deadLetters[rk]; ok {
+		rs.deadLetters[rk] = &failedOp{what: what, op: op, failedAt: queued.failedAt, lastErr: queued.lastErr}
+		rs.retryMu.Unlock()
+		return fmt.Errorf("%s: queued behind an earlier failed write: %v", what, queued.lastErr)
+	}
+	rs.retryMu.Unlock()
+
+	backoff := cfg.Backoff
+	var err error
+	for attempt := 0; attempt < cfg.Attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+			if cfg.MaxBackoff > 0 && backoff > cfg.MaxBackoff {
+				backoff = cfg.MaxBackoff
+			}
+			rs.retryMu.Lock()
+			rs.stats.Retries++
+			rs.retryMu.Unlock()
+		}
+		if err = op(replica); err == nil {
+			return nil
+		}
+	}
+
+	log.Printf("Replication error: %s: %v; queued for retry", what, err)
+
+	rs.retryMu.Lock()
+	defer rs.retryMu.Unlock()
+	rs.stats.Failed++
+	if _, ok := rs.deadLetters[rk]; !ok {
+		rs.deadLetters[rk] = &failedOp{what: what, op: op, failedAt: time.Now(), lastErr: err}
+	}
+	return err
+}
+
+// retryLoop retries queued writes every RetryInterval until stopped
+func (rs *ReplicatedStorage) retryLoop() {
+	defer close(rs.retryDone)
+
+	for {
+		rs.retryMu.Lock()
+		interval := rs.retry.RetryInterval
+		rs.retryMu.Unlock()
+		if interval <= 0 {
+			interval = DefaultRetryConfig().RetryInterval
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-rs.retryStop:
+			timer.Stop()
+			return
+		case <-timer.C:
+			rs.retryDeadLetters()
+		}
+	}
+}
+
+// retryDeadLetters makes one attempt at every queued write, dropping those
+// older than MaxAge that still fail
+func (rs *ReplicatedStorage) retryDeadLetters() {
+	rs.retryMu.Lock()
+	pending := make(map[replicaKey]*failedOp, len(rs.deadLetters))
+	for rk, f := range rs.deadLetters {
+		pending[rk] = f
+	}
+	maxAge := rs.retry.MaxAge
+	rs.retryMu.Unlock()
+
+	for rk, f := range pending {
+		err := f.op(rk.replica)
+
+		rs.retryMu.Lock()
+		rs.stats.Retries++
+		// A newer write may have replaced this one while it was retried;
+		// leave that to the next round
+		if rs.deadLetters[rk] != f {
+			rs.retryMu.Unlock()
+			continue
+		}
+		switch {
+		case err == nil:
+			delete(rs.deadLetters, rk)
+			rs.stats.Recovered++
+		case maxAge > 0 && time.Since(f.failedAt) > maxAge:
+			delete(rs.deadLetters, rk)
+			rs.stats.Dropped++
+			log.Printf("Replication error: dropping %s of %q after %v: %v", f.what, rk.key, maxAge, err)
+		default:
+			f.lastErr = err
+		}
+		rs.retryMu.Unlock()
+	}
+}
+
+// stopRetrying stops the background retry worker
+func (rs *ReplicatedStorage) stopRetrying() {
+	rs.retryStopOnce.Do(func() {
+		close(rs.retryStop)
+	})
+	<-rs.retryDone
+}
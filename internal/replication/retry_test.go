@@ -0,0 +1,123 @@
+package replication
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+// flakyStorage fails the first failures Puts to the wrapped storage
+type flakyStorage struct {
+	storage.Storage
+	mu       sync.Mutex
+	failures int
+	attempts int
+}
+
+func (f *flakyStorage) Put(key, value []byte) error {
+	f.mu.Lock()
+	f.attempts++
+	fail := f.attempts <= f.failures
+	f.mu.Unlock()
+	if fail {
+		return errors.New("replica unavailable")
+	}
+	return f.Storage.Put(key, value)
+}
+
+func newRetryTestStorage(t *testing.T, replica storage.Storage, cfg RetryConfig) *ReplicatedStorage {
+	rs, err := NewReplicatedStorage(newBadger(t), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rs.stopRetrying)
+	rs.replicas = []storage.Storage{replica}
+	rs.SetRetryConfig(cfg)
+	return rs
+}
+
+func waitForValue(t *testing.T, s storage.Storage, key, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if value, err := s.Get([]byte(key)); err == nil && string(value) == want {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Expected %s=%s on the replica", key, want)
+}
+
+func TestReplicatedStorage_RetriesFailedReplicaWrites(t *testing.T) {
+	replica := &flakyStorage{Storage: newBadger(t), failures: 2}
+	rs := newRetryTestStorage(t, replica, RetryConfig{
+		Attempts:      3,
+		Backoff:       time.Millisecond,
+		RetryInterval: time.Hour,
+	})
+
+	// The third attempt succeeds before Put returns
+	if err := rs.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if value, err := replica.Get([]byte("key")); err != nil || string(value) != "value" {
+		t.Errorf("Expected the replica to have the write on return, got %q, %v", value, err)
+	}
+	if stats := rs.RetryStats(); stats.Retries != 2 || stats.Failed != 0 || stats.Pending != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestReplicatedStorage_QueuedReplicaWriteEventuallyLands(t *testing.T) {
+	replica := &flakyStorage{Storage: newBadger(t), failures: 5}
+	rs := newRetryTestStorage(t, replica, RetryConfig{
+		Attempts:      2,
+		Backoff:       time.Millisecond,
+		RetryInterval: 10 * time.Millisecond,
+	})
+
+	if err := rs.Put([]byte("key"), []byte("v1")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if stats := rs.RetryStats(); stats.Failed != 1 {
+		t.Errorf("Expected the write to be queued, got %+v", stats)
+	}
+
+	// A newer write to the same key replaces the queued one
+	if err := rs.Put([]byte("key"), []byte("v2")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	waitForValue(t, replica, "key", "v2")
+	time.Sleep(50 * time.Millisecond)
+	if value, _ := replica.Get([]byte("key")); string(value) != "v2" {
+		t.Errorf("Expected the replica to keep v2, got %s", value)
+	}
+	if stats := rs.RetryStats(); stats.Pending != 0 || stats.Recovered != 1 {
+		t.Errorf("Expected the queued write to be recovered, got %+v", stats)
+	}
+}
+
+func TestReplicatedStorage_DropsQueuedWritesAfterMaxAge(t *testing.T) {
+	replica := &flakyStorage{Storage: newBadger(t), failures: 1 << 30}
+	rs := newRetryTestStorage(t, replica, RetryConfig{
+		Attempts:      1,
+		RetryInterval: 10 * time.Millisecond,
+		MaxAge:        30 * time.Millisecond,
+	})
+
+	if err := rs.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for rs.RetryStats().Dropped == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if stats := rs.RetryStats(); stats.Dropped != 1 || stats.Pending != 0 || stats.Recovered != 0 {
+		t.Errorf("Expected the write to be dropped, got %+v", stats)
+	}
+}
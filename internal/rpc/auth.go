@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"godatabase/internal/storage"
+)
+
+// authHeader is the metadata key carrying a client's bearer token
+const authHeader = "authorization"
+
+// unauthenticatedMethods can be called without a token, so load balancers
+// can probe a server without holding its secret
+var unauthenticatedMethods = map[string]bool{
+	"/storage.Storage/Health": true,
+}
+
+// ErrEmptyToken is returned by NewServerWithAuth when given an empty
+// token, which would otherwise leave the server open to every client
+var ErrEmptyToken = errors.New("authentication token must not be empty")
+
+// NewServerWithAuth creates a server that only answers clients presenting
+// token as a bearer token in their "authorization" metadata, as
+// client.NewWithToken does. Other calls fail with codes.Unauthenticated,
+// except Health. The token must not be empty. NewServer creates a server
+// without authentication, for local use.
+func NewServerWithAuth(storage storage.Storage, token string, opts ...ServerOption) (*Server, error) {
+	if token == "" {
+		return nil, ErrEmptyToken
+	}
+	opts = append([]ServerOption{func(s *Server) { s.token = token }}, opts...)
+	return NewServer(storage, opts...), nil
+}
+
+// authenticate fails with codes.Unauthenticated unless ctx carries the
+// server's token
+func (s *Server) authenticate(ctx context.Context, method string) error {
+	if unauthenticatedMethods[method] {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get(authHeader) {
+		token, ok := strings.CutPrefix(value, "Bearer ")
+		if ok && s.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid token")
+}
+
+// authUnary is a unary server interceptor that rejects unauthenticated
+// calls
+func (s *Server) authUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.authenticate(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authStream is a stream server interceptor that rejects unauthenticated
+// calls, so scans and watches are guarded as well
+func (s *Server) authStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authenticate(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
@@ -0,0 +1,75 @@
+package rpc
+
+import (
+	"context"
+
+	"godatabase/internal/rpc/proto"
+	"godatabase/internal/storage"
+)
+
+// Version is the server version reported by Capabilities
+const Version = "0.1.0"
+
+// features lists the RPCs this server implements. Names are stable: add
+// one whenever a new RPC is added, and never reuse a removed name.
+var features = []string{
+	"put",
+	"put_ttl",
+	"get",
+	"delete",
+	"delete_if",
+	"compare_and_swap",
+	"increment",
+	"batch_put",
+	"write_batch",
+	"batch",
+	"tail",
+	"fingerprint",
+	"split_ranges",
+	"scan",
+	"scan_prefix",
+	"keys",
+	"stats",
+	"metrics",
+	"size",
+	"barrier",
+	"cluster_info",
+	"capabilities",
+	"health",
+	"bootstrap",
+	"stream_operations",
+	"load_operations",
+	"watch",
+	"backup",
+	"range_hashes",
+	"scan_ranges",
+}
+
+// backendInfo names the type of storage a server is running over
+func backendInfo(s storage.Storage) string {
+	switch s.(type) {
+	case *storage.BadgerStorage:
+		return string(storage.BadgerStorageType)
+	case *storage.StorageEngine:
+		return string(storage.CustomStorage)
+	case *storage.MemStorage:
+		return string(storage.MemStorageType)
+	}
+	if _, ok := s.(clusterMember); ok {
+		return "raft"
+	}
+	return "unknown"
+}
+
+// Capabilities implements the Capabilities RPC method
+func (s *Server) Capabilities(ctx context.Context, req *proto.CapabilitiesRequest) (*proto.CapabilitiesResponse, error) {
+	limits := s.storage.Limits()
+	return &proto.CapabilitiesResponse{
+		Version:        Version,
+		Features:       append([]string(nil), features...),
+		Backend:        backendInfo(s.storage),
+		MaxMessageSize: int32(s.maxMsgSize),
+		MaxKeySize:     int32(limits.MaxKeySize),
+		MaxValueSize:   int32(limits.MaxValueSize),
+	}, nil
+}
@@ -0,0 +1,271 @@
+package rpc
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"godatabase/internal/rpc/proto"
+	"godatabase/internal/storage"
+)
+
+// feedHistory is how many recent operations the feed keeps for
+// subscribers that start from an earlier version
+const feedHistory = 10000
+
+// subscriberBuffer is how many operations may queue for a subscriber
+// before it is dropped as too slow
+const subscriberBuffer = 1024
+
+var (
+	// errVersionTooOld is returned when a subscriber asks for operations
+	// the feed no longer retains
+	errVersionTooOld = errors.New("version too old, bootstrap again")
+
+	// errSubscriberTooSlow is returned when a subscriber falls too far behind
+	errSubscriberTooSlow = errors.New("subscriber too slow")
+)
+
+// versionedStorage is implemented by storage backends that version their
+// own writes as they apply them, such as raft.RaftStorage, which uses the
+// index of the log entry that made each write. The feed follows the
+// storage's versions instead of numbering writes itself, so writes through
+// the server aren't serialized, and writes made through other nodes are
+// published too.
+type versionedStorage interface {
+	// ObserveApplied calls applied with the writes applied at each
+	// version from now on, in version order, and reset when the storage's
+	// contents are replaced up to a version without its writes being
+	// applied one by one. It returns the version applied before it was
+	// called.
+	ObserveApplied(applied func(version int64, ops []storage.BatchOp), reset func(version int64)) (int64, error)
+
+	// VersionedGet reads key along with the version of the last write
+	// applied before the read
+	VersionedGet(key []byte) ([]byte, int64, error)
+
+	// VersionedScan opens a point-in-time scan along with the version of
+	// the last write it holds
+	VersionedScan(start, end []byte) (storage.Iterator, int64, error)
+}
+
+// changeFeed numbers writes and fans them out to subscribers. Over a
+// versionedStorage the storage reports writes as it applies them;
+// otherwise the feed numbers the writes made through the server.
+type changeFeed struct {
+	// versioned is set once the feed follows a versionedStorage
+	versioned bool
+
+	// Over other storage, writing is held from when a write is applied to
+	// storage until it has been published, so versions follow the order
+	// writes were applied in and between never runs part way through a
+	// write
+	writing sync.Mutex
+
+	version     int64
+	trimmed     int64              // version of the newest operation no longer retained
+	history     []*proto.Operation // most recent operations, oldest first
+	subscribers map[*subscriber]struct{}
+	mu          sync.Mutex
+}
+
+// subscriber receives operations from the feed
+type subscriber struct {
+	ops     chan *proto.Operation
+	dropped chan struct{} // closed if the feed stopped delivering to it
+	reason  error         // why it was dropped, set before dropped is closed
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// follow makes the feed publish the writes vs applies, versioned by vs
+func (f *changeFeed) follow(vs versionedStorage) error {
+	start, err := vs.ObserveApplied(f.publishApplied, f.reset)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.versioned = true
+	// Writes applied before the feed started were never retained
+	if f.trimmed < start {
+		f.trimmed = start
+	}
+	if f.version < start {
+		f.version = start
+	}
+	return nil
+}
+
+// feedWrite is a write in progress; see changeFeed.begin
+type feedWrite struct {
+	feed *changeFeed
+}
+
+// begin starts a write, waiting for any other write, or call to between,
+// to finish. The caller applies the write to storage, publishes each
+// operation it made through the returned feedWrite, and then calls end.
+// A feed that follows its storage publishes writes as the storage applies
+// them, so begin doesn't wait and the write's publishes do nothing.
+func (f *changeFeed) begin() *feedWrite {
+	if f.versioned {
+		return &feedWrite{}
+	}
+	f.writing.Lock()
+	return &feedWrite{feed: f}
+}
+
+// publish assigns the next version to an operation of the write and
+// delivers it
+func (w *feedWrite) publish(opType proto.Operation_Type, key, value []byte) {
+	if w.feed == nil {
+		return
+	}
+	w.feed.publish(opType, key, value)
+}
+
+// end finishes the write
+func (w *feedWrite) end() {
+	if w.feed == nil {
+		return
+	}
+	w.feed.writing.Unlock()
+}
+
+// between runs fn while no write is in progress and returns the version
+// of the last write published before it, so what fn reads holds exactly
+// the writes up to that version. Writes wait for fn, so it should only
+// start a read, such as opening a point-in-time iterator. It is only
+// needed over storage the feed doesn't follow.
+func (f *changeFeed) between(fn func() error) (int64, error) {
+	f.writing.Lock()
+	defer f.writing.Unlock()
+
+	if err := fn(); err != nil {
+		return 0, err
+	}
+	return f.current(), nil
+}
+
+// current returns the version of the latest operation
+func (f *changeFeed) current() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.version
+}
+
+// publish assigns the next version to an operation and delivers it
+func (f *changeFeed) publish(opType proto.Operation_Type, key, value []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.version++
+	f.deliver(&proto.Operation{
+		Type:      opType,
+		Key:       key,
+		Value:     value,
+		Timestamp: time.Now().UnixNano(),
+		Version:   f.version,
+	})
+}
+
+// publishApplied delivers the writes a followed storage applied at
+// version. They all carry that version, so a subscriber starting from it
+// gets all of them or none.
+func (f *changeFeed) publishApplied(version int64, ops []storage.BatchOp) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.version = version
+	now := time.Now().UnixNano()
+	for _, op := range ops {
+		opType := proto.Operation_PUT
+		if op.Delete {
+			opType = proto.Operation_DELETE
+		}
+		f.deliver(&proto.Operation{
+			Type:      opType,
+			Key:       op.Key,
+			Value:     op.Value,
+			Timestamp: now,
+			Version:   version,
+		})
+	}
+}
+
+// reset drops the history and every subscriber once a followed storage's
+// contents were replaced up to version, since the writes that did it
+// can't be delivered
+func (f *changeFeed) reset(version int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.version, f.trimmed = version, version
+	f.history = nil
+	for sub := range f.subscribers {
+		f.drop(sub, errVersionTooOld)
+	}
+}
+
+// deliver retains op and sends it to every subscriber. The caller must
+// hold f.mu.
+func (f *changeFeed) deliver(op *proto.Operation) {
+	f.history = append(f.history, op)
+	if len(f.history) > feedHistory {
+		drop := len(f.history) - feedHistory
+		f.trimmed = f.history[drop-1].Version
+		f.history = f.history[drop:]
+	}
+
+	for sub := range f.subscribers {
+		select {
+		case sub.ops <- op:
+		default:
+			f.drop(sub, errSubscriberTooSlow)
+		}
+	}
+}
+
+// drop stops delivering to sub. The caller must hold f.mu.
+func (f *changeFeed) drop(sub *subscriber, reason error) {
+	sub.reason = reason
+	close(sub.dropped)
+	delete(f.subscribers, sub)
+}
+
+// subscribe returns the retained operations after version and registers
+// a subscriber for everything published from now on
+func (f *changeFeed) subscribe(version int64) ([]*proto.Operation, *subscriber, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if version < f.trimmed {
+		return nil, nil, errVersionTooOld
+	}
+
+	// Versions in the history ascend, but may repeat or skip numbers
+	i := sort.Search(len(f.history), func(i int) bool {
+		return f.history[i].Version > version
+	})
+	backlog := append([]*proto.Operation(nil), f.history[i:]...)
+	sub := &subscriber{
+		ops:     make(chan *proto.Operation, subscriberBuffer),
+		dropped: make(chan struct{}),
+	}
+	f.subscribers[sub] = struct{}{}
+	return backlog, sub, nil
+}
+
+// unsubscribe stops delivering operations to sub
+func (f *changeFeed) unsubscribe(sub *subscriber) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.subscribers, sub)
+}
@@ -0,0 +1,64 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"godatabase/internal/rpc/proto"
+	"godatabase/internal/storage"
+)
+
+// Roles reported by Health
+const (
+	roleLeader   = "leader"
+	roleFollower = "follower"
+)
+
+// healthProbeKey is read to check that the storage answers; it doesn't
+// need to exist
+var healthProbeKey = []byte("\x00health")
+
+// healthChecker is implemented by storage that checks itself without going
+// through a cluster's read path, such as raft.RaftStorage, whose reads
+// fail while no leader is elected
+type healthChecker interface {
+	Health() error
+}
+
+// checkStorage makes a trivial read to check the storage is reachable
+func (s *Server) checkStorage() error {
+	if checker, ok := s.storage.(healthChecker); ok {
+		return checker.Health()
+	}
+	if _, err := s.storage.Get(healthProbeKey); err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Health implements the Health RPC method. A server over standalone
+// storage reports itself as the leader, like ClusterInfo; a cluster member
+// only serves while it knows a leader.
+func (s *Server) Health(ctx context.Context, req *proto.HealthRequest) (*proto.HealthResponse, error) {
+	resp := &proto.HealthResponse{Role: roleLeader}
+	member, inCluster := s.storage.(clusterMember)
+	if inCluster && !member.IsLeader() {
+		resp.Role = roleFollower
+	}
+
+	if err := s.checkStorage(); err != nil {
+		resp.Error = fmt.Sprintf("storage unreachable: %v", err)
+		return resp, nil
+	}
+	resp.StorageOk = true
+
+	if inCluster {
+		if _, err := member.LeaderID(); err != nil {
+			resp.Error = fmt.Sprintf("no leader: %v", err)
+			return resp, nil
+		}
+	}
+	resp.Serving = true
+	return resp, nil
+}
@@ -0,0 +1,167 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"godatabase/internal/rpc/proto"
+)
+
+// DefaultSlowThreshold is the latency from which a unary call is logged as
+// slow, unless WithSlowThreshold sets another
+const DefaultSlowThreshold = 100 * time.Millisecond
+
+// latencyBuckets are the upper bounds of the latency histogram's buckets.
+// Calls slower than the last bound fall into one more bucket.
+var latencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// WithSlowThreshold sets the latency from which a unary call counts as
+// slow, and is logged if slow logging is on. Zero or less keeps
+// DefaultSlowThreshold.
+func WithSlowThreshold(threshold time.Duration) ServerOption {
+	return func(s *Server) {
+		if threshold > 0 {
+			s.latency.slowThreshold = threshold
+		}
+	}
+}
+
+// WithSlowLogging turns logging of slow calls on or off. It is on by
+// default; slow calls are counted in Metrics either way.
+func WithSlowLogging(enabled bool) ServerOption {
+	return func(s *Server) {
+		s.latency.logSlow = enabled
+	}
+}
+
+// methodLatency is the latency histogram of one RPC method
+type methodLatency struct {
+	count   uint64
+	total   time.Duration
+	max     time.Duration
+	buckets []uint64
+	slow    uint64
+}
+
+// latencyRecorder keeps a latency histogram for each unary RPC method
+type latencyRecorder struct {
+	slowThreshold time.Duration
+	logSlow       bool
+
+	mu      sync.Mutex
+	methods map[string]*methodLatency
+}
+
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{
+		slowThreshold: DefaultSlowThreshold,
+		logSlow:       true,
+		methods:       make(map[string]*methodLatency),
+	}
+}
+
+// intercept is a unary server interceptor that times each call, records
+// it in the method's histogram and logs it if it was slow
+func (l *latencyRecorder) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	elapsed := time.Since(start)
+
+	method := path.Base(info.FullMethod)
+	slow := elapsed >= l.slowThreshold
+	l.record(method, elapsed, slow)
+	if slow && l.logSlow {
+		log.Printf("Slow %s took %v%s", method, elapsed, describeRequest(req))
+	}
+	return resp, err
+}
+
+// record adds a call to method's histogram
+func (l *latencyRecorder) record(method string, elapsed time.Duration, slow bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m, ok := l.methods[method]
+	if !ok {
+		m = &methodLatency{buckets: make([]uint64, len(latencyBuckets)+1)}
+		l.methods[method] = m
+	}
+	m.count++
+	m.total += elapsed
+	if elapsed > m.max {
+		m.max = elapsed
+	}
+	m.buckets[sort.Search(len(latencyBuckets), func(i int) bool {
+		return elapsed <= latencyBuckets[i]
+	})]++
+	if slow {
+		m.slow++
+	}
+}
+
+// describeRequest reports the sizes of a request's key and value, for
+// requests that have them
+func describeRequest(req interface{}) string {
+	keyed, ok := req.(interface{ GetKey() []byte })
+	if !ok {
+		return ""
+	}
+	if valued, ok := req.(interface{ GetValue() []byte }); ok {
+		return fmt.Sprintf(" (key %d bytes, value %d bytes)", len(keyed.GetKey()), len(valued.GetValue()))
+	}
+	return fmt.Sprintf(" (key %d bytes)", len(keyed.GetKey()))
+}
+
+// snapshot returns the histograms as a MetricsResponse, sorted by method
+func (l *latencyRecorder) snapshot() *proto.MetricsResponse {
+	resp := &proto.MetricsResponse{
+		BucketBoundsUs:  make([]int64, len(latencyBuckets)),
+		SlowThresholdUs: l.slowThreshold.Microseconds(),
+	}
+	for i, bound := range latencyBuckets {
+		resp.BucketBoundsUs[i] = bound.Microseconds()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for method, m := range l.methods {
+		resp.Methods = append(resp.Methods, &proto.MethodLatency{
+			Method:  method,
+			Count:   m.count,
+			TotalUs: m.total.Microseconds(),
+			MaxUs:   m.max.Microseconds(),
+			Buckets: append([]uint64(nil), m.buckets...),
+			Slow:    m.slow,
+		})
+	}
+	sort.Slice(resp.Methods, func(i, j int) bool {
+		return resp.Methods[i].Method < resp.Methods[j].Method
+	})
+	return resp
+}
+
+// Metrics implements the Metrics RPC method
+func (s *Server) Metrics(ctx context.Context, req *proto.MetricsRequest) (*proto.MetricsResponse, error) {
+	return s.latency.snapshot(), nil
+}
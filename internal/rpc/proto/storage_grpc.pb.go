@@ -28,8 +28,70 @@ type StorageClient interface {
 	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
 	// Delete removes a key-value pair
 	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	// DeleteIf removes a key only if it holds the expected value
+	DeleteIf(ctx context.Context, in *DeleteIfRequest, opts ...grpc.CallOption) (*DeleteIfResponse, error)
+	// CompareAndSwap sets a key only if it holds the old value, or is absent
+	CompareAndSwap(ctx context.Context, in *CompareAndSwapRequest, opts ...grpc.CallOption) (*CompareAndSwapResponse, error)
+	// Increment adds to a key's counter, a big-endian int64, and returns the
+	// new value
+	Increment(ctx context.Context, in *IncrementRequest, opts ...grpc.CallOption) (*IncrementResponse, error)
+	// BatchPut stores several key-value pairs in one round trip
+	BatchPut(ctx context.Context, in *BatchPutRequest, opts ...grpc.CallOption) (*BatchPutResponse, error)
+	// WriteBatch applies puts and deletes together, atomically if the
+	// storage backend supports it
+	WriteBatch(ctx context.Context, in *WriteBatchRequest, opts ...grpc.CallOption) (*WriteBatchResponse, error)
+	// Batch applies gets, puts and deletes in order in one round trip and
+	// returns a result for each
+	Batch(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResponse, error)
+	// Tail returns the largest keys in descending order
+	Tail(ctx context.Context, in *TailRequest, opts ...grpc.CallOption) (*TailResponse, error)
+	// Fingerprint returns an order-independent hash of the whole dataset,
+	// for comparing replicas
+	Fingerprint(ctx context.Context, in *FingerprintRequest, opts ...grpc.CallOption) (*FingerprintResponse, error)
+	// SplitRanges divides the keyspace into ranges of roughly equal size
+	SplitRanges(ctx context.Context, in *SplitRangesRequest, opts ...grpc.CallOption) (*SplitRangesResponse, error)
+	// RangeHashes hashes the dataset over 2^depth key ranges, for finding
+	// the ranges that differ between replicas
+	RangeHashes(ctx context.Context, in *RangeHashesRequest, opts ...grpc.CallOption) (*RangeHashesResponse, error)
+	// Scan streams the key-value pairs in a key range in ascending order, or
+	// descending if asked, up to an optional limit
+	Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (Storage_ScanClient, error)
+	// ScanPrefix streams the key-value pairs whose keys begin with a prefix
+	ScanPrefix(ctx context.Context, in *ScanPrefixRequest, opts ...grpc.CallOption) (Storage_ScanPrefixClient, error)
+	// Keys streams every key in ascending order, without its value
+	Keys(ctx context.Context, in *KeysRequest, opts ...grpc.CallOption) (Storage_KeysClient, error)
+	// Stats reports the key count, disk usage and backend of the storage
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+	// Metrics reports the server's latency histogram for each RPC method
+	Metrics(ctx context.Context, in *MetricsRequest, opts ...grpc.CallOption) (*MetricsResponse, error)
+	// Size returns the number of keys
+	Size(ctx context.Context, in *SizeRequest, opts ...grpc.CallOption) (*SizeResponse, error)
+	// Barrier returns once every write acknowledged before it is durable
+	Barrier(ctx context.Context, in *BarrierRequest, opts ...grpc.CallOption) (*BarrierResponse, error)
+	// ClusterInfo reports this node's role so clients can find the leader
+	ClusterInfo(ctx context.Context, in *ClusterInfoRequest, opts ...grpc.CallOption) (*ClusterInfoResponse, error)
+	// Capabilities reports the server version, supported features and limits
+	Capabilities(ctx context.Context, in *CapabilitiesRequest, opts ...grpc.CallOption) (*CapabilitiesResponse, error)
+	// Health reports whether this node is serving, its role and whether its
+	// storage can be read, for load balancers and orchestration
+	Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error)
+	// Bootstrap streams a consistent snapshot of every key-value pair,
+	// ending with a marker that carries the snapshot's version
+	Bootstrap(ctx context.Context, in *BootstrapRequest, opts ...grpc.CallOption) (Storage_BootstrapClient, error)
 	// Stream operations for replication
 	StreamOperations(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (Storage_StreamOperationsClient, error)
+	// LoadOperations applies a stream of puts and deletes in order,
+	// acknowledging each one, for bulk loading
+	LoadOperations(ctx context.Context, opts ...grpc.CallOption) (Storage_LoadOperationsClient, error)
+	// Watch streams a key's current value, then its value after every
+	// later write to it
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Storage_WatchClient, error)
+	// Backup streams a point-in-time backup of the storage in chunks, to
+	// be concatenated and passed to storage.Restore
+	Backup(ctx context.Context, in *BackupRequest, opts ...grpc.CallOption) (Storage_BackupClient, error)
+	// ScanRanges streams the key-value pairs in some of the key ranges
+	// numbered by RangeHashes, in ascending order
+	ScanRanges(ctx context.Context, in *ScanRangesRequest, opts ...grpc.CallOption) (Storage_ScanRangesClient, error)
 }
 
 type storageClient struct {
@@ -67,8 +129,289 @@ func (c *storageClient) Delete(ctx context.Context, in *DeleteRequest, opts ...g
 	return out, nil
 }
 
+func (c *storageClient) DeleteIf(ctx context.Context, in *DeleteIfRequest, opts ...grpc.CallOption) (*DeleteIfResponse, error) {
+	out := new(DeleteIfResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/DeleteIf", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) CompareAndSwap(ctx context.Context, in *CompareAndSwapRequest, opts ...grpc.CallOption) (*CompareAndSwapResponse, error) {
+	out := new(CompareAndSwapResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/CompareAndSwap", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Increment(ctx context.Context, in *IncrementRequest, opts ...grpc.CallOption) (*IncrementResponse, error) {
+	out := new(IncrementResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/Increment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) BatchPut(ctx context.Context, in *BatchPutRequest, opts ...grpc.CallOption) (*BatchPutResponse, error) {
+	out := new(BatchPutResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/BatchPut", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) WriteBatch(ctx context.Context, in *WriteBatchRequest, opts ...grpc.CallOption) (*WriteBatchResponse, error) {
+	out := new(WriteBatchResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/WriteBatch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Batch(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResponse, error) {
+	out := new(BatchResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/Batch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Tail(ctx context.Context, in *TailRequest, opts ...grpc.CallOption) (*TailResponse, error) {
+	out := new(TailResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/Tail", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Fingerprint(ctx context.Context, in *FingerprintRequest, opts ...grpc.CallOption) (*FingerprintResponse, error) {
+	out := new(FingerprintResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/Fingerprint", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) SplitRanges(ctx context.Context, in *SplitRangesRequest, opts ...grpc.CallOption) (*SplitRangesResponse, error) {
+	out := new(SplitRangesResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/SplitRanges", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) RangeHashes(ctx context.Context, in *RangeHashesRequest, opts ...grpc.CallOption) (*RangeHashesResponse, error) {
+	out := new(RangeHashesResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/RangeHashes", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (Storage_ScanClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[0], "/storage.Storage/Scan", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageScanClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Storage_ScanClient interface {
+	Recv() (*KeyValue, error)
+	grpc.ClientStream
+}
+
+type storageScanClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageScanClient) Recv() (*KeyValue, error) {
+	m := new(KeyValue)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageClient) ScanPrefix(ctx context.Context, in *ScanPrefixRequest, opts ...grpc.CallOption) (Storage_ScanPrefixClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[1], "/storage.Storage/ScanPrefix", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageScanPrefixClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Storage_ScanPrefixClient interface {
+	Recv() (*KeyValue, error)
+	grpc.ClientStream
+}
+
+type storageScanPrefixClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageScanPrefixClient) Recv() (*KeyValue, error) {
+	m := new(KeyValue)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageClient) Keys(ctx context.Context, in *KeysRequest, opts ...grpc.CallOption) (Storage_KeysClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[2], "/storage.Storage/Keys", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageKeysClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Storage_KeysClient interface {
+	Recv() (*Key, error)
+	grpc.ClientStream
+}
+
+type storageKeysClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageKeysClient) Recv() (*Key, error) {
+	m := new(Key)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	out := new(StatsResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/Stats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Metrics(ctx context.Context, in *MetricsRequest, opts ...grpc.CallOption) (*MetricsResponse, error) {
+	out := new(MetricsResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/Metrics", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Size(ctx context.Context, in *SizeRequest, opts ...grpc.CallOption) (*SizeResponse, error) {
+	out := new(SizeResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/Size", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Barrier(ctx context.Context, in *BarrierRequest, opts ...grpc.CallOption) (*BarrierResponse, error) {
+	out := new(BarrierResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/Barrier", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) ClusterInfo(ctx context.Context, in *ClusterInfoRequest, opts ...grpc.CallOption) (*ClusterInfoResponse, error) {
+	out := new(ClusterInfoResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/ClusterInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Capabilities(ctx context.Context, in *CapabilitiesRequest, opts ...grpc.CallOption) (*CapabilitiesResponse, error) {
+	out := new(CapabilitiesResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/Capabilities", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error) {
+	out := new(HealthResponse)
+	err := c.cc.Invoke(ctx, "/storage.Storage/Health", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Bootstrap(ctx context.Context, in *BootstrapRequest, opts ...grpc.CallOption) (Storage_BootstrapClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[3], "/storage.Storage/Bootstrap", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageBootstrapClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Storage_BootstrapClient interface {
+	Recv() (*BootstrapMessage, error)
+	grpc.ClientStream
+}
+
+type storageBootstrapClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageBootstrapClient) Recv() (*BootstrapMessage, error) {
+	m := new(BootstrapMessage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *storageClient) StreamOperations(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (Storage_StreamOperationsClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[0], "/storage.Storage/StreamOperations", opts...)
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[4], "/storage.Storage/StreamOperations", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -82,121 +425,775 @@ func (c *storageClient) StreamOperations(ctx context.Context, in *StreamRequest,
 	return x, nil
 }
 
-type Storage_StreamOperationsClient interface {
-	Recv() (*Operation, error)
-	grpc.ClientStream
+type Storage_StreamOperationsClient interface {
+	Recv() (*Operation, error)
+	grpc.ClientStream
+}
+
+type storageStreamOperationsClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageStreamOperationsClient) Recv() (*Operation, error) {
+	m := new(Operation)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageClient) LoadOperations(ctx context.Context, opts ...grpc.CallOption) (Storage_LoadOperationsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[5], "/storage.Storage/LoadOperations", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageLoadOperationsClient{stream}
+	return x, nil
+}
+
+type Storage_LoadOperationsClient interface {
+	Send(*Operation) error
+	Recv() (*OperationAck, error)
+	grpc.ClientStream
+}
+
+type storageLoadOperationsClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageLoadOperationsClient) Send(m *Operation) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *storageLoadOperationsClient) Recv() (*OperationAck, error) {
+	m := new(OperationAck)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Storage_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[6], "/storage.Storage/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Storage_WatchClient interface {
+	Recv() (*WatchEvent, error)
+	grpc.ClientStream
+}
+
+type storageWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageWatchClient) Recv() (*WatchEvent, error) {
+	m := new(WatchEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageClient) Backup(ctx context.Context, in *BackupRequest, opts ...grpc.CallOption) (Storage_BackupClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[7], "/storage.Storage/Backup", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageBackupClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Storage_BackupClient interface {
+	Recv() (*BackupChunk, error)
+	grpc.ClientStream
+}
+
+type storageBackupClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageBackupClient) Recv() (*BackupChunk, error) {
+	m := new(BackupChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageClient) ScanRanges(ctx context.Context, in *ScanRangesRequest, opts ...grpc.CallOption) (Storage_ScanRangesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[8], "/storage.Storage/ScanRanges", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageScanRangesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Storage_ScanRangesClient interface {
+	Recv() (*KeyValue, error)
+	grpc.ClientStream
+}
+
+type storageScanRangesClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageScanRangesClient) Recv() (*KeyValue, error) {
+	m := new(KeyValue)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// StorageServer is the server API for Storage service.
+// All implementations must embed UnimplementedStorageServer
+// for forward compatibility
+type StorageServer interface {
+	// Put stores a key-value pair
+	Put(context.Context, *PutRequest) (*PutResponse, error)
+	// Get retrieves a value for a key
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	// Delete removes a key-value pair
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	// DeleteIf removes a key only if it holds the expected value
+	DeleteIf(context.Context, *DeleteIfRequest) (*DeleteIfResponse, error)
+	// CompareAndSwap sets a key only if it holds the old value, or is absent
+	CompareAndSwap(context.Context, *CompareAndSwapRequest) (*CompareAndSwapResponse, error)
+	// Increment adds to a key's counter, a big-endian int64, and returns the
+	// new value
+	Increment(context.Context, *IncrementRequest) (*IncrementResponse, error)
+	// BatchPut stores several key-value pairs in one round trip
+	BatchPut(context.Context, *BatchPutRequest) (*BatchPutResponse, error)
+	// WriteBatch applies puts and deletes together, atomically if the
+	// storage backend supports it
+	WriteBatch(context.Context, *WriteBatchRequest) (*WriteBatchResponse, error)
+	// Batch applies gets, puts and deletes in order in one round trip and
+	// returns a result for each
+	Batch(context.Context, *BatchRequest) (*BatchResponse, error)
+	// Tail returns the largest keys in descending order
+	Tail(context.Context, *TailRequest) (*TailResponse, error)
+	// Fingerprint returns an order-independent hash of the whole dataset,
+	// for comparing replicas
+	Fingerprint(context.Context, *FingerprintRequest) (*FingerprintResponse, error)
+	// SplitRanges divides the keyspace into ranges of roughly equal size
+	SplitRanges(context.Context, *SplitRangesRequest) (*SplitRangesResponse, error)
+	// RangeHashes hashes the dataset over 2^depth key ranges, for finding
+	// the ranges that differ between replicas
+	RangeHashes(context.Context, *RangeHashesRequest) (*RangeHashesResponse, error)
+	// Scan streams the key-value pairs in a key range in ascending order, or
+	// descending if asked, up to an optional limit
+	Scan(*ScanRequest, Storage_ScanServer) error
+	// ScanPrefix streams the key-value pairs whose keys begin with a prefix
+	ScanPrefix(*ScanPrefixRequest, Storage_ScanPrefixServer) error
+	// Keys streams every key in ascending order, without its value
+	Keys(*KeysRequest, Storage_KeysServer) error
+	// Stats reports the key count, disk usage and backend of the storage
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+	// Metrics reports the server's latency histogram for each RPC method
+	Metrics(context.Context, *MetricsRequest) (*MetricsResponse, error)
+	// Size returns the number of keys
+	Size(context.Context, *SizeRequest) (*SizeResponse, error)
+	// Barrier returns once every write acknowledged before it is durable
+	Barrier(context.Context, *BarrierRequest) (*BarrierResponse, error)
+	// ClusterInfo reports this node's role so clients can find the leader
+	ClusterInfo(context.Context, *ClusterInfoRequest) (*ClusterInfoResponse, error)
+	// Capabilities reports the server version, supported features and limits
+	Capabilities(context.Context, *CapabilitiesRequest) (*CapabilitiesResponse, error)
+	// Health reports whether this node is serving, its role and whether its
+	// storage can be read, for load balancers and orchestration
+	Health(context.Context, *HealthRequest) (*HealthResponse, error)
+	// Bootstrap streams a consistent snapshot of every key-value pair,
+	// ending with a marker that carries the snapshot's version
+	Bootstrap(*BootstrapRequest, Storage_BootstrapServer) error
+	// Stream operations for replication
+	StreamOperations(*StreamRequest, Storage_StreamOperationsServer) error
+	// LoadOperations applies a stream of puts and deletes in order,
+	// acknowledging each one, for bulk loading
+	LoadOperations(Storage_LoadOperationsServer) error
+	// Watch streams a key's current value, then its value after every
+	// later write to it
+	Watch(*WatchRequest, Storage_WatchServer) error
+	// Backup streams a point-in-time backup of the storage in chunks, to
+	// be concatenated and passed to storage.Restore
+	Backup(*BackupRequest, Storage_BackupServer) error
+	// ScanRanges streams the key-value pairs in some of the key ranges
+	// numbered by RangeHashes, in ascending order
+	ScanRanges(*ScanRangesRequest, Storage_ScanRangesServer) error
+	mustEmbedUnimplementedStorageServer()
+}
+
+// UnimplementedStorageServer must be embedded to have forward compatible implementations.
+type UnimplementedStorageServer struct {
+}
+
+func (UnimplementedStorageServer) Put(context.Context, *PutRequest) (*PutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Put not implemented")
+}
+func (UnimplementedStorageServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedStorageServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedStorageServer) DeleteIf(context.Context, *DeleteIfRequest) (*DeleteIfResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteIf not implemented")
+}
+func (UnimplementedStorageServer) CompareAndSwap(context.Context, *CompareAndSwapRequest) (*CompareAndSwapResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompareAndSwap not implemented")
+}
+func (UnimplementedStorageServer) Increment(context.Context, *IncrementRequest) (*IncrementResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Increment not implemented")
+}
+func (UnimplementedStorageServer) BatchPut(context.Context, *BatchPutRequest) (*BatchPutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchPut not implemented")
+}
+func (UnimplementedStorageServer) WriteBatch(context.Context, *WriteBatchRequest) (*WriteBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WriteBatch not implemented")
+}
+func (UnimplementedStorageServer) Batch(context.Context, *BatchRequest) (*BatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Batch not implemented")
+}
+func (UnimplementedStorageServer) Tail(context.Context, *TailRequest) (*TailResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Tail not implemented")
+}
+func (UnimplementedStorageServer) Fingerprint(context.Context, *FingerprintRequest) (*FingerprintResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Fingerprint not implemented")
+}
+func (UnimplementedStorageServer) SplitRanges(context.Context, *SplitRangesRequest) (*SplitRangesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SplitRanges not implemented")
+}
+func (UnimplementedStorageServer) RangeHashes(context.Context, *RangeHashesRequest) (*RangeHashesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RangeHashes not implemented")
+}
+func (UnimplementedStorageServer) Scan(*ScanRequest, Storage_ScanServer) error {
+	return status.Errorf(codes.Unimplemented, "method Scan not implemented")
+}
+func (UnimplementedStorageServer) ScanPrefix(*ScanPrefixRequest, Storage_ScanPrefixServer) error {
+	return status.Errorf(codes.Unimplemented, "method ScanPrefix not implemented")
+}
+func (UnimplementedStorageServer) Keys(*KeysRequest, Storage_KeysServer) error {
+	return status.Errorf(codes.Unimplemented, "method Keys not implemented")
+}
+func (UnimplementedStorageServer) Stats(context.Context, *StatsRequest) (*StatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
+}
+func (UnimplementedStorageServer) Metrics(context.Context, *MetricsRequest) (*MetricsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Metrics not implemented")
+}
+func (UnimplementedStorageServer) Size(context.Context, *SizeRequest) (*SizeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Size not implemented")
+}
+func (UnimplementedStorageServer) Barrier(context.Context, *BarrierRequest) (*BarrierResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Barrier not implemented")
+}
+func (UnimplementedStorageServer) ClusterInfo(context.Context, *ClusterInfoRequest) (*ClusterInfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClusterInfo not implemented")
+}
+func (UnimplementedStorageServer) Capabilities(context.Context, *CapabilitiesRequest) (*CapabilitiesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Capabilities not implemented")
+}
+func (UnimplementedStorageServer) Health(context.Context, *HealthRequest) (*HealthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Health not implemented")
+}
+func (UnimplementedStorageServer) Bootstrap(*BootstrapRequest, Storage_BootstrapServer) error {
+	return status.Errorf(codes.Unimplemented, "method Bootstrap not implemented")
+}
+func (UnimplementedStorageServer) StreamOperations(*StreamRequest, Storage_StreamOperationsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamOperations not implemented")
+}
+func (UnimplementedStorageServer) LoadOperations(Storage_LoadOperationsServer) error {
+	return status.Errorf(codes.Unimplemented, "method LoadOperations not implemented")
+}
+func (UnimplementedStorageServer) Watch(*WatchRequest, Storage_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedStorageServer) Backup(*BackupRequest, Storage_BackupServer) error {
+	return status.Errorf(codes.Unimplemented, "method Backup not implemented")
+}
+func (UnimplementedStorageServer) ScanRanges(*ScanRangesRequest, Storage_ScanRangesServer) error {
+	return status.Errorf(codes.Unimplemented, "method ScanRanges not implemented")
+}
+func (UnimplementedStorageServer) mustEmbedUnimplementedStorageServer() {}
+
+// UnsafeStorageServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to StorageServer will
+// result in compilation errors.
+type UnsafeStorageServer interface {
+	mustEmbedUnimplementedStorageServer()
+}
+
+func RegisterStorageServer(s grpc.ServiceRegistrar, srv StorageServer) {
+	s.RegisterService(&Storage_ServiceDesc, srv)
+}
+
+func _Storage_Put_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Put(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/Put",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Put(ctx, req.(*PutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_DeleteIf_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteIfRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).DeleteIf(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/DeleteIf",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).DeleteIf(ctx, req.(*DeleteIfRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_CompareAndSwap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompareAndSwapRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).CompareAndSwap(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/CompareAndSwap",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).CompareAndSwap(ctx, req.(*CompareAndSwapRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_Increment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IncrementRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Increment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/Increment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Increment(ctx, req.(*IncrementRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_BatchPut_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchPutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).BatchPut(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/BatchPut",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).BatchPut(ctx, req.(*BatchPutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_WriteBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WriteBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).WriteBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/WriteBatch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).WriteBatch(ctx, req.(*WriteBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_Batch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Batch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/Batch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Batch(ctx, req.(*BatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_Tail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TailRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Tail(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/Tail",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Tail(ctx, req.(*TailRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_Fingerprint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FingerprintRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Fingerprint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/Fingerprint",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Fingerprint(ctx, req.(*FingerprintRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_SplitRanges_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SplitRangesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).SplitRanges(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/SplitRanges",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).SplitRanges(ctx, req.(*SplitRangesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_RangeHashes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RangeHashesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).RangeHashes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/RangeHashes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).RangeHashes(ctx, req.(*RangeHashesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_Scan_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScanRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageServer).Scan(m, &storageScanServer{stream})
+}
+
+type Storage_ScanServer interface {
+	Send(*KeyValue) error
+	grpc.ServerStream
+}
+
+type storageScanServer struct {
+	grpc.ServerStream
 }
 
-type storageStreamOperationsClient struct {
-	grpc.ClientStream
+func (x *storageScanServer) Send(m *KeyValue) error {
+	return x.ServerStream.SendMsg(m)
 }
 
-func (x *storageStreamOperationsClient) Recv() (*Operation, error) {
-	m := new(Operation)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
-		return nil, err
+func _Storage_ScanPrefix_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScanPrefixRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
 	}
-	return m, nil
+	return srv.(StorageServer).ScanPrefix(m, &storageScanPrefixServer{stream})
 }
 
-// StorageServer is the server API for Storage service.
-// All implementations must embed UnimplementedStorageServer
-// for forward compatibility
-type StorageServer interface {
-	// Put stores a key-value pair
-	Put(context.Context, *PutRequest) (*PutResponse, error)
-	// Get retrieves a value for a key
-	Get(context.Context, *GetRequest) (*GetResponse, error)
-	// Delete removes a key-value pair
-	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
-	// Stream operations for replication
-	StreamOperations(*StreamRequest, Storage_StreamOperationsServer) error
-	mustEmbedUnimplementedStorageServer()
+type Storage_ScanPrefixServer interface {
+	Send(*KeyValue) error
+	grpc.ServerStream
 }
 
-// UnimplementedStorageServer must be embedded to have forward compatible implementations.
-type UnimplementedStorageServer struct {
+type storageScanPrefixServer struct {
+	grpc.ServerStream
 }
 
-func (UnimplementedStorageServer) Put(context.Context, *PutRequest) (*PutResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Put not implemented")
+func (x *storageScanPrefixServer) Send(m *KeyValue) error {
+	return x.ServerStream.SendMsg(m)
 }
-func (UnimplementedStorageServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+
+func _Storage_Keys_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(KeysRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageServer).Keys(m, &storageKeysServer{stream})
 }
-func (UnimplementedStorageServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+
+type Storage_KeysServer interface {
+	Send(*Key) error
+	grpc.ServerStream
 }
-func (UnimplementedStorageServer) StreamOperations(*StreamRequest, Storage_StreamOperationsServer) error {
-	return status.Errorf(codes.Unimplemented, "method StreamOperations not implemented")
+
+type storageKeysServer struct {
+	grpc.ServerStream
 }
-func (UnimplementedStorageServer) mustEmbedUnimplementedStorageServer() {}
 
-// UnsafeStorageServer may be embedded to opt out of forward compatibility for this service.
-// Use of this interface is not recommended, as added methods to StorageServer will
-// result in compilation errors.
-type UnsafeStorageServer interface {
-	mustEmbedUnimplementedStorageServer()
+func (x *storageKeysServer) Send(m *Key) error {
+	return x.ServerStream.SendMsg(m)
 }
 
-func RegisterStorageServer(s grpc.ServiceRegistrar, srv StorageServer) {
-	s.RegisterService(&Storage_ServiceDesc, srv)
+func _Storage_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/Stats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _Storage_Put_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(PutRequest)
+func _Storage_Metrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MetricsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(StorageServer).Put(ctx, in)
+		return srv.(StorageServer).Metrics(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/storage.Storage/Put",
+		FullMethod: "/storage.Storage/Metrics",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(StorageServer).Put(ctx, req.(*PutRequest))
+		return srv.(StorageServer).Metrics(ctx, req.(*MetricsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _Storage_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetRequest)
+func _Storage_Size_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SizeRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(StorageServer).Get(ctx, in)
+		return srv.(StorageServer).Size(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/storage.Storage/Get",
+		FullMethod: "/storage.Storage/Size",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(StorageServer).Get(ctx, req.(*GetRequest))
+		return srv.(StorageServer).Size(ctx, req.(*SizeRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _Storage_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeleteRequest)
+func _Storage_Barrier_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BarrierRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(StorageServer).Delete(ctx, in)
+		return srv.(StorageServer).Barrier(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/storage.Storage/Delete",
+		FullMethod: "/storage.Storage/Barrier",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(StorageServer).Delete(ctx, req.(*DeleteRequest))
+		return srv.(StorageServer).Barrier(ctx, req.(*BarrierRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_ClusterInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClusterInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).ClusterInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/ClusterInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).ClusterInfo(ctx, req.(*ClusterInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_Capabilities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CapabilitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Capabilities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/Capabilities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Capabilities(ctx, req.(*CapabilitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_Health_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HealthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Health(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.Storage/Health",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Health(ctx, req.(*HealthRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Storage_Bootstrap_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BootstrapRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageServer).Bootstrap(m, &storageBootstrapServer{stream})
+}
+
+type Storage_BootstrapServer interface {
+	Send(*BootstrapMessage) error
+	grpc.ServerStream
+}
+
+type storageBootstrapServer struct {
+	grpc.ServerStream
+}
+
+func (x *storageBootstrapServer) Send(m *BootstrapMessage) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _Storage_StreamOperations_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(StreamRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -218,6 +1215,95 @@ func (x *storageStreamOperationsServer) Send(m *Operation) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Storage_LoadOperations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(StorageServer).LoadOperations(&storageLoadOperationsServer{stream})
+}
+
+type Storage_LoadOperationsServer interface {
+	Send(*OperationAck) error
+	Recv() (*Operation, error)
+	grpc.ServerStream
+}
+
+type storageLoadOperationsServer struct {
+	grpc.ServerStream
+}
+
+func (x *storageLoadOperationsServer) Send(m *OperationAck) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *storageLoadOperationsServer) Recv() (*Operation, error) {
+	m := new(Operation)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Storage_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageServer).Watch(m, &storageWatchServer{stream})
+}
+
+type Storage_WatchServer interface {
+	Send(*WatchEvent) error
+	grpc.ServerStream
+}
+
+type storageWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *storageWatchServer) Send(m *WatchEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Storage_Backup_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BackupRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageServer).Backup(m, &storageBackupServer{stream})
+}
+
+type Storage_BackupServer interface {
+	Send(*BackupChunk) error
+	grpc.ServerStream
+}
+
+type storageBackupServer struct {
+	grpc.ServerStream
+}
+
+func (x *storageBackupServer) Send(m *BackupChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Storage_ScanRanges_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScanRangesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageServer).ScanRanges(m, &storageScanRangesServer{stream})
+}
+
+type Storage_ScanRangesServer interface {
+	Send(*KeyValue) error
+	grpc.ServerStream
+}
+
+type storageScanRangesServer struct {
+	grpc.ServerStream
+}
+
+func (x *storageScanRangesServer) Send(m *KeyValue) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // Storage_ServiceDesc is the grpc.ServiceDesc for Storage service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -237,13 +1323,122 @@ var Storage_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Delete",
 			Handler:    _Storage_Delete_Handler,
 		},
+		{
+			MethodName: "DeleteIf",
+			Handler:    _Storage_DeleteIf_Handler,
+		},
+		{
+			MethodName: "CompareAndSwap",
+			Handler:    _Storage_CompareAndSwap_Handler,
+		},
+		{
+			MethodName: "Increment",
+			Handler:    _Storage_Increment_Handler,
+		},
+		{
+			MethodName: "BatchPut",
+			Handler:    _Storage_BatchPut_Handler,
+		},
+		{
+			MethodName: "WriteBatch",
+			Handler:    _Storage_WriteBatch_Handler,
+		},
+		{
+			MethodName: "Batch",
+			Handler:    _Storage_Batch_Handler,
+		},
+		{
+			MethodName: "Tail",
+			Handler:    _Storage_Tail_Handler,
+		},
+		{
+			MethodName: "Fingerprint",
+			Handler:    _Storage_Fingerprint_Handler,
+		},
+		{
+			MethodName: "SplitRanges",
+			Handler:    _Storage_SplitRanges_Handler,
+		},
+		{
+			MethodName: "RangeHashes",
+			Handler:    _Storage_RangeHashes_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _Storage_Stats_Handler,
+		},
+		{
+			MethodName: "Metrics",
+			Handler:    _Storage_Metrics_Handler,
+		},
+		{
+			MethodName: "Size",
+			Handler:    _Storage_Size_Handler,
+		},
+		{
+			MethodName: "Barrier",
+			Handler:    _Storage_Barrier_Handler,
+		},
+		{
+			MethodName: "ClusterInfo",
+			Handler:    _Storage_ClusterInfo_Handler,
+		},
+		{
+			MethodName: "Capabilities",
+			Handler:    _Storage_Capabilities_Handler,
+		},
+		{
+			MethodName: "Health",
+			Handler:    _Storage_Health_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Scan",
+			Handler:       _Storage_Scan_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ScanPrefix",
+			Handler:       _Storage_ScanPrefix_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Keys",
+			Handler:       _Storage_Keys_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Bootstrap",
+			Handler:       _Storage_Bootstrap_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "StreamOperations",
 			Handler:       _Storage_StreamOperations_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "LoadOperations",
+			Handler:       _Storage_LoadOperations_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _Storage_Watch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Backup",
+			Handler:       _Storage_Backup_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ScanRanges",
+			Handler:       _Storage_ScanRanges_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "internal/rpc/proto/storage.proto",
 }
@@ -1,27 +1,125 @@
 package rpc
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"sync"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
 	"godatabase/internal/rpc/proto"
 	"godatabase/internal/storage"
 )
 
+// clusterMember is implemented by storage backends that are part of a
+// replicated cluster, such as raft.RaftStorage.
+type clusterMember interface {
+	NodeID() string
+	IsLeader() bool
+	LeaderID() (string, error)
+}
+
+// leaderLocator is implemented by cluster members that know where clients
+// can reach the leader, such as raft.RaftStorage
+type leaderLocator interface {
+	GetLeaderAddress() (string, error)
+}
+
+// staleReader is implemented by storage backends that may serve reads from
+// possibly stale state, such as raft.RaftStorage during an election
+type staleReader interface {
+	GetWithStaleness(key []byte) ([]byte, bool, error)
+}
+
+// defaultMaxMessageSize is gRPC's default limit on received messages
+const defaultMaxMessageSize = 4 * 1024 * 1024
+
+// stopTimeout bounds how long Stop waits for in-flight requests before
+// closing their connections
+const stopTimeout = 5 * time.Second
+
+// errServerStopping ends streams that are still open when the server stops
+var errServerStopping = errors.New("server is stopping")
+
+// ServerOption configures a Server
+type ServerOption func(*Server)
+
+// WithMaxMessageSize limits the size in bytes of messages the server
+// receives and sends. Clients learn the limit through ClusterInfo.
+func WithMaxMessageSize(bytes int) ServerOption {
+	return func(s *Server) {
+		s.maxMsgSize = bytes
+	}
+}
+
 type Server struct {
 	proto.UnimplementedStorageServer
-	storage storage.Storage
-	server  *grpc.Server
+	storage    storage.Storage
+	server     *grpc.Server
+	maxMsgSize int
+
+	// Every write is published to the feed. Over a versionedStorage the
+	// feed takes versions from the storage; over other storage it
+	// serializes writes made through the server so each gets a version in
+	// the order it was applied.
+	feed *changeFeed
+
+	// The storage, if it versions its own writes and the feed follows it
+	versioned versionedStorage
+
+	// Latency histograms of unary calls
+	latency *latencyRecorder
+
+	// Bearer token clients must present, empty for no authentication
+	token string
+
+	// Closed by Stop to end Watch and StreamOperations streams, which
+	// never finish on their own
+	stopping chan struct{}
+	stopOnce sync.Once
 }
 
-func NewServer(storage storage.Storage) *Server {
-	return &Server{
-		storage: storage,
-		server:  grpc.NewServer(),
+func NewServer(storage storage.Storage, opts ...ServerOption) *Server {
+	s := &Server{
+		storage:    storage,
+		maxMsgSize: defaultMaxMessageSize,
+		feed:       newChangeFeed(),
+		latency:    newLatencyRecorder(),
+		stopping:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	if vs, ok := storage.(versionedStorage); ok {
+		if err := s.feed.follow(vs); err != nil {
+			log.Printf("Change feed falls back to versioning writes made through this server: %v", err)
+		} else {
+			s.versioned = vs
+		}
+	}
+
+	serverOpts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(s.maxMsgSize),
+		grpc.MaxSendMsgSize(s.maxMsgSize),
+	}
+	if s.token != "" {
+		serverOpts = append(serverOpts,
+			grpc.ChainUnaryInterceptor(s.authUnary),
+			grpc.ChainStreamInterceptor(s.authStream),
+		)
+	}
+	serverOpts = append(serverOpts, grpc.ChainUnaryInterceptor(s.latency.intercept))
+	s.server = grpc.NewServer(serverOpts...)
+	return s
 }
 
 func (s *Server) Start(addr string) error {
@@ -35,15 +133,46 @@ func (s *Server) Start(addr string) error {
 	return s.server.Serve(lis)
 }
 
+// GRPCServer returns the underlying gRPC server, so other services, such
+// as Raft, can share its listener. They must be registered before Start.
+func (s *Server) GRPCServer() *grpc.Server {
+	return s.server
+}
+
+// Stop stops the server. Watch and StreamOperations streams are ended
+// first, since they would otherwise keep a graceful stop waiting forever;
+// other in-flight requests get up to stopTimeout to finish before their
+// connections are closed.
 func (s *Server) Stop() {
-	if s.server != nil {
+	if s.server == nil {
+		return
+	}
+	s.stopOnce.Do(func() { close(s.stopping) })
+
+	stopped := make(chan struct{})
+	go func() {
 		s.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(stopTimeout):
+		s.server.Stop()
+		<-stopped
 	}
 }
 
 // Put implements the Put RPC method
 func (s *Server) Put(ctx context.Context, req *proto.PutRequest) (*proto.PutResponse, error) {
-	err := s.storage.Put(req.Key, req.Value)
+	w := s.feed.begin()
+	defer w.end()
+
+	var err error
+	if req.TtlMs > 0 {
+		err = s.storage.PutWithTTL(req.Key, req.Value, time.Duration(req.TtlMs)*time.Millisecond)
+	} else {
+		err = s.storage.Put(req.Key, req.Value)
+	}
 	if err != nil {
 		return &proto.PutResponse{
 			Success: false,
@@ -51,30 +180,52 @@ func (s *Server) Put(ctx context.Context, req *proto.PutRequest) (*proto.PutResp
 		}, nil
 	}
 
+	w.publish(proto.Operation_PUT, req.Key, req.Value)
 	return &proto.PutResponse{
 		Success: true,
 	}, nil
 }
 
-// Get implements the Get RPC method
+// Get implements the Get RPC method. A missing key is answered with Found
+// false; any other failure is returned as an error.
 func (s *Server) Get(ctx context.Context, req *proto.GetRequest) (*proto.GetResponse, error) {
-	value, err := s.storage.Get(req.Key)
-	if err != nil {
+	var value []byte
+	var stale bool
+	var err error
+	if reader, ok := s.storage.(staleReader); ok {
+		value, stale, err = reader.GetWithStaleness(req.Key)
+	} else {
+		value, err = s.storage.Get(req.Key)
+	}
+	if errors.Is(err, storage.ErrKeyNotFound) {
 		return &proto.GetResponse{
 			Found: false,
 			Error: err.Error(),
 		}, nil
 	}
+	if err != nil {
+		// Found is false only for missing keys, so clients can tell a
+		// missing key from a failed read
+		return nil, status.Error(codes.Internal, err.Error())
+	}
 
 	return &proto.GetResponse{
 		Value: value,
 		Found: true,
+		Stale: stale,
 	}, nil
 }
 
-// Delete implements the Delete RPC method
+// Delete implements the Delete RPC method. Deleting a missing key fails
+// with codes.NotFound.
 func (s *Server) Delete(ctx context.Context, req *proto.DeleteRequest) (*proto.DeleteResponse, error) {
+	w := s.feed.begin()
+	defer w.end()
+
 	err := s.storage.Delete(req.Key)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
 	if err != nil {
 		return &proto.DeleteResponse{
 			Success: false,
@@ -82,14 +233,730 @@ func (s *Server) Delete(ctx context.Context, req *proto.DeleteRequest) (*proto.D
 		}, nil
 	}
 
+	w.publish(proto.Operation_DELETE, req.Key, nil)
 	return &proto.DeleteResponse{
 		Success: true,
 	}, nil
 }
 
-// StreamOperations implements the StreamOperations RPC method
+// DeleteIf implements the DeleteIf RPC method
+func (s *Server) DeleteIf(ctx context.Context, req *proto.DeleteIfRequest) (*proto.DeleteIfResponse, error) {
+	w := s.feed.begin()
+	defer w.end()
+
+	deleted, err := s.storage.DeleteIf(req.Key, req.Expected)
+	if err != nil {
+		return &proto.DeleteIfResponse{
+			Error: err.Error(),
+		}, nil
+	}
+
+	if deleted {
+		w.publish(proto.Operation_DELETE, req.Key, nil)
+	}
+	return &proto.DeleteIfResponse{
+		Deleted: deleted,
+	}, nil
+}
+
+// CompareAndSwap implements the CompareAndSwap RPC method
+func (s *Server) CompareAndSwap(ctx context.Context, req *proto.CompareAndSwapRequest) (*proto.CompareAndSwapResponse, error) {
+	w := s.feed.begin()
+	defer w.end()
+
+	// The storage tells an absent key from an empty value by nil
+	old := req.Old
+	if req.Absent {
+		old = nil
+	} else if old == nil {
+		old = []byte{}
+	}
+
+	swapped, err := s.storage.CompareAndSwap(req.Key, old, req.New)
+	if err != nil {
+		return &proto.CompareAndSwapResponse{
+			Error: err.Error(),
+		}, nil
+	}
+
+	if swapped {
+		w.publish(proto.Operation_PUT, req.Key, req.New)
+	}
+	return &proto.CompareAndSwapResponse{
+		Swapped: swapped,
+	}, nil
+}
+
+// Increment implements the Increment RPC method
+func (s *Server) Increment(ctx context.Context, req *proto.IncrementRequest) (*proto.IncrementResponse, error) {
+	w := s.feed.begin()
+	defer w.end()
+
+	n, err := s.storage.Increment(req.Key, req.Delta)
+	if err != nil {
+		return &proto.IncrementResponse{
+			Error: err.Error(),
+		}, nil
+	}
+
+	w.publish(proto.Operation_PUT, req.Key, storage.EncodeCounter(n))
+	return &proto.IncrementResponse{
+		Value: n,
+	}, nil
+}
+
+// BatchPut implements the BatchPut RPC method.
+// Pairs are applied in order and the call stops at the first failure.
+func (s *Server) BatchPut(ctx context.Context, req *proto.BatchPutRequest) (*proto.BatchPutResponse, error) {
+	w := s.feed.begin()
+	defer w.end()
+
+	for _, kv := range req.Pairs {
+		if err := s.storage.Put(kv.Key, kv.Value); err != nil {
+			return &proto.BatchPutResponse{
+				Success: false,
+				Error:   err.Error(),
+			}, nil
+		}
+		w.publish(proto.Operation_PUT, kv.Key, kv.Value)
+	}
+
+	return &proto.BatchPutResponse{
+		Success: true,
+	}, nil
+}
+
+// WriteBatch implements the WriteBatch RPC method.
+// The operations are committed as one storage batch and published to the
+// change feed only once the batch has committed.
+func (s *Server) WriteBatch(ctx context.Context, req *proto.WriteBatchRequest) (*proto.WriteBatchResponse, error) {
+	w := s.feed.begin()
+	defer w.end()
+
+	batch := s.storage.NewBatch()
+	for _, op := range req.Ops {
+		if op.Type == proto.Operation_DELETE {
+			batch.Delete(op.Key)
+		} else {
+			batch.Put(op.Key, op.Value)
+		}
+	}
+	if err := batch.Commit(); err != nil {
+		return &proto.WriteBatchResponse{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+
+	for _, op := range req.Ops {
+		w.publish(op.Type, op.Key, op.Value)
+	}
+	return &proto.WriteBatchResponse{
+		Success: true,
+	}, nil
+}
+
+// Batch implements the Batch RPC method.
+// A batch of only puts and deletes is committed as one storage batch, so
+// it is applied in full or not at all. A batch with gets is applied one
+// operation at a time, in order, and a failed operation is reported in its
+// result without stopping the rest.
+func (s *Server) Batch(ctx context.Context, req *proto.BatchRequest) (*proto.BatchResponse, error) {
+	w := s.feed.begin()
+	defer w.end()
+
+	results := make([]*proto.BatchResult, len(req.Ops))
+	for i := range results {
+		results[i] = &proto.BatchResult{}
+	}
+
+	writesOnly := true
+	for _, op := range req.Ops {
+		if op.Type != proto.BatchOp_PUT && op.Type != proto.BatchOp_DELETE {
+			writesOnly = false
+			break
+		}
+	}
+
+	if writesOnly {
+		batch := s.storage.NewBatch()
+		for _, op := range req.Ops {
+			if op.Type == proto.BatchOp_DELETE {
+				batch.Delete(op.Key)
+			} else {
+				batch.Put(op.Key, op.Value)
+			}
+		}
+		if err := batch.Commit(); err != nil {
+			return &proto.BatchResponse{
+				Error: err.Error(),
+			}, nil
+		}
+		for _, op := range req.Ops {
+			s.publishBatchOp(w, op)
+		}
+		return &proto.BatchResponse{
+			Results: results,
+		}, nil
+	}
+
+	for i, op := range req.Ops {
+		var err error
+		switch op.Type {
+		case proto.BatchOp_GET:
+			var value []byte
+			value, err = s.storage.Get(op.Key)
+			if err == nil {
+				results[i].Value, results[i].Found = value, true
+			} else if errors.Is(err, storage.ErrKeyNotFound) {
+				err = nil
+			}
+		case proto.BatchOp_PUT:
+			err = s.storage.Put(op.Key, op.Value)
+		case proto.BatchOp_DELETE:
+			if err = s.storage.Delete(op.Key); errors.Is(err, storage.ErrKeyNotFound) {
+				err = nil
+			}
+		default:
+			err = fmt.Errorf("unknown operation type %v", op.Type)
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		s.publishBatchOp(w, op)
+	}
+
+	return &proto.BatchResponse{
+		Results: results,
+	}, nil
+}
+
+// publishBatchOp publishes an applied batch write to the change feed
+func (s *Server) publishBatchOp(w *feedWrite, op *proto.BatchOp) {
+	switch op.Type {
+	case proto.BatchOp_PUT:
+		w.publish(proto.Operation_PUT, op.Key, op.Value)
+	case proto.BatchOp_DELETE:
+		w.publish(proto.Operation_DELETE, op.Key, nil)
+	}
+}
+
+// Tail implements the Tail RPC method
+func (s *Server) Tail(ctx context.Context, req *proto.TailRequest) (*proto.TailResponse, error) {
+	pairs, err := s.storage.Tail(int(req.Limit))
+	if err != nil {
+		return &proto.TailResponse{
+			Error: err.Error(),
+		}, nil
+	}
+
+	resp := &proto.TailResponse{
+		Pairs: make([]*proto.KeyValue, 0, len(pairs)),
+	}
+	for _, kv := range pairs {
+		resp.Pairs = append(resp.Pairs, &proto.KeyValue{Key: kv.Key, Value: kv.Value})
+	}
+	return resp, nil
+}
+
+// Fingerprint implements the Fingerprint RPC method
+func (s *Server) Fingerprint(ctx context.Context, req *proto.FingerprintRequest) (*proto.FingerprintResponse, error) {
+	fingerprint, err := s.storage.Fingerprint()
+	if err != nil {
+		return &proto.FingerprintResponse{
+			Error: err.Error(),
+		}, nil
+	}
+
+	return &proto.FingerprintResponse{
+		Fingerprint: fingerprint,
+	}, nil
+}
+
+// Stats implements the Stats RPC method
+func (s *Server) Stats(ctx context.Context, req *proto.StatsRequest) (*proto.StatsResponse, error) {
+	stats, err := s.storage.Stats()
+	if err != nil {
+		return &proto.StatsResponse{
+			Error: err.Error(),
+		}, nil
+	}
+
+	return &proto.StatsResponse{
+		KeyCount:    stats.KeyCount,
+		DiskBytes:   stats.DiskBytes,
+		Backend:     stats.Backend,
+		Compression: stats.Compression,
+	}, nil
+}
+
+// Size implements the Size RPC method. Storage that can't count its keys
+// reports a negative size, which is returned as an error.
+func (s *Server) Size(ctx context.Context, req *proto.SizeRequest) (*proto.SizeResponse, error) {
+	size := s.storage.Size()
+	if size < 0 {
+		return &proto.SizeResponse{
+			Error: "size unavailable",
+		}, nil
+	}
+
+	return &proto.SizeResponse{
+		Count: int64(size),
+	}, nil
+}
+
+// SplitRanges implements the SplitRanges RPC method
+func (s *Server) SplitRanges(ctx context.Context, req *proto.SplitRangesRequest) (*proto.SplitRangesResponse, error) {
+	ranges, err := s.storage.SplitRanges(int(req.Count))
+	if err != nil {
+		return &proto.SplitRangesResponse{
+			Error: err.Error(),
+		}, nil
+	}
+
+	resp := &proto.SplitRangesResponse{
+		Boundaries: make([][]byte, 0, len(ranges)-1),
+	}
+	for _, r := range ranges[1:] {
+		resp.Boundaries = append(resp.Boundaries, r.Start)
+	}
+	return resp, nil
+}
+
+// RangeHashes implements the RangeHashes RPC method
+func (s *Server) RangeHashes(ctx context.Context, req *proto.RangeHashesRequest) (*proto.RangeHashesResponse, error) {
+	hashes, err := storage.RangeHashes(s.storage, int(req.Depth))
+	if err != nil {
+		return &proto.RangeHashesResponse{
+			Error: err.Error(),
+		}, nil
+	}
+
+	return &proto.RangeHashesResponse{
+		Hashes: hashes,
+	}, nil
+}
+
+// ScanRanges implements the ScanRanges RPC method, streaming pairs like
+// Scan
+func (s *Server) ScanRanges(req *proto.ScanRangesRequest, stream proto.Storage_ScanRangesServer) error {
+	ranges := make([]int, len(req.Ranges))
+	for i, r := range req.Ranges {
+		ranges[i] = int(r)
+	}
+
+	it, err := storage.ScanRanges(s.storage, int(req.Depth), ranges)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to scan: %v", err)
+	}
+	return sendPairs(it, stream)
+}
+
+// Scan implements the Scan RPC method.
+// Pairs are sent as the storage iterator produces them, so a large range
+// is never held in memory at once.
+func (s *Server) Scan(req *proto.ScanRequest, stream proto.Storage_ScanServer) error {
+	var end []byte
+	if len(req.End) > 0 {
+		end = req.End
+	}
+
+	var it storage.Iterator
+	var err error
+	switch {
+	case req.ConsistentPrefix:
+		it, err = s.consistentScan(req.Start, end, req.Reverse)
+	case req.Reverse:
+		it, err = s.storage.ScanReverse(req.Start, end)
+	default:
+		it, err = s.storage.Scan(req.Start, end)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to scan: %v", err)
+	}
+	if req.Limit > 0 {
+		it = &limitIterator{Iterator: it, left: int(req.Limit)}
+	}
+	return sendPairs(it, stream)
+}
+
+// consistentScan scans [start, end) with storage.ScanConsistentPrefix.
+// Storage has no descending form of it, so a reverse scan reads the range
+// into memory and reverses it.
+func (s *Server) consistentScan(start, end []byte, reverse bool) (storage.Iterator, error) {
+	it, err := s.storage.ScanWithConsistency(start, end, storage.ScanConsistentPrefix)
+	if err != nil || !reverse {
+		return it, err
+	}
+	pairs, err := storage.Collect(it)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(pairs)-1; i < j; i, j = i+1, j-1 {
+		pairs[i], pairs[j] = pairs[j], pairs[i]
+	}
+	return storage.NewSliceIterator(pairs), nil
+}
+
+// limitIterator ends an iterator after left pairs
+type limitIterator struct {
+	storage.Iterator
+	left int
+}
+
+func (it *limitIterator) Next() bool {
+	if it.left <= 0 {
+		return false
+	}
+	it.left--
+	return it.Iterator.Next()
+}
+
+// ScanPrefix implements the ScanPrefix RPC method, streaming pairs like Scan
+func (s *Server) ScanPrefix(req *proto.ScanPrefixRequest, stream proto.Storage_ScanPrefixServer) error {
+	it, err := s.storage.ScanPrefix(req.Prefix)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to scan: %v", err)
+	}
+	return sendPairs(it, stream)
+}
+
+// Keys implements the Keys RPC method
+func (s *Server) Keys(req *proto.KeysRequest, stream proto.Storage_KeysServer) error {
+	keys, err := s.storage.Keys()
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to list keys: %v", err)
+	}
+	for _, key := range keys {
+		if err := stream.Send(&proto.Key{Key: key}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pairSender is a stream of key-value pairs, such as a Scan stream
+type pairSender interface {
+	Send(*proto.KeyValue) error
+	Context() context.Context
+}
+
+// sendPairs sends every pair from it on stream and closes it. It stops
+// early if the client cancels the stream.
+func sendPairs(it storage.Iterator, stream pairSender) error {
+	ctx := stream.Context()
+	for it.Next() {
+		if err := ctx.Err(); err != nil {
+			it.Close()
+			return status.FromContextError(err).Err()
+		}
+		if err := stream.Send(&proto.KeyValue{Key: it.Key(), Value: it.Value()}); err != nil {
+			it.Close()
+			return err
+		}
+	}
+	if err := it.Close(); err != nil {
+		return status.Errorf(codes.Internal, "scan failed: %v", err)
+	}
+	return nil
+}
+
+// Barrier implements the Barrier RPC method. It waits for in-flight writes
+// to finish and then syncs the storage, so every write acknowledged
+// before the barrier is durable when it returns. A versionedStorage's Sync
+// waits for them itself.
+func (s *Server) Barrier(ctx context.Context, req *proto.BarrierRequest) (*proto.BarrierResponse, error) {
+	var err error
+	if s.versioned != nil {
+		err = s.storage.Sync()
+	} else {
+		_, err = s.feed.between(s.storage.Sync)
+	}
+	if err != nil {
+		return &proto.BarrierResponse{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+
+	return &proto.BarrierResponse{
+		Success: true,
+	}, nil
+}
+
+// ClusterInfo implements the ClusterInfo RPC method.
+// A server over standalone storage reports itself as the leader.
+func (s *Server) ClusterInfo(ctx context.Context, req *proto.ClusterInfoRequest) (*proto.ClusterInfoResponse, error) {
+	member, ok := s.storage.(clusterMember)
+	if !ok {
+		return &proto.ClusterInfoResponse{
+			IsLeader:       true,
+			MaxMessageSize: int32(s.maxMsgSize),
+		}, nil
+	}
+
+	// An unknown leader (e.g. mid-election) is reported as an empty ID
+	leaderID, _ := member.LeaderID()
+	var leaderAddress string
+	if locator, ok := s.storage.(leaderLocator); ok {
+		leaderAddress, _ = locator.GetLeaderAddress()
+	}
+	return &proto.ClusterInfoResponse{
+		NodeId:         member.NodeID(),
+		IsLeader:       member.IsLeader(),
+		LeaderId:       leaderID,
+		MaxMessageSize: int32(s.maxMsgSize),
+		LeaderAddress:  leaderAddress,
+	}, nil
+}
+
+// Bootstrap implements the Bootstrap RPC method.
+// The snapshot is a point-in-time scan that reflects exactly the
+// operations up to the version sent in the final message; see snapshot.
+func (s *Server) Bootstrap(req *proto.BootstrapRequest, stream proto.Storage_BootstrapServer) error {
+	it, version, err := s.snapshot()
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to read snapshot: %v", err)
+	}
+
+	for it.Next() {
+		msg := &proto.BootstrapMessage{
+			Pair: &proto.KeyValue{Key: it.Key(), Value: it.Value()},
+		}
+		if err := stream.Send(msg); err != nil {
+			it.Close()
+			return err
+		}
+	}
+	if err := it.Close(); err != nil {
+		return status.Errorf(codes.Internal, "failed to read snapshot: %v", err)
+	}
+
+	return stream.Send(&proto.BootstrapMessage{
+		Done:    true,
+		Version: version,
+	})
+}
+
+// StreamOperations implements the StreamOperations RPC method.
+// It sends every write published to the feed after req.FromVersion, then
+// follows new writes until the client goes away.
 func (s *Server) StreamOperations(req *proto.StreamRequest, stream proto.Storage_StreamOperationsServer) error {
-	// This would be implemented for replication
-	// For now, we'll just return an error
-	return fmt.Errorf("streaming not implemented yet")
-} 
\ No newline at end of file
+	backlog, sub, err := s.feed.subscribe(req.FromVersion)
+	if err != nil {
+		return status.Error(codes.OutOfRange, err.Error())
+	}
+	defer s.feed.unsubscribe(sub)
+
+	for _, op := range backlog {
+		if err := stream.Send(op); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.stopping:
+			return status.Error(codes.Unavailable, errServerStopping.Error())
+		case <-sub.dropped:
+			return droppedError(sub)
+		case op := <-sub.ops:
+			if err := stream.Send(op); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// LoadOperations implements the LoadOperations RPC method.
+// Each operation is applied on its own and acknowledged before the next
+// is read. Deleting a missing key succeeds, so a load can be replayed.
+// A client that goes away mid-stream just ends the load; the operations
+// acknowledged so far stay applied.
+func (s *Server) LoadOperations(stream proto.Storage_LoadOperationsServer) error {
+	var sequence int64
+	for {
+		op, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if stream.Context().Err() != nil {
+				return nil
+			}
+			return err
+		}
+		sequence++
+
+		ack := &proto.OperationAck{Sequence: sequence, Success: true}
+		if err := s.applyOperation(op); err != nil {
+			ack.Success = false
+			ack.Error = err.Error()
+		}
+		if err := stream.Send(ack); err != nil {
+			if stream.Context().Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// applyOperation applies a single streamed put or delete and publishes it
+func (s *Server) applyOperation(op *proto.Operation) error {
+	w := s.feed.begin()
+	defer w.end()
+
+	var err error
+	switch op.Type {
+	case proto.Operation_PUT:
+		err = s.storage.Put(op.Key, op.Value)
+	case proto.Operation_DELETE:
+		if err = s.storage.Delete(op.Key); errors.Is(err, storage.ErrKeyNotFound) {
+			err = nil
+		}
+	default:
+		err = fmt.Errorf("unknown operation type %v", op.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	w.publish(op.Type, op.Key, op.Value)
+	return nil
+}
+
+// Watch implements the Watch RPC method.
+// The key's current value is read along with the feed version it
+// reflects, and the watch continues with the writes to the key after that
+// version, so the first event and the ones after it miss no write.
+func (s *Server) Watch(req *proto.WatchRequest, stream proto.Storage_WatchServer) error {
+	value, version, err := s.versionedGet(req.Key)
+	// Found is false only for a missing key; a failed read ends the watch
+	// rather than passing for one
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return status.Error(codes.Internal, err.Error())
+	}
+	backlog, sub, subErr := s.feed.subscribe(version)
+	if subErr != nil {
+		return status.Error(codes.Internal, subErr.Error())
+	}
+	defer s.feed.unsubscribe(sub)
+
+	first := &proto.WatchEvent{Version: version}
+	if err == nil {
+		first.Value, first.Found = value, true
+	}
+	if err := stream.Send(first); err != nil {
+		return err
+	}
+
+	send := func(op *proto.Operation) error {
+		if !bytes.Equal(op.Key, req.Key) {
+			return nil
+		}
+		event := &proto.WatchEvent{Version: op.Version}
+		if op.Type == proto.Operation_PUT {
+			event.Value, event.Found = op.Value, true
+		}
+		return stream.Send(event)
+	}
+	for _, op := range backlog {
+		if err := send(op); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.stopping:
+			return status.Error(codes.Unavailable, errServerStopping.Error())
+		case <-sub.dropped:
+			return droppedError(sub)
+		case op := <-sub.ops:
+			if err := send(op); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// versionedGet reads key along with the feed version the read reflects.
+// Over storage the feed doesn't follow, the read runs between two writes.
+func (s *Server) versionedGet(key []byte) ([]byte, int64, error) {
+	if s.versioned != nil {
+		return s.versioned.VersionedGet(key)
+	}
+
+	var value []byte
+	var err error
+	version, _ := s.feed.between(func() error {
+		value, err = s.storage.Get(key)
+		return nil
+	})
+	return value, version, err
+}
+
+// snapshot opens a point-in-time scan of the whole storage along with the
+// feed version it reflects. Over storage the feed doesn't follow, the scan
+// is opened between two writes, so writes are held off while it opens but
+// not while it is read.
+func (s *Server) snapshot() (storage.Iterator, int64, error) {
+	if s.versioned != nil {
+		return s.versioned.VersionedScan(nil, nil)
+	}
+
+	var it storage.Iterator
+	version, err := s.feed.between(func() error {
+		var err error
+		it, err = s.storage.ScanWithConsistency(nil, nil, storage.ScanConsistentPrefix)
+		return err
+	})
+	return it, version, err
+}
+
+// droppedError is the error a stream ends with once the feed has dropped
+// its subscriber
+func droppedError(sub *subscriber) error {
+	if errors.Is(sub.reason, errVersionTooOld) {
+		return status.Error(codes.OutOfRange, sub.reason.Error())
+	}
+	return status.Error(codes.ResourceExhausted, errSubscriberTooSlow.Error())
+}
+
+// backupChunkSize is the most backup data sent in one BackupChunk
+const backupChunkSize = 64 * 1024
+
+// chunkSender sends what is written to it as BackupChunk messages
+type chunkSender struct {
+	stream proto.Storage_BackupServer
+}
+
+func (c chunkSender) Write(p []byte) (int, error) {
+	for sent := 0; sent < len(p); {
+		n := len(p) - sent
+		if n > backupChunkSize {
+			n = backupChunkSize
+		}
+		if err := c.stream.Send(&proto.BackupChunk{Data: p[sent : sent+n]}); err != nil {
+			return sent, err
+		}
+		sent += n
+	}
+	return len(p), nil
+}
+
+// Backup implements the Backup RPC method. The storage makes the backup
+// consistent, so writes are not held off while it streams.
+func (s *Server) Backup(req *proto.BackupRequest, stream proto.Storage_BackupServer) error {
+	w := bufio.NewWriterSize(chunkSender{stream: stream}, backupChunkSize)
+	if err := s.storage.Backup(w); err != nil {
+		return status.Errorf(codes.Internal, "backup failed: %v", err)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// backupMagic starts every backup. The byte after it names the format of
+// the rest of the backup.
+const backupMagic = "GDBK"
+
+const (
+	// backupEngine is a StorageEngine database file
+	backupEngine byte = 1
+
+	// backupBadger is a stream written by badger.DB.Backup
+	backupBadger byte = 2
+
+	// backupPairs is a sequence of key-value pairs, each preceded by a 1
+	// byte and its key and value by their uvarint lengths, then a 0 byte.
+	// Storages without a file format of their own write it.
+	backupPairs byte = 3
+)
+
+// maxBackupField bounds the length of a key or value read from a pairs
+// backup, so a corrupt length can't exhaust memory
+const maxBackupField = 1 << 30
+
+// ErrInvalidBackup is returned by Restore for data that isn't a backup
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// writeBackupHeader starts a backup in the given format
+func writeBackupHeader(w io.Writer, format byte) error {
+	_, err := w.Write(append([]byte(backupMagic), format))
+	return err
+}
+
+// writeBackupPairs writes a backupPairs backup of every pair from it and
+// closes it
+func writeBackupPairs(w io.Writer, it Iterator) error {
+	// Write errors stick to bw and are returned by Flush
+	bw := bufio.NewWriter(w)
+	writeBackupHeader(bw, backupPairs)
+	var buf [binary.MaxVarintLen64]byte
+	for it.Next() {
+		bw.WriteByte(1)
+		for _, field := range [][]byte{it.Key(), it.Value()} {
+			bw.Write(buf[:binary.PutUvarint(buf[:], uint64(len(field)))])
+			bw.Write(field)
+		}
+	}
+	if err := it.Close(); err != nil {
+		return err
+	}
+	bw.WriteByte(0)
+	return bw.Flush()
+}
+
+// Restore creates a storage at path from a backup written by Backup. A
+// B+Tree engine backup is restored as a database file and a Badger backup
+// as a Badger directory, each to be opened with the matching constructor.
+// Pairs backups, written by TieredStorage and MemStorage, are restored as
+// a B+Tree engine database file. Restore refuses to overwrite an existing
+// path.
+//
+// Parameters:
+//   - r: The backup
+//   - path: Where to create the restored storage
+//
+// Returns:
+//   - An error if the backup is invalid, path exists, or writing fails
+func Restore(r io.Reader, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("restore target %s already exists", path)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	header := make([]byte, len(backupMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if string(header[:len(backupMagic)]) != backupMagic {
+		return fmt.Errorf("%w: bad magic", ErrInvalidBackup)
+	}
+
+	switch header[len(backupMagic)] {
+	case backupEngine:
+		return restoreFile(r, path)
+	case backupBadger:
+		return restoreBadger(r, path)
+	case backupPairs:
+		return restorePairs(bufio.NewReader(r), path)
+	default:
+		return fmt.Errorf("%w: unknown format %d", ErrInvalidBackup, header[len(backupMagic)])
+	}
+}
+
+// restoreFile copies a database file from r to path
+func restoreFile(r io.Reader, path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to restore database file: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// restoreBadger loads a Badger backup stream into a new Badger directory
+func restoreBadger(r io.Reader, path string) error {
+	s, err := NewBadgerStorage(path)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Load(r, 256); err != nil {
+		s.Close()
+		return fmt.Errorf("failed to restore badger backup: %v", err)
+	}
+	return s.Close()
+}
+
+// restorePairs writes the pairs of a backupPairs backup to a new B+Tree
+// engine database file
+func restorePairs(r *bufio.Reader, path string) error {
+	engine, err := NewStorageEngine(path)
+	if err != nil {
+		return err
+	}
+	// Close writes the whole tree once at the end
+	engine.SetAutoFlush(false)
+
+	readField := func() ([]byte, error) {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if n > maxBackupField {
+			return nil, fmt.Errorf("field of %d bytes", n)
+		}
+		field := make([]byte, n)
+		_, err = io.ReadFull(r, field)
+		return field, err
+	}
+	for {
+		more, err := r.ReadByte()
+		if err != nil {
+			engine.Close()
+			return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		if more == 0 {
+			break
+		}
+		key, err := readField()
+		if err != nil {
+			engine.Close()
+			return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		value, err := readField()
+		if err != nil {
+			engine.Close()
+			return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		if err := engine.Put(key, value); err != nil {
+			engine.Close()
+			return err
+		}
+	}
+	return engine.Close()
+}
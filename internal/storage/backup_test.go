@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// fillAndBackup writes n keys to s and returns a backup of it
+func fillAndBackup(t *testing.T, s Storage, n int) *bytes.Buffer {
+	for i := 0; i < n; i++ {
+		if err := s.Put([]byte(fmt.Sprintf("key%04d", i)), []byte(fmt.Sprintf("value%04d", i))); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := s.Backup(&buf); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	return &buf
+}
+
+// checkRestored verifies that s holds exactly the n keys fillAndBackup wrote
+func checkRestored(t *testing.T, s Storage, n int) {
+	t.Helper()
+	if s.Size() != n {
+		t.Errorf("Expected %d keys, got %d", n, s.Size())
+	}
+	for i := 0; i < n; i++ {
+		value, err := s.Get([]byte(fmt.Sprintf("key%04d", i)))
+		if err != nil || string(value) != fmt.Sprintf("value%04d", i) {
+			t.Fatalf("Expected value%04d, got %s (%v)", i, value, err)
+		}
+	}
+}
+
+func TestStorageEngine_BackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	engine, err := NewStorageEngine(filepath.Join(dir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+
+	// Writes that haven't been flushed are backed up too
+	engine.SetAutoFlush(false)
+	backup := fillAndBackup(t, engine, 500)
+
+	// Writes after the backup aren't in it
+	if err := engine.Put([]byte("later"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "restored.db")
+	if err := Restore(backup, path); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	restored, err := NewStorageEngine(path)
+	if err != nil {
+		t.Fatalf("Failed to open restored engine: %v", err)
+	}
+	defer restored.Close()
+	checkRestored(t, restored, 500)
+}
+
+func TestBadgerStorage_BackupRestore(t *testing.T) {
+	store, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	backup := fillAndBackup(t, store, 500)
+
+	path := filepath.Join(t.TempDir(), "restored")
+	if err := Restore(backup, path); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	restored, err := NewBadgerStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to open restored badger: %v", err)
+	}
+	defer restored.Close()
+	checkRestored(t, restored, 500)
+}
+
+func TestTieredStorage_BackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	hot, err := NewStorageEngine(filepath.Join(dir, "hot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cold, err := NewBadgerStorage(filepath.Join(dir, "cold"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiered, err := NewTieredStorage(hot, cold, TieredConfig{MaxHotKeys: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tiered.Close()
+
+	for i := 0; i < 300; i++ {
+		if err := tiered.Put([]byte(fmt.Sprintf("key%04d", i)), []byte(fmt.Sprintf("value%04d", i))); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	// The backup spans both tiers
+	if tiered.Demote() == 0 {
+		t.Fatal("Expected keys to be demoted")
+	}
+	var backup bytes.Buffer
+	if err := tiered.Backup(&backup); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	path := filepath.Join(dir, "restored.db")
+	if err := Restore(&backup, path); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	restored, err := NewStorageEngine(path)
+	if err != nil {
+		t.Fatalf("Failed to open restored engine: %v", err)
+	}
+	defer restored.Close()
+	checkRestored(t, restored, 300)
+}
+
+func TestRestore_RejectsBadInput(t *testing.T) {
+	dir := t.TempDir()
+	if err := Restore(bytes.NewReader([]byte("not a backup")), filepath.Join(dir, "a")); !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("Expected ErrInvalidBackup, got %v", err)
+	}
+
+	engine, err := NewStorageEngine(filepath.Join(dir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	backup := fillAndBackup(t, engine, 10)
+
+	// An existing path is never overwritten
+	if err := Restore(bytes.NewReader(backup.Bytes()), filepath.Join(dir, "data.db")); err == nil {
+		t.Error("Expected Restore over an existing file to fail")
+	}
+	if value, err := engine.Get([]byte("key0003")); err != nil || string(value) != "value0003" {
+		t.Errorf("Expected the existing database to be untouched, got %s (%v)", value, err)
+	}
+}
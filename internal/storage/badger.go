@@ -1,7 +1,18 @@
 package storage
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
 	"github.com/dgraph-io/badger/v3"
+	"github.com/dgraph-io/badger/v3/options"
 )
 
 // BadgerStorage implements the Storage interface using BadgerDB.
@@ -9,7 +20,45 @@ import (
 // It's designed with a single point in mind: to provide a simple, 
 // efficient, and embeddable key-value store for Go projects.
 type BadgerStorage struct {
-	db *badger.DB // The underlying BadgerDB instance
+	db          *badger.DB        // The underlying BadgerDB instance
+	compression BadgerCompression // The codec new tables are compressed with
+}
+
+// BadgerCompression names a codec BadgerDB compresses its tables with
+type BadgerCompression string
+
+const (
+	BadgerCompressionNone   BadgerCompression = "none"
+	BadgerCompressionSnappy BadgerCompression = "snappy"
+	BadgerCompressionZSTD   BadgerCompression = "zstd"
+)
+
+// compressionFile records, in a BadgerDB directory, the codec the
+// database was last opened with
+const compressionFile = "GODATABASE_COMPRESSION"
+
+// BadgerOptions configures a BadgerStorage
+type BadgerOptions struct {
+	// Compression is the codec BadgerDB compresses its tables with. They
+	// hold every value below BadgerDB's value threshold of 1MB, so this
+	// covers all but the largest values. Empty keeps the codec the
+	// database was last opened with, or Snappy, BadgerDB's default, for a
+	// new database. Tables written with an earlier codec stay readable
+	// after the codec changes.
+	Compression BadgerCompression
+}
+
+// badgerCompression maps a BadgerCompression to BadgerDB's option
+func badgerCompression(c BadgerCompression) (options.CompressionType, error) {
+	switch c {
+	case BadgerCompressionNone:
+		return options.None, nil
+	case BadgerCompressionSnappy:
+		return options.Snappy, nil
+	case BadgerCompressionZSTD:
+		return options.ZSTD, nil
+	}
+	return 0, fmt.Errorf("unknown compression %q", c)
 }
 
 // NewBadgerStorage creates a new BadgerDB storage instance.
@@ -23,17 +72,53 @@ type BadgerStorage struct {
 //   - A pointer to a BadgerStorage instance
 //   - An error if the database couldn't be opened
 func NewBadgerStorage(path string) (*BadgerStorage, error) {
+	return NewBadgerStorageWithOptions(path, BadgerOptions{})
+}
+
+// NewBadgerStorageWithOptions is NewBadgerStorage configured by opts. The
+// compression codec is saved in the database directory, so a later open
+// without one keeps using it.
+//
+// Parameters:
+//   - path: The directory where BadgerDB will store its data files
+//   - opts: The storage options
+//
+// Returns:
+//   - A pointer to a BadgerStorage instance
+//   - An error if the options are invalid or the database couldn't be
+//     opened
+func NewBadgerStorageWithOptions(path string, opts BadgerOptions) (*BadgerStorage, error) {
+	codec := opts.Compression
+	if codec == "" {
+		codec = BadgerCompressionSnappy
+		if saved, err := os.ReadFile(filepath.Join(path, compressionFile)); err == nil {
+			codec = BadgerCompression(bytes.TrimSpace(saved))
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	compression, err := badgerCompression(codec)
+	if err != nil {
+		return nil, err
+	}
+
 	// Configure BadgerDB options
-	opts := badger.DefaultOptions(path)
-	opts.Logger = nil // Disable Badger's default logging
+	bopts := badger.DefaultOptions(path)
+	bopts.Logger = nil // Disable Badger's default logging
+	bopts.Compression = compression
 	
 	// Open the database
-	db, err := badger.Open(opts)
+	db, err := badger.Open(bopts)
 	if err != nil {
 		return nil, err
 	}
 	
-	return &BadgerStorage{db: db}, nil
+	if err := os.WriteFile(filepath.Join(path, compressionFile), []byte(codec), 0644); err != nil {
+		db.Close()
+		return nil, err
+	}
+	
+	return &BadgerStorage{db: db, compression: codec}, nil
 }
 
 // Put implements Storage.Put by storing a key-value pair in BadgerDB.
@@ -46,11 +131,99 @@ func NewBadgerStorage(path string) (*BadgerStorage, error) {
 // Returns:
 //   - An error if the operation fails
 func (s *BadgerStorage) Put(key, value []byte) error {
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.PutCtx(context.Background(), key, value)
+}
+
+// PutCtx implements Storage.PutCtx. The write is retried while its
+// transaction conflicts with another, until it commits or ctx is done.
+//
+// Parameters:
+//   - ctx: Bounds the write and its retries
+//   - key: The key as a byte slice
+//   - value: The value as a byte slice
+//
+// Returns:
+//   - An error if the operation fails, or ctx.Err() if ctx is done first
+func (s *BadgerStorage) PutCtx(ctx context.Context, key, value []byte) error {
+	return s.updateCtx(ctx, func(txn *badger.Txn) error {
 		return txn.Set(key, value)
 	})
 }
 
+// updateCtx runs fn in a read-write transaction, running it again each
+// time the commit fails with a conflict. ctx is checked before every
+// attempt, so cancelling it stops the retries.
+func (s *BadgerStorage) updateCtx(ctx context.Context, fn func(txn *badger.Txn) error) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.db.Update(fn); err != badger.ErrConflict {
+			return err
+		}
+	}
+}
+
+// PutWithTTL implements Storage.PutWithTTL by storing a key-value pair
+// that expires after ttl.
+//
+// BadgerDB only tracks expiry in whole seconds, so the exact deadline is
+// stored in front of the value and checked on every read; the entry is
+// also given a Badger TTL a second longer, so compaction removes it once
+// it is certainly dead.
+//
+// Parameters:
+//   - key: The key as a byte slice
+//   - value: The value as a byte slice
+//   - ttl: How long the key lives
+//
+// Returns:
+//   - An error if the operation fails
+func (s *BadgerStorage) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	stored := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(stored, uint64(time.Now().Add(ttl).UnixNano()))
+	copy(stored[8:], value)
+	
+	return s.db.Update(func(txn *badger.Txn) error {
+		entry := badger.NewEntry(key, stored).WithMeta(badgerExpiring).WithTTL(ttl + time.Second)
+		return txn.SetEntry(entry)
+	})
+}
+
+// errBadgerKeyNotFound is returned for missing keys. It is ErrKeyNotFound,
+// and also matches badger.ErrKeyNotFound under errors.Is.
+var errBadgerKeyNotFound error = badgerKeyNotFound{}
+
+// badgerKeyNotFound is the type of errBadgerKeyNotFound
+type badgerKeyNotFound struct{}
+
+func (badgerKeyNotFound) Error() string {
+	return ErrKeyNotFound.Error()
+}
+
+func (badgerKeyNotFound) Unwrap() []error {
+	return []error{ErrKeyNotFound, badger.ErrKeyNotFound}
+}
+
+// badgerExpiring is the user metadata of entries written by PutWithTTL,
+// whose values begin with their deadline in Unix nanoseconds
+const badgerExpiring byte = 1
+
+// itemValue returns a copy of an item's value and whether it is still live
+// at now. Expired items should be treated as missing.
+func itemValue(item *badger.Item, now time.Time) ([]byte, bool, error) {
+	value, err := item.ValueCopy(nil)
+	if err != nil || item.UserMeta()&badgerExpiring == 0 {
+		return value, true, err
+	}
+	
+	if len(value) < 8 {
+		return nil, false, errors.New("expiring value has no deadline")
+	}
+	deadline := int64(binary.BigEndian.Uint64(value))
+	return value[8:], now.UnixNano() < deadline, nil
+}
+
 // Get implements Storage.Get by retrieving a value for a given key.
 // It uses BadgerDB's read-only transaction to retrieve the value.
 //
@@ -59,16 +232,44 @@ func (s *BadgerStorage) Put(key, value []byte) error {
 //
 // Returns:
 //   - The value as a byte slice
-//   - An error if the key doesn't exist or the operation fails
+//   - ErrKeyNotFound if the key doesn't exist, or an error if the
+//     operation fails
 func (s *BadgerStorage) Get(key []byte) ([]byte, error) {
+	return s.GetCtx(context.Background(), key)
+}
+
+// GetCtx implements Storage.GetCtx. Reads never conflict, so ctx is only
+// checked before the read transaction begins.
+//
+// Parameters:
+//   - ctx: Bounds the read
+//   - key: The key to look up
+//
+// Returns:
+//   - The value as a byte slice
+//   - ErrKeyNotFound if the key doesn't exist, an error if the operation
+//     fails, or ctx.Err() if ctx is already done
+func (s *BadgerStorage) GetCtx(ctx context.Context, key []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	
 	var value []byte
 	err := s.db.View(func(txn *badger.Txn) error {
 		item, err := txn.Get(key)
+		if err == badger.ErrKeyNotFound {
+			return errBadgerKeyNotFound
+		}
 		if err != nil {
 			return err
 		}
 		
-		value, err = item.ValueCopy(nil)
+		var live bool
+		value, live, err = itemValue(item, time.Now())
+		if err == nil && !live {
+			// Expired keys look exactly like missing ones
+			return errBadgerKeyNotFound
+		}
 		return err
 	})
 	
@@ -84,11 +285,616 @@ func (s *BadgerStorage) Get(key []byte) ([]byte, error) {
 // Returns:
 //   - An error if the key doesn't exist or the operation fails
 func (s *BadgerStorage) Delete(key []byte) error {
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.DeleteCtx(context.Background(), key)
+}
+
+// DeleteCtx implements Storage.DeleteCtx, retrying conflicting
+// transactions like PutCtx.
+//
+// Parameters:
+//   - ctx: Bounds the delete and its retries
+//   - key: The key to delete
+//
+// Returns:
+//   - An error if the operation fails, or ctx.Err() if ctx is done first
+func (s *BadgerStorage) DeleteCtx(ctx context.Context, key []byte) error {
+	return s.updateCtx(ctx, func(txn *badger.Txn) error {
 		return txn.Delete(key)
 	})
 }
 
+// DeleteRange implements Storage.DeleteRange. It collects the live keys in
+// the range with a key-only iterator, then deletes them in transactions of
+// DeleteRangeBatchSize keys, so a large range doesn't exceed BadgerDB's
+// transaction size limit.
+//
+// Parameters:
+//   - start: The first key to delete, or nil for the smallest key
+//   - end: The key to stop before, or nil for no end
+//
+// Returns:
+//   - The number of keys deleted
+//   - An error if reading the range or a delete transaction fails
+func (s *BadgerStorage) DeleteRange(start, end []byte) (int, error) {
+	if emptyRange(start, end, bytes.Compare) {
+		return 0, nil
+	}
+	
+	var keys [][]byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		
+		now := time.Now()
+		for it.Seek(start); it.Valid(); it.Next() {
+			item := it.Item()
+			if end != nil && bytes.Compare(item.Key(), end) >= 0 {
+				break
+			}
+			if item.UserMeta()&badgerExpiring != 0 {
+				_, live, err := itemValue(item, now)
+				if err != nil {
+					return err
+				}
+				if !live {
+					continue
+				}
+			}
+			keys = append(keys, item.KeyCopy(nil))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	
+	deleted := 0
+	for len(keys) > 0 {
+		n := DeleteRangeBatchSize
+		if n > len(keys) {
+			n = len(keys)
+		}
+		err := s.updateCtx(context.Background(), func(txn *badger.Txn) error {
+			for _, key := range keys[:n] {
+				if err := txn.Delete(key); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+		keys = keys[n:]
+	}
+	return deleted, nil
+}
+
+// DeleteIf implements Storage.DeleteIf by comparing and deleting in one
+// BadgerDB transaction. If another writer changes the key concurrently,
+// the transaction fails with a conflict instead of deleting the new value.
+//
+// Parameters:
+//   - key: The key to delete
+//   - expected: The value the key must hold to be deleted
+//
+// Returns:
+//   - true if the key was deleted
+//   - An error if the operation fails
+func (s *BadgerStorage) DeleteIf(key, expected []byte) (bool, error) {
+	deleted := false
+	err := s.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		value, live, err := itemValue(item, time.Now())
+		if err != nil {
+			return err
+		}
+		if !live || !bytes.Equal(value, expected) {
+			return nil
+		}
+
+		deleted = true
+		return txn.Delete(key)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return deleted, nil
+}
+
+// CompareAndSwap implements Storage.CompareAndSwap by reading and
+// conditionally setting the key in one BadgerDB transaction. If another
+// writer changes the key concurrently, the transaction fails with a
+// conflict instead of overwriting the new value.
+//
+// Parameters:
+//   - key: The key to set
+//   - old: The value the key must hold, or nil if it must be absent
+//   - new: The value to set
+//
+// Returns:
+//   - true if the value was set
+//   - An error if the operation fails
+func (s *BadgerStorage) CompareAndSwap(key, old, new []byte) (bool, error) {
+	swapped := false
+	err := s.db.Update(func(txn *badger.Txn) error {
+		var current []byte
+		item, err := txn.Get(key)
+		if err != nil && err != badger.ErrKeyNotFound {
+			return err
+		}
+		exists := err == nil
+		if exists {
+			if current, exists, err = itemValue(item, time.Now()); err != nil {
+				return err
+			}
+		}
+		
+		if !casMatches(current, exists, old) {
+			return nil
+		}
+		swapped = true
+		return txn.Set(key, new)
+	})
+	if err != nil {
+		return false, err
+	}
+	
+	return swapped, nil
+}
+
+// Increment implements Storage.Increment by reading and writing the
+// counter in one BadgerDB transaction. A transaction that conflicts with a
+// concurrent write to the key is retried, so concurrent increments are
+// never lost.
+//
+// Parameters:
+//   - key: The key of the counter
+//   - delta: The amount to add, which may be negative
+//
+// Returns:
+//   - The counter's new value
+//   - An error if the key holds something other than a counter, or the
+//     operation fails
+func (s *BadgerStorage) Increment(key []byte, delta int64) (int64, error) {
+	var n int64
+	err := s.updateCtx(context.Background(), func(txn *badger.Txn) error {
+		var current []byte
+		item, err := txn.Get(key)
+		if err != nil && err != badger.ErrKeyNotFound {
+			return err
+		}
+		exists := err == nil
+		if exists {
+			if current, exists, err = itemValue(item, time.Now()); err != nil {
+				return err
+			}
+		}
+		
+		var value []byte
+		if n, value, err = AddToCounter(current, exists, delta); err != nil {
+			return err
+		}
+		return txn.Set(key, value)
+	})
+	if err != nil {
+		return 0, err
+	}
+	
+	return n, nil
+}
+
+// Append implements Storage.Append by reading the list and writing it back
+// longer in one BadgerDB transaction, retried if it conflicts with a
+// concurrent write to the key. BadgerDB's merge operator isn't used: it
+// merges in the background, so a read would have to merge pending values
+// itself to see an append.
+//
+// Parameters:
+//   - key: The key of the list
+//   - value: The element to append
+//
+// Returns:
+//   - An error if the key holds something other than a list, or the
+//     operation fails
+func (s *BadgerStorage) Append(key, value []byte) error {
+	return s.updateCtx(context.Background(), func(txn *badger.Txn) error {
+		var current []byte
+		item, err := txn.Get(key)
+		if err != nil && err != badger.ErrKeyNotFound {
+			return err
+		}
+		exists := err == nil
+		if exists {
+			if current, exists, err = itemValue(item, time.Now()); err != nil {
+				return err
+			}
+		}
+
+		list, err := AppendToList(current, exists, value)
+		if err != nil {
+			return err
+		}
+		return txn.Set(key, list)
+	})
+}
+
+// GetList implements Storage.GetList
+func (s *BadgerStorage) GetList(key []byte) ([][]byte, error) {
+	return getList(s, key)
+}
+
+// Tail implements Storage.Tail using a reverse BadgerDB iterator.
+// The iterator starts at the largest key and stops after n entries.
+//
+// Parameters:
+//   - n: The number of entries to return
+//
+// Returns:
+//   - Up to n key-value pairs in descending key order
+//   - An error if n is negative or the read fails
+func (s *BadgerStorage) Tail(n int) ([]KV, error) {
+	if n < 0 {
+		return nil, ErrInvalidLimit
+	}
+	
+	result := make([]KV, 0, n)
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		
+		now := time.Now()
+		for it.Rewind(); it.Valid() && len(result) < n; it.Next() {
+			item := it.Item()
+			value, live, err := itemValue(item, now)
+			if err != nil {
+				return err
+			}
+			if live {
+				result = append(result, KV{Key: item.KeyCopy(nil), Value: value})
+			}
+		}
+		return nil
+	})
+	
+	return result, err
+}
+
+// Fingerprint implements Storage.Fingerprint by hashing every pair in
+// one read transaction, so the result reflects a single point in time.
+//
+// Returns:
+//   - The dataset's fingerprint
+//   - An error if the read fails
+func (s *BadgerStorage) Fingerprint() ([]byte, error) {
+	var f Fingerprinter
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		
+		now := time.Now()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			value, live, err := itemValue(item, now)
+			if err != nil {
+				return err
+			}
+			if live {
+				f.Add(item.Key(), value)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	return f.Sum(), nil
+}
+
+// SplitRanges implements Storage.SplitRanges by sampling keys from a
+// keys-only iterator in one read transaction; values are not read.
+//
+// Parameters:
+//   - n: The number of ranges to return
+//
+// Returns:
+//   - n ranges covering the keyspace in ascending order
+//   - An error if n is less than 1 or the read fails
+func (s *BadgerStorage) SplitRanges(n int) ([]KeyRange, error) {
+	sampler, err := newKeySampler(n)
+	if err != nil {
+		return nil, err
+	}
+	
+	err = s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		
+		for it.Rewind(); it.Valid(); it.Next() {
+			sampler.Add(it.Item().Key())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	return sampler.Ranges(), nil
+}
+
+// Scan implements Storage.Scan with a BadgerDB iterator in a read
+// transaction that stays open until the iterator is closed, so the
+// iterator sees the data as of the call to Scan.
+//
+// Parameters:
+//   - start: The first key to return, or nil for the smallest key
+//   - end: The key to stop before, or nil for no end
+//
+// Returns:
+//   - An iterator over the range
+//   - An error if the scan can't be started
+func (s *BadgerStorage) Scan(start, end []byte) (Iterator, error) {
+	if emptyRange(start, end, bytes.Compare) {
+		return NewSliceIterator(nil), nil
+	}
+	
+	txn := s.db.NewTransaction(false)
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	return &badgerIterator{txn: txn, it: it, start: start, end: end, now: time.Now()}, nil
+}
+
+// ScanWithConsistency implements Storage.ScanWithConsistency with Scan
+// for either consistency, since Scan's read transaction already sees the
+// data as of a single point in time.
+//
+// Parameters:
+//   - start: The first key to return, or nil for the smallest key
+//   - end: The key to stop before, or nil for no end
+//   - consistency: The guarantee the scan must give
+//
+// Returns:
+//   - An iterator over the range
+//   - An error if the scan can't be started
+func (s *BadgerStorage) ScanWithConsistency(start, end []byte, consistency ScanConsistency) (Iterator, error) {
+	return s.Scan(start, end)
+}
+
+// ScanReverse implements Storage.ScanReverse with a reverse BadgerDB
+// iterator, which starts at the last key before end, in a read
+// transaction held open like Scan's.
+//
+// Parameters:
+//   - start: The last key to return, or nil for the smallest key
+//   - end: The key to start below, or nil for the largest key
+//
+// Returns:
+//   - An iterator over the range in descending order
+//   - An error if the scan can't be started
+func (s *BadgerStorage) ScanReverse(start, end []byte) (Iterator, error) {
+	if emptyRange(start, end, bytes.Compare) {
+		return NewSliceIterator(nil), nil
+	}
+	
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = true
+	
+	txn := s.db.NewTransaction(false)
+	it := txn.NewIterator(opts)
+	return &badgerIterator{txn: txn, it: it, start: start, end: end, reverse: true, now: time.Now()}, nil
+}
+
+// ScanPrefix implements Storage.ScanPrefix with BadgerDB's prefix
+// iteration, which also lets it skip tables holding no matching keys.
+//
+// Parameters:
+//   - prefix: The prefix keys must begin with; empty matches every key
+//
+// Returns:
+//   - An iterator over the matching keys
+//   - An error if the scan can't be started
+func (s *BadgerStorage) ScanPrefix(prefix []byte) (Iterator, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	
+	txn := s.db.NewTransaction(false)
+	it := txn.NewIterator(opts)
+	return &badgerIterator{txn: txn, it: it, start: prefix, prefix: prefix, now: time.Now()}, nil
+}
+
+// Keys implements Storage.Keys with a key-only BadgerDB iterator, which
+// reads values only for expiring keys, to tell whether they are live.
+//
+// Returns:
+//   - Every live key in ascending order
+//   - An error if reading a value fails
+func (s *BadgerStorage) Keys() ([][]byte, error) {
+	var keys [][]byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		
+		now := time.Now()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			if item.UserMeta()&badgerExpiring != 0 {
+				_, live, err := itemValue(item, now)
+				if err != nil {
+					return err
+				}
+				if !live {
+					continue
+				}
+			}
+			keys = append(keys, item.KeyCopy(nil))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// badgerIterator walks a key range with a BadgerDB iterator, skipping
+// keys that had expired when the scan started. A reverse iterator walks
+// down from end and stops below start.
+type badgerIterator struct {
+	txn        *badger.Txn
+	it         *badger.Iterator
+	start, end []byte
+	prefix     []byte
+	reverse    bool
+	now        time.Time
+	started    bool
+	done       bool
+	err        error
+	key, value []byte
+}
+
+func (b *badgerIterator) Next() bool {
+	if b.done {
+		return false
+	}
+	for {
+		if b.started {
+			b.it.Next()
+		} else if b.reverse && b.end == nil {
+			b.it.Rewind()
+			b.started = true
+		} else if b.reverse {
+			// A reverse seek lands on the last key at or before end,
+			// which is skipped below if it is end itself
+			b.it.Seek(b.end)
+			b.started = true
+		} else {
+			b.it.Seek(b.start)
+			b.started = true
+		}
+		
+		if b.reverse {
+			if !b.it.Valid() || (b.start != nil && bytes.Compare(b.it.Item().Key(), b.start) < 0) {
+				b.done = true
+				return false
+			}
+			if b.end != nil && bytes.Compare(b.it.Item().Key(), b.end) >= 0 {
+				continue
+			}
+		} else if !b.it.ValidForPrefix(b.prefix) || (b.end != nil && bytes.Compare(b.it.Item().Key(), b.end) >= 0) {
+			b.done = true
+			return false
+		}
+		
+		item := b.it.Item()
+		value, live, err := itemValue(item, b.now)
+		if err != nil {
+			b.err = err
+			b.done = true
+			return false
+		}
+		if live {
+			b.key, b.value = item.KeyCopy(nil), value
+			return true
+		}
+	}
+}
+
+func (b *badgerIterator) Key() []byte {
+	return b.key
+}
+
+func (b *badgerIterator) Value() []byte {
+	return b.value
+}
+
+// Close closes the BadgerDB iterator and discards its transaction
+func (b *badgerIterator) Close() error {
+	if b.it != nil {
+		b.it.Close()
+		b.txn.Discard()
+		b.it = nil
+	}
+	b.done = true
+	return b.err
+}
+
+// NewBatch implements Storage.NewBatch with a batch committed in a single
+// BadgerDB transaction, so either every operation is applied or none is.
+//
+// Returns:
+//   - An empty batch
+func (s *BadgerStorage) NewBatch() WriteBatch {
+	return &badgerBatch{db: s.db}
+}
+
+// badgerBatch is a WriteBatch over BadgerDB
+type badgerBatch struct {
+	BatchOps
+	db *badger.DB
+}
+
+// Commit applies the batch in one read-write transaction. If any operation
+// fails, including the transaction growing too large, it is discarded and
+// nothing is applied.
+func (b *badgerBatch) Commit() error {
+	return b.db.Update(func(txn *badger.Txn) error {
+		for _, op := range b.Ops {
+			var err error
+			if op.Delete {
+				err = txn.Delete(op.Key)
+			} else {
+				err = txn.Set(op.Key, op.Value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Sync implements Syncer by syncing BadgerDB's write-ahead log and value log.
+// Writes are not synced individually, so this is what makes them durable.
+//
+// Returns:
+//   - An error if the sync fails
+func (s *BadgerStorage) Sync() error {
+	return s.db.Sync()
+}
+
+// Backup implements Storage.Backup with BadgerDB's online backup, which
+// reads every key-value pair at a single timestamp. Expiry times are kept.
+//
+// Parameters:
+//   - w: Where to write the backup
+//
+// Returns:
+//   - An error if the backup fails
+func (s *BadgerStorage) Backup(w io.Writer) error {
+	if err := writeBackupHeader(w, backupBadger); err != nil {
+		return err
+	}
+	_, err := s.db.Backup(w, 0)
+	return err
+}
+
 // Close implements Storage.Close by properly closing the BadgerDB database.
 // This ensures all pending writes are flushed to disk and resources are released.
 //
@@ -98,6 +904,29 @@ func (s *BadgerStorage) Close() error {
 	return s.db.Close()
 }
 
+// Stats implements Storage.Stats. The disk usage is the size of the LSM
+// tree and value log as last measured by BadgerDB, which refreshes it in
+// the background, so it may trail recent writes.
+//
+// Returns:
+//   - The key count, disk usage and backend type
+//   - An error if the stats can't be read
+func (s *BadgerStorage) Stats() (StorageStats, error) {
+	lsm, vlog := s.db.Size()
+	return StorageStats{
+		KeyCount:    int64(s.Size()),
+		DiskBytes:   lsm + vlog,
+		Backend:     string(BadgerStorageType),
+		Compression: string(s.compression),
+	}, nil
+}
+
+// Limits implements Storage.Limits. BadgerDB's own limits are far larger
+// than the transport allows, so none are reported.
+func (s *BadgerStorage) Limits() Limits {
+	return Limits{}
+}
+
 // Size implements Storage.Size by counting the number of keys in BadgerDB.
 // Since BadgerDB doesn't provide a direct way to get the number of keys,
 // this method iterates through all keys to count them.
@@ -114,7 +943,14 @@ func (s *BadgerStorage) Size() int {
 		it := txn.NewIterator(opts)
 		defer it.Close()
 		
+		now := time.Now()
 		for it.Rewind(); it.Valid(); it.Next() {
+			// Only expiring keys need their value read to be counted
+			if item := it.Item(); item.UserMeta()&badgerExpiring != 0 {
+				if _, live, err := itemValue(item, now); err != nil || !live {
+					continue
+				}
+			}
 			count++
 		}
 		return nil
@@ -0,0 +1,45 @@
+package storage
+
+// WriteBatch groups puts and deletes that are committed together.
+// Operations take effect in the order they were added, so a later
+// operation on a key overrides an earlier one. Deleting a key that doesn't
+// exist is not an error. A batch is committed at most once; whether a
+// failed Commit can leave some operations applied depends on the
+// implementation.
+type WriteBatch interface {
+	// Put adds a write of value to key
+	Put(key, value []byte)
+
+	// Delete adds a deletion of key
+	Delete(key []byte)
+
+	// Commit applies the batch's operations
+	Commit() error
+}
+
+// BatchOp is a single operation in a WriteBatch
+type BatchOp struct {
+	Key    []byte
+	Value  []byte
+	Delete bool
+}
+
+// BatchOps records the operations added to a WriteBatch. Implementations
+// embed it and provide Commit.
+type BatchOps struct {
+	Ops []BatchOp
+}
+
+// Put records a write. The key and value are copied, so the caller may
+// reuse them.
+func (b *BatchOps) Put(key, value []byte) {
+	b.Ops = append(b.Ops, BatchOp{
+		Key:   append([]byte(nil), key...),
+		Value: append([]byte(nil), value...),
+	})
+}
+
+// Delete records a deletion
+func (b *BatchOps) Delete(key []byte) {
+	b.Ops = append(b.Ops, BatchOp{Key: append([]byte(nil), key...), Delete: true})
+}
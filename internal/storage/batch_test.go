@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"godatabase/internal/btree"
+)
+
+func TestWriteBatch_CommitsAllWrites(t *testing.T) {
+	engine, err := NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	badgerStore, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer badgerStore.Close()
+	mem := NewMemStorage()
+
+	for _, s := range []Storage{engine, badgerStore, mem} {
+		s.Put([]byte("old"), []byte("value"))
+
+		batch := s.NewBatch()
+		for i := 0; i < 50; i++ {
+			batch.Put([]byte(fmt.Sprintf("key%02d", i)), []byte(fmt.Sprintf("value%02d", i)))
+		}
+		// Later operations on a key win
+		batch.Delete([]byte("key00"))
+		batch.Put([]byte("key01"), []byte("updated"))
+		batch.Delete([]byte("old"))
+		batch.Delete([]byte("missing"))
+		if err := batch.Commit(); err != nil {
+			t.Fatalf("%T: Commit failed: %v", s, err)
+		}
+
+		if s.Size() != 49 {
+			t.Errorf("%T: Expected 49 keys, got %d", s, s.Size())
+		}
+		for i := 2; i < 50; i++ {
+			value, err := s.Get([]byte(fmt.Sprintf("key%02d", i)))
+			if err != nil || string(value) != fmt.Sprintf("value%02d", i) {
+				t.Errorf("%T: Expected value%02d, got %q (%v)", s, i, value, err)
+			}
+		}
+		if value, _ := s.Get([]byte("key01")); string(value) != "updated" {
+			t.Errorf("%T: Expected the later put to win, got %q", s, value)
+		}
+		for _, key := range []string{"key00", "old"} {
+			if _, err := s.Get([]byte(key)); err == nil {
+				t.Errorf("%T: Expected %s to be deleted", s, key)
+			}
+		}
+	}
+}
+
+func TestWriteBatch_FailedCommitAppliesNothing(t *testing.T) {
+	engine, err := NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	badgerStore, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer badgerStore.Close()
+
+	// An entry each backend rejects, in the middle of the batch: a value
+	// over the B+Tree's limit, and a key over Badger's
+	tooLarge := map[Storage]BatchOp{
+		engine:      {Key: []byte("key25"), Value: make([]byte, btree.BTREE_MAX_VAL_SIZE+1)},
+		badgerStore: {Key: bytes.Repeat([]byte("k"), 70000), Value: []byte("value")},
+	}
+	for s, bad := range tooLarge {
+		s.Put([]byte("existing"), []byte("before"))
+
+		batch := s.NewBatch()
+		batch.Put([]byte("existing"), []byte("after"))
+		for i := 0; i < 50; i++ {
+			if i == 25 {
+				batch.Put(bad.Key, bad.Value)
+				continue
+			}
+			batch.Put([]byte(fmt.Sprintf("key%02d", i)), []byte("value"))
+		}
+		if err := batch.Commit(); err == nil {
+			t.Fatalf("%T: Expected the oversized entry to fail the commit", s)
+		}
+
+		if s.Size() != 1 {
+			t.Errorf("%T: Expected no batch entry applied, got %d keys", s, s.Size())
+		}
+		if value, _ := s.Get([]byte("existing")); string(value) != "before" {
+			t.Errorf("%T: Expected the existing value untouched, got %q", s, value)
+		}
+	}
+}
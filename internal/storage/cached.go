@@ -0,0 +1,456 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// CacheConfig configures a CachedStorage
+type CacheConfig struct {
+	// MaxKeys is the key budget. A Put that takes the storage past it
+	// evicts least recently used keys until the budget is met again.
+	// Zero or negative disables automatic eviction; call EvictToSize.
+	MaxKeys int
+
+	// TailTouches makes Tail count as an access to the keys it returns.
+	// By default range reads leave access times alone, so a full scan
+	// doesn't make every key look recently used.
+	TailTouches bool
+}
+
+// CachedStorage wraps a Storage with a per-key access-time index so the
+// least recently used keys can be evicted when a size budget is exceeded.
+// It implements the Storage interface.
+type CachedStorage struct {
+	store Storage
+	cfg   CacheConfig
+	lru   *list.List               // *accessEntry, most recently used first
+	index map[string]*list.Element // key -> element in lru
+	mu    sync.Mutex
+}
+
+// accessEntry records when a key was last accessed
+type accessEntry struct {
+	key string
+	at  time.Time
+}
+
+// NewCachedStorage wraps store with access tracking. Keys already in store
+// count as least recently used.
+func NewCachedStorage(store Storage, cfg CacheConfig) (*CachedStorage, error) {
+	c := &CachedStorage{
+		store: store,
+		cfg:   cfg,
+		lru:   list.New(),
+		index: make(map[string]*list.Element),
+	}
+
+	it, err := store.Scan(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	for it.Next() {
+		c.index[string(it.Key())] = c.lru.PushBack(&accessEntry{key: string(it.Key())})
+	}
+	if err := it.Close(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// touch marks key as accessed now. The caller must hold c.mu.
+func (c *CachedStorage) touch(key []byte) {
+	now := time.Now()
+	if elem, ok := c.index[string(key)]; ok {
+		elem.Value.(*accessEntry).at = now
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.index[string(key)] = c.lru.PushFront(&accessEntry{key: string(key), at: now})
+}
+
+// forget drops key from the access index. The caller must hold c.mu.
+func (c *CachedStorage) forget(key []byte) {
+	if elem, ok := c.index[string(key)]; ok {
+		c.lru.Remove(elem)
+		delete(c.index, string(key))
+	}
+}
+
+// Put stores a key-value pair and evicts down to the budget if needed
+func (c *CachedStorage) Put(key, value []byte) error {
+	return c.PutCtx(context.Background(), key, value)
+}
+
+// PutCtx is Put, passing ctx on to the wrapped storage
+func (c *CachedStorage) PutCtx(ctx context.Context, key, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.store.PutCtx(ctx, key, value); err != nil {
+		return err
+	}
+	c.touch(key)
+
+	if c.cfg.MaxKeys > 0 && c.lru.Len() > c.cfg.MaxKeys {
+		if _, err := c.evictLocked(c.cfg.MaxKeys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PutWithTTL stores an expiring key-value pair and marks the key as
+// recently used. An expired key stays in the LRU list until it is read,
+// deleted or evicted.
+func (c *CachedStorage) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.store.PutWithTTL(key, value, ttl); err != nil {
+		return err
+	}
+	c.touch(key)
+
+	if c.cfg.MaxKeys > 0 && c.lru.Len() > c.cfg.MaxKeys {
+		if _, err := c.evictLocked(c.cfg.MaxKeys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get retrieves a value and marks the key as recently used
+func (c *CachedStorage) Get(key []byte) ([]byte, error) {
+	return c.GetCtx(context.Background(), key)
+}
+
+// GetCtx is Get, passing ctx on to the wrapped storage
+func (c *CachedStorage) GetCtx(ctx context.Context, key []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, err := c.store.GetCtx(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	c.touch(key)
+	return value, nil
+}
+
+// Delete removes a key-value pair
+func (c *CachedStorage) Delete(key []byte) error {
+	return c.DeleteCtx(context.Background(), key)
+}
+
+// DeleteCtx is Delete, passing ctx on to the wrapped storage
+func (c *CachedStorage) DeleteCtx(ctx context.Context, key []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.store.DeleteCtx(ctx, key); err != nil {
+		return err
+	}
+	c.forget(key)
+	return nil
+}
+
+// DeleteRange removes a key range from the underlying storage and drops
+// the removed keys from the access index. After a partial failure the
+// index keeps them; evicting a key that is already gone does no harm.
+func (c *CachedStorage) DeleteRange(start, end []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n, err := c.store.DeleteRange(start, end)
+	if err != nil {
+		return n, err
+	}
+	r := KeyRange{Start: start, End: end}
+	for key := range c.index {
+		if r.Contains([]byte(key)) {
+			c.forget([]byte(key))
+		}
+	}
+	return n, nil
+}
+
+// DeleteIf removes key if its value equals expected
+func (c *CachedStorage) DeleteIf(key, expected []byte) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deleted, err := c.store.DeleteIf(key, expected)
+	if deleted {
+		c.forget(key)
+	}
+	return deleted, err
+}
+
+// CompareAndSwap sets key to new if it holds old, marking it as recently
+// used if it was set
+func (c *CachedStorage) CompareAndSwap(key, old, new []byte) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	swapped, err := c.store.CompareAndSwap(key, old, new)
+	if !swapped || err != nil {
+		return swapped, err
+	}
+	c.touch(key)
+
+	if c.cfg.MaxKeys > 0 && c.lru.Len() > c.cfg.MaxKeys {
+		if _, err := c.evictLocked(c.cfg.MaxKeys); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// Increment adds to key's counter, marking it as recently used
+func (c *CachedStorage) Increment(key []byte, delta int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n, err := c.store.Increment(key, delta)
+	if err != nil {
+		return 0, err
+	}
+	c.touch(key)
+
+	if c.cfg.MaxKeys > 0 && c.lru.Len() > c.cfg.MaxKeys {
+		if _, err := c.evictLocked(c.cfg.MaxKeys); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Append adds value to key's list, marking it as recently used
+func (c *CachedStorage) Append(key, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.store.Append(key, value); err != nil {
+		return err
+	}
+	c.touch(key)
+
+	if c.cfg.MaxKeys > 0 && c.lru.Len() > c.cfg.MaxKeys {
+		if _, err := c.evictLocked(c.cfg.MaxKeys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetList returns the elements of key's list, marking it as recently used
+// like Get
+func (c *CachedStorage) GetList(key []byte) ([][]byte, error) {
+	return getList(c, key)
+}
+
+// Tail returns the n largest keys. It only updates their access times if
+// TailTouches is set.
+func (c *CachedStorage) Tail(n int) ([]KV, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pairs, err := c.store.Tail(n)
+	if err != nil {
+		return nil, err
+	}
+	if c.cfg.TailTouches {
+		for _, kv := range pairs {
+			c.touch(kv.Key)
+		}
+	}
+	return pairs, nil
+}
+
+// Size returns the number of keys in the underlying storage
+func (c *CachedStorage) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.Size()
+}
+
+// Sync syncs the underlying storage
+func (c *CachedStorage) Sync() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.Sync()
+}
+
+// Backup backs up the underlying storage
+func (c *CachedStorage) Backup(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.Backup(w)
+}
+
+// Stats returns the underlying storage's stats
+func (c *CachedStorage) Stats() (StorageStats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.Stats()
+}
+
+// Limits returns the underlying storage's limits
+func (c *CachedStorage) Limits() Limits {
+	return c.store.Limits()
+}
+
+// Fingerprint returns the underlying storage's fingerprint
+func (c *CachedStorage) Fingerprint() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.Fingerprint()
+}
+
+// SplitRanges splits the underlying storage's keyspace
+func (c *CachedStorage) SplitRanges(n int) ([]KeyRange, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.SplitRanges(n)
+}
+
+// Scan returns an iterator over the underlying storage. Like Tail without
+// TailTouches, it leaves access times alone.
+func (c *CachedStorage) Scan(start, end []byte) (Iterator, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.Scan(start, end)
+}
+
+// ScanWithConsistency returns an iterator over the underlying storage with
+// the chosen consistency, leaving access times alone like Scan
+func (c *CachedStorage) ScanWithConsistency(start, end []byte, consistency ScanConsistency) (Iterator, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.ScanWithConsistency(start, end, consistency)
+}
+
+// ScanReverse returns a descending iterator over the underlying storage,
+// leaving access times alone like Scan
+func (c *CachedStorage) ScanReverse(start, end []byte) (Iterator, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.ScanReverse(start, end)
+}
+
+// ScanPrefix returns a prefix iterator over the underlying storage,
+// leaving access times alone like Scan
+func (c *CachedStorage) ScanPrefix(prefix []byte) (Iterator, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.ScanPrefix(prefix)
+}
+
+// Keys lists the underlying storage's keys, leaving access times alone
+// like Scan
+func (c *CachedStorage) Keys() ([][]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.Keys()
+}
+
+// NewBatch returns a batch over the underlying storage that updates access
+// times like Put and Delete once it commits
+func (c *CachedStorage) NewBatch() WriteBatch {
+	return &cachedBatch{cache: c}
+}
+
+// cachedBatch is a WriteBatch over a CachedStorage
+type cachedBatch struct {
+	BatchOps
+	cache *CachedStorage
+}
+
+// Commit commits the batch to the underlying storage, with its atomicity,
+// then evicts down to the budget if needed
+func (b *cachedBatch) Commit() error {
+	c := b.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	batch := c.store.NewBatch()
+	for _, op := range b.Ops {
+		if op.Delete {
+			batch.Delete(op.Key)
+		} else {
+			batch.Put(op.Key, op.Value)
+		}
+	}
+	if err := batch.Commit(); err != nil {
+		return err
+	}
+
+	for _, op := range b.Ops {
+		if op.Delete {
+			c.forget(op.Key)
+		} else {
+			c.touch(op.Key)
+		}
+	}
+	if c.cfg.MaxKeys > 0 && c.lru.Len() > c.cfg.MaxKeys {
+		if _, err := c.evictLocked(c.cfg.MaxKeys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the underlying storage
+func (c *CachedStorage) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.Close()
+}
+
+// LastAccess returns when key was last written or read. ok is false if the
+// key isn't tracked; keys that existed before the cache was created report
+// the zero time until they are accessed.
+func (c *CachedStorage) LastAccess(key []byte) (at time.Time, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.index[string(key)]
+	if !ok {
+		return time.Time{}, false
+	}
+	return elem.Value.(*accessEntry).at, true
+}
+
+// EvictToSize deletes least recently used keys until at most maxKeys
+// remain and returns how many were deleted
+func (c *CachedStorage) EvictToSize(maxKeys int) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.evictLocked(maxKeys)
+}
+
+// evictLocked implements EvictToSize. The caller must hold c.mu.
+func (c *CachedStorage) evictLocked(maxKeys int) (int, error) {
+	if maxKeys < 0 {
+		maxKeys = 0
+	}
+
+	evicted := 0
+	for c.lru.Len() > maxKeys {
+		elem := c.lru.Back()
+		key := []byte(elem.Value.(*accessEntry).key)
+		if err := c.store.Delete(key); err != nil && !errors.Is(err, ErrKeyNotFound) {
+			return evicted, err
+		}
+		c.lru.Remove(elem)
+		delete(c.index, string(key))
+		evicted++
+	}
+	return evicted, nil
+}
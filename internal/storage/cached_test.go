@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCachedStorage_EvictsLeastRecentlyUsed(t *testing.T) {
+	backing, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Evict manually so the test controls when it happens
+	c, err := NewCachedStorage(backing, CacheConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 20; i++ {
+		key := []byte(fmt.Sprintf("key%02d", i))
+		if err := c.Put(key, []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	// Read the five oldest keys so they become the most recently used,
+	// and scan without that counting as an access
+	for i := 0; i < 5; i++ {
+		if _, err := c.Get([]byte(fmt.Sprintf("key%02d", i))); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+	}
+	if _, err := c.Tail(20); err != nil {
+		t.Fatalf("Tail failed: %v", err)
+	}
+
+	evicted, err := c.EvictToSize(10)
+	if err != nil {
+		t.Fatalf("EvictToSize failed: %v", err)
+	}
+	if evicted != 10 {
+		t.Errorf("Expected 10 keys evicted, got %d", evicted)
+	}
+	if size := c.Size(); size != 10 {
+		t.Errorf("Expected 10 keys left, got %d", size)
+	}
+
+	// Survivors are the five read keys and the five most recent writes
+	for i := 0; i < 20; i++ {
+		key := []byte(fmt.Sprintf("key%02d", i))
+		_, err := backing.Get(key)
+		survived := i < 5 || i >= 15
+		if survived && err != nil {
+			t.Errorf("Expected %s to survive eviction", key)
+		}
+		if !survived && err == nil {
+			t.Errorf("Expected %s to be evicted", key)
+		}
+	}
+}
+
+func TestCachedStorage_EvictsOnBudget(t *testing.T) {
+	backing, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewCachedStorage(backing, CacheConfig{MaxKeys: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 8; i++ {
+		if err := c.Put([]byte(fmt.Sprintf("key%d", i)), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if i == 4 {
+			// Keep key0 warm
+			c.Get([]byte("key0"))
+		}
+	}
+
+	if size := c.Size(); size != 5 {
+		t.Errorf("Expected size to stay within budget of 5, got %d", size)
+	}
+	if _, ok := c.LastAccess([]byte("key0")); !ok {
+		t.Error("Expected key0 to survive as recently used")
+	}
+	if _, ok := c.LastAccess([]byte("key1")); ok {
+		t.Error("Expected key1 to be evicted")
+	}
+}
@@ -0,0 +1,12 @@
+package storage
+
+import "bytes"
+
+// casMatches reports whether a key's current state satisfies the old value
+// of a CompareAndSwap: a nil old matches only a missing key
+func casMatches(current []byte, exists bool, old []byte) bool {
+	if old == nil {
+		return !exists
+	}
+	return exists && bytes.Equal(current, old)
+}
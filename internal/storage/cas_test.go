@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareAndSwap_BothBackends(t *testing.T) {
+	engine, err := NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	badgerStore, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer badgerStore.Close()
+	mem := NewMemStorage()
+
+	for _, s := range []Storage{engine, badgerStore, mem} {
+		// A nil old only matches an absent key
+		swapped, err := s.CompareAndSwap([]byte("key"), nil, []byte("v1"))
+		if err != nil || !swapped {
+			t.Fatalf("%T: Expected swap of absent key, got %v (%v)", s, swapped, err)
+		}
+		if swapped, _ := s.CompareAndSwap([]byte("key"), nil, []byte("v2")); swapped {
+			t.Errorf("%T: Expected swap with nil old to fail for existing key", s)
+		}
+
+		// A mismatched old leaves the value alone
+		if swapped, _ := s.CompareAndSwap([]byte("key"), []byte("wrong"), []byte("v2")); swapped {
+			t.Errorf("%T: Expected swap with wrong old to fail", s)
+		}
+		if value, _ := s.Get([]byte("key")); string(value) != "v1" {
+			t.Errorf("%T: Expected v1, got %q", s, value)
+		}
+
+		// A matching old replaces the value
+		swapped, err = s.CompareAndSwap([]byte("key"), []byte("v1"), []byte("v2"))
+		if err != nil || !swapped {
+			t.Errorf("%T: Expected swap with matching old, got %v (%v)", s, swapped, err)
+		}
+		if value, _ := s.Get([]byte("key")); string(value) != "v2" {
+			t.Errorf("%T: Expected v2, got %q", s, value)
+		}
+
+		// An empty old doesn't match a missing key
+		if swapped, _ := s.CompareAndSwap([]byte("missing"), []byte{}, []byte("v")); swapped {
+			t.Errorf("%T: Expected empty old not to match a missing key", s)
+		}
+		if _, err := s.Get([]byte("missing")); err == nil {
+			t.Errorf("%T: Expected missing to stay absent", s)
+		}
+	}
+}
+
+// failingGetStorage fails every Get with an error other than
+// ErrKeyNotFound, as a storage with a broken disk would
+type failingGetStorage struct {
+	Storage
+}
+
+var errDiskFailure = errors.New("disk failure")
+
+func (s failingGetStorage) Get(key []byte) ([]byte, error) {
+	return nil, errDiskFailure
+}
+
+func TestConditionalWrites_ReportReadErrors(t *testing.T) {
+	cold := failingGetStorage{NewMemStorage()}
+	s, err := NewTieredStorage(NewMemStorage(), cold, TieredConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// A read that fails isn't the same as a missing key
+	if swapped, err := s.CompareAndSwap([]byte("key"), nil, []byte("v1")); !errors.Is(err, errDiskFailure) || swapped {
+		t.Errorf("Expected CompareAndSwap to report the read error, got %v (%v)", swapped, err)
+	}
+	if _, err := s.Increment([]byte("key"), 1); !errors.Is(err, errDiskFailure) {
+		t.Errorf("Expected Increment to report the read error, got %v", err)
+	}
+	if err := s.Append([]byte("key"), []byte("v1")); !errors.Is(err, errDiskFailure) {
+		t.Errorf("Expected Append to report the read error, got %v", err)
+	}
+	if s.Size() != 0 {
+		t.Errorf("Expected nothing written after failed reads, got %d keys", s.Size())
+	}
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tableBytes returns the size of the BadgerDB tables in dir
+func tableBytes(t *testing.T, dir string) int64 {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.sst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += info.Size()
+	}
+	return total
+}
+
+// writeJSONBlobs stores compressible JSON values in a new database at dir
+// and closes it, so its tables are written out
+func writeJSONBlobs(t *testing.T, dir string, opts BadgerOptions) {
+	s, err := NewBadgerStorageWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 500; i++ {
+		value := fmt.Sprintf(`{"id": %d, "tags": [%s], "body": %q}`, i,
+			strings.Repeat(`"event", "audit", `, 20), strings.Repeat("lorem ipsum dolor sit amet ", 80))
+		if err := s.Put([]byte(fmt.Sprintf("doc%04d", i)), []byte(value)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestBadgerStorage_CompressionShrinksTables(t *testing.T) {
+	plainDir, zstdDir := t.TempDir(), t.TempDir()
+	writeJSONBlobs(t, plainDir, BadgerOptions{Compression: BadgerCompressionNone})
+	writeJSONBlobs(t, zstdDir, BadgerOptions{Compression: BadgerCompressionZSTD})
+
+	plain, compressed := tableBytes(t, plainDir), tableBytes(t, zstdDir)
+	if plain == 0 || compressed*4 > plain {
+		t.Errorf("Expected ZSTD to shrink the tables at least 4x, got %d bytes against %d uncompressed", compressed, plain)
+	}
+
+	// Reopening without options keeps the codec, and reads decompress
+	s, err := NewBadgerStorage(zstdDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Compression != "zstd" {
+		t.Errorf("Expected the reopened database to keep zstd, got %q", stats.Compression)
+	}
+	value, err := s.Get([]byte("doc0042"))
+	if err != nil || !strings.HasPrefix(string(value), `{"id": 42,`) {
+		t.Errorf("Expected doc0042 to read back, got %.20q (%v)", value, err)
+	}
+}
+
+func TestBadgerStorage_CompressionDefaultsAndValidation(t *testing.T) {
+	s, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if stats, _ := s.Stats(); stats.Compression != "snappy" {
+		t.Errorf("Expected a new database to use snappy, got %q", stats.Compression)
+	}
+
+	if _, err := NewBadgerStorageWithOptions(t.TempDir(), BadgerOptions{Compression: "lz4"}); err == nil {
+		t.Error("Expected an unknown codec to be rejected")
+	}
+}
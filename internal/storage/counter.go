@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// EncodeCounter returns the stored form of a counter value, as written by
+// Increment: 8 bytes, big-endian
+func EncodeCounter(n int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(n))
+	return b
+}
+
+// DecodeCounter reads a value written by Increment or EncodeCounter. It
+// returns ErrNotCounter if the value isn't 8 bytes long.
+func DecodeCounter(value []byte) (int64, error) {
+	if len(value) != 8 {
+		return 0, ErrNotCounter
+	}
+	return int64(binary.BigEndian.Uint64(value)), nil
+}
+
+// AddToCounter adds delta to a key's current counter value, or to 0 if the
+// key doesn't exist, and returns the sum with its stored form. Backends
+// call it between the read and the write of their Increment.
+func AddToCounter(current []byte, exists bool, delta int64) (int64, []byte, error) {
+	var n int64
+	if exists {
+		var err error
+		if n, err = DecodeCounter(current); err != nil {
+			return 0, nil, err
+		}
+	}
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		return 0, nil, ErrCounterOverflow
+	}
+	n += delta
+	return n, EncodeCounter(n), nil
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestIncrement_ConcurrentIncrementsAreNotLost(t *testing.T) {
+	for _, s := range newRangeStores(t) {
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := s.Increment([]byte("hits"), 1); err != nil {
+					t.Errorf("%T: Increment failed: %v", s, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		value, err := s.Get([]byte("hits"))
+		if err != nil {
+			t.Fatalf("%T: Get failed: %v", s, err)
+		}
+		if n, err := DecodeCounter(value); err != nil || n != 100 {
+			t.Errorf("%T: Expected 100, got %d (%v)", s, n, err)
+		}
+	}
+}
+
+func TestIncrement_MissingAndInvalidValues(t *testing.T) {
+	for _, s := range newRangeStores(t) {
+		// A missing key counts as 0, and delta may be negative
+		if n, err := s.Increment([]byte("counter"), -5); err != nil || n != -5 {
+			t.Errorf("%T: Expected -5, got %d (%v)", s, n, err)
+		}
+		if n, err := s.Increment([]byte("counter"), 7); err != nil || n != 2 {
+			t.Errorf("%T: Expected 2, got %d (%v)", s, n, err)
+		}
+
+		// A value that isn't a counter is left alone
+		s.Put([]byte("name"), []byte("alice"))
+		if _, err := s.Increment([]byte("name"), 1); !errors.Is(err, ErrNotCounter) {
+			t.Errorf("%T: Expected ErrNotCounter, got %v", s, err)
+		}
+		if value, _ := s.Get([]byte("name")); string(value) != "alice" {
+			t.Errorf("%T: Expected alice, got %q", s, value)
+		}
+
+		s.Put([]byte("max"), EncodeCounter(math.MaxInt64))
+		if _, err := s.Increment([]byte("max"), 1); !errors.Is(err, ErrCounterOverflow) {
+			t.Errorf("%T: Expected ErrCounterOverflow, got %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package storage
+
+// DeleteRangeBatchSize is how many deletes DeleteRangeInBatches, and
+// BadgerStorage's DeleteRange, commit together
+const DeleteRangeBatchSize = 1000
+
+// DeleteRangeInBatches implements Storage.DeleteRange for storage without
+// a range delete of its own. It scans the keys in [start, end), then
+// deletes them through s.NewBatch, batchSize at a time, so each batch is
+// applied with the storage's batch atomicity but the range as a whole is
+// not: a key written into the range during the call may survive it.
+//
+// Parameters:
+//   - s: The storage to delete from
+//   - start: The first key to delete, or nil for the smallest key
+//   - end: The key to stop before, or nil for no end
+//   - batchSize: How many deletes to commit together
+//
+// Returns:
+//   - The number of keys deleted, including those of batches committed
+//     before an error
+//   - An error if the scan or a batch fails
+func DeleteRangeInBatches(s Storage, start, end []byte, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = DeleteRangeBatchSize
+	}
+
+	// Collect the keys first, so the deletes don't run under an open
+	// iterator
+	it, err := s.Scan(start, end)
+	if err != nil {
+		return 0, err
+	}
+	var keys [][]byte
+	for it.Next() {
+		keys = append(keys, append([]byte(nil), it.Key()...))
+	}
+	if err := it.Close(); err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for len(keys) > 0 {
+		n := batchSize
+		if n > len(keys) {
+			n = len(keys)
+		}
+		batch := s.NewBatch()
+		for _, key := range keys[:n] {
+			batch.Delete(key)
+		}
+		if err := batch.Commit(); err != nil {
+			return deleted, err
+		}
+		deleted += n
+		keys = keys[n:]
+	}
+	return deleted, nil
+}
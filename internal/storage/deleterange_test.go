@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// newRangeStores returns one of each backend that implements DeleteRange
+// itself, plus the wrappers over them
+func newRangeStores(t *testing.T) []Storage {
+	engine, err := NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	badgerStore, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, err := NewCachedStorage(NewMemStorage(), CacheConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiered, err := NewTieredStorage(NewMemStorage(), NewMemStorage(), TieredConfig{MaxHotKeys: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := []Storage{engine, badgerStore, NewMemStorage(), cached, tiered}
+	t.Cleanup(func() {
+		for _, s := range stores {
+			s.Close()
+		}
+	})
+	return stores
+}
+
+// keysOf lists every key in s
+func keysOf(t *testing.T, s Storage) string {
+	it, err := s.Scan(nil, nil)
+	if err != nil {
+		t.Fatalf("%T: Scan failed: %v", s, err)
+	}
+	return fmt.Sprint(collect(t, it))
+}
+
+func TestDeleteRange_MiddleRange(t *testing.T) {
+	for _, s := range newRangeStores(t) {
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("key%03d", i)
+			if err := s.Put([]byte(key), []byte("value-"+key)); err != nil {
+				t.Fatalf("%T: Put failed: %v", s, err)
+			}
+		}
+		if tiered, ok := s.(*TieredStorage); ok {
+			// Spread the keys over both tiers
+			tiered.Demote()
+		}
+
+		n, err := s.DeleteRange([]byte("key020"), []byte("key080"))
+		if err != nil {
+			t.Fatalf("%T: DeleteRange failed: %v", s, err)
+		}
+		if n != 60 {
+			t.Errorf("%T: Expected 60 keys deleted, got %d", s, n)
+		}
+		if s.Size() != 40 {
+			t.Errorf("%T: Expected 40 keys left, got %d", s, s.Size())
+		}
+		for _, key := range []string{"key000", "key019", "key080", "key099"} {
+			if _, err := s.Get([]byte(key)); err != nil {
+				t.Errorf("%T: Expected %s outside the range to survive, got %v", s, key, err)
+			}
+		}
+		for _, key := range []string{"key020", "key050", "key079"} {
+			if _, err := s.Get([]byte(key)); err == nil {
+				t.Errorf("%T: Expected %s to be deleted", s, key)
+			}
+		}
+
+		// Nothing is left to delete, and an empty range deletes nothing
+		if n, err := s.DeleteRange([]byte("key020"), []byte("key080")); err != nil || n != 0 {
+			t.Errorf("%T: Expected nothing deleted again, got %d (%v)", s, n, err)
+		}
+		if n, err := s.DeleteRange([]byte("key090"), []byte("key010")); err != nil || n != 0 {
+			t.Errorf("%T: Expected an empty range to delete nothing, got %d (%v)", s, n, err)
+		}
+		if s.Size() != 40 {
+			t.Errorf("%T: Expected 40 keys left, got %d", s, s.Size())
+		}
+	}
+}
+
+func TestDeleteRange_PrefixRange(t *testing.T) {
+	// Neighbours on both sides of the "session:" keys
+	keys := []string{"config:db", "session", "session:a", "session:b", "session:c", "session;", "sessions", "user:1"}
+	for _, s := range newRangeStores(t) {
+		for _, key := range keys {
+			if err := s.Put([]byte(key), []byte("value-"+key)); err != nil {
+				t.Fatalf("%T: Put failed: %v", s, err)
+			}
+		}
+
+		// Every key beginning with "session:" sorts before "session;"
+		n, err := s.DeleteRange([]byte("session:"), []byte("session;"))
+		if err != nil {
+			t.Fatalf("%T: DeleteRange failed: %v", s, err)
+		}
+		if n != 3 {
+			t.Errorf("%T: Expected 3 keys deleted, got %d", s, n)
+		}
+		if got := keysOf(t, s); got != "[config:db session session; sessions user:1]" {
+			t.Errorf("%T: Unexpected keys left: %s", s, got)
+		}
+
+		// Unbounded ends delete up to and from the edges of the keyspace
+		if n, err := s.DeleteRange(nil, []byte("session")); err != nil || n != 1 {
+			t.Errorf("%T: Expected 1 key deleted before session, got %d (%v)", s, n, err)
+		}
+		if n, err := s.DeleteRange([]byte("sessions"), nil); err != nil || n != 2 {
+			t.Errorf("%T: Expected 2 keys deleted from sessions on, got %d (%v)", s, n, err)
+		}
+		if got := keysOf(t, s); got != "[session session;]" {
+			t.Errorf("%T: Unexpected keys left: %s", s, got)
+		}
+	}
+}
+
+func TestDeleteRangeInBatches_SpansSeveralBatches(t *testing.T) {
+	s := NewMemStorage()
+	for i := 0; i < 25; i++ {
+		key := fmt.Sprintf("key%02d", i)
+		s.Put([]byte(key), []byte("value-"+key))
+	}
+
+	n, err := DeleteRangeInBatches(s, []byte("key03"), []byte("key20"), 4)
+	if err != nil {
+		t.Fatalf("DeleteRangeInBatches failed: %v", err)
+	}
+	if n != 17 {
+		t.Errorf("Expected 17 keys deleted, got %d", n)
+	}
+	if got := keysOf(t, s); got != "[key00 key01 key02 key20 key21 key22 key23 key24]" {
+		t.Errorf("Unexpected keys left: %s", got)
+	}
+}
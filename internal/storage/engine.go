@@ -1,11 +1,17 @@
 package storage
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"log"
 	"os"
 	"sync"
+	"time"
 
 	"godatabase/internal/btree"
 )
@@ -18,19 +24,45 @@ const (
 	MAGIC = uint32(0x12345678)
 
 	// Version of the storage format
-	VERSION = uint32(1)
+	VERSION = uint32(2)
+
+	// HEADER_SIZE is the number of bytes of the header page in use:
+	// magic, version, root page and page count
+	HEADER_SIZE = 24
 )
 
 // StorageEngine represents the storage engine
 type StorageEngine struct {
 	file     *os.File
 	btree    *btree.BTree
+	cmp      btree.Comparator
 	mu       sync.RWMutex
 	filename string
+
+	// Whether each write is durable before it returns. Without
+	// auto-flush, writes only change the in-memory tree and dirty records
+	// that it differs from the file until Sync, Checkpoint or Close.
+	autoFlush bool
+	dirty     bool
+
+	// The write-ahead log of changes since the last checkpoint, and the
+	// size it may reach before a write checkpoints. unlogged records that
+	// writes made without auto-flush are missing from the log, so the
+	// next logged write must checkpoint instead.
+	wal      *wal
+	walLimit int64
+	unlogged bool
 }
 
 // NewStorageEngine creates a new storage engine
 func NewStorageEngine(filename string) (*StorageEngine, error) {
+	return NewStorageEngineWithComparator(filename, bytes.Compare)
+}
+
+// NewStorageEngineWithComparator creates a new storage engine whose keys are
+// ordered by cmp. A database file must always be opened with the comparator
+// it was created with; see btree.NewBTreeWithComparator.
+func NewStorageEngineWithComparator(filename string, cmp btree.Comparator) (*StorageEngine, error) {
 	// Open or create the database file
 	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
@@ -39,8 +71,12 @@ func NewStorageEngine(filename string) (*StorageEngine, error) {
 
 	engine := &StorageEngine{
 		file:     file,
-		btree:    btree.NewBTree(),
+		btree:    btree.NewBTreeWithComparator(cmp),
+		cmp:      cmp,
 		filename: filename,
+
+		autoFlush: true,
+		walLimit:  DefaultWALCheckpointSize,
 	}
 
 	// Initialize the database if it's new
@@ -49,10 +85,50 @@ func NewStorageEngine(filename string) (*StorageEngine, error) {
 		return nil, err
 	}
 
+	// Bring the checkpoint up to date with the writes logged after it
+	if err := engine.openWAL(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
 	return engine, nil
 }
 
-// initialize sets up a new database file
+// openWAL opens the engine's write-ahead log and replays it onto the tree
+// loaded from the checkpoint. A crash between a checkpoint and emptying
+// the log leaves records the checkpoint already holds; replaying them
+// again is harmless, since each one sets or deletes keys outright.
+func (e *StorageEngine) openWAL() error {
+	w, err := openWAL(e.filename + ".wal")
+	if err != nil {
+		return err
+	}
+
+	records, err := w.replay(func(op walOp) error {
+		switch op.kind {
+		case walPut:
+			return e.btree.Upsert(op.key, op.value)
+		case walDelete:
+			if err := e.btree.Delete(op.key); err != nil && !errors.Is(err, ErrKeyNotFound) {
+				return err
+			}
+		case walDeleteRange:
+			e.btree.DeleteRange(op.key, op.value)
+		}
+		return nil
+	})
+	if err != nil {
+		w.close()
+		return fmt.Errorf("failed to replay write-ahead log: %v", err)
+	}
+
+	e.wal = w
+	e.dirty = records > 0
+	return nil
+}
+
+// initialize sets up a new database file, or loads the tree from an
+// existing one
 func (e *StorageEngine) initialize() error {
 	// Check if the file is empty
 	stat, err := e.file.Stat()
@@ -61,48 +137,106 @@ func (e *StorageEngine) initialize() error {
 	}
 
 	if stat.Size() == 0 {
-		// Write the header
-		header := make([]byte, 8)
-		binary.BigEndian.PutUint32(header[0:4], MAGIC)
-		binary.BigEndian.PutUint32(header[4:8], VERSION)
-		if _, err := e.file.Write(header); err != nil {
-			return err
-		}
-	} else {
-		// Verify the header
-		header := make([]byte, 8)
-		if _, err := e.file.ReadAt(header, 0); err != nil {
-			return err
-		}
-		magic := binary.BigEndian.Uint32(header[0:4])
-		version := binary.BigEndian.Uint32(header[4:8])
-		if magic != MAGIC {
-			return errors.New("invalid database file")
+		// Write the header of an empty database
+		return writeHeader(e.file, 0, 0)
+	}
+
+	// Verify the header
+	header := make([]byte, HEADER_SIZE)
+	if _, err := e.file.ReadAt(header[:8], 0); err != nil {
+		return err
+	}
+	magic := binary.BigEndian.Uint32(header[0:4])
+	version := binary.BigEndian.Uint32(header[4:8])
+	if magic != MAGIC {
+		return errors.New("invalid database file")
+	}
+	if version == 1 {
+		// Version 1 files never held the tree; they open empty and are
+		// rewritten in the current format by the next flush
+		return nil
+	}
+	if version != VERSION {
+		return errors.New("unsupported database version")
+	}
+	if _, err := e.file.ReadAt(header, 0); err != nil {
+		return fmt.Errorf("failed to read header: %v", err)
+	}
+
+	root := binary.BigEndian.Uint64(header[8:16])
+	pages := binary.BigEndian.Uint64(header[16:24])
+	if root == 0 {
+		return nil
+	}
+	if root > pages || stat.Size() < int64(pages+1)*PAGE_SIZE {
+		return errors.New("database file is truncated")
+	}
+
+	tree, err := btree.LoadBTree(e.cmp, root, func(page uint64) ([]byte, error) {
+		if page == 0 || page > pages {
+			return nil, fmt.Errorf("page %d out of range", page)
 		}
-		if version != VERSION {
-			return errors.New("unsupported database version")
+		buf := make([]byte, PAGE_SIZE)
+		if _, err := e.file.ReadAt(buf, int64(page)*PAGE_SIZE); err != nil {
+			return nil, err
 		}
+		return buf, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load tree: %v", err)
 	}
-
+	e.btree = tree
 	return nil
 }
 
-// Put stores a key-value pair
+// writeHeader writes the header page of file, recording the root node's
+// page and how many node pages follow the header. A root of 0 means no
+// tree has been written.
+func writeHeader(file *os.File, root, pages uint64) error {
+	header := make([]byte, PAGE_SIZE)
+	binary.BigEndian.PutUint32(header[0:4], MAGIC)
+	binary.BigEndian.PutUint32(header[4:8], VERSION)
+	binary.BigEndian.PutUint64(header[8:16], root)
+	binary.BigEndian.PutUint64(header[16:24], pages)
+	_, err := file.WriteAt(header, 0)
+	return err
+}
+
+// Put stores a key-value pair, replacing the value of an existing key
 func (e *StorageEngine) Put(key, value []byte) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+	return e.PutCtx(context.Background(), key, value)
+}
 
-	// Insert into B+Tree
-	if err := e.btree.Insert(key, value); err != nil {
+// PutCtx is Put bounded by ctx. The tree is written in memory without
+// blocking, so ctx is only checked before the write begins.
+func (e *StorageEngine) PutCtx(ctx context.Context, key, value []byte) error {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	// Write to disk
-	return e.flush()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.logged(walOp{kind: walPut, key: key, value: value})
+}
+
+// PutWithTTL stores a key-value pair like Put. The B+Tree has nowhere to
+// keep an expiry time, so the key never expires.
+func (e *StorageEngine) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	return e.Put(key, value)
 }
 
 // Get retrieves a value for a given key
 func (e *StorageEngine) Get(key []byte) ([]byte, error) {
+	return e.GetCtx(context.Background(), key)
+}
+
+// GetCtx is Get bounded by ctx, which is checked before the read begins
+func (e *StorageEngine) GetCtx(ctx context.Context, key []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
@@ -111,70 +245,404 @@ func (e *StorageEngine) Get(key []byte) ([]byte, error) {
 
 // Delete removes a key-value pair
 func (e *StorageEngine) Delete(key []byte) error {
+	return e.DeleteCtx(context.Background(), key)
+}
+
+// DeleteCtx is Delete bounded by ctx, which is checked before the delete
+// begins like PutCtx
+func (e *StorageEngine) DeleteCtx(ctx context.Context, key []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	// Delete from B+Tree
-	if err := e.btree.Delete(key); err != nil {
+	if _, err := e.btree.Get(key); err != nil {
 		return err
 	}
+	return e.logged(walOp{kind: walDelete, key: key})
+}
 
-	// Write to disk
-	return e.flush()
+// DeleteIf removes key only if its value equals expected.
+// The compare and delete happen under the engine's write lock.
+func (e *StorageEngine) DeleteIf(key, expected []byte) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	value, err := e.btree.Get(key)
+	if errors.Is(err, ErrKeyNotFound) {
+		// A missing key doesn't match anything
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(value, expected) {
+		return false, nil
+	}
+
+	if err := e.logged(walOp{kind: walDelete, key: key}); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
-// flush writes the current state to disk
-func (e *StorageEngine) flush() error {
-	// Seek to the start of the data section (after header)
-	if _, err := e.file.Seek(8, io.SeekStart); err != nil {
-		return err
-	}
-	
-	// This is a basic implementation of tree serialization.
-	// In a real implementation, you would serialize each node separately
-	// and build a page index.
-	
-	// Write a simple header for the B+Tree data
-	treeHeader := make([]byte, 8)
-	binary.BigEndian.PutUint32(treeHeader[0:4], uint32(e.btree.Size()))
-	binary.BigEndian.PutUint32(treeHeader[4:8], uint32(e.btree.Height()))
-	if _, err := e.file.Write(treeHeader); err != nil {
-		return err
-	}
-	
-	// For now, we'll use a simplified approach that doesn't support
-	// full tree reconstruction, but allows us to store key-value pairs
-	
-	// Serialize the root node
-	if e.btree.Size() > 0 {
-		// Get the root node and its data
-		rootData := serializeNode(e.btree)
-		if _, err := e.file.Write(rootData); err != nil {
+// DeleteRange removes the keys in [start, end) as ordered by the engine's
+// comparator, under one write lock and with one write to disk. If the
+// write fails the removed keys are put back.
+func (e *StorageEngine) DeleteRange(start, end []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var removed []KV
+	cursor := e.btree.Cursor(start)
+	for cursor.Next() {
+		if end != nil && e.cmp(cursor.Key(), end) >= 0 {
+			break
+		}
+		removed = append(removed, KV{Key: cursor.Key(), Value: cursor.Value()})
+	}
+	if err := cursor.Err(); err != nil {
+		return 0, err
+	}
+	if len(removed) == 0 {
+		return 0, nil
+	}
+
+	e.btree.DeleteRange(start, end)
+	if err := e.written(walOp{kind: walDeleteRange, key: start, value: end}); err != nil {
+		for _, kv := range removed {
+			e.btree.Upsert(kv.Key, kv.Value)
+		}
+		return 0, err
+	}
+	return len(removed), nil
+}
+
+// NewBatch returns a batch that is applied under one write lock and
+// written to disk as a single log record, if the engine auto-flushes
+func (e *StorageEngine) NewBatch() WriteBatch {
+	return &engineBatch{engine: e}
+}
+
+// engineBatch is a WriteBatch over a StorageEngine
+type engineBatch struct {
+	BatchOps
+	engine *StorageEngine
+}
+
+// Commit applies the batch atomically: if any entry is too large or the
+// write to disk fails, nothing is applied. Every entry is checked before
+// the tree is changed, and a failed write is undone by restoring the
+// previous values of the batch's keys.
+func (b *engineBatch) Commit() error {
+	e := b.engine
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i, op := range b.Ops {
+		if op.Delete {
+			continue
+		}
+		if err := e.btree.CheckSize(op.Key, op.Value); err != nil {
+			return fmt.Errorf("batch entry %d: %v", i, err)
+		}
+	}
+
+	ops := make([]walOp, len(b.Ops))
+	for i, op := range b.Ops {
+		if op.Delete {
+			ops[i] = walOp{kind: walDelete, key: op.Key}
+		} else {
+			ops[i] = walOp{kind: walPut, key: op.Key, value: op.Value}
+		}
+	}
+	return e.logged(ops...)
+}
+
+// logged applies ops to the tree and then records them with written. A
+// delete of a missing key is skipped and not recorded. If an operation
+// can't be applied or the write fails, every operation already applied is
+// undone by restoring the previous value of its key, so the tree never
+// keeps a change that isn't in the write-ahead log and that a later
+// checkpoint could persist. The caller must hold the write lock.
+func (e *StorageEngine) logged(ops ...walOp) error {
+	type undo struct {
+		key     []byte
+		value   []byte
+		existed bool
+	}
+	undos := make([]undo, 0, len(ops))
+	rollback := func() {
+		for i := len(undos) - 1; i >= 0; i-- {
+			u := undos[i]
+			if u.existed {
+				e.btree.Upsert(u.key, u.value)
+			} else {
+				e.btree.Delete(u.key)
+			}
+		}
+	}
+
+	applied := make([]walOp, 0, len(ops))
+	for _, op := range ops {
+		prev, err := e.btree.Get(op.key)
+		u := undo{key: op.key, existed: err == nil}
+		if u.existed {
+			u.value = append([]byte(nil), prev...)
+		}
+
+		if op.kind == walDelete {
+			if !u.existed {
+				continue
+			}
+			err = e.btree.Delete(op.key)
+		} else {
+			err = e.btree.Upsert(op.key, op.value)
+		}
+		if err != nil {
+			rollback()
 			return err
 		}
+		undos = append(undos, u)
+		applied = append(applied, op)
+	}
+
+	if len(applied) == 0 {
+		return nil
+	}
+	if err := e.written(applied...); err != nil {
+		rollback()
+		return err
+	}
+	return nil
+}
+
+// CompareAndSwap sets key to new if it holds old, or if it is absent when
+// old is nil. The compare and write happen under the engine's write lock.
+func (e *StorageEngine) CompareAndSwap(key, old, new []byte) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	value, err := e.btree.Get(key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return false, err
+	}
+	if !casMatches(value, err == nil, old) {
+		return false, nil
+	}
+
+	if err := e.logged(walOp{kind: walPut, key: key, value: new}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Increment adds delta to key's counter. The read and write happen under
+// the engine's write lock.
+func (e *StorageEngine) Increment(key []byte, delta int64) (int64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	current, err := e.btree.Get(key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return 0, err
+	}
+	n, value, err := AddToCounter(current, err == nil, delta)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := e.logged(walOp{kind: walPut, key: key, value: value}); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Append adds value to key's list under the engine's write lock
+func (e *StorageEngine) Append(key, value []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	current, err := e.btree.Get(key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return err
+	}
+	list, err := AppendToList(current, err == nil, value)
+	if err != nil {
+		return err
 	}
-	
-	// Ensure all data is written to disk
-	return e.file.Sync()
-}
-
-// serializeNode creates a byte representation of the key-value pairs in the tree
-// This is a simplified implementation that doesn't actually serialize the tree structure
-func serializeNode(tree *btree.BTree) []byte {
-	// Get all key-value pairs from the tree
-	// This is just a placeholder implementation
-	// that serializes up to 1000 key-value pairs
-	
-	buf := make([]byte, 0, PAGE_SIZE)
-	
-	// This would typically iterate through the tree's leaf nodes 
-	// For now, we just append some metadata
-	metaSize := 8
-	buf = append(buf, make([]byte, metaSize)...)
-	
-	// In a real implementation, this would follow the proper B+Tree serialization format
-	
-	return buf
+
+	return e.logged(walOp{kind: walPut, key: key, value: list})
+}
+
+// GetList returns the elements of key's list
+func (e *StorageEngine) GetList(key []byte) ([][]byte, error) {
+	return getList(e, key)
+}
+
+// SetAutoFlush sets whether each write is durable before it returns,
+// which is the default: each write is appended to the write-ahead log and
+// synced. Callers making many writes can turn it off and call Sync once
+// they need the writes to be durable; until then, a crash loses them.
+func (e *StorageEngine) SetAutoFlush(enabled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.autoFlush = enabled
+}
+
+// SetWALCheckpointSize sets how large the write-ahead log grows, in bytes,
+// before a write checkpoints the tree into the database file and empties
+// the log. It is DefaultWALCheckpointSize by default. A larger log means
+// fewer rewrites of the tree but a longer replay after a crash.
+func (e *StorageEngine) SetWALCheckpointSize(bytes int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.walLimit = bytes
+}
+
+// written records that the in-memory tree changed by ops. Unless
+// auto-flush is off, ops are appended to the write-ahead log, and the tree
+// is checkpointed once the log has grown past its limit. The caller must
+// hold e.mu.
+func (e *StorageEngine) written(ops ...walOp) error {
+	e.dirty = true
+	if !e.autoFlush {
+		e.unlogged = true
+		return nil
+	}
+	if e.unlogged {
+		// The log is missing earlier writes, so replaying it alone
+		// wouldn't restore this one
+		return e.flush()
+	}
+
+	if err := e.wal.append(ops); err != nil {
+		return err
+	}
+	if e.wal.size >= e.walLimit {
+		// The write is already durable in the log, so a failed
+		// checkpoint is only retried by the next write
+		if err := e.flush(); err != nil {
+			log.Printf("WARNING: checkpoint of %s failed, keeping the write-ahead log: %v", e.filename, err)
+		}
+	}
+	return nil
+}
+
+// flush checkpoints the tree: it writes the whole tree to a new database
+// file, one node per page after the header page, syncs it and renames it
+// over the old one, so a crash leaves either the old checkpoint or the new
+// one. The write-ahead log is emptied once the new file is in place.
+func (e *StorageEngine) flush() error {
+	tmp := e.filename + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	var pages uint64
+	root, err := e.btree.WritePages(func(page uint64, data []byte) error {
+		pages = page
+		_, err := file.WriteAt(data, int64(page)*PAGE_SIZE)
+		return err
+	})
+	if err == nil {
+		err = writeHeader(file, root, pages)
+	}
+	if err == nil {
+		// Ensure all data is written to disk before it replaces the old file
+		err = file.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, e.filename)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	e.file.Close()
+	e.file = file
+	if err := e.wal.reset(); err != nil {
+		return err
+	}
+	e.dirty = false
+	e.unlogged = false
+	return nil
+}
+
+// Checkpoint forces a full flush of the tree to the database file and
+// syncs it, so everything written before it returns is on disk, and
+// empties the write-ahead log.
+func (e *StorageEngine) Checkpoint() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.flush()
+}
+
+// Sync implements Syncer by flushing the writes made since the last flush.
+// With auto-flush on, every write is already flushed before it returns,
+// so there is nothing to do.
+func (e *StorageEngine) Sync() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.dirty {
+		return nil
+	}
+	return e.flush()
+}
+
+// Backup implements Storage.Backup by writing a database file holding the
+// tree, under the read lock so no write lands partway through. The file is
+// written from the in-memory tree, as a flush would write it, so writes
+// not yet flushed with auto-flush off are included.
+func (e *StorageEngine) Backup(w io.Writer) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	// Pages are numbered in write order and the root is written last, so
+	// a first pass finds the root page for the header, which comes first
+	pages, err := e.btree.WritePages(func(uint64, []byte) error { return nil })
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeBackupHeader(bw, backupEngine); err != nil {
+		return err
+	}
+	header := make([]byte, PAGE_SIZE)
+	binary.BigEndian.PutUint32(header[0:4], MAGIC)
+	binary.BigEndian.PutUint32(header[4:8], VERSION)
+	binary.BigEndian.PutUint64(header[8:16], pages)
+	binary.BigEndian.PutUint64(header[16:24], pages)
+	if _, err := bw.Write(header); err != nil {
+		return err
+	}
+	if _, err := e.btree.WritePages(func(page uint64, data []byte) error {
+		_, err := bw.Write(data)
+		return err
+	}); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// crash simulates a process crash for tests: the file is closed without
+// flushing and the in-memory tree is dropped. Only what earlier flushes
+// and checkpoints wrote to disk is visible to a reopened engine.
+func (e *StorageEngine) crash() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.file.Close()
+	e.wal.close()
+	e.btree = btree.NewBTreeWithComparator(e.cmp)
 }
 
 // Close closes the storage engine
@@ -187,13 +655,236 @@ func (e *StorageEngine) Close() error {
 		return err
 	}
 
+	// The checkpoint holds everything, so the emptied log can go
+	e.wal.close()
+	os.Remove(e.wal.path)
 	return e.file.Close()
 }
 
+// Tail returns the n largest key-value pairs in descending key order
+func (e *StorageEngine) Tail(n int) ([]KV, error) {
+	if n < 0 {
+		return nil, ErrInvalidLimit
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	result := make([]KV, 0, n)
+	e.btree.ReverseIterate(func(key, value []byte) bool {
+		if len(result) >= n {
+			return false
+		}
+		// Copy out of the node's buffer so later writes can't change the result
+		result = append(result, KV{
+			Key:   append([]byte(nil), key...),
+			Value: append([]byte(nil), value...),
+		})
+		return true
+	})
+
+	return result, nil
+}
+
+// Fingerprint returns an order-independent hash of every key-value pair
+func (e *StorageEngine) Fingerprint() ([]byte, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var f Fingerprinter
+	e.btree.ReverseIterate(func(key, value []byte) bool {
+		f.Add(key, value)
+		return true
+	})
+
+	return f.Sum(), nil
+}
+
+// SplitRanges divides the keyspace into n ranges of roughly equal size,
+// sampling keys under the read lock
+func (e *StorageEngine) SplitRanges(n int) ([]KeyRange, error) {
+	sampler, err := newKeySampler(n)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	e.btree.ReverseIterate(func(key, value []byte) bool {
+		sampler.Add(key)
+		return true
+	})
+
+	return sampler.Ranges(), nil
+}
+
+// Scan returns an iterator over the keys in [start, end) as ordered by the
+// engine's comparator. It reads through a Cursor, so writes made while
+// the iterator is open may or may not be seen, but no key is returned
+// twice or out of order.
+func (e *StorageEngine) Scan(start, end []byte) (Iterator, error) {
+	if emptyRange(start, end, e.cmp) {
+		return NewSliceIterator(nil), nil
+	}
+	return &cursorIterator{cursor: e.Cursor(start), end: end, cmp: e.cmp}, nil
+}
+
+// ScanWithConsistency is Scan, or with ScanConsistentPrefix a copy of the
+// pairs in [start, end) read under the read lock, so no write lands
+// part way through the range
+func (e *StorageEngine) ScanWithConsistency(start, end []byte, consistency ScanConsistency) (Iterator, error) {
+	if consistency != ScanConsistentPrefix || emptyRange(start, end, e.cmp) {
+		return e.Scan(start, end)
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var pairs []KV
+	cursor := e.btree.Cursor(start)
+	for cursor.Next() {
+		key := cursor.Key()
+		if end != nil && e.cmp(key, end) >= 0 {
+			break
+		}
+		pairs = append(pairs, KV{
+			Key:   append([]byte(nil), key...),
+			Value: append([]byte(nil), cursor.Value()...),
+		})
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return NewSliceIterator(pairs), nil
+}
+
+// ScanReverse returns an iterator over the keys in [start, end) in
+// descending order. It reads through a reverse Cursor, which may see
+// concurrent writes like Scan.
+func (e *StorageEngine) ScanReverse(start, end []byte) (Iterator, error) {
+	if emptyRange(start, end, e.cmp) {
+		return NewSliceIterator(nil), nil
+	}
+	return &cursorIterator{cursor: e.ReverseCursor(end), start: start, reverse: true, cmp: e.cmp}, nil
+}
+
+// ScanPrefix returns an iterator over the keys beginning with prefix. It
+// seeks to the first key at or after prefix and stops at the first key
+// without it, so it relies on keys sharing a prefix being adjacent; with a
+// comparator that doesn't keep them together, some may be missed.
+func (e *StorageEngine) ScanPrefix(prefix []byte) (Iterator, error) {
+	return &cursorIterator{cursor: e.Cursor(prefix), prefix: prefix, cmp: e.cmp}, nil
+}
+
+// Keys returns every key, walking the tree's leaves in the order of the
+// engine's comparator under the read lock
+func (e *StorageEngine) Keys() ([][]byte, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	keys := make([][]byte, 0, e.btree.Size())
+	cursor := e.btree.Cursor(nil)
+	for cursor.Next() {
+		keys = append(keys, cursor.Key())
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Stats reports the number of keys and the size of the database file and
+// write-ahead log. Changes not yet flushed aren't counted in the sizes.
+func (e *StorageEngine) Stats() (StorageStats, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	info, err := e.file.Stat()
+	if err != nil {
+		return StorageStats{}, err
+	}
+	return StorageStats{
+		KeyCount:  int64(e.btree.Size()),
+		DiskBytes: info.Size() + e.wal.size,
+		Backend:   string(CustomStorage),
+	}, nil
+}
+
+// Limits reports the B+Tree's key and value size limits
+func (e *StorageEngine) Limits() Limits {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	opts := e.btree.Options()
+	return Limits{MaxKeySize: opts.MaxKeySize, MaxValueSize: opts.MaxValueSize}
+}
+
 // Size returns the number of key-value pairs in the storage engine
 func (e *StorageEngine) Size() int {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
 	return e.btree.Size()
-} 
\ No newline at end of file
+} 
+// Cursor iterates over the storage engine in ascending key order.
+// Each step takes the engine's read lock only for its own duration, so
+// writes can run while a cursor is open; see btree.Cursor for how splits
+// and merges made by those writes are handled.
+type Cursor struct {
+	engine *StorageEngine
+	cursor *btree.Cursor
+}
+
+// Cursor returns a cursor starting at the first key greater than or equal
+// to start, or at the smallest key if start is nil. If a concurrent write
+// splits or merges the tree, the cursor re-seeks from the last key it
+// returned, so it never returns a key twice or out of order.
+func (e *StorageEngine) Cursor(start []byte) *Cursor {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return &Cursor{engine: e, cursor: e.btree.Cursor(start)}
+}
+
+// ReverseCursor returns a cursor walking down from the last key less than
+// end, or from the largest key if end is nil. It handles concurrent
+// splits and merges like Cursor.
+func (e *StorageEngine) ReverseCursor(end []byte) *Cursor {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return &Cursor{engine: e, cursor: e.btree.ReverseCursor(end)}
+}
+
+// StrictCursor is like Cursor, but the cursor stops with
+// ErrIterInvalidated if a concurrent write splits or merges the tree.
+func (e *StorageEngine) StrictCursor(start []byte) *Cursor {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return &Cursor{engine: e, cursor: e.btree.StrictCursor(start)}
+}
+
+// Next advances the cursor and reports whether there is a current entry
+func (c *Cursor) Next() bool {
+	c.engine.mu.RLock()
+	defer c.engine.mu.RUnlock()
+
+	return c.cursor.Next()
+}
+
+// Key returns the key of the current entry
+func (c *Cursor) Key() []byte {
+	return c.cursor.Key()
+}
+
+// Value returns the value of the current entry
+func (c *Cursor) Value() []byte {
+	return c.cursor.Value()
+}
+
+// Err returns the error that stopped the cursor, if any
+func (c *Cursor) Err() error {
+	return c.cursor.Err()
+}
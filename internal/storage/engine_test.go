@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"bytes"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestStorageEngine_Basic(t *testing.T) {
@@ -186,4 +190,267 @@ func TestStorageEngine_Size(t *testing.T) {
 	if engine.Size() != 5 {
 		t.Errorf("Expected size 5, got %d", engine.Size())
 	}
-} 
\ No newline at end of file
+} 
+func TestStorageEngine_CheckpointAndCrash(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "db-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	engine, err := NewStorageEngine(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := engine.Put([]byte("key1"), []byte("value1")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := engine.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if err := engine.Put([]byte("key2"), []byte("value2")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	engine.crash()
+
+	// The crashed engine has lost its in-memory state and its file
+	if _, err := engine.Get([]byte("key1")); err == nil {
+		t.Error("Expected in-memory state to be dropped by crash")
+	}
+	if err := engine.Put([]byte("key3"), []byte("value3")); err == nil {
+		t.Error("Expected Put after crash to fail")
+	}
+
+	// The file left behind must still open cleanly
+	reopened, err := NewStorageEngine(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Reopen after crash failed: %v", err)
+	}
+	defer reopened.Close()
+
+	// Both writes were flushed before the crash
+	for _, key := range []string{"key1", "key2"} {
+		if _, err := reopened.Get([]byte(key)); err != nil {
+			t.Errorf("Expected %s to survive the crash, got %v", key, err)
+		}
+	}
+
+	if err := reopened.Checkpoint(); err != nil {
+		t.Errorf("Checkpoint after reopen failed: %v", err)
+	}
+}
+
+func TestStorageEngine_NoAutoFlushIsDurableOnlyAfterSync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	engine, err := NewStorageEngine(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetAutoFlush(false)
+
+	if err := engine.Put([]byte("synced"), []byte("value1")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := engine.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if err := engine.Put([]byte("unsynced"), []byte("value2")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := engine.Delete([]byte("synced")); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	// Unsynced writes are visible before the crash
+	if value, err := engine.Get([]byte("unsynced")); err != nil || string(value) != "value2" {
+		t.Errorf("Expected value2 before the crash, got %q, %v", value, err)
+	}
+
+	engine.crash()
+
+	reopened, err := NewStorageEngine(path)
+	if err != nil {
+		t.Fatalf("Reopen after crash failed: %v", err)
+	}
+	defer reopened.Close()
+
+	// Only the state at the last Sync survived
+	if value, err := reopened.Get([]byte("synced")); err != nil || string(value) != "value1" {
+		t.Errorf("Expected the synced key to survive the crash, got %q, %v", value, err)
+	}
+	if _, err := reopened.Get([]byte("unsynced")); err == nil {
+		t.Error("Expected the unsynced Put to be lost in the crash")
+	}
+}
+
+func TestStorageEngine_CursorDuringMerges(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		tmpfile, err := os.CreateTemp("", "db-*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(tmpfile.Name())
+		tmpfile.Close()
+
+		engine, err := NewStorageEngine(tmpfile.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer engine.Close()
+
+		const n = 2000
+		for i := 0; i < n; i++ {
+			key := []byte(fmt.Sprintf("key_%05d", i))
+			if err := engine.Put(key, []byte(fmt.Sprintf("val_%05d", i))); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+
+		// Delete all but every tenth key while the cursor walks, forcing merges
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := n - 1; i >= 0; i-- {
+				if i%10 != 0 {
+					engine.Delete([]byte(fmt.Sprintf("key_%05d", i)))
+				}
+			}
+		}()
+
+		c := engine.Cursor(nil)
+		if strict {
+			c = engine.StrictCursor(nil)
+		}
+		var prev []byte
+		seen := make(map[string]bool)
+		for c.Next() {
+			key, value := c.Key(), c.Value()
+			if prev != nil && bytes.Compare(key, prev) <= 0 {
+				t.Fatalf("Expected ascending keys, got %s after %s", key, prev)
+			}
+			if !bytes.Equal(value, []byte("val_"+string(key[4:]))) {
+				t.Fatalf("Expected value for %s, got %s", key, value)
+			}
+			prev = key
+			seen[string(key)] = true
+			time.Sleep(50 * time.Microsecond)
+		}
+		<-done
+
+		if strict {
+			if c.Err() != nil && c.Err() != ErrIterInvalidated {
+				t.Errorf("Expected ErrIterInvalidated or nil, got %v", c.Err())
+			}
+			continue
+		}
+		if c.Err() != nil {
+			t.Errorf("Unexpected error: %v", c.Err())
+		}
+		// Keys that were never deleted must all be returned
+		for i := 0; i < n; i += 10 {
+			if key := fmt.Sprintf("key_%05d", i); !seen[key] {
+				t.Errorf("Expected cursor to return %s", key)
+			}
+		}
+	}
+}
+
+func TestStorageEngine_ReopenKeepsData(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "db-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	engine, err := NewStorageEngine(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 500
+	for i := 0; i < n; i++ {
+		if err := engine.Put([]byte(fmt.Sprintf("key_%05d", i)), []byte(fmt.Sprintf("value_%05d", i))); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if engine.btree.Height() == 0 {
+		t.Fatal("Expected the tree to span several pages")
+	}
+	if err := engine.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened, err := NewStorageEngine(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.Size() != n {
+		t.Errorf("Expected size %d, got %d", n, reopened.Size())
+	}
+	for i := 0; i < n; i++ {
+		value, err := reopened.Get([]byte(fmt.Sprintf("key_%05d", i)))
+		if err != nil {
+			t.Fatalf("Get key_%05d failed: %v", i, err)
+		}
+		if want := fmt.Sprintf("value_%05d", i); string(value) != want {
+			t.Errorf("Expected %s, got %s", want, value)
+		}
+	}
+
+	// The reopened tree keeps working, and the next checkpoint shrinks the
+	// file as it empties
+	for i := 0; i < n; i++ {
+		if err := reopened.Delete([]byte(fmt.Sprintf("key_%05d", i))); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	if err := reopened.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	stat, err := os.Stat(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Size() != 2*PAGE_SIZE {
+		t.Errorf("Expected a header and one empty root page, got %d bytes", stat.Size())
+	}
+}
+
+func TestStorageEngine_PutOverwrites(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "db-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	engine, err := NewStorageEngine(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+
+	if err := engine.Put([]byte("k"), []byte("v1")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := engine.Put([]byte("k"), []byte("v2")); err != nil {
+		t.Fatalf("Put over an existing key failed: %v", err)
+	}
+
+	value, err := engine.Get([]byte("k"))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(value) != "v2" {
+		t.Errorf("Expected v2, got %s", value)
+	}
+	if engine.Size() != 1 {
+		t.Errorf("Expected size 1, got %d", engine.Size())
+	}
+}
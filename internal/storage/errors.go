@@ -1,20 +1,50 @@
 package storage
 
-import "errors"
+import (
+	"errors"
+
+	"godatabase/internal/btree"
+)
 
 var (
 	// ErrInvalidStorageType is returned when an invalid storage type is specified
 	ErrInvalidStorageType = errors.New("invalid storage type")
 	
-	// ErrKeyNotFound is returned when a key is not found
-	ErrKeyNotFound = errors.New("key not found")
+	// ErrKeyNotFound is returned when a key is not found. Every backend
+	// returns an error matching it under errors.Is, which callers should
+	// use instead of comparing errors or their text.
+	ErrKeyNotFound = btree.ErrKeyNotFound
 	
-	// ErrKeyExists is returned when a key already exists
-	ErrKeyExists = errors.New("key already exists")
+	// ErrKeyExists is returned when a key already exists. Like
+	// ErrKeyNotFound, match it with errors.Is.
+	ErrKeyExists = btree.ErrKeyExists
 	
 	// ErrInvalidDatabase is returned when the database file is invalid
 	ErrInvalidDatabase = errors.New("invalid database file")
 	
 	// ErrUnsupportedVersion is returned when the database version is not supported
 	ErrUnsupportedVersion = errors.New("unsupported database version")
+	
+	// ErrInvalidLimit is returned when a negative entry count is requested
+	ErrInvalidLimit = errors.New("invalid limit")
+	
+	// ErrNotCounter is returned by Increment when the key holds a value
+	// that isn't an 8-byte counter
+	ErrNotCounter = errors.New("value is not an 8-byte counter")
+	
+	// ErrCounterOverflow is returned by Increment when the new value
+	// doesn't fit in an int64
+	ErrCounterOverflow = errors.New("counter overflow")
+	
+	// ErrNotList is returned by Append and GetList when the key holds a
+	// value that isn't a list of length-prefixed elements
+	ErrNotList = errors.New("value is not a list")
+	
+	// ErrTooLarge is returned when a key or value is larger than the
+	// storage's Limits
+	ErrTooLarge = errors.New("key or value too large")
+	
+	// ErrIterInvalidated is returned by a strict cursor when the tree it
+	// walks is split or merged during iteration
+	ErrIterInvalidated = btree.ErrIterInvalidated
 ) 
\ No newline at end of file
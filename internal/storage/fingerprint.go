@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// FingerprintSize is the length in bytes of a dataset fingerprint
+const FingerprintSize = sha256.Size
+
+// Fingerprinter accumulates an order-independent fingerprint of a set of
+// key-value pairs: the XOR of a SHA-256 hash of each pair. Two stores
+// holding the same pairs have the same fingerprint no matter the order the
+// pairs were written or visited in. Each key must be added at most once.
+type Fingerprinter struct {
+	sum [FingerprintSize]byte
+}
+
+// Add mixes a key-value pair into the fingerprint
+func (f *Fingerprinter) Add(key, value []byte) {
+	// Length-prefix the key so ("ab", "c") and ("a", "bc") differ
+	var keyLen [4]byte
+	binary.BigEndian.PutUint32(keyLen[:], uint32(len(key)))
+
+	h := sha256.New()
+	h.Write(keyLen[:])
+	h.Write(key)
+	h.Write(value)
+	xorInto(f.sum[:], h.Sum(nil))
+}
+
+// Sum returns the fingerprint of the pairs added so far. An empty set has
+// an all-zero fingerprint.
+func (f *Fingerprinter) Sum() []byte {
+	return append([]byte(nil), f.sum[:]...)
+}
+
+// xorInto XORs src into dst, which must be at least as long
+func xorInto(dst, src []byte) {
+	for i := range src {
+		dst[i] ^= src[i]
+	}
+}
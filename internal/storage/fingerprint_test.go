@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestFingerprint_OrderIndependent(t *testing.T) {
+	forward, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer forward.Close()
+	backward, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backward.Close()
+	engine, err := NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+
+	const n = 500
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("key%03d", i))
+		value := []byte(fmt.Sprintf("value%d", i))
+		forward.Put(key, value)
+		engine.Put(key, value)
+	}
+	for i := n - 1; i >= 0; i-- {
+		backward.Put([]byte(fmt.Sprintf("key%03d", i)), []byte(fmt.Sprintf("value%d", i)))
+	}
+
+	fingerprints := make([][]byte, 0, 3)
+	for _, s := range []Storage{forward, backward, engine} {
+		fp, err := s.Fingerprint()
+		if err != nil {
+			t.Fatalf("Fingerprint failed: %v", err)
+		}
+		if len(fp) != FingerprintSize {
+			t.Fatalf("Expected a %d-byte fingerprint, got %d", FingerprintSize, len(fp))
+		}
+		fingerprints = append(fingerprints, fp)
+	}
+	if !bytes.Equal(fingerprints[0], fingerprints[1]) || !bytes.Equal(fingerprints[0], fingerprints[2]) {
+		t.Errorf("Expected equal fingerprints for equal data, got %x, %x and %x",
+			fingerprints[0], fingerprints[1], fingerprints[2])
+	}
+
+	// One extra key sets them apart
+	backward.Put([]byte("extra"), []byte("value"))
+	fp, err := backward.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(fp, fingerprints[0]) {
+		t.Error("Expected fingerprints to diverge after an extra key")
+	}
+
+	// So does a changed value, and removing it again restores the match
+	forward.Put([]byte("key000"), []byte("changed"))
+	if fp, _ := forward.Fingerprint(); bytes.Equal(fp, fingerprints[0]) {
+		t.Error("Expected fingerprints to diverge after a changed value")
+	}
+	backward.Delete([]byte("extra"))
+	if fp, _ := backward.Fingerprint(); !bytes.Equal(fp, fingerprints[0]) {
+		t.Error("Expected fingerprints to match again after deleting the extra key")
+	}
+}
+
+func TestFingerprint_TieredMatchesAcrossTiers(t *testing.T) {
+	flat, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer flat.Close()
+	hot, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cold, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiered, err := NewTieredStorage(hot, cold, TieredConfig{MaxHotKeys: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tiered.Close()
+
+	for i := 0; i < 50; i++ {
+		key := []byte(fmt.Sprintf("key%02d", i))
+		flat.Put(key, []byte("value"))
+		tiered.Put(key, []byte("value"))
+	}
+	tiered.Demote()
+
+	want, _ := flat.Fingerprint()
+	got, err := tiered.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Expected keys split across tiers to fingerprint like one store, got %x, want %x", got, want)
+	}
+}
@@ -2,6 +2,13 @@
 // It includes a custom B+Tree implementation and a BadgerDB wrapper.
 package storage
 
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
 // Storage defines the interface for storage operations
 // Any storage engine implementation must provide these methods.
 type Storage interface {
@@ -9,6 +16,11 @@ type Storage interface {
 	// Returns an error if the operation fails.
 	Put(key, value []byte) error
 	
+	// PutWithTTL stores a key-value pair that expires after ttl. Once it
+	// has expired, Get returns the same error as for a missing key.
+	// Engines that can't expire keys store it like Put, without expiry.
+	PutWithTTL(key, value []byte, ttl time.Duration) error
+	
 	// Get retrieves a value for a given key from the storage engine.
 	// Returns the value and an error (which will be non-nil if the key doesn't exist).
 	Get(key []byte) ([]byte, error)
@@ -17,12 +29,174 @@ type Storage interface {
 	// Returns an error if the operation fails or the key doesn't exist.
 	Delete(key []byte) error
 	
+	// PutCtx, GetCtx and DeleteCtx are Put, Get and Delete bounded by
+	// ctx. If ctx is done before the operation completes they give up and
+	// return ctx.Err(), though a write may still take effect if it was
+	// already submitted. Put, Get and Delete call them with
+	// context.Background().
+	PutCtx(ctx context.Context, key, value []byte) error
+	GetCtx(ctx context.Context, key []byte) ([]byte, error)
+	DeleteCtx(ctx context.Context, key []byte) error
+	
+	// DeleteRange removes every key from start, inclusive, up to end,
+	// exclusive, with the same bounds as Scan, and returns how many keys
+	// it removed. It isn't atomic: if it fails part way, the keys already
+	// removed stay removed and are counted.
+	DeleteRange(start, end []byte) (int, error)
+	
+	// DeleteIf atomically deletes key only if its current value equals expected.
+	// Returns true if the key was deleted, false if it was missing or held another value.
+	DeleteIf(key, expected []byte) (bool, error)
+	
+	// CompareAndSwap atomically sets key to new only if its current value
+	// equals old, or, when old is nil, only if the key is absent. Returns
+	// true if the value was set. An empty but non-nil old matches an empty
+	// value, not a missing key.
+	CompareAndSwap(key, old, new []byte) (bool, error)
+	
+	// Increment atomically adds delta to the counter stored at key, a
+	// big-endian int64 as written by EncodeCounter, and returns the new
+	// value. A missing key counts as 0. The result is stored without
+	// expiry, like Put. Returns ErrNotCounter if the key holds a value
+	// that isn't 8 bytes long, and ErrCounterOverflow if the sum doesn't
+	// fit in an int64.
+	Increment(key []byte, delta int64) (int64, error)
+	
+	// Append atomically adds value to the end of the list stored at key,
+	// creating a one-element list if the key is missing. Lists are stored
+	// as length-prefixed elements, as written by EncodeList, and without
+	// expiry. Returns ErrNotList if the key holds a value that isn't a
+	// list.
+	Append(key, value []byte) error
+	
+	// GetList returns the elements of the list stored at key, in the order
+	// they were appended. Returns ErrKeyNotFound if the key doesn't exist
+	// and ErrNotList if it holds a value that isn't a list.
+	GetList(key []byte) ([][]byte, error)
+	
 	// Close closes the storage engine, flushing any pending changes to disk
 	// and releasing any resources. Returns an error if the operation fails.
 	Close() error
 	
 	// Size returns the number of key-value pairs in the storage engine.
 	Size() int
+	
+	// Tail returns the n largest keys with their values, in descending key order.
+	// It stops after n entries instead of scanning the whole keyspace.
+	// The result is a consistent prefix: it reflects a single point in time,
+	// so no write is visible unless every write before it is visible too.
+	Tail(n int) ([]KV, error)
+	
+	// Fingerprint returns an order-independent hash of every key-value pair,
+	// so two stores holding the same data have the same fingerprint.
+	// See Fingerprinter.
+	Fingerprint() ([]byte, error)
+	
+	// SplitRanges divides the keyspace into n disjoint ranges holding
+	// roughly equal numbers of keys, judged from a sample of the keys,
+	// so the ranges can be scanned in parallel. Together they cover every
+	// key, in ascending order; ranges may be empty if there are few keys.
+	SplitRanges(n int) ([]KeyRange, error)
+	
+	// Scan returns an iterator over the keys from start, inclusive, up to
+	// end, exclusive, in ascending order. A nil start begins at the
+	// smallest key and a nil end runs past the largest one, as in
+	// KeyRange. The caller must close the iterator.
+	Scan(start, end []byte) (Iterator, error)
+	
+	// ScanWithConsistency is Scan with the guarantee chosen by consistency.
+	// Scan is ScanWithConsistency with ScanLive. See ScanConsistency.
+	ScanWithConsistency(start, end []byte, consistency ScanConsistency) (Iterator, error)
+	
+	// ScanReverse returns an iterator over the same keys as Scan, in
+	// descending order. The caller must close the iterator.
+	ScanReverse(start, end []byte) (Iterator, error)
+	
+	// ScanPrefix returns an iterator over the keys that begin with prefix,
+	// in ascending order. An empty prefix matches every key. The caller
+	// must close the iterator.
+	ScanPrefix(prefix []byte) (Iterator, error)
+	
+	// Keys returns every key in ascending order, without reading values.
+	// Like Size, it walks the whole keyspace, so it is meant for debugging
+	// and admin tools rather than regular reads.
+	Keys() ([][]byte, error)
+	
+	// Stats reports how many keys the storage holds, how much disk it
+	// uses and which backend it is. See StorageStats.
+	Stats() (StorageStats, error)
+	
+	// Limits reports the largest keys and values the storage accepts, so
+	// a server can refuse an oversized request before it reaches the
+	// storage. See Limits.
+	Limits() Limits
+	
+	// Backup writes a point-in-time copy of every key-value pair to w
+	// while the storage stays online. The backup is consistent: it holds
+	// no write unless every write before it is included too. Pass it to
+	// Restore to recreate the storage.
+	Backup(w io.Writer) error
+	
+	// NewBatch returns an empty batch of writes to commit together.
+	// See WriteBatch.
+	NewBatch() WriteBatch
+	
+	// Syncer forces acknowledged writes onto durable media, for engines
+	// that don't write each one through as it is made.
+	Syncer
+}
+
+// Syncer forces writes a storage engine has acknowledged onto durable
+// media.
+type Syncer interface {
+	// Sync returns once every write acknowledged before it was called
+	// would survive a crash.
+	Sync() error
+}
+
+// KV is a single key-value pair returned by multi-key reads.
+type KV struct {
+	Key   []byte
+	Value []byte
+}
+
+// StorageStats describes a storage engine for operators.
+type StorageStats struct {
+	// KeyCount is the number of keys, as returned by Size
+	KeyCount int64
+	
+	// DiskBytes is approximately how many bytes the data takes on disk.
+	// Engines that update their size in the background may lag behind
+	// recent writes.
+	DiskBytes int64
+	
+	// Backend names the engine, such as "custom" or "badger"
+	Backend string
+	
+	// Compression names the codec the engine compresses data with, such
+	// as "snappy", "zstd" or "none", or is empty for engines that have no
+	// compression
+	Compression string
+}
+
+// Limits are the largest keys and values a storage engine accepts, in
+// bytes. A zero field means the engine sets no limit of that kind, though
+// the transport in front of it may.
+type Limits struct {
+	MaxKeySize   int
+	MaxValueSize int
+}
+
+// Check returns a descriptive error if key or value is larger than the
+// limits allow
+func (l Limits) Check(key, value []byte) error {
+	if l.MaxKeySize > 0 && len(key) > l.MaxKeySize {
+		return fmt.Errorf("%w: key is %d bytes, more than the %d-byte limit", ErrTooLarge, len(key), l.MaxKeySize)
+	}
+	if l.MaxValueSize > 0 && len(value) > l.MaxValueSize {
+		return fmt.Errorf("%w: value is %d bytes, more than the %d-byte limit", ErrTooLarge, len(value), l.MaxValueSize)
+	}
+	return nil
 }
 
 // StorageType represents the type of storage to use.
@@ -37,12 +211,16 @@ const (
 	// BadgerStorageType uses BadgerDB, a third-party key-value store.
 	// This is a wrapper around github.com/dgraph-io/badger/v3.
 	BadgerStorageType StorageType = "badger"
+	
+	// MemStorageType keeps data in memory only; see MemStorage. The path
+	// passed to NewStorage is ignored.
+	MemStorageType StorageType = "memory"
 )
 
 // NewStorage creates a new storage instance of the specified type.
 // This factory function returns the appropriate storage implementation based on the type.
 // Parameters:
-//   - storageType: The type of storage to create (CustomStorage or BadgerStorageType)
+//   - storageType: The type of storage to create (CustomStorage, BadgerStorageType or MemStorageType)
 //   - path: The path to the storage file/directory
 //
 // Returns:
@@ -54,6 +232,8 @@ func NewStorage(storageType StorageType, path string) (Storage, error) {
 		return NewStorageEngine(path)
 	case BadgerStorageType:
 		return NewBadgerStorage(path)
+	case MemStorageType:
+		return NewMemStorage(), nil
 	default:
 		return nil, ErrInvalidStorageType
 	}
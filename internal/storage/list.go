@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// A list is stored as its elements in order, each one a uvarint length
+// followed by that many bytes, so appending never rewrites the elements
+// already there
+
+// DecodeList reads a value written by Append. It returns ErrNotList if the
+// value isn't a sequence of length-prefixed elements.
+func DecodeList(value []byte) ([][]byte, error) {
+	var elems [][]byte
+	for len(value) > 0 {
+		n, size := binary.Uvarint(value)
+		if size <= 0 || n > uint64(len(value)-size) {
+			return nil, ErrNotList
+		}
+		value = value[size:]
+		elems = append(elems, value[:n:n])
+		value = value[n:]
+	}
+	return elems, nil
+}
+
+// EncodeList returns the stored form of a list holding elems
+func EncodeList(elems ...[]byte) []byte {
+	var list []byte
+	for _, elem := range elems {
+		list = binary.AppendUvarint(list, uint64(len(elem)))
+		list = append(list, elem...)
+	}
+	return list
+}
+
+// AppendToList returns a key's stored list with value appended, or a list
+// of value alone if the key doesn't exist. current is left unchanged.
+// Backends call it between the read and the write of their Append.
+func AppendToList(current []byte, exists bool, value []byte) ([]byte, error) {
+	if exists {
+		if _, err := DecodeList(current); err != nil {
+			return nil, err
+		}
+	}
+	list := make([]byte, 0, len(current)+binary.MaxVarintLen64+len(value))
+	list = append(list, current...)
+	list = binary.AppendUvarint(list, uint64(len(value)))
+	return append(list, value...), nil
+}
+
+// AppendWithCompareAndSwap implements Storage.Append for storage without
+// an atomic read-modify-write of its own, by reading the list and
+// compare-and-swapping the longer one in its place. A swap that loses to a
+// concurrent write is retried from the read.
+func AppendWithCompareAndSwap(s Storage, key, value []byte) error {
+	for {
+		current, err := s.Get(key)
+		if err != nil && !errors.Is(err, ErrKeyNotFound) {
+			return err
+		}
+		exists := err == nil
+
+		list, err := AppendToList(current, exists, value)
+		if err != nil {
+			return err
+		}
+
+		// A nil old only matches a missing key
+		old := current
+		if exists && old == nil {
+			old = []byte{}
+		}
+		swapped, err := s.CompareAndSwap(key, old, list)
+		if err != nil {
+			return err
+		}
+		if swapped {
+			return nil
+		}
+	}
+}
+
+// getList is GetList for storage whose Get returns the stored list
+func getList(s Storage, key []byte) ([][]byte, error) {
+	value, err := s.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeList(value)
+}
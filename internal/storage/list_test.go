@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestAppend_ElementsReadBackInOrder(t *testing.T) {
+	for _, s := range newRangeStores(t) {
+		if _, err := s.GetList([]byte("events")); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("%T: Expected ErrKeyNotFound for a missing list, got %v", s, err)
+		}
+
+		for i := 0; i < 50; i++ {
+			// Include an empty element, which must survive the encoding
+			event := fmt.Sprintf("event-%02d", i)
+			if i == 25 {
+				event = ""
+			}
+			if err := s.Append([]byte("events"), []byte(event)); err != nil {
+				t.Fatalf("%T: Append failed: %v", s, err)
+			}
+		}
+
+		list, err := s.GetList([]byte("events"))
+		if err != nil {
+			t.Fatalf("%T: GetList failed: %v", s, err)
+		}
+		if len(list) != 50 {
+			t.Fatalf("%T: Expected 50 elements, got %d", s, len(list))
+		}
+		for i, elem := range list {
+			want := fmt.Sprintf("event-%02d", i)
+			if i == 25 {
+				want = ""
+			}
+			if string(elem) != want {
+				t.Errorf("%T: Expected %q at %d, got %q", s, want, i, elem)
+			}
+		}
+	}
+}
+
+func TestAppend_RejectsValuesThatAreNotLists(t *testing.T) {
+	for _, s := range newRangeStores(t) {
+		// A length prefix running past the end of the value
+		s.Put([]byte("name"), []byte("alice"))
+		if err := s.Append([]byte("name"), []byte("x")); !errors.Is(err, ErrNotList) {
+			t.Errorf("%T: Expected ErrNotList from Append, got %v", s, err)
+		}
+		if _, err := s.GetList([]byte("name")); !errors.Is(err, ErrNotList) {
+			t.Errorf("%T: Expected ErrNotList from GetList, got %v", s, err)
+		}
+		if value, _ := s.Get([]byte("name")); string(value) != "alice" {
+			t.Errorf("%T: Expected alice, got %q", s, value)
+		}
+
+		// A list written with Put can be appended to
+		s.Put([]byte("list"), EncodeList([]byte("a"), []byte("b")))
+		if err := s.Append([]byte("list"), []byte("c")); err != nil {
+			t.Fatalf("%T: Append failed: %v", s, err)
+		}
+		if list, err := s.GetList([]byte("list")); err != nil || fmt.Sprintf("%s", list) != "[a b c]" {
+			t.Errorf("%T: Expected [a b c], got %s (%v)", s, list, err)
+		}
+	}
+}
+
+func TestAppendWithCompareAndSwap_ConcurrentAppendsAreNotLost(t *testing.T) {
+	s := NewMemStorage()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := AppendWithCompareAndSwap(s, []byte("events"), []byte(fmt.Sprint(i))); err != nil {
+				t.Errorf("Append failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	list, err := s.GetList([]byte("events"))
+	if err != nil {
+		t.Fatalf("GetList failed: %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, elem := range list {
+		seen[string(elem)] = true
+	}
+	if len(list) != 50 || len(seen) != 50 {
+		t.Errorf("Expected 50 distinct elements, got %d (%d distinct)", len(list), len(seen))
+	}
+}
@@ -0,0 +1,443 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memEntry is a value held by MemStorage
+type memEntry struct {
+	value   []byte
+	expires time.Time // zero if the key never expires
+}
+
+// live reports whether the entry hasn't expired at now
+func (e memEntry) live(now time.Time) bool {
+	return e.expires.IsZero() || now.Before(e.expires)
+}
+
+// MemStorage implements the Storage interface in memory, for tests and
+// caches that don't need their data to outlive the process. Values live
+// in a map, with a sorted index of the keys for ordered reads. Expired
+// keys read as missing and are dropped when next written. It is safe for
+// concurrent use.
+type MemStorage struct {
+	mu   sync.RWMutex
+	data map[string]memEntry
+	keys []string // every key in data, in ascending order
+}
+
+// NewMemStorage creates an empty in-memory storage
+func NewMemStorage() *MemStorage {
+	return &MemStorage{data: make(map[string]memEntry)}
+}
+
+// get returns key's value if it exists and hasn't expired. The caller
+// must hold m.mu.
+func (m *MemStorage) get(key []byte, now time.Time) ([]byte, bool) {
+	entry, ok := m.data[string(key)]
+	if !ok || !entry.live(now) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// set stores a copy of value under key. The caller must hold m.mu for
+// writing.
+func (m *MemStorage) set(key, value []byte, expires time.Time) {
+	k := string(key)
+	if _, ok := m.data[k]; !ok {
+		i := sort.SearchStrings(m.keys, k)
+		m.keys = append(m.keys, "")
+		copy(m.keys[i+1:], m.keys[i:])
+		m.keys[i] = k
+	}
+	m.data[k] = memEntry{value: append([]byte{}, value...), expires: expires}
+}
+
+// remove deletes key if it is stored, expired or not. The caller must
+// hold m.mu for writing.
+func (m *MemStorage) remove(key []byte) {
+	k := string(key)
+	if _, ok := m.data[k]; !ok {
+		return
+	}
+	delete(m.data, k)
+	i := sort.SearchStrings(m.keys, k)
+	m.keys = append(m.keys[:i], m.keys[i+1:]...)
+}
+
+// pairs returns copies of the live pairs whose keys are in [start, end),
+// in ascending order. The caller must hold m.mu.
+func (m *MemStorage) pairs(start, end []byte) []KV {
+	now := time.Now()
+	var result []KV
+	for i := sort.SearchStrings(m.keys, string(start)); i < len(m.keys); i++ {
+		k := m.keys[i]
+		if end != nil && k >= string(end) {
+			break
+		}
+		if value, ok := m.get([]byte(k), now); ok {
+			result = append(result, KV{Key: []byte(k), Value: append([]byte{}, value...)})
+		}
+	}
+	return result
+}
+
+// Put stores a key-value pair, replacing the value of an existing key
+func (m *MemStorage) Put(key, value []byte) error {
+	return m.PutCtx(context.Background(), key, value)
+}
+
+// PutCtx is Put bounded by ctx, which is checked before the write begins;
+// writes to memory don't block
+func (m *MemStorage) PutCtx(ctx context.Context, key, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(key, value, time.Time{})
+	return nil
+}
+
+// PutWithTTL stores a key-value pair that expires after ttl
+func (m *MemStorage) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(key, value, time.Now().Add(ttl))
+	return nil
+}
+
+// Get returns a copy of key's value, or ErrKeyNotFound
+func (m *MemStorage) Get(key []byte) ([]byte, error) {
+	return m.GetCtx(context.Background(), key)
+}
+
+// GetCtx is Get bounded by ctx, which is checked before the read begins
+func (m *MemStorage) GetCtx(ctx context.Context, key []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	value, ok := m.get(key, time.Now())
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return append([]byte{}, value...), nil
+}
+
+// Delete removes a key, or returns ErrKeyNotFound
+func (m *MemStorage) Delete(key []byte) error {
+	return m.DeleteCtx(context.Background(), key)
+}
+
+// DeleteCtx is Delete bounded by ctx, which is checked before the delete
+// begins
+func (m *MemStorage) DeleteCtx(ctx context.Context, key []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.get(key, time.Now()); !ok {
+		return ErrKeyNotFound
+	}
+	m.remove(key)
+	return nil
+}
+
+// DeleteRange removes the keys in [start, end), counting those that
+// hadn't expired
+func (m *MemStorage) DeleteRange(start, end []byte) (int, error) {
+	if emptyRange(start, end, bytes.Compare) {
+		return 0, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	from := sort.SearchStrings(m.keys, string(start))
+	to := len(m.keys)
+	if end != nil {
+		to = sort.SearchStrings(m.keys, string(end))
+	}
+	count := 0
+	for _, k := range m.keys[from:to] {
+		if m.data[k].live(now) {
+			count++
+		}
+		delete(m.data, k)
+	}
+	m.keys = append(m.keys[:from], m.keys[to:]...)
+	return count, nil
+}
+
+// DeleteIf removes key if it holds expected
+func (m *MemStorage) DeleteIf(key, expected []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.get(key, time.Now())
+	if !ok || !bytes.Equal(current, expected) {
+		return false, nil
+	}
+	m.remove(key)
+	return true, nil
+}
+
+// CompareAndSwap sets key to new if it holds old, or if old is nil and
+// the key is missing. The new value doesn't expire.
+func (m *MemStorage) CompareAndSwap(key, old, new []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.get(key, time.Now())
+	if !casMatches(current, ok, old) {
+		return false, nil
+	}
+	m.set(key, new, time.Time{})
+	return true, nil
+}
+
+// Increment adds delta to key's counter. The new value doesn't expire.
+func (m *MemStorage) Increment(key []byte, delta int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.get(key, time.Now())
+	n, value, err := AddToCounter(current, ok, delta)
+	if err != nil {
+		return 0, err
+	}
+	m.set(key, value, time.Time{})
+	return n, nil
+}
+
+// Append adds value to key's list. The list doesn't expire.
+func (m *MemStorage) Append(key, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.get(key, time.Now())
+	list, err := AppendToList(current, ok, value)
+	if err != nil {
+		return err
+	}
+	m.set(key, list, time.Time{})
+	return nil
+}
+
+// GetList returns the elements of key's list
+func (m *MemStorage) GetList(key []byte) ([][]byte, error) {
+	return getList(m, key)
+}
+
+// Close discards the data
+func (m *MemStorage) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.data = make(map[string]memEntry)
+	m.keys = nil
+	return nil
+}
+
+// Size returns the number of keys that haven't expired
+func (m *MemStorage) Size() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	count := 0
+	for _, entry := range m.data {
+		if entry.live(now) {
+			count++
+		}
+	}
+	return count
+}
+
+// Tail returns the n largest key-value pairs in descending key order
+func (m *MemStorage) Tail(n int) ([]KV, error) {
+	if n < 0 {
+		return nil, ErrInvalidLimit
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	result := make([]KV, 0, n)
+	for i := len(m.keys) - 1; i >= 0 && len(result) < n; i-- {
+		if value, ok := m.get([]byte(m.keys[i]), now); ok {
+			result = append(result, KV{Key: []byte(m.keys[i]), Value: append([]byte{}, value...)})
+		}
+	}
+	return result, nil
+}
+
+// Fingerprint returns an order-independent hash of every key-value pair
+func (m *MemStorage) Fingerprint() ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	var f Fingerprinter
+	for k, entry := range m.data {
+		if entry.live(now) {
+			f.Add([]byte(k), entry.value)
+		}
+	}
+	return f.Sum(), nil
+}
+
+// SplitRanges divides the keyspace into n ranges of roughly equal size
+func (m *MemStorage) SplitRanges(n int) ([]KeyRange, error) {
+	sampler, err := newKeySampler(n)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	for _, k := range m.keys {
+		if m.data[k].live(now) {
+			sampler.Add([]byte(k))
+		}
+	}
+	return sampler.Ranges(), nil
+}
+
+// Scan returns an iterator over a copy of the pairs in [start, end), so
+// later writes don't affect it
+func (m *MemStorage) Scan(start, end []byte) (Iterator, error) {
+	if emptyRange(start, end, bytes.Compare) {
+		return NewSliceIterator(nil), nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return NewSliceIterator(m.pairs(start, end)), nil
+}
+
+// ScanWithConsistency is Scan for either consistency, since Scan's copy
+// already holds the range as of a single point in time
+func (m *MemStorage) ScanWithConsistency(start, end []byte, consistency ScanConsistency) (Iterator, error) {
+	return m.Scan(start, end)
+}
+
+// ScanReverse returns an iterator over a copy of the pairs in [start, end)
+// in descending order
+func (m *MemStorage) ScanReverse(start, end []byte) (Iterator, error) {
+	if emptyRange(start, end, bytes.Compare) {
+		return NewSliceIterator(nil), nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	pairs := m.pairs(start, end)
+	for i, j := 0, len(pairs)-1; i < j; i, j = i+1, j-1 {
+		pairs[i], pairs[j] = pairs[j], pairs[i]
+	}
+	return NewSliceIterator(pairs), nil
+}
+
+// ScanPrefix returns an iterator over a copy of the pairs whose keys begin
+// with prefix
+func (m *MemStorage) ScanPrefix(prefix []byte) (Iterator, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	pairs := m.pairs(prefix, nil)
+	end := sort.Search(len(pairs), func(i int) bool {
+		return !bytes.HasPrefix(pairs[i].Key, prefix)
+	})
+	return NewSliceIterator(pairs[:end]), nil
+}
+
+// Keys returns every key in ascending order
+func (m *MemStorage) Keys() ([][]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	keys := make([][]byte, 0, len(m.keys))
+	for _, k := range m.keys {
+		if m.data[k].live(now) {
+			keys = append(keys, []byte(k))
+		}
+	}
+	return keys, nil
+}
+
+// Stats reports the number of keys. Nothing is kept on disk.
+func (m *MemStorage) Stats() (StorageStats, error) {
+	return StorageStats{
+		KeyCount: int64(m.Size()),
+		Backend:  string(MemStorageType),
+	}, nil
+}
+
+// Limits reports no limits; any key and value fit in memory
+func (m *MemStorage) Limits() Limits {
+	return Limits{}
+}
+
+// Backup writes every pair as a pairs backup, which Restore turns into a
+// B+Tree engine database file. Expiry times are not kept.
+func (m *MemStorage) Backup(w io.Writer) error {
+	m.mu.RLock()
+	pairs := m.pairs(nil, nil)
+	m.mu.RUnlock()
+
+	return writeBackupPairs(w, NewSliceIterator(pairs))
+}
+
+// Sync implements Syncer. There is nothing to make durable.
+func (m *MemStorage) Sync() error {
+	return nil
+}
+
+// NewBatch returns a batch that is applied atomically under the write lock
+func (m *MemStorage) NewBatch() WriteBatch {
+	return &memBatch{m: m}
+}
+
+// memBatch is a WriteBatch over MemStorage
+type memBatch struct {
+	BatchOps
+	m *MemStorage
+}
+
+// Commit applies every operation at once; readers see all or none of them
+func (b *memBatch) Commit() error {
+	b.m.mu.Lock()
+	defer b.m.mu.Unlock()
+
+	for _, op := range b.Ops {
+		if op.Delete {
+			b.m.remove(op.Key)
+		} else {
+			b.m.set(op.Key, op.Value, time.Time{})
+		}
+	}
+	return nil
+}
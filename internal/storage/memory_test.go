@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemStorage_ExpiredKeysAreHidden(t *testing.T) {
+	store := NewMemStorage()
+
+	store.Put([]byte("cache:a"), []byte("value-cache:a"))
+	store.PutWithTTL([]byte("cache:b"), []byte("value-cache:b"), 50*time.Millisecond)
+	store.PutWithTTL([]byte("cache:c"), []byte("stale"), 50*time.Millisecond)
+	// A plain Put replaces the value and its expiry
+	store.Put([]byte("cache:c"), []byte("value-cache:c"))
+
+	time.Sleep(100 * time.Millisecond)
+
+	if store.Size() != 2 {
+		t.Errorf("Expected 2 live keys, got %d", store.Size())
+	}
+	if _, err := store.Get([]byte("cache:b")); err != ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound for an expired key, got %v", err)
+	}
+	it, err := store.ScanPrefix([]byte("cache:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := collect(t, it); len(keys) != 2 || keys[0] != "cache:a" || keys[1] != "cache:c" {
+		t.Errorf("Expected cache:a and cache:c, got %v", keys)
+	}
+	if pairs, _ := store.Tail(5); len(pairs) != 2 {
+		t.Errorf("Expected Tail to skip the expired key, got %d pairs", len(pairs))
+	}
+	if swapped, _ := store.CompareAndSwap([]byte("cache:b"), nil, []byte("new")); !swapped {
+		t.Error("Expected an expired key to count as absent")
+	}
+}
+
+func TestMemStorage_ScanIsASnapshot(t *testing.T) {
+	store := NewMemStorage()
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("key%d", i)
+		store.Put([]byte(key), []byte("value-"+key))
+	}
+
+	it, err := store.Scan(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Delete([]byte("key0"))
+	store.Put([]byte("key5"), []byte("value-key5"))
+
+	if keys := collect(t, it); len(keys) != 5 || keys[0] != "key0" {
+		t.Errorf("Expected the keys at the time of the scan, got %v", keys)
+	}
+	if stats, _ := store.Stats(); stats.Backend != "memory" || stats.KeyCount != 5 {
+		t.Errorf("Expected 5 keys in the memory backend, got %+v", stats)
+	}
+}
+
+func TestMemStorage_ConcurrentWriters(t *testing.T) {
+	store := NewMemStorage()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := []byte(fmt.Sprintf("w%d-key%03d", w, i))
+				if err := store.Put(key, key); err != nil {
+					t.Errorf("Put failed: %v", err)
+				}
+				if i%2 == 0 {
+					if err := store.Delete(key); err != nil {
+						t.Errorf("Delete failed: %v", err)
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	keys, err := store.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 800 || store.Size() != 800 {
+		t.Fatalf("Expected 800 keys, got %d (size %d)", len(keys), store.Size())
+	}
+	for i := 1; i < len(keys); i++ {
+		if string(keys[i-1]) >= string(keys[i]) {
+			t.Fatalf("Keys out of order: %s before %s", keys[i-1], keys[i])
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/fnv"
+)
+
+// MaxRangeDepth is the deepest split RangeHashes accepts, 2^20 ranges
+const MaxRangeDepth = 20
+
+// RangeOf returns which of the 2^depth key ranges key belongs to. Keys are
+// assigned to ranges by hash rather than by value, so two stores split at
+// the same depth always have identical ranges and can be compared range
+// by range.
+func RangeOf(key []byte, depth int) int {
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() & uint32(1<<depth-1))
+}
+
+// RangeHashes scans s and returns a hash of each of its 2^depth key
+// ranges, see RangeOf. A range's hash covers its pairs in key order, so
+// two stores holding the same pairs in a range hash it the same.
+func RangeHashes(s Storage, depth int) ([][]byte, error) {
+	if depth < 0 || depth > MaxRangeDepth {
+		return nil, fmt.Errorf("invalid range depth: %d", depth)
+	}
+
+	it, err := s.Scan(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	// Scan visits keys in order, so each range's pairs arrive in order
+	hashers := make([]hash.Hash, 1<<depth)
+	var n [4]byte
+	for it.Next() {
+		r := RangeOf(it.Key(), depth)
+		if hashers[r] == nil {
+			hashers[r] = sha256.New()
+		}
+		h := hashers[r]
+
+		// Keys and values are length-prefixed so different pairs can't
+		// produce the same input
+		binary.BigEndian.PutUint32(n[:], uint32(len(it.Key())))
+		h.Write(n[:])
+		h.Write(it.Key())
+		binary.BigEndian.PutUint32(n[:], uint32(len(it.Value())))
+		h.Write(n[:])
+		h.Write(it.Value())
+	}
+	if err := it.Close(); err != nil {
+		return nil, err
+	}
+
+	hashes := make([][]byte, len(hashers))
+	for r, h := range hashers {
+		if h == nil {
+			h = sha256.New()
+		}
+		hashes[r] = h.Sum(nil)
+	}
+	return hashes, nil
+}
+
+// ScanRanges returns an iterator over the pairs of s that fall in the given
+// key ranges of a 2^depth split, in key order
+func ScanRanges(s Storage, depth int, ranges []int) (Iterator, error) {
+	if depth < 0 || depth > MaxRangeDepth {
+		return nil, fmt.Errorf("invalid range depth: %d", depth)
+	}
+	want := make(map[int]bool, len(ranges))
+	for _, r := range ranges {
+		want[r] = true
+	}
+
+	it, err := s.Scan(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &rangeIterator{Iterator: it, depth: depth, want: want}, nil
+}
+
+// rangeIterator skips the pairs of an iterator outside the wanted ranges
+type rangeIterator struct {
+	Iterator
+	depth int
+	want  map[int]bool
+}
+
+func (it *rangeIterator) Next() bool {
+	for it.Iterator.Next() {
+		if it.want[RangeOf(it.Key(), it.depth)] {
+			return true
+		}
+	}
+	return false
+}
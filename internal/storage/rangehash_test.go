@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestRangeHashes_FindDifferingRange(t *testing.T) {
+	a, b := NewMemStorage(), NewMemStorage()
+	defer a.Close()
+	defer b.Close()
+
+	for i := 0; i < 200; i++ {
+		key := []byte(fmt.Sprintf("key%03d", i))
+		a.Put(key, []byte("value"))
+		b.Put(key, []byte("value"))
+	}
+	b.Put([]byte("key100"), []byte("changed"))
+
+	const depth = 4
+	hashesA, err := RangeHashes(a, depth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashesB, err := RangeHashes(b, depth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashesA) != 1<<depth || len(hashesB) != 1<<depth {
+		t.Fatalf("Expected %d hashes, got %d and %d", 1<<depth, len(hashesA), len(hashesB))
+	}
+
+	var differing []int
+	for r := range hashesA {
+		if !bytes.Equal(hashesA[r], hashesB[r]) {
+			differing = append(differing, r)
+		}
+	}
+	if want := RangeOf([]byte("key100"), depth); len(differing) != 1 || differing[0] != want {
+		t.Fatalf("Expected only range %d to differ, got %v", want, differing)
+	}
+
+	// Only the differing range's keys are scanned, in order
+	it, err := ScanRanges(b, depth, differing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs, err := Collect(it)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for i, kv := range pairs {
+		if RangeOf(kv.Key, depth) != differing[0] {
+			t.Errorf("Expected only keys in range %d, got %s", differing[0], kv.Key)
+		}
+		if i > 0 && bytes.Compare(pairs[i-1].Key, kv.Key) >= 0 {
+			t.Errorf("Expected keys in order, got %s after %s", kv.Key, pairs[i-1].Key)
+		}
+		found = found || string(kv.Key) == "key100"
+	}
+	if !found || len(pairs) >= 200 {
+		t.Errorf("Expected the range holding key100 alone, got %d pairs", len(pairs))
+	}
+
+	if _, err := RangeHashes(a, MaxRangeDepth+1); err == nil {
+		t.Error("Expected too deep a split to be rejected")
+	}
+}
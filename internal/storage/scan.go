@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"bytes"
+)
+
+// Iterator walks key-value pairs in key order, ascending except for the
+// iterators returned by ScanReverse. It starts before the first pair, so
+// Next must be called before Key and Value. The slices
+// returned by Key and Value belong to the caller and may be kept.
+//
+// An iterator must be closed when the caller is done with it, whether or
+// not it was read to the end.
+type Iterator interface {
+	// Next advances to the next pair and reports whether there is one.
+	// It returns false at the end of the range or if iteration failed.
+	Next() bool
+
+	// Key returns the key of the current pair
+	Key() []byte
+
+	// Value returns the value of the current pair
+	Value() []byte
+
+	// Close releases the iterator's resources. It returns the error that
+	// stopped iteration early, if any, so a false Next followed by a nil
+	// Close means the whole range was read.
+	Close() error
+}
+
+// ScanConsistency chooses what a scan guarantees about writes made while
+// it runs
+type ScanConsistency int
+
+const (
+	// ScanLive reads the data as the iterator advances, so writes made
+	// while it is open may or may not be seen. This is what Scan does.
+	ScanLive ScanConsistency = iota
+
+	// ScanConsistentPrefix returns the range as of a single point in time,
+	// like Tail: no write is visible unless every write before it is
+	// visible too. Engines without snapshots read the range into memory
+	// to provide it.
+	ScanConsistentPrefix
+)
+
+// Collect reads the rest of it into memory, copying each pair, and closes
+// it. It returns the error that stopped iteration, if any.
+func Collect(it Iterator) ([]KV, error) {
+	var pairs []KV
+	for it.Next() {
+		pairs = append(pairs, KV{
+			Key:   append([]byte(nil), it.Key()...),
+			Value: append([]byte(nil), it.Value()...),
+		})
+	}
+	if err := it.Close(); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// sliceIterator iterates over pairs already read into memory
+type sliceIterator struct {
+	pairs []KV
+	pos   int
+}
+
+// NewSliceIterator returns an iterator over pairs, which must already be
+// in the iterator's key order
+func NewSliceIterator(pairs []KV) Iterator {
+	return &sliceIterator{pairs: pairs, pos: -1}
+}
+
+func (it *sliceIterator) Next() bool {
+	if it.pos+1 >= len(it.pairs) {
+		it.pos = len(it.pairs)
+		return false
+	}
+	it.pos++
+	return true
+}
+
+func (it *sliceIterator) Key() []byte {
+	return it.pairs[it.pos].Key
+}
+
+func (it *sliceIterator) Value() []byte {
+	return it.pairs[it.pos].Value
+}
+
+func (it *sliceIterator) Close() error {
+	return nil
+}
+
+// cursorIterator adapts a StorageEngine cursor to an Iterator, stopping
+// before the end bound or at the first key without the prefix. Over a
+// reverse cursor, which starts before the end bound itself, it stops
+// after the start bound instead.
+type cursorIterator struct {
+	cursor  *Cursor
+	start   []byte
+	end     []byte
+	prefix  []byte
+	reverse bool
+	cmp     func(a, b []byte) int
+	done    bool
+}
+
+func (it *cursorIterator) Next() bool {
+	if it.done {
+		return false
+	}
+	if !it.cursor.Next() {
+		it.done = true
+		return false
+	}
+	key := it.cursor.Key()
+	if it.reverse {
+		it.done = it.start != nil && it.cmp(key, it.start) < 0
+	} else {
+		it.done = (it.end != nil && it.cmp(key, it.end) >= 0) || !bytes.HasPrefix(key, it.prefix)
+	}
+	return !it.done
+}
+
+func (it *cursorIterator) Key() []byte {
+	return it.cursor.Key()
+}
+
+func (it *cursorIterator) Value() []byte {
+	return it.cursor.Value()
+}
+
+func (it *cursorIterator) Close() error {
+	it.done = true
+	return it.cursor.Err()
+}
+
+// mergeIterator merges two iterators into one ascending sequence, or one
+// descending sequence if both are descending. When both hold the same key,
+// the pair from first is returned and the one from second is skipped.
+type mergeIterator struct {
+	first, second     Iterator
+	firstOK, secondOK bool
+	started           bool
+	reverse           bool
+	key, value        []byte
+}
+
+func newMergeIterator(first, second Iterator) *mergeIterator {
+	return &mergeIterator{first: first, second: second}
+}
+
+// newReverseMergeIterator merges two descending iterators
+func newReverseMergeIterator(first, second Iterator) *mergeIterator {
+	return &mergeIterator{first: first, second: second, reverse: true}
+}
+
+func (it *mergeIterator) Next() bool {
+	if !it.started {
+		it.firstOK = it.first.Next()
+		it.secondOK = it.second.Next()
+		it.started = true
+	}
+
+	switch {
+	case it.firstOK && it.secondOK:
+		c := bytes.Compare(it.first.Key(), it.second.Key())
+		if it.reverse {
+			c = -c
+		}
+		if c <= 0 {
+			it.key, it.value = it.first.Key(), it.first.Value()
+			it.firstOK = it.first.Next()
+			if c == 0 {
+				it.secondOK = it.second.Next()
+			}
+		} else {
+			it.key, it.value = it.second.Key(), it.second.Value()
+			it.secondOK = it.second.Next()
+		}
+	case it.firstOK:
+		it.key, it.value = it.first.Key(), it.first.Value()
+		it.firstOK = it.first.Next()
+	case it.secondOK:
+		it.key, it.value = it.second.Key(), it.second.Value()
+		it.secondOK = it.second.Next()
+	default:
+		return false
+	}
+	return true
+}
+
+func (it *mergeIterator) Key() []byte {
+	return it.key
+}
+
+func (it *mergeIterator) Value() []byte {
+	return it.value
+}
+
+// Close closes both iterators and returns the first error
+func (it *mergeIterator) Close() error {
+	firstErr := it.first.Close()
+	if err := it.second.Close(); err != nil && firstErr == nil {
+		return err
+	}
+	return firstErr
+}
+
+// emptyRange reports whether no key can fall within [start, end) as
+// ordered by cmp
+func emptyRange(start, end []byte, cmp func(a, b []byte) int) bool {
+	return end != nil && cmp(start, end) >= 0
+}
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// collect reads every key from it and closes it
+func collect(t *testing.T, it Iterator) []string {
+	var keys []string
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+		if want := "value-" + string(it.Key()); string(it.Value()) != want {
+			t.Errorf("Expected %s, got %s", want, it.Value())
+		}
+	}
+	if err := it.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	return keys
+}
+
+func TestScan_ReturnsRangeInOrder(t *testing.T) {
+	engine, err := NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	badgerStore, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer badgerStore.Close()
+	mem := NewMemStorage()
+
+	// Insert out of order
+	for _, s := range []Storage{engine, badgerStore, mem} {
+		for _, i := range []int{5, 1, 9, 3, 7, 2, 8, 4, 6} {
+			key := fmt.Sprintf("k%d", i)
+			if err := s.Put([]byte(key), []byte("value-"+key)); err != nil {
+				t.Fatalf("%T: Put failed: %v", s, err)
+			}
+		}
+	}
+
+	tests := []struct {
+		start, end string
+		unbounded  bool
+		want       string
+	}{
+		{"k3", "k7", false, "[k3 k4 k5 k6]"},
+		{"k0", "k3", false, "[k1 k2]"},
+		{"k7", "", true, "[k7 k8 k9]"},
+		{"k5", "k5", false, "[]"},
+		{"k7", "k3", false, "[]"},
+	}
+	for _, s := range []Storage{engine, badgerStore, mem} {
+		for _, tt := range tests {
+			var end []byte
+			if !tt.unbounded {
+				end = []byte(tt.end)
+			}
+			it, err := s.Scan([]byte(tt.start), end)
+			if err != nil {
+				t.Fatalf("%T: Scan failed: %v", s, err)
+			}
+			if got := fmt.Sprint(collect(t, it)); got != tt.want {
+				t.Errorf("%T: Scan [%s, %s): expected %s, got %s", s, tt.start, tt.end, tt.want, got)
+			}
+		}
+
+		it, err := s.Scan(nil, nil)
+		if err != nil {
+			t.Fatalf("%T: Scan failed: %v", s, err)
+		}
+		if got := collect(t, it); len(got) != 9 {
+			t.Errorf("%T: Expected every key from an unbounded scan, got %v", s, got)
+		}
+	}
+}
+
+func TestScan_TieredMergesTiers(t *testing.T) {
+	hot, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cold, err := NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewTieredStorage(hot, cold, TieredConfig{MaxHotKeys: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for i := 1; i <= 9; i++ {
+		key := fmt.Sprintf("k%d", i)
+		s.Put([]byte(key), []byte("value-"+key))
+	}
+	s.Demote()
+	if hot.Size() == 0 || cold.Size() == 0 {
+		t.Fatalf("Expected keys in both tiers, got %d hot and %d cold", hot.Size(), cold.Size())
+	}
+
+	it, err := s.Scan([]byte("k3"), []byte("k7"))
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if got := fmt.Sprint(collect(t, it)); got != "[k3 k4 k5 k6]" {
+		t.Errorf("Expected [k3 k4 k5 k6], got %s", got)
+	}
+}
+
+func TestScanPrefix_BothBackends(t *testing.T) {
+	engine, err := NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	badgerStore, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer badgerStore.Close()
+	mem := NewMemStorage()
+
+	// Neighbours on both sides of the "user:" keys
+	keys := []string{"user:2", "config:db", "user", "user:1", "user;", "usera", "user:10"}
+	for _, s := range []Storage{engine, badgerStore, mem} {
+		for _, key := range keys {
+			if err := s.Put([]byte(key), []byte("value-"+key)); err != nil {
+				t.Fatalf("%T: Put failed: %v", s, err)
+			}
+		}
+	}
+
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"user:", "[user:1 user:10 user:2]"},
+		{"config:", "[config:db]"},
+		{"user:1", "[user:1 user:10]"},
+		{"", "[config:db user user:1 user:10 user:2 user; usera]"},
+		{"zzz", "[]"},
+		{"a", "[]"},
+	}
+	for _, s := range []Storage{engine, badgerStore, mem} {
+		for _, tt := range tests {
+			it, err := s.ScanPrefix([]byte(tt.prefix))
+			if err != nil {
+				t.Fatalf("%T: ScanPrefix failed: %v", s, err)
+			}
+			if got := fmt.Sprint(collect(t, it)); got != tt.want {
+				t.Errorf("%T: ScanPrefix %q: expected %s, got %s", s, tt.prefix, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestKeys_ReturnsEveryKeySorted(t *testing.T) {
+	engine, err := NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	badgerStore, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer badgerStore.Close()
+	mem := NewMemStorage()
+
+	// Insert 20 keys out of order
+	var want []string
+	for i := 0; i < 20; i++ {
+		want = append(want, fmt.Sprintf("key%02d", i))
+	}
+	for _, s := range []Storage{engine, badgerStore, mem} {
+		for i := 0; i < 20; i++ {
+			key := want[(i*7)%20]
+			if err := s.Put([]byte(key), []byte("value-"+key)); err != nil {
+				t.Fatalf("%T: Put failed: %v", s, err)
+			}
+		}
+	}
+	// Badger doesn't list a key that has expired
+	if err := badgerStore.PutWithTTL([]byte("expired"), []byte("value"), time.Nanosecond); err != nil {
+		t.Fatalf("PutWithTTL failed: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	for _, s := range []Storage{engine, badgerStore, mem} {
+		keys, err := s.Keys()
+		if err != nil {
+			t.Fatalf("%T: Keys failed: %v", s, err)
+		}
+		var got []string
+		for _, key := range keys {
+			got = append(got, string(key))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%T: Expected %v, got %v", s, want, got)
+		}
+	}
+}
+
+func TestScanReverse_AllBackends(t *testing.T) {
+	for _, s := range newRangeStores(t) {
+		for i := 1; i <= 5; i++ {
+			key := fmt.Sprintf("k%d", i)
+			if err := s.Put([]byte(key), []byte("value-"+key)); err != nil {
+				t.Fatalf("%T: Put failed: %v", s, err)
+			}
+		}
+		if tiered, ok := s.(*TieredStorage); ok {
+			// Spread the keys over both tiers
+			tiered.Demote()
+		}
+
+		tests := []struct {
+			start, end []byte
+			want       string
+		}{
+			{nil, nil, "[k5 k4 k3 k2 k1]"},
+			{[]byte("k2"), []byte("k4"), "[k3 k2]"},
+			{[]byte("k2"), nil, "[k5 k4 k3 k2]"},
+			{nil, []byte("k3"), "[k2 k1]"},
+			{[]byte("k0"), []byte("k9"), "[k5 k4 k3 k2 k1]"},
+			{[]byte("k4"), []byte("k2"), "[]"},
+		}
+		for _, tt := range tests {
+			it, err := s.ScanReverse(tt.start, tt.end)
+			if err != nil {
+				t.Fatalf("%T: ScanReverse failed: %v", s, err)
+			}
+			if got := fmt.Sprint(collect(t, it)); got != tt.want {
+				t.Errorf("%T: ScanReverse [%s, %s): expected %s, got %s", s, tt.start, tt.end, tt.want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// splitSamplesPerRange is how many keys are sampled for each range
+// SplitRanges returns. More samples give more even ranges.
+const splitSamplesPerRange = 64
+
+// KeyRange is a half-open range of keys: Start is included and End is
+// not. A nil Start begins at the smallest key and a nil End runs past the
+// largest one.
+type KeyRange struct {
+	Start []byte
+	End   []byte
+}
+
+// Contains reports whether key falls within the range
+func (r KeyRange) Contains(key []byte) bool {
+	if r.Start != nil && bytes.Compare(key, r.Start) < 0 {
+		return false
+	}
+	return r.End == nil || bytes.Compare(key, r.End) < 0
+}
+
+// keySampler picks a uniform random sample of the keys added to it
+// (reservoir sampling), from which it splits the keyspace into ranges
+type keySampler struct {
+	n       int
+	samples [][]byte
+	seen    int
+	rng     *rand.Rand
+}
+
+// newKeySampler returns a sampler for splitting into n ranges. The seed is
+// fixed so splitting the same data gives the same ranges.
+func newKeySampler(n int) (*keySampler, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("%w: cannot split into %d ranges", ErrInvalidLimit, n)
+	}
+	return &keySampler{
+		n:   n,
+		rng: rand.New(rand.NewSource(1)),
+	}, nil
+}
+
+// Add offers a key to the sample. The key is copied only if it is kept.
+func (s *keySampler) Add(key []byte) {
+	s.seen++
+	if len(s.samples) < s.n*splitSamplesPerRange {
+		s.samples = append(s.samples, append([]byte(nil), key...))
+		return
+	}
+	if i := s.rng.Intn(s.seen); i < len(s.samples) {
+		s.samples[i] = append([]byte(nil), key...)
+	}
+}
+
+// Ranges returns n ranges covering the whole keyspace, each holding about
+// the same share of the sampled keys
+func (s *keySampler) Ranges() []KeyRange {
+	sort.Slice(s.samples, func(i, j int) bool {
+		return bytes.Compare(s.samples[i], s.samples[j]) < 0
+	})
+
+	boundaries := make([][]byte, s.n-1)
+	for i := range boundaries {
+		if len(s.samples) == 0 {
+			boundaries[i] = []byte{}
+			continue
+		}
+		boundaries[i] = s.samples[(i+1)*len(s.samples)/s.n]
+	}
+	return rangesBetween(boundaries)
+}
+
+// rangesBetween returns the ranges separated by the given ascending,
+// non-nil boundaries: one more range than there are boundaries. Repeated
+// boundaries give empty ranges, so together the ranges always cover every
+// key exactly once.
+func rangesBetween(boundaries [][]byte) []KeyRange {
+	ranges := make([]KeyRange, 0, len(boundaries)+1)
+	var start []byte
+	for _, end := range boundaries {
+		ranges = append(ranges, KeyRange{Start: start, End: end})
+		start = end
+	}
+	return append(ranges, KeyRange{Start: start})
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// scanRange returns the keys of s within r in ascending order
+func scanRange(t *testing.T, s Storage, r KeyRange) []string {
+	it, err := s.Scan(r.Start, r.End)
+	if err != nil {
+		// Called from the scanning goroutines, so it can't stop the test
+		t.Errorf("%T: Scan failed: %v", s, err)
+		return nil
+	}
+	var keys []string
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	if err := it.Close(); err != nil {
+		t.Error(err)
+	}
+	return keys
+}
+
+func TestSplitRanges_ParallelScansCoverEveryKeyOnce(t *testing.T) {
+	badgerStore, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer badgerStore.Close()
+	engine, err := NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+
+	const total = 1000
+	var want []string
+	for i := 0; i < total; i++ {
+		key := fmt.Sprintf("key%04d", i)
+		want = append(want, key)
+		badgerStore.Put([]byte(key), []byte("value"))
+		engine.Put([]byte(key), []byte("value"))
+	}
+
+	for _, s := range []Storage{badgerStore, engine} {
+		ranges, err := s.SplitRanges(4)
+		if err != nil {
+			t.Fatalf("%T: SplitRanges failed: %v", s, err)
+		}
+		if len(ranges) != 4 {
+			t.Fatalf("%T: Expected 4 ranges, got %d", s, len(ranges))
+		}
+		if ranges[0].Start != nil || ranges[3].End != nil {
+			t.Errorf("%T: Expected the outer ranges to be unbounded, got %q and %q", s, ranges[0].Start, ranges[3].End)
+		}
+
+		parts := make([][]string, len(ranges))
+		var wg sync.WaitGroup
+		for i, r := range ranges {
+			wg.Add(1)
+			go func(i int, r KeyRange) {
+				defer wg.Done()
+				parts[i] = scanRange(t, s, r)
+			}(i, r)
+		}
+		wg.Wait()
+
+		seen := make(map[string]int)
+		var got []string
+		for i, part := range parts {
+			// Sampling only approximates the split
+			if len(part) < total/8 || len(part) > total/2 {
+				t.Errorf("%T: Expected range %d to hold about %d keys, got %d", s, i, total/4, len(part))
+			}
+			for _, key := range part {
+				if !ranges[i].Contains([]byte(key)) {
+					t.Errorf("%T: Range %d returned %s outside it", s, i, key)
+				}
+				seen[key]++
+				got = append(got, key)
+			}
+		}
+		for key, count := range seen {
+			if count != 1 {
+				t.Errorf("%T: Expected %s in one range, found in %d", s, key, count)
+			}
+		}
+		sort.Strings(got)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%T: Expected the ranges to cover all %d keys, got %d", s, total, len(got))
+		}
+	}
+}
+
+func TestSplitRanges_EmptyStoreAndBadCount(t *testing.T) {
+	engine, err := NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+
+	ranges, err := engine.SplitRanges(3)
+	if err != nil {
+		t.Fatalf("SplitRanges failed: %v", err)
+	}
+	if len(ranges) != 3 {
+		t.Fatalf("Expected 3 ranges, got %d", len(ranges))
+	}
+
+	// Keys written later fall in exactly one of the ranges
+	for _, key := range []string{"", "a", "zzz"} {
+		matches := 0
+		for _, r := range ranges {
+			if r.Contains([]byte(key)) {
+				matches++
+			}
+		}
+		if matches != 1 {
+			t.Errorf("Expected %q in one range, found in %d", key, matches)
+		}
+	}
+
+	if _, err := engine.SplitRanges(0); !errors.Is(err, ErrInvalidLimit) {
+		t.Errorf("Expected ErrInvalidLimit, got %v", err)
+	}
+}
+
+func TestSplitRanges_TieredWeighsBothTiers(t *testing.T) {
+	hot, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cold, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewTieredStorage(hot, cold, TieredConfig{MaxHotKeys: 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	const total = 1000
+	for i := 0; i < total; i++ {
+		s.Put([]byte(fmt.Sprintf("key%04d", i)), []byte("value"))
+	}
+	// The oldest keys go cold, so each tier holds one end of the keyspace
+	s.Demote()
+
+	ranges, err := s.SplitRanges(4)
+	if err != nil {
+		t.Fatalf("SplitRanges failed: %v", err)
+	}
+	counts := make([]int, len(ranges))
+	for i := 0; i < total; i++ {
+		for j, r := range ranges {
+			if r.Contains([]byte(fmt.Sprintf("key%04d", i))) {
+				counts[j]++
+			}
+		}
+	}
+	for i, count := range counts {
+		if count < total/8 || count > total/2 {
+			t.Errorf("Expected range %d to hold about %d keys, got %v", i, total/4, counts)
+			break
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestStats_KeyCountFollowsWrites(t *testing.T) {
+	engine, err := NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	badgerStore, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer badgerStore.Close()
+	mem := NewMemStorage()
+
+	for _, s := range []Storage{engine, badgerStore, mem} {
+		for i := 0; i < 10; i++ {
+			if err := s.Put([]byte(fmt.Sprintf("key%d", i)), []byte("value")); err != nil {
+				t.Fatalf("%T: Put failed: %v", s, err)
+			}
+		}
+		// Overwriting a key doesn't add one
+		if err := s.Put([]byte("key0"), []byte("new")); err != nil {
+			t.Fatalf("%T: Put failed: %v", s, err)
+		}
+		for i := 0; i < 3; i++ {
+			if err := s.Delete([]byte(fmt.Sprintf("key%d", i))); err != nil {
+				t.Fatalf("%T: Delete failed: %v", s, err)
+			}
+		}
+
+		stats, err := s.Stats()
+		if err != nil {
+			t.Fatalf("%T: Stats failed: %v", s, err)
+		}
+		if stats.KeyCount != 7 {
+			t.Errorf("%T: Expected 7 keys, got %d", s, stats.KeyCount)
+		}
+		if stats.DiskBytes < 0 {
+			t.Errorf("%T: Expected a disk size, got %d", s, stats.DiskBytes)
+		}
+	}
+
+	if stats, _ := engine.Stats(); stats.Backend != "custom" || stats.DiskBytes == 0 {
+		t.Errorf("Expected the custom backend with a database file, got %+v", stats)
+	}
+	if stats, _ := badgerStore.Stats(); stats.Backend != "badger" {
+		t.Errorf("Expected the badger backend, got %q", stats.Backend)
+	}
+}
+
+func TestStats_TieredAddsTiers(t *testing.T) {
+	hot, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cold, err := NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewTieredStorage(hot, cold, TieredConfig{MaxHotKeys: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 9; i++ {
+		s.Put([]byte(fmt.Sprintf("key%d", i)), []byte("value"))
+	}
+	s.Demote()
+	s.Delete([]byte("key0"))
+
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.KeyCount != 8 {
+		t.Errorf("Expected 8 keys, got %d", stats.KeyCount)
+	}
+	if stats.Backend != "tiered(badger, custom)" {
+		t.Errorf("Expected tiered(badger, custom), got %q", stats.Backend)
+	}
+}
@@ -1,9 +1,14 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
 )
 
 func setupTest(t *testing.T) (string, func()) {
@@ -144,4 +149,274 @@ func TestBadgerStorage(t *testing.T) {
 
 	path := filepath.Join(testDir, "badger.db")
 	testStorageImplementation(t, BadgerStorageType, path)
-} 
\ No newline at end of file
+}
+
+func TestMemStorage(t *testing.T) {
+	testStorageImplementation(t, MemStorageType, "")
+} 
+func TestStorage_Tail(t *testing.T) {
+	testDir, cleanup := setupTest(t)
+	defer cleanup()
+
+	for _, storageType := range []StorageType{CustomStorage, BadgerStorageType, MemStorageType} {
+		t.Run(string(storageType), func(t *testing.T) {
+			s, err := NewStorage(storageType, filepath.Join(testDir, "tail-"+string(storageType)))
+			if err != nil {
+				t.Fatalf("Failed to create storage: %v", err)
+			}
+			defer s.Close()
+
+			// Insert ordered keys
+			for i := 0; i < 1000; i++ {
+				key := []byte(fmt.Sprintf("key%04d", i))
+				if err := s.Put(key, []byte(fmt.Sprintf("value%d", i))); err != nil {
+					t.Fatalf("Put failed: %v", err)
+				}
+			}
+
+			pairs, err := s.Tail(10)
+			if err != nil {
+				t.Fatalf("Tail failed: %v", err)
+			}
+			if len(pairs) != 10 {
+				t.Fatalf("Expected 10 pairs, got %d", len(pairs))
+			}
+
+			// Expect key0999 down to key0990
+			for i, kv := range pairs {
+				n := 999 - i
+				if string(kv.Key) != fmt.Sprintf("key%04d", n) {
+					t.Errorf("Expected key%04d at position %d, got %s", n, i, kv.Key)
+				}
+				if string(kv.Value) != fmt.Sprintf("value%d", n) {
+					t.Errorf("Expected value%d at position %d, got %s", n, i, kv.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestStorage_DeleteIf(t *testing.T) {
+	testDir, cleanup := setupTest(t)
+	defer cleanup()
+
+	for _, storageType := range []StorageType{CustomStorage, BadgerStorageType, MemStorageType} {
+		t.Run(string(storageType), func(t *testing.T) {
+			s, err := NewStorage(storageType, filepath.Join(testDir, "deleteif-"+string(storageType)))
+			if err != nil {
+				t.Fatalf("Failed to create storage: %v", err)
+			}
+			defer s.Close()
+
+			if err := s.Put([]byte("key"), []byte("value")); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+
+			// Mismatched value leaves the key alone
+			deleted, err := s.DeleteIf([]byte("key"), []byte("other"))
+			if err != nil {
+				t.Fatalf("DeleteIf failed: %v", err)
+			}
+			if deleted {
+				t.Error("Expected no delete for mismatched value")
+			}
+			if value, err := s.Get([]byte("key")); err != nil || string(value) != "value" {
+				t.Errorf("Expected key to still hold value, got %s (%v)", value, err)
+			}
+
+			// Matching value deletes the key
+			deleted, err = s.DeleteIf([]byte("key"), []byte("value"))
+			if err != nil {
+				t.Fatalf("DeleteIf failed: %v", err)
+			}
+			if !deleted {
+				t.Error("Expected delete for matching value")
+			}
+			if _, err := s.Get([]byte("key")); err == nil {
+				t.Error("Expected key to be deleted")
+			}
+
+			// Missing key is never deleted
+			deleted, err = s.DeleteIf([]byte("missing"), []byte("value"))
+			if err != nil {
+				t.Fatalf("DeleteIf on missing key failed: %v", err)
+			}
+			if deleted {
+				t.Error("Expected no delete for missing key")
+			}
+		})
+	}
+}
+
+func TestStorage_TailConsistentPrefix(t *testing.T) {
+	testDir, cleanup := setupTest(t)
+	defer cleanup()
+
+	const keys = 20
+	const rounds = 50
+
+	for _, storageType := range []StorageType{CustomStorage, BadgerStorageType, MemStorageType} {
+		t.Run(string(storageType), func(t *testing.T) {
+			s, err := NewStorage(storageType, filepath.Join(testDir, "prefix-"+string(storageType)))
+			if err != nil {
+				t.Fatalf("Failed to create storage: %v", err)
+			}
+			defer s.Close()
+
+			for k := 0; k < keys; k++ {
+				if err := s.Put([]byte(fmt.Sprintf("key%02d", k)), []byte("0")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Each round rewrites every key in order with the round number
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for round := 1; round <= rounds; round++ {
+					for k := 0; k < keys; k++ {
+						s.Put([]byte(fmt.Sprintf("key%02d", k)), []byte(fmt.Sprintf("%d", round)))
+					}
+				}
+			}()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				pairs, err := s.Tail(keys)
+				if err != nil {
+					t.Fatalf("Tail failed: %v", err)
+				}
+
+				// Walking keys in write order, rounds may only step down by one,
+				// once: a later write must never show without an earlier one.
+				var rounds []int
+				for i := len(pairs) - 1; i >= 0; i-- {
+					var r int
+					fmt.Sscanf(string(pairs[i].Value), "%d", &r)
+					rounds = append(rounds, r)
+				}
+				for i := 1; i < len(rounds); i++ {
+					if rounds[i] > rounds[i-1] || rounds[0]-rounds[i] > 1 {
+						t.Fatalf("Torn view: %v", rounds)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestStorage_MissingKeyIsErrKeyNotFound(t *testing.T) {
+	testDir, cleanup := setupTest(t)
+	defer cleanup()
+
+	for _, storageType := range []StorageType{CustomStorage, BadgerStorageType, MemStorageType} {
+		t.Run(string(storageType), func(t *testing.T) {
+			s, err := NewStorage(storageType, filepath.Join(testDir, "missing-"+string(storageType)))
+			if err != nil {
+				t.Fatalf("Failed to create storage: %v", err)
+			}
+			defer s.Close()
+
+			if _, err := s.Get([]byte("missing")); !errors.Is(err, ErrKeyNotFound) {
+				t.Errorf("Expected ErrKeyNotFound for a missing key, got %v", err)
+			}
+
+			// An expired key reads like a missing one, on engines that
+			// expire keys
+			if storageType == CustomStorage {
+				return
+			}
+			s.PutWithTTL([]byte("expiring"), []byte("value"), time.Millisecond)
+			time.Sleep(5 * time.Millisecond)
+			if _, err := s.Get([]byte("expiring")); !errors.Is(err, ErrKeyNotFound) {
+				t.Errorf("Expected ErrKeyNotFound for an expired key, got %v", err)
+			}
+		})
+	}
+
+	// Badger's own error still matches, for callers that check for it
+	s, err := NewBadgerStorage(filepath.Join(testDir, "badger-missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Get([]byte("missing")); !errors.Is(err, badger.ErrKeyNotFound) {
+		t.Errorf("Expected the error to wrap badger.ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestStorage_ScanConsistentPrefix(t *testing.T) {
+	testDir, cleanup := setupTest(t)
+	defer cleanup()
+
+	const keys = 20
+	const rounds = 50
+
+	for _, storageType := range []StorageType{CustomStorage, BadgerStorageType, MemStorageType} {
+		t.Run(string(storageType), func(t *testing.T) {
+			s, err := NewStorage(storageType, filepath.Join(testDir, "scan-prefix-"+string(storageType)))
+			if err != nil {
+				t.Fatalf("Failed to create storage: %v", err)
+			}
+			defer s.Close()
+
+			for k := 0; k < keys; k++ {
+				if err := s.Put([]byte(fmt.Sprintf("key%02d", k)), []byte("0")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Each round rewrites every key in order with the round number
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for round := 1; round <= rounds; round++ {
+					for k := 0; k < keys; k++ {
+						s.Put([]byte(fmt.Sprintf("key%02d", k)), []byte(fmt.Sprintf("%d", round)))
+					}
+				}
+			}()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				it, err := s.ScanWithConsistency(nil, nil, ScanConsistentPrefix)
+				if err != nil {
+					t.Fatalf("Scan failed: %v", err)
+				}
+				var seen []int
+				for it.Next() {
+					var r int
+					fmt.Sscanf(string(it.Value()), "%d", &r)
+					seen = append(seen, r)
+					// Give the writer a chance to land mid-scan
+					time.Sleep(time.Microsecond)
+				}
+				if err := it.Close(); err != nil {
+					t.Fatalf("Scan failed: %v", err)
+				}
+				if len(seen) != keys {
+					t.Fatalf("Expected %d keys, got %d", keys, len(seen))
+				}
+
+				// Keys are scanned in write order, so rounds may only step
+				// down by one, once: a later write must never show without
+				// an earlier one
+				for i := 1; i < len(seen); i++ {
+					if seen[i] > seen[i-1] || seen[0]-seen[i] > 1 {
+						t.Fatalf("Torn view: %v", seen)
+					}
+				}
+			}
+		})
+	}
+}
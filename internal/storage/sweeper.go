@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"container/heap"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// SweeperConfig tunes how a Sweeper reclaims expired keys
+type SweeperConfig struct {
+	// Interval is how often the sweeper looks for expired keys.
+	// Zero or negative disables background sweeping; call Sweep directly.
+	Interval time.Duration
+
+	// BatchSize bounds how many keys are deleted before the sweeper yields,
+	// so a burst of simultaneous expirations doesn't hold up other writers.
+	BatchSize int
+
+	// BatchPause is how long the sweeper yields between batches.
+	BatchPause time.Duration
+}
+
+// DefaultSweeperConfig returns the default sweeper configuration
+func DefaultSweeperConfig() SweeperConfig {
+	return SweeperConfig{
+		Interval:   time.Second,
+		BatchSize:  1000,
+		BatchPause: time.Millisecond,
+	}
+}
+
+// SweeperStats reports what a Sweeper has done
+type SweeperStats struct {
+	Sweeps            int           // completed sweeps
+	Batches           int           // batches across all sweeps
+	KeysExpired       int           // keys deleted across all sweeps
+	LastSweepExpired  int           // keys deleted by the most recent sweep
+	LastSweepDuration time.Duration // how long the most recent sweep took
+}
+
+// Sweeper deletes keys from a Storage once their expiry time has passed
+type Sweeper struct {
+	store     Storage
+	cfg       SweeperConfig
+	deadlines map[string]time.Time // key -> expiry, authoritative
+	queue     expiryQueue          // may hold stale entries for changed deadlines
+	stats     SweeperStats
+	mu        sync.Mutex
+	sweepMu   sync.Mutex // serializes sweeps
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewSweeper creates a sweeper for store and starts sweeping in the background
+func NewSweeper(store Storage, cfg SweeperConfig) *Sweeper {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultSweeperConfig().BatchSize
+	}
+
+	s := &Sweeper{
+		store:     store,
+		cfg:       cfg,
+		deadlines: make(map[string]time.Time),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+// ExpireAt schedules key to be deleted at the given time, replacing any
+// earlier schedule for it
+func (s *Sweeper) ExpireAt(key []byte, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deadlines[string(key)] = at
+	heap.Push(&s.queue, expiryEntry{key: string(key), at: at})
+}
+
+// Cancel removes any expiry scheduled for key
+func (s *Sweeper) Cancel(key []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The queue entry is skipped once it no longer matches the deadlines map
+	delete(s.deadlines, string(key))
+}
+
+// Stats returns a snapshot of the sweeper's metrics
+func (s *Sweeper) Stats() SweeperStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// Stop stops background sweeping
+func (s *Sweeper) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+}
+
+// loop runs a sweep every Interval until stopped
+func (s *Sweeper) loop() {
+	defer close(s.done)
+
+	if s.cfg.Interval <= 0 {
+		<-s.stop
+		return
+	}
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.Sweep()
+		}
+	}
+}
+
+// Sweep deletes every key whose expiry has passed, at most BatchSize keys
+// at a time with a pause between batches. It returns the number of keys
+// deleted.
+func (s *Sweeper) Sweep() int {
+	s.sweepMu.Lock()
+	defer s.sweepMu.Unlock()
+
+	start := time.Now()
+	expired := 0
+	batches := 0
+
+sweep:
+	for {
+		batch := s.nextBatch(start)
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, key := range batch {
+			if err := s.store.Delete([]byte(key)); err != nil && !errors.Is(err, ErrKeyNotFound) {
+				log.Printf("Failed to delete expired key: %v", err)
+				continue
+			}
+			expired++
+		}
+		batches++
+
+		if len(batch) < s.cfg.BatchSize {
+			break
+		}
+		select {
+		case <-s.stop:
+			// Stopping; the remaining keys are picked up by a later sweep
+			break sweep
+		case <-time.After(s.cfg.BatchPause):
+		}
+	}
+
+	s.mu.Lock()
+	s.stats.Sweeps++
+	s.stats.Batches += batches
+	s.stats.KeysExpired += expired
+	s.stats.LastSweepExpired = expired
+	s.stats.LastSweepDuration = time.Since(start)
+	s.mu.Unlock()
+
+	return expired
+}
+
+// nextBatch removes up to BatchSize keys that expired by now from the
+// schedule and returns them
+func (s *Sweeper) nextBatch(now time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var batch []string
+	for len(batch) < s.cfg.BatchSize && s.queue.Len() > 0 && !s.queue[0].at.After(now) {
+		entry := heap.Pop(&s.queue).(expiryEntry)
+		at, ok := s.deadlines[entry.key]
+		if !ok || !at.Equal(entry.at) {
+			continue // cancelled or rescheduled
+		}
+		delete(s.deadlines, entry.key)
+		batch = append(batch, entry.key)
+	}
+	return batch
+}
+
+// expiryEntry is one scheduled expiry
+type expiryEntry struct {
+	key string
+	at  time.Time
+}
+
+// expiryQueue is a min-heap of expiries ordered by time
+type expiryQueue []expiryEntry
+
+func (q expiryQueue) Len() int            { return len(q) }
+func (q expiryQueue) Less(i, j int) bool  { return q[i].at.Before(q[j].at) }
+func (q expiryQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *expiryQueue) Push(x interface{}) { *q = append(*q, x.(expiryEntry)) }
+
+func (q *expiryQueue) Pop() interface{} {
+	old := *q
+	entry := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return entry
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSweeper_ExpiresInBoundedBatches(t *testing.T) {
+	s, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Sweep manually so the test controls when passes happen
+	sweeper := NewSweeper(s, SweeperConfig{BatchSize: 1000, BatchPause: time.Millisecond})
+	defer sweeper.Stop()
+
+	past := time.Now().Add(-time.Second)
+	for i := 0; i < 2500; i++ {
+		key := []byte(fmt.Sprintf("key%04d", i))
+		if err := s.Put(key, []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+		sweeper.ExpireAt(key, past)
+	}
+
+	// Keys that haven't expired, or whose expiry was cancelled, must survive
+	if err := s.Put([]byte("future"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	sweeper.ExpireAt([]byte("future"), time.Now().Add(time.Hour))
+	if err := s.Put([]byte("cancelled"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	sweeper.ExpireAt([]byte("cancelled"), past)
+	sweeper.Cancel([]byte("cancelled"))
+
+	if n := sweeper.Sweep(); n != 2500 {
+		t.Errorf("Expected 2500 keys expired, got %d", n)
+	}
+
+	stats := sweeper.Stats()
+	if stats.Sweeps != 1 {
+		t.Errorf("Expected 1 sweep, got %d", stats.Sweeps)
+	}
+	if stats.Batches != 3 {
+		t.Errorf("Expected 3 batches of at most 1000 keys, got %d", stats.Batches)
+	}
+	if stats.KeysExpired != 2500 || stats.LastSweepExpired != 2500 {
+		t.Errorf("Expected 2500 keys expired in stats, got %+v", stats)
+	}
+	if stats.LastSweepDuration <= 0 {
+		t.Errorf("Expected a positive sweep duration, got %v", stats.LastSweepDuration)
+	}
+
+	if size := s.Size(); size != 2 {
+		t.Errorf("Expected 2 keys left, got %d", size)
+	}
+
+	// Nothing left to expire
+	if n := sweeper.Sweep(); n != 0 {
+		t.Errorf("Expected empty second sweep, got %d", n)
+	}
+	if stats := sweeper.Stats(); stats.Sweeps != 2 || stats.KeysExpired != 2500 || stats.LastSweepExpired != 0 {
+		t.Errorf("Unexpected stats after second sweep: %+v", stats)
+	}
+}
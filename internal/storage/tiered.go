@@ -0,0 +1,714 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TieredConfig configures a TieredStorage
+type TieredConfig struct {
+	// MaxHotKeys bounds how many keys the hot tier keeps after demotion.
+	MaxHotKeys int
+
+	// DemoteInterval is how often the least recently used hot keys are
+	// moved to the cold tier. Zero or negative disables background
+	// demotion; call Demote directly.
+	DemoteInterval time.Duration
+}
+
+// DefaultTieredConfig returns the default tiering configuration
+func DefaultTieredConfig() TieredConfig {
+	return TieredConfig{
+		MaxHotKeys:     100000,
+		DemoteInterval: time.Minute,
+	}
+}
+
+// TieredStorage keeps recently used keys in a fast hot tier and everything
+// else in a slower cold tier. Writes go to the hot tier, reads fall through
+// to the cold tier and promote what they find, and keys that haven't been
+// used recently are demoted in the background. Each key lives in exactly
+// one tier. It implements the Storage interface.
+type TieredStorage struct {
+	hot    Storage
+	cold   Storage
+	cfg    TieredConfig
+	access map[string]time.Time // hot key -> last access
+	mu     sync.Mutex
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewTieredStorage creates a tiered storage over the given tiers and starts
+// background demotion. Keys already in the hot tier count as least recently
+// used.
+func NewTieredStorage(hot, cold Storage, cfg TieredConfig) (*TieredStorage, error) {
+	t := &TieredStorage{
+		hot:    hot,
+		cold:   cold,
+		cfg:    cfg,
+		access: make(map[string]time.Time),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	it, err := hot.Scan(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	for it.Next() {
+		t.access[string(it.Key())] = time.Time{}
+	}
+	if err := it.Close(); err != nil {
+		return nil, err
+	}
+
+	go t.demoteLoop()
+	return t, nil
+}
+
+// Put stores a key-value pair in the hot tier
+func (t *TieredStorage) Put(key, value []byte) error {
+	return t.PutCtx(context.Background(), key, value)
+}
+
+// PutCtx is Put, passing ctx on to the hot tier
+func (t *TieredStorage) PutCtx(ctx context.Context, key, value []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.hot.PutCtx(ctx, key, value); err != nil {
+		return err
+	}
+	// Drop any older copy so the key lives in one tier only
+	t.cold.Delete(key)
+
+	t.access[string(key)] = time.Now()
+	return nil
+}
+
+// PutWithTTL stores an expiring key-value pair in the hot tier. Demoting
+// the key to the cold tier copies its value but not its expiry.
+func (t *TieredStorage) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.hot.PutWithTTL(key, value, ttl); err != nil {
+		return err
+	}
+	t.cold.Delete(key)
+
+	t.access[string(key)] = time.Now()
+	return nil
+}
+
+// Get retrieves a value, promoting it to the hot tier if it was cold
+func (t *TieredStorage) Get(key []byte) ([]byte, error) {
+	return t.GetCtx(context.Background(), key)
+}
+
+// GetCtx is Get, passing ctx on to both tiers' reads. Promotion isn't
+// bounded by ctx, since the value has already been read.
+func (t *TieredStorage) GetCtx(ctx context.Context, key []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if value, err := t.hot.GetCtx(ctx, key); err == nil {
+		t.access[string(key)] = time.Now()
+		return value, nil
+	}
+
+	value, err := t.cold.GetCtx(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Promote; if that fails the key simply stays cold
+	if err := t.hot.Put(key, value); err == nil {
+		t.cold.Delete(key)
+		t.access[string(key)] = time.Now()
+	}
+	return value, nil
+}
+
+// Delete removes a key from whichever tier holds it
+func (t *TieredStorage) Delete(key []byte) error {
+	return t.DeleteCtx(context.Background(), key)
+}
+
+// DeleteCtx is Delete, passing ctx on to the tier that holds the key
+func (t *TieredStorage) DeleteCtx(ctx context.Context, key []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, inHot := t.access[string(key)]
+	delete(t.access, string(key))
+
+	if inHot {
+		return t.hot.DeleteCtx(ctx, key)
+	}
+	return t.cold.DeleteCtx(ctx, key)
+}
+
+// DeleteRange removes a key range from both tiers. Each key lives in one
+// tier, so the counts add up.
+func (t *TieredStorage) DeleteRange(start, end []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hot, err := t.hot.DeleteRange(start, end)
+	if err != nil {
+		return hot, err
+	}
+	r := KeyRange{Start: start, End: end}
+	for key := range t.access {
+		if r.Contains([]byte(key)) {
+			delete(t.access, key)
+		}
+	}
+	cold, err := t.cold.DeleteRange(start, end)
+	return hot + cold, err
+}
+
+// DeleteIf removes key from whichever tier holds it if its value equals expected
+func (t *TieredStorage) DeleteIf(key, expected []byte) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, inHot := t.access[string(key)]; !inHot {
+		return t.cold.DeleteIf(key, expected)
+	}
+
+	deleted, err := t.hot.DeleteIf(key, expected)
+	if deleted {
+		delete(t.access, string(key))
+	}
+	return deleted, err
+}
+
+// CompareAndSwap sets key to new if it holds old, in whichever tier holds
+// it. A cold key that matches is written to the hot tier like Put. The
+// tiers are only changed under the storage lock, so the compare and the
+// write are atomic with respect to every other operation on it.
+func (t *TieredStorage) CompareAndSwap(key, old, new []byte) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, inHot := t.access[string(key)]; inHot {
+		swapped, err := t.hot.CompareAndSwap(key, old, new)
+		if swapped {
+			t.access[string(key)] = time.Now()
+		}
+		return swapped, err
+	}
+
+	current, err := t.cold.Get(key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return false, err
+	}
+	if !casMatches(current, err == nil, old) {
+		return false, nil
+	}
+	if err := t.hot.Put(key, new); err != nil {
+		return false, err
+	}
+	t.cold.Delete(key)
+	t.access[string(key)] = time.Now()
+	return true, nil
+}
+
+// Increment adds delta to key's counter in whichever tier holds it. A
+// cold counter is moved to the hot tier with its new value, like Put.
+func (t *TieredStorage) Increment(key []byte, delta int64) (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, inHot := t.access[string(key)]; inHot {
+		n, err := t.hot.Increment(key, delta)
+		if err == nil {
+			t.access[string(key)] = time.Now()
+		}
+		return n, err
+	}
+
+	current, err := t.cold.Get(key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return 0, err
+	}
+	n, value, err := AddToCounter(current, err == nil, delta)
+	if err != nil {
+		return 0, err
+	}
+	if err := t.hot.Put(key, value); err != nil {
+		return 0, err
+	}
+	t.cold.Delete(key)
+	t.access[string(key)] = time.Now()
+	return n, nil
+}
+
+// Append adds value to key's list in whichever tier holds it. A cold list
+// is moved to the hot tier with the new element, like Put.
+func (t *TieredStorage) Append(key, value []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, inHot := t.access[string(key)]; inHot {
+		err := t.hot.Append(key, value)
+		if err == nil {
+			t.access[string(key)] = time.Now()
+		}
+		return err
+	}
+
+	current, err := t.cold.Get(key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return err
+	}
+	list, err := AppendToList(current, err == nil, value)
+	if err != nil {
+		return err
+	}
+	if err := t.hot.Put(key, list); err != nil {
+		return err
+	}
+	t.cold.Delete(key)
+	t.access[string(key)] = time.Now()
+	return nil
+}
+
+// GetList returns the elements of key's list, from whichever tier holds it
+func (t *TieredStorage) GetList(key []byte) ([][]byte, error) {
+	return getList(t, key)
+}
+
+// Tail returns the n largest keys across both tiers, in descending key order
+func (t *TieredStorage) Tail(n int) ([]KV, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hot, err := t.hot.Tail(n)
+	if err != nil {
+		return nil, err
+	}
+	cold, err := t.cold.Tail(n)
+	if err != nil {
+		return nil, err
+	}
+
+	// Merge the two descending lists
+	result := make([]KV, 0, n)
+	for len(result) < n && (len(hot) > 0 || len(cold) > 0) {
+		if len(cold) == 0 || (len(hot) > 0 && bytes.Compare(hot[0].Key, cold[0].Key) > 0) {
+			result = append(result, hot[0])
+			hot = hot[1:]
+		} else {
+			result = append(result, cold[0])
+			cold = cold[1:]
+		}
+	}
+	return result, nil
+}
+
+// Fingerprint returns the fingerprint of both tiers together. Each key
+// lives in one tier, so it is the XOR of the tiers' fingerprints.
+func (t *TieredStorage) Fingerprint() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hot, err := t.hot.Fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	cold, err := t.cold.Fingerprint()
+	if err != nil {
+		return nil, err
+	}
+
+	xorInto(hot, cold)
+	return hot, nil
+}
+
+// SplitRanges splits the keyspace of both tiers together. Each tier is
+// split finely on its own, and the combined boundaries are chosen from the
+// tiers' boundaries weighted by how many keys each tier holds.
+func (t *TieredStorage) SplitRanges(n int) ([]KeyRange, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("%w: cannot split into %d ranges", ErrInvalidLimit, n)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Each boundary of a tier split into m ranges stands for 1/m of its keys
+	m := n * splitSamplesPerRange
+	type weighted struct {
+		key    []byte
+		weight int
+	}
+	var boundaries []weighted
+	total := 0
+	for _, tier := range []Storage{t.hot, t.cold} {
+		size := tier.Size()
+		if size <= 0 {
+			continue
+		}
+		ranges, err := tier.SplitRanges(m)
+		if err != nil {
+			return nil, err
+		}
+		total += size
+		for _, r := range ranges[1:] {
+			boundaries = append(boundaries, weighted{key: r.Start, weight: size})
+		}
+	}
+	sort.Slice(boundaries, func(i, j int) bool {
+		return bytes.Compare(boundaries[i].key, boundaries[j].key) < 0
+	})
+
+	// About seen/m keys lie below a boundary once the weights up to it add
+	// up to seen, so the i-th combined boundary is where that reaches
+	// i/n of the total
+	chosen := make([][]byte, 0, n-1)
+	seen := 0
+	for _, b := range boundaries {
+		seen += b.weight
+		for len(chosen) < n-1 && seen*n >= (len(chosen)+1)*total*m {
+			chosen = append(chosen, b.key)
+		}
+	}
+	for len(chosen) < n-1 {
+		last := []byte{}
+		if len(chosen) > 0 {
+			last = chosen[len(chosen)-1]
+		}
+		chosen = append(chosen, last)
+	}
+	return rangesBetween(chosen), nil
+}
+
+// Scan returns an iterator over both tiers, merged in key order. Each
+// tier is read through its own iterator, so a key demoted or promoted
+// while the iterator is open may be missed or seen twice.
+func (t *TieredStorage) Scan(start, end []byte) (Iterator, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hot, err := t.hot.Scan(start, end)
+	if err != nil {
+		return nil, err
+	}
+	cold, err := t.cold.Scan(start, end)
+	if err != nil {
+		hot.Close()
+		return nil, err
+	}
+	return newMergeIterator(hot, cold), nil
+}
+
+// ScanWithConsistency is Scan, or with ScanConsistentPrefix a copy of both
+// tiers merged while holding the lock, so no key moves between tiers and
+// no write lands part way through the range
+func (t *TieredStorage) ScanWithConsistency(start, end []byte, consistency ScanConsistency) (Iterator, error) {
+	if consistency != ScanConsistentPrefix {
+		return t.Scan(start, end)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hot, err := t.hot.ScanWithConsistency(start, end, consistency)
+	if err != nil {
+		return nil, err
+	}
+	cold, err := t.cold.ScanWithConsistency(start, end, consistency)
+	if err != nil {
+		hot.Close()
+		return nil, err
+	}
+	pairs, err := Collect(newMergeIterator(hot, cold))
+	if err != nil {
+		return nil, err
+	}
+	return NewSliceIterator(pairs), nil
+}
+
+// ScanReverse returns a descending iterator over both tiers, merged like
+// Scan
+func (t *TieredStorage) ScanReverse(start, end []byte) (Iterator, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hot, err := t.hot.ScanReverse(start, end)
+	if err != nil {
+		return nil, err
+	}
+	cold, err := t.cold.ScanReverse(start, end)
+	if err != nil {
+		hot.Close()
+		return nil, err
+	}
+	return newReverseMergeIterator(hot, cold), nil
+}
+
+// ScanPrefix returns an iterator over the keys beginning with prefix in
+// both tiers, merged like Scan
+func (t *TieredStorage) ScanPrefix(prefix []byte) (Iterator, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hot, err := t.hot.ScanPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	cold, err := t.cold.ScanPrefix(prefix)
+	if err != nil {
+		hot.Close()
+		return nil, err
+	}
+	return newMergeIterator(hot, cold), nil
+}
+
+// Keys returns the keys of both tiers, merged in ascending order
+func (t *TieredStorage) Keys() ([][]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hot, err := t.hot.Keys()
+	if err != nil {
+		return nil, err
+	}
+	cold, err := t.cold.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([][]byte, 0, len(hot)+len(cold))
+	for len(hot) > 0 && len(cold) > 0 {
+		switch c := bytes.Compare(hot[0], cold[0]); {
+		case c < 0:
+			keys, hot = append(keys, hot[0]), hot[1:]
+		case c > 0:
+			keys, cold = append(keys, cold[0]), cold[1:]
+		default:
+			keys, hot, cold = append(keys, hot[0]), hot[1:], cold[1:]
+		}
+	}
+	keys = append(keys, hot...)
+	return append(keys, cold...), nil
+}
+
+// NewBatch returns a batch that writes to the hot tier like Put
+func (t *TieredStorage) NewBatch() WriteBatch {
+	return &tieredBatch{tiered: t}
+}
+
+// tieredBatch is a WriteBatch over a TieredStorage
+type tieredBatch struct {
+	BatchOps
+	tiered *TieredStorage
+}
+
+// Commit commits the batch to the hot tier, with that tier's atomicity,
+// then drops cold copies of the batch's keys as Put and Delete do. If a
+// cold deletion fails, the hot tier has still been updated.
+func (b *tieredBatch) Commit() error {
+	t := b.tiered
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	batch := t.hot.NewBatch()
+	for _, op := range b.Ops {
+		if op.Delete {
+			batch.Delete(op.Key)
+		} else {
+			batch.Put(op.Key, op.Value)
+		}
+	}
+	if err := batch.Commit(); err != nil {
+		return err
+	}
+
+	var coldErr error
+	now := time.Now()
+	for _, op := range b.Ops {
+		if op.Delete {
+			delete(t.access, string(op.Key))
+		} else {
+			t.access[string(op.Key)] = now
+		}
+		if err := t.cold.Delete(op.Key); err != nil && !errors.Is(err, ErrKeyNotFound) && coldErr == nil {
+			coldErr = err
+		}
+	}
+	return coldErr
+}
+
+// Size returns the number of keys across both tiers
+func (t *TieredStorage) Size() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hot, cold := t.hot.Size(), t.cold.Size()
+	if hot < 0 || cold < 0 {
+		return -1
+	}
+	return hot + cold
+}
+
+// Stats adds up the key counts and disk usage of both tiers
+func (t *TieredStorage) Stats() (StorageStats, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hot, err := t.hot.Stats()
+	if err != nil {
+		return StorageStats{}, err
+	}
+	cold, err := t.cold.Stats()
+	if err != nil {
+		return StorageStats{}, err
+	}
+	return StorageStats{
+		KeyCount:  hot.KeyCount + cold.KeyCount,
+		DiskBytes: hot.DiskBytes + cold.DiskBytes,
+		Backend:   fmt.Sprintf("tiered(%s, %s)", hot.Backend, cold.Backend),
+	}, nil
+}
+
+// Limits returns the tighter of the two tiers' limits for keys and for
+// values, since a pair may be written to one tier and moved to the other
+func (t *TieredStorage) Limits() Limits {
+	hot, cold := t.hot.Limits(), t.cold.Limits()
+	return Limits{
+		MaxKeySize:   tighterLimit(hot.MaxKeySize, cold.MaxKeySize),
+		MaxValueSize: tighterLimit(hot.MaxValueSize, cold.MaxValueSize),
+	}
+}
+
+// tighterLimit returns the smaller of two limits, where 0 means no limit
+func tighterLimit(a, b int) int {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// Sync syncs both tiers
+func (t *TieredStorage) Sync() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.hot.Sync(); err != nil {
+		return err
+	}
+	return t.cold.Sync()
+}
+
+// Backup writes both tiers, merged in key order, as one backup. Nothing
+// moves between tiers while it runs, so each key is backed up once.
+// Expiry times are not kept.
+func (t *TieredStorage) Backup(w io.Writer) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hot, err := t.hot.Scan(nil, nil)
+	if err != nil {
+		return err
+	}
+	cold, err := t.cold.Scan(nil, nil)
+	if err != nil {
+		hot.Close()
+		return err
+	}
+	return writeBackupPairs(w, newMergeIterator(hot, cold))
+}
+
+// HotSize returns the number of keys in the hot tier
+func (t *TieredStorage) HotSize() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.access)
+}
+
+// Demote moves the least recently used hot keys to the cold tier until the
+// hot tier holds at most MaxHotKeys. It returns the number of keys moved.
+func (t *TieredStorage) Demote() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	excess := len(t.access) - t.cfg.MaxHotKeys
+	if excess <= 0 {
+		return 0
+	}
+
+	keys := make([]string, 0, len(t.access))
+	for key := range t.access {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return t.access[keys[i]].Before(t.access[keys[j]])
+	})
+
+	moved := 0
+	for _, key := range keys[:excess] {
+		value, err := t.hot.Get([]byte(key))
+		if err != nil {
+			continue
+		}
+		if err := t.cold.Put([]byte(key), value); err != nil {
+			continue
+		}
+		t.hot.Delete([]byte(key))
+		delete(t.access, key)
+		moved++
+	}
+	return moved
+}
+
+// demoteLoop runs Demote every DemoteInterval until the storage is closed
+func (t *TieredStorage) demoteLoop() {
+	defer close(t.done)
+
+	if t.cfg.DemoteInterval <= 0 {
+		<-t.stop
+		return
+	}
+
+	ticker := time.NewTicker(t.cfg.DemoteInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			t.Demote()
+		}
+	}
+}
+
+// Close stops demotion and closes both tiers, returning the first error
+func (t *TieredStorage) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.stop)
+	})
+	<-t.done
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hotErr := t.hot.Close()
+	if err := t.cold.Close(); err != nil && hotErr == nil {
+		return err
+	}
+	return hotErr
+}
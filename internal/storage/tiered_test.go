@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTieredStorage_DemotesAndPromotes(t *testing.T) {
+	hot, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cold, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Demote manually so the test controls when it happens
+	s, err := NewTieredStorage(hot, cold, TieredConfig{MaxHotKeys: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 50; i++ {
+		key := []byte(fmt.Sprintf("key%02d", i))
+		if err := s.Put(key, []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	// Touch an early key so it is the most recently used
+	if _, err := s.Get([]byte("key00")); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	if moved := s.Demote(); moved != 40 {
+		t.Errorf("Expected 40 keys demoted, got %d", moved)
+	}
+	if size := hot.Size(); size != 10 {
+		t.Errorf("Expected 10 hot keys, got %d", size)
+	}
+	if size := cold.Size(); size != 40 {
+		t.Errorf("Expected 40 cold keys, got %d", size)
+	}
+	if _, err := hot.Get([]byte("key00")); err != nil {
+		t.Error("Expected recently used key00 to stay hot")
+	}
+
+	// Every key is still readable; cold reads promote
+	for i := 0; i < 50; i++ {
+		key := []byte(fmt.Sprintf("key%02d", i))
+		value, err := s.Get(key)
+		if err != nil {
+			t.Fatalf("Get %s failed: %v", key, err)
+		}
+		if string(value) != fmt.Sprintf("value%d", i) {
+			t.Errorf("Expected value%d, got %s", i, value)
+		}
+	}
+	if _, err := hot.Get([]byte("key01")); err != nil {
+		t.Error("Expected key01 to be promoted to the hot tier")
+	}
+	if size := s.Size(); size != 50 {
+		t.Errorf("Expected 50 keys in total, got %d", size)
+	}
+
+	// Another pass brings the hot tier back within bounds
+	s.Demote()
+	if size := s.HotSize(); size != 10 {
+		t.Errorf("Expected hot tier bounded at 10, got %d", size)
+	}
+
+	pairs, err := s.Tail(3)
+	if err != nil {
+		t.Fatalf("Tail failed: %v", err)
+	}
+	for i, kv := range pairs {
+		if expected := fmt.Sprintf("key%02d", 49-i); string(kv.Key) != expected {
+			t.Errorf("Expected %s at position %d, got %s", expected, i, kv.Key)
+		}
+	}
+
+	if err := s.Delete([]byte("key05")); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if _, err := s.Get([]byte("key05")); err == nil {
+		t.Error("Expected key05 to be deleted")
+	}
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBadgerStorage_PutWithTTL(t *testing.T) {
+	store, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if err := store.PutWithTTL([]byte("cache:key"), []byte("value"), 100*time.Millisecond); err != nil {
+		t.Fatalf("PutWithTTL failed: %v", err)
+	}
+	if value, err := store.Get([]byte("cache:key")); err != nil || string(value) != "value" {
+		t.Fatalf("Expected value before expiry, got %q (%v)", value, err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	_, err = store.Get([]byte("cache:key"))
+	_, missingErr := store.Get([]byte("missing"))
+	if err == nil || err.Error() != missingErr.Error() {
+		t.Errorf("Expected the missing-key error after expiry, got %v", err)
+	}
+}
+
+func TestBadgerStorage_ExpiredKeysAreHidden(t *testing.T) {
+	store, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	store.Put([]byte("cache:a"), []byte("value-cache:a"))
+	store.PutWithTTL([]byte("cache:b"), []byte("value-cache:b"), 50*time.Millisecond)
+	store.PutWithTTL([]byte("cache:c"), []byte("stale"), 50*time.Millisecond)
+	// A plain Put replaces the value and its expiry
+	store.Put([]byte("cache:c"), []byte("value-cache:c"))
+
+	time.Sleep(100 * time.Millisecond)
+
+	if store.Size() != 2 {
+		t.Errorf("Expected 2 live keys, got %d", store.Size())
+	}
+	it, err := store.ScanPrefix([]byte("cache:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := collect(t, it); len(keys) != 2 || keys[0] != "cache:a" || keys[1] != "cache:c" {
+		t.Errorf("Expected cache:a and cache:c, got %v", keys)
+	}
+	if swapped, _ := store.CompareAndSwap([]byte("cache:b"), nil, []byte("new")); !swapped {
+		t.Error("Expected an expired key to count as absent")
+	}
+}
+
+func TestStorageEngine_PutWithTTLNeverExpires(t *testing.T) {
+	engine, err := NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+
+	if err := engine.PutWithTTL([]byte("key"), []byte("value"), time.Millisecond); err != nil {
+		t.Fatalf("PutWithTTL failed: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if value, err := engine.Get([]byte("key")); err != nil || string(value) != "value" {
+		t.Errorf("Expected value, got %q (%v)", value, err)
+	}
+}
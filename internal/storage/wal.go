@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// DefaultWALCheckpointSize is how large a StorageEngine's write-ahead log
+// grows, in bytes, before a write checkpoints the tree into the database
+// file and empties the log
+const DefaultWALCheckpointSize = 4 * 1024 * 1024
+
+// walHeaderSize is the size of a record's header: the payload length and
+// the payload's CRC-32
+const walHeaderSize = 8
+
+// Kinds of walOp
+const (
+	walPut byte = iota
+	walDelete
+	walDeleteRange
+)
+
+// walOp is one change to the tree recorded in the write-ahead log. A
+// range delete keeps its start in key and its end in value, either of
+// which is nil if that end of the range is unbounded.
+type walOp struct {
+	kind  byte
+	key   []byte
+	value []byte
+}
+
+// errTornRecord marks the end of the usable log: a record cut short by a
+// crash while it was appended, or one whose contents don't match its CRC
+var errTornRecord = errors.New("torn write-ahead log record")
+
+// wal is a StorageEngine's write-ahead log. Each write to the tree is
+// appended as one record and synced before the write returns, so every
+// change since the last checkpoint can be replayed after a crash. A record
+// holds all the operations of one write, so a batch is replayed in full or
+// not at all.
+//
+// Each record is the length of its payload and the payload's CRC-32, both
+// 4-byte big-endian, followed by the payload: the number of operations
+// and then each operation's kind and fields, as uvarint-prefixed bytes.
+type wal struct {
+	file *os.File
+	path string
+	size int64
+}
+
+// openWAL opens the write-ahead log at path, creating it if it doesn't
+// exist
+func openWAL(path string) (*wal, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &wal{file: file, path: path}, nil
+}
+
+// append durably adds a record of ops to the end of the log
+func (w *wal) append(ops []walOp) error {
+	payload := binary.AppendUvarint(nil, uint64(len(ops)))
+	for _, op := range ops {
+		payload = append(payload, op.kind)
+		switch op.kind {
+		case walPut:
+			payload = appendWALField(payload, op.key)
+			payload = appendWALField(payload, op.value)
+		case walDelete:
+			payload = appendWALField(payload, op.key)
+		case walDeleteRange:
+			// Flag which ends are bounded, since an empty end isn't
+			// the same as none
+			var bounded byte
+			if op.key != nil {
+				bounded |= 1
+			}
+			if op.value != nil {
+				bounded |= 2
+			}
+			payload = append(payload, bounded)
+			payload = appendWALField(payload, op.key)
+			payload = appendWALField(payload, op.value)
+		}
+	}
+
+	record := make([]byte, walHeaderSize, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	record = append(record, payload...)
+
+	if _, err := w.file.WriteAt(record, w.size); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.size += int64(len(record))
+	return nil
+}
+
+// appendWALField appends field to buf, prefixed with its length
+func appendWALField(buf, field []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(field)))
+	return append(buf, field...)
+}
+
+// replay calls apply with the operations of every record in the log, in
+// order. It stops at the first torn record and cuts the log off before
+// it, so later appends follow the last complete record. It returns the
+// number of records replayed.
+func (w *wal) replay(apply func(op walOp) error) (int, error) {
+	data, err := io.ReadAll(io.NewSectionReader(w.file, 0, 1<<62))
+	if err != nil {
+		return 0, err
+	}
+
+	records := 0
+	var offset int64
+	for int64(len(data)) > offset {
+		ops, n, err := decodeWALRecord(data[offset:])
+		if err == errTornRecord {
+			break
+		}
+		for _, op := range ops {
+			if err := apply(op); err != nil {
+				return records, err
+			}
+		}
+		offset += int64(n)
+		records++
+	}
+
+	if offset < int64(len(data)) {
+		if err := w.file.Truncate(offset); err != nil {
+			return records, err
+		}
+		if err := w.file.Sync(); err != nil {
+			return records, err
+		}
+	}
+	w.size = offset
+	return records, nil
+}
+
+// decodeWALRecord decodes the record at the start of data, returning its
+// operations and its length in bytes
+func decodeWALRecord(data []byte) ([]walOp, int, error) {
+	if len(data) < walHeaderSize {
+		return nil, 0, errTornRecord
+	}
+	length := int64(binary.BigEndian.Uint32(data[0:4]))
+	if length > int64(len(data)-walHeaderSize) {
+		return nil, 0, errTornRecord
+	}
+	payload := data[walHeaderSize : walHeaderSize+length]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(data[4:8]) {
+		return nil, 0, errTornRecord
+	}
+
+	count, n := binary.Uvarint(payload)
+	if n <= 0 {
+		return nil, 0, errTornRecord
+	}
+	payload = payload[n:]
+
+	var ops []walOp
+	var ok bool
+	for i := uint64(0); i < count; i++ {
+		if len(payload) == 0 {
+			return nil, 0, errTornRecord
+		}
+		op := walOp{kind: payload[0]}
+		payload = payload[1:]
+		switch op.kind {
+		case walPut:
+			if op.key, payload, ok = readWALField(payload); !ok {
+				return nil, 0, errTornRecord
+			}
+			if op.value, payload, ok = readWALField(payload); !ok {
+				return nil, 0, errTornRecord
+			}
+		case walDelete:
+			if op.key, payload, ok = readWALField(payload); !ok {
+				return nil, 0, errTornRecord
+			}
+		case walDeleteRange:
+			if len(payload) == 0 {
+				return nil, 0, errTornRecord
+			}
+			bounded := payload[0]
+			payload = payload[1:]
+			if op.key, payload, ok = readWALField(payload); !ok {
+				return nil, 0, errTornRecord
+			}
+			if op.value, payload, ok = readWALField(payload); !ok {
+				return nil, 0, errTornRecord
+			}
+			if bounded&1 == 0 {
+				op.key = nil
+			} else if op.key == nil {
+				op.key = []byte{}
+			}
+			if bounded&2 == 0 {
+				op.value = nil
+			} else if op.value == nil {
+				op.value = []byte{}
+			}
+		default:
+			return nil, 0, errTornRecord
+		}
+		ops = append(ops, op)
+	}
+	return ops, walHeaderSize + int(length), nil
+}
+
+// readWALField reads a length-prefixed field from the start of buf,
+// returning it and the rest of buf
+func readWALField(buf []byte) (field, rest []byte, ok bool) {
+	length, n := binary.Uvarint(buf)
+	if n <= 0 || length > uint64(len(buf)-n) {
+		return nil, nil, false
+	}
+	buf = buf[n:]
+	return buf[:length:length], buf[length:], true
+}
+
+// reset empties the log, once a checkpoint holds everything it recorded
+func (w *wal) reset() error {
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.size = 0
+	return nil
+}
+
+// close closes the log file
+func (w *wal) close() error {
+	return w.file.Close()
+}
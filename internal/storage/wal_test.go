@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStorageEngine_ReplaysWALAfterCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	engine, err := NewStorageEngine(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if err := engine.Put([]byte(fmt.Sprintf("key%03d", i)), []byte(fmt.Sprintf("value%03d", i))); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := engine.Delete([]byte("key000")); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if n, err := engine.DeleteRange([]byte("key090"), nil); err != nil || n != 10 {
+		t.Fatalf("Expected DeleteRange to delete 10 keys, got %d (%v)", n, err)
+	}
+	batch := engine.NewBatch()
+	batch.Put([]byte("key001"), []byte("batched"))
+	batch.Delete([]byte("key002"))
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if _, err := engine.Increment([]byte("counter"), 7); err != nil {
+		t.Fatalf("Increment failed: %v", err)
+	}
+
+	// Crash without ever checkpointing: the database file still holds
+	// only the empty database's header
+	engine.crash()
+	if stat, err := os.Stat(path); err != nil || stat.Size() != PAGE_SIZE {
+		t.Fatalf("Expected only a header page in the database file, got %v (%v)", stat.Size(), err)
+	}
+
+	reopened, err := NewStorageEngine(path)
+	if err != nil {
+		t.Fatalf("Reopen after crash failed: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.Size() != 89 {
+		t.Errorf("Expected 89 keys after replay, got %d", reopened.Size())
+	}
+	for _, key := range []string{"key000", "key002", "key090", "key099"} {
+		if _, err := reopened.Get([]byte(key)); err == nil {
+			t.Errorf("Expected %s to stay deleted", key)
+		}
+	}
+	if value, _ := reopened.Get([]byte("key001")); string(value) != "batched" {
+		t.Errorf("Expected the batch's write to key001, got %q", value)
+	}
+	if value, _ := reopened.Get([]byte("key089")); string(value) != "value089" {
+		t.Errorf("Expected value089, got %q", value)
+	}
+	if value, _ := reopened.Get([]byte("counter")); string(value) != string(EncodeCounter(7)) {
+		t.Errorf("Expected the counter at 7, got %v", value)
+	}
+}
+
+func TestStorageEngine_WALIgnoresTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	engine, err := NewStorageEngine(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := engine.Put([]byte(fmt.Sprintf("key%d", i)), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	engine.crash()
+
+	// A crash partway through appending leaves the start of a record
+	wal, err := os.OpenFile(path+".wal", os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal.Write([]byte{0, 0, 0, 40, 1, 2})
+	wal.Close()
+
+	reopened, err := NewStorageEngine(path)
+	if err != nil {
+		t.Fatalf("Reopen after torn write failed: %v", err)
+	}
+	if reopened.Size() != 10 {
+		t.Errorf("Expected the 10 complete records to replay, got %d keys", reopened.Size())
+	}
+
+	// Writes after the replay follow the last complete record
+	if err := reopened.Put([]byte("after"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	reopened.crash()
+
+	again, err := NewStorageEngine(path)
+	if err != nil {
+		t.Fatalf("Second reopen failed: %v", err)
+	}
+	defer again.Close()
+	if again.Size() != 11 {
+		t.Errorf("Expected 11 keys, got %d", again.Size())
+	}
+	if _, err := again.Get([]byte("after")); err != nil {
+		t.Errorf("Expected the write made after the replay to survive, got %v", err)
+	}
+}
+
+func TestStorageEngine_CheckpointsWhenWALIsFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	engine, err := NewStorageEngine(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetWALCheckpointSize(1024)
+
+	for i := 0; i < 200; i++ {
+		if err := engine.Put([]byte(fmt.Sprintf("key%03d", i)), []byte(fmt.Sprintf("value%03d", i))); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if engine.wal.size >= 1024 {
+			t.Fatalf("Expected the log to be emptied at 1024 bytes, got %d", engine.wal.size)
+		}
+	}
+
+	// Part of the data is in the checkpoint and the rest in the log
+	stat, err := os.Stat(path)
+	if err != nil || stat.Size() <= PAGE_SIZE {
+		t.Errorf("Expected checkpoints in the database file, got %d bytes (%v)", stat.Size(), err)
+	}
+	engine.crash()
+
+	reopened, err := NewStorageEngine(path)
+	if err != nil {
+		t.Fatalf("Reopen after crash failed: %v", err)
+	}
+	defer reopened.Close()
+	if reopened.Size() != 200 {
+		t.Errorf("Expected 200 keys, got %d", reopened.Size())
+	}
+}
+
+func TestStorageEngine_FailedWALAppendLeavesTreeUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	engine, err := NewStorageEngine(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := engine.Put([]byte(fmt.Sprintf("key%d", i)), []byte("old")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	// Swap in a read-only handle, so every append to the log fails
+	logFile := engine.wal.file
+	readOnly, err := os.Open(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.wal.file = readOnly
+
+	if err := engine.Put([]byte("key0"), []byte("new")); err == nil {
+		t.Error("Expected Put to fail")
+	}
+	if err := engine.Put([]byte("added"), []byte("new")); err == nil {
+		t.Error("Expected Put of a new key to fail")
+	}
+	if err := engine.Delete([]byte("key1")); err == nil {
+		t.Error("Expected Delete to fail")
+	}
+	if deleted, err := engine.DeleteIf([]byte("key2"), []byte("old")); err == nil || deleted {
+		t.Errorf("Expected DeleteIf to fail, got %v (%v)", deleted, err)
+	}
+	if n, err := engine.DeleteRange([]byte("key5"), nil); err == nil || n != 0 {
+		t.Errorf("Expected DeleteRange to fail, got %d (%v)", n, err)
+	}
+	if swapped, err := engine.CompareAndSwap([]byte("key3"), []byte("old"), []byte("new")); err == nil || swapped {
+		t.Errorf("Expected CompareAndSwap to fail, got %v (%v)", swapped, err)
+	}
+	if _, err := engine.Increment([]byte("counter"), 1); err == nil {
+		t.Error("Expected Increment to fail")
+	}
+	if err := engine.Append([]byte("list"), []byte("element")); err == nil {
+		t.Error("Expected Append to fail")
+	}
+	batch := engine.NewBatch()
+	batch.Put([]byte("key4"), []byte("new"))
+	batch.Delete([]byte("key6"))
+	if err := batch.Commit(); err == nil {
+		t.Error("Expected Commit to fail")
+	}
+
+	// None of the failed writes is visible
+	checkUnchanged := func(s Storage) {
+		t.Helper()
+		if s.Size() != 10 {
+			t.Errorf("Expected 10 keys, got %d", s.Size())
+		}
+		for i := 0; i < 10; i++ {
+			if value, err := s.Get([]byte(fmt.Sprintf("key%d", i))); err != nil || string(value) != "old" {
+				t.Errorf("Expected key%d to hold old, got %q (%v)", i, value, err)
+			}
+		}
+	}
+	checkUnchanged(engine)
+
+	// Nor is it written by a later checkpoint
+	readOnly.Close()
+	engine.wal.file = logFile
+	if err := engine.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewStorageEngine(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	checkUnchanged(reopened)
+}
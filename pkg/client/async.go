@@ -0,0 +1,211 @@
+package client
+
+import (
+	"errors"
+	"hash/fnv"
+
+	"godatabase/internal/rpc/proto"
+)
+
+// defaultMaxOutstanding is the default number of async workers
+const defaultMaxOutstanding = 64
+
+// asyncQueueSize is how many async operations may wait for each worker
+// before the call that starts one blocks
+const asyncQueueSize = 16
+
+// ErrClientClosed is the result of an async operation started after the
+// client was closed
+var ErrClientClosed = errors.New("client is closed")
+
+// GetResult is the outcome of a GetAsync
+type GetResult struct {
+	Value []byte
+	Err   error
+}
+
+// PutAsync stores a key-value pair without waiting for the server. The
+// returned channel receives the result once it is known and is then
+// closed. The key and value are copied, so the caller may reuse them as
+// soon as PutAsync returns.
+//
+// Async operations are run by a fixed pool of workers, WithMaxOutstanding
+// of them, sharing the client's connection. Each key is always routed to
+// the same worker, so async operations on one key are applied in the
+// order they were started; operations on different keys are not ordered
+// with each other, nor with synchronous calls. Starting an operation
+// blocks while its worker's queue is full. Call Flush to wait for every
+// outstanding async operation. An operation started after Close fails
+// with ErrClientClosed.
+//
+// On a buffered client the write joins the buffer instead of going to a
+// worker, and is coalesced with other writes; the channel receives the
+// result of the first flush that carries it. If that flush fails, the
+// write stays buffered for a later retry as described in BufferConfig.
+// Such writes aren't ordered with GetAsync and DeleteAsync calls.
+func (c *Client) PutAsync(key, value []byte) <-chan error {
+	result := make(chan error, 1)
+	done := func(err error) {
+		result <- err
+		close(result)
+	}
+	key = append([]byte(nil), key...)
+	value = append([]byte(nil), value...)
+
+	c.async.Add(1)
+
+	if err := c.checkSize(&proto.PutRequest{Key: key, Value: value}); err != nil {
+		go c.runCallbacks([]func(error){done}, err)
+		return result
+	}
+
+	if c.buffer != nil {
+		c.bufferPutAsync(key, value, done)
+		return result
+	}
+
+	err := c.enqueue(key, func() {
+		c.runCallbacks([]func(error){done}, c.Put(key, value))
+	})
+	if err != nil {
+		c.runCallbacks([]func(error){done}, err)
+	}
+	return result
+}
+
+// GetAsync retrieves the value for a key without waiting for the server.
+// The returned channel receives the result, with storage.ErrKeyNotFound
+// if the key is missing, and is then closed. See PutAsync for how async
+// operations are ordered.
+func (c *Client) GetAsync(key []byte) <-chan GetResult {
+	result := make(chan GetResult, 1)
+	done := func(value []byte, err error) {
+		result <- GetResult{Value: value, Err: err}
+		close(result)
+		c.async.Done()
+	}
+	key = append([]byte(nil), key...)
+
+	c.async.Add(1)
+	err := c.enqueue(key, func() {
+		done(c.Get(key))
+	})
+	if err != nil {
+		done(nil, err)
+	}
+	return result
+}
+
+// DeleteAsync removes a key-value pair without waiting for the server.
+// The returned channel receives the result, as for Delete, and is then
+// closed. See PutAsync for how async operations are ordered.
+func (c *Client) DeleteAsync(key []byte) <-chan error {
+	result := make(chan error, 1)
+	done := func(err error) {
+		result <- err
+		close(result)
+	}
+	key = append([]byte(nil), key...)
+
+	c.async.Add(1)
+	err := c.enqueue(key, func() {
+		c.runCallbacks([]func(error){done}, c.Delete(key))
+	})
+	if err != nil {
+		c.runCallbacks([]func(error){done}, err)
+	}
+	return result
+}
+
+// enqueue hands op to the worker that key is routed to, starting the
+// workers if this is the first async operation. It returns ErrClientClosed
+// without running op once the workers have been stopped.
+func (c *Client) enqueue(key []byte, op func()) error {
+	c.workersOnce.Do(c.startWorkers)
+
+	// The read lock keeps stopWorkers from closing the queue while op is
+	// being sent on it
+	c.workersMu.RLock()
+	defer c.workersMu.RUnlock()
+	if c.workersClosed {
+		return ErrClientClosed
+	}
+
+	h := fnv.New32a()
+	h.Write(key)
+	c.workers[h.Sum32()%uint32(len(c.workers))] <- op
+	return nil
+}
+
+// startWorkers starts the async workers, one per outstanding request
+// allowed
+func (c *Client) startWorkers() {
+	c.workers = make([]chan func(), cap(c.outstanding))
+	for i := range c.workers {
+		queue := make(chan func(), asyncQueueSize)
+		c.workers[i] = queue
+		go func() {
+			for op := range queue {
+				op()
+			}
+		}()
+	}
+}
+
+// stopWorkers stops the async workers once their queues are drained. It
+// keeps workers from being started afterwards, and later operations fail
+// with ErrClientClosed.
+func (c *Client) stopWorkers() {
+	c.workersOnce.Do(func() {})
+
+	c.workersMu.Lock()
+	defer c.workersMu.Unlock()
+	if c.workersClosed {
+		return
+	}
+	c.workersClosed = true
+	for _, queue := range c.workers {
+		close(queue)
+	}
+	c.workers = nil
+}
+
+// bufferPutAsync adds a key-value pair to the write buffer along with its
+// callback. A full buffer is flushed in the background.
+func (c *Client) bufferPutAsync(key, value []byte, done func(error)) {
+	b := c.buffer
+	b.mu.Lock()
+	kv := &proto.KeyValue{Key: key, Value: value}
+	if i, ok := b.index[string(key)]; ok {
+		b.pending[i] = kv
+	} else {
+		b.index[string(key)] = len(b.pending)
+		b.pending = append(b.pending, kv)
+	}
+	b.callbacks = append(b.callbacks, done)
+	full := len(b.pending) >= b.cfg.MaxPending
+	b.mu.Unlock()
+
+	if !full {
+		return
+	}
+
+	c.outstanding <- struct{}{}
+	go func() {
+		defer func() { <-c.outstanding }()
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if err := c.flushLocked(); err != nil {
+			b.err = err
+		}
+	}()
+}
+
+// runCallbacks calls each async callback with err
+func (c *Client) runCallbacks(callbacks []func(error), err error) {
+	for _, done := range callbacks {
+		done(err)
+		c.async.Done()
+	}
+}
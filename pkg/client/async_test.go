@@ -0,0 +1,180 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"godatabase/internal/storage"
+)
+
+func TestClient_PutAsync(t *testing.T) {
+	newClients := map[string]func(addr string) (*Client, error){
+		"unbuffered": func(addr string) (*Client, error) {
+			return NewClient(addr, WithMaxOutstanding(8))
+		},
+		"buffered": func(addr string) (*Client, error) {
+			return NewBufferedClient(addr, BufferConfig{MaxPending: 50})
+		},
+	}
+
+	for name, newClient := range newClients {
+		store, err := storage.NewBadgerStorage(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+
+		c, err := newClient(startServer(t, store))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		const n = 1000
+		results := make([]<-chan error, n)
+		for i := 0; i < n; i++ {
+			key := []byte(fmt.Sprintf("key%04d", i))
+			results[i] = c.PutAsync(key, []byte(fmt.Sprintf("value%d", i)))
+		}
+		// Buffered writes below MaxPending only leave on a flush
+		if err := c.Flush(); err != nil {
+			t.Fatalf("%s: Flush failed: %v", name, err)
+		}
+
+		// Flush waited for every put, so every result is already in
+		failed := 0
+		for _, result := range results {
+			select {
+			case err := <-result:
+				if err != nil {
+					failed++
+				}
+			default:
+				t.Fatalf("%s: Expected every async Put to be done after Flush", name)
+			}
+		}
+		if failed != 0 {
+			t.Errorf("%s: Expected every async Put to succeed, %d failed", name, failed)
+		}
+		for i := 0; i < n; i++ {
+			value, err := store.Get([]byte(fmt.Sprintf("key%04d", i)))
+			if err != nil || string(value) != fmt.Sprintf("value%d", i) {
+				t.Errorf("%s: Expected key%04d to be readable, got %q, %v", name, i, value, err)
+				break
+			}
+		}
+
+		if err := c.Close(); err != nil {
+			t.Errorf("%s: Close failed: %v", name, err)
+		}
+	}
+}
+
+func TestClient_PutAsyncReportsFailure(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	c, err := NewClient(startServer(t, store), WithMaxMessageSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := <-c.PutAsync([]byte("key"), make([]byte, 4096)); err != ErrValueTooLarge {
+		t.Errorf("Expected ErrValueTooLarge, got %v", err)
+	}
+}
+
+func TestClient_AsyncKeepsPerKeyOrder(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	c, err := NewClient(startServer(t, store), WithMaxOutstanding(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Each key's operations run on one worker, in the order they started
+	var gets []<-chan GetResult
+	var deletes []<-chan error
+	for i := 0; i < 50; i++ {
+		key := []byte(fmt.Sprintf("key%02d", i))
+		c.PutAsync(key, []byte("first"))
+		c.PutAsync(key, []byte("second"))
+		gets = append(gets, c.GetAsync(key))
+		deletes = append(deletes, c.DeleteAsync(key))
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	for i := range gets {
+		if r := <-gets[i]; r.Err != nil || string(r.Value) != "second" {
+			t.Errorf("Expected key%02d to read second, got %q (%v)", i, r.Value, r.Err)
+		}
+		if err := <-deletes[i]; err != nil {
+			t.Errorf("Expected key%02d to be deleted, got %v", i, err)
+		}
+	}
+	if store.Size() != 0 {
+		t.Errorf("Expected every key deleted, got %d left", store.Size())
+	}
+
+	if r := <-c.GetAsync([]byte("missing")); !errors.Is(r.Err, storage.ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", r.Err)
+	}
+}
+
+func TestClient_AsyncAfterClose(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	c, err := NewClient(startServer(t, store), WithMaxOutstanding(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Async calls racing with Close either run or fail cleanly
+	var results []<-chan error
+	stop := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			results = append(results, c.PutAsync([]byte(fmt.Sprintf("key%d", i)), []byte("value")))
+		}
+	}()
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	close(stop)
+	<-started
+	for _, result := range results {
+		<-result
+	}
+
+	if err := <-c.PutAsync([]byte("key"), []byte("value")); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected PutAsync to fail with ErrClientClosed, got %v", err)
+	}
+	if r := <-c.GetAsync([]byte("key")); !errors.Is(r.Err, ErrClientClosed) {
+		t.Errorf("Expected GetAsync to fail with ErrClientClosed, got %v", r.Err)
+	}
+	if err := <-c.DeleteAsync([]byte("key")); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected DeleteAsync to fail with ErrClientClosed, got %v", err)
+	}
+}
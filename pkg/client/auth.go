@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+)
+
+// tokenCredentials attaches a bearer token to every request, for servers
+// created with rpc.NewServerWithAuth
+type tokenCredentials struct {
+	token string
+}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials. The
+// client dials without TLS, so the token is sent in the clear and should
+// only cross trusted networks.
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// WithToken makes the client present token to the server as a bearer
+// token on every request, including after it follows a new leader
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// NewWithToken creates a client for the server at addr, which must have
+// been created with rpc.NewServerWithAuth and the same token. It is
+// NewClient with WithToken.
+func NewWithToken(addr, token string, opts ...Option) (*Client, error) {
+	return NewClient(addr, append(opts, WithToken(token))...)
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"godatabase/internal/rpc"
+	"godatabase/internal/storage"
+)
+
+func TestClient_TokenAuth(t *testing.T) {
+	store := storage.NewMemStorage()
+	addr := freeAddr(t)
+	server, err := rpc.NewServerWithAuth(store, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Start(addr)
+	defer server.Stop()
+
+	// Without a token, or with the wrong one, every call is refused
+	anonymous, err := NewClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer anonymous.Close()
+	wrong, err := NewWithToken(addr, "guess")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wrong.Close()
+	for name, c := range map[string]*Client{"anonymous": anonymous, "wrong token": wrong} {
+		if err := c.Put([]byte("key"), []byte("value")); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s: Expected Put to be unauthenticated, got %v", name, err)
+		}
+		if _, err := c.Get([]byte("key")); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s: Expected Get to be unauthenticated, got %v", name, err)
+		}
+		if _, err := c.Scan(nil, nil); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s: Expected Scan to be unauthenticated, got %v", name, err)
+		}
+	}
+	if store.Size() != 0 {
+		t.Errorf("Expected no writes to get through, got %d keys", store.Size())
+	}
+
+	// Load balancers can still probe health
+	if _, err := anonymous.Health(); err != nil {
+		t.Errorf("Expected Health without a token to succeed, got %v", err)
+	}
+
+	c, err := NewWithToken(addr, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if value, err := c.Get([]byte("key")); err != nil || string(value) != "value" {
+		t.Errorf("Expected value, got %q (%v)", value, err)
+	}
+}
+
+func TestClient_TokenAuthRejectsEmptyToken(t *testing.T) {
+	if _, err := rpc.NewServerWithAuth(storage.NewMemStorage(), ""); !errors.Is(err, rpc.ErrEmptyToken) {
+		t.Errorf("Expected ErrEmptyToken for an empty token, got %v", err)
+	}
+}
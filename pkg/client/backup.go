@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"io"
+
+	"godatabase/internal/rpc/proto"
+)
+
+// Backup streams a point-in-time backup of the server's storage to w, to
+// be restored with storage.Restore. Buffered writes are flushed first so
+// they are included. If the stream fails partway, w holds an incomplete
+// backup and the error is returned.
+func (c *Client) Backup(w io.Writer) error {
+	if err := c.flushBuffer(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.rpc().Backup(ctx, &proto.BackupRequest{})
+	if err != nil {
+		return transportError(err)
+	}
+
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return transportError(err)
+		}
+		if _, err := w.Write(chunk.Data); err != nil {
+			return err
+		}
+	}
+}
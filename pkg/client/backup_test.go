@@ -0,0 +1,61 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"godatabase/internal/storage"
+)
+
+func TestClient_BackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewStorageEngine(filepath.Join(dir, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.SetAutoFlush(false)
+	addr := startServer(t, store)
+
+	// Buffered writes are flushed into the backup
+	c, err := NewBufferedClient(addr, BufferConfig{MaxPending: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Enough data to take several chunks
+	value := func(i int) []byte { return bytes.Repeat([]byte{byte('a' + i%26)}, 2000) }
+	for i := 0; i < 300; i++ {
+		if err := c.Put([]byte(fmt.Sprintf("key%04d", i)), value(i)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	var backup bytes.Buffer
+	if err := c.Backup(&backup); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	path := filepath.Join(dir, "restored.db")
+	if err := storage.Restore(&backup, path); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	restored, err := storage.NewStorageEngine(path)
+	if err != nil {
+		t.Fatalf("Failed to open restored engine: %v", err)
+	}
+	defer restored.Close()
+
+	if restored.Size() != 300 {
+		t.Errorf("Expected 300 keys, got %d", restored.Size())
+	}
+	for i := 0; i < 300; i++ {
+		got, err := restored.Get([]byte(fmt.Sprintf("key%04d", i)))
+		if err != nil || !bytes.Equal(got, value(i)) {
+			t.Fatalf("Expected key%04d to be restored, got %d bytes (%v)", i, len(got), err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"sync"
+	"testing"
+
+	"godatabase/internal/rpc"
+	"godatabase/internal/storage"
+)
+
+// volatileStorage acknowledges writes from memory and only writes them to
+// the durable storage underneath when synced, like a disk cache
+type volatileStorage struct {
+	storage.Storage
+	mu      sync.Mutex
+	pending map[string][]byte
+}
+
+func (v *volatileStorage) Put(key, value []byte) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.pending[string(key)] = append([]byte(nil), value...)
+	return nil
+}
+
+func (v *volatileStorage) Sync() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for key, value := range v.pending {
+		if err := v.Storage.Put([]byte(key), value); err != nil {
+			return err
+		}
+	}
+	v.pending = make(map[string][]byte)
+	return nil
+}
+
+// crash drops every write that was never synced
+func (v *volatileStorage) crash() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.pending = make(map[string][]byte)
+}
+
+func TestClient_BarrierMakesWritesDurable(t *testing.T) {
+	durable, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer durable.Close()
+	store := &volatileStorage{Storage: durable, pending: make(map[string][]byte)}
+
+	addr := freeAddr(t)
+	server := rpc.NewServer(store)
+	go server.Start(addr)
+	defer server.Stop()
+
+	// No timer and a large buffer, so writes only leave the client when
+	// something flushes them
+	c, err := NewBufferedClient(addr, BufferConfig{MaxPending: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := c.Put([]byte(key), []byte("before")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Barrier(); err != nil {
+		t.Fatalf("Barrier failed: %v", err)
+	}
+
+	// Acknowledged by the server but never synced
+	if err := c.Put([]byte("d"), []byte("after")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// Still buffered in the client
+	if err := c.Put([]byte("e"), []byte("after")); err != nil {
+		t.Fatal(err)
+	}
+
+	store.crash()
+
+	for _, key := range []string{"a", "b", "c"} {
+		value, err := durable.Get([]byte(key))
+		if err != nil || string(value) != "before" {
+			t.Errorf("Expected %s to survive the crash, got %q, %v", key, value, err)
+		}
+	}
+	for _, key := range []string{"d", "e"} {
+		if _, err := durable.Get([]byte(key)); err == nil {
+			t.Errorf("Expected %s to be lost without a barrier", key)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"godatabase/internal/rpc/proto"
+	"godatabase/internal/storage"
+)
+
+// NewBatch returns a batch that is sent to the server in a single
+// WriteBatch request when committed
+func (c *Client) NewBatch() storage.WriteBatch {
+	return &clientBatch{client: c}
+}
+
+// DeleteRange removes the server's keys from start, inclusive, up to end,
+// exclusive. There is no range delete RPC, so the keys are read with Scan
+// and deleted with WriteBatch requests of storage.DeleteRangeBatchSize
+// keys each.
+func (c *Client) DeleteRange(start, end []byte) (int, error) {
+	return storage.DeleteRangeInBatches(c, start, end, storage.DeleteRangeBatchSize)
+}
+
+// clientBatch is a WriteBatch sent over RPC
+type clientBatch struct {
+	storage.BatchOps
+	client *Client
+}
+
+// Commit sends the batch in one round trip. Buffered writes are flushed
+// first so the batch is ordered after them. The server commits it with
+// its storage's atomicity.
+func (b *clientBatch) Commit() error {
+	c := b.client
+	if err := c.flushBuffer(); err != nil {
+		return err
+	}
+
+	req := &proto.WriteBatchRequest{
+		Ops: make([]*proto.Operation, 0, len(b.Ops)),
+	}
+	for _, op := range b.Ops {
+		if op.Delete {
+			req.Ops = append(req.Ops, &proto.Operation{Type: proto.Operation_DELETE, Key: op.Key})
+		} else {
+			req.Ops = append(req.Ops, &proto.Operation{Type: proto.Operation_PUT, Key: op.Key, Value: op.Value})
+		}
+	}
+	if err := c.checkSize(req); err != nil {
+		return err
+	}
+
+	return c.withRedirect(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		resp, err := c.rpc().WriteBatch(ctx, req)
+		if err != nil {
+			return transportError(err)
+		}
+
+		if !resp.Success {
+			return fmt.Errorf("write batch failed: %s", resp.Error)
+		}
+		return nil
+	})
+}
+
+// OpType is the kind of an Op
+type OpType int
+
+// Kinds of Op
+const (
+	OpGet OpType = iota
+	OpPut
+	OpDelete
+)
+
+// Op is one operation of a Batch call
+type Op struct {
+	Type  OpType
+	Key   []byte
+	Value []byte
+}
+
+// Result is the outcome of one Op of a Batch call
+type Result struct {
+	// Value is the value read by an OpGet
+	Value []byte
+	// Found is false if an OpGet found no value
+	Found bool
+	// Err is why the operation failed, or nil if it succeeded
+	Err error
+}
+
+// Batch sends ops to the server in a single round trip and returns a
+// result for each, in order. Ops made up only of puts and deletes are
+// committed together with the server storage's batch atomicity; otherwise
+// the ops are applied one at a time and a failed op doesn't stop the rest.
+// Deleting a missing key isn't an error. Buffered writes are flushed first
+// so the batch is ordered after them.
+func (c *Client) Batch(ops []Op) ([]Result, error) {
+	if err := c.flushBuffer(); err != nil {
+		return nil, err
+	}
+
+	req := &proto.BatchRequest{
+		Ops: make([]*proto.BatchOp, 0, len(ops)),
+	}
+	for _, op := range ops {
+		var opType proto.BatchOp_Type
+		switch op.Type {
+		case OpGet:
+			opType = proto.BatchOp_GET
+		case OpPut:
+			opType = proto.BatchOp_PUT
+		case OpDelete:
+			opType = proto.BatchOp_DELETE
+		default:
+			return nil, fmt.Errorf("unknown operation type %d", op.Type)
+		}
+		req.Ops = append(req.Ops, &proto.BatchOp{Type: opType, Key: op.Key, Value: op.Value})
+	}
+	if err := c.checkSize(req); err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	err := c.withRedirect(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		resp, err := c.rpc().Batch(ctx, req)
+		if err != nil {
+			return transportError(err)
+		}
+
+		if resp.Error != "" {
+			return fmt.Errorf("batch failed: %s", resp.Error)
+		}
+		if len(resp.Results) != len(ops) {
+			return fmt.Errorf("batch failed: expected %d results, got %d", len(ops), len(resp.Results))
+		}
+		results = make([]Result, len(resp.Results))
+		for i, r := range resp.Results {
+			results[i] = Result{Value: r.Value, Found: r.Found}
+			if r.Error != "" {
+				results[i].Err = fmt.Errorf("%s failed: %s", opNames[ops[i].Type], r.Error)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// opNames names each OpType in errors
+var opNames = map[OpType]string{
+	OpGet:    "get",
+	OpPut:    "put",
+	OpDelete: "delete",
+}
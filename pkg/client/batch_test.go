@@ -0,0 +1,141 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+
+	"godatabase/internal/storage"
+)
+
+func TestClient_WriteBatch(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Put([]byte("old"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	batch := c.NewBatch()
+	for i := 0; i < 50; i++ {
+		batch.Put([]byte(fmt.Sprintf("key%02d", i)), []byte(fmt.Sprintf("value%02d", i)))
+	}
+	batch.Delete([]byte("old"))
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	if store.Size() != 50 {
+		t.Errorf("Expected 50 keys on the server, got %d", store.Size())
+	}
+	if value, err := c.Get([]byte("key49")); err != nil || string(value) != "value49" {
+		t.Errorf("Expected value49, got %q (%v)", value, err)
+	}
+	if _, err := store.Get([]byte("old")); err == nil {
+		t.Error("Expected old to be deleted")
+	}
+}
+
+func TestClient_BatchMixedOperations(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("old%02d", i)
+		if err := store.Put([]byte(key), []byte("value-"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each group reads back its own put and its own delete, so the ops
+	// must be applied in order
+	var ops []Op
+	for i := 0; i < 50; i++ {
+		newKey := []byte(fmt.Sprintf("new%02d", i))
+		oldKey := []byte(fmt.Sprintf("old%02d", i))
+		ops = append(ops,
+			Op{Type: OpPut, Key: newKey, Value: []byte(fmt.Sprintf("value-new%02d", i))},
+			Op{Type: OpGet, Key: newKey},
+			Op{Type: OpDelete, Key: oldKey},
+			Op{Type: OpGet, Key: oldKey},
+		)
+	}
+	results, err := c.Batch(ops)
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	if len(results) != 200 {
+		t.Fatalf("Expected 200 results, got %d", len(results))
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			t.Errorf("Expected op %d to succeed, got %v", i, r.Err)
+		}
+		switch i % 4 {
+		case 1:
+			expected := fmt.Sprintf("value-new%02d", i/4)
+			if !r.Found || string(r.Value) != expected {
+				t.Errorf("Expected op %d to read %s, got %q (found %v)", i, expected, r.Value, r.Found)
+			}
+		case 3:
+			if r.Found {
+				t.Errorf("Expected op %d to find its key deleted, got %q", i, r.Value)
+			}
+		}
+	}
+
+	if store.Size() != 50 {
+		t.Errorf("Expected 50 keys, got %d", store.Size())
+	}
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("new%02d", i)
+		if value, err := store.Get([]byte(key)); err != nil || string(value) != "value-"+key {
+			t.Errorf("Expected %s on the server, got %q (%v)", key, value, err)
+		}
+	}
+}
+
+func TestClient_BatchWritesOnly(t *testing.T) {
+	store := storage.NewMemStorage()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	store.Put([]byte("gone"), []byte("x"))
+	results, err := c.Batch([]Op{
+		{Type: OpPut, Key: []byte("a"), Value: []byte("1")},
+		{Type: OpPut, Key: []byte("b"), Value: []byte("2")},
+		{Type: OpDelete, Key: []byte("gone")},
+	})
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	for i, r := range results {
+		if r.Err != nil || r.Found {
+			t.Errorf("Expected op %d to succeed with no value, got %+v", i, r)
+		}
+	}
+	if store.Size() != 2 {
+		t.Errorf("Expected 2 keys, got %d", store.Size())
+	}
+	if _, err := store.Get([]byte("gone")); err == nil {
+		t.Error("Expected gone to be deleted")
+	}
+}
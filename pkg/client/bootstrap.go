@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"godatabase/internal/rpc/proto"
+	"godatabase/internal/storage"
+)
+
+// Bootstrap copies a consistent snapshot of the server's data into dst and
+// returns the version the snapshot reflects. Passing that version to Follow
+// continues with exactly the writes made after the snapshot.
+func (c *Client) Bootstrap(dst storage.Storage) (int64, error) {
+	if err := c.flushBuffer(); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := c.rpc().Bootstrap(ctx, &proto.BootstrapRequest{})
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return 0, errors.New("bootstrap ended without a version marker")
+		}
+		if err != nil {
+			return 0, transportError(err)
+		}
+
+		if msg.Done {
+			return msg.Version, nil
+		}
+		if err := dst.Put(msg.Pair.Key, msg.Pair.Value); err != nil {
+			return 0, fmt.Errorf("failed to apply snapshot: %v", err)
+		}
+	}
+}
+
+// Follow applies every write published to the server's change feed after
+// version to dst, in order, until ctx is cancelled or the stream fails.
+// Over Raft storage that is every committed write, wherever it was made;
+// otherwise it is the writes made through the server.
+func (c *Client) Follow(ctx context.Context, version int64, dst storage.Storage) error {
+	stream, err := c.rpc().StreamOperations(ctx, &proto.StreamRequest{
+		FromVersion: version,
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		op, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		switch op.Type {
+		case proto.Operation_PUT:
+			err = dst.Put(op.Key, op.Value)
+		case proto.Operation_DELETE:
+			if err = dst.Delete(op.Key); errors.Is(err, storage.ErrKeyNotFound) {
+				err = nil
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to apply operation %d: %v", op.Version, err)
+		}
+	}
+}
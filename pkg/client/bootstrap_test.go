@@ -0,0 +1,389 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"godatabase/internal/rpc"
+	"godatabase/internal/storage"
+)
+
+// putCounter counts how many times each key is written to the wrapped storage
+type putCounter struct {
+	storage.Storage
+	mu   sync.Mutex
+	puts map[string]int
+}
+
+func (p *putCounter) Put(key, value []byte) error {
+	p.mu.Lock()
+	p.puts[string(key)]++
+	p.mu.Unlock()
+	return p.Storage.Put(key, value)
+}
+
+func (p *putCounter) count(key string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.puts[key]
+}
+
+func TestClient_BootstrapThenFollow(t *testing.T) {
+	primary, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+
+	addr := freeAddr(t)
+	server := rpc.NewServer(primary)
+	go server.Start(addr)
+	defer server.Stop()
+
+	c, err := NewClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		if err := c.Put([]byte(fmt.Sprintf("key%03d", i)), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backing, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backing.Close()
+	replica := &putCounter{Storage: backing, puts: make(map[string]int)}
+
+	version, err := c.Bootstrap(replica)
+	if err != nil {
+		t.Fatalf("Bootstrap failed: %v", err)
+	}
+	if version != 100 {
+		t.Errorf("Expected snapshot version 100, got %d", version)
+	}
+	if size := replica.Size(); size != 100 {
+		t.Errorf("Expected 100 keys after bootstrap, got %d", size)
+	}
+
+	// A write between bootstrap and follow must not be missed
+	if err := c.Put([]byte("between"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	followErr := make(chan error, 1)
+	go func() {
+		followErr <- c.Follow(ctx, version, replica)
+	}()
+
+	if err := c.Put([]byte("after"), []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for replica.count("after") == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-followErr; err != nil {
+		t.Fatalf("Follow failed: %v", err)
+	}
+
+	for _, key := range []string{"key000", "key099", "between", "after"} {
+		if n := replica.count(key); n != 1 {
+			t.Errorf("Expected %s applied exactly once, got %d", key, n)
+		}
+	}
+	if size := replica.Size(); size != 102 {
+		t.Errorf("Expected 102 keys on replica, got %d", size)
+	}
+}
+
+func TestClient_BootstrapDuringWrites(t *testing.T) {
+	primary, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+
+	addr := freeAddr(t)
+	server := rpc.NewServer(primary)
+	go server.Start(addr)
+	defer server.Stop()
+
+	c, err := NewClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		if err := c.Put([]byte(fmt.Sprintf("key%03d", i)), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Writes through the server are versioned; writes straight to the
+	// storage, like a Raft apply from another node, add larger keys that
+	// must not push the smallest ones out of the snapshot
+	const written = 200
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < written; i++ {
+			if err := c.Put([]byte(fmt.Sprintf("new%03d", i)), []byte("value")); err != nil {
+				t.Errorf("Put failed: %v", err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			primary.Put([]byte(fmt.Sprintf("zzz%06d", i)), []byte("value"))
+		}
+	}()
+
+	backing, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backing.Close()
+	replica := &putCounter{Storage: backing, puts: make(map[string]int)}
+
+	version, err := c.Bootstrap(replica)
+	close(stop)
+	if err != nil {
+		t.Fatalf("Bootstrap failed: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if key := fmt.Sprintf("key%03d", i); replica.count(key) != 1 {
+			t.Fatalf("Expected %s in the snapshot", key)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	followErr := make(chan error, 1)
+	go func() {
+		followErr <- c.Follow(ctx, version, replica)
+	}()
+	wg.Wait()
+
+	last := fmt.Sprintf("new%03d", written-1)
+	deadline := time.Now().Add(5 * time.Second)
+	for replica.count(last) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-followErr; err != nil {
+		t.Fatalf("Follow failed: %v", err)
+	}
+
+	// Each versioned write is in the snapshot or the feed, never both
+	for i := 0; i < written; i++ {
+		if key := fmt.Sprintf("new%03d", i); replica.count(key) != 1 {
+			t.Errorf("Expected %s applied exactly once, got %d", key, replica.count(key))
+		}
+	}
+}
+
+// versionedMem is a MemStorage that versions its own writes as it applies
+// them, like raft.RaftStorage. A Put of blockKey waits for release before
+// it is applied, like a write waiting on replication.
+type versionedMem struct {
+	*storage.MemStorage
+	blockKey string
+	release  chan struct{}
+
+	mu      sync.Mutex
+	version int64
+	applied func(version int64, ops []storage.BatchOp)
+}
+
+func (v *versionedMem) Put(key, value []byte) error {
+	if string(key) == v.blockKey {
+		<-v.release
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.MemStorage.Put(key, value); err != nil {
+		return err
+	}
+	v.version++
+	if v.applied != nil {
+		v.applied(v.version, []storage.BatchOp{{Key: key, Value: value}})
+	}
+	return nil
+}
+
+func (v *versionedMem) ObserveApplied(applied func(version int64, ops []storage.BatchOp), reset func(version int64)) (int64, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.applied = applied
+	return v.version, nil
+}
+
+func (v *versionedMem) VersionedGet(key []byte) ([]byte, int64, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	value, err := v.MemStorage.Get(key)
+	return value, v.version, err
+}
+
+func (v *versionedMem) VersionedScan(start, end []byte) (storage.Iterator, int64, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	it, err := v.MemStorage.Scan(start, end)
+	return it, v.version, err
+}
+
+func TestClient_VersionedStorageWritesAreNotSerialized(t *testing.T) {
+	primary := &versionedMem{
+		MemStorage: storage.NewMemStorage(),
+		blockKey:   "slow",
+		release:    make(chan struct{}),
+	}
+
+	addr := freeAddr(t)
+	server := rpc.NewServer(primary)
+	go server.Start(addr)
+	defer server.Stop()
+
+	c, err := NewClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	slowErr := make(chan error, 1)
+	go func() {
+		slowErr <- c.Put([]byte("slow"), []byte("1"))
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// Neither another write nor a bootstrap waits for the slow write
+	fastErr := make(chan error, 1)
+	go func() {
+		fastErr <- c.Put([]byte("fast"), []byte("1"))
+	}()
+	select {
+	case err := <-fastErr:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		close(primary.release)
+		t.Fatal("Expected a write to proceed while another waits to be applied")
+	}
+
+	replica := &putCounter{Storage: storage.NewMemStorage(), puts: make(map[string]int)}
+	version, err := c.Bootstrap(replica)
+	if err != nil {
+		t.Fatalf("Bootstrap failed: %v", err)
+	}
+	if version != 1 || replica.count("fast") != 1 {
+		t.Errorf("Expected a snapshot of the fast write at version 1, got version %d", version)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	followErr := make(chan error, 1)
+	go func() {
+		followErr <- c.Follow(ctx, version, replica)
+	}()
+
+	// The slow write is published at the version the storage gives it
+	close(primary.release)
+	if err := <-slowErr; err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for replica.count("slow") == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-followErr; err != nil {
+		t.Fatalf("Follow failed: %v", err)
+	}
+	if replica.count("slow") != 1 || replica.count("fast") != 1 {
+		t.Errorf("Expected each write applied once, got slow %d, fast %d", replica.count("slow"), replica.count("fast"))
+	}
+}
+
+func TestServer_StopEndsOpenStreams(t *testing.T) {
+	primary, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+
+	addr := freeAddr(t)
+	server := rpc.NewServer(primary)
+	go server.Start(addr)
+
+	c, err := NewClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	replica := storage.NewMemStorage()
+	followErr := make(chan error, 1)
+	go func() {
+		followErr <- c.Follow(context.Background(), 0, replica)
+	}()
+	waitErr := make(chan error, 1)
+	go func() {
+		_, err := c.WaitFor(context.Background(), []byte("never"), func(value []byte, found bool) bool {
+			return found
+		})
+		waitErr <- err
+	}()
+
+	// Once a write reaches the replica the stream is open
+	if err := c.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := replica.Get([]byte("key")); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Follow never applied the write")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		server.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Stop to end the open streams instead of waiting for them")
+	}
+	if err := <-followErr; err == nil {
+		t.Error("Expected Follow to fail when the server stops")
+	}
+	if err := <-waitErr; err == nil {
+		t.Error("Expected WaitFor to fail when the server stops")
+	}
+}
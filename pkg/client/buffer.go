@@ -0,0 +1,212 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"godatabase/internal/rpc/proto"
+)
+
+// BufferConfig controls client-side write buffering.
+//
+// Buffering trades durability for latency: a buffered Put returns as soon as
+// the pair is held in client memory, before the server has stored it. Writes
+// still in the buffer are lost if the process exits without calling Flush or
+// Close. A failed background flush keeps the writes buffered and is reported
+// by the next Put; Flush and Close retry them and report their own result.
+type BufferConfig struct {
+	// MaxPending is the number of buffered writes that triggers a flush.
+	MaxPending int
+
+	// FlushInterval is how often buffered writes are flushed in the
+	// background. Zero disables the timer and flushes only on size, Flush,
+	// or Close.
+	FlushInterval time.Duration
+}
+
+// DefaultBufferConfig returns a buffer configuration suitable for most
+// chatty write workloads.
+func DefaultBufferConfig() BufferConfig {
+	return BufferConfig{
+		MaxPending:    100,
+		FlushInterval: 50 * time.Millisecond,
+	}
+}
+
+// writeBuffer holds Puts that have not yet been sent to the server.
+type writeBuffer struct {
+	cfg     BufferConfig
+	pending []*proto.KeyValue
+	index   map[string]int // key -> position in pending
+	err     error          // error from the last background flush
+	mu      sync.Mutex
+
+	// Callbacks of PutAsync calls whose writes are in pending
+	callbacks []func(error)
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewBufferedClient creates a client whose Puts are buffered locally and
+// sent to the server in batches. See BufferConfig for the durability
+// trade-offs this implies.
+func NewBufferedClient(addr string, cfg BufferConfig, opts ...Option) (*Client, error) {
+	if cfg.MaxPending <= 0 {
+		return nil, fmt.Errorf("invalid buffer size: %d", cfg.MaxPending)
+	}
+
+	c, err := NewClient(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.buffer = &writeBuffer{
+		cfg:   cfg,
+		index: make(map[string]int),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go c.flushLoop()
+
+	return c, nil
+}
+
+// flushLoop periodically flushes the write buffer until Close is called.
+func (c *Client) flushLoop() {
+	b := c.buffer
+	defer close(b.done)
+
+	if b.cfg.FlushInterval <= 0 {
+		<-b.stop
+		return
+	}
+
+	ticker := time.NewTicker(b.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.mu.Lock()
+			if err := c.flushLocked(); err != nil {
+				b.err = err
+			}
+			b.mu.Unlock()
+		}
+	}
+}
+
+// bufferPut adds a key-value pair to the write buffer, flushing it when it
+// reaches the configured size.
+func (c *Client) bufferPut(key, value []byte) error {
+	b := c.buffer
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.err; err != nil {
+		b.err = nil
+		return fmt.Errorf("buffered flush failed: %v", err)
+	}
+
+	// Copy the caller's slices since they may be reused after Put returns
+	kv := &proto.KeyValue{
+		Key:   append([]byte(nil), key...),
+		Value: append([]byte(nil), value...),
+	}
+	if i, ok := b.index[string(key)]; ok {
+		b.pending[i] = kv
+	} else {
+		b.index[string(key)] = len(b.pending)
+		b.pending = append(b.pending, kv)
+	}
+
+	if len(b.pending) >= b.cfg.MaxPending {
+		return c.flushLocked()
+	}
+	return nil
+}
+
+// bufferedGet returns the buffered value for key, if any.
+func (c *Client) bufferedGet(key []byte) ([]byte, bool) {
+	b := c.buffer
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i, ok := b.index[string(key)]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), b.pending[i].Value...), true
+}
+
+// flushLocked sends all buffered writes as a single BatchPut and reports
+// the result to any PutAsync callbacks waiting on them.
+// The caller must hold the buffer mutex. On failure the writes stay
+// buffered so a later flush can retry them.
+func (c *Client) flushLocked() error {
+	b := c.buffer
+	if len(b.pending) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := c.rpc().BatchPut(ctx, &proto.BatchPutRequest{
+		Pairs: b.pending,
+	})
+	if err != nil {
+		err = transportError(err)
+	} else if !resp.Success {
+		err = fmt.Errorf("batch put failed: %s", resp.Error)
+	}
+
+	// Async writers learn the outcome of the first flush that carried
+	// their write, even though failed writes stay buffered for a retry
+	if len(b.callbacks) > 0 {
+		go c.runCallbacks(b.callbacks, err)
+		b.callbacks = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	b.pending = nil
+	b.index = make(map[string]int)
+	return nil
+}
+
+// Flush sends any buffered writes to the server and waits for them to be
+// acknowledged, then waits for every outstanding async operation to
+// finish. It returns the buffered flush's error; async operations report
+// theirs on their own channels.
+func (c *Client) Flush() error {
+	err := c.flushBuffer()
+	c.async.Wait()
+	return err
+}
+
+// flushBuffer sends any buffered writes to the server and waits for them
+// to be acknowledged. It is a no-op for unbuffered clients. Operations
+// that must be ordered after buffered writes call it rather than Flush,
+// which would wait on the async workers that run them.
+func (c *Client) flushBuffer() error {
+	if c.buffer == nil {
+		return nil
+	}
+
+	b := c.buffer
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Failed writes stay buffered, so a successful retry here supersedes
+	// any earlier background error.
+	b.err = nil
+	return c.flushLocked()
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+
+	"godatabase/internal/rpc/proto"
+
+	"google.golang.org/grpc"
+)
+
+// batchRecorder is a minimal Storage server that records BatchPut sizes.
+type batchRecorder struct {
+	proto.UnimplementedStorageServer
+	mu      sync.Mutex
+	data    map[string][]byte
+	batches []int
+}
+
+func (s *batchRecorder) BatchPut(ctx context.Context, req *proto.BatchPutRequest) (*proto.BatchPutResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, kv := range req.Pairs {
+		s.data[string(kv.Key)] = kv.Value
+	}
+	s.batches = append(s.batches, len(req.Pairs))
+	return &proto.BatchPutResponse{Success: true}, nil
+}
+
+func (s *batchRecorder) Get(ctx context.Context, req *proto.GetRequest) (*proto.GetResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.data[string(req.Key)]
+	if !ok {
+		return &proto.GetResponse{Found: false, Error: "key not found"}, nil
+	}
+	return &proto.GetResponse{Value: value, Found: true}, nil
+}
+
+func startBatchRecorder(t *testing.T) (*batchRecorder, string) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &batchRecorder{data: make(map[string][]byte)}
+	server := grpc.NewServer()
+	proto.RegisterStorageServer(server, rec)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	return rec, lis.Addr().String()
+}
+
+func TestBufferedClient_FlushesInBatches(t *testing.T) {
+	rec, addr := startBatchRecorder(t)
+
+	// Disable the timer so only size-based flushes happen
+	c, err := NewBufferedClient(addr, BufferConfig{MaxPending: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 25; i++ {
+		key := []byte(fmt.Sprintf("key%d", i))
+		if err := c.Put(key, []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	// Buffered but unflushed keys must be readable locally
+	value, err := c.Get([]byte("key24"))
+	if err != nil {
+		t.Fatalf("Get of buffered key failed: %v", err)
+	}
+	if string(value) != "value24" {
+		t.Errorf("Expected value24, got %s", value)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	expected := []int{10, 10, 5}
+	if fmt.Sprint(rec.batches) != fmt.Sprint(expected) {
+		t.Errorf("Expected batches %v, got %v", expected, rec.batches)
+	}
+	if len(rec.data) != 25 {
+		t.Errorf("Expected 25 keys on server, got %d", len(rec.data))
+	}
+}
+
+func TestBufferedClient_ExplicitFlush(t *testing.T) {
+	rec, addr := startBatchRecorder(t)
+
+	c, err := NewBufferedClient(addr, DefaultBufferConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if string(rec.data["key"]) != "value" {
+		t.Errorf("Expected key to be flushed to server")
+	}
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"godatabase/internal/rpc/proto"
+)
+
+// Feature names reported by servers in Capabilities
+const (
+	FeaturePut              = "put"
+	FeaturePutTTL           = "put_ttl"
+	FeatureGet              = "get"
+	FeatureDelete           = "delete"
+	FeatureDeleteIf         = "delete_if"
+	FeatureCompareAndSwap   = "compare_and_swap"
+	FeatureIncrement        = "increment"
+	FeatureBatchPut         = "batch_put"
+	FeatureWriteBatch       = "write_batch"
+	FeatureBatch            = "batch"
+	FeatureTail             = "tail"
+	FeatureFingerprint      = "fingerprint"
+	FeatureSplitRanges      = "split_ranges"
+	FeatureScan             = "scan"
+	FeatureScanPrefix       = "scan_prefix"
+	FeatureKeys             = "keys"
+	FeatureStats            = "stats"
+	FeatureMetrics          = "metrics"
+	FeatureSize             = "size"
+	FeatureBarrier          = "barrier"
+	FeatureClusterInfo      = "cluster_info"
+	FeatureCapabilities     = "capabilities"
+	FeatureHealth           = "health"
+	FeatureBootstrap        = "bootstrap"
+	FeatureStreamOperations = "stream_operations"
+	FeatureLoadOperations   = "load_operations"
+	FeatureWatch            = "watch"
+	FeatureBackup           = "backup"
+	FeatureRangeHashes      = "range_hashes"
+	FeatureScanRanges       = "scan_ranges"
+)
+
+// ErrMissingCapability is returned when a server lacks a feature the client
+// was configured to require
+var ErrMissingCapability = errors.New("server is missing a required capability")
+
+// Capabilities describes what a server supports
+type Capabilities struct {
+	Version  string
+	Features []string
+	Backend  string // storage backend type, e.g. "badger", "custom", "memory" or "raft"
+
+	// Size limits in bytes; 0 means the server reports no limit of that kind
+	MaxMessageSize int
+	MaxKeySize     int
+	MaxValueSize   int
+}
+
+// Supports reports whether the server implements feature
+func (c *Capabilities) Supports(feature string) bool {
+	for _, f := range c.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Missing returns the features in required that the server doesn't support
+func (c *Capabilities) Missing(required ...string) []string {
+	var missing []string
+	for _, feature := range required {
+		if !c.Supports(feature) {
+			missing = append(missing, feature)
+		}
+	}
+	return missing
+}
+
+// WithRequiredFeatures makes the client check on connect that the server
+// supports every listed feature, failing with ErrMissingCapability if not
+func WithRequiredFeatures(features ...string) Option {
+	return func(o *options) {
+		o.requiredFeatures = append(o.requiredFeatures, features...)
+	}
+}
+
+// Capabilities returns what the server supports. The answer is fetched
+// once and cached for the life of the client.
+func (c *Client) Capabilities() (*Capabilities, error) {
+	c.capsMu.Lock()
+	defer c.capsMu.Unlock()
+
+	if c.caps != nil {
+		return c.caps, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := c.rpc().Capabilities(ctx, &proto.CapabilitiesRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	c.caps = &Capabilities{
+		Version:        resp.Version,
+		Features:       resp.Features,
+		Backend:        resp.Backend,
+		MaxMessageSize: int(resp.MaxMessageSize),
+		MaxKeySize:     int(resp.MaxKeySize),
+		MaxValueSize:   int(resp.MaxValueSize),
+	}
+	return c.caps, nil
+}
+
+// checkRequiredFeatures fails if the server lacks any required feature
+func (c *Client) checkRequiredFeatures() error {
+	if len(c.opts.requiredFeatures) == 0 {
+		return nil
+	}
+
+	caps, err := c.Capabilities()
+	if err != nil {
+		return fmt.Errorf("failed to fetch capabilities: %v", err)
+	}
+	if missing := caps.Missing(c.opts.requiredFeatures...); len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrMissingCapability, strings.Join(missing, ", "))
+	}
+	return nil
+}
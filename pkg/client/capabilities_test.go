@@ -0,0 +1,113 @@
+package client
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"godatabase/internal/btree"
+	"godatabase/internal/rpc"
+	"godatabase/internal/storage"
+)
+
+func startServer(t *testing.T, store storage.Storage) string {
+	addr := freeAddr(t)
+	server := rpc.NewServer(store)
+	go server.Start(addr)
+	t.Cleanup(server.Stop)
+	return addr
+}
+
+func TestClient_Capabilities(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	addr := startServer(t, store)
+
+	c, err := NewClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	caps, err := c.Capabilities()
+	if err != nil {
+		t.Fatalf("Capabilities failed: %v", err)
+	}
+
+	if caps.Version != rpc.Version {
+		t.Errorf("Expected version %s, got %s", rpc.Version, caps.Version)
+	}
+	if caps.Backend != "badger" {
+		t.Errorf("Expected badger backend, got %s", caps.Backend)
+	}
+	if caps.MaxMessageSize != 4*1024*1024 {
+		t.Errorf("Expected 4MB message limit, got %d", caps.MaxMessageSize)
+	}
+
+	expected := []string{
+		FeaturePut, FeaturePutTTL, FeatureGet, FeatureDelete, FeatureDeleteIf, FeatureCompareAndSwap, FeatureIncrement, FeatureBatchPut, FeatureWriteBatch, FeatureBatch,
+		FeatureTail, FeatureFingerprint, FeatureSplitRanges, FeatureScan, FeatureScanPrefix, FeatureKeys, FeatureStats, FeatureMetrics, FeatureSize, FeatureBarrier, FeatureClusterInfo, FeatureCapabilities, FeatureHealth,
+		FeatureBootstrap, FeatureStreamOperations, FeatureLoadOperations, FeatureWatch, FeatureBackup,
+		FeatureRangeHashes, FeatureScanRanges,
+	}
+	if missing := caps.Missing(expected...); len(missing) > 0 {
+		t.Errorf("Expected server to support %v", missing)
+	}
+	if len(caps.Features) != len(expected) {
+		t.Errorf("Expected %d features, got %v", len(expected), caps.Features)
+	}
+
+	// Later calls are served from the cache
+	again, err := c.Capabilities()
+	if err != nil || again != caps {
+		t.Error("Expected cached capabilities")
+	}
+}
+
+func TestClient_CapabilitiesReportBackendLimits(t *testing.T) {
+	store, err := storage.NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	caps, err := c.Capabilities()
+	if err != nil {
+		t.Fatalf("Capabilities failed: %v", err)
+	}
+	if caps.Backend != "custom" {
+		t.Errorf("Expected custom backend, got %s", caps.Backend)
+	}
+	if caps.MaxKeySize != btree.BTREE_MAX_KEY_SIZE || caps.MaxValueSize != btree.BTREE_MAX_VAL_SIZE {
+		t.Errorf("Expected B+Tree limits, got key %d value %d", caps.MaxKeySize, caps.MaxValueSize)
+	}
+}
+
+func TestClient_RequiredFeatures(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	addr := startServer(t, store)
+
+	c, err := NewClient(addr, WithRequiredFeatures(FeatureTail, FeatureDeleteIf))
+	if err != nil {
+		t.Fatalf("Expected supported features to connect, got %v", err)
+	}
+	c.Close()
+
+	_, err = NewClient(addr, WithRequiredFeatures(FeatureTail, "transactions"))
+	if !errors.Is(err, ErrMissingCapability) {
+		t.Errorf("Expected ErrMissingCapability, got %v", err)
+	}
+}
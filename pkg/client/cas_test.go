@@ -0,0 +1,41 @@
+package client
+
+import (
+	"testing"
+
+	"godatabase/internal/storage"
+)
+
+func TestClient_CompareAndSwap(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if swapped, err := c.CompareAndSwap([]byte("key"), nil, []byte("v1")); err != nil || !swapped {
+		t.Fatalf("Expected swap of absent key, got %v (%v)", swapped, err)
+	}
+	if swapped, _ := c.CompareAndSwap([]byte("key"), nil, []byte("v2")); swapped {
+		t.Error("Expected swap with nil old to fail for existing key")
+	}
+	if swapped, _ := c.CompareAndSwap([]byte("key"), []byte("wrong"), []byte("v2")); swapped {
+		t.Error("Expected swap with wrong old to fail")
+	}
+	if swapped, err := c.CompareAndSwap([]byte("key"), []byte("v1"), []byte("v2")); err != nil || !swapped {
+		t.Errorf("Expected swap with matching old, got %v (%v)", swapped, err)
+	}
+	if value, _ := store.Get([]byte("key")); string(value) != "v2" {
+		t.Errorf("Expected v2 on the server, got %q", value)
+	}
+
+	// An empty old must not be sent as "absent"
+	if swapped, _ := c.CompareAndSwap([]byte("missing"), []byte{}, []byte("v")); swapped {
+		t.Error("Expected empty old not to match a missing key")
+	}
+}
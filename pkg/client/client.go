@@ -2,115 +2,833 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"godatabase/internal/rpc/proto"
+	"godatabase/internal/storage"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
+// NodeInfo describes a server's role in its cluster
+type NodeInfo struct {
+	NodeID   string
+	IsLeader bool
+	LeaderID string // empty if the server doesn't currently know the leader
+
+	// LeaderAddress is where clients can reach the leader, empty if the
+	// server doesn't know it
+	LeaderAddress string
+
+	// MaxMessageSize is the largest message the server accepts, in bytes
+	MaxMessageSize int
+}
+
 // Client represents a client for the distributed key-value store
 // It implements the storage.Storage interface
 type Client struct {
 	conn   *grpc.ClientConn
 	client proto.StorageClient
+	addr   string
+	opts   options
+	buffer *writeBuffer // nil unless created with NewBufferedClient
+
+	// Server message size limit, discovered on first use
+	serverLimit      int
+	serverLimitKnown bool
+	limitMu          sync.Mutex
+
+	// Server capabilities, fetched on first use
+	caps   *Capabilities
+	capsMu sync.Mutex
+
+	// Background buffer flushes in flight, and async operations whose
+	// results haven't been delivered
+	outstanding chan struct{}
+	async       sync.WaitGroup
+
+	// Queues of the workers that run async operations, started on first
+	// use; see PutAsync. workersMu guards closing them, after which
+	// workersClosed is set.
+	workers       []chan func()
+	workersOnce   sync.Once
+	workersMu     sync.RWMutex
+	workersClosed bool
+
+	// Cluster addresses to look for the leader at, nil for a client that
+	// only talks to the server it dialed. connMu guards conn, client and
+	// addr, which change when the client follows the leader; connections
+	// it moved away from are closed with the client.
+	seeds   []string
+	connMu  sync.RWMutex
+	retired []*grpc.ClientConn
 }
 
-// New creates a new client (alias for NewClient)
-func New(addr string) (*Client, error) {
-	return NewClient(addr)
+// New creates a client for a cluster, connecting to the first of seeds
+// that answers. Writes the server refuses because it isn't the leader,
+// or fails because it is unreachable, are retried on the leader: the
+// client learns the leader's address from ClusterInfo, or from the other
+// seeds, and reconnects to it. See WithMaxRedirects.
+func New(seeds []string, opts ...Option) (*Client, error) {
+	if len(seeds) == 0 {
+		return nil, errors.New("no seed addresses")
+	}
+
+	var err error
+	for _, addr := range seeds {
+		var c *Client
+		c, err = NewClient(addr, opts...)
+		if err == nil {
+			c.seeds = append([]string(nil), seeds...)
+			return c, nil
+		}
+	}
+	return nil, err
+}
+
+// NewClient creates a client for the server at addr. It only ever talks
+// to that server; use New to follow a cluster's leader.
+func NewClient(addr string, opts ...Option) (*Client, error) {
+	return dial(addr, true, opts...)
 }
 
-// NewClient creates a new client
-func NewClient(addr string) (*Client, error) {
+// dial creates a client for addr, optionally blocking until connected
+func dial(addr string, block bool, opts ...Option) (*Client, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	conn, err := o.dial(addr, block)
+	if err != nil {
+		return nil, err
+	}
+
+	maxOutstanding := o.maxOutstanding
+	if maxOutstanding <= 0 {
+		maxOutstanding = defaultMaxOutstanding
+	}
+
+	c := &Client{
+		conn:        conn,
+		client:      proto.NewStorageClient(conn),
+		addr:        addr,
+		opts:        o,
+		outstanding: make(chan struct{}, maxOutstanding),
+	}
+	if err := c.checkRequiredFeatures(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// dial connects to addr with these settings, optionally blocking until
+// connected
+func (o *options) dial(addr string, block bool) (*grpc.ClientConn, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	conn, err := grpc.DialContext(ctx, addr,
+	dialOpts := []grpc.DialOption{
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithBlock(),
-	)
+	}
+	if block {
+		dialOpts = append(dialOpts, grpc.WithBlock())
+	}
+	dialOpts = append(dialOpts, o.dialOptions()...)
+
+	conn, err := grpc.DialContext(ctx, addr, dialOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect: %v", err)
 	}
+	return conn, nil
+}
 
-	return &Client{
-		conn:   conn,
-		client: proto.NewStorageClient(conn),
-	}, nil
+// rpc returns the stub for the server the client currently talks to
+func (c *Client) rpc() proto.StorageClient {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.client
 }
 
-// Put stores a key-value pair
+// Put stores a key-value pair.
+// On a buffered client it returns once the pair is buffered; call Flush
+// to wait for it to reach the server.
 func (c *Client) Put(key, value []byte) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	return c.PutCtx(context.Background(), key, value)
+}
 
-	resp, err := c.client.Put(ctx, &proto.PutRequest{
+// PutCtx is Put with each request to the server bounded by ctx as well as
+// the client's own timeout. Buffering a pair doesn't wait, so it ignores ctx.
+func (c *Client) PutCtx(ctx context.Context, key, value []byte) error {
+	req := &proto.PutRequest{
 		Key:   key,
 		Value: value,
+	}
+	if err := c.checkSize(req); err != nil {
+		return err
+	}
+
+	if c.buffer != nil {
+		return c.bufferPut(key, value)
+	}
+
+	return c.withRedirect(func() error {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		resp, err := c.rpc().Put(ctx, req)
+		if err != nil {
+			return transportError(err)
+		}
+
+		if !resp.Success {
+			return fmt.Errorf("put failed: %s", resp.Error)
+		}
+
+		return nil
 	})
-	if err != nil {
+}
+
+// PutWithTTL stores a key-value pair that the server expires after ttl,
+// rounded down to whole milliseconds. It is never buffered; buffered
+// writes are flushed first so they can't overwrite it later.
+func (c *Client) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	if ttl < time.Millisecond {
+		return fmt.Errorf("ttl must be at least 1ms, got %v", ttl)
+	}
+	if err := c.flushBuffer(); err != nil {
 		return err
 	}
 
-	if !resp.Success {
-		return fmt.Errorf("put failed: %s", resp.Error)
+	req := &proto.PutRequest{
+		Key:   key,
+		Value: value,
+		TtlMs: ttl.Milliseconds(),
+	}
+	if err := c.checkSize(req); err != nil {
+		return err
 	}
 
-	return nil
+	return c.withRedirect(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		resp, err := c.rpc().Put(ctx, req)
+		if err != nil {
+			return transportError(err)
+		}
+
+		if !resp.Success {
+			return fmt.Errorf("put failed: %s", resp.Error)
+		}
+
+		return nil
+	})
 }
 
-// Get retrieves a value for a key
+// Get retrieves a value for a key, or returns storage.ErrKeyNotFound if
+// it is missing. Buffered writes are visible to Get before they are flushed.
 func (c *Client) Get(key []byte) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	return c.GetCtx(context.Background(), key)
+}
+
+// GetCtx is Get with the request bounded by ctx as well as the client's
+// own timeout
+func (c *Client) GetCtx(ctx context.Context, key []byte) ([]byte, error) {
+	value, _, err := c.getWithStaleness(ctx, key)
+	return value, err
+}
+
+// GetWithStaleness is like Get, and also reports whether the server
+// answered from possibly stale state because its cluster was electing a
+// leader. Values read from the write buffer are never stale.
+func (c *Client) GetWithStaleness(key []byte) ([]byte, bool, error) {
+	return c.getWithStaleness(context.Background(), key)
+}
+
+// getWithStaleness is GetWithStaleness bounded by ctx
+func (c *Client) getWithStaleness(ctx context.Context, key []byte) ([]byte, bool, error) {
+	if c.buffer != nil {
+		if value, ok := c.bufferedGet(key); ok {
+			return value, false, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	resp, err := c.client.Get(ctx, &proto.GetRequest{
+	resp, err := c.rpc().Get(ctx, &proto.GetRequest{
 		Key: key,
 	})
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	if !resp.Found {
-		return nil, fmt.Errorf("key not found: %s", resp.Error)
+		return nil, false, storage.ErrKeyNotFound
 	}
 
-	return resp.Value, nil
+	return resp.Value, resp.Stale, nil
 }
 
-// Delete removes a key-value pair
+// Exists reports whether key holds a value. A missing key is (false,
+// nil); an error means the server couldn't be asked or the read failed.
+func (c *Client) Exists(key []byte) (bool, error) {
+	_, err := c.Get(key)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetOrDefault retrieves a value like Get, returning def instead if the
+// key is missing. Other failures are returned as errors.
+func (c *Client) GetOrDefault(key, def []byte) ([]byte, error) {
+	value, err := c.Get(key)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return def, nil
+	}
+	return value, err
+}
+
+// Delete removes a key-value pair, returning storage.ErrKeyNotFound if
+// the server's storage reports the key missing.
+// Buffered writes are flushed first so the delete is ordered after them.
 func (c *Client) Delete(key []byte) error {
+	return c.DeleteCtx(context.Background(), key)
+}
+
+// DeleteCtx is Delete with the request bounded by ctx as well as the
+// client's own timeout. Flushing buffered writes first isn't bounded by ctx.
+func (c *Client) DeleteCtx(ctx context.Context, key []byte) error {
+	if err := c.flushBuffer(); err != nil {
+		return err
+	}
+
+	return c.withRedirect(func() error {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		resp, err := c.rpc().Delete(ctx, &proto.DeleteRequest{
+			Key: key,
+		})
+		if status.Code(err) == codes.NotFound {
+			return storage.ErrKeyNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if !resp.Success {
+			return fmt.Errorf("delete failed: %s", resp.Error)
+		}
+
+		return nil
+	})
+}
+
+// DeleteIf removes key only if it currently holds expected and reports
+// whether it was deleted. Buffered writes are flushed first.
+func (c *Client) DeleteIf(key, expected []byte) (bool, error) {
+	if err := c.flushBuffer(); err != nil {
+		return false, err
+	}
+
+	var deleted bool
+	err := c.withRedirect(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		resp, err := c.rpc().DeleteIf(ctx, &proto.DeleteIfRequest{
+			Key:      key,
+			Expected: expected,
+		})
+		if err != nil {
+			return err
+		}
+
+		if resp.Error != "" {
+			return fmt.Errorf("delete failed: %s", resp.Error)
+		}
+
+		deleted = resp.Deleted
+		return nil
+	})
+	return deleted, err
+}
+
+// CompareAndSwap sets key to new only if it currently holds old, or only
+// if it is absent when old is nil, and reports whether it was set.
+// Buffered writes are flushed first.
+func (c *Client) CompareAndSwap(key, old, new []byte) (bool, error) {
+	if err := c.flushBuffer(); err != nil {
+		return false, err
+	}
+
+	req := &proto.CompareAndSwapRequest{
+		Key:    key,
+		Old:    old,
+		New:    new,
+		Absent: old == nil,
+	}
+	if err := c.checkSize(req); err != nil {
+		return false, err
+	}
+
+	var swapped bool
+	err := c.withRedirect(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		resp, err := c.rpc().CompareAndSwap(ctx, req)
+		if err != nil {
+			return transportError(err)
+		}
+
+		if resp.Error != "" {
+			return fmt.Errorf("compare and swap failed: %s", resp.Error)
+		}
+
+		swapped = resp.Swapped
+		return nil
+	})
+	return swapped, err
+}
+
+// Increment adds delta to the counter at key, a big-endian int64, and
+// returns its new value. A missing key counts as 0. Buffered writes are
+// flushed first. Adding twice would count twice, so the write is only sent
+// again on the leader when the server rejected it unapplied; after a
+// timeout or failed replication the error is returned and the counter may
+// or may not hold the delta.
+func (c *Client) Increment(key []byte, delta int64) (int64, error) {
+	if err := c.flushBuffer(); err != nil {
+		return 0, err
+	}
+
+	req := &proto.IncrementRequest{
+		Key:   key,
+		Delta: delta,
+	}
+	if err := c.checkSize(req); err != nil {
+		return 0, err
+	}
+
+	var n int64
+	err := c.withRedirectRetrying(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		resp, err := c.rpc().Increment(ctx, req)
+		if err != nil {
+			return transportError(err)
+		}
+
+		if resp.Error != "" {
+			return fmt.Errorf("increment failed: %s", resp.Error)
+		}
+
+		n = resp.Value
+		return nil
+	}, isRejected)
+	return n, err
+}
+
+// Append adds value to the end of the list at key, creating the list if
+// the key is missing. The server has no append of its own, so the list is
+// read and compare-and-swapped back with the new element, retrying if
+// another write to the key gets in between.
+func (c *Client) Append(key, value []byte) error {
+	return storage.AppendWithCompareAndSwap(c, key, value)
+}
+
+// GetList returns the elements of the list at key, in the order they were
+// appended
+func (c *Client) GetList(key []byte) ([][]byte, error) {
+	value, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return storage.DecodeList(value)
+}
+
+// Tail returns the n largest keys with their values, in descending key order.
+// Buffered writes are flushed first so they are included in the result.
+func (c *Client) Tail(n int) ([]storage.KV, error) {
+	if err := c.flushBuffer(); err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	resp, err := c.client.Delete(ctx, &proto.DeleteRequest{
-		Key: key,
+	resp, err := c.rpc().Tail(ctx, &proto.TailRequest{
+		Limit: int32(n),
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, fmt.Errorf("tail failed: %s", resp.Error)
+	}
+
+	pairs := make([]storage.KV, 0, len(resp.Pairs))
+	for _, kv := range resp.Pairs {
+		pairs = append(pairs, storage.KV{Key: kv.Key, Value: kv.Value})
+	}
+	return pairs, nil
+}
+
+// Fingerprint returns an order-independent hash of the server's whole
+// dataset. Two servers holding the same data return the same fingerprint.
+// Buffered writes are flushed first so they are included.
+func (c *Client) Fingerprint() ([]byte, error) {
+	if err := c.flushBuffer(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := c.rpc().Fingerprint(ctx, &proto.FingerprintRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, fmt.Errorf("fingerprint failed: %s", resp.Error)
+	}
+	return resp.Fingerprint, nil
+}
+
+// Stats returns the server's key count, disk usage and storage backend.
+// Buffered writes are flushed first so they are included.
+func (c *Client) Stats() (storage.StorageStats, error) {
+	if err := c.flushBuffer(); err != nil {
+		return storage.StorageStats{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := c.rpc().Stats(ctx, &proto.StatsRequest{})
+	if err != nil {
+		return storage.StorageStats{}, err
+	}
+
+	if resp.Error != "" {
+		return storage.StorageStats{}, fmt.Errorf("stats failed: %s", resp.Error)
+	}
+	return storage.StorageStats{
+		KeyCount:    resp.KeyCount,
+		DiskBytes:   resp.DiskBytes,
+		Backend:     resp.Backend,
+		Compression: resp.Compression,
+	}, nil
+}
+
+// Limits returns the key and value size limits the server reports in
+// Capabilities, or none if they can't be fetched
+func (c *Client) Limits() storage.Limits {
+	limits, _ := c.limits()
+	return limits
+}
+
+// limits returns the server's key and value size limits
+func (c *Client) limits() (storage.Limits, error) {
+	caps, err := c.Capabilities()
+	if err != nil {
+		return storage.Limits{}, err
+	}
+	return storage.Limits{MaxKeySize: caps.MaxKeySize, MaxValueSize: caps.MaxValueSize}, nil
+}
+
+// MethodLatency is the latency histogram of one RPC method on the server
+type MethodLatency struct {
+	Count uint64
+	Total time.Duration
+	Max   time.Duration
+
+	// Buckets counts the calls no slower than each of
+	// ServerMetrics.BucketBounds, then the calls slower than the last bound
+	Buckets []uint64
+
+	// Slow counts the calls that took at least the slow threshold
+	Slow uint64
+}
+
+// ServerMetrics is the server's latency histogram for each RPC method it
+// has served
+type ServerMetrics struct {
+	BucketBounds  []time.Duration
+	SlowThreshold time.Duration
+	Methods       map[string]MethodLatency
+}
+
+// Metrics returns the server's per-method latency histograms
+func (c *Client) Metrics() (*ServerMetrics, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := c.rpc().Metrics(ctx, &proto.MetricsRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := &ServerMetrics{
+		BucketBounds:  make([]time.Duration, len(resp.BucketBoundsUs)),
+		SlowThreshold: time.Duration(resp.SlowThresholdUs) * time.Microsecond,
+		Methods:       make(map[string]MethodLatency, len(resp.Methods)),
+	}
+	for i, bound := range resp.BucketBoundsUs {
+		metrics.BucketBounds[i] = time.Duration(bound) * time.Microsecond
+	}
+	for _, m := range resp.Methods {
+		metrics.Methods[m.Method] = MethodLatency{
+			Count:   m.Count,
+			Total:   time.Duration(m.TotalUs) * time.Microsecond,
+			Max:     time.Duration(m.MaxUs) * time.Microsecond,
+			Buckets: m.Buckets,
+			Slow:    m.Slow,
+		}
+	}
+	return metrics, nil
+}
+
+// SplitRanges divides the server's keyspace into n ranges holding roughly
+// equal numbers of keys, so they can be scanned in parallel. Buffered
+// writes are flushed first so they are included.
+func (c *Client) SplitRanges(n int) ([]storage.KeyRange, error) {
+	if err := c.flushBuffer(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := c.rpc().SplitRanges(ctx, &proto.SplitRangesRequest{
+		Count: int32(n),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, fmt.Errorf("split ranges failed: %s", resp.Error)
+	}
+
+	// A boundary of empty bytes comes back as nil; only the outer ends of
+	// the keyspace are unbounded
+	ranges := make([]storage.KeyRange, 0, len(resp.Boundaries)+1)
+	var start []byte
+	for _, b := range resp.Boundaries {
+		end := append([]byte{}, b...)
+		ranges = append(ranges, storage.KeyRange{Start: start, End: end})
+		start = end
+	}
+	return append(ranges, storage.KeyRange{Start: start}), nil
+}
+
+// RangeHashes returns the server's hash of each of its 2^depth key ranges,
+// as storage.RangeHashes computes them. Only the hashes cross the network,
+// so comparing them with another store's finds the ranges that differ
+// without reading either dataset. Buffered writes are flushed first.
+func (c *Client) RangeHashes(depth int) ([][]byte, error) {
+	if err := c.flushBuffer(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := c.rpc().RangeHashes(ctx, &proto.RangeHashesRequest{
+		Depth: int32(depth),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, fmt.Errorf("range hashes failed: %s", resp.Error)
+	}
+	return resp.Hashes, nil
+}
+
+// ScanRanges returns an iterator over the pairs in the given key ranges of
+// a 2^depth split, see RangeHashes, streamed from the server in ascending
+// key order. Buffered writes are flushed first.
+func (c *Client) ScanRanges(depth int, ranges []int) (storage.Iterator, error) {
+	if err := c.flushBuffer(); err != nil {
+		return nil, err
+	}
+
+	req := &proto.ScanRangesRequest{
+		Depth:  int32(depth),
+		Ranges: make([]int32, len(ranges)),
+	}
+	for i, r := range ranges {
+		req.Ranges[i] = int32(r)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := c.rpc().ScanRanges(ctx, req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return startScan(ctx, stream, cancel)
+}
+
+// Barrier returns once every write this client made before calling it is
+// durable on the server: synced to disk for standalone storage, committed
+// on a quorum and synced on the leader for Raft. Buffered writes are
+// flushed first.
+func (c *Client) Barrier() error {
+	if err := c.flushBuffer(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := c.rpc().Barrier(ctx, &proto.BarrierRequest{})
 	if err != nil {
 		return err
 	}
 
 	if !resp.Success {
-		return fmt.Errorf("delete failed: %s", resp.Error)
+		return fmt.Errorf("barrier failed: %s", resp.Error)
 	}
 
 	return nil
 }
 
-// Close closes the connection
+// Sync implements storage.Storage. It is the same as Barrier.
+func (c *Client) Sync() error {
+	return c.Barrier()
+}
+
+// Roles a server reports in HealthStatus
+const (
+	RoleLeader   = "leader"
+	RoleFollower = "follower"
+)
+
+// HealthStatus describes whether a server can take requests
+type HealthStatus struct {
+	// Serving is true if the server's storage is readable and, in a
+	// cluster, it knows the leader
+	Serving bool
+
+	// Role is RoleLeader or RoleFollower. Only a leader serves writes
+	// itself; a standalone server is its own leader.
+	Role string
+
+	// StorageOK is true if a trivial read of the server's storage succeeded
+	StorageOK bool
+
+	// Error says why the server isn't serving, empty if it is
+	Error string
+}
+
+// Health asks the server whether it is serving, and in which role
+func (c *Client) Health() (*HealthStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := c.rpc().Health(ctx, &proto.HealthRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &HealthStatus{
+		Serving:   resp.Serving,
+		Role:      resp.Role,
+		StorageOK: resp.StorageOk,
+		Error:     resp.Error,
+	}, nil
+}
+
+// ClusterInfo asks the server for its current role in the cluster
+func (c *Client) ClusterInfo() (*NodeInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := c.rpc().ClusterInfo(ctx, &proto.ClusterInfoRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &NodeInfo{
+		NodeID:         resp.NodeId,
+		IsLeader:       resp.IsLeader,
+		LeaderID:       resp.LeaderId,
+		MaxMessageSize: int(resp.MaxMessageSize),
+		LeaderAddress:  resp.LeaderAddress,
+	}, nil
+}
+
+// Close flushes any buffered writes, waits for outstanding async
+// operations, and closes the connection. Async operations must not be
+// started once Close is called.
 func (c *Client) Close() error {
+	if c.buffer != nil {
+		c.buffer.closeOnce.Do(func() { close(c.buffer.stop) })
+		<-c.buffer.done
+	}
+	flushErr := c.Flush()
+	c.stopWorkers()
+
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	for _, conn := range c.retired {
+		conn.Close()
+	}
+	c.retired = nil
 	if c.conn != nil {
-		return c.conn.Close()
+		if err := c.conn.Close(); err != nil {
+			return err
+		}
 	}
-	return nil
+	return flushErr
 }
 
-// Size returns the number of keys (not implemented for client)
+// Size returns the number of keys on the server, or -1 if it can't be
+// read. See SizeE.
 func (c *Client) Size() int {
-	// This would require a new protocol message
-	// For now, return -1 to indicate not supported
-	return -1
+	size, err := c.SizeE()
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
+// SizeE returns the number of keys on the server, or the error that kept
+// it from being read. Buffered writes are flushed first so they are
+// counted.
+func (c *Client) SizeE() (int, error) {
+	if err := c.flushBuffer(); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := c.rpc().Size(ctx, &proto.SizeRequest{})
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.Error != "" {
+		return 0, fmt.Errorf("size failed: %s", resp.Error)
+	}
+	return int(resp.Count), nil
 }
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+func TestClient_ConcurrentIncrements(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Increment([]byte("hits"), 1); err != nil {
+				t.Errorf("Increment failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n, err := c.Increment([]byte("hits"), 0); err != nil || n != 100 {
+		t.Errorf("Expected 100, got %d (%v)", n, err)
+	}
+	value, _ := store.Get([]byte("hits"))
+	if n, err := storage.DecodeCounter(value); err != nil || n != 100 {
+		t.Errorf("Expected 100 on the server, got %d (%v)", n, err)
+	}
+
+	c.Put([]byte("name"), []byte("alice"))
+	if _, err := c.Increment([]byte("name"), 1); err == nil {
+		t.Error("Expected incrementing a non-counter value to fail")
+	}
+}
+
+// lostAckStorage applies increments but reports them failed, like a leader
+// that committed the write and then timed out waiting for its followers
+type lostAckStorage struct {
+	storage.Storage
+}
+
+func (s lostAckStorage) Increment(key []byte, delta int64) (int64, error) {
+	if _, err := s.Storage.Increment(key, delta); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("failed to replicate to majority")
+}
+
+func TestClient_IncrementIsNotRetriedAfterItMayHaveApplied(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	addr := startServer(t, lostAckStorage{store})
+
+	c, err := New([]string{addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Increment([]byte("hits"), 1); err == nil {
+		t.Error("Expected the failed replication to be reported")
+	}
+
+	cfg := DefaultPoolConfig(addr)
+	cfg.RetryTimeout = time.Second
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if _, err := p.Increment([]byte("hits"), 1); err == nil {
+		t.Error("Expected the failed replication to be reported")
+	}
+
+	// Each increment was applied once despite the errors
+	value, _ := store.Get([]byte("hits"))
+	if n, err := storage.DecodeCounter(value); err != nil || n != 2 {
+		t.Errorf("Expected 2, got %d (%v)", n, err)
+	}
+}
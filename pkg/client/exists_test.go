@@ -0,0 +1,49 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"godatabase/internal/storage"
+)
+
+func TestClient_ExistsAndGetOrDefault(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Put([]byte("present"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get([]byte("missing")); !errors.Is(err, storage.ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound for a missing key, got %v", err)
+	}
+	if ok, err := c.Exists([]byte("missing")); ok || err != nil {
+		t.Errorf("Expected a missing key not to exist, got %v (%v)", ok, err)
+	}
+	if ok, err := c.Exists([]byte("present")); !ok || err != nil {
+		t.Errorf("Expected a present key to exist, got %v (%v)", ok, err)
+	}
+	if value, err := c.GetOrDefault([]byte("missing"), []byte("default")); err != nil || string(value) != "default" {
+		t.Errorf("Expected default for a missing key, got %q (%v)", value, err)
+	}
+	if value, err := c.GetOrDefault([]byte("present"), []byte("default")); err != nil || string(value) != "value" {
+		t.Errorf("Expected value for a present key, got %q (%v)", value, err)
+	}
+
+	// A closed connection is a failure, not a missing key
+	c.Close()
+	if ok, err := c.Exists([]byte("present")); err == nil || errors.Is(err, storage.ErrKeyNotFound) {
+		t.Errorf("Expected a transport error on a closed client, got %v (%v)", ok, err)
+	}
+	if value, err := c.GetOrDefault([]byte("missing"), []byte("default")); err == nil {
+		t.Errorf("Expected an error on a closed client, got %q", value)
+	}
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"godatabase/internal/storage"
+)
+
+func TestClient_FingerprintComparesReplicas(t *testing.T) {
+	var clients []*Client
+	for i := 0; i < 2; i++ {
+		store, err := storage.NewBadgerStorage(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+		c, err := NewClient(startServer(t, store))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		clients = append(clients, c)
+	}
+
+	for i := 0; i < 20; i++ {
+		clients[0].Put([]byte(fmt.Sprintf("key%d", i)), []byte("value"))
+		clients[1].Put([]byte(fmt.Sprintf("key%d", 19-i)), []byte("value"))
+	}
+
+	a, err := clients[0].Fingerprint()
+	if err != nil {
+		t.Fatalf("Fingerprint failed: %v", err)
+	}
+	b, err := clients[1].Fingerprint()
+	if err != nil {
+		t.Fatalf("Fingerprint failed: %v", err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Errorf("Expected matching fingerprints, got %x and %x", a, b)
+	}
+
+	clients[1].Put([]byte("extra"), []byte("value"))
+	if b, _ = clients[1].Fingerprint(); bytes.Equal(a, b) {
+		t.Error("Expected fingerprints to diverge after an extra key")
+	}
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+func TestClient_HealthOfStandaloneServer(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	health, err := c.Health()
+	if err != nil {
+		t.Fatalf("Health failed: %v", err)
+	}
+	if !health.Serving || !health.StorageOK || health.Error != "" {
+		t.Errorf("Expected a fresh server to be healthy, got %+v", health)
+	}
+	if health.Role != RoleLeader {
+		t.Errorf("Expected a standalone server to report %s, got %s", RoleLeader, health.Role)
+	}
+}
+
+func TestClient_HealthReportsRaftRole(t *testing.T) {
+	cluster, nodes := startRaftCluster(t)
+
+	var leaderID string
+	deadline := time.Now().Add(5 * time.Second)
+	for leaderID == "" && time.Now().Before(deadline) {
+		if leader, err := cluster.GetLeader(); err == nil {
+			leaderID = leader.GetID()
+		} else {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	if leaderID == "" {
+		t.Fatal("No leader elected")
+	}
+
+	for id, n := range nodes {
+		c, err := NewClient(n.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		health, err := c.Health()
+		c.Close()
+		if err != nil {
+			t.Fatalf("%s: Health failed: %v", id, err)
+		}
+
+		want := RoleFollower
+		if id == leaderID {
+			want = RoleLeader
+		}
+		if health.Role != want {
+			t.Errorf("%s: Expected role %s, got %s", id, want, health.Role)
+		}
+		if !health.Serving || !health.StorageOK {
+			t.Errorf("%s: Expected node to be healthy, got %+v", id, health)
+		}
+	}
+}
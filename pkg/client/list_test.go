@@ -0,0 +1,45 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+
+	"godatabase/internal/storage"
+)
+
+func TestClient_AppendAndGetList(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 50; i++ {
+		if err := c.Append([]byte("events"), []byte(fmt.Sprintf("event-%02d", i))); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	list, err := c.GetList([]byte("events"))
+	if err != nil {
+		t.Fatalf("GetList failed: %v", err)
+	}
+	if len(list) != 50 {
+		t.Fatalf("Expected 50 elements, got %d", len(list))
+	}
+	for i, elem := range list {
+		if want := fmt.Sprintf("event-%02d", i); string(elem) != want {
+			t.Errorf("Expected %s at %d, got %s", want, i, elem)
+		}
+	}
+
+	// The server holds the same list
+	if list, err := store.GetList([]byte("events")); err != nil || len(list) != 50 {
+		t.Errorf("Expected 50 elements on the server, got %d (%v)", len(list), err)
+	}
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"godatabase/internal/rpc/proto"
+	"godatabase/internal/storage"
+)
+
+// Load streams ops to the server, which applies them in order and
+// acknowledges each one. Unlike a WriteBatch the operations are applied
+// one at a time, so a failed operation doesn't stop the ones after it;
+// Load returns once every operation is acknowledged, with an error naming
+// the first one that failed. Buffered writes are flushed first.
+func (c *Client) Load(ops []storage.BatchOp) error {
+	if err := c.flushBuffer(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := c.rpc().LoadOperations(ctx)
+	if err != nil {
+		return transportError(err)
+	}
+
+	// Send while acknowledgements are received, so neither side waits on
+	// the other's flow control
+	sendErr := make(chan error, 1)
+	go func() {
+		for _, op := range ops {
+			msg := &proto.Operation{Type: proto.Operation_PUT, Key: op.Key, Value: op.Value}
+			if op.Delete {
+				msg = &proto.Operation{Type: proto.Operation_DELETE, Key: op.Key}
+			}
+			if err := stream.Send(msg); err != nil {
+				sendErr <- err
+				return
+			}
+		}
+		sendErr <- stream.CloseSend()
+	}()
+
+	var firstErr error
+	var acked int
+	for acked < len(ops) {
+		ack, err := stream.Recv()
+		if err == io.EOF {
+			return errors.New("load ended before every operation was acknowledged")
+		}
+		if err != nil {
+			return transportError(err)
+		}
+		acked++
+		if !ack.Success && firstErr == nil {
+			firstErr = fmt.Errorf("load operation %d failed: %s", ack.Sequence, ack.Error)
+		}
+	}
+
+	if err := <-sendErr; err != nil {
+		return transportError(err)
+	}
+	return firstErr
+}
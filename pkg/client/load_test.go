@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"godatabase/internal/rpc/proto"
+	"godatabase/internal/storage"
+)
+
+func TestClient_Load(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// 80 puts, then deletes of every fourth key
+	var ops []storage.BatchOp
+	for i := 0; i < 80; i++ {
+		ops = append(ops, storage.BatchOp{
+			Key:   []byte(fmt.Sprintf("key%02d", i)),
+			Value: []byte(fmt.Sprintf("value%02d", i)),
+		})
+	}
+	for i := 0; i < 80; i += 4 {
+		ops = append(ops, storage.BatchOp{Key: []byte(fmt.Sprintf("key%02d", i)), Delete: true})
+	}
+	if err := c.Load(ops); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	for i := 0; i < 80; i++ {
+		value, err := c.Get([]byte(fmt.Sprintf("key%02d", i)))
+		if i%4 == 0 {
+			if err == nil {
+				t.Errorf("Expected key%02d to be deleted", i)
+			}
+		} else if err != nil || string(value) != fmt.Sprintf("value%02d", i) {
+			t.Errorf("Expected value%02d, got %q (%v)", i, value, err)
+		}
+	}
+}
+
+func TestServer_LoadOperationsSurvivesDisconnect(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := c.client.LoadOperations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&proto.Operation{Key: []byte("first"), Value: []byte("value")}); err != nil {
+		t.Fatal(err)
+	}
+	if ack, err := stream.Recv(); err != nil || !ack.Success || ack.Sequence != 1 {
+		t.Fatalf("Expected a successful ack for operation 1, got %v (%v)", ack, err)
+	}
+	// Go away without closing the stream
+	cancel()
+
+	if value, err := c.Get([]byte("first")); err != nil || string(value) != "value" {
+		t.Errorf("Expected the acknowledged put to stay applied, got %q (%v)", value, err)
+	}
+	if err := c.Put([]byte("after"), []byte("value")); err != nil {
+		t.Errorf("Expected the server to keep serving, got %v", err)
+	}
+}
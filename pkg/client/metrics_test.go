@@ -0,0 +1,132 @@
+package client
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"godatabase/internal/rpc"
+	"godatabase/internal/storage"
+)
+
+// slowGetStorage is storage whose reads take at least delay
+type slowGetStorage struct {
+	storage.Storage
+	delay time.Duration
+}
+
+func (s *slowGetStorage) Get(key []byte) ([]byte, error) {
+	time.Sleep(s.delay)
+	return s.Storage.Get(key)
+}
+
+// syncBuffer is a bytes.Buffer safe to log to from the server's goroutines
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestClient_MetricsLogSlowOperations(t *testing.T) {
+	var logs syncBuffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	store := &slowGetStorage{Storage: storage.NewMemStorage(), delay: 50 * time.Millisecond}
+	addr := freeAddr(t)
+	server := rpc.NewServer(store, rpc.WithSlowThreshold(20*time.Millisecond))
+	go server.Start(addr)
+	defer server.Stop()
+
+	c, err := NewClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Put([]byte("user"), []byte("alice")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get([]byte("user")); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+	}
+
+	if got := logs.String(); !strings.Contains(got, "Slow Get took") || !strings.Contains(got, "key 4 bytes") {
+		t.Errorf("Expected the slow Get to be logged with its key size, got %q", got)
+	}
+	if strings.Contains(logs.String(), "Slow Put") {
+		t.Error("Expected the fast Put not to be logged")
+	}
+
+	metrics, err := c.Metrics()
+	if err != nil {
+		t.Fatalf("Metrics failed: %v", err)
+	}
+	if metrics.SlowThreshold != 20*time.Millisecond {
+		t.Errorf("Expected a 20ms threshold, got %v", metrics.SlowThreshold)
+	}
+	get := metrics.Methods["Get"]
+	if get.Count != 3 || get.Slow != 3 {
+		t.Errorf("Expected 3 slow Gets, got %d of %d", get.Slow, get.Count)
+	}
+	if get.Max < 50*time.Millisecond || get.Total < 150*time.Millisecond {
+		t.Errorf("Expected Gets of at least 50ms, got max %v total %v", get.Max, get.Total)
+	}
+	var bucketed uint64
+	for _, n := range get.Buckets {
+		bucketed += n
+	}
+	if len(get.Buckets) != len(metrics.BucketBounds)+1 || bucketed != 3 {
+		t.Errorf("Expected 3 Gets across %d buckets, got %v", len(metrics.BucketBounds)+1, get.Buckets)
+	}
+	if put := metrics.Methods["Put"]; put.Count != 1 || put.Slow != 0 {
+		t.Errorf("Expected 1 fast Put, got %d slow of %d", put.Slow, put.Count)
+	}
+}
+
+func TestClient_MetricsSlowLoggingOff(t *testing.T) {
+	var logs syncBuffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	store := &slowGetStorage{Storage: storage.NewMemStorage(), delay: 30 * time.Millisecond}
+	addr := freeAddr(t)
+	server := rpc.NewServer(store, rpc.WithSlowThreshold(10*time.Millisecond), rpc.WithSlowLogging(false))
+	go server.Start(addr)
+	defer server.Stop()
+
+	c, err := NewClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Get([]byte("missing"))
+	if strings.Contains(logs.String(), "Slow") {
+		t.Errorf("Expected no slow-operation log, got %q", logs.String())
+	}
+	metrics, err := c.Metrics()
+	if err != nil {
+		t.Fatalf("Metrics failed: %v", err)
+	}
+	if get := metrics.Methods["Get"]; get.Slow != 1 {
+		t.Errorf("Expected the slow Get to be counted, got %d", get.Slow)
+	}
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	gproto "google.golang.org/protobuf/proto"
+)
+
+// ErrValueTooLarge is returned when a request would exceed the message size
+// limit of the client or the server.
+var ErrValueTooLarge = errors.New("value too large")
+
+// Option configures a Client
+type Option func(*options)
+
+// options holds the settings applied by Option values
+type options struct {
+	maxMessageSize   int // 0 means the gRPC default
+	requiredFeatures []string
+	maxOutstanding   int    // 0 means defaultMaxOutstanding
+	maxRedirects     int    // 0 means defaultMaxRedirects
+	token            string // empty means no authentication
+}
+
+// WithMaxMessageSize limits the size in bytes of messages the client sends
+// and receives. Requests are also checked against the server's limit,
+// which the client discovers through ClusterInfo.
+func WithMaxMessageSize(bytes int) Option {
+	return func(o *options) {
+		o.maxMessageSize = bytes
+	}
+}
+
+// WithMaxOutstanding sets how many workers run async operations, which
+// bounds how many of their requests are in flight at once.
+func WithMaxOutstanding(n int) Option {
+	return func(o *options) {
+		o.maxOutstanding = n
+	}
+}
+
+// WithMaxRedirects bounds how many times a client created by New retries a
+// write on a newly found leader before returning the error. Zero or
+// negative means the default of 3.
+func WithMaxRedirects(n int) Option {
+	return func(o *options) {
+		o.maxRedirects = n
+	}
+}
+
+// dialOptions returns the gRPC dial options for these settings
+func (o *options) dialOptions() []grpc.DialOption {
+	var dialOpts []grpc.DialOption
+	if o.maxMessageSize > 0 {
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(
+			grpc.MaxCallSendMsgSize(o.maxMessageSize),
+			grpc.MaxCallRecvMsgSize(o.maxMessageSize),
+		))
+	}
+	if o.token != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(tokenCredentials{token: o.token}))
+	}
+	return dialOpts
+}
+
+// messageLimit returns the smallest message size limit known for this
+// client and its server, or 0 if neither is known. The server's limit is
+// fetched on first use and retried until the server answers.
+func (c *Client) messageLimit() int {
+	c.limitMu.Lock()
+	defer c.limitMu.Unlock()
+
+	if !c.serverLimitKnown {
+		info, err := c.ClusterInfo()
+		switch {
+		case err == nil:
+			c.serverLimit = info.MaxMessageSize
+			c.serverLimitKnown = true
+		case status.Code(err) == codes.Unimplemented:
+			// Older servers don't report a limit
+			c.serverLimitKnown = true
+		}
+	}
+
+	limit := c.opts.maxMessageSize
+	if c.serverLimit > 0 && (limit == 0 || c.serverLimit < limit) {
+		limit = c.serverLimit
+	}
+	return limit
+}
+
+// checkSize returns ErrValueTooLarge if req would exceed the message limit
+func (c *Client) checkSize(req gproto.Message) error {
+	if limit := c.messageLimit(); limit > 0 && gproto.Size(req) > limit {
+		return ErrValueTooLarge
+	}
+	return nil
+}
+
+// transportError maps gRPC size rejections to ErrValueTooLarge and returns
+// every other error unchanged
+func transportError(err error) error {
+	if status.Code(err) == codes.ResourceExhausted {
+		return ErrValueTooLarge
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"godatabase/internal/rpc"
+	"godatabase/internal/storage"
+)
+
+func TestClient_PutOverServerLimit(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	addr := freeAddr(t)
+	server := rpc.NewServer(store, rpc.WithMaxMessageSize(1024))
+	go server.Start(addr)
+	defer server.Stop()
+
+	c, err := NewClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	info, err := c.ClusterInfo()
+	if err != nil {
+		t.Fatalf("ClusterInfo failed: %v", err)
+	}
+	if info.MaxMessageSize != 1024 {
+		t.Errorf("Expected server limit 1024, got %d", info.MaxMessageSize)
+	}
+
+	if err := c.Put([]byte("small"), make([]byte, 100)); err != nil {
+		t.Fatalf("Put under the limit failed: %v", err)
+	}
+
+	err = c.Put([]byte("large"), make([]byte, 2048))
+	if !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Expected ErrValueTooLarge, got %v", err)
+	}
+
+	// The smaller of the client and server limits applies
+	small, err := NewClient(addr, WithMaxMessageSize(4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer small.Close()
+	if err := small.Put([]byte("large"), make([]byte, 8192)); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Expected ErrValueTooLarge from client limit, got %v", err)
+	}
+}
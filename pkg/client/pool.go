@@ -0,0 +1,614 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"godatabase/internal/storage"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errNoLeader is returned when the pool doesn't know which node leads
+var errNoLeader = errors.New("no leader known")
+
+// retryBackoff is the pause between attempts while the leader is unknown
+const retryBackoff = 100 * time.Millisecond
+
+// PoolConfig configures a Pool
+type PoolConfig struct {
+	// Addrs lists the gRPC addresses of every node in the cluster.
+	Addrs []string
+
+	// ReadFromAny routes reads to any healthy node instead of only the
+	// leader. Raft-backed nodes serve reads from their committed state,
+	// so this spreads read load without exposing uncommitted writes.
+	ReadFromAny bool
+
+	// RetryTimeout bounds how long an operation keeps retrying while the
+	// cluster elects a new leader or a node is unreachable.
+	RetryTimeout time.Duration
+
+	// HealthCheckInterval is how often every node is probed and the
+	// pool's view of the leader is refreshed.
+	HealthCheckInterval time.Duration
+}
+
+// DefaultPoolConfig returns a pool configuration for the given addresses
+// that routes reads and writes to the leader.
+func DefaultPoolConfig(addrs ...string) PoolConfig {
+	return PoolConfig{
+		Addrs:               addrs,
+		RetryTimeout:        5 * time.Second,
+		HealthCheckInterval: time.Second,
+	}
+}
+
+// Pool is a client for a whole cluster. It keeps a connection to every
+// node, routes writes to the current leader and transparently retries
+// while the leader changes. It implements the storage.Storage interface.
+type Pool struct {
+	cfg     PoolConfig
+	clients map[string]*Client // addr -> client
+	healthy map[string]bool    // addr -> answered the last probe
+	leader  string             // address of the current leader, "" if unknown
+	next    int                // round-robin position for reads
+	mu      sync.RWMutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewPool connects to every node in cfg.Addrs and discovers the leader.
+// Nodes that are down at creation time are reconnected in the background.
+func NewPool(cfg PoolConfig) (*Pool, error) {
+	if len(cfg.Addrs) == 0 {
+		return nil, errors.New("no node addresses")
+	}
+
+	p := &Pool{
+		cfg:     cfg,
+		clients: make(map[string]*Client),
+		healthy: make(map[string]bool),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	for _, addr := range cfg.Addrs {
+		// Don't block on dial so a down node doesn't stall the pool
+		c, err := dial(addr, false)
+		if err != nil {
+			p.closeClients()
+			return nil, fmt.Errorf("failed to connect to %s: %v", addr, err)
+		}
+		p.clients[addr] = c
+	}
+
+	p.refresh()
+	go p.healthLoop()
+
+	return p, nil
+}
+
+// healthLoop periodically refreshes node health and the leader
+func (p *Pool) healthLoop() {
+	defer close(p.done)
+
+	if p.cfg.HealthCheckInterval <= 0 {
+		<-p.stop
+		return
+	}
+
+	ticker := time.NewTicker(p.cfg.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.refresh()
+		}
+	}
+}
+
+// refresh probes every node for its cluster role
+func (p *Pool) refresh() {
+	healthy := make(map[string]bool)
+	leader := ""
+
+	for _, addr := range p.cfg.Addrs {
+		info, err := p.clients[addr].ClusterInfo()
+		if err != nil {
+			continue
+		}
+		healthy[addr] = true
+		if info.IsLeader {
+			leader = addr
+		}
+	}
+
+	p.mu.Lock()
+	p.healthy = healthy
+	p.leader = leader
+	p.mu.Unlock()
+}
+
+// leaderClient returns the client for the current leader
+func (p *Pool) leaderClient() (*Client, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.leader == "" {
+		return nil, errNoLeader
+	}
+	return p.clients[p.leader], nil
+}
+
+// readClients returns the healthy clients in round-robin order
+func (p *Pool) readClients() []*Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	clients := make([]*Client, 0, len(p.cfg.Addrs))
+	for i := range p.cfg.Addrs {
+		addr := p.cfg.Addrs[(p.next+i)%len(p.cfg.Addrs)]
+		if p.healthy[addr] {
+			clients = append(clients, p.clients[addr])
+		}
+	}
+	p.next++
+	return clients
+}
+
+// withLeader runs op against the leader. While errors show the leader has
+// moved or is unreachable, it rediscovers the leader and retries until
+// RetryTimeout elapses.
+func (p *Pool) withLeader(op func(c *Client) error) error {
+	return p.withLeaderRetrying(op, isRetryable)
+}
+
+// withLeaderRetrying is withLeader that only runs op again after an error
+// retryable accepts
+func (p *Pool) withLeaderRetrying(op func(c *Client) error, retryable func(error) bool) error {
+	deadline := time.Now().Add(p.cfg.RetryTimeout)
+	for {
+		c, err := p.leaderClient()
+		if err == nil {
+			err = op(c)
+			if err == nil || !retryable(err) {
+				return err
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(retryBackoff)
+		p.refresh()
+	}
+}
+
+// withAny runs a read against any healthy node when ReadFromAny is set,
+// falling back to the leader if no node could serve it.
+func (p *Pool) withAny(op func(c *Client) error) error {
+	if p.cfg.ReadFromAny {
+		for _, c := range p.readClients() {
+			err := op(c)
+			if err == nil || !isRetryable(err) {
+				return err
+			}
+		}
+	}
+	return p.withLeader(op)
+}
+
+// isRetryable reports whether err means the request may succeed on
+// another attempt once the leader is rediscovered
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+
+	msg := err.Error()
+	for _, s := range []string{"not the leader", "no leader", "failed to replicate", "no quorum", "too stale"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRejected reports whether err shows the request was turned away before
+// it could be applied. Unlike isRetryable, it excludes timeouts, lost
+// connections and failed replication, after which the write may already
+// have been applied, so a write that isn't safe to apply twice can still
+// be retried on it.
+func isRejected(err error) bool {
+	msg := err.Error()
+	for _, s := range []string{"not the leader", "no leader", "no quorum"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Put stores a key-value pair on the leader
+func (p *Pool) Put(key, value []byte) error {
+	return p.PutCtx(context.Background(), key, value)
+}
+
+// PutCtx is Put bounded by ctx, which also ends the retries
+func (p *Pool) PutCtx(ctx context.Context, key, value []byte) error {
+	return p.withLeader(func(c *Client) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return c.PutCtx(ctx, key, value)
+	})
+}
+
+// PutWithTTL stores an expiring key-value pair on the leader
+func (p *Pool) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	return p.withLeader(func(c *Client) error {
+		return c.PutWithTTL(key, value, ttl)
+	})
+}
+
+// Get retrieves a value for a key
+func (p *Pool) Get(key []byte) ([]byte, error) {
+	return p.GetCtx(context.Background(), key)
+}
+
+// GetCtx is Get bounded by ctx, which also ends the retries
+func (p *Pool) GetCtx(ctx context.Context, key []byte) ([]byte, error) {
+	var value []byte
+	err := p.withAny(func(c *Client) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		value, err = c.GetCtx(ctx, key)
+		return err
+	})
+	return value, err
+}
+
+// Delete removes a key-value pair on the leader
+func (p *Pool) Delete(key []byte) error {
+	return p.DeleteCtx(context.Background(), key)
+}
+
+// DeleteCtx is Delete bounded by ctx, which also ends the retries
+func (p *Pool) DeleteCtx(ctx context.Context, key []byte) error {
+	return p.withLeader(func(c *Client) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return c.DeleteCtx(ctx, key)
+	})
+}
+
+// DeleteRange removes a key range on the leader. One that fails part way
+// through is retried from the start on a new leader, and the count is of
+// the keys the last attempt deleted.
+func (p *Pool) DeleteRange(start, end []byte) (int, error) {
+	var deleted int
+	err := p.withLeader(func(c *Client) error {
+		var err error
+		deleted, err = c.DeleteRange(start, end)
+		return err
+	})
+	return deleted, err
+}
+
+// DeleteIf removes key on the leader if it holds expected
+func (p *Pool) DeleteIf(key, expected []byte) (bool, error) {
+	var deleted bool
+	err := p.withLeader(func(c *Client) error {
+		var err error
+		deleted, err = c.DeleteIf(key, expected)
+		return err
+	})
+	return deleted, err
+}
+
+// CompareAndSwap sets key on the leader if it holds old
+func (p *Pool) CompareAndSwap(key, old, new []byte) (bool, error) {
+	var swapped bool
+	err := p.withLeader(func(c *Client) error {
+		var err error
+		swapped, err = c.CompareAndSwap(key, old, new)
+		return err
+	})
+	return swapped, err
+}
+
+// Append adds value to key's list on the leader
+func (p *Pool) Append(key, value []byte) error {
+	return p.withLeader(func(c *Client) error {
+		return c.Append(key, value)
+	})
+}
+
+// GetList returns the elements of key's list, read like Get
+func (p *Pool) GetList(key []byte) ([][]byte, error) {
+	value, err := p.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return storage.DecodeList(value)
+}
+
+// Increment adds to key's counter on the leader. Adding twice would count
+// twice, so it is only retried when the request was rejected unapplied.
+func (p *Pool) Increment(key []byte, delta int64) (int64, error) {
+	var n int64
+	err := p.withLeaderRetrying(func(c *Client) error {
+		var err error
+		n, err = c.Increment(key, delta)
+		return err
+	}, isRejected)
+	return n, err
+}
+
+// Load streams operations to the leader. A load that fails part way
+// through is retried from the start on a new leader, so every operation
+// should be safe to apply twice.
+func (p *Pool) Load(ops []storage.BatchOp) error {
+	return p.withLeader(func(c *Client) error {
+		return c.Load(ops)
+	})
+}
+
+// NewBatch returns a batch that is committed on the leader
+func (p *Pool) NewBatch() storage.WriteBatch {
+	return &poolBatch{pool: p}
+}
+
+// poolBatch is a WriteBatch committed through a Pool
+type poolBatch struct {
+	storage.BatchOps
+	pool *Pool
+}
+
+// Commit sends the batch to the leader, retrying on a new leader like Put
+func (b *poolBatch) Commit() error {
+	return b.pool.withLeader(func(c *Client) error {
+		batch := &clientBatch{BatchOps: b.BatchOps, client: c}
+		return batch.Commit()
+	})
+}
+
+// Tail returns the n largest keys with their values, in descending key order
+func (p *Pool) Tail(n int) ([]storage.KV, error) {
+	var pairs []storage.KV
+	err := p.withAny(func(c *Client) error {
+		var err error
+		pairs, err = c.Tail(n)
+		return err
+	})
+	return pairs, err
+}
+
+// Fingerprint returns the fingerprint of the leader's dataset
+func (p *Pool) Fingerprint() ([]byte, error) {
+	var fingerprint []byte
+	err := p.withLeader(func(c *Client) error {
+		var err error
+		fingerprint, err = c.Fingerprint()
+		return err
+	})
+	return fingerprint, err
+}
+
+// SplitRanges splits the leader's keyspace into n ranges
+func (p *Pool) SplitRanges(n int) ([]storage.KeyRange, error) {
+	var ranges []storage.KeyRange
+	err := p.withLeader(func(c *Client) error {
+		var err error
+		ranges, err = c.SplitRanges(n)
+		return err
+	})
+	return ranges, err
+}
+
+// RangeHashes returns the hashes of the leader's key ranges. Like
+// ScanRanges it reads from the leader, so the ranges it finds differing
+// are read from the same node.
+func (p *Pool) RangeHashes(depth int) ([][]byte, error) {
+	var hashes [][]byte
+	err := p.withLeader(func(c *Client) error {
+		var err error
+		hashes, err = c.RangeHashes(depth)
+		return err
+	})
+	return hashes, err
+}
+
+// ScanRanges returns an iterator over the pairs in some of the leader's
+// key ranges; see RangeHashes
+func (p *Pool) ScanRanges(depth int, ranges []int) (storage.Iterator, error) {
+	var it storage.Iterator
+	err := p.withLeader(func(c *Client) error {
+		var err error
+		it, err = c.ScanRanges(depth, ranges)
+		return err
+	})
+	return it, err
+}
+
+// Scan returns an iterator over a key range, read like Tail. The node is
+// chosen when the scan starts; the iterator is not moved to another node
+// if that one fails partway through.
+func (p *Pool) Scan(start, end []byte) (storage.Iterator, error) {
+	var it storage.Iterator
+	err := p.withAny(func(c *Client) error {
+		var err error
+		it, err = c.Scan(start, end)
+		return err
+	})
+	return it, err
+}
+
+// ScanWithConsistency returns an iterator over a key range with the chosen
+// consistency, read from a node chosen like Scan
+func (p *Pool) ScanWithConsistency(start, end []byte, consistency storage.ScanConsistency) (storage.Iterator, error) {
+	var it storage.Iterator
+	err := p.withAny(func(c *Client) error {
+		var err error
+		it, err = c.ScanWithConsistency(start, end, consistency)
+		return err
+	})
+	return it, err
+}
+
+// ScanReverse returns a descending iterator over a key range, read from a
+// node chosen like Scan
+func (p *Pool) ScanReverse(start, end []byte) (storage.Iterator, error) {
+	var it storage.Iterator
+	err := p.withAny(func(c *Client) error {
+		var err error
+		it, err = c.ScanReverse(start, end)
+		return err
+	})
+	return it, err
+}
+
+// ScanPrefix returns an iterator over the keys beginning with prefix,
+// read from a node chosen like Scan
+func (p *Pool) ScanPrefix(prefix []byte) (storage.Iterator, error) {
+	var it storage.Iterator
+	err := p.withAny(func(c *Client) error {
+		var err error
+		it, err = c.ScanPrefix(prefix)
+		return err
+	})
+	return it, err
+}
+
+// Keys lists the keys of a node chosen like Scan
+func (p *Pool) Keys() ([][]byte, error) {
+	var keys [][]byte
+	err := p.withAny(func(c *Client) error {
+		var err error
+		keys, err = c.Keys()
+		return err
+	})
+	return keys, err
+}
+
+// Stats returns the leader's stats
+func (p *Pool) Stats() (storage.StorageStats, error) {
+	var stats storage.StorageStats
+	err := p.withLeader(func(c *Client) error {
+		var err error
+		stats, err = c.Stats()
+		return err
+	})
+	return stats, err
+}
+
+// Limits returns the limits reported by any reachable server, or none if
+// no server answers
+func (p *Pool) Limits() storage.Limits {
+	var limits storage.Limits
+	p.withAny(func(c *Client) error {
+		var err error
+		limits, err = c.limits()
+		return err
+	})
+	return limits
+}
+
+// Barrier returns once every write acknowledged by the leader is durable
+func (p *Pool) Barrier() error {
+	return p.withLeader(func(c *Client) error {
+		return c.Barrier()
+	})
+}
+
+// Sync implements storage.Storage. It is the same as Barrier.
+func (p *Pool) Sync() error {
+	return p.Barrier()
+}
+
+// Backup streams a backup of the leader's storage to w. The leader isn't
+// retried once data has been written to w.
+func (p *Pool) Backup(w io.Writer) error {
+	cw := &countingWriter{w: w}
+	return p.withLeader(func(c *Client) error {
+		err := c.Backup(cw)
+		if err != nil && cw.n > 0 {
+			// Part of the backup is already in w, so it can't be retried
+			return fmt.Errorf("backup interrupted after %d bytes: %v", cw.n, err)
+		}
+		return err
+	})
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// Size returns the number of keys reported by the leader, or -1 if it
+// can't be read
+func (p *Pool) Size() int {
+	size, err := p.SizeE()
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
+// SizeE returns the number of keys reported by the leader, retrying on a
+// new leader like Put
+func (p *Pool) SizeE() (int, error) {
+	var size int
+	err := p.withLeader(func(c *Client) error {
+		var err error
+		size, err = c.SizeE()
+		return err
+	})
+	return size, err
+}
+
+// Close stops health checks and closes every connection
+func (p *Pool) Close() error {
+	select {
+	case <-p.stop:
+		return nil // Already closed
+	default:
+		close(p.stop)
+	}
+	<-p.done
+
+	return p.closeClients()
+}
+
+// closeClients closes every node connection, returning the first error
+func (p *Pool) closeClients() error {
+	var firstErr error
+	for _, c := range p.clients {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
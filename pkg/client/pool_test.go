@@ -0,0 +1,121 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"godatabase/internal/raft"
+	"godatabase/internal/rpc"
+	"godatabase/internal/storage"
+)
+
+// freeAddr returns a localhost address with an unused port
+func freeAddr(t *testing.T) string {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+// testNode is one Raft node of an in-process cluster with its gRPC server
+type testNode struct {
+	id     string
+	addr   string
+	server *rpc.Server
+}
+
+// startRaftCluster starts three Raft nodes, each behind its own gRPC server
+func startRaftCluster(t *testing.T) (*raft.GlobalCluster, map[string]*testNode) {
+	cluster := raft.GetGlobalCluster()
+
+	raftAddrs := make(map[string]string)
+	nodes := make(map[string]*testNode)
+	for i := 1; i <= 3; i++ {
+		id := fmt.Sprintf("node%d", i)
+		_, port, _ := net.SplitHostPort(freeAddr(t))
+		raftAddrs[id] = ":" + port
+		nodes[id] = &testNode{id: id, addr: freeAddr(t)}
+	}
+
+	for id, n := range nodes {
+		peers := make(map[string]string)
+		for peerID, peerAddr := range raftAddrs {
+			if peerID != id {
+				peers[peerID] = peerAddr
+			}
+		}
+
+		store, err := storage.NewBadgerStorage(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+
+		node := raft.NewRaftNode(id, raftAddrs[id], peers, store)
+		if err := cluster.RegisterNode(node); err != nil {
+			t.Fatal(err)
+		}
+		if err := node.StartRPCServer(); err != nil {
+			t.Fatal(err)
+		}
+		if err := node.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		n.server = rpc.NewServer(raft.NewRaftStorage(cluster, id))
+		go n.server.Start(n.addr)
+		t.Cleanup(n.server.Stop)
+	}
+	t.Cleanup(cluster.StopAll)
+
+	return cluster, nodes
+}
+
+func TestPool_SurvivesLeaderChange(t *testing.T) {
+	cluster, nodes := startRaftCluster(t)
+
+	addrs := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		addrs = append(addrs, n.addr)
+	}
+
+	cfg := DefaultPoolConfig(addrs...)
+	cfg.ReadFromAny = true
+	cfg.HealthCheckInterval = 100 * time.Millisecond
+	pool, err := NewPool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	if err := pool.Put([]byte("before"), []byte("1")); err != nil {
+		t.Fatalf("Put before leader change failed: %v", err)
+	}
+
+	// Take the leader down: stop serving clients and leave the cluster
+	leader, err := cluster.GetLeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes[leader.GetID()].server.Stop()
+	cluster.UnregisterNode(leader.GetID())
+
+	if err := pool.Put([]byte("after"), []byte("2")); err != nil {
+		t.Fatalf("Put after leader change failed: %v", err)
+	}
+
+	for key, expected := range map[string]string{"before": "1", "after": "2"} {
+		value, err := pool.Get([]byte(key))
+		if err != nil {
+			t.Errorf("Get %s failed: %v", key, err)
+			continue
+		}
+		if string(value) != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, key, value)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"godatabase/internal/rpc/proto"
+
+	"google.golang.org/grpc"
+)
+
+// defaultMaxRedirects is how many times a write is retried on a new leader
+// unless WithMaxRedirects says otherwise
+const defaultMaxRedirects = 3
+
+// withRedirect runs the write op. On a client created by New, an error
+// that shows the server isn't the leader or can't be reached makes the
+// client find the leader, reconnect to it and run op again, up to
+// WithMaxRedirects times. The last error is returned if that doesn't
+// succeed.
+func (c *Client) withRedirect(op func() error) error {
+	return c.withRedirectRetrying(op, isRetryable)
+}
+
+// withRedirectRetrying is withRedirect that only runs op again after an
+// error retryable accepts
+func (c *Client) withRedirectRetrying(op func() error, retryable func(error) bool) error {
+	err := op()
+	if c.seeds == nil {
+		return err
+	}
+
+	redirects := c.opts.maxRedirects
+	if redirects <= 0 {
+		redirects = defaultMaxRedirects
+	}
+	for attempt := 0; attempt < redirects && err != nil && retryable(err); attempt++ {
+		if c.followLeader() != nil {
+			// Mid-election nobody leads yet; give the cluster a moment
+			time.Sleep(retryBackoff)
+			continue
+		}
+		err = op()
+	}
+	return err
+}
+
+// followLeader finds the leader and points the client at it. It asks the
+// current server first, then every seed, following the leader address
+// each one reports.
+func (c *Client) followLeader() error {
+	c.connMu.RLock()
+	current := c.addr
+	c.connMu.RUnlock()
+
+	candidates := []string{current}
+	tried := make(map[string]bool)
+	for _, seed := range c.seeds {
+		candidates = append(candidates, seed)
+	}
+
+	for len(candidates) > 0 {
+		addr := candidates[0]
+		candidates = candidates[1:]
+		if tried[addr] {
+			continue
+		}
+		tried[addr] = true
+
+		var conn *grpc.ClientConn
+		var stub proto.StorageClient
+		if addr == current {
+			stub = c.rpc()
+		} else {
+			var err error
+			if conn, err = c.opts.dial(addr, false); err != nil {
+				continue
+			}
+			stub = proto.NewStorageClient(conn)
+		}
+
+		info, err := leaderInfo(stub)
+		switch {
+		case err == nil && info.IsLeader:
+			if conn != nil {
+				c.switchTo(addr, conn, stub)
+			}
+			return nil
+		case err == nil && info.LeaderAddress != "":
+			// Try the leader it reports next
+			candidates = append([]string{info.LeaderAddress}, candidates...)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+	}
+	return errNoLeader
+}
+
+// leaderInfo asks the server behind stub for its cluster role
+func leaderInfo(stub proto.StorageClient) (*proto.ClusterInfoResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return stub.ClusterInfo(ctx, &proto.ClusterInfoRequest{})
+}
+
+// switchTo makes the server at addr the one the client talks to. The old
+// connection may still carry requests started before the switch, so it is
+// kept open until the client closes.
+func (c *Client) switchTo(addr string, conn *grpc.ClientConn, stub proto.StorageClient) {
+	c.connMu.Lock()
+	c.retired = append(c.retired, c.conn)
+	c.conn, c.client, c.addr = conn, stub, addr
+	c.connMu.Unlock()
+
+	// The new server may have another message size limit
+	c.limitMu.Lock()
+	c.serverLimitKnown = false
+	c.limitMu.Unlock()
+}
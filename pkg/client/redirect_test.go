@@ -0,0 +1,114 @@
+package client
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"godatabase/internal/storage"
+)
+
+// followerStorage acts like a cluster follower: it refuses writes and
+// points clients at the leader
+type followerStorage struct {
+	storage.Storage
+	leaderAddr string
+}
+
+func (f *followerStorage) Put(key, value []byte) error {
+	return errors.New("not the leader")
+}
+
+func (f *followerStorage) NodeID() string                    { return "follower" }
+func (f *followerStorage) IsLeader() bool                    { return false }
+func (f *followerStorage) LeaderID() (string, error)         { return "leader", nil }
+func (f *followerStorage) GetLeaderAddress() (string, error) { return f.leaderAddr, nil }
+
+func TestClient_FollowsLeaderRedirect(t *testing.T) {
+	leaderStore, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer leaderStore.Close()
+	leaderAddr := startServer(t, leaderStore)
+
+	followerStore, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer followerStore.Close()
+	followerAddr := startServer(t, &followerStorage{Storage: followerStore, leaderAddr: leaderAddr})
+
+	c, err := New([]string{followerAddr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Expected the write to be retried on the leader, got %v", err)
+	}
+	if value, err := leaderStore.Get([]byte("key")); err != nil || string(value) != "value" {
+		t.Errorf("Expected the leader to hold the write, got %q (%v)", value, err)
+	}
+	if _, err := followerStore.Get([]byte("key")); err == nil {
+		t.Error("Expected the follower not to hold the write")
+	}
+
+	// Later requests go to the leader directly
+	if info, err := c.ClusterInfo(); err != nil || !info.IsLeader {
+		t.Errorf("Expected the client to talk to the leader, got %+v (%v)", info, err)
+	}
+}
+
+func TestClient_RedirectsAreBounded(t *testing.T) {
+	// Two followers that each name the other as leader
+	var addrs []string
+	var followers []*followerStorage
+	for i := 0; i < 2; i++ {
+		store, err := storage.NewBadgerStorage(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+		f := &followerStorage{Storage: store}
+		followers = append(followers, f)
+		addrs = append(addrs, startServer(t, f))
+	}
+	followers[0].leaderAddr, followers[1].leaderAddr = addrs[1], addrs[0]
+
+	c, err := New(addrs, WithMaxRedirects(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	err = c.Put([]byte("key"), []byte("value"))
+	if err == nil || !strings.Contains(err.Error(), "not the leader") {
+		t.Errorf("Expected the write to fail with not the leader, got %v", err)
+	}
+}
+
+func TestClient_NewClientDoesNotRedirect(t *testing.T) {
+	leaderStore, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer leaderStore.Close()
+	followerStore, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer followerStore.Close()
+	followerAddr := startServer(t, &followerStorage{Storage: followerStore, leaderAddr: startServer(t, leaderStore)})
+
+	c, err := NewClient(followerAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Put([]byte("key"), []byte("value")); err == nil {
+		t.Error("Expected a client for one server to return the follower's error")
+	}
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"godatabase/internal/rpc/proto"
+	"godatabase/internal/storage"
+)
+
+// ScanOptions adjusts a ScanCtx
+type ScanOptions struct {
+	// Limit is the most pairs to return, or 0 for no limit
+	Limit int
+
+	// Reverse returns the range in descending key order. With a Limit,
+	// the last pairs of the range are returned.
+	Reverse bool
+
+	// Consistency chooses what the scan guarantees about writes made
+	// while it runs; see storage.ScanConsistency
+	Consistency storage.ScanConsistency
+}
+
+// Scan returns an iterator over the server's keys from start, inclusive,
+// up to end, exclusive, streamed from the server as the iterator advances.
+// Buffered writes are flushed first so they are included. The first pair
+// is received before Scan returns, so a scan the server can't start fails
+// here rather than on the first Next.
+func (c *Client) Scan(start, end []byte) (storage.Iterator, error) {
+	return c.ScanCtx(context.Background(), start, end, ScanOptions{})
+}
+
+// ScanWithConsistency is Scan with the guarantee chosen by consistency
+func (c *Client) ScanWithConsistency(start, end []byte, consistency storage.ScanConsistency) (storage.Iterator, error) {
+	return c.ScanCtx(context.Background(), start, end, ScanOptions{Consistency: consistency})
+}
+
+// ScanReverse returns an iterator over the same keys as Scan, in
+// descending order
+func (c *Client) ScanReverse(start, end []byte) (storage.Iterator, error) {
+	return c.ScanCtx(context.Background(), start, end, ScanOptions{Reverse: true})
+}
+
+// ScanCtx is Scan with a limit and order set by opts, and with the stream
+// bounded by ctx. If ctx ends mid-scan the server stops sending, Next
+// returns false and Close returns ctx's error.
+func (c *Client) ScanCtx(ctx context.Context, start, end []byte, opts ScanOptions) (storage.Iterator, error) {
+	if opts.Limit < 0 {
+		return nil, fmt.Errorf("invalid scan limit: %d", opts.Limit)
+	}
+	if err := c.flushBuffer(); err != nil {
+		return nil, err
+	}
+
+	// An empty end can't be told apart from no end on the wire, and no
+	// key sorts before it
+	if end != nil && len(end) == 0 {
+		return storage.NewSliceIterator(nil), nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := c.rpc().Scan(ctx, &proto.ScanRequest{
+		Start:            start,
+		End:              end,
+		Limit:            int64(opts.Limit),
+		Reverse:          opts.Reverse,
+		ConsistentPrefix: opts.Consistency == storage.ScanConsistentPrefix,
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return startScan(ctx, stream, cancel)
+}
+
+// ScanPrefix returns an iterator over the server's keys that begin with
+// prefix, streamed like Scan. An empty prefix matches every key.
+func (c *Client) ScanPrefix(prefix []byte) (storage.Iterator, error) {
+	if err := c.flushBuffer(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := c.rpc().ScanPrefix(ctx, &proto.ScanPrefixRequest{
+		Prefix: prefix,
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return startScan(ctx, stream, cancel)
+}
+
+// Keys returns every key on the server in ascending order, streamed from
+// the server without their values. Buffered writes are flushed first so
+// they are included.
+func (c *Client) Keys() ([][]byte, error) {
+	if err := c.flushBuffer(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.rpc().Keys(ctx, &proto.KeysRequest{})
+	if err != nil {
+		return nil, transportError(err)
+	}
+
+	var keys [][]byte
+	for {
+		key, err := stream.Recv()
+		if err == io.EOF {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, transportError(err)
+		}
+		keys = append(keys, key.Key)
+	}
+}
+
+// pairReceiver is a stream of key-value pairs, such as a Scan stream
+type pairReceiver interface {
+	Recv() (*proto.KeyValue, error)
+}
+
+// startScan receives the first pair of stream, so an error starting the
+// scan is returned here, and returns an iterator over the rest. ctx is the
+// stream's context and cancel ends it.
+func startScan(ctx context.Context, stream pairReceiver, cancel context.CancelFunc) (storage.Iterator, error) {
+	it := &scanIterator{ctx: ctx, stream: stream, cancel: cancel}
+	it.fetch()
+	if it.err != nil {
+		cancel()
+		return nil, it.err
+	}
+	return it, nil
+}
+
+// scanIterator reads a Scan stream one pair ahead of the caller
+type scanIterator struct {
+	ctx     context.Context
+	stream  pairReceiver
+	cancel  context.CancelFunc
+	next    *proto.KeyValue // received but not yet returned
+	current *proto.KeyValue
+	err     error
+}
+
+// fetch receives the pair after the current one
+func (it *scanIterator) fetch() {
+	kv, err := it.stream.Recv()
+	if err == io.EOF {
+		it.next = nil
+		return
+	}
+	if err != nil {
+		it.next = nil
+		it.err = transportError(err)
+		if ctxErr := it.ctx.Err(); ctxErr != nil {
+			// Report the caller's cancellation rather than the status
+			// it was turned into
+			it.err = ctxErr
+		}
+		return
+	}
+	it.next = kv
+}
+
+func (it *scanIterator) Next() bool {
+	if it.next == nil {
+		return false
+	}
+	it.current = it.next
+	it.fetch()
+	return true
+}
+
+func (it *scanIterator) Key() []byte {
+	return it.current.Key
+}
+
+func (it *scanIterator) Value() []byte {
+	return it.current.Value
+}
+
+// Close stops the stream and returns the error that ended it early, if any
+func (it *scanIterator) Close() error {
+	it.cancel()
+	it.next = nil
+	return it.err
+}
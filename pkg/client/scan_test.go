@@ -0,0 +1,254 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"godatabase/internal/storage"
+)
+
+func TestClient_ScanStreamsRange(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 1; i <= 9; i++ {
+		if err := c.Put([]byte(fmt.Sprintf("k%d", i)), []byte(fmt.Sprintf("v%d", i))); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	scan := func(start, end []byte) []string {
+		it, err := c.Scan(start, end)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		var pairs []string
+		for it.Next() {
+			pairs = append(pairs, string(it.Key())+"="+string(it.Value()))
+		}
+		if err := it.Close(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+		return pairs
+	}
+
+	if got := fmt.Sprint(scan([]byte("k3"), []byte("k7"))); got != "[k3=v3 k4=v4 k5=v5 k6=v6]" {
+		t.Errorf("Expected k3 to k6, got %s", got)
+	}
+	if got := scan([]byte("k7"), nil); len(got) != 3 {
+		t.Errorf("Expected k7 to k9 from an unbounded scan, got %v", got)
+	}
+	// The empty end bound is an empty range, not an unbounded one
+	if got := scan(nil, []byte{}); len(got) != 0 {
+		t.Errorf("Expected nothing before the empty key, got %v", got)
+	}
+
+	// Closing partway through stops the stream
+	it, err := c.Scan(nil, nil)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if !it.Next() || string(it.Key()) != "k1" {
+		t.Errorf("Expected k1 first, got %s", it.Key())
+	}
+	if err := it.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if it.Next() {
+		t.Error("Expected no more pairs after Close")
+	}
+}
+
+func TestClient_ScanPrefix(t *testing.T) {
+	store, err := storage.NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for _, key := range []string{"user:1", "user:2", "config:db", "usera"} {
+		if err := c.Put([]byte(key), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	scan := func(prefix string) []string {
+		it, err := c.ScanPrefix([]byte(prefix))
+		if err != nil {
+			t.Fatalf("ScanPrefix failed: %v", err)
+		}
+		var keys []string
+		for it.Next() {
+			keys = append(keys, string(it.Key()))
+		}
+		if err := it.Close(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+		return keys
+	}
+
+	if got := fmt.Sprint(scan("user:")); got != "[user:1 user:2]" {
+		t.Errorf("Expected the user: keys, got %s", got)
+	}
+	if got := scan(""); len(got) != 4 {
+		t.Errorf("Expected every key for an empty prefix, got %v", got)
+	}
+	if got := scan("nothing"); len(got) != 0 {
+		t.Errorf("Expected no keys, got %v", got)
+	}
+}
+
+func TestClient_Keys(t *testing.T) {
+	store, err := storage.NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	keys, err := c.Keys()
+	if err != nil {
+		t.Fatalf("Keys failed: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("Expected no keys, got %q", keys)
+	}
+
+	for _, key := range []string{"user:2", "config:db", "user:1"} {
+		if err := c.Put([]byte(key), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	keys, err = c.Keys()
+	if err != nil {
+		t.Fatalf("Keys failed: %v", err)
+	}
+	if got := fmt.Sprintf("%s", keys); got != "[config:db user:1 user:2]" {
+		t.Errorf("Expected [config:db user:1 user:2], got %s", got)
+	}
+}
+
+func TestClient_ScanLimitAndReverse(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%02d", i)
+		if err := c.Put([]byte(key), []byte("value-"+key)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	scan := func(start, end []byte, opts ScanOptions) string {
+		it, err := c.ScanCtx(context.Background(), start, end, opts)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		var keys []string
+		for it.Next() {
+			if string(it.Value()) != "value-"+string(it.Key()) {
+				t.Errorf("Unexpected value %q for %s", it.Value(), it.Key())
+			}
+			keys = append(keys, string(it.Key()))
+		}
+		if err := it.Close(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+		return fmt.Sprint(keys)
+	}
+
+	cases := []struct {
+		start, end string
+		opts       ScanOptions
+		expected   string
+	}{
+		{"key10", "key20", ScanOptions{Limit: 3}, "[key10 key11 key12]"},
+		{"key10", "key13", ScanOptions{Limit: 10}, "[key10 key11 key12]"},
+		{"key10", "key14", ScanOptions{Reverse: true}, "[key13 key12 key11 key10]"},
+		{"key10", "key50", ScanOptions{Limit: 3, Reverse: true}, "[key49 key48 key47]"},
+		{"", "", ScanOptions{Limit: 2, Reverse: true}, "[key99 key98]"},
+		{"key98", "", ScanOptions{Limit: 5}, "[key98 key99]"},
+		{"key10", "key13", ScanOptions{Consistency: storage.ScanConsistentPrefix}, "[key10 key11 key12]"},
+		{"key10", "key50", ScanOptions{Limit: 3, Reverse: true, Consistency: storage.ScanConsistentPrefix}, "[key49 key48 key47]"},
+	}
+	for _, tc := range cases {
+		var start, end []byte
+		if tc.start != "" {
+			start = []byte(tc.start)
+		}
+		if tc.end != "" {
+			end = []byte(tc.end)
+		}
+		if got := scan(start, end, tc.opts); got != tc.expected {
+			t.Errorf("Scan [%s, %s) %+v: expected %s, got %s", tc.start, tc.end, tc.opts, tc.expected, got)
+		}
+	}
+
+	if _, err := c.ScanCtx(context.Background(), nil, nil, ScanOptions{Limit: -1}); err == nil {
+		t.Error("Expected a negative limit to be rejected")
+	}
+}
+
+func TestClient_ScanCancelledMidStream(t *testing.T) {
+	store := storage.NewMemStorage()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Far more data than gRPC buffers ahead of the reader
+	const n = 2000
+	value := make([]byte, 1024)
+	for i := 0; i < n; i++ {
+		store.Put([]byte(fmt.Sprintf("key%04d", i)), value)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	it, err := c.ScanCtx(ctx, nil, nil, ScanOptions{})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	read := 0
+	for it.Next() {
+		read++
+		if read == 10 {
+			cancel()
+		}
+	}
+	if read >= n {
+		t.Errorf("Expected the scan to stop early, read all %d pairs", read)
+	}
+	if err := it.Close(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"godatabase/internal/storage"
+)
+
+func TestClient_SplitRangesMatchesServer(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// An empty store splits on empty boundaries, which must not come back
+	// as unbounded ones
+	ranges, err := c.SplitRanges(3)
+	if err != nil {
+		t.Fatalf("SplitRanges failed: %v", err)
+	}
+	if ranges[0].Contains([]byte("key")) || ranges[1].Contains([]byte("key")) || !ranges[2].Contains([]byte("key")) {
+		t.Errorf("Expected only the last range of an empty store to hold keys, got %q", ranges)
+	}
+
+	for i := 0; i < 200; i++ {
+		c.Put([]byte(fmt.Sprintf("key%03d", i)), []byte("value"))
+	}
+	ranges, err = c.SplitRanges(4)
+	if err != nil {
+		t.Fatalf("SplitRanges failed: %v", err)
+	}
+	want, err := store.SplitRanges(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Errorf("Expected %q, got %q", want, ranges)
+	}
+}
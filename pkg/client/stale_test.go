@@ -0,0 +1,43 @@
+package client
+
+import (
+	"testing"
+
+	"godatabase/internal/storage"
+)
+
+// electingStorage serves every read as if its cluster were mid-election
+type electingStorage struct {
+	storage.Storage
+}
+
+func (e *electingStorage) GetWithStaleness(key []byte) ([]byte, bool, error) {
+	value, err := e.Storage.Get(key)
+	return value, true, err
+}
+
+func TestClient_GetReportsStaleReads(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.Put([]byte("key"), []byte("value"))
+
+	for _, s := range []storage.Storage{store, &electingStorage{Storage: store}} {
+		c, err := NewClient(startServer(t, s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		_, isElecting := s.(*electingStorage)
+		value, stale, err := c.GetWithStaleness([]byte("key"))
+		if err != nil || string(value) != "value" {
+			t.Fatalf("Expected value, got %q, %v", value, err)
+		}
+		if stale != isElecting {
+			t.Errorf("%T: Expected stale=%v, got %v", s, isElecting, stale)
+		}
+	}
+}
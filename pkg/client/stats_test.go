@@ -0,0 +1,86 @@
+package client
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"godatabase/internal/storage"
+)
+
+func TestClient_Stats(t *testing.T) {
+	store, err := storage.NewStorageEngine(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := c.Put([]byte(fmt.Sprintf("key%d", i)), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := c.Delete([]byte("key0")); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.KeyCount != 4 {
+		t.Errorf("Expected 4 keys, got %d", stats.KeyCount)
+	}
+	if stats.Backend != "custom" {
+		t.Errorf("Expected the custom backend, got %q", stats.Backend)
+	}
+	if size := c.Size(); size != 4 {
+		t.Errorf("Expected Size to report 4 keys, got %d", size)
+	}
+}
+
+func TestClient_SizeCountsKeys(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	addr := startServer(t, store)
+	c, err := NewClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if size, err := c.SizeE(); err != nil || size != 0 {
+		t.Errorf("Expected an empty store, got %d (%v)", size, err)
+	}
+	for i := 0; i < 25; i++ {
+		if err := c.Put([]byte(fmt.Sprintf("key%d", i)), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if size, err := c.SizeE(); err != nil || size != 25 {
+		t.Errorf("Expected 25 keys, got %d (%v)", size, err)
+	}
+
+	// Buffered writes are flushed before counting
+	buffered, err := NewBufferedClient(addr, BufferConfig{MaxPending: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buffered.Close()
+	for i := 25; i < 30; i++ {
+		if err := buffered.Put([]byte(fmt.Sprintf("key%d", i)), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if size := buffered.Size(); size != 30 {
+		t.Errorf("Expected 30 keys, got %d", size)
+	}
+}
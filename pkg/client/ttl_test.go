@@ -0,0 +1,37 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+func TestClient_PutWithTTL(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.PutWithTTL([]byte("cache:key"), []byte("value"), 100*time.Millisecond); err != nil {
+		t.Fatalf("PutWithTTL failed: %v", err)
+	}
+	if value, err := c.Get([]byte("cache:key")); err != nil || string(value) != "value" {
+		t.Fatalf("Expected value before expiry, got %q (%v)", value, err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if _, err := c.Get([]byte("cache:key")); err == nil {
+		t.Error("Expected key to have expired")
+	}
+	if err := c.PutWithTTL([]byte("key"), []byte("value"), 0); err == nil {
+		t.Error("Expected a zero ttl to be rejected")
+	}
+}
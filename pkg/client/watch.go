@@ -0,0 +1,57 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"godatabase/internal/rpc/proto"
+)
+
+// WaitForValue blocks until key holds want, or returns ctx's error once
+// ctx is done. It returns at once if key already holds want.
+func (c *Client) WaitForValue(ctx context.Context, key, want []byte) error {
+	_, err := c.WaitFor(ctx, key, func(value []byte, found bool) bool {
+		return found && bytes.Equal(value, want)
+	})
+	return err
+}
+
+// WaitFor blocks until match accepts key's value, and returns that value.
+// match is called with the current value first and then after every write
+// to key; found is false while the key doesn't exist. If ctx is done
+// first, WaitFor returns ctx's error.
+//
+// The server pushes each change, so waiting costs no polling. Buffered
+// writes are flushed first, so the wait observes them.
+func (c *Client) WaitFor(ctx context.Context, key []byte, match func(value []byte, found bool) bool) ([]byte, error) {
+	if err := c.flushBuffer(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.rpc().Watch(ctx, &proto.WatchRequest{Key: key})
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if err == io.EOF {
+				return nil, errors.New("watch ended by server")
+			}
+			return nil, err
+		}
+
+		if match(event.Value, event.Found) {
+			return event.Value, nil
+		}
+	}
+}
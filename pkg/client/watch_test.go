@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"godatabase/internal/storage"
+)
+
+// getCounter counts reads of the storage underneath
+type getCounter struct {
+	storage.Storage
+	gets int32
+}
+
+func (g *getCounter) Get(key []byte) ([]byte, error) {
+	atomic.AddInt32(&g.gets, 1)
+	return g.Storage.Get(key)
+}
+
+func TestClient_WaitForValue(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	counted := &getCounter{Storage: store}
+	addr := startServer(t, counted)
+
+	waiter, err := NewClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer waiter.Close()
+	setter, err := NewClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer setter.Close()
+
+	const delay = 200 * time.Millisecond
+	var setAt atomic.Value
+	go func() {
+		time.Sleep(delay)
+		setter.Put([]byte("lock"), []byte("held"))
+		time.Sleep(delay)
+		setAt.Store(time.Now())
+		setter.Put([]byte("lock"), []byte("released"))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := waiter.WaitForValue(ctx, []byte("lock"), []byte("released")); err != nil {
+		t.Fatalf("WaitForValue failed: %v", err)
+	}
+	if lag := time.Since(setAt.Load().(time.Time)); lag > 100*time.Millisecond {
+		t.Errorf("Expected waiter to unblock promptly, took %v", lag)
+	}
+
+	// The server read the key once, when the watch started
+	if gets := atomic.LoadInt32(&counted.gets); gets != 1 {
+		t.Errorf("Expected a single read while waiting, got %d", gets)
+	}
+
+	// A value that already matches returns at once
+	if err := waiter.WaitForValue(ctx, []byte("lock"), []byte("released")); err != nil {
+		t.Errorf("WaitForValue on a matching key failed: %v", err)
+	}
+}
+
+func TestClient_WaitForPredicate(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go func() {
+		for i := 1; i <= 5; i++ {
+			time.Sleep(20 * time.Millisecond)
+			c.Put([]byte("counter"), []byte(strconv.Itoa(i)))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	value, err := c.WaitFor(ctx, []byte("counter"), func(value []byte, found bool) bool {
+		n, _ := strconv.Atoi(string(value))
+		return found && n >= 3
+	})
+	if err != nil {
+		t.Fatalf("WaitFor failed: %v", err)
+	}
+	if string(value) != "3" {
+		t.Errorf("Expected to stop at 3, got %s", value)
+	}
+
+	// Nothing deletes the key, so waiting for its absence times out
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = c.WaitFor(ctx, []byte("counter"), func(value []byte, found bool) bool {
+		return !found
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+}
+
+// failingGetStorage fails every read with an error other than a missing key
+type failingGetStorage struct {
+	storage.Storage
+}
+
+func (f failingGetStorage) Get(key []byte) ([]byte, error) {
+	return nil, errors.New("disk read failed")
+}
+
+func TestClient_WaitForReportsFailedRead(t *testing.T) {
+	store, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	c, err := NewClient(startServer(t, failingGetStorage{store}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Waiting for a missing key must not mistake the failed read for one
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = c.WaitFor(ctx, []byte("key"), func(value []byte, found bool) bool {
+		return !found
+	})
+	if err == nil || !strings.Contains(err.Error(), "disk read failed") {
+		t.Errorf("Expected the read error, got %v", err)
+	}
+}